@@ -19,7 +19,10 @@ var analyzeCmd = &cobra.Command{
 	Args:   cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ctx := cmd.Context()
-		state, err := state.New(ctx, flags.PostgresURL(), flags.StateSchema(), state.WithPgrollVersion(Version))
+		state, err := state.New(ctx, flags.PostgresURL(), flags.StateSchema(),
+			state.WithPgrollVersion(Version),
+			state.WithConnectionAttempts(flags.ConnectionAttempts(), flags.ConnectionRetryDelay()),
+		)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func backfillCmd() *cobra.Command {
+	var dryRun bool
+	var samples int
+
+	backfillCmd := &cobra.Command{
+		Use:       "backfill <file>",
+		Short:     "Count the rows that the backfill of a migration would update, without updating them",
+		Example:   "backfill --dry-run migrations/03_lowercase_emails.yaml",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"file"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			fileName := args[0]
+
+			if !dryRun {
+				return fmt.Errorf("backfills are run by `pgroll start`; only --dry-run is supported")
+			}
+			if samples < 0 {
+				return fmt.Errorf("--sample must not be negative")
+			}
+
+			// Create a roll instance and check if pgroll is initialized
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			migration, err := migrations.ReadMigration(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
+			if err != nil {
+				return err
+			}
+
+			tables, err := m.DryRunBackfill(ctx, migration, backfill.NewConfig(), samples)
+			if err != nil {
+				return fmt.Errorf("failed to dry run backfill of migration %q: %w", migration.Name, err)
+			}
+			return writeBackfillDryRun(os.Stdout, tables)
+		},
+	}
+
+	backfillCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Count the rows that the backfill would update and change, without updating them")
+	backfillCmd.Flags().IntVar(&samples, "sample", 0, "Number of changes to each column to print, with the values before and after the backfill")
+
+	return backfillCmd
+}
+
+// writeBackfillDryRun writes the number of rows of each table that a backfill
+// would update and change as a table, followed by the sampled changes to each
+// column.
+func writeBackfillDryRun(w io.Writer, tables []backfill.TableDryRun) error {
+	if len(tables) == 0 {
+		_, err := fmt.Fprintln(w, "The migration doesn't backfill any table.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tROWS\tCOLUMN\tCHANGED")
+	for _, table := range tables {
+		if len(table.Columns) == 0 {
+			fmt.Fprintf(tw, "%s\t%d\t\t\n", table.Table, table.Rows)
+		}
+		for _, column := range table.Columns {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\n", table.Table, table.Rows, column.Column, column.Changed)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		for _, column := range table.Columns {
+			if len(column.Samples) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "\nChanges to column %q of table %q:\n", column.Column, table.Table)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "BEFORE\tAFTER")
+			for _, change := range column.Samples {
+				fmt.Fprintf(tw, "%s\t%s\n", sampleValue(change.Before), sampleValue(change.After))
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sampleValue formats a sampled value, which is nil for NULL
+func sampleValue(v *string) string {
+	if v == nil {
+		return "NULL"
+	}
+	return *v
+}
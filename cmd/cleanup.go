@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove pgroll triggers left in place by `complete --keep-triggers`",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Create a roll instance and check if pgroll is initialized
+		m, err := NewRollWithInitCheck(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		sp, _ := pterm.DefaultSpinner.WithText("Removing pgroll triggers...").Start()
+		functions, err := m.Cleanup(cmd.Context())
+		if err != nil {
+			sp.Fail(fmt.Sprintf("Failed to clean up: %s", err))
+			return err
+		}
+
+		if len(functions) == 0 {
+			sp.Success("Nothing to clean up")
+			return nil
+		}
+
+		sp.Success(fmt.Sprintf("Removed trigger functions and their triggers: %s", strings.Join(functions, ", ")))
+		return nil
+	},
+}
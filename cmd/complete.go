@@ -4,30 +4,100 @@ package cmd
 
 import (
 	"fmt"
+	"maps"
+	"os"
+	"slices"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/xataio/pgroll/cmd/flags"
+	"github.com/xataio/pgroll/pkg/roll"
 )
 
-var completeCmd = &cobra.Command{
-	Use:   "complete <file>",
-	Short: "Complete an ongoing migration with the operations present in the given file",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		// Create a roll instance and check if pgroll is initialized
-		m, err := NewRollWithInitCheck(cmd.Context())
-		if err != nil {
-			return err
-		}
-		defer m.Close()
-
-		sp, _ := pterm.DefaultSpinner.WithText("Completing migration...").Start()
-		err = m.Complete(cmd.Context())
-		if err != nil {
-			sp.Fail(fmt.Sprintf("Failed to complete migration: %s", err))
-			return err
-		}
-
-		sp.Success("Migration successful!")
-		return nil
-	},
+func completeCmd() *cobra.Command {
+	var dryRun bool
+	var fallback string
+
+	completeCmd := &cobra.Command{
+		Use:   "complete <file>",
+		Short: "Complete an ongoing migration with the operations present in the given file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Create a roll instance and check if pgroll is initialized
+			m, err := NewRollWithInitCheck(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			if dryRun && fallback != "" {
+				return fmt.Errorf("--force-on-backfill-incomplete can't be used with --dry-run")
+			}
+
+			if dryRun {
+				groups, err := m.DryRunComplete(cmd.Context())
+				if err != nil {
+					return fmt.Errorf("failed to dry run migration completion: %w", err)
+				}
+				return writeSQLGroups(os.Stdout, groups)
+			}
+
+			if flags.KeepTriggers() {
+				err := reportWarnings("--keep-triggers is a debugging aid and must not be used in production. " +
+					"pgroll triggers and trigger functions will be left disabled in the schema; " +
+					"run `pgroll cleanup` to remove them before starting another migration.")
+				if err != nil {
+					return err
+				}
+			}
+
+			if fallback != "" {
+				filled, err := m.FillIncompleteBackfill(cmd.Context(), fallback)
+				if err != nil {
+					return fmt.Errorf("failed to fill incomplete backfill: %w", err)
+				}
+				reportFallbackRows(filled)
+			}
+
+			sp, _ := pterm.DefaultSpinner.WithText("Completing migration...").Start()
+			err = roll.NewRunner(m).Complete(cmd.Context())
+			if err != nil {
+				sp.Fail(fmt.Sprintf("Failed to complete migration: %s", err))
+				return err
+			}
+
+			sp.Success("Migration successful!")
+			return nil
+		},
+	}
+
+	completeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the SQL that completing the migration would execute, without executing it")
+	completeCmd.Flags().StringVar(&fallback, "force-on-backfill-incomplete", "", "Set the columns of rows that still need a backfill to this SQL expression before completing the migration")
+	completeCmd.Flags().Bool("keep-triggers", false, "Leave pgroll triggers and trigger functions in place (disabled) for debugging; not for production use")
+
+	completeCmd.Flags().Int("constraint-validation-concurrency", roll.DefaultConstraintValidationConcurrency, "Maximum number of tables on which constraints are validated concurrently")
+	completeCmd.Flags().Bool("maintenance-mode", false, "Set the schema's maintenance flag while taking locks that block the application's queries")
+	completeCmd.Flags().Duration("maintenance-mode-wait", 0, "Time to wait after setting the maintenance flag before taking the locks")
+
+	viper.BindPFlag("KEEP_TRIGGERS", completeCmd.Flags().Lookup("keep-triggers"))
+	viper.BindPFlag("CONSTRAINT_VALIDATION_CONCURRENCY", completeCmd.Flags().Lookup("constraint-validation-concurrency"))
+	viper.BindPFlag("MAINTENANCE_MODE", completeCmd.Flags().Lookup("maintenance-mode"))
+	viper.BindPFlag("MAINTENANCE_MODE_WAIT", completeCmd.Flags().Lookup("maintenance-mode-wait"))
+
+	return completeCmd
+}
+
+// reportFallbackRows prints the number of rows in each table that were given
+// the fallback value because their backfill was incomplete.
+func reportFallbackRows(filled map[string]int64) {
+	if len(filled) == 0 {
+		pterm.Info.Println("No rows needed the fallback value")
+		return
+	}
+
+	tables := slices.Sorted(maps.Keys(filled))
+	for _, table := range tables {
+		pterm.Warning.Printfln("%d rows of table %q were given the fallback value", filled[table], table)
+	}
 }
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configFileNames are the names of the config files pgroll looks for, in
+// order of preference.
+var configFileNames = []string{"pgroll.yaml", "pgroll.yml"}
+
+// configKeys maps the settings accepted in the config file, named after their
+// CLI flags, to the viper keys of those flags.
+var configKeys = map[string]string{
+	"postgres-url":                "PG_URL",
+	"schema":                      "SCHEMA",
+	"pgroll-schema":               "STATE_SCHEMA",
+	"internal-prefix":             "INTERNAL_PREFIX",
+	"lock-timeout":                "LOCK_TIMEOUT",
+	"idle-in-transaction-timeout": "IDLE_IN_TRANSACTION_TIMEOUT",
+	"backfill-batch-size":         "BACKFILL_BATCH_SIZE",
+	"backfill-batch-delay":        "BACKFILL_BATCH_DELAY",
+	"backfill-batch-keys":         "BACKFILL_BATCH_KEYS",
+	"backfill-isolation-level":    "BACKFILL_ISOLATION_LEVEL",
+	"backfill-role":               "BACKFILL_ROLE",
+	"backfill-setting":            "BACKFILL_SETTINGS",
+	"backfill-column-concurrency": "BACKFILL_COLUMN_CONCURRENCY",
+	"backfill-parallelism":        "BACKFILL_PARALLELISM",
+	"maintenance-mode":            "MAINTENANCE_MODE",
+	"maintenance-mode-wait":       "MAINTENANCE_MODE_WAIT",
+	"environment":                 "ENVIRONMENT",
+	"view-options":                "VIEW_OPTIONS",
+}
+
+// findConfigFile returns the path of the config file in the current
+// directory or, failing that, the user's home directory. An empty string is
+// returned if there is no config file.
+func findConfigFile() string {
+	dirs := []string{"."}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+
+	for _, dir := range dirs {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// readConfigFile loads the settings from the config file, if there is one.
+// The settings are merged into viper's config layer, so values set with a
+// CLI flag or environment variable take precedence over them.
+func readConfigFile() error {
+	path := findConfigFile()
+	if path == "" {
+		return nil
+	}
+
+	cfg := viper.New()
+	cfg.SetConfigFile(path)
+	cfg.SetConfigType("yaml")
+	if err := cfg.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file %q: %w", path, err)
+	}
+
+	settings := make(map[string]any)
+	for _, key := range cfg.AllKeys() {
+		viperKey, ok := configKeys[key]
+		if !ok {
+			return fmt.Errorf("unknown setting %q in config file %q (supported settings: %s)",
+				key, path, strings.Join(supportedConfigKeys(), ", "))
+		}
+		settings[viperKey] = cfg.Get(key)
+	}
+
+	return viper.MergeConfigMap(settings)
+}
+
+func supportedConfigKeys() []string {
+	keys := make([]string, 0, len(configKeys))
+	for k := range configKeys {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
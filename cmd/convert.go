@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"github.com/xataio/pgroll/pkg/migrations"
 	"github.com/xataio/pgroll/pkg/sql2pgroll"
@@ -37,6 +38,12 @@ func convertCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to write migration to stdout: %w", err)
 			}
+
+			// The migration is written to stdout, so report the operations that
+			// need review on stderr
+			for _, warning := range sql2pgroll.Warnings(migration.Operations) {
+				pterm.Warning.WithWriter(os.Stderr).Println(warning)
+			}
 			return nil
 		},
 	}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func diffCmd() *cobra.Command {
+	var format string
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <from> [<to>]",
+		Short: "Print the differences between the schema after two migrations",
+		Long: "Print the tables, columns, indexes and constraints that differ between the schema after migration <from> and the schema after migration <to>. " +
+			"If <to> is omitted, <from> is compared with the latest version of the live schema.",
+		Example:   "diff 01_create_tables 02_add_email --format json",
+		Args:      cobra.RangeArgs(1, 2),
+		ValidArgs: []string{"from", "to"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// Create a roll instance and check if pgroll is initialized
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			to := ""
+			if len(args) == 2 {
+				to = args[1]
+			}
+
+			return m.WriteDiff(ctx, os.Stdout, args[0], to, roll.DiffFormat(format))
+		},
+	}
+
+	diffCmd.Flags().StringVarP(&format, "format", "f", string(roll.DiffFormatText), "output format of the diff: text or json")
+
+	return diffCmd
+}
@@ -2,6 +2,30 @@
 
 package cmd
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var errPGRollNotInitialized = errors.New("pgroll is not initialized, run 'pgroll init' to initialize")
+
+// WarningsAsErrorsError is returned when warnings are reported with the
+// strict setting enabled.
+type WarningsAsErrorsError struct {
+	Count int
+}
+
+func (e WarningsAsErrorsError) Error() string {
+	return fmt.Sprintf("%d warning(s) reported with --strict enabled", e.Count)
+}
+
+// MigrationInvalidError is returned when a migration file is found to be
+// invalid by the validate command.
+type MigrationInvalidError struct {
+	File  string
+	Count int
+}
+
+func (e MigrationInvalidError) Error() string {
+	return fmt.Sprintf("migration %q is invalid: %d error(s) found", e.File, e.Count)
+}
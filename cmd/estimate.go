@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/xataio/pgroll/cmd/flags"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func estimateCmd() *cobra.Command {
+	estimateCmd := &cobra.Command{
+		Use:       "estimate <file>",
+		Short:     "Estimate how long the index builds, backfills and validations of a migration take",
+		Example:   "estimate migrations/03_add_index.yaml",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"file"},
+		PreRun: func(cmd *cobra.Command, _ []string) {
+			viper.BindPFlag("BACKFILL_BATCH_SIZE", cmd.Flags().Lookup("backfill-batch-size"))
+			viper.BindPFlag("BACKFILL_BATCH_DELAY", cmd.Flags().Lookup("backfill-batch-delay"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			fileName := args[0]
+
+			// Create a roll instance and check if pgroll is initialized
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			migration, err := migrations.ReadMigration(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
+			if err != nil {
+				return err
+			}
+
+			batchSizeOpt, err := batchSizeOption()
+			if err != nil {
+				return err
+			}
+			batchKeyOpts, err := batchKeyOptions()
+			if err != nil {
+				return err
+			}
+			c := backfill.NewConfig(append(batchKeyOpts,
+				batchSizeOpt,
+				backfill.WithBatchDelay(flags.BackfillBatchDelay()),
+			)...)
+
+			estimate, err := m.Estimate(ctx, migration, c)
+			if err != nil {
+				return fmt.Errorf("failed to estimate migration %q: %w", migration.Name, err)
+			}
+			return writeEstimate(os.Stdout, estimate)
+		},
+	}
+
+	estimateCmd.Flags().String("backfill-batch-size", strconv.Itoa(backfill.DefaultBatchSize), "Number of rows backfilled in each batch, or 'auto' to size batches by the width of each table's rows")
+	estimateCmd.Flags().Duration("backfill-batch-delay", backfill.DefaultDelay, "Duration of delay between batch backfills (eg. 1s, 1000ms)")
+
+	return estimateCmd
+}
+
+// writeEstimate writes the estimated duration of each piece of work of a
+// migration as a table, followed by the estimated total.
+func writeEstimate(w io.Writer, estimate *roll.MigrationEstimate) error {
+	if _, err := fmt.Fprintln(w, "These are rough estimates based on table statistics; actual durations depend on the hardware, configuration and load of the database."); err != nil {
+		return err
+	}
+	if len(estimate.Work) == 0 {
+		_, err := fmt.Fprintln(w, "\nThe migration doesn't build indexes, backfill or validate constraints on existing tables.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nPHASE\tOPERATION\tWORK\tTABLE\tROWS\tSIZE\tESTIMATE")
+	for _, work := range estimate.Work {
+		duration := "~" + formatDuration(work.Duration)
+		if work.Batches > 0 {
+			duration += fmt.Sprintf(" (%d batches)", work.Batches)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			work.Phase, work.Operation, work.Work, work.Table, work.Rows, formatBytes(work.Bytes), duration)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nEstimated total: ~%s\n", formatDuration(estimate.Total))
+	return err
+}
+
+// formatDuration rounds the duration to a precision that doesn't suggest more
+// accuracy than an estimate has
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return "<1s"
+	case d < time.Minute:
+		return d.Round(time.Second).String()
+	default:
+		return d.Round(time.Minute).String()
+	}
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
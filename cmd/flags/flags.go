@@ -3,10 +3,24 @@
 package flags
 
 import (
+	"os"
+	"time"
+
 	"github.com/spf13/viper"
+	"github.com/xataio/pgroll/internal/connstr"
 )
 
+// PostgresURL is the URL of the Postgres instance to connect to. If no URL is
+// set with the --postgres-url flag, the PGROLL_PG_URL environment variable or
+// the config file, the URL is assembled from the standard libpq environment
+// variables, such as PGHOST and PGUSER. The flag's default is used if none of
+// those are set either.
 func PostgresURL() string {
+	if !viper.IsSet("PG_URL") {
+		if url := connstr.FromEnv(os.Getenv); url != "" {
+			return url
+		}
+	}
 	return viper.GetString("PG_URL")
 }
 
@@ -18,18 +32,179 @@ func StateSchema() string {
 	return viper.GetString("STATE_SCHEMA")
 }
 
+// InternalPrefix is the prefix of the names of the objects that pgroll creates
+// for its own use.
+func InternalPrefix() string {
+	return viper.GetString("INTERNAL_PREFIX")
+}
+
 func LockTimeout() int {
 	return viper.GetInt("LOCK_TIMEOUT")
 }
 
+func IdleInTransactionTimeout() int {
+	return viper.GetInt("IDLE_IN_TRANSACTION_TIMEOUT")
+}
+
+// BackfillBatchSize is the number of rows backfilled in each batch, or "auto"
+// to size batches by the width of each table's rows.
+func BackfillBatchSize() string {
+	return viper.GetString("BACKFILL_BATCH_SIZE")
+}
+
+func BackfillBatchDelay() time.Duration {
+	return viper.GetDuration("BACKFILL_BATCH_DELAY")
+}
+
+func BackfillWithoutTriggers() []string {
+	return viper.GetStringSlice("BACKFILL_WITHOUT_TRIGGERS")
+}
+
+func BackfillDisableAutovacuum() bool {
+	return viper.GetBool("BACKFILL_DISABLE_AUTOVACUUM")
+}
+
+// NeedsBackfillColumn is the name of the column that marks the rows of each
+// table to backfill.
+func NeedsBackfillColumn() string {
+	return viper.GetString("NEEDS_BACKFILL_COLUMN")
+}
+
+// BackfillSeparateMark is whether to mark each batch of rows as backfilled
+// with a separate statement from the one that backfills their data.
+func BackfillSeparateMark() bool {
+	return viper.GetBool("BACKFILL_SEPARATE_MARK")
+}
+
+// BackfillIsolationLevel is the transaction isolation level of each backfill
+// batch, or empty for the session's default level.
+func BackfillIsolationLevel() string {
+	return viper.GetString("BACKFILL_ISOLATION_LEVEL")
+}
+
+// BackfillRole is the role as which the backfill batches are run, or empty
+// for the role of the migration.
+func BackfillRole() string {
+	return viper.GetString("BACKFILL_ROLE")
+}
+
+// BackfillSettings are the settings of the session in which the backfill
+// batches are run, each as a name=value pair.
+func BackfillSettings() []string {
+	return viper.GetStringSlice("BACKFILL_SETTINGS")
+}
+
+// BackfillColumnConcurrency is the number of columns of a table that are
+// backfilled at once, each in a pass of its own.
+func BackfillColumnConcurrency() int {
+	return viper.GetInt("BACKFILL_COLUMN_CONCURRENCY")
+}
+
+// BackfillParallelism is the number of ranges of a table's rows that are
+// backfilled at once, each on a connection of its own.
+func BackfillParallelism() int {
+	return viper.GetInt("BACKFILL_PARALLELISM")
+}
+
+// VerifyReversible is whether to check, after backfilling, that the down SQL
+// of each column change reverses its up SQL.
+func VerifyReversible() bool {
+	return viper.GetBool("VERIFY_REVERSIBLE")
+}
+
+// Environment is the environment being migrated. Migrations tagged with other
+// environments are skipped.
+func Environment() string {
+	return viper.GetString("ENVIRONMENT")
+}
+
+// BackfillBatchKey is the key by which the rows of a table are paged during
+// a backfill.
+type BackfillBatchKey struct {
+	Table string   `mapstructure:"table"`
+	Key   []string `mapstructure:"key"`
+}
+
+func BackfillBatchKeys() ([]BackfillBatchKey, error) {
+	var keys []BackfillBatchKey
+	err := viper.UnmarshalKey("BACKFILL_BATCH_KEYS", &keys)
+	return keys, err
+}
+
+func ConnectionAttempts() int {
+	return viper.GetInt("CONNECTION_ATTEMPTS")
+}
+
+func ConnectionRetryDelay() time.Duration {
+	return viper.GetDuration("CONNECTION_RETRY_DELAY")
+}
+
 func SkipValidation() bool { return viper.GetBool("SKIP_VALIDATION") }
 
+func ReorderOperations() bool { return viper.GetBool("REORDER_OPERATIONS") }
+
+func MigrationStacking() bool { return viper.GetBool("MIGRATION_STACKING") }
+
+func KeepTriggers() bool { return viper.GetBool("KEEP_TRIGGERS") }
+
+func ConstraintValidationConcurrency() int {
+	return viper.GetInt("CONSTRAINT_VALIDATION_CONCURRENCY")
+}
+
+func MaintenanceMode() bool { return viper.GetBool("MAINTENANCE_MODE") }
+
+func MaintenanceModeWait() time.Duration {
+	return viper.GetDuration("MAINTENANCE_MODE_WAIT")
+}
+
 func Role() string {
 	return viper.GetString("ROLE")
 }
 
+func ObjectOwner() string {
+	return viper.GetString("OBJECT_OWNER")
+}
+
+func CacheDir() string {
+	return viper.GetString("CACHE_DIR")
+}
+
 func Verbose() bool { return viper.GetBool("VERBOSE") }
 
+// LogFormat is the format of the migration log, either "text" or "json".
+func LogFormat() string { return viper.GetString("LOG_FORMAT") }
+
+// Strict is whether warnings are treated as errors.
+func Strict() bool { return viper.GetBool("STRICT") }
+
+func Progress() bool { return viper.GetBool("PROGRESS") }
+
 func UseVersionSchema() bool {
 	return viper.GetBool("USE_VERSION_SCHEMA")
 }
+
+func SecurityInvokerViews() bool {
+	return viper.GetBool("SECURITY_INVOKER_VIEWS")
+}
+
+// ViewOption sets the options of the view that exposes a table in version
+// schemas.
+type ViewOption struct {
+	Table           string `mapstructure:"table"`
+	SecurityInvoker *bool  `mapstructure:"security-invoker"`
+	SecurityBarrier bool   `mapstructure:"security-barrier"`
+}
+
+func ViewOptions() ([]ViewOption, error) {
+	var opts []ViewOption
+	err := viper.UnmarshalKey("VIEW_OPTIONS", &opts)
+	return opts, err
+}
+
+func PerTableTransactions() bool {
+	return viper.GetBool("PER_TABLE_TRANSACTIONS")
+}
+
+func ConcurrentIndexes() bool {
+	return viper.GetBool("CONCURRENT_INDEXES")
+}
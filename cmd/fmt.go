@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func fmtCmd() *cobra.Command {
+	var check bool
+
+	fmtCmd := &cobra.Command{
+		Use:       "fmt <file>",
+		Short:     "Format a migration file in canonical form",
+		Example:   "fmt migrations/03_my_migration.yaml",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"file"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fileName := args[0]
+
+			contents, err := os.ReadFile(fileName)
+			if err != nil {
+				return fmt.Errorf("failed to read migration file: %w", err)
+			}
+
+			formatted, err := migrations.FormatMigration(fileName, contents)
+			if err != nil {
+				return fmt.Errorf("failed to format migration file: %w", err)
+			}
+
+			if bytes.Equal(contents, formatted) {
+				return nil
+			}
+
+			if check {
+				return fmt.Errorf("migration file %q is not formatted", fileName)
+			}
+
+			if err := os.WriteFile(fileName, formatted, 0o644); err != nil {
+				return fmt.Errorf("failed to write migration file: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	fmtCmd.Flags().BoolVar(&check, "check", false, "Exit with an error if the file is not formatted instead of rewriting it")
+
+	return fmtCmd
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/xataio/pgroll/cmd/flags"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func generateCmd() *cobra.Command {
+	var upFile, downFile string
+	var idempotent bool
+
+	generateCmd := &cobra.Command{
+		Use:       "generate <file>",
+		Short:     "Generate the SQL that applies and reverts a migration",
+		Example:   "generate migrations/03_my_migration.yaml --up up.sql --down down.sql",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"file"},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlag("BACKFILL_BATCH_SIZE", cmd.Flags().Lookup("backfill-batch-size"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			fileName := args[0]
+
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			migration, err := migrations.ReadMigration(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
+			if err != nil {
+				return err
+			}
+
+			// The generated SQL backfills tables in batches of a fixed size
+			batchSize, err := strconv.Atoi(flags.BackfillBatchSize())
+			if err != nil {
+				return fmt.Errorf("invalid backfill-batch-size setting %q: must be a number of rows", flags.BackfillBatchSize())
+			}
+
+			c := backfill.NewConfig(backfill.WithBatchSize(batchSize))
+			generated, err := m.GenerateSQL(ctx, migration, c)
+			if err != nil {
+				return fmt.Errorf("failed to generate SQL for migration %q: %w", migration.Name, err)
+			}
+			if idempotent {
+				generated.Up = roll.IdempotentSQL(generated.Up)
+				generated.Down = roll.IdempotentSQL(generated.Down)
+			}
+
+			if upFile == "" {
+				err = writeSQL(os.Stdout, generated.Up)
+			} else {
+				err = writeSQLFile(upFile, generated.Up)
+			}
+			if err != nil {
+				return err
+			}
+
+			if downFile != "" {
+				return writeSQLFile(downFile, generated.Down)
+			}
+			return nil
+		},
+	}
+
+	generateCmd.Flags().StringVar(&upFile, "up", "", "File to write the SQL that applies the migration to (default: stdout)")
+	generateCmd.Flags().StringVar(&downFile, "down", "", "File to write the SQL that reverts the migration to")
+	generateCmd.Flags().BoolVar(&idempotent, "idempotent", false, "Wrap statements so that they can be re-run on a partially migrated database")
+	generateCmd.Flags().Int("backfill-batch-size", backfill.DefaultBatchSize, "Number of rows backfilled in each batch")
+
+	return generateCmd
+}
+
+func writeSQLFile(fileName string, statements []string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create SQL file: %w", err)
+	}
+	defer file.Close()
+
+	if err := writeSQL(file, statements); err != nil {
+		return fmt.Errorf("failed to write SQL file: %w", err)
+	}
+	return nil
+}
+
+// writeSQL writes the statements to w, each terminated by a semicolon.
+// Comments are written on the lines before the statements they annotate.
+func writeSQL(w io.Writer, statements []string) error {
+	for _, stmt := range statements {
+		if roll.IsComment(stmt) {
+			if _, err := fmt.Fprintf(w, "%s\n", strings.TrimSpace(stmt)); err != nil {
+				return err
+			}
+			continue
+		}
+		stmt = strings.TrimRight(strings.TrimSpace(stmt), ";")
+		if _, err := fmt.Fprintf(w, "%s;\n\n", stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSQLGroups writes the statements of each group to w, preceded by a
+// comment naming the group's phase and operation.
+func writeSQLGroups(w io.Writer, groups []roll.SQLGroup) error {
+	for _, group := range groups {
+		header := "-- " + group.Phase
+		if group.Operation != "" {
+			header += ": " + string(group.Operation)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", header); err != nil {
+			return err
+		}
+		if err := writeSQL(w, group.Statements); err != nil {
+			return err
+		}
+	}
+	return nil
+}
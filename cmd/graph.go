@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func graphCmd() *cobra.Command {
+	var format string
+
+	graphCmd := &cobra.Command{
+		Use:     "graph",
+		Short:   "Print a diagram of the tables and foreign keys in the latest version of the schema",
+		Example: "graph --format mermaid",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			// Create a roll instance and check if pgroll is initialized
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			return m.WriteGraph(ctx, os.Stdout, roll.GraphFormat(format))
+		},
+	}
+
+	graphCmd.Flags().StringVarP(&format, "format", "f", string(roll.GraphFormatDOT), "output format of the diagram: dot or mermaid")
+
+	return graphCmd
+}
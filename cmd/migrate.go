@@ -3,21 +3,24 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
+	"github.com/xataio/pgroll/cmd/flags"
 	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
 )
 
 func migrateCmd() *cobra.Command {
 	var complete bool
-	var batchSize int
-	var batchDelay time.Duration
 
 	migrateCmd := &cobra.Command{
 		Use:       "migrate <directory>",
@@ -25,6 +28,10 @@ func migrateCmd() *cobra.Command {
 		Example:   "migrate ./migrations",
 		Args:      cobra.ExactArgs(1),
 		ValidArgs: []string{"directory"},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			bindBackfillFlags(cmd)
+			viper.BindPFlag("ENVIRONMENT", cmd.Flags().Lookup("environment"))
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			migrationsDir := args[0]
@@ -79,31 +86,98 @@ func migrateCmd() *cobra.Command {
 			}
 
 			// fail early if there is an incompatible migration
-			migs, err := parseMigrations(rawMigs)
+			migs, err := parseMigrations(m, rawMigs)
 			if err != nil {
 				return fmt.Errorf("failed to run migrate: %w", err)
 			}
 
-			backfillConfig := backfill.NewConfig(
-				backfill.WithBatchSize(batchSize),
-				backfill.WithBatchDelay(batchDelay),
-			)
+			// fail early if skipping the migrations that are not tagged for the
+			// environment leaves a migration that can't be applied
+			if err := validateEnvironment(ctx, m, rawMigs, migs); err != nil {
+				return fmt.Errorf("failed to run migrate: %w", err)
+			}
 
-			// Run all migrations after the latest version up to the final migration,
-			// completing each one.
-			for _, mig := range migs[:len(migs)-1] {
-				if err := runMigration(ctx, m, mig, true, backfillConfig); err != nil {
-					return fmt.Errorf("failed to run migration file %q: %w", mig.Name, err)
+			batchSizeOpt, err := batchSizeOption()
+			if err != nil {
+				return err
+			}
+			batchKeyOpts, err := batchKeyOptions()
+			if err != nil {
+				return err
+			}
+			isolationLevelOpt, err := isolationLevelOption()
+			if err != nil {
+				return err
+			}
+			sessionSettingsOpt, err := sessionSettingsOption()
+			if err != nil {
+				return err
+			}
+
+			backfillConfig := backfill.NewConfig(append(batchKeyOpts,
+				batchSizeOpt,
+				backfill.WithBatchDelay(flags.BackfillBatchDelay()),
+				backfill.WithoutTriggers(flags.BackfillWithoutTriggers()...),
+				backfill.WithAutovacuumDisabled(flags.BackfillDisableAutovacuum()),
+				backfill.WithNeedsBackfillColumn(flags.NeedsBackfillColumn()),
+				backfill.WithSeparateBackfillMark(flags.BackfillSeparateMark()),
+				isolationLevelOpt,
+				sessionSettingsOpt,
+				backfill.WithSessionRole(flags.BackfillRole()),
+				backfill.WithColumnConcurrency(flags.BackfillColumnConcurrency()),
+				backfill.WithParallelism(flags.BackfillParallelism()),
+				reversibilityCheckOption(),
+			)...)
+
+			// Run all migrations after the latest version, completing each one
+			// except for the final migration, which is completed only if
+			// requested. Migrations that are not tagged for the environment are
+			// recorded as skipped instead.
+			environment := flags.Environment()
+			last := len(migs) - 1
+			for last >= 0 && !migs[last].AppliesTo(environment) {
+				last--
+			}
+			for i, mig := range migs {
+				if mig.AppliesTo(environment) {
+					err := runMigration(ctx, m, mig, i < last || complete, backfillConfig)
+					if err != nil && i < last {
+						return fmt.Errorf("failed to run migration file %q: %w", mig.Name, err)
+					}
+					if err != nil {
+						return err
+					}
+					continue
 				}
+
+				// Migrations after a final migration that is left active are
+				// skipped by the next run, once it has been completed
+				if last >= 0 && i > last && !complete {
+					break
+				}
+				if err := m.Skip(ctx, mig); err != nil {
+					return fmt.Errorf("failed to skip migration file %q: %w", mig.Name, err)
+				}
+				fmt.Printf("Skipped migration %q: not tagged for environment %q\n", mig.Name, environment)
 			}
 
-			// Run the final migration, completing it only if requested.
-			return runMigration(ctx, m, migs[len(migs)-1], complete, backfillConfig)
+			return nil
 		},
 	}
 
-	migrateCmd.Flags().IntVar(&batchSize, "backfill-batch-size", backfill.DefaultBatchSize, "Number of rows backfilled in each batch")
-	migrateCmd.Flags().DurationVar(&batchDelay, "backfill-batch-delay", backfill.DefaultDelay, "Duration of delay between batch backfills (eg. 1s, 1000ms)")
+	migrateCmd.Flags().String("backfill-batch-size", strconv.Itoa(backfill.DefaultBatchSize), "Number of rows backfilled in each batch, or 'auto' to size batches by the width of each table's rows")
+	migrateCmd.Flags().Duration("backfill-batch-delay", backfill.DefaultDelay, "Duration of delay between batch backfills (eg. 1s, 1000ms)")
+	migrateCmd.Flags().StringSlice("backfill-without-triggers", nil, "Tables to backfill without update triggers; updates and deletes on them are rejected until the migration is completed or rolled back")
+	migrateCmd.Flags().Bool("backfill-disable-autovacuum", false, "Disable autovacuum on each table while it is backfilled, restoring its previous setting afterwards")
+	migrateCmd.Flags().String("needs-backfill-column", "", "Name of the column that marks the rows of each table to backfill (default: the internal prefix followed by needs_backfill)")
+	migrateCmd.Flags().Bool("backfill-separate-mark", false, "Mark each batch of rows as backfilled with a separate statement from the one that backfills their data")
+	migrateCmd.Flags().String("backfill-isolation-level", "", "Transaction isolation level of each backfill batch: 'read-committed', 'repeatable-read' or 'serializable' (default: the session's default level)")
+	migrateCmd.Flags().String("backfill-role", "", "Role as which the backfill batches are run, in a session separate from the migration's DDL")
+	migrateCmd.Flags().StringArray("backfill-setting", nil, "Setting of the session in which the backfill batches are run, as name=value (eg. statement_timeout=5min); may be repeated")
+	migrateCmd.Flags().Int("backfill-column-concurrency", 1, "Number of columns of a table backfilled at once, each in a pass of its own on a separate connection")
+	migrateCmd.Flags().Int("backfill-parallelism", 1, "Number of ranges of a table's rows backfilled at once, each on a separate connection")
+	migrateCmd.Flags().Bool("verify-reversible", false, "After backfilling, check on a sample of rows that the down SQL of each column change reverses its up SQL")
+	migrateCmd.Flags().String("environment", "", "Environment being migrated; migrations tagged with other environments are skipped")
 	migrateCmd.Flags().BoolVarP(&complete, "complete", "c", false, "complete the final migration rather than leaving it active")
 
 	return migrateCmd
@@ -111,18 +185,41 @@ func migrateCmd() *cobra.Command {
 
 // parseMigrations tries to parse all RawMigrations and collects all the errors
 // if any.
-func parseMigrations(migs []*migrations.RawMigration) ([]*migrations.Migration, error) {
+func parseMigrations(m *roll.Roll, migs []*migrations.RawMigration) ([]*migrations.Migration, error) {
 	parsedMigrations := make([]*migrations.Migration, 0, len(migs))
 	var errs error
 	for _, rawMigration := range migs {
-		m, err := migrations.ParseMigration(rawMigration)
+		mig, err := m.ParseMigration(rawMigration)
 		if err != nil {
 			errs = errors.Join(errs, err)
 		}
-		parsedMigrations = append(parsedMigrations, m)
+		parsedMigrations = append(parsedMigrations, mig)
 	}
 	if errs != nil {
 		return nil, fmt.Errorf("incompatible migration(s): %w", errs)
 	}
 	return parsedMigrations, nil
 }
+
+// validateEnvironment checks that every migration tagged with environments can
+// be matched against the environment being migrated and, if any migration is
+// skipped, that the remaining migrations can still be applied in order.
+func validateEnvironment(ctx context.Context, m *roll.Roll, rawMigs []*migrations.RawMigration, migs []*migrations.Migration) error {
+	environment := flags.Environment()
+
+	skipped := false
+	for _, mig := range migs {
+		if len(mig.Environments) > 0 && environment == "" {
+			return fmt.Errorf("migration %q is only applied in environments %s; set the environment to migrate with --environment",
+				mig.Name, strings.Join(mig.Environments, ", "))
+		}
+		if !mig.AppliesTo(environment) {
+			skipped = true
+		}
+	}
+	if !skipped {
+		return nil
+	}
+
+	return m.ValidateForEnvironment(ctx, rawMigs, environment)
+}
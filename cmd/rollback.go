@@ -7,6 +7,8 @@ import (
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgroll/pkg/roll"
 )
 
 var rollbackCmd = &cobra.Command{
@@ -21,7 +23,7 @@ var rollbackCmd = &cobra.Command{
 		defer m.Close()
 
 		sp, _ := pterm.DefaultSpinner.WithText("Rolling back migration...").Start()
-		err = m.Rollback(cmd.Context())
+		err = roll.NewRunner(m).Rollback(cmd.Context())
 		if err != nil {
 			sp.Fail(fmt.Sprintf("Failed to roll back migration: %s", err))
 			return err
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func showCmd() *cobra.Command {
+	var useJSON, useYAML bool
+
+	showCmd := &cobra.Command{
+		Use:       "show <migration name>",
+		Short:     "Print a migration as it was recorded when it was applied to the target database",
+		Example:   "show 02_add_column --json",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"migration name"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			name := args[0]
+
+			// Create a roll instance and check if pgroll is initialized
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			mig, err := m.State().Migration(ctx, m.Schema(), name)
+			if err != nil {
+				return fmt.Errorf("failed to read migration %q: %w", name, err)
+			}
+
+			return migrations.NewWriter(os.Stdout, migrations.NewMigrationFormat(useJSON)).WriteRaw(mig)
+		},
+	}
+
+	showCmd.Flags().BoolVarP(&useJSON, "json", "j", false, "output the migration in JSON format")
+	showCmd.Flags().BoolVarP(&useYAML, "yaml", "y", false, "output the migration in YAML format (default)")
+	showCmd.MarkFlagsMutuallyExclusive("json", "yaml")
+
+	return showCmd
+}
@@ -8,7 +8,8 @@ import (
 	"math"
 	"os"
 	"path/filepath"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -22,14 +23,17 @@ import (
 
 func startCmd() *cobra.Command {
 	var complete bool
-	var batchSize int
-	var batchDelay time.Duration
+	var onlyIfNeeded bool
+	var dryRun bool
 
 	startCmd := &cobra.Command{
 		Use:       "start <file>",
 		Short:     "Start a migration for the operations present in the given file",
 		Args:      cobra.ExactArgs(1),
 		ValidArgs: []string{"file"},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			bindBackfillFlags(cmd)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			fileName := args[0]
@@ -51,25 +55,184 @@ func startCmd() *cobra.Command {
 				return nil
 			}
 
-			c := backfill.NewConfig(
-				backfill.WithBatchSize(batchSize),
-				backfill.WithBatchDelay(batchDelay),
-			)
+			batchSizeOpt, err := batchSizeOption()
+			if err != nil {
+				return err
+			}
+			batchKeyOpts, err := batchKeyOptions()
+			if err != nil {
+				return err
+			}
+			isolationLevelOpt, err := isolationLevelOption()
+			if err != nil {
+				return err
+			}
+			sessionSettingsOpt, err := sessionSettingsOption()
+			if err != nil {
+				return err
+			}
+
+			c := backfill.NewConfig(append(batchKeyOpts,
+				batchSizeOpt,
+				backfill.WithBatchDelay(flags.BackfillBatchDelay()),
+				backfill.WithOnlyIfNeeded(onlyIfNeeded),
+				backfill.WithoutTriggers(flags.BackfillWithoutTriggers()...),
+				backfill.WithAutovacuumDisabled(flags.BackfillDisableAutovacuum()),
+				backfill.WithNeedsBackfillColumn(flags.NeedsBackfillColumn()),
+				backfill.WithSeparateBackfillMark(flags.BackfillSeparateMark()),
+				isolationLevelOpt,
+				sessionSettingsOpt,
+				backfill.WithSessionRole(flags.BackfillRole()),
+				backfill.WithColumnConcurrency(flags.BackfillColumnConcurrency()),
+				backfill.WithParallelism(flags.BackfillParallelism()),
+				reversibilityCheckOption(),
+			)...)
+
+			if dryRun {
+				if complete {
+					return fmt.Errorf("--dry-run can't be combined with --complete; use `pgroll generate` for the SQL of the whole migration")
+				}
+				migration, err := migrations.ReadMigration(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
+				if err != nil {
+					return err
+				}
+				if err := reportWarnings(migration.Warnings()...); err != nil {
+					return err
+				}
+				groups, err := m.DryRunStart(ctx, migration, c)
+				if err != nil {
+					return fmt.Errorf("failed to dry run migration %q: %w", migration.Name, err)
+				}
+				return writeSQLGroups(os.Stdout, groups)
+			}
 
 			return runMigrationFromFile(ctx, m, fileName, complete, c)
 		},
 	}
 
-	startCmd.Flags().IntVar(&batchSize, "backfill-batch-size", backfill.DefaultBatchSize, "Number of rows backfilled in each batch")
-	startCmd.Flags().DurationVar(&batchDelay, "backfill-batch-delay", backfill.DefaultDelay, "Duration of delay between batch backfills (eg. 1s, 1000ms)")
+	startCmd.Flags().String("backfill-batch-size", strconv.Itoa(backfill.DefaultBatchSize), "Number of rows backfilled in each batch, or 'auto' to size batches by the width of each table's rows")
+	startCmd.Flags().Duration("backfill-batch-delay", backfill.DefaultDelay, "Duration of delay between batch backfills (eg. 1s, 1000ms)")
+	startCmd.Flags().StringSlice("backfill-without-triggers", nil, "Tables to backfill without update triggers; updates and deletes on them are rejected until the migration is completed or rolled back")
+	startCmd.Flags().Bool("backfill-disable-autovacuum", false, "Disable autovacuum on each table while it is backfilled, restoring its previous setting afterwards")
+	startCmd.Flags().String("needs-backfill-column", "", "Name of the column that marks the rows of each table to backfill (default: the internal prefix followed by needs_backfill)")
+	startCmd.Flags().Bool("backfill-separate-mark", false, "Mark each batch of rows as backfilled with a separate statement from the one that backfills their data")
+	startCmd.Flags().String("backfill-isolation-level", "", "Transaction isolation level of each backfill batch: 'read-committed', 'repeatable-read' or 'serializable' (default: the session's default level)")
+	startCmd.Flags().String("backfill-role", "", "Role as which the backfill batches are run, in a session separate from the migration's DDL")
+	startCmd.Flags().StringArray("backfill-setting", nil, "Setting of the session in which the backfill batches are run, as name=value (eg. statement_timeout=5min); may be repeated")
+	startCmd.Flags().Int("backfill-column-concurrency", 1, "Number of columns of a table backfilled at once, each in a pass of its own on a separate connection")
+	startCmd.Flags().Int("backfill-parallelism", 1, "Number of ranges of a table's rows backfilled at once, each on a separate connection")
+	startCmd.Flags().Bool("verify-reversible", false, "After backfilling, check on a sample of rows that the down SQL of each column change reverses its up SQL")
+	startCmd.Flags().BoolVar(&onlyIfNeeded, "backfill-only-if-needed", false, "Skip backfilling the columns that have no rows left to backfill, when continuing an interrupted start")
 	startCmd.Flags().BoolVarP(&complete, "complete", "c", false, "Mark the migration as complete")
+	startCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the SQL that starting the migration would execute, without executing it")
 	startCmd.Flags().BoolP("skip-validation", "s", false, "skip migration validation")
+	startCmd.Flags().Bool("reorder-operations", false, "Reorder operations so that operations run after the operations they depend on")
+	startCmd.Flags().Bool("stack", false, "Start the migration on top of any active migrations that touch different tables")
 
 	viper.BindPFlag("SKIP_VALIDATION", startCmd.Flags().Lookup("skip-validation"))
+	viper.BindPFlag("REORDER_OPERATIONS", startCmd.Flags().Lookup("reorder-operations"))
+	viper.BindPFlag("MIGRATION_STACKING", startCmd.Flags().Lookup("stack"))
 
 	return startCmd
 }
 
+// bindBackfillFlags binds the backfill flags of the given command to viper.
+// Several commands define these flags, so they are bound when the command
+// runs rather than when it is created.
+func bindBackfillFlags(cmd *cobra.Command) {
+	viper.BindPFlag("BACKFILL_BATCH_SIZE", cmd.Flags().Lookup("backfill-batch-size"))
+	viper.BindPFlag("BACKFILL_BATCH_DELAY", cmd.Flags().Lookup("backfill-batch-delay"))
+	viper.BindPFlag("BACKFILL_WITHOUT_TRIGGERS", cmd.Flags().Lookup("backfill-without-triggers"))
+	viper.BindPFlag("BACKFILL_DISABLE_AUTOVACUUM", cmd.Flags().Lookup("backfill-disable-autovacuum"))
+	viper.BindPFlag("NEEDS_BACKFILL_COLUMN", cmd.Flags().Lookup("needs-backfill-column"))
+	viper.BindPFlag("BACKFILL_SEPARATE_MARK", cmd.Flags().Lookup("backfill-separate-mark"))
+	viper.BindPFlag("BACKFILL_ISOLATION_LEVEL", cmd.Flags().Lookup("backfill-isolation-level"))
+	viper.BindPFlag("VERIFY_REVERSIBLE", cmd.Flags().Lookup("verify-reversible"))
+	viper.BindPFlag("BACKFILL_ROLE", cmd.Flags().Lookup("backfill-role"))
+	viper.BindPFlag("BACKFILL_SETTINGS", cmd.Flags().Lookup("backfill-setting"))
+	viper.BindPFlag("BACKFILL_COLUMN_CONCURRENCY", cmd.Flags().Lookup("backfill-column-concurrency"))
+	viper.BindPFlag("BACKFILL_PARALLELISM", cmd.Flags().Lookup("backfill-parallelism"))
+}
+
+// reversibilityCheckOption returns the backfill option for the
+// verify-reversible setting.
+func reversibilityCheckOption() backfill.OptionFn {
+	if !flags.VerifyReversible() {
+		return backfill.WithReversibilityCheck(0)
+	}
+	return backfill.WithReversibilityCheck(backfill.DefaultVerifySampleSize)
+}
+
+// batchSizeAuto is the backfill-batch-size setting that sizes the batches of
+// each table's backfill by the width of its rows.
+const batchSizeAuto = "auto"
+
+// batchSizeOption returns the backfill option for the backfill-batch-size
+// setting.
+func batchSizeOption() (backfill.OptionFn, error) {
+	setting := flags.BackfillBatchSize()
+	if setting == batchSizeAuto {
+		return backfill.WithAutoBatchSize(backfill.DefaultBatchBytes), nil
+	}
+
+	batchSize, err := strconv.Atoi(setting)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backfill-batch-size setting %q: must be a number of rows or %q", setting, batchSizeAuto)
+	}
+	return backfill.WithBatchSize(batchSize), nil
+}
+
+// isolationLevelOption returns the backfill option for the
+// backfill-isolation-level setting. Levels are accepted in any case, with
+// words separated by hyphens, underscores or spaces.
+func isolationLevelOption() (backfill.OptionFn, error) {
+	setting := flags.BackfillIsolationLevel()
+	if setting == "" {
+		return backfill.WithIsolationLevel(""), nil
+	}
+
+	normalized := strings.ToUpper(strings.NewReplacer("-", " ", "_", " ").Replace(setting))
+	for _, level := range backfill.IsolationLevels {
+		if normalized == string(level) {
+			return backfill.WithIsolationLevel(level), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid backfill-isolation-level setting %q: must be 'read-committed', 'repeatable-read' or 'serializable'", setting)
+}
+
+// sessionSettingsOption returns the backfill option for the backfill-setting
+// setting, each of whose entries is a name=value pair.
+func sessionSettingsOption() (backfill.OptionFn, error) {
+	settings := make(map[string]string)
+	for _, entry := range flags.BackfillSettings() {
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid backfill-setting %q: must be of the form name=value", entry)
+		}
+		settings[name] = value
+	}
+	return backfill.WithSessionSettings(settings), nil
+}
+
+// batchKeyOptions returns the backfill options for the per-table batch keys
+// set in the config file.
+func batchKeyOptions() ([]backfill.OptionFn, error) {
+	keys, err := flags.BackfillBatchKeys()
+	if err != nil {
+		return nil, fmt.Errorf("invalid backfill-batch-keys setting: %w", err)
+	}
+
+	opts := make([]backfill.OptionFn, 0, len(keys))
+	for _, k := range keys {
+		if k.Table == "" || len(k.Key) == 0 {
+			return nil, fmt.Errorf("invalid backfill-batch-keys setting: each entry requires a table and a key")
+		}
+		opts = append(opts, backfill.WithBatchKey(k.Table, k.Key...))
+	}
+	return opts, nil
+}
+
 func runMigrationFromFile(ctx context.Context, m *roll.Roll, fileName string, complete bool, c *backfill.Config) error {
 	migration, err := migrations.ReadMigration(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
 	if err != nil {
@@ -80,6 +243,10 @@ func runMigrationFromFile(ctx context.Context, m *roll.Roll, fileName string, co
 }
 
 func runMigration(ctx context.Context, m *roll.Roll, migration *migrations.Migration, complete bool, c *backfill.Config) error {
+	if err := reportWarnings(migration.Warnings()...); err != nil {
+		return err
+	}
+
 	sp, _ := pterm.DefaultSpinner.WithText("Starting migration...").Start()
 	c.AddCallback(func(n int64, total int64) {
 		if total > 0 {
@@ -92,25 +259,26 @@ func runMigration(ctx context.Context, m *roll.Roll, migration *migrations.Migra
 		}
 	})
 
-	err := m.Start(ctx, migration, c)
+	runner := roll.NewRunner(m)
+	result, err := runner.Start(ctx, migration, c)
 	if err != nil {
 		sp.Fail(fmt.Sprintf("Failed to start migration: %s", err))
 		return err
 	}
 
-	if complete {
-		if err = m.Complete(ctx); err != nil {
+	// A migration that is started again may have been completed already
+	if complete && !result.Done {
+		if err := runner.Complete(ctx); err != nil {
 			sp.Fail(fmt.Sprintf("Failed to complete migration: %s", err))
 			return err
 		}
 	}
 
 	var msg string
-	if m.UseVersionSchema() {
-		viewName := roll.VersionedSchemaName(flags.Schema(), migration.VersionSchemaName())
-		msg = fmt.Sprintf("New version of the schema available under the postgres %q schema", viewName)
+	if result.VersionSchema != "" {
+		msg = fmt.Sprintf("New version of the schema available under the postgres %q schema", result.VersionSchema)
 	} else {
-		msg = fmt.Sprintf("Migration %q started successfully", migration.Name)
+		msg = fmt.Sprintf("Migration %q started successfully", result.Migration)
 	}
 
 	sp.Success(msg)
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+func stateCmd() *cobra.Command {
+	stateCmd := &cobra.Command{
+		Use:   "state",
+		Short: "Dump or restore pgroll's state",
+		Long:  "Dump pgroll's state, including the migration history of every schema, to a JSON file, or restore it into a database in which pgroll has no state yet",
+	}
+
+	stateCmd.AddCommand(stateDumpCmd())
+	stateCmd.AddCommand(stateRestoreCmd())
+
+	return stateCmd
+}
+
+func stateDumpCmd() *cobra.Command {
+	dumpCmd := &cobra.Command{
+		Use:       "dump [file]",
+		Short:     "Dump pgroll's state to a JSON file, or to stdout if no file is given",
+		Example:   "state dump pgroll-state.json",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"file"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// Create a roll instance and check if pgroll is initialized
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			dump, err := m.State().Dump(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to dump pgroll state: %w", err)
+			}
+
+			if len(args) == 0 {
+				return writeStateDump(os.Stdout, dump)
+			}
+
+			f, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if err := writeStateDump(f, dump); err != nil {
+				return err
+			}
+			return f.Close()
+		},
+	}
+
+	return dumpCmd
+}
+
+func stateRestoreCmd() *cobra.Command {
+	restoreCmd := &cobra.Command{
+		Use:       "restore <file>",
+		Short:     "Restore pgroll's state from a JSON file made by `pgroll state dump`",
+		Example:   "state restore pgroll-state.json",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"file"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			var dump state.Dump
+			if err := json.NewDecoder(f).Decode(&dump); err != nil {
+				return fmt.Errorf("failed to read pgroll state dump %q: %w", args[0], err)
+			}
+
+			m, err := NewRoll(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			sp, _ := pterm.DefaultSpinner.WithText("Restoring pgroll state...").Start()
+
+			// The state is restored into a database in which pgroll may not
+			// have been initialized yet
+			if err := m.Init(ctx); err != nil {
+				sp.Fail(fmt.Sprintf("Failed to initialize pgroll: %s", err))
+				return err
+			}
+			if err := m.State().Restore(ctx, &dump); err != nil {
+				sp.Fail(fmt.Sprintf("Failed to restore pgroll state: %s", err))
+				return err
+			}
+
+			sp.Success("pgroll state restored")
+			return nil
+		},
+	}
+
+	return restoreCmd
+}
+
+// writeStateDump writes a dump of pgroll's state as indented JSON.
+func writeStateDump(w io.Writer, dump *state.Dump) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
@@ -3,37 +3,148 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/xataio/pgroll/cmd/flags"
+	"github.com/xataio/pgroll/pkg/roll"
 
 	"github.com/spf13/cobra"
 )
 
-var statusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "Show pgroll status",
-	RunE: func(cmd *cobra.Command, _ []string) error {
-		ctx := cmd.Context()
+func statusCmd() *cobra.Command {
+	var watch bool
+	var watchInterval time.Duration
 
-		m, err := NewRollWithInitCheck(ctx)
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show pgroll status",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			if watchInterval <= 0 {
+				return fmt.Errorf("--watch-interval must be greater than zero")
+			}
+
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			if watch {
+				return watchStatus(ctx, m, flags.Schema(), watchInterval, os.Stdout)
+			}
+
+			status, err := roll.NewRunner(m).Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			statusJSON, err := json.MarshalIndent(status, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(statusJSON))
+			return nil
+		},
+	}
+
+	statusCmd.Flags().BoolVar(&watch, "watch", false, "Poll the progress of the migration in progress until it is completed or rolled back")
+	statusCmd.Flags().DurationVar(&watchInterval, "watch-interval", 2*time.Second, "How often the progress is polled with --watch (eg. 1s, 500ms)")
+
+	return statusCmd
+}
+
+// watchStatus polls the progress of the latest migration of the schema every
+// interval and writes a line for each change to it, until the migration is
+// completed or rolled back.
+func watchStatus(ctx context.Context, m *roll.Roll, schema string, interval time.Duration, w io.Writer) error {
+	active, err := m.State().IsActiveMigrationPeriod(ctx, schema)
+	if err != nil {
+		return err
+	}
+	name, err := m.State().LatestMigration(ctx, schema)
+	if err != nil {
+		return err
+	}
+	if !active || name == nil {
+		_, err := fmt.Fprintf(w, "No migration in progress in schema %q\n", schema)
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var startedAt time.Time
+	printed := make(map[string]string)
+	for {
+		progress, err := m.MigrationProgress(ctx, schema, *name)
 		if err != nil {
 			return err
 		}
-		defer m.Close()
+		if !progress.StartedAt.IsZero() {
+			startedAt = progress.StartedAt
+		}
 
-		status, err := m.Status(ctx, flags.Schema())
-		if err != nil {
-			return err
+		elapsed := time.Since(startedAt).Round(time.Second)
+		for _, line := range progressLines(progress) {
+			if printed[line.key] == line.text {
+				continue
+			}
+			printed[line.key] = line.text
+			if _, err := fmt.Fprintf(w, "[%s] %s\n", elapsed, line.text); err != nil {
+				return err
+			}
 		}
 
-		statusJSON, err := json.MarshalIndent(status, "", "  ")
-		if err != nil {
-			return err
+		if progress.Terminal() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
 		}
+	}
+}
+
+type progressLine struct {
+	key  string
+	text string
+}
+
+// progressLines describes the progress of a migration, one line for the
+// migration and one for the backfill of each of its tables.
+func progressLines(p *roll.MigrationProgress) []progressLine {
+	text := fmt.Sprintf("%s: %s", p.Migration, p.Status)
+	if !p.Terminal() && len(p.Operations) > 0 {
+		text += fmt.Sprintf(" (%s)", strings.Join(p.Operations, ", "))
+	}
+	lines := []progressLine{{key: "", text: text}}
 
-		fmt.Println(string(statusJSON))
-		return nil
-	},
+	if p.Terminal() {
+		return lines
+	}
+	for _, t := range p.Backfill {
+		var text string
+		switch {
+		case t.Done:
+			text = fmt.Sprintf("backfill of table %q finished: %d rows", t.Table, t.RowsDone)
+		case t.RowsTotal > 0:
+			percent := min(t.RowsDone*100/t.RowsTotal, 100)
+			text = fmt.Sprintf("backfilling table %q: %d/%d rows (%d%%)", t.Table, t.RowsDone, t.RowsTotal, percent)
+		default:
+			text = fmt.Sprintf("backfilling table %q: %d rows", t.Table, t.RowsDone)
+		}
+		lines = append(lines, progressLine{key: t.Table, text: text})
+	}
+	return lines
 }
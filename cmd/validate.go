@@ -3,37 +3,139 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgroll/cmd/flags"
 	"github.com/xataio/pgroll/pkg/migrations"
 )
 
-var validateCmd = &cobra.Command{
-	Use:       "validate <file>",
-	Short:     "Validate a migration file",
-	Example:   "validate migrations/03_my_migration.yaml",
-	Args:      cobra.ExactArgs(1),
-	ValidArgs: []string{"file"},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := cmd.Context()
-		fileName := args[0]
-
-		m, err := NewRollWithInitCheck(ctx)
-		if err != nil {
-			return err
-		}
-		defer m.Close()
+const (
+	validateFormatText = "text"
+	validateFormatJSON = "json"
+)
 
-		migration, err := migrations.ReadMigration(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
-		if err != nil {
-			return err
-		}
-		err = m.Validate(ctx, migration)
-		if err != nil {
-			return err
+func validateCmd() *cobra.Command {
+	var format string
+
+	validateCmd := &cobra.Command{
+		Use:       "validate <file>",
+		Short:     "Validate a migration file",
+		Long:      "Validate a migration file against the schema after the latest completed migration, without making any changes to the database. Every error in the migration is reported, rather than only the first one.",
+		Example:   "validate migrations/03_my_migration.yaml --format json",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"file"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			fileName := args[0]
+
+			if format != validateFormatText && format != validateFormatJSON {
+				return fmt.Errorf("unknown format %q: must be %s or %s", format, validateFormatText, validateFormatJSON)
+			}
+
+			m, err := NewRollWithInitCheck(ctx)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+
+			report := validationReport{File: fileName, Errors: []validationError{}, Warnings: []string{}}
+
+			migration, err := migrations.ReadMigration(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
+			if err != nil {
+				report.addErrors(err)
+			} else {
+				errs, err := m.ValidateAll(ctx, migration)
+				if err != nil {
+					return err
+				}
+				report.addErrors(errs...)
+				report.Warnings = append(report.Warnings, migration.Warnings()...)
+			}
+			report.Valid = len(report.Errors) == 0
+
+			if format == validateFormatJSON {
+				if err := report.writeJSON(os.Stdout); err != nil {
+					return err
+				}
+				return report.err()
+			}
+
+			for _, e := range report.Errors {
+				pterm.Error.Println(e)
+			}
+			if !report.Valid {
+				return report.err()
+			}
+			return reportWarnings(report.Warnings...)
+		},
+	}
+
+	validateCmd.Flags().StringVarP(&format, "format", "f", validateFormatText, "output format of the report: text or json")
+
+	return validateCmd
+}
+
+// validationReport is the result of validating a migration file.
+type validationReport struct {
+	File     string            `json:"file"`
+	Valid    bool              `json:"valid"`
+	Errors   []validationError `json:"errors"`
+	Warnings []string          `json:"warnings"`
+}
+
+// validationError is an error found in a migration file. Errors in an
+// operation give the index and type of the operation.
+type validationError struct {
+	Operation *int   `json:"operation,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (e validationError) String() string {
+	if e.Operation == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("operations[%d] (%s): %s", *e.Operation, e.Type, e.Message)
+}
+
+func (r *validationReport) addErrors(errs ...error) {
+	for _, err := range errs {
+		var opErr migrations.OperationError
+		if errors.As(err, &opErr) {
+			r.Errors = append(r.Errors, validationError{
+				Operation: &opErr.Index,
+				Type:      string(opErr.Operation),
+				Message:   opErr.Err.Error(),
+			})
+			continue
 		}
-		return nil
-	},
+		r.Errors = append(r.Errors, validationError{Message: err.Error()})
+	}
+}
+
+func (r *validationReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// err returns the error that the command exits with: a
+// MigrationInvalidError if there are any errors or, with the strict setting,
+// a WarningsAsErrorsError if there are any warnings.
+func (r *validationReport) err() error {
+	if !r.Valid {
+		return MigrationInvalidError{File: r.File, Count: len(r.Errors)}
+	}
+	if len(r.Warnings) > 0 && flags.Strict() {
+		return WarningsAsErrorsError{Count: len(r.Warnings)}
+	}
+	return nil
 }
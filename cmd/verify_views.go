@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var verifyViewsCmd = &cobra.Command{
+	Use:   "verify-views",
+	Short: "Verify that the views of the latest version schema match the schema",
+	Long:  "Verify that the views of the latest version schema expose exactly the logical columns of each table, and none of the columns pgroll uses internally",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		m, err := NewRollWithInitCheck(ctx)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		discrepancies, err := m.VerifyViews(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range discrepancies {
+			pterm.Error.Println(d)
+		}
+		if len(discrepancies) > 0 {
+			return fmt.Errorf("found %d discrepancies between the views and the schema", len(discrepancies))
+		}
+
+		pterm.Success.Println("Views match the schema")
+		return nil
+	},
+}
@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"github.com/pterm/pterm"
+
+	"github.com/xataio/pgroll/cmd/flags"
+)
+
+// reportWarnings prints the given warnings. With the strict setting, the
+// warnings are errors and a WarningsAsErrorsError is returned if there are
+// any.
+func reportWarnings(warnings ...string) error {
+	for _, warning := range warnings {
+		pterm.Warning.Println(warning)
+	}
+	if len(warnings) > 0 && flags.Strict() {
+		return WarningsAsErrorsError{Count: len(warnings)}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ package connstr
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
 )
@@ -33,3 +34,76 @@ func AppendSearchPathOption(connStr, schema string) (string, error) {
 
 	return u.String(), nil
 }
+
+// envParams maps the libpq environment variables that are passed through to
+// the connection string unchanged to the connection parameters they set.
+var envParams = map[string]string{
+	"PGAPPNAME":         "application_name",
+	"PGCONNECT_TIMEOUT": "connect_timeout",
+	"PGOPTIONS":         "options",
+	"PGSSLCERT":         "sslcert",
+	"PGSSLKEY":          "sslkey",
+	"PGSSLMODE":         "sslmode",
+	"PGSSLROOTCERT":     "sslrootcert",
+}
+
+// FromEnv assembles a Postgres connection string in URL format from the
+// standard libpq environment variables (PGHOST, PGPORT, PGUSER, PGPASSWORD,
+// PGDATABASE and the variables in envParams), as read by getenv. Variables
+// that are unset or empty are left out of the connection string, so that the
+// driver's defaults apply to them as they would for libpq. A PGHOST starting
+// with a slash is the directory of a Unix-domain socket. An empty string is
+// returned if none of the variables are set.
+func FromEnv(getenv func(string) string) string {
+	host := getenv("PGHOST")
+	port := getenv("PGPORT")
+	user := getenv("PGUSER")
+	password := getenv("PGPASSWORD")
+	database := getenv("PGDATABASE")
+
+	u := url.URL{Scheme: "postgres"}
+	q := url.Values{}
+	set := host != "" || port != "" || user != "" || password != "" || database != ""
+
+	switch {
+	case strings.HasPrefix(host, "/"):
+		q.Set("host", host)
+		if port != "" {
+			q.Set("port", port)
+		}
+	case port != "":
+		if host == "" {
+			host = "localhost"
+		}
+		u.Host = net.JoinHostPort(host, port)
+	default:
+		u.Host = host
+	}
+
+	switch {
+	case user != "" && password != "":
+		u.User = url.UserPassword(user, password)
+	case user != "":
+		u.User = url.User(user)
+	case password != "":
+		q.Set("password", password)
+	}
+
+	if database != "" {
+		u.Path = "/" + database
+	}
+
+	for env, param := range envParams {
+		if v := getenv(env); v != "" {
+			q.Set(param, v)
+			set = true
+		}
+	}
+
+	if !set {
+		return ""
+	}
+
+	u.RawQuery = strings.ReplaceAll(q.Encode(), "+", "%20")
+	return u.String()
+}
@@ -28,6 +28,15 @@ func UsesFastPath(ctx context.Context, conn db.DB, tableName, columnType, defaul
 		return true, nil
 	}
 
+	// Probe the database behind a recording connection, so that the probe
+	// itself is not recorded
+	if rec, ok := conn.(*db.RecordingDB); ok {
+		if rec.Conn == nil {
+			return true, nil
+		}
+		conn = rec.Conn
+	}
+
 	// Create a schema-only copy of the table
 	_, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE UNLOGGED TABLE %s AS SELECT * FROM %s WHERE false",
 		pq.QuoteIdentifier(cNewTableName),
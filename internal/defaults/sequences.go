@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package defaults
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/db"
+)
+
+// sequenceFunctions are the functions whose first argument names a sequence
+var sequenceFunctions = map[string]bool{
+	"nextval": true,
+	"currval": true,
+	"setval":  true,
+}
+
+// QualifySequences returns [expr] with the sequence names passed as string
+// literals to `nextval`, `currval` and `setval` qualified with the schema of
+// the sequence, as resolved by the search_path of [conn].
+//
+// A sequence named by a string literal is only looked up when the expression
+// is evaluated, using the search_path of the session evaluating it. Qualifying
+// the names keeps the expression referring to the same sequences when it is
+// evaluated by a session with a different search_path, for example by a
+// trigger fired by a client of another version of the schema. Sequences that
+// don't exist yet are left as they are.
+func QualifySequences(ctx context.Context, conn db.DB, expr string) (string, error) {
+	// Check if we have a real connection or a fake one
+	if _, ok := conn.(*db.FakeDB); ok {
+		return expr, nil
+	}
+
+	// Look the sequences up behind a recording connection, so that the lookups
+	// are not recorded
+	if rec, ok := conn.(*db.RecordingDB); ok {
+		if rec.Conn == nil {
+			return expr, nil
+		}
+		conn = rec.Conn
+	}
+
+	refs, err := sequenceReferences(expr)
+	if err != nil {
+		return "", err
+	}
+
+	// Replace the references from last to first, so that the positions of the
+	// earlier ones are unaffected
+	for i := len(refs) - 1; i >= 0; i-- {
+		ref := refs[i]
+		qualified, err := qualifiedSequenceName(ctx, conn, ref.name)
+		if err != nil {
+			return "", err
+		}
+		if qualified == "" {
+			continue
+		}
+		expr = expr[:ref.start] + pq.QuoteLiteral(qualified) + expr[ref.end:]
+	}
+
+	return expr, nil
+}
+
+// sequenceReference is a string literal naming a sequence in an expression
+type sequenceReference struct {
+	name       string
+	start, end int
+}
+
+// sequenceReferences returns the string literals passed as the first argument
+// of a sequence function in the expression, in the order they appear.
+func sequenceReferences(expr string) ([]sequenceReference, error) {
+	result, err := pgq.Scan(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan expression %q: %w", expr, err)
+	}
+
+	var refs []sequenceReference
+	tokens := result.GetTokens()
+	for i := 0; i+2 < len(tokens); i++ {
+		fn, paren, arg := tokens[i], tokens[i+1], tokens[i+2]
+		if fn.GetToken() != pgq.Token_IDENT || paren.GetToken() != pgq.Token_ASCII_40 || arg.GetToken() != pgq.Token_SCONST {
+			continue
+		}
+		if !sequenceFunctions[strings.ToLower(expr[fn.GetStart():fn.GetEnd()])] {
+			continue
+		}
+
+		literal := expr[arg.GetStart():arg.GetEnd()]
+		if !strings.HasPrefix(literal, "'") {
+			// Escape strings and dollar-quoted strings are left alone
+			continue
+		}
+		refs = append(refs, sequenceReference{
+			name:  strings.ReplaceAll(literal[1:len(literal)-1], "''", "'"),
+			start: int(arg.GetStart()),
+			end:   int(arg.GetEnd()),
+		})
+	}
+
+	return refs, nil
+}
+
+// qualifiedSequenceName returns the schema-qualified name of the sequence,
+// quoted as needed, or an empty string if there is no such sequence.
+func qualifiedSequenceName(ctx context.Context, conn db.DB, name string) (string, error) {
+	rows, err := conn.QueryContext(ctx, `
+	  SELECT pg_catalog.format('%I.%I', n.nspname, c.relname)
+	  FROM pg_catalog.pg_class c
+	  JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	  WHERE c.oid = pg_catalog.to_regclass($1) AND c.relkind = 'S'`, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up sequence %q: %w", name, err)
+	}
+	defer rows.Close()
+
+	var qualified string
+	if rows.Next() {
+		if err := rows.Scan(&qualified); err != nil {
+			return "", fmt.Errorf("failed to read sequence %q: %w", name, err)
+		}
+	}
+	return qualified, rows.Err()
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package defaults_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/roll"
+
+	"github.com/xataio/pgroll/internal/defaults"
+	"github.com/xataio/pgroll/internal/testutils"
+)
+
+func TestQualifySequences(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name     string
+		Default  string
+		Expected string
+	}{
+		{
+			Name:     "sequence in the current schema",
+			Default:  "nextval('local_seq')",
+			Expected: "nextval('public.local_seq')",
+		},
+		{
+			Name:     "sequence in another schema on the search_path",
+			Default:  "nextval('shared_seq'::regclass)",
+			Expected: "nextval('shared.shared_seq'::regclass)",
+		},
+		{
+			Name:     "already qualified sequence",
+			Default:  "NEXTVAL('shared.shared_seq')",
+			Expected: "NEXTVAL('shared.shared_seq')",
+		},
+		{
+			Name:     "sequence with a name that needs quoting",
+			Default:  `currval('"Mixed Case"') + 1`,
+			Expected: `currval('public."Mixed Case"') + 1`,
+		},
+		{
+			Name:     "several sequences",
+			Default:  "nextval('local_seq') * 1000 + nextval('shared_seq')",
+			Expected: "nextval('public.local_seq') * 1000 + nextval('shared.shared_seq')",
+		},
+		{
+			Name:     "sequence that doesn't exist",
+			Default:  "nextval('missing_seq')",
+			Expected: "nextval('missing_seq')",
+		},
+		{
+			Name:     "string literal that isn't a sequence reference",
+			Default:  "'local_seq'",
+			Expected: "'local_seq'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, conn *sql.DB) {
+				ctx := context.Background()
+
+				// Use a single connection so that the search_path set on it
+				// applies to the lookups
+				conn.SetMaxOpenConns(1)
+
+				_, err := conn.ExecContext(ctx, `
+          CREATE SCHEMA shared;
+          CREATE SEQUENCE shared.shared_seq;
+          CREATE SEQUENCE local_seq;
+          CREATE SEQUENCE "Mixed Case";
+          SET search_path = public, shared;
+        `)
+				require.NoError(t, err)
+
+				qualified, err := defaults.QualifySequences(ctx, &db.RDB{DB: conn}, tc.Default)
+				require.NoError(t, err)
+				require.Equal(t, tc.Expected, qualified)
+			})
+		})
+	}
+}
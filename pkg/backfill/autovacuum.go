@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/db"
+)
+
+// autovacuumSQL returns the statement that sets the autovacuum_enabled
+// storage parameter of the table to the given value, or resets it to the
+// server default if value is nil.
+func autovacuumSQL(tableName string, value *string) string {
+	if value == nil {
+		return fmt.Sprintf("ALTER TABLE %s RESET (autovacuum_enabled)", pq.QuoteIdentifier(tableName))
+	}
+	return fmt.Sprintf("ALTER TABLE %s SET (autovacuum_enabled = %s)",
+		pq.QuoteIdentifier(tableName), pq.QuoteLiteral(*value))
+}
+
+// getAutovacuumSetting returns the autovacuum_enabled storage parameter of the
+// table, or nil if it is not set.
+func getAutovacuumSetting(ctx context.Context, conn db.DB, tableName string) (*string, error) {
+	rows, err := conn.QueryContext(ctx, `
+	  SELECT option_value
+	  FROM pg_class, pg_options_to_table(reloptions)
+	  WHERE oid = $1::regclass AND option_name = 'autovacuum_enabled'`,
+		pq.QuoteIdentifier(tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var value string
+	if err := rows.Scan(&value); err != nil {
+		return nil, err
+	}
+	return &value, rows.Err()
+}
+
+// pauseAutovacuum disables autovacuum on the table and returns a function
+// that restores the table's previous setting.
+func (bf *Backfill) pauseAutovacuum(ctx context.Context, tableName string) (func(context.Context) error, error) {
+	previous, err := getAutovacuumSetting(ctx, bf.conn, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("get autovacuum setting: %w", err)
+	}
+
+	disabled := "false"
+	if _, err := bf.conn.ExecContext(ctx, autovacuumSQL(tableName, &disabled)); err != nil {
+		return nil, fmt.Errorf("disable autovacuum: %w", err)
+	}
+
+	return func(ctx context.Context) error {
+		if _, err := bf.conn.ExecContext(ctx, autovacuumSQL(tableName, previous)); err != nil {
+			return fmt.Errorf("restore autovacuum setting: %w", err)
+		}
+		return nil
+	}, nil
+}
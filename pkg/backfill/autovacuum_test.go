@@ -0,0 +1,15 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutovacuumSQL(t *testing.T) {
+	disabled := "false"
+	assert.Equal(t, `ALTER TABLE "events" SET (autovacuum_enabled = 'false')`, autovacuumSQL("events", &disabled))
+	assert.Equal(t, `ALTER TABLE "events" RESET (autovacuum_enabled)`, autovacuumSQL("events", nil))
+}
@@ -7,7 +7,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lib/pq"
@@ -17,31 +20,107 @@ import (
 )
 
 // CNeedsBackfillColumn is the name of the internal column created
-// by pgroll to mark rows that must be backfilled
-const CNeedsBackfillColumn = "_pgroll_needs_backfill"
+// by pgroll to mark rows that must be backfilled, with the default prefix
+const CNeedsBackfillColumn = DefaultPrefix + "needs_backfill"
+
+// NeedsBackfillColumnComment is the comment set on the needs backfill column
+// when it is created. It identifies the column when it is created with a
+// name other than the default one.
+const NeedsBackfillColumnComment = "pgroll: marks the rows to backfill"
+
+// IsNeedsBackfillColumn returns true if the column is the needs backfill
+// column created by pgroll for a migration named with the default naming.
+func IsNeedsBackfillColumn(column *schema.Column) bool {
+	return Naming{}.IsNeedsBackfillColumn(column)
+}
+
+// IsNeedsBackfillColumn returns true if the column is the needs backfill
+// column created by pgroll for a migration named with the naming.
+func (n Naming) IsNeedsBackfillColumn(column *schema.Column) bool {
+	return column.Name == n.NeedsBackfillColumn() || column.Comment == NeedsBackfillColumnComment
+}
 
 // Task represents a backfill task for a specific table from an operation.
 type Task struct {
-	table    *schema.Table
-	triggers []OperationTrigger
+	table     *schema.Table
+	triggers  []OperationTrigger
+	filter    string
+	operation string
 }
 
 // Job is a collection of all tables that need to be backfilled and their associated triggers.
 type Job struct {
-	schemaName   string
-	latestSchema string
-	triggers     map[string]triggerConfig
+	schemaName     string
+	latestSchema   string
+	stackedSchemas []string
+	triggers       map[string]triggerConfig
+
+	// conditions limiting the backfill of each table, and the tables that
+	// have a task without a condition and so must be backfilled in full
+	filters    map[string][]string
+	unfiltered map[string]bool
+
+	// scopes of the tasks for each table
+	scopes map[string][]taskScope
 
 	Tables []*schema.Table
 }
 
+// taskScope is the part of a table that a task backfills: the rows that match
+// its filter and the columns set by its up triggers.
+type taskScope struct {
+	operation  string
+	filter     string
+	upTriggers []string
+}
+
 type Backfill struct {
 	conn db.DB
 	*Config
+
+	// naming of the objects the backfill creates, if not that of the config
+	naming Naming
+
+	// connection on which the batches are run, if not conn
+	batchConn db.DB
+
+	// names of the triggers created for each table by CreateTriggers, and of
+	// those among them that backfill a column
+	triggers   map[string][]string
+	upTriggers map[string][]string
+
+	// scopes of the tasks for each table of the job whose triggers were
+	// created by CreateTriggers
+	scopes map[string][]taskScope
+
+	// connections on which the passes for the columns of a table are run
+	columnConns []db.DB
+
+	// connections on which the ranges of a table's rows are backfilled
+	rangeConns []db.DB
+
+	triggerCallbacks []TriggerCallbackFn
+	batchCallbacks   []BatchCallbackFn
+	skipCallbacks    []SkipCallbackFn
+
+	// progress stores how far the backfill of each table has got, if set
+	progress Progress
 }
 
 type CallbackFn func(done int64, total int64)
 
+// TriggerCallbackFn is called after each trigger is created by CreateTriggers,
+// with the time it took to create it.
+type TriggerCallbackFn func(table, trigger string, duration time.Duration)
+
+// BatchCallbackFn is called after each batch of a backfill is committed, with
+// the number of rows the batch updated and the time it took.
+type BatchCallbackFn func(table string, rows int64, duration time.Duration)
+
+// SkipCallbackFn is called for each task whose backfill is skipped because
+// none of its rows are left to backfill, with the operation the task is for.
+type SkipCallbackFn func(table, operation string)
+
 func NewTask(table *schema.Table, triggers ...OperationTrigger) *Task {
 	return &Task{
 		table:    table,
@@ -54,10 +133,40 @@ func NewJob(schemaName, latestSchema string) *Job {
 		schemaName:   schemaName,
 		latestSchema: latestSchema,
 		triggers:     make(map[string]triggerConfig, 0),
+		filters:      make(map[string][]string),
+		unfiltered:   make(map[string]bool),
+		scopes:       make(map[string][]taskScope),
 		Tables:       make([]*schema.Table, 0),
 	}
 }
 
+// SetStackedSchemas sets the version schemas of the migrations stacked on top
+// of the job's migration. The triggers treat writes made through them like
+// writes made through the latest version schema.
+func (j *Job) SetStackedSchemas(schemas ...string) {
+	j.stackedSchemas = schemas
+}
+
+// RemoveDownTriggers removes the triggers that propagate writes made through
+// the new version of the schema to the old one.
+func (t *Task) RemoveDownTriggers() {
+	t.triggers = slices.DeleteFunc(t.triggers, func(trigger OperationTrigger) bool {
+		return trigger.Direction == TriggerDirectionDown
+	})
+}
+
+// SetFilter limits the backfill of the task's table to the rows that match the
+// SQL condition.
+func (t *Task) SetFilter(where string) {
+	t.filter = where
+}
+
+// SetOperation sets a description of the operation that the task is for,
+// which is used to report errors in the SQL of its triggers.
+func (t *Task) SetOperation(operation string) {
+	t.operation = operation
+}
+
 func (t *Task) AddTriggers(other *Task) {
 	t.triggers = append(t.triggers, other.triggers...)
 }
@@ -65,9 +174,29 @@ func (t *Task) AddTriggers(other *Task) {
 func (j *Job) AddTask(t *Task) {
 	if t.table != nil {
 		j.Tables = append(j.Tables, t.table)
+
+		if t.filter == "" {
+			j.unfiltered[t.table.Name] = true
+		} else {
+			j.filters[t.table.Name] = append(j.filters[t.table.Name], t.filter)
+		}
+
+		scope := taskScope{operation: t.operation, filter: t.filter}
+		for _, trigger := range t.triggers {
+			if trigger.Direction == TriggerDirectionUp && !isGeneratedColumn(trigger.Columns, trigger.PhysicalColumn) {
+				scope.upTriggers = append(scope.upTriggers, trigger.Name)
+			}
+		}
+		j.scopes[t.table.Name] = append(j.scopes[t.table.Name], scope)
 	}
 
 	for _, trigger := range t.triggers {
+		// Postgres computes the values of generated columns, which can't be
+		// assigned to by a trigger
+		if isGeneratedColumn(trigger.Columns, trigger.PhysicalColumn) {
+			continue
+		}
+
 		if tg, exists := j.triggers[trigger.Name]; exists {
 			// If the trigger already exists, append the SQL to the existing trigger config
 			// The rewriting is necessary to ensure that the expression uses the NEW prefix with the physical column name
@@ -76,11 +205,17 @@ func (j *Job) AddTask(t *Task) {
 			// CASE WHEN NEW."_pgroll_new_review" = 'bad' THEN 'bad review' ELSE 'good review' END.
 			// Otherwise, the trigger will not work correctly because it will reference the old column name.
 			tg.SQL = append(tg.SQL, rewriteTriggerSQL(trigger.SQL, findColumnName(tg.Columns, tg.PhysicalColumn), tg.PhysicalColumn))
+			if trigger.Statements != "" {
+				tg.Statements = append(tg.Statements, trigger.Statements)
+			}
+			if t.operation != "" && !slices.Contains(tg.Operations, t.operation) {
+				tg.Operations = append(tg.Operations, t.operation)
+			}
 			j.triggers[trigger.Name] = tg
 		} else {
 			// If the trigger does not exist, create a new trigger config
 			// No need to rewrite the SQL here, as it is the first time we are adding it.
-			j.triggers[trigger.Name] = triggerConfig{
+			tg := triggerConfig{
 				Name:                trigger.Name,
 				Direction:           trigger.Direction,
 				Columns:             trigger.Columns,
@@ -91,10 +226,32 @@ func (j *Job) AddTask(t *Task) {
 				SQL:                 []string{trigger.SQL},
 				NeedsBackfillColumn: CNeedsBackfillColumn,
 			}
+			if trigger.Statements != "" {
+				tg.Statements = []string{trigger.Statements}
+			}
+			if t.operation != "" {
+				tg.Operations = []string{t.operation}
+			}
+			j.triggers[trigger.Name] = tg
 		}
 	}
 }
 
+// Filter returns the condition that limits the backfill of the table, or an
+// empty string if all of its rows must be backfilled. Rows are backfilled if
+// they match the condition of any of the tasks for the table.
+func (j *Job) Filter(table string) string {
+	if j.unfiltered[table] || len(j.filters[table]) == 0 {
+		return ""
+	}
+
+	conditions := make([]string, len(j.filters[table]))
+	for i, f := range j.filters[table] {
+		conditions[i] = "(" + f + ")"
+	}
+	return strings.Join(conditions, " OR ")
+}
+
 // rewriteTriggerSQL rewrites the SQL migrations expression provided by the user
 // in the up or down attribute of the operations config.
 // The column name are turned from user defined names the physical column name with NEW prefix.
@@ -115,20 +272,107 @@ func findColumnName(columns map[string]*schema.Column, columnName string) string
 	return columnName
 }
 
+// isGeneratedColumn returns true if the column with the provided physical
+// column name is a generated column.
+func isGeneratedColumn(columns map[string]*schema.Column, columnName string) bool {
+	for _, col := range columns {
+		if col.Name == columnName {
+			return col.Generated != nil
+		}
+	}
+	return false
+}
+
 // New creates a new backfill operation with the given options. The backfill is
 // not started until `Start` is invoked.
 func New(conn db.DB, c *Config) *Backfill {
 	b := &Backfill{
-		conn:   conn,
-		Config: c,
+		conn:       conn,
+		Config:     c,
+		naming:     c.naming,
+		triggers:   make(map[string][]string),
+		upTriggers: make(map[string][]string),
 	}
 
 	return b
 }
 
+// SetNaming sets the naming of the columns, triggers and other objects that
+// the backfill creates for its own use, in place of that of the backfill's
+// config. They must be named as the objects created by the migration being
+// backfilled.
+func (bf *Backfill) SetNaming(n Naming) {
+	bf.naming = n
+}
+
+// Naming returns the naming of the objects that the backfill creates for its
+// own use.
+func (bf *Backfill) Naming() Naming {
+	return bf.naming
+}
+
+// NeedsBackfillColumn returns the name of the column that marks the rows that
+// are still to be backfilled.
+func (bf *Backfill) NeedsBackfillColumn() string {
+	if bf.needsBackfillColumn == "" {
+		return bf.naming.NeedsBackfillColumn()
+	}
+	return bf.needsBackfillColumn
+}
+
+// SetBatchConn sets the connection on which the batches of the backfill are
+// run, e.g. one set up with Config.SetupSession. The triggers, row counts and
+// other statements of the backfill still run on the backfill's connection.
+func (bf *Backfill) SetBatchConn(conn db.DB) {
+	bf.batchConn = conn
+}
+
+// SetColumnConns sets the connections on which the passes for the columns of
+// a table are run when the backfill is configured with WithColumnConcurrency.
+// A pass runs on each connection at a time, so the number of connections
+// bounds the number of passes that run at once. The columns of each table are
+// backfilled in a single pass if no connections are set.
+func (bf *Backfill) SetColumnConns(conns ...db.DB) {
+	bf.columnConns = conns
+}
+
+// SetRangeConns sets the connections on which the ranges of a table's rows
+// are backfilled when the backfill is configured with WithParallelism. The
+// rows of each table are split into a range for each connection, and each
+// range is backfilled on a connection of its own. Each table is backfilled in
+// a single range if fewer than two connections are set.
+func (bf *Backfill) SetRangeConns(conns ...db.DB) {
+	bf.rangeConns = conns
+}
+
+// AddTriggerCallback adds a callback that is invoked after each trigger is
+// created.
+func (bf *Backfill) AddTriggerCallback(fn TriggerCallbackFn) {
+	bf.triggerCallbacks = append(bf.triggerCallbacks, fn)
+}
+
+// AddBatchCallback adds a callback that is invoked after each batch of a
+// backfill is committed.
+func (bf *Backfill) AddBatchCallback(fn BatchCallbackFn) {
+	bf.batchCallbacks = append(bf.batchCallbacks, fn)
+}
+
+// AddSkipCallback adds a callback that is invoked for each task whose backfill
+// is skipped by WithOnlyIfNeeded.
+func (bf *Backfill) AddSkipCallback(fn SkipCallbackFn) {
+	bf.skipCallbacks = append(bf.skipCallbacks, fn)
+}
+
 // CreateTriggers creates the triggers for the tables before starting the backfill.
 func (bf *Backfill) CreateTriggers(ctx context.Context, j *Job) error {
+	bf.scopes = j.scopes
 	for _, trigger := range j.triggers {
+		start := time.Now()
+		trigger.NeedsBackfillColumn = bf.NeedsBackfillColumn()
+		trigger.Naming = bf.naming
+		trigger.DeferMark = bf.separateMark || bf.ColumnConcurrency() > 0
+		trigger.ColumnPasses = bf.ColumnConcurrency() > 0
+		trigger.StackedSchemas = j.stackedSchemas
 		a := &createTriggerAction{
 			conn: bf.conn,
 			cfg:  trigger,
@@ -136,6 +380,39 @@ func (bf *Backfill) CreateTriggers(ctx context.Context, j *Job) error {
 		if err := a.execute(ctx); err != nil {
 			return fmt.Errorf("creating trigger %q: %w", trigger.Name, err)
 		}
+		bf.triggers[trigger.TableName] = append(bf.triggers[trigger.TableName], trigger.Name)
+		if trigger.Direction == TriggerDirectionUp {
+			bf.upTriggers[trigger.TableName] = append(bf.upTriggers[trigger.TableName], trigger.Name)
+		}
+
+		for _, cb := range bf.triggerCallbacks {
+			cb(trigger.TableName, trigger.Name, time.Since(start))
+		}
+	}
+	return nil
+}
+
+// ReplaceTriggerFunctions replaces the functions of the triggers created for
+// the job, so that they treat writes made through the job's stacked schemas
+// like writes made through the latest version schema. The triggers themselves
+// are left as they are, so tables guarded by a write guard stay guarded.
+func (bf *Backfill) ReplaceTriggerFunctions(ctx context.Context, j *Job) error {
+	for _, trigger := range j.triggers {
+		trigger.NeedsBackfillColumn = bf.NeedsBackfillColumn()
+		trigger.Naming = bf.naming
+		trigger.DeferMark = bf.separateMark || bf.ColumnConcurrency() > 0
+		trigger.ColumnPasses = bf.ColumnConcurrency() > 0
+		trigger.StackedSchemas = j.stackedSchemas
+		trigger.SQL = slices.Clone(trigger.SQL)
+		parenthesizeSQL(trigger.SQL)
+
+		funcSQL, err := buildFunction(trigger)
+		if err != nil {
+			return err
+		}
+		if _, err := bf.conn.ExecContext(ctx, funcSQL); err != nil {
+			return fmt.Errorf("replacing trigger function %q: %w", trigger.Name, err)
+		}
 	}
 	return nil
 }
@@ -146,54 +423,440 @@ func (bf *Backfill) CreateTriggers(ctx context.Context, j *Job) error {
 // 2. Get the first batch of rows from the table, ordered by the primary key.
 // 3. Update each row in the batch, setting the value of the primary key column to itself.
 // 4. Repeat steps 2 and 3 until no more rows are returned.
-func (bf *Backfill) Start(ctx context.Context, table *schema.Table) error {
+//
+// The rows are paged through on the server: each batch returns only the
+// primary key of its last row and the number of rows it updated, so the memory
+// used by the backfill doesn't grow with the size of the table or its rows.
+//
+// If filter is not empty, only the rows that match the SQL condition are
+// updated. Tables configured to be backfilled without triggers have their
+// triggers limited to inserts and guarded against updates and deletes once
+// the backfill has finished. If
+// autovacuum is disabled for the backfill, the table's previous setting is
+// restored once the backfill has finished.
+func (bf *Backfill) Start(ctx context.Context, table *schema.Table, filter string) (err error) {
+	if bf.noAutovacuum {
+		restore, err := bf.pauseAutovacuum(ctx, table.Name)
+		if err != nil {
+			return fmt.Errorf("pause autovacuum on %q: %w", table.Name, err)
+		}
+		defer func() {
+			// restore the setting even if the backfill was cancelled
+			if errRestore := restore(context.WithoutCancel(ctx)); errRestore != nil {
+				err = errors.Join(err, fmt.Errorf("%q: %w", table.Name, errRestore))
+			}
+		}()
+	}
+
+	if err := bf.backfillTable(ctx, table, filter); err != nil {
+		return err
+	}
+
+	if err := bf.GuardTable(ctx, table.Name); err != nil {
+		return fmt.Errorf("guard %q against writes: %w", table.Name, err)
+	}
+	return nil
+}
+
+func (bf *Backfill) backfillTable(ctx context.Context, table *schema.Table, filter string) error {
+	batchSize, err := bf.tableBatchSize(ctx, table.Name)
+	if err != nil {
+		return fmt.Errorf("get batch size for %q: %w", table.Name, err)
+	}
+
+	var lastValue []string
+	if bf.progress != nil {
+		var done bool
+		lastValue, done, err = bf.progress.Load(ctx, table.Name)
+		if err != nil {
+			return fmt.Errorf("load backfill progress of %q: %w", table.Name, err)
+		}
+		if done {
+			return nil
+		}
+	}
+
+	upTriggers := bf.upTriggers[table.Name]
+	if bf.onlyIfNeeded {
+		var pending bool
+		filter, upTriggers, pending, err = bf.pendingTasks(ctx, table.Name, filter)
+		if err != nil {
+			return err
+		}
+		if !pending {
+			return bf.saveProgress(ctx, table.Name, nil, 0, 0, true)
+		}
+	}
+
 	// Create a batcher for the table.
 	var b batcher
-	if identityColumns := getIdentityColumns(table); identityColumns != nil {
+	if identityColumns := getIdentityColumns(table, bf.naming); identityColumns != nil {
+		batchKey := bf.batchKeys[table.Name]
+		if len(batchKey) > 0 {
+			ok, err := hasBatchKeyIndex(ctx, bf.conn, table.Name, batchKey)
+			if err != nil {
+				return fmt.Errorf("find index for batch key of %q: %w", table.Name, err)
+			}
+			if !ok {
+				return BatchKeyIndexMissingError{Table: table.Name, Key: strings.Join(batchKey, ", ")}
+			}
+		}
+
 		b = &pkBatcher{
 			BatchConfig: templates.BatchConfig{
 				TableName:           table.Name,
 				PrimaryKey:          identityColumns,
-				BatchSize:           bf.batchSize,
-				NeedsBackfillColumn: CNeedsBackfillColumn,
+				BatchKey:            batchKey,
+				BatchSize:           batchSize,
+				NeedsBackfillColumn: bf.NeedsBackfillColumn(),
+				Filter:              filter,
+				Prefix:              bf.naming.Prefix(),
 			},
+			separateMark:   bf.separateMark,
+			isolationLevel: bf.isolationLevel,
 		}
 	} else {
 		b = &needsBackfillColumnBatcher{
 			table:               table.Name,
-			batchSize:           bf.batchSize,
-			needsBackfillColumn: CNeedsBackfillColumn,
+			batchSize:           batchSize,
+			needsBackfillColumn: bf.NeedsBackfillColumn(),
+			filter:              filter,
+			isolationLevel:      bf.isolationLevel,
 		}
 	}
 
-	total, err := getRowCount(ctx, bf.conn, table.Name)
-	if err != nil {
-		return fmt.Errorf("get row count for %q: %w", table.Name, err)
+	if bf.progress != nil {
+		b.resume(lastValue)
+	}
+
+	var total int64
+	if bf.onlyIfNeeded || filter != "" {
+		// The rows matching a filter are counted as there is no estimate for
+		// them
+		total, err = getPendingRowCount(ctx, bf.conn, table.Name, bf.NeedsBackfillColumn(), filter)
+		if err != nil {
+			return fmt.Errorf("get pending row count for %q: %w", table.Name, err)
+		}
+	} else {
+		total, err = getRowCount(ctx, bf.conn, table.Name)
+		if err != nil {
+			return fmt.Errorf("get row count for %q: %w", table.Name, err)
+		}
+	}
+
+	batchConn := bf.conn
+	if bf.batchConn != nil {
+		batchConn = bf.batchConn
+	}
+
+	// Backfill each column of the table in a pass of its own, then mark the
+	// rows as backfilled in a final pass
+	if pk, ok := b.(*pkBatcher); ok && len(bf.columnConns) > 0 && len(upTriggers) > 1 {
+		if err := bf.backfillColumns(ctx, pk, upTriggers); err != nil {
+			return err
+		}
+		b = pk.markPass()
+	}
+
+	// Backfill ranges of the table's rows at once, each on a connection of its
+	// own. Rows with a NULL batch key are only found once the rest of the
+	// table has been backfilled, so tables with a batch key aren't split.
+	if pk, ok := b.(*pkBatcher); ok && len(bf.rangeConns) > 1 && len(pk.BatchKey) == 0 && pk.trigger == "" {
+		return bf.backfillRanges(ctx, table.Name, pk, total)
 	}
 
 	// Update each batch of rows, invoking callbacks for each one.
 	for batch := 0; ; batch++ {
 		for _, cb := range bf.callbacks {
-			cb(int64(batch*bf.batchSize), total)
+			cb(int64(batch*batchSize), total)
 		}
 
-		if err := b.updateBatch(ctx, bf.conn); err != nil {
+		start := time.Now()
+		rows, err := bf.updateBatch(ctx, batchConn, b)
+		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				break
 			}
 			return err
 		}
+		for _, cb := range bf.batchCallbacks {
+			cb(table.Name, rows, time.Since(start))
+		}
+
+		if err := bf.saveProgress(ctx, table.Name, b.position(), rows, total, false); err != nil {
+			return err
+		}
+
+		if err := waitBatchDelay(ctx, bf.batchDelay); err != nil {
+			return err
+		}
+	}
+
+	return bf.saveProgress(ctx, table.Name, b.position(), 0, total, true)
+}
+
+// pendingTasks narrows the backfill of the table to the tasks that still have
+// rows to backfill, as marked by the needs backfill column. A task is skipped
+// if none of the marked rows match its filter, which is the case once the
+// rows of its columns have all been backfilled by an earlier, interrupted
+// start of the migration. It returns the condition limiting the backfill to
+// the rows of the remaining tasks, the up triggers that set their columns, and
+// whether any task remains. Tables whose tasks are unknown are checked as a
+// whole against the given filter.
+func (bf *Backfill) pendingTasks(ctx context.Context, table, filter string) (string, []string, bool, error) {
+	scopes, ok := bf.scopes[table]
+	if !ok {
+		total, err := getPendingRowCount(ctx, bf.conn, table, bf.NeedsBackfillColumn(), filter)
+		if err != nil {
+			return "", nil, false, fmt.Errorf("get pending row count for %q: %w", table, err)
+		}
+		return filter, bf.upTriggers[table], total > 0, nil
+	}
+
+	var conditions, triggers []string
+	pending, unfiltered := false, false
+	for _, scope := range scopes {
+		total, err := getPendingRowCount(ctx, bf.conn, table, bf.NeedsBackfillColumn(), scope.filter)
+		if err != nil {
+			return "", nil, false, fmt.Errorf("get pending row count for %q: %w", table, err)
+		}
+		if total == 0 {
+			for _, cb := range bf.skipCallbacks {
+				cb(table, scope.operation)
+			}
+			continue
+		}
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(bf.batchDelay):
+		pending = true
+		if scope.filter == "" {
+			unfiltered = true
+		} else {
+			conditions = append(conditions, "("+scope.filter+")")
 		}
+		triggers = append(triggers, scope.upTriggers...)
 	}
 
+	// Keep the up triggers in the order in which they were created
+	upTriggers := slices.DeleteFunc(slices.Clone(bf.upTriggers[table]), func(trigger string) bool {
+		return !slices.Contains(triggers, trigger)
+	})
+
+	if unfiltered {
+		return "", upTriggers, pending, nil
+	}
+	return strings.Join(conditions, " OR "), upTriggers, pending, nil
+}
+
+// backfillColumns backfills each column of the table in a pass of its own,
+// running a pass on each of the backfill's column connections at a time. Each
+// pass pages through the rows that need a backfill from where the batcher
+// would start, and leaves them marked as needing a backfill for the passes of
+// the other columns. If a pass fails, the other passes are cancelled.
+func (bf *Backfill) backfillColumns(ctx context.Context, b *pkBatcher, triggers []string) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	next := make(chan string)
+	var wg sync.WaitGroup
+	for _, conn := range bf.columnConns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for trigger := range next {
+				if err := bf.backfillColumn(ctx, conn, b.columnPass(trigger)); err != nil {
+					cancel(fmt.Errorf("backfill column with trigger %q: %w", trigger, err))
+				}
+			}
+		}()
+	}
+	for _, trigger := range triggers {
+		next <- trigger
+	}
+	close(next)
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+// backfillColumn updates each batch of rows of a column pass until no rows are
+// left to update.
+func (bf *Backfill) backfillColumn(ctx context.Context, conn db.DB, b *pkBatcher) error {
+	for {
+		_, err := bf.updateBatch(ctx, conn, b)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := waitBatchDelay(ctx, bf.batchDelay); err != nil {
+			return err
+		}
+	}
+}
+
+// backfillRanges splits the rows of the table that need a backfill into a
+// range for each of the backfill's range connections, and backfills the
+// ranges at once, each on a connection of its own. The batches of all ranges
+// are reported to the backfill's callbacks and progress as they are
+// committed. If a range fails, the other ranges are cancelled.
+func (bf *Backfill) backfillRanges(ctx context.Context, table string, b *pkBatcher, total int64) error {
+	bounds, err := rangeBounds(ctx, bf.conn, b.BatchConfig, len(bf.rangeConns))
+	if err != nil {
+		return fmt.Errorf("split %q into ranges: %w", table, err)
+	}
+	ranges := b.rangePasses(bounds)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// The batches of the ranges are reported one at a time, so that the
+	// callbacks aren't called concurrently
+	var mu sync.Mutex
+	var done int64
+	report := func(rows int64, duration time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		done += rows
+		for _, cb := range bf.callbacks {
+			cb(done, total)
+		}
+		for _, cb := range bf.batchCallbacks {
+			cb(table, rows, duration)
+		}
+		// The ranges have no single position from which the backfill can
+		// continue, so an interrupted backfill starts from the first row again
+		return bf.saveProgress(ctx, table, nil, rows, total, false)
+	}
+
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bf.backfillRange(ctx, bf.rangeConns[i], r, report); err != nil {
+				cancel(fmt.Errorf("backfill range %d of %q: %w", i+1, table, err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	return bf.saveProgress(ctx, table, nil, 0, total, true)
+}
+
+// backfillRange updates each batch of rows of a range until no rows are left
+// to update, reporting each batch once it has been committed.
+func (bf *Backfill) backfillRange(ctx context.Context, conn db.DB, b *pkBatcher, report func(rows int64, duration time.Duration) error) error {
+	for {
+		start := time.Now()
+		rows, err := bf.updateBatch(ctx, conn, b)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := report(rows, time.Since(start)); err != nil {
+			return err
+		}
+
+		if err := waitBatchDelay(ctx, bf.batchDelay); err != nil {
+			return err
+		}
+	}
+}
+
+// rangeBounds returns the primary keys that split the rows of the table that
+// need a backfill into up to n ranges of about the same size: the key of the
+// last row of each range but the last. The last range has no upper bound, so
+// that the ranges cover every key. Fewer bounds are returned if the table has
+// fewer rows to backfill than ranges.
+func rangeBounds(ctx context.Context, conn db.DB, cfg templates.BatchConfig, n int) ([][]string, error) {
+	query, err := templates.BuildBoundsSQL(cfg, n)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bounds [][]string
+	for rows.Next() {
+		bound := make([]string, len(cfg.PrimaryKey))
+		dest := make([]any, len(bound))
+		for i := range bound {
+			dest[i] = &bound[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		bounds = append(bounds, bound)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(bounds) == 0 {
+		return nil, nil
+	}
+	return bounds[:len(bounds)-1], nil
+}
+
+// updateBatch updates the next batch of rows of the batcher. A batch that
+// conflicts with a concurrent write to one of its rows makes no changes, so it
+// is run again, up to maxSerializationRetries times.
+func (bf *Backfill) updateBatch(ctx context.Context, conn db.DB, b batcher) (int64, error) {
+	for retries := 0; ; retries++ {
+		rows, err := b.updateBatch(ctx, conn)
+		if !isSerializationFailure(err) || retries >= maxSerializationRetries {
+			return rows, err
+		}
+		if err := waitBatchDelay(ctx, bf.batchDelay); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// saveProgress stores the progress of the backfill of the table, if the
+// backfill has somewhere to store it.
+func (bf *Backfill) saveProgress(ctx context.Context, table string, lastValue []string, rows, total int64, done bool) error {
+	if bf.progress == nil {
+		return nil
+	}
+	if err := bf.progress.Save(ctx, table, lastValue, rows, total, done); err != nil {
+		return fmt.Errorf("save backfill progress of %q: %w", table, err)
+	}
 	return nil
 }
 
+// waitBatchDelay waits for the delay between two batches of a backfill. It
+// returns early with the context's error if the context is cancelled while
+// waiting.
+func waitBatchDelay(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // getRowCount will attempt to get the row count for the given table. It first attempts to get an
 // estimate and if that is zero, falls back to a full table scan.
 func getRowCount(ctx context.Context, conn db.DB, tableName string) (int64, error) {
@@ -236,15 +899,56 @@ func getRowCount(ctx context.Context, conn db.DB, tableName string) (int64, erro
 	return total, nil
 }
 
-// getIdentityColumn will return a column suitable for use in a backfill operation.
-func getIdentityColumns(table *schema.Table) []string {
+// getPendingRowCount returns the number of rows in the given table that have
+// not been backfilled yet and match the filter, if any.
+func getPendingRowCount(ctx context.Context, conn db.DB, tableName, needsBackfillColumn, filter string) (int64, error) {
+	var total int64
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`SELECT count(*) FROM %s WHERE %s = true%s`,
+		pq.QuoteIdentifier(tableName),
+		pq.QuoteIdentifier(needsBackfillColumn),
+		filterSQL(filter)))
+	if err != nil {
+		return 0, fmt.Errorf("getting pending row count for %q: %w", tableName, err)
+	}
+	defer rows.Close()
+	if err := db.ScanFirstValue(rows, &total); err != nil {
+		return 0, fmt.Errorf("scanning pending row count for %q: %w", tableName, err)
+	}
+
+	return total, nil
+}
+
+// getIdentityColumns returns the columns by which the rows of the table are
+// identified and paged during a backfill. The primary key is used if the
+// table has one. Otherwise, the unique index with the fewest columns that are
+// all NOT NULL is used, as NULLs are neither unique nor ordered by a keyset
+// comparison. Partial and expression indexes, and indexes on columns that
+// pgroll creates for the migration, as named by the naming, are not
+// considered. nil is returned if the table has no suitable key.
+func getIdentityColumns(table *schema.Table, naming Naming) []string {
 	if len(table.PrimaryKey) != 0 {
 		return table.PrimaryKey
 	}
 
-	// If there is no primary key, look for a unique not null column
-	for _, col := range table.Columns {
-		if col.Unique && !col.Nullable {
+	var key []string
+	var keyIndex string
+	for name, idx := range table.Indexes {
+		if !idx.Unique || idx.Predicate != nil || len(idx.Expressions) > 0 || !usableIdentityColumns(table, idx.Columns, naming) {
+			continue
+		}
+		if key == nil || len(idx.Columns) < len(key) || (len(idx.Columns) == len(key) && name < keyIndex) {
+			key, keyIndex = idx.Columns, name
+		}
+	}
+	if key != nil {
+		return key
+	}
+
+	// If there is no unique index, look for a unique not null column
+	names := slices.Sorted(maps.Keys(table.Columns))
+	for _, name := range names {
+		col := table.Columns[name]
+		if col.Unique && usableIdentityColumns(table, []string{col.Name}, naming) {
 			return []string{col.Name}
 		}
 	}
@@ -253,9 +957,114 @@ func getIdentityColumns(table *schema.Table) []string {
 	return nil
 }
 
-// A batcher is responsible for updating a batch of rows in a table.
+// usableIdentityColumns reports whether the given physical columns of the
+// table are all NOT NULL columns that are not created by pgroll.
+func usableIdentityColumns(table *schema.Table, columns []string, naming Naming) bool {
+	if len(columns) == 0 {
+		return false
+	}
+	for _, name := range columns {
+		col := findPhysicalColumn(table, name)
+		if col == nil || col.Nullable || strings.HasPrefix(name, naming.Prefix()) {
+			return false
+		}
+	}
+	return true
+}
+
+// findPhysicalColumn returns the column of the table with the given physical
+// name, or nil if there is none.
+func findPhysicalColumn(table *schema.Table, name string) *schema.Column {
+	for _, col := range table.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// PagingKey returns the key by which the rows of the table are paged during
+// its backfill: the table's batch key, if one is set, followed by the columns
+// that identify its rows. nil is returned if the table has no key, in which
+// case the rows that need a backfill are found using the needs backfill
+// column.
+func (bf *Backfill) PagingKey(table *schema.Table) []string {
+	identityColumns := getIdentityColumns(table, bf.naming)
+	if identityColumns == nil {
+		return nil
+	}
+	key := slices.Clone(bf.batchKeys[table.Name])
+	for _, c := range identityColumns {
+		key = append(key, pq.QuoteIdentifier(c))
+	}
+	return key
+}
+
+// hasBatchKeyIndex reports whether the table has a valid, non-partial btree
+// index whose leading key columns match the given batch key.
+func hasBatchKeyIndex(ctx context.Context, conn db.DB, tableName string, key []string) (bool, error) {
+	rows, err := conn.QueryContext(ctx, `
+	  SELECT array_agg(pg_get_indexdef(i.indexrelid, k, true) ORDER BY k)
+	  FROM pg_index i
+	  JOIN pg_class c ON c.oid = i.indexrelid
+	  JOIN pg_am am ON am.oid = c.relam
+	  CROSS JOIN generate_series(1, $2::int) AS k
+	  WHERE i.indrelid = $1::regclass
+	    AND i.indisvalid
+	    AND i.indpred IS NULL
+	    AND i.indnkeyatts >= $2
+	    AND am.amname = 'btree'
+	  GROUP BY i.indexrelid`, pq.QuoteIdentifier(tableName), len(key))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var columns pq.StringArray
+		if err := rows.Scan(&columns); err != nil {
+			return false, err
+		}
+		if batchKeyMatchesIndex(key, columns) {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// batchKeyMatchesIndex reports whether the batch key matches the leading key
+// columns of an index, as returned by pg_get_indexdef. Case, whitespace and
+// identifier quoting are ignored in the comparison.
+func batchKeyMatchesIndex(key, indexColumns []string) bool {
+	if len(indexColumns) < len(key) {
+		return false
+	}
+
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(s, `"`, ""))), "")
+	}
+	for i := range key {
+		if normalize(key[i]) != normalize(indexColumns[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// A batcher is responsible for updating a batch of rows in a table. It
+// returns the number of rows updated by the batch, or sql.ErrNoRows once no
+// rows are left to update.
 type batcher interface {
-	updateBatch(context.Context, db.DB) error
+	updateBatch(context.Context, db.DB) (int64, error)
+
+	// position returns the paging key of the last row updated, or nil if the
+	// batcher doesn't page through the table by key.
+	position() []string
+
+	// resume makes the next batch start after the row with the given paging
+	// key, as returned by position.
+	resume(lastValue []string)
 }
 
 // pkBatcher is responsible for updating a batch of rows in a table.
@@ -263,32 +1072,164 @@ type batcher interface {
 // It holds the state necessary to update the next batch of rows.
 type pkBatcher struct {
 	templates.BatchConfig
+
+	// separateMark marks the rows of each batch as backfilled with a separate
+	// statement, after the statement that backfills their data.
+	separateMark bool
+
+	// isolationLevel is the isolation level of each batch's transaction
+	isolationLevel IsolationLevel
+
+	// trigger is the only trigger that sets its column for the rows of each
+	// batch, in a pass for the columns of the table. The rows are left marked
+	// as needing a backfill unless separateMark is set.
+	trigger string
+}
+
+// markPassTrigger is the trigger of the pass that marks the rows of a table as
+// backfilled once its columns have been backfilled. No trigger has the name,
+// so none of them sets its column again.
+const markPassTrigger = "-"
+
+// columnPass returns a batcher for the pass that backfills the column set by
+// the given trigger, starting from where the batcher would start.
+func (b *pkBatcher) columnPass(trigger string) *pkBatcher {
+	pass := *b
+	pass.BatchKey = slices.Clone(b.BatchKey)
+	pass.LastValue = slices.Clone(b.LastValue)
+	pass.separateMark = false
+	pass.trigger = trigger
+	return &pass
+}
+
+// markPass returns a batcher for the pass that marks the rows of the table as
+// backfilled once each of its columns has been backfilled by a pass.
+func (b *pkBatcher) markPass() *pkBatcher {
+	pass := b.columnPass(markPassTrigger)
+	pass.separateMark = true
+	return pass
 }
 
-func (b *pkBatcher) updateBatch(ctx context.Context, conn db.DB) error {
-	return conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+// rangePasses returns a batcher for each of the ranges of rows separated by
+// the given bounds. The first range starts from where the batcher would start,
+// and the last one has no upper bound.
+func (b *pkBatcher) rangePasses(bounds [][]string) []*pkBatcher {
+	passes := make([]*pkBatcher, 0, len(bounds)+1)
+	lower := b.LastValue
+	for _, upper := range append(bounds, nil) {
+		pass := *b
+		pass.LastValue = slices.Clone(lower)
+		pass.UpperBound = upper
+		passes = append(passes, &pass)
+		lower = upper
+	}
+	return passes
+}
+
+func (b *pkBatcher) position() []string {
+	return b.LastValue
+}
+
+// resume ignores paging keys whose length doesn't match that of the batch
+// key followed by the primary key. This is the case if the batch key of the
+// table has changed, or if the backfill had moved on to the rows whose batch
+// key is NULL; the backfill then starts from the first row again, skipping
+// the rows that have already been backfilled.
+func (b *pkBatcher) resume(lastValue []string) {
+	if len(lastValue) != len(b.BatchKey)+len(b.PrimaryKey) {
+		return
+	}
+	b.LastValue = lastValue
+}
+
+func (b *pkBatcher) updateBatch(ctx context.Context, conn db.DB) (int64, error) {
+	rows, err := b.updateKeyedBatch(ctx, conn)
+	if errors.Is(err, sql.ErrNoRows) && len(b.BatchKey) > 0 {
+		// Rows whose batch key is NULL can't be paged through by the batch key,
+		// so they are backfilled by their identity columns once all other rows
+		// have been backfilled.
+		b.Filter = nullBatchKeyFilter(b.Filter, b.BatchKey)
+		b.BatchKey = nil
+		b.LastValue = nil
+		return b.updateKeyedBatch(ctx, conn)
+	}
+	return rows, err
+}
+
+// nullBatchKeyFilter returns a filter that matches the rows that match the
+// given filter and have a NULL value for any element of the batch key.
+func nullBatchKeyFilter(filter string, batchKey []string) string {
+	nulls := make([]string, len(batchKey))
+	for i, key := range batchKey {
+		nulls[i] = fmt.Sprintf("(%s) IS NULL", key)
+	}
+	nullFilter := strings.Join(nulls, " OR ")
+	if filter == "" {
+		return nullFilter
+	}
+	return fmt.Sprintf("(%s) AND (%s)", filter, nullFilter)
+}
+
+func (b *pkBatcher) updateKeyedBatch(ctx context.Context, conn db.DB) (int64, error) {
+	var rows int64
+	var lastValue []string
+	err := conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		if err := setIsolationLevel(ctx, tx, b.isolationLevel); err != nil {
+			return err
+		}
+
 		// Build the query to update the next batch of rows
 		sql, err := templates.BuildSQL(b.BatchConfig)
 		if err != nil {
 			return err
 		}
 
-		// Execute the query to update the next batch of rows and update the last PK
-		// value for the next batch
-		if b.LastValue == nil {
-			b.LastValue = make([]string, len(b.PrimaryKey))
+		if b.separateMark || b.trigger != "" {
+			// Stop the triggers from clearing the needs backfill column as
+			// they backfill the rows of the batch
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL %s = 'on'", templates.DeferMarkSetting)); err != nil {
+				return err
+			}
 		}
-		wrapper := make([]any, len(b.LastValue))
-		for i := range b.LastValue {
-			wrapper[i] = &b.LastValue[i]
+		if b.trigger != "" {
+			// Only let the trigger of the pass set its column
+			if _, err := tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", templates.BackfillTriggerSetting, b.trigger); err != nil {
+				return err
+			}
 		}
+
+		// Execute the query to update the next batch of rows and get the last
+		// PK value for the next batch. The batcher moves on to the next batch
+		// only once the transaction has committed, so that a retried
+		// transaction updates the same batch again.
+		lastValue = make([]string, len(b.BatchKey)+len(b.PrimaryKey))
+		wrapper := make([]any, len(lastValue), len(lastValue)+1)
+		for i := range lastValue {
+			wrapper[i] = &lastValue[i]
+		}
+		wrapper = append(wrapper, &rows)
 		err = tx.QueryRowContext(ctx, sql).Scan(wrapper...)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		if !b.separateMark {
+			return nil
+		}
+
+		// Mark the rows of the batch as backfilled
+		markSQL, err := templates.BuildMarkSQL(b.BatchConfig, lastValue)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, markSQL)
+		return err
 	})
+	if err != nil {
+		return rows, err
+	}
+	b.LastValue = lastValue
+	return rows, nil
 }
 
 // needsBackfillColumnBatcher is responsible for updating a batch of rows in a table
@@ -297,24 +1238,71 @@ type needsBackfillColumnBatcher struct {
 	table               string
 	batchSize           int
 	needsBackfillColumn string
+	filter              string
+	isolationLevel      IsolationLevel
+}
+
+// position returns nil, as the rows that are left to backfill are found
+// using the needs backfill column.
+func (b *needsBackfillColumnBatcher) position() []string {
+	return nil
 }
 
-func (b *needsBackfillColumnBatcher) updateBatch(ctx context.Context, conn db.DB) error {
-	return conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
-		//nolint:gosec // tablenames are column names are checked
-		stmt := fmt.Sprintf("UPDATE %s SET %s = true WHERE ctid IN (SELECT ctid FROM %s WHERE %s = true LIMIT %d)",
-			pq.QuoteIdentifier(b.table),
-			pq.QuoteIdentifier(b.needsBackfillColumn),
-			pq.QuoteIdentifier(b.table),
-			pq.QuoteIdentifier(b.needsBackfillColumn),
-			b.batchSize)
+func (b *needsBackfillColumnBatcher) resume([]string) {}
+
+func (b *needsBackfillColumnBatcher) updateBatch(ctx context.Context, conn db.DB) (int64, error) {
+	var rows int64
+	err := conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		if err := setIsolationLevel(ctx, tx, b.isolationLevel); err != nil {
+			return err
+		}
+		stmt := needsBackfillBatchSQL(b.table, b.needsBackfillColumn, b.batchSize, b.filter)
 		res, err := tx.Exec(stmt)
 		if err != nil {
 			return err
 		}
-		if count, err := res.RowsAffected(); err != nil || count == 0 {
+		if rows, err = res.RowsAffected(); err != nil || rows == 0 {
 			return sql.ErrNoRows
 		}
 		return nil
 	})
+	return rows, err
+}
+
+// needsBackfillBatchSQL returns a statement that backfills the next batch of
+// rows that are marked as needing a backfill and match the filter, if any.
+// Updating the needs backfill column fires the table's backfill triggers,
+// which clear the column again.
+func needsBackfillBatchSQL(table, needsBackfillColumn string, batchSize int, filter string) string {
+	//nolint:gosec // tablenames are column names are checked
+	return fmt.Sprintf("UPDATE %s SET %s = true WHERE ctid IN (SELECT ctid FROM %s WHERE %s = true%s LIMIT %d)",
+		pq.QuoteIdentifier(table),
+		pq.QuoteIdentifier(needsBackfillColumn),
+		pq.QuoteIdentifier(table),
+		pq.QuoteIdentifier(needsBackfillColumn),
+		filterSQL(filter),
+		batchSize)
+}
+
+// filterSQL returns the clause that adds the filter to a WHERE clause, or an
+// empty string if there is no filter.
+func filterSQL(filter string) string {
+	if filter == "" {
+		return ""
+	}
+	return fmt.Sprintf(" AND (%s)", filter)
+}
+
+// LoopSQL returns a statement that backfills all rows of the table that are
+// marked as needing a backfill and match the filter, if any, in batches of the
+// configured size. The statement runs in a single transaction; it is intended
+// for tools that apply migrations as SQL scripts rather than through pgroll.
+func (bf *Backfill) LoopSQL(table, filter string) string {
+	return fmt.Sprintf(`DO $$
+BEGIN
+  LOOP
+    %s;
+    EXIT WHEN NOT FOUND;
+  END LOOP;
+END $$`, needsBackfillBatchSQL(table, bf.NeedsBackfillColumn(), bf.batchSize, filter))
 }
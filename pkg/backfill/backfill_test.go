@@ -0,0 +1,361 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/backfill/templates"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+func TestBatchKeyMatchesIndex(t *testing.T) {
+	testCases := []struct {
+		name         string
+		key          []string
+		indexColumns []string
+		expected     bool
+	}{
+		{
+			name:         "identical columns",
+			key:          []string{"tenant_id", "created_at"},
+			indexColumns: []string{"tenant_id", "created_at"},
+			expected:     true,
+		},
+		{
+			name:         "key is a prefix of the index",
+			key:          []string{"tenant_id"},
+			indexColumns: []string{"tenant_id", "created_at"},
+			expected:     true,
+		},
+		{
+			name:         "case, whitespace and quoting are ignored",
+			key:          []string{`"Tenant_ID"`, "lower( email )"},
+			indexColumns: []string{"tenant_id", "lower(email)"},
+			expected:     true,
+		},
+		{
+			name:         "columns in a different order",
+			key:          []string{"created_at", "tenant_id"},
+			indexColumns: []string{"tenant_id", "created_at"},
+			expected:     false,
+		},
+		{
+			name:         "index has fewer columns than the key",
+			key:          []string{"tenant_id", "created_at"},
+			indexColumns: []string{"tenant_id"},
+			expected:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, batchKeyMatchesIndex(tc.key, tc.indexColumns))
+		})
+	}
+}
+
+func TestLoopSQL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filter   string
+		expected string
+	}{
+		{
+			name:   "without a filter",
+			filter: "",
+			expected: `DO $$
+BEGIN
+  LOOP
+    UPDATE "users" SET "_pgroll_needs_backfill" = true WHERE ctid IN (SELECT ctid FROM "users" WHERE "_pgroll_needs_backfill" = true LIMIT 500);
+    EXIT WHEN NOT FOUND;
+  END LOOP;
+END $$`,
+		},
+		{
+			name:   "with a filter",
+			filter: "active = true",
+			expected: `DO $$
+BEGIN
+  LOOP
+    UPDATE "users" SET "_pgroll_needs_backfill" = true WHERE ctid IN (SELECT ctid FROM "users" WHERE "_pgroll_needs_backfill" = true AND (active = true) LIMIT 500);
+    EXIT WHEN NOT FOUND;
+  END LOOP;
+END $$`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, New(nil, NewConfig(WithBatchSize(500))).LoopSQL("users", tc.filter))
+		})
+	}
+}
+
+func TestJobFilter(t *testing.T) {
+	users := &schema.Table{Name: "users"}
+	orders := &schema.Table{Name: "orders"}
+
+	newTask := func(table *schema.Table, filter string) *Task {
+		task := NewTask(table)
+		task.SetFilter(filter)
+		return task
+	}
+
+	job := NewJob("public", "public_01_migration")
+	job.AddTask(newTask(users, "active = true"))
+	job.AddTask(newTask(users, "id < 100"))
+	job.AddTask(newTask(orders, "total > 0"))
+	job.AddTask(newTask(orders, ""))
+
+	assert.Equal(t, "(active = true) OR (id < 100)", job.Filter("users"))
+	assert.Equal(t, "", job.Filter("orders"))
+	assert.Equal(t, "", job.Filter("products"))
+}
+
+func TestJobSkipsTriggersForGeneratedColumns(t *testing.T) {
+	expression := "first_name || ' ' || last_name"
+	users := &schema.Table{
+		Name: "users",
+		Columns: map[string]*schema.Column{
+			"name":      {Name: "_pgroll_new_name", Type: "text"},
+			"full_name": {Name: "_pgroll_new_full_name", Type: "text", Generated: &expression},
+		},
+	}
+
+	job := NewJob("public", "public_01_migration")
+	job.AddTask(NewTask(users,
+		OperationTrigger{
+			Name:           TriggerName("users", "name"),
+			Direction:      TriggerDirectionUp,
+			Columns:        users.Columns,
+			TableName:      "users",
+			PhysicalColumn: "_pgroll_new_name",
+			SQL:            "upper(name)",
+		},
+		OperationTrigger{
+			Name:           TriggerName("users", "full_name"),
+			Direction:      TriggerDirectionUp,
+			Columns:        users.Columns,
+			TableName:      "users",
+			PhysicalColumn: "_pgroll_new_full_name",
+			SQL:            expression,
+		},
+	))
+
+	assert.Len(t, job.triggers, 1)
+	assert.Contains(t, job.triggers, TriggerName("users", "name"))
+}
+
+func TestGetIdentityColumns(t *testing.T) {
+	notNull := func(name string) *schema.Column { return &schema.Column{Name: name} }
+	nullable := func(name string) *schema.Column { return &schema.Column{Name: name, Nullable: true} }
+	predicate := "deleted_at IS NULL"
+
+	testCases := []struct {
+		name     string
+		table    *schema.Table
+		expected []string
+	}{
+		{
+			name: "primary key",
+			table: &schema.Table{
+				Columns:    map[string]*schema.Column{"id": notNull("id"), "code": notNull("code")},
+				PrimaryKey: []string{"id"},
+				Indexes: map[string]*schema.Index{
+					"code_key": {Name: "code_key", Unique: true, Columns: []string{"code"}},
+				},
+			},
+			expected: []string{"id"},
+		},
+		{
+			name: "composite primary key",
+			table: &schema.Table{
+				Columns:    map[string]*schema.Column{"tenant_id": notNull("tenant_id"), "id": notNull("id")},
+				PrimaryKey: []string{"tenant_id", "id"},
+			},
+			expected: []string{"tenant_id", "id"},
+		},
+		{
+			name: "unique index with the fewest columns",
+			table: &schema.Table{
+				Columns: map[string]*schema.Column{"a": notNull("a"), "b": notNull("b"), "c": notNull("c")},
+				Indexes: map[string]*schema.Index{
+					"a_b_key": {Name: "a_b_key", Unique: true, Columns: []string{"a", "b"}},
+					"c_key":   {Name: "c_key", Unique: true, Columns: []string{"c"}},
+				},
+			},
+			expected: []string{"c"},
+		},
+		{
+			name: "ties are broken by index name",
+			table: &schema.Table{
+				Columns: map[string]*schema.Column{"a": notNull("a"), "b": notNull("b")},
+				Indexes: map[string]*schema.Index{
+					"b_key": {Name: "b_key", Unique: true, Columns: []string{"b"}},
+					"a_key": {Name: "a_key", Unique: true, Columns: []string{"a"}},
+				},
+			},
+			expected: []string{"a"},
+		},
+		{
+			name: "composite unique index",
+			table: &schema.Table{
+				Columns: map[string]*schema.Column{"tenant_id": notNull("tenant_id"), "code": notNull("code")},
+				Indexes: map[string]*schema.Index{
+					"tenant_code_key": {Name: "tenant_code_key", Unique: true, Columns: []string{"tenant_id", "code"}},
+				},
+			},
+			expected: []string{"tenant_id", "code"},
+		},
+		{
+			name: "unique index on a nullable column is not used",
+			table: &schema.Table{
+				Columns: map[string]*schema.Column{"tenant_id": notNull("tenant_id"), "code": nullable("code")},
+				Indexes: map[string]*schema.Index{
+					"tenant_code_key": {Name: "tenant_code_key", Unique: true, Columns: []string{"tenant_id", "code"}},
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "partial, expression and non-unique indexes are not used",
+			table: &schema.Table{
+				Columns: map[string]*schema.Column{"a": notNull("a"), "b": notNull("b"), "c": notNull("c")},
+				Indexes: map[string]*schema.Index{
+					"a_key": {Name: "a_key", Unique: true, Columns: []string{"a"}, Predicate: &predicate},
+					"b_key": {Name: "b_key", Unique: true, Columns: []string{"b"}, Expressions: []string{"lower(b)"}},
+					"c_idx": {Name: "c_idx", Columns: []string{"c"}},
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "indexes on columns created by pgroll are not used",
+			table: &schema.Table{
+				Columns: map[string]*schema.Column{"code": notNull("_pgroll_new_code")},
+				Indexes: map[string]*schema.Index{
+					"_pgroll_uniq_code": {Name: "_pgroll_uniq_code", Unique: true, Columns: []string{"_pgroll_new_code"}},
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "unique not null column",
+			table: &schema.Table{
+				Columns: map[string]*schema.Column{
+					"b": {Name: "b", Unique: true},
+					"a": {Name: "a", Unique: true},
+					"c": {Name: "c", Unique: true, Nullable: true},
+				},
+			},
+			expected: []string{"a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, getIdentityColumns(tc.table, Naming{}))
+		})
+	}
+}
+
+func TestPagingKey(t *testing.T) {
+	table := &schema.Table{
+		Name:       "users",
+		Columns:    map[string]*schema.Column{"id": {Name: "id"}},
+		PrimaryKey: []string{"id"},
+	}
+	keyless := &schema.Table{
+		Name:    "events",
+		Columns: map[string]*schema.Column{"id": {Name: "id", Nullable: true}},
+	}
+
+	bf := New(nil, NewConfig(WithBatchKey("users", "tenant_id", "lower(email)"), WithBatchKey("events", "created_at")))
+
+	assert.Equal(t, []string{"tenant_id", "lower(email)", `"id"`}, bf.PagingKey(table))
+	assert.Nil(t, bf.PagingKey(keyless))
+}
+
+func TestNullBatchKeyFilter(t *testing.T) {
+	assert.Equal(t,
+		"(tenant_id) IS NULL OR (lower(email)) IS NULL",
+		nullBatchKeyFilter("", []string{"tenant_id", "lower(email)"}))
+	assert.Equal(t,
+		"(status = 'active') AND ((tenant_id) IS NULL)",
+		nullBatchKeyFilter("status = 'active'", []string{"tenant_id"}))
+}
+
+func TestPkBatcherResume(t *testing.T) {
+	b := &pkBatcher{BatchConfig: templates.BatchConfig{
+		TableName:  "users",
+		PrimaryKey: []string{"id"},
+		BatchKey:   []string{"tenant_id"},
+	}}
+
+	// Keys from the rows whose batch key is NULL, or from another batch key,
+	// are ignored
+	b.resume([]string{"5"})
+	assert.Nil(t, b.position())
+
+	b.resume([]string{"3", "5"})
+	assert.Equal(t, []string{"3", "5"}, b.position())
+}
+
+func TestColumnPass(t *testing.T) {
+	b := &pkBatcher{
+		BatchConfig: templates.BatchConfig{
+			TableName:  "users",
+			PrimaryKey: []string{"id"},
+			LastValue:  []string{"10"},
+		},
+		separateMark: true,
+	}
+
+	pass := b.columnPass("trigger")
+	assert.Equal(t, "trigger", pass.trigger)
+	assert.False(t, pass.separateMark)
+	assert.Equal(t, []string{"10"}, pass.LastValue)
+
+	// the pass pages through the table without moving the batcher on
+	pass.LastValue[0] = "20"
+	assert.Equal(t, []string{"10"}, b.LastValue)
+
+	mark := b.markPass()
+	assert.Equal(t, markPassTrigger, mark.trigger)
+	assert.True(t, mark.separateMark)
+}
+
+func TestRangePasses(t *testing.T) {
+	b := &pkBatcher{
+		BatchConfig: templates.BatchConfig{
+			TableName:  "users",
+			PrimaryKey: []string{"id"},
+			LastValue:  []string{"10"},
+		},
+	}
+
+	// the ranges are disjoint and cover every key after where the batcher
+	// would start
+	passes := b.rangePasses([][]string{{"100"}, {"200"}})
+	assert.Len(t, passes, 3)
+	assert.Equal(t, []string{"10"}, passes[0].LastValue)
+	assert.Equal(t, []string{"100"}, passes[0].UpperBound)
+	assert.Equal(t, []string{"100"}, passes[1].LastValue)
+	assert.Equal(t, []string{"200"}, passes[1].UpperBound)
+	assert.Equal(t, []string{"200"}, passes[2].LastValue)
+	assert.Nil(t, passes[2].UpperBound)
+
+	// a range pages through its rows without moving the other ranges on
+	passes[0].LastValue[0] = "50"
+	assert.Equal(t, []string{"10"}, b.LastValue)
+	assert.Equal(t, []string{"100"}, passes[1].LastValue)
+
+	// without bounds, a single range covers the whole table
+	passes = b.rangePasses(nil)
+	assert.Len(t, passes, 1)
+	assert.Equal(t, []string{"10"}, passes[0].LastValue)
+	assert.Nil(t, passes[0].UpperBound)
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/db"
+)
+
+// tableBatchSize returns the number of rows to update in each batch of the
+// backfill of the table. Unless batches are sized automatically, this is the
+// configured batch size.
+func (bf *Backfill) tableBatchSize(ctx context.Context, tableName string) (int, error) {
+	if bf.batchBytes <= 0 {
+		return bf.batchSize, nil
+	}
+
+	width, err := getAverageRowWidth(ctx, bf.conn, tableName)
+	if err != nil {
+		return 0, fmt.Errorf("get average row width: %w", err)
+	}
+	return autoBatchSize(width, bf.batchBytes, bf.batchSize), nil
+}
+
+// autoBatchSize returns the number of rows of the given average width that
+// fit in a batch of targetBytes, between 1 and MaxAutoBatchSize rows. If the
+// row width is unknown, fallback is returned.
+func autoBatchSize(rowWidth float64, targetBytes int64, fallback int) int {
+	if rowWidth <= 0 {
+		return fallback
+	}
+
+	rows := float64(targetBytes) / rowWidth
+	switch {
+	case rows < 1:
+		return 1
+	case rows > float64(MaxAutoBatchSize):
+		return MaxAutoBatchSize
+	default:
+		return int(rows)
+	}
+}
+
+// getAverageRowWidth returns the average size in bytes of the rows of the
+// table on disk, including tuple headers, padding and free space, as estimated
+// from the table's statistics. It returns 0 if the table has no statistics,
+// e.g. because it has not been analyzed yet.
+func getAverageRowWidth(ctx context.Context, conn db.DB, tableName string) (float64, error) {
+	rows, err := conn.QueryContext(ctx, `
+	  SELECT CASE WHEN reltuples > 0 AND relpages > 0
+	    THEN relpages::float8 * current_setting('block_size')::float8 / reltuples
+	    ELSE 0
+	  END
+	  FROM pg_class
+	  WHERE oid = $1::regclass`,
+		pq.QuoteIdentifier(tableName))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var width float64
+	if err := db.ScanFirstValue(rows, &width); err != nil {
+		return 0, err
+	}
+	return width, nil
+}
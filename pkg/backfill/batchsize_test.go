@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoBatchSize(t *testing.T) {
+	tests := map[string]struct {
+		rowWidth float64
+		want     int
+	}{
+		"rows that fit the target": {rowWidth: 1024, want: 4096},
+		"no statistics":            {rowWidth: 0, want: DefaultBatchSize},
+		"narrow rows":              {rowWidth: 8, want: MaxAutoBatchSize},
+		"rows wider than target":   {rowWidth: 8 << 20, want: 1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, autoBatchSize(tt.rowWidth, DefaultBatchBytes, DefaultBatchSize))
+		})
+	}
+}
+
+func TestBatchCount(t *testing.T) {
+	tests := map[string]struct {
+		rows      int64
+		batchSize int
+		want      int64
+	}{
+		"empty table":         {rows: 0, batchSize: 1000, want: 0},
+		"less than one batch": {rows: 10, batchSize: 1000, want: 1},
+		"exact number":        {rows: 3000, batchSize: 1000, want: 3},
+		"partial last batch":  {rows: 3001, batchSize: 1000, want: 4},
+		"batch size not set":  {rows: 3001, batchSize: 0, want: 0},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, batchCount(tt.rows, tt.batchSize))
+		})
+	}
+}
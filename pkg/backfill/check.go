@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// notNullViolationErrorCode is the SQLSTATE of a NOT NULL violation.
+const notNullViolationErrorCode pq.ErrorCode = "23502"
+
+// errTriggerChecked rolls back the transaction in which a trigger is checked.
+var errTriggerChecked = errors.New("trigger checked")
+
+// CheckTriggers runs each of the job's trigger functions once, before the
+// triggers are created, so that errors in the up and down SQL, such as a
+// reference to a column that doesn't exist, are found before any row is
+// backfilled. Postgres only resolves the names used by a PL/pgSQL function
+// when the function is run, so creating the function isn't enough to find
+// them. Instead, in a transaction that is rolled back, each function is
+// created and attached to an empty temporary copy of its table, and run by
+// inserting a row of NULLs into the copy.
+//
+// A TriggerCheckError is returned for the first trigger whose function can't
+// be created or fails to run. SQL that fails only for NULL values, eg. a
+// function that rejects NULL arguments, fails the check too.
+func (bf *Backfill) CheckTriggers(ctx context.Context, j *Job) error {
+	for _, name := range slices.Sorted(maps.Keys(j.triggers)) {
+		trigger := j.triggers[name]
+		trigger.NeedsBackfillColumn = bf.NeedsBackfillColumn()
+		trigger.Naming = bf.naming
+		trigger.DeferMark = bf.separateMark
+		trigger.StackedSchemas = j.stackedSchemas
+		trigger.SQL = slices.Clone(trigger.SQL)
+		parenthesizeSQL(trigger.SQL)
+
+		err := bf.conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			return checkTrigger(ctx, tx, trigger)
+		})
+		if !errors.Is(err, errTriggerChecked) {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTrigger creates the trigger function in the transaction and runs it
+// for a row of NULLs. It returns errTriggerChecked if the function runs, so
+// that the transaction is rolled back.
+func checkTrigger(ctx context.Context, tx *sql.Tx, trigger triggerConfig) error {
+	checkTable := "pg_temp." + pq.QuoteIdentifier(trigger.Naming.Prefix()+"trigger_check")
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE %s) ON COMMIT DROP",
+		checkTable, pq.QuoteIdentifier(trigger.TableName)))
+	if err != nil {
+		return fmt.Errorf("create copy of table %q to check trigger %q: %w", trigger.TableName, trigger.Name, err)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s boolean",
+		checkTable, pq.QuoteIdentifier(trigger.NeedsBackfillColumn)))
+	if err != nil {
+		return fmt.Errorf("create copy of table %q to check trigger %q: %w", trigger.TableName, trigger.Name, err)
+	}
+
+	failed := func(err error) error {
+		return TriggerCheckError{
+			Operations: trigger.Operations,
+			Direction:  trigger.Direction,
+			Table:      trigger.TableName,
+			Column:     findColumnName(trigger.Columns, trigger.PhysicalColumn),
+			Err:        err,
+		}
+	}
+
+	funcSQL, err := buildFunction(trigger)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, funcSQL); err != nil {
+		return failed(err)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE TRIGGER %s BEFORE INSERT ON %s FOR EACH ROW EXECUTE PROCEDURE %s()",
+		pq.QuoteIdentifier(trigger.Name), checkTable, pq.QuoteIdentifier(trigger.Name)))
+	if err != nil {
+		return failed(err)
+	}
+
+	// The down trigger only runs its SQL for writes made through the latest
+	// version of the schema
+	if trigger.Direction == TriggerDirectionDown {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+pq.QuoteIdentifier(trigger.LatestSchema)); err != nil {
+			return err
+		}
+	}
+
+	// The copy keeps the NOT NULL constraints of the table, which the row of
+	// NULLs violates once the trigger has run
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", checkTable))
+	var pqErr *pq.Error
+	if err != nil && !(errors.As(err, &pqErr) && pqErr.Code == notNullViolationErrorCode) {
+		return failed(err)
+	}
+
+	return errTriggerChecked
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+func TestTriggerCheckError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err  TriggerCheckError
+		want string
+	}{
+		"PL/pgSQL error": {
+			err: TriggerCheckError{
+				Operations: []string{"2 (add_column)"},
+				Direction:  TriggerDirectionUp,
+				Table:      "items",
+				Column:     "name_upper",
+				Err: &pq.Error{
+					Message: `column "nme" does not exist`,
+					Where:   `PL/pgSQL function _pgroll_trigger_items_name_upper() line 14 at assignment`,
+				},
+			},
+			want: `up SQL of operation 2 (add_column) for column "name_upper" on table "items" fails: ` +
+				`column "nme" does not exist (PL/pgSQL function _pgroll_trigger_items_name_upper() line 14 at assignment)`,
+		},
+		"several operations": {
+			err: TriggerCheckError{
+				Operations: []string{"1 (alter_column)", "3 (alter_column)"},
+				Direction:  TriggerDirectionDown,
+				Table:      "items",
+				Column:     "name",
+				Err:        errors.New("boom"),
+			},
+			want: `down SQL of operations 1 (alter_column), 3 (alter_column) for column "name" on table "items" fails: boom`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, tc.err.Error())
+		})
+	}
+}
+
+func TestJobRecordsTriggerOperations(t *testing.T) {
+	t.Parallel()
+
+	table := &schema.Table{
+		Name: "items",
+		Columns: map[string]*schema.Column{
+			"name": {Name: "name", Type: "text"},
+		},
+	}
+	trigger := OperationTrigger{
+		Name:           TriggerName("items", "name"),
+		Direction:      TriggerDirectionUp,
+		Columns:        table.Columns,
+		TableName:      "items",
+		PhysicalColumn: "_pgroll_new_name",
+		SQL:            "upper(name)",
+	}
+
+	job := NewJob("public", "public_01_migration")
+	for _, op := range []string{"1 (alter_column)", "2 (alter_column)"} {
+		task := NewTask(table, trigger)
+		task.SetOperation(op)
+		job.AddTask(task)
+	}
+
+	assert.Equal(t, []string{"1 (alter_column)", "2 (alter_column)"}, job.triggers[trigger.Name].Operations)
+}
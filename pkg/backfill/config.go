@@ -7,23 +7,55 @@ import (
 )
 
 type Config struct {
-	batchSize  int
-	batchDelay time.Duration
-	callbacks  []CallbackFn
+	batchSize    int
+	batchBytes   int64
+	batchDelay   time.Duration
+	onlyIfNeeded bool
+	batchKeys    map[string][]string
+	triggerless  map[string]bool
+	noAutovacuum bool
+	verifySample int
+	callbacks    []CallbackFn
+
+	naming              Naming
+	needsBackfillColumn string
+	separateMark        bool
+	isolationLevel      IsolationLevel
+
+	sessionRole     string
+	sessionSettings map[string]string
+	sessionHooks    []SessionFn
+
+	columnConcurrency int
+	parallelism       int
 }
 
 const (
 	DefaultBatchSize int           = 1000
 	DefaultDelay     time.Duration = 0
+
+	// DefaultBatchBytes is the amount of data updated by each batch of a
+	// backfill when batches are sized automatically.
+	DefaultBatchBytes int64 = 4 << 20
+
+	// MaxAutoBatchSize is the largest number of rows updated by each batch of
+	// a backfill when batches are sized automatically.
+	MaxAutoBatchSize int = 100_000
+
+	// DefaultVerifySampleSize is the number of rows of each table sampled to
+	// check that the down SQL reverses the up SQL.
+	DefaultVerifySampleSize int = 1000
 )
 
 type OptionFn func(*Config)
 
 func NewConfig(opts ...OptionFn) *Config {
 	c := &Config{
-		batchSize:  DefaultBatchSize,
-		batchDelay: DefaultDelay,
-		callbacks:  make([]CallbackFn, 0),
+		batchSize:   DefaultBatchSize,
+		batchDelay:  DefaultDelay,
+		batchKeys:   make(map[string][]string),
+		triggerless: make(map[string]bool),
+		callbacks:   make([]CallbackFn, 0),
 	}
 
 	for _, opt := range opts {
@@ -32,20 +64,242 @@ func NewConfig(opts ...OptionFn) *Config {
 	return c
 }
 
-// WithBatchSize sets the batch size for the backfill operation.
+// WithBatchSize sets the batch size for the backfill operation. A batch size
+// of zero or less leaves the default batch size in place.
 func WithBatchSize(batchSize int) OptionFn {
 	return func(o *Config) {
+		if batchSize <= 0 {
+			batchSize = DefaultBatchSize
+		}
 		o.batchSize = batchSize
 	}
 }
 
-// WithBatchDelay sets the delay between batches for the backfill operation.
+// WithAutoBatchSize sizes the batches of each table's backfill so that each
+// batch updates about targetBytes of data, based on the average width of the
+// table's rows on disk. This bounds the IO of each batch for tables with very
+// narrow or very wide rows alike. Tables without statistics, e.g. because they
+// have not been analyzed yet, are backfilled in batches of the size set by
+// WithBatchSize.
+func WithAutoBatchSize(targetBytes int64) OptionFn {
+	return func(o *Config) {
+		o.batchBytes = targetBytes
+	}
+}
+
+// WithBatchDelay sets the delay between batches for the backfill operation. A
+// delay of zero or less runs the batches back to back.
 func WithBatchDelay(delay time.Duration) OptionFn {
 	return func(o *Config) {
+		if delay < 0 {
+			delay = DefaultDelay
+		}
 		o.batchDelay = delay
 	}
 }
 
+// WithOnlyIfNeeded skips the backfill of the columns that have no rows left to
+// backfill, as marked by the needs backfill column. The rows left to backfill
+// are counted for each task, among the rows that match the task's filter, so
+// that a table whose backfill was interrupted only backfills the columns of
+// the tasks that hadn't finished. Tables with no task left are skipped.
+func WithOnlyIfNeeded(onlyIfNeeded bool) OptionFn {
+	return func(o *Config) {
+		o.onlyIfNeeded = onlyIfNeeded
+	}
+}
+
+// WithBatchKey sets the key by which the rows of the given table are paged
+// during the backfill. Each element of the key is a column name or SQL
+// expression, and the table must have an index whose leading key columns
+// match the key. The table's primary key is used to break ties between rows
+// with the same key.
+func WithBatchKey(table string, key ...string) OptionFn {
+	return func(o *Config) {
+		o.batchKeys[table] = key
+	}
+}
+
+// WithoutTriggers backfills the given tables without leaving update triggers
+// in place for the rest of the migration. The triggers that keep the old and
+// new versions of the tables' columns in sync are used for a one-shot
+// backfill, after which they only fire on INSERT, and a statement-level guard
+// rejects any UPDATE, DELETE or TRUNCATE of the tables until the migration is
+// completed or rolled back. This asserts that existing rows of the tables are
+// not changed while the migration is active, and removes the per-row cost of
+// the triggers on updates. Completing the migration fails if the guard was
+// disabled or dropped in the meantime.
+func WithoutTriggers(tables ...string) OptionFn {
+	return func(o *Config) {
+		for _, table := range tables {
+			o.triggerless[table] = true
+		}
+	}
+}
+
+// WithAutovacuumDisabled disables autovacuum on each table while it is being
+// backfilled, so that autovacuum runs don't contend with the backfill. The
+// table's previous autovacuum_enabled setting is restored once its backfill
+// has finished, whether or not it succeeded. Dead rows left by the backfill
+// are not vacuumed until autovacuum is enabled again.
+func WithAutovacuumDisabled(disabled bool) OptionFn {
+	return func(o *Config) {
+		o.noAutovacuum = disabled
+	}
+}
+
+// WithReversibilityCheck checks, once the tables have been backfilled, that
+// applying the down SQL of each column change to the backfilled value of the
+// new column reproduces the value of the old column. The check is made on a
+// random sample of up to sampleSize backfilled rows of each table. A sample
+// size of zero disables the check.
+func WithReversibilityCheck(sampleSize int) OptionFn {
+	return func(o *Config) {
+		o.verifySample = sampleSize
+	}
+}
+
+// WithNeedsBackfillColumn sets the name of the column that marks the rows of
+// each table that are still to be backfilled. The column is created on each
+// table backfilled by the migration, so the name must not clash with any of
+// their columns. An empty name leaves the default name of the naming in
+// place.
+func WithNeedsBackfillColumn(name string) OptionFn {
+	return func(o *Config) {
+		o.needsBackfillColumn = name
+	}
+}
+
+// WithNaming sets the naming of the columns, triggers and other objects that
+// the backfill creates for its own use. They must be named as the objects
+// created by the migration being backfilled.
+func WithNaming(n Naming) OptionFn {
+	return func(o *Config) {
+		o.naming = n
+	}
+}
+
+// WithSeparateBackfillMark marks the rows of each batch as backfilled with a
+// separate statement, after the statement that backfills their data. Row
+// changes captured from the WAL then show the backfill of a row's data
+// separately from the change to the needs backfill column. Tables without a
+// primary key or unique NOT NULL columns are always backfilled with a single
+// statement per batch.
+func WithSeparateBackfillMark(separate bool) OptionFn {
+	return func(o *Config) {
+		o.separateMark = separate
+	}
+}
+
+// WithIsolationLevel sets the transaction isolation level at which each batch
+// of a backfill runs. An empty level runs the batches at the session's default
+// level, which is READ COMMITTED unless the server is configured otherwise.
+// Batches that fail to serialize with a concurrent transaction at REPEATABLE
+// READ or SERIALIZABLE are retried.
+func WithIsolationLevel(level IsolationLevel) OptionFn {
+	return func(o *Config) {
+		o.isolationLevel = level
+	}
+}
+
+// WithSessionRole runs the batches of a backfill as the given role, in a
+// session of their own. An empty role leaves the batches to run as the role of
+// the migration.
+func WithSessionRole(role string) OptionFn {
+	return func(o *Config) {
+		o.sessionRole = role
+	}
+}
+
+// WithSessionSettings sets the given configuration parameters, such as
+// statement_timeout or synchronous_commit, for the session in which the
+// batches of a backfill run. The session is separate from the one in which the
+// migration's DDL runs, so the settings don't apply to the DDL.
+func WithSessionSettings(settings map[string]string) OptionFn {
+	return func(o *Config) {
+		o.sessionSettings = settings
+	}
+}
+
+// WithSessionHook calls fn to set up the session in which the batches of a
+// backfill run, after its role and settings have been set. The session is
+// separate from the one in which the migration's DDL runs.
+func WithSessionHook(fn SessionFn) OptionFn {
+	return func(o *Config) {
+		o.sessionHooks = append(o.sessionHooks, fn)
+	}
+}
+
+// WithColumnConcurrency backfills the columns of a table that are set by
+// triggers of their own, such as the columns added by several add_column
+// operations, in a pass per column, running up to n passes at once, each on a
+// connection of its own. The rows of each pass are left marked as needing a
+// backfill, and are marked as backfilled by a final pass once the passes for
+// all columns have finished. Tables without a primary key or unique NOT NULL
+// columns, and tables with a single column to backfill, are backfilled in a
+// single pass. A concurrency of one or less backfills every table in a single
+// pass.
+func WithColumnConcurrency(n int) OptionFn {
+	return func(o *Config) {
+		o.columnConcurrency = n
+	}
+}
+
+// ColumnConcurrency returns the number of column passes of a backfill that
+// run at once, or zero if the columns of each table are backfilled in a single
+// pass.
+func (c *Config) ColumnConcurrency() int {
+	if c.columnConcurrency <= 1 {
+		return 0
+	}
+	return c.columnConcurrency
+}
+
+// WithParallelism backfills each table in up to n ranges of its rows at once,
+// each on a connection of its own. The ranges are found by splitting the rows
+// that need a backfill into n groups of about the same size, ordered by the
+// table's primary key or unique NOT NULL columns. The ranges are disjoint and
+// together cover every possible key, so each row is backfilled by exactly one
+// range and the batches of different ranges never lock the same rows. Tables
+// without a primary key or unique NOT NULL columns, tables with a batch key,
+// and tables whose columns are backfilled in passes of their own are
+// backfilled in a single range. A parallelism of one or less backfills every
+// table in a single range.
+func WithParallelism(n int) OptionFn {
+	return func(o *Config) {
+		o.parallelism = n
+	}
+}
+
+// Parallelism returns the number of ranges of each table's rows that are
+// backfilled at once, or zero if each table is backfilled in a single range.
+func (c *Config) Parallelism() int {
+	if c.parallelism <= 1 {
+		return 0
+	}
+	return c.parallelism
+}
+
+// NeedsBackfillColumn returns the name of the column that marks the rows that
+// are still to be backfilled.
+func (c *Config) NeedsBackfillColumn() string {
+	if c.needsBackfillColumn == "" {
+		return c.naming.NeedsBackfillColumn()
+	}
+	return c.needsBackfillColumn
+}
+
+// Naming returns the naming of the objects that the backfill creates for its
+// own use.
+func (c *Config) Naming() Naming {
+	return c.naming
+}
+
+// Triggerless returns true if the table is to be backfilled without triggers.
+func (c *Config) Triggerless(table string) bool {
+	return c.triggerless[table]
+}
+
 // AddCallback adds a callback to the backfill operation.
 // Callbacks are invoked after each batch is processed.
 func (c *Config) AddCallback(fn CallbackFn) {
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/db"
+)
+
+func TestNewConfig(t *testing.T) {
+	tests := map[string]struct {
+		opts      []OptionFn
+		wantSize  int
+		wantDelay time.Duration
+	}{
+		"defaults": {
+			wantSize:  DefaultBatchSize,
+			wantDelay: DefaultDelay,
+		},
+		"batch size and delay": {
+			opts:      []OptionFn{WithBatchSize(500), WithBatchDelay(100 * time.Millisecond)},
+			wantSize:  500,
+			wantDelay: 100 * time.Millisecond,
+		},
+		"zero batch size and delay": {
+			opts:      []OptionFn{WithBatchSize(0), WithBatchDelay(0)},
+			wantSize:  DefaultBatchSize,
+			wantDelay: DefaultDelay,
+		},
+		"negative batch size and delay": {
+			opts:      []OptionFn{WithBatchSize(-1), WithBatchDelay(-time.Second)},
+			wantSize:  DefaultBatchSize,
+			wantDelay: DefaultDelay,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewConfig(tt.opts...)
+			assert.Equal(t, tt.wantSize, c.batchSize)
+			assert.Equal(t, tt.wantDelay, c.batchDelay)
+		})
+	}
+}
+
+func TestWithNeedsBackfillColumn(t *testing.T) {
+	assert.Equal(t, CNeedsBackfillColumn, NewConfig().NeedsBackfillColumn())
+	assert.Equal(t, CNeedsBackfillColumn, NewConfig(WithNeedsBackfillColumn("")).NeedsBackfillColumn())
+	assert.Equal(t, "cdc_needs_backfill", NewConfig(WithNeedsBackfillColumn("cdc_needs_backfill")).NeedsBackfillColumn())
+}
+
+func TestWaitBatchDelay(t *testing.T) {
+	t.Run("waits for the delay", func(t *testing.T) {
+		start := time.Now()
+		assert.NoError(t, waitBatchDelay(context.Background(), 10*time.Millisecond))
+		assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	})
+
+	t.Run("cancelled context interrupts the delay", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		start := time.Now()
+		assert.ErrorIs(t, waitBatchDelay(ctx, time.Minute), context.Canceled)
+		assert.Less(t, time.Since(start), time.Minute)
+	})
+
+	t.Run("cancelled context with no delay", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, waitBatchDelay(ctx, 0), context.Canceled)
+	})
+}
+
+func TestSeparateSession(t *testing.T) {
+	hook := func(context.Context, db.DB) error { return nil }
+
+	assert.False(t, NewConfig().SeparateSession())
+	assert.False(t, NewConfig(WithSessionRole(""), WithSessionSettings(map[string]string{})).SeparateSession())
+	assert.True(t, NewConfig(WithSessionRole("backfiller")).SeparateSession())
+	assert.True(t, NewConfig(WithSessionSettings(map[string]string{"statement_timeout": "5min"})).SeparateSession())
+	assert.True(t, NewConfig(WithSessionHook(hook)).SeparateSession())
+}
+
+func TestColumnConcurrency(t *testing.T) {
+	assert.Equal(t, 0, NewConfig().ColumnConcurrency())
+	assert.Equal(t, 0, NewConfig(WithColumnConcurrency(1)).ColumnConcurrency())
+	assert.Equal(t, 0, NewConfig(WithColumnConcurrency(-2)).ColumnConcurrency())
+	assert.Equal(t, 4, NewConfig(WithColumnConcurrency(4)).ColumnConcurrency())
+}
+
+func TestParallelism(t *testing.T) {
+	assert.Equal(t, 0, NewConfig().Parallelism())
+	assert.Equal(t, 0, NewConfig(WithParallelism(1)).Parallelism())
+	assert.Equal(t, 0, NewConfig(WithParallelism(-2)).Parallelism())
+	assert.Equal(t, 8, NewConfig(WithParallelism(8)).Parallelism())
+}
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// TableDryRun is the outcome of a dry run of the backfill of a table
+type TableDryRun struct {
+	// Table is the name of the table
+	Table string
+
+	// Rows is the number of rows that the backfill would update: the rows of
+	// the table that match its filter, if any
+	Rows int64
+
+	// Columns are the columns set by the up SQL of the migration, by name
+	Columns []ColumnDryRun
+}
+
+// ColumnDryRun is the outcome of a dry run of the up SQL of a column
+type ColumnDryRun struct {
+	// Column is the name of the column
+	Column string
+
+	// Changed is the number of rows whose value of the column would be
+	// changed by the up SQL
+	Changed int64
+
+	// Samples are some of the changes the up SQL would make
+	Samples []ValueChange
+}
+
+// ValueChange is the value of a column before and after the up SQL is
+// applied to a row, as text. A nil value is NULL.
+type ValueChange struct {
+	Before *string
+	After  *string
+}
+
+// DryRun counts the rows of each of the job's tables that the backfill would
+// update, and the rows whose values the up SQL of each column would change,
+// without updating any row. For each column, up to `samples` of the changes
+// are returned too.
+//
+// The up SQL is evaluated in a read-only transaction against the columns of
+// the table as they are before the migration starts, and its value compared,
+// as text, with the current value of the column. Columns that the migration
+// adds are NULL in every row. Columns changed by more than one operation of
+// the migration are not included, and the extra statements run by the
+// triggers are not run.
+func (bf *Backfill) DryRun(ctx context.Context, job *Job, samples int) ([]TableDryRun, error) {
+	results := make([]TableDryRun, 0, len(job.Tables))
+	for _, table := range job.Tables {
+		if slices.ContainsFunc(results, func(r TableDryRun) bool { return r.Table == table.Name }) {
+			continue
+		}
+
+		result := TableDryRun{Table: table.Name}
+		err := bf.conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "SET TRANSACTION READ ONLY"); err != nil {
+				return err
+			}
+			var err error
+			result.Rows, result.Columns, err = dryRunTable(ctx, tx, job, bf.naming, table.Name, samples)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("dry run backfill of table %q: %w", table.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// dryRunTable counts the rows of the table that match the job's filter and
+// runs the up SQL of each of the table's columns against them. The objects of
+// the migration are named with the naming.
+func dryRunTable(ctx context.Context, tx *sql.Tx, job *Job, naming Naming, tableName string, samples int) (int64, []ColumnDryRun, error) {
+	filter := job.Filter(tableName)
+
+	var rows int64
+	//nolint:gosec // the table name is quoted and the filter is the migration's
+	query := fmt.Sprintf("SELECT count(*) FROM %s%s", pq.QuoteIdentifier(tableName), whereSQL(filter))
+	if err := tx.QueryRowContext(ctx, query).Scan(&rows); err != nil {
+		return 0, nil, err
+	}
+
+	existing, err := getColumnNames(ctx, tx, tableName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	columns := make([]ColumnDryRun, 0)
+	for _, name := range slices.Sorted(maps.Keys(job.triggers)) {
+		trigger := job.triggers[name]
+		trigger.Naming = naming
+		if trigger.TableName != tableName || trigger.Direction != TriggerDirectionUp || len(trigger.SQL) != 1 {
+			continue
+		}
+
+		column := ColumnDryRun{Column: triggerColumn(trigger)}
+		if err := tx.QueryRowContext(ctx, changedRowsSQL(trigger, existing, filter)).Scan(&column.Changed); err != nil {
+			return 0, nil, fmt.Errorf("up SQL for column %q: %w", column.Column, err)
+		}
+		if samples > 0 && column.Changed > 0 {
+			column.Samples, err = sampleChanges(ctx, tx, sampleChangesSQL(trigger, existing, filter, samples))
+			if err != nil {
+				return 0, nil, fmt.Errorf("up SQL for column %q: %w", column.Column, err)
+			}
+		}
+		columns = append(columns, column)
+	}
+
+	slices.SortFunc(columns, func(a, b ColumnDryRun) int { return strings.Compare(a.Column, b.Column) })
+	return rows, columns, nil
+}
+
+// triggerColumn returns the name of the column that an up trigger sets. Up
+// triggers are named after the table and the column they set.
+func triggerColumn(trigger triggerConfig) string {
+	for name := range trigger.Columns {
+		if trigger.Naming.TriggerName(trigger.TableName, name) == trigger.Name {
+			return name
+		}
+	}
+	return findColumnName(trigger.Columns, trigger.PhysicalColumn)
+}
+
+// dryRunSubquery returns a query that selects the rows of the trigger's table
+// that match the filter, with the columns that the up SQL can refer to under
+// their names. Columns that don't exist yet are NULL. The current value of the
+// column that the trigger sets is selected under the original value alias of
+// the trigger's naming.
+func dryRunSubquery(trigger triggerConfig, existing map[string]bool, filter string) string {
+	column := triggerColumn(trigger)
+
+	columns := make([]string, 0, len(trigger.Columns)+1)
+	for _, name := range slices.Sorted(maps.Keys(trigger.Columns)) {
+		columns = append(columns, fmt.Sprintf("%s AS %s",
+			dryRunValue(trigger.Columns[name].Name, trigger.Columns[name].Type, existing),
+			pq.QuoteIdentifier(name)))
+	}
+	original := "NULL"
+	if c, ok := trigger.Columns[column]; ok {
+		original = dryRunValue(c.Name, c.Type, existing)
+	}
+	columns = append(columns, fmt.Sprintf("%s AS %s", original, pq.QuoteIdentifier(trigger.Naming.originalValueColumn())))
+
+	return fmt.Sprintf("SELECT %s FROM %s%s",
+		strings.Join(columns, ", "),
+		pq.QuoteIdentifier(trigger.TableName),
+		whereSQL(filter))
+}
+
+// dryRunValue returns the physical column if it exists, or a NULL of the
+// column's type if it doesn't.
+func dryRunValue(physical, columnType string, existing map[string]bool) string {
+	switch {
+	case existing[physical]:
+		return pq.QuoteIdentifier(physical)
+	case columnType != "":
+		return "NULL::" + columnType
+	default:
+		return "NULL"
+	}
+}
+
+// changedRowsSQL returns a query that counts the rows for which the trigger's
+// up SQL gives a value other than the current value of the column.
+func changedRowsSQL(trigger triggerConfig, existing map[string]bool, filter string) string {
+	return fmt.Sprintf(`SELECT count(*)
+FROM (
+  %s
+) AS t
+WHERE %s`,
+		dryRunSubquery(trigger, existing, filter),
+		changedSQL(trigger))
+}
+
+// sampleChangesSQL returns a query that selects up to `limit` of the changes
+// made by the trigger's up SQL, as text.
+func sampleChangesSQL(trigger triggerConfig, existing map[string]bool, filter string, limit int) string {
+	return fmt.Sprintf(`SELECT CAST(%s AS text), CAST((%s) AS text)
+FROM (
+  %s
+) AS t
+WHERE %s
+LIMIT %d`,
+		pq.QuoteIdentifier(trigger.Naming.originalValueColumn()),
+		trigger.SQL[0],
+		dryRunSubquery(trigger, existing, filter),
+		changedSQL(trigger),
+		limit)
+}
+
+// changedSQL returns the condition under which the up SQL changes the value of
+// the column. The values are compared as text, as the up SQL may convert the
+// column to another type.
+func changedSQL(trigger triggerConfig) string {
+	return fmt.Sprintf("CAST((%s) AS text) IS DISTINCT FROM CAST(%s AS text)",
+		trigger.SQL[0],
+		pq.QuoteIdentifier(trigger.Naming.originalValueColumn()))
+}
+
+// whereSQL returns a WHERE clause for the filter, or an empty string if there
+// is no filter.
+func whereSQL(filter string) string {
+	if filter == "" {
+		return ""
+	}
+	return fmt.Sprintf(" WHERE (%s)", filter)
+}
+
+// sampleChanges runs the query returned by sampleChangesSQL.
+func sampleChanges(ctx context.Context, tx *sql.Tx, query string) ([]ValueChange, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]ValueChange, 0)
+	for rows.Next() {
+		var before, after sql.NullString
+		if err := rows.Scan(&before, &after); err != nil {
+			return nil, err
+		}
+		changes = append(changes, ValueChange{Before: nullableString(before), After: nullableString(after)})
+	}
+	return changes, rows.Err()
+}
+
+func nullableString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// getColumnNames returns the names of the columns of the table.
+func getColumnNames(ctx context.Context, tx *sql.Tx, tableName string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `
+	  SELECT attname
+	  FROM pg_attribute
+	  WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped`,
+		pq.QuoteIdentifier(tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
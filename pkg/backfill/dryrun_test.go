@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+func TestChangedRowsSQL(t *testing.T) {
+	t.Run("column that the migration changes", func(t *testing.T) {
+		trigger := triggerConfig{
+			Name:      TriggerName("reviews", "rating"),
+			Direction: TriggerDirectionUp,
+			Columns: map[string]*schema.Column{
+				"id":     {Name: "id", Type: "integer"},
+				"rating": {Name: "rating", Type: "integer"},
+			},
+			TableName:      "reviews",
+			PhysicalColumn: "_pgroll_new_rating",
+			SQL:            []string{"rating::text"},
+		}
+		existing := map[string]bool{"id": true, "rating": true}
+
+		expected := `SELECT count(*)
+FROM (
+  SELECT "id" AS "id", "rating" AS "rating", "rating" AS "_pgroll_original_value" FROM "reviews" WHERE (rating > 0)
+) AS t
+WHERE CAST((rating::text) AS text) IS DISTINCT FROM CAST("_pgroll_original_value" AS text)`
+
+		assert.Equal(t, expected, changedRowsSQL(trigger, existing, "rating > 0"))
+	})
+
+	t.Run("column that the migration adds", func(t *testing.T) {
+		trigger := triggerConfig{
+			Name:      TriggerName("users", "age_group"),
+			Direction: TriggerDirectionUp,
+			Columns: map[string]*schema.Column{
+				"age":       {Name: "age", Type: "integer"},
+				"age_group": {Name: "_pgroll_new_age_group", Type: "text"},
+			},
+			TableName:      "users",
+			PhysicalColumn: "_pgroll_new_age_group",
+			SQL:            []string{"CASE WHEN age >= 18 THEN 'adult' END"},
+		}
+		existing := map[string]bool{"age": true}
+
+		expected := `SELECT CAST("_pgroll_original_value" AS text), CAST((CASE WHEN age >= 18 THEN 'adult' END) AS text)
+FROM (
+  SELECT "age" AS "age", NULL::text AS "age_group", NULL::text AS "_pgroll_original_value" FROM "users"
+) AS t
+WHERE CAST((CASE WHEN age >= 18 THEN 'adult' END) AS text) IS DISTINCT FROM CAST("_pgroll_original_value" AS text)
+LIMIT 5`
+
+		assert.Equal(t, expected, sampleChangesSQL(trigger, existing, "", 5))
+	})
+}
@@ -1,3 +1,72 @@
 // SPDX-License-Identifier: Apache-2.0
 
 package backfill
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type BatchKeyIndexMissingError struct {
+	Table string
+	Key   string
+}
+
+func (e BatchKeyIndexMissingError) Error() string {
+	return fmt.Sprintf("table %q has no index on (%s) to support the backfill batch key", e.Table, e.Key)
+}
+
+type ReversibilityMismatchError struct {
+	Table      string
+	Column     string
+	Mismatches int64
+	Sampled    int64
+}
+
+func (e ReversibilityMismatchError) Error() string {
+	return fmt.Sprintf("down SQL for column %q on table %q does not reproduce the original value for %d of %d sampled rows",
+		e.Column, e.Table, e.Mismatches, e.Sampled)
+}
+
+type NeedsBackfillColumnConflictError struct {
+	Table  string
+	Column string
+}
+
+func (e NeedsBackfillColumnConflictError) Error() string {
+	return fmt.Sprintf("table %q already has a column %q that can't be used to mark the rows to backfill", e.Table, e.Column)
+}
+
+type TriggerCheckError struct {
+	// Operations are the operations whose SQL the trigger runs, eg. "2
+	// (add_column)"
+	Operations []string
+	Direction  TriggerDirection
+	Table      string
+	Column     string
+	Err        error
+}
+
+func (e TriggerCheckError) Error() string {
+	var operations string
+	if len(e.Operations) == 1 {
+		operations = " of operation " + e.Operations[0]
+	} else if len(e.Operations) > 1 {
+		operations = " of operations " + strings.Join(e.Operations, ", ")
+	}
+
+	msg := e.Err.Error()
+	var pqErr *pq.Error
+	if errors.As(e.Err, &pqErr) && pqErr.Where != "" {
+		msg = fmt.Sprintf("%s (%s)", pqErr.Message, pqErr.Where)
+	}
+
+	return fmt.Sprintf("%s SQL%s for column %q on table %q fails: %s", e.Direction, operations, e.Column, e.Table, msg)
+}
+
+func (e TriggerCheckError) Unwrap() error {
+	return e.Err
+}
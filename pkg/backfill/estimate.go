@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TableEstimate is the planned size of the backfill of a table
+type TableEstimate struct {
+	// Rows is the estimated number of rows to backfill
+	Rows int64
+
+	// BatchSize is the number of rows updated by each batch
+	BatchSize int
+
+	// Batches is the number of batches needed to backfill the rows
+	Batches int64
+
+	// BatchDelay is the delay between two batches
+	BatchDelay time.Duration
+}
+
+// EstimateTable returns the number of rows of the table that a backfill would
+// update, and the number and size of the batches it would update them in.
+// The row count is taken from the table's statistics, so tables backfilled
+// with a filter are estimated as if all of their rows matched it.
+func (bf *Backfill) EstimateTable(ctx context.Context, tableName string) (TableEstimate, error) {
+	rows, err := getRowCount(ctx, bf.conn, tableName)
+	if err != nil {
+		return TableEstimate{}, fmt.Errorf("get row count for %q: %w", tableName, err)
+	}
+
+	batchSize, err := bf.tableBatchSize(ctx, tableName)
+	if err != nil {
+		return TableEstimate{}, fmt.Errorf("get batch size for %q: %w", tableName, err)
+	}
+
+	return TableEstimate{
+		Rows:       rows,
+		BatchSize:  batchSize,
+		Batches:    batchCount(rows, batchSize),
+		BatchDelay: bf.batchDelay,
+	}, nil
+}
+
+// batchCount returns the number of batches of batchSize rows needed to update
+// the given number of rows
+func batchCount(rows int64, batchSize int) int64 {
+	if rows <= 0 || batchSize <= 0 {
+		return 0
+	}
+	return (rows + int64(batchSize) - 1) / int64(batchSize)
+}
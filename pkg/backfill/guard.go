@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// WriteGuardName returns the name of the function and statement-level trigger
+// that reject updates and deletes on a table that was backfilled without
+// triggers, with the default naming.
+func WriteGuardName(tableName string) string {
+	return Naming{}.WriteGuardName(tableName)
+}
+
+// IsWriteGuardName returns true if the name is that of the write guard
+// function or trigger of the table.
+func (n Naming) IsWriteGuardName(tableName, name string) bool {
+	return name == n.WriteGuardName(tableName)
+}
+
+// WriteGuardSQL returns the statements that make the named row triggers of
+// the table fire on INSERT only, so that new rows still get the values of the
+// columns that the migration adds or changes, and add a statement-level
+// trigger that rejects any UPDATE, DELETE or TRUNCATE of the table. The guard
+// is enabled ALWAYS so that it also fires for sessions that replicate changes,
+// which skip ordinary triggers. It is named with the naming.
+func (n Naming) WriteGuardSQL(tableName string, triggers []string) []string {
+	stmts := make([]string, 0, len(triggers)+3)
+	for _, trigger := range triggers {
+		stmts = append(stmts, fmt.Sprintf(`CREATE OR REPLACE TRIGGER %[1]s
+    BEFORE INSERT
+    ON %[2]s
+    FOR EACH ROW
+    EXECUTE PROCEDURE %[1]s()`, pq.QuoteIdentifier(trigger), pq.QuoteIdentifier(tableName)))
+	}
+
+	name := n.WriteGuardName(tableName)
+	stmts = append(stmts,
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS $$
+    BEGIN
+      RAISE EXCEPTION 'table %% was backfilled without triggers and its rows can''t be updated or deleted until the migration is completed or rolled back', TG_TABLE_NAME
+        USING ERRCODE = 'object_not_in_prerequisite_state';
+    END; $$`, pq.QuoteIdentifier(name)),
+		fmt.Sprintf(`CREATE OR REPLACE TRIGGER %[1]s
+    BEFORE UPDATE OR DELETE OR TRUNCATE
+    ON %[2]s
+    FOR EACH STATEMENT
+    EXECUTE PROCEDURE %[1]s()`, pq.QuoteIdentifier(name), pq.QuoteIdentifier(tableName)),
+		fmt.Sprintf("ALTER TABLE %s ENABLE ALWAYS TRIGGER %s", pq.QuoteIdentifier(tableName), pq.QuoteIdentifier(name)),
+	)
+	return stmts
+}
+
+// GuardTable limits the triggers created for the table by CreateTriggers to
+// inserts and adds a write guard that rejects updates and deletes, if the
+// table is configured to be backfilled without triggers. It is called once the
+// table has been backfilled.
+func (bf *Backfill) GuardTable(ctx context.Context, tableName string) error {
+	if !bf.Triggerless(tableName) {
+		return nil
+	}
+
+	return bf.conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		for _, stmt := range bf.naming.WriteGuardSQL(tableName, bf.triggers[tableName]) {
+			if _, err := bf.conn.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
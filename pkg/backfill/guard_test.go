@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteGuardSQL(t *testing.T) {
+	stmts := Naming{}.WriteGuardSQL("events", []string{"_pgroll_trigger_events_payload", "_pgroll_trigger_events__pgroll_new_payload"})
+
+	assert.Equal(t, []string{
+		`CREATE OR REPLACE TRIGGER "_pgroll_trigger_events_payload"
+    BEFORE INSERT
+    ON "events"
+    FOR EACH ROW
+    EXECUTE PROCEDURE "_pgroll_trigger_events_payload"()`,
+		`CREATE OR REPLACE TRIGGER "_pgroll_trigger_events__pgroll_new_payload"
+    BEFORE INSERT
+    ON "events"
+    FOR EACH ROW
+    EXECUTE PROCEDURE "_pgroll_trigger_events__pgroll_new_payload"()`,
+		`CREATE OR REPLACE FUNCTION "_pgroll_trigger_events__pgroll_write_guard"()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS $$
+    BEGIN
+      RAISE EXCEPTION 'table % was backfilled without triggers and its rows can''t be updated or deleted until the migration is completed or rolled back', TG_TABLE_NAME
+        USING ERRCODE = 'object_not_in_prerequisite_state';
+    END; $$`,
+		`CREATE OR REPLACE TRIGGER "_pgroll_trigger_events__pgroll_write_guard"
+    BEFORE UPDATE OR DELETE OR TRUNCATE
+    ON "events"
+    FOR EACH STATEMENT
+    EXECUTE PROCEDURE "_pgroll_trigger_events__pgroll_write_guard"()`,
+		`ALTER TABLE "events" ENABLE ALWAYS TRIGGER "_pgroll_trigger_events__pgroll_write_guard"`,
+	}, stmts)
+}
+
+func TestIsWriteGuardName(t *testing.T) {
+	assert.True(t, Naming{}.IsWriteGuardName("events", WriteGuardName("events")))
+	assert.False(t, Naming{}.IsWriteGuardName("events", TriggerFunctionName("events", "payload")))
+	assert.False(t, Naming{}.IsWriteGuardName("events", TriggerFunctionName("events", "_pgroll_new_payload")))
+	assert.False(t, Naming{}.IsWriteGuardName("orders", WriteGuardName("events")))
+
+	long := strings.Repeat("t", 60)
+	assert.True(t, Naming{}.IsWriteGuardName(long, WriteGuardName(long)))
+	assert.LessOrEqual(t, len(WriteGuardName(long)), MaxIdentifierLength)
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// IsolationLevel is the transaction isolation level of the batches of a
+// backfill.
+type IsolationLevel string
+
+const (
+	IsolationLevelReadCommitted  IsolationLevel = "READ COMMITTED"
+	IsolationLevelRepeatableRead IsolationLevel = "REPEATABLE READ"
+	IsolationLevelSerializable   IsolationLevel = "SERIALIZABLE"
+)
+
+// IsolationLevels are the isolation levels that batches can be run at.
+var IsolationLevels = []IsolationLevel{
+	IsolationLevelReadCommitted,
+	IsolationLevelRepeatableRead,
+	IsolationLevelSerializable,
+}
+
+// maxSerializationRetries is the number of times in a row that a batch is
+// retried after failing to serialize with a concurrent transaction.
+const maxSerializationRetries = 10
+
+// serializationFailureErrorCode is the error code of the errors raised when a
+// transaction at REPEATABLE READ or SERIALIZABLE conflicts with a concurrent
+// transaction.
+const serializationFailureErrorCode pq.ErrorCode = "40001"
+
+// setIsolationLevel sets the isolation level of the transaction, unless the
+// level is empty, in which case the transaction keeps the session's default
+// level. It must be called before any other statement of the transaction.
+func setIsolationLevel(ctx context.Context, tx *sql.Tx, level IsolationLevel) error {
+	if level == "" {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("SET TRANSACTION ISOLATION LEVEL %s", level))
+	return err
+}
+
+// isSerializationFailure returns true if the error is raised by a transaction
+// that conflicted with a concurrent transaction. Such a transaction can be
+// retried.
+func isSerializationFailure(err error) bool {
+	pqErr := &pq.Error{}
+	return errors.As(err, &pqErr) && pqErr.Code == serializationFailureErrorCode
+}
@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSerializationFailure(t *testing.T) {
+	assert.True(t, isSerializationFailure(&pq.Error{Code: "40001"}))
+	assert.True(t, isSerializationFailure(fmt.Errorf("update batch: %w", &pq.Error{Code: "40001"})))
+	assert.False(t, isSerializationFailure(&pq.Error{Code: "55P03"}))
+	assert.False(t, isSerializationFailure(errors.New("could not serialize access")))
+	assert.False(t, isSerializationFailure(nil))
+}
@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultPrefix is the default prefix of the names of the columns, triggers
+// and other objects that pgroll creates for its own use.
+const DefaultPrefix = "_pgroll_"
+
+// MaxIdentifierLength is the maximum length, in bytes, of a Postgres
+// identifier. Longer identifiers are silently truncated by Postgres.
+const MaxIdentifierLength = 63
+
+// identifierHashLength is the number of hex characters of the hash appended to
+// identifiers that are shortened to fit in MaxIdentifierLength.
+const identifierHashLength = 8
+
+// The names of the objects pgroll creates for its own use are formed from the
+// prefix of the naming followed by one of these infixes.
+const (
+	temporaryInfix         = "new_"
+	deletedInfix           = "del_"
+	truncatedInfix         = "trunc_"
+	duplicationInfix       = "dup_"
+	uniqueIndexInfix       = "uniq_"
+	notNullConstraintInfix = "check_not_null_"
+	triggerInfix           = "trigger_"
+	writeGuardInfix        = "write_guard"
+	needsBackfillInfix     = "needs_backfill"
+)
+
+// Naming names the columns, triggers and other objects that pgroll creates for
+// its own use. The zero value names them with DefaultPrefix.
+//
+// pgroll finds the objects it created by their names, so the objects of a
+// migration must be named with the same Naming from the time the migration is
+// started until it is completed or rolled back.
+type Naming struct {
+	prefix string
+
+	// truncated names that exceed MaxIdentifierLength are truncated, as
+	// Postgres truncates them, rather than shortened with a hash
+	truncated bool
+}
+
+// NewNaming returns a Naming that names objects with the given prefix. An
+// empty prefix names them with DefaultPrefix.
+func NewNaming(prefix string) Naming {
+	return Naming{prefix: prefix}
+}
+
+// LegacyNaming returns the Naming of the objects created by migrations that
+// were started by versions of pgroll that didn't record the naming of each
+// migration. These versions named objects with DefaultPrefix and left
+// Postgres to truncate the names that exceed MaxIdentifierLength.
+func LegacyNaming() Naming {
+	return Naming{truncated: true}
+}
+
+// Prefix returns the prefix of the names of the objects.
+func (n Naming) Prefix() string {
+	if n.prefix == "" {
+		return DefaultPrefix
+	}
+	return n.prefix
+}
+
+// IsLegacy returns true if the naming is LegacyNaming.
+func (n Naming) IsLegacy() bool {
+	return n.truncated
+}
+
+// IsInternalName returns true if the name has the prefix of the names of the
+// objects that pgroll creates for its own use.
+func (n Naming) IsInternalName(name string) bool {
+	return strings.HasPrefix(name, n.Prefix())
+}
+
+// Identifier returns name if it fits in a Postgres identifier, or the name
+// shortened with Identifier otherwise. Legacy namings truncate the name
+// instead, as Postgres does.
+func (n Naming) Identifier(name string) string {
+	if n.truncated {
+		return truncateIdentifier(name)
+	}
+	return Identifier(name)
+}
+
+// TemporaryPrefix returns the prefix of temporary names.
+func (n Naming) TemporaryPrefix() string {
+	return n.Prefix() + temporaryInfix
+}
+
+// TemporaryName returns a temporary name for a given name.
+func (n Naming) TemporaryName(name string) string {
+	return n.Identifier(n.TemporaryPrefix() + name)
+}
+
+// DeletionName returns the deleted name for a given name.
+func (n Naming) DeletionName(name string) string {
+	return n.Identifier(n.Prefix() + deletedInfix + name)
+}
+
+// TruncatedName returns the name of the table that holds a copy of the rows of
+// a truncated table.
+func (n Naming) TruncatedName(name string) string {
+	return n.Identifier(n.Prefix() + truncatedInfix + name)
+}
+
+// DuplicationName returns the name of a duplicated column, constraint or
+// index. Unlike the other names pgroll generates, duplication names are not
+// shortened to fit in an identifier, as the original name is recovered from
+// them with StripDuplicationPrefix.
+func (n Naming) DuplicationName(name string) string {
+	return n.Prefix() + duplicationInfix + name
+}
+
+// IsDuplicatedName returns true if the name is a duplicated column name.
+func (n Naming) IsDuplicatedName(name string) bool {
+	return strings.HasPrefix(name, n.Prefix()+duplicationInfix)
+}
+
+// StripDuplicationPrefix removes the duplication prefix from a column name.
+func (n Naming) StripDuplicationPrefix(name string) string {
+	return strings.TrimPrefix(name, n.Prefix()+duplicationInfix)
+}
+
+// UniqueIndexName returns the name of the unique index for the given column.
+func (n Naming) UniqueIndexName(columnName string) string {
+	return n.Identifier(n.Prefix() + uniqueIndexInfix + columnName)
+}
+
+// NotNullConstraintName returns the name of the NOT NULL constraint for the
+// given column.
+func (n Naming) NotNullConstraintName(columnName string) string {
+	return n.Identifier(n.Prefix() + notNullConstraintInfix + columnName)
+}
+
+// IsNotNullConstraintName returns true if the given name is a NOT NULL
+// constraint name.
+func (n Naming) IsNotNullConstraintName(name string) bool {
+	return strings.HasPrefix(name, n.Prefix()+notNullConstraintInfix)
+}
+
+// StripNotNullConstraintPrefix returns the name of the column that the given
+// NOT NULL constraint name was created for. Names that were shortened to fit
+// in an identifier only give the beginning of the column name.
+func (n Naming) StripNotNullConstraintPrefix(name string) string {
+	return strings.TrimPrefix(name, n.Prefix()+notNullConstraintInfix)
+}
+
+// TriggerFunctionPrefix returns the prefix of the names of all pgroll trigger
+// functions and triggers.
+func (n Naming) TriggerFunctionPrefix() string {
+	return n.Prefix() + triggerInfix
+}
+
+// TriggerFunctionName returns the name of the trigger function for a given
+// table and column.
+func (n Naming) TriggerFunctionName(tableName, columnName string) string {
+	return n.Identifier(n.TriggerFunctionPrefix() + tableName + "_" + columnName)
+}
+
+// TriggerName returns the name of the trigger for a given table and column.
+func (n Naming) TriggerName(tableName, columnName string) string {
+	return n.TriggerFunctionName(tableName, columnName)
+}
+
+// WriteGuardName returns the name of the function and statement-level trigger
+// that reject updates and deletes on a table that was backfilled without
+// triggers.
+func (n Naming) WriteGuardName(tableName string) string {
+	return n.TriggerFunctionName(tableName, n.Prefix()+writeGuardInfix)
+}
+
+// NeedsBackfillColumn returns the default name of the column that marks the
+// rows that must be backfilled.
+func (n Naming) NeedsBackfillColumn() string {
+	return n.Prefix() + needsBackfillInfix
+}
+
+// Identifier returns name if it fits in a Postgres identifier. Longer names
+// are truncated and suffixed with a hash of the full name, so that the result
+// is at most MaxIdentifierLength bytes long, is the same each time it is
+// generated from the same name and differs between names that share a long
+// common beginning, such as the names generated for the columns of a table.
+func Identifier(name string) string {
+	if len(name) <= MaxIdentifierLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:identifierHashLength]
+
+	return clipIdentifier(name, MaxIdentifierLength-identifierHashLength-1) + "_" + hash
+}
+
+// truncateIdentifier returns name truncated to MaxIdentifierLength bytes, as
+// Postgres truncates identifiers.
+func truncateIdentifier(name string) string {
+	if len(name) <= MaxIdentifierLength {
+		return name
+	}
+	return clipIdentifier(name, MaxIdentifierLength)
+}
+
+// clipIdentifier returns the longest beginning of name that is at most length
+// bytes long and ends on a character boundary, to keep the identifier valid
+// UTF-8.
+func clipIdentifier(name string, length int) string {
+	for length > 0 && !utf8.RuneStart(name[length]) {
+		length--
+	}
+	return name[:length]
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentifier(t *testing.T) {
+	t.Run("names that fit are unchanged", func(t *testing.T) {
+		name := "_pgroll_new_" + strings.Repeat("a", MaxIdentifierLength-len("_pgroll_new_"))
+
+		assert.Equal(t, "_pgroll_new_name", Identifier("_pgroll_new_name"))
+		assert.Equal(t, name, Identifier(name))
+	})
+
+	t.Run("long names are shortened deterministically", func(t *testing.T) {
+		name := "_pgroll_new_" + strings.Repeat("a", 100)
+
+		got := Identifier(name)
+		assert.Len(t, got, MaxIdentifierLength)
+		assert.True(t, strings.HasPrefix(got, "_pgroll_new_aaaa"))
+		assert.Equal(t, got, Identifier(name))
+	})
+
+	t.Run("long names with a common beginning stay unique", func(t *testing.T) {
+		column := strings.Repeat("a", 70)
+
+		a := Identifier("_pgroll_new_" + column + "_first")
+		b := Identifier("_pgroll_new_" + column + "_second")
+		assert.NotEqual(t, a, b)
+		assert.Equal(t, a[:54], b[:54])
+	})
+
+	t.Run("long names are shortened on a character boundary", func(t *testing.T) {
+		got := Identifier("_pgroll_new_" + strings.Repeat("é", 40))
+
+		assert.LessOrEqual(t, len(got), MaxIdentifierLength)
+		assert.True(t, utf8.ValidString(got))
+	})
+}
+
+func TestNaming(t *testing.T) {
+	t.Run("the zero value uses the default prefix", func(t *testing.T) {
+		var n Naming
+
+		assert.Equal(t, DefaultPrefix, n.Prefix())
+		assert.Equal(t, CNeedsBackfillColumn, n.NeedsBackfillColumn())
+		assert.Equal(t, "_pgroll_trigger_users_name", n.TriggerFunctionName("users", "name"))
+		assert.Equal(t, NewNaming("").TemporaryName("name"), n.TemporaryName("name"))
+	})
+
+	t.Run("names use the prefix of the naming", func(t *testing.T) {
+		n := NewNaming("_acme_")
+
+		assert.Equal(t, "_acme_needs_backfill", n.NeedsBackfillColumn())
+		assert.Equal(t, "_acme_needs_backfill", NewConfig(WithNaming(n)).NeedsBackfillColumn())
+		assert.Equal(t, "_acme_trigger_users_name", n.TriggerFunctionName("users", "name"))
+		assert.Equal(t, "_acme_trigger_users__acme_write_guard", n.WriteGuardName("users"))
+		assert.Equal(t, "_acme_new_name", n.TemporaryName("name"))
+		assert.Equal(t, "_acme_dup_name", n.DuplicationName("name"))
+		assert.Equal(t, "name", n.StripDuplicationPrefix(n.DuplicationName("name")))
+		assert.True(t, n.IsInternalName("_acme_new_name"))
+		assert.False(t, n.IsInternalName("_pgroll_new_name"))
+
+		// the default naming is left as it is
+		assert.Equal(t, "_pgroll_trigger_users_name", TriggerFunctionName("users", "name"))
+	})
+
+	t.Run("legacy names are truncated as Postgres truncates them", func(t *testing.T) {
+		column := strings.Repeat("c", MaxIdentifierLength)
+		n := LegacyNaming()
+
+		assert.True(t, n.IsLegacy())
+		assert.Equal(t, DefaultPrefix, n.Prefix())
+		assert.Equal(t, "_pgroll_new_name", n.TemporaryName("name"))
+		assert.Equal(t, ("_pgroll_new_" + column)[:MaxIdentifierLength], n.TemporaryName(column))
+		assert.Equal(t, ("_pgroll_trigger_users_" + column)[:MaxIdentifierLength], n.TriggerFunctionName("users", column))
+		assert.NotEqual(t, Naming{}.TemporaryName(column), n.TemporaryName(column))
+	})
+
+	t.Run("legacy names are truncated on a character boundary", func(t *testing.T) {
+		got := LegacyNaming().TemporaryName(strings.Repeat("é", 40))
+
+		assert.LessOrEqual(t, len(got), MaxIdentifierLength)
+		assert.True(t, utf8.ValidString(got))
+	})
+}
+
+func TestTriggerFunctionNameLongColumn(t *testing.T) {
+	column := strings.Repeat("c", MaxIdentifierLength)
+
+	first := TriggerFunctionName("users", column+"_1")
+	second := TriggerFunctionName("users", column+"_2")
+
+	assert.Len(t, first, MaxIdentifierLength)
+	assert.Len(t, second, MaxIdentifierLength)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, first, TriggerFunctionName("users", column+"_1"))
+}
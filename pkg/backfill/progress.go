@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+)
+
+// Progress stores how far the backfill of each table has got, so that a
+// backfill that is interrupted can be resumed from the last batch that was
+// committed rather than from the first row of each table.
+type Progress interface {
+	// Load returns the paging key of the last row backfilled in the table,
+	// and whether the backfill of the table has finished. A nil key means
+	// that the backfill of the table starts from its first row.
+	Load(ctx context.Context, table string) (lastValue []string, done bool, err error)
+
+	// Save stores the paging key of the last row backfilled in the table, and
+	// whether the backfill of the table has finished. `rows` is the number of
+	// rows backfilled since the progress was last saved, and `total` the
+	// number of rows the table is expected to have.
+	Save(ctx context.Context, table string, lastValue []string, rows, total int64, done bool) error
+}
+
+// SetProgress sets where the progress of the backfill of each table is
+// stored. The backfill of a table that the progress shows as finished is
+// skipped, and the backfill of a table that the progress shows as started
+// continues after the last row it backfilled.
+//
+// The progress is only an optimization: rows that have been backfilled have
+// their needs backfill column cleared, so they are never backfilled again
+// even if the batch that backfilled them was committed but not saved.
+func (bf *Backfill) SetProgress(p Progress) {
+	bf.progress = p
+}
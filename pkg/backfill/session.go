@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/db"
+)
+
+// SessionFn sets up the session in which the batches of a backfill run.
+type SessionFn func(ctx context.Context, conn db.DB) error
+
+// SeparateSession returns true if the batches of a backfill run in a session
+// of their own, because a role, settings or hooks are configured for it.
+func (c *Config) SeparateSession() bool {
+	return c.sessionRole != "" || len(c.sessionSettings) > 0 || len(c.sessionHooks) > 0
+}
+
+// SetupSession sets the role and settings configured for the session in which
+// the batches of a backfill run, and calls its hooks. conn must be limited to
+// a single connection, so that the settings apply to every batch.
+func (c *Config) SetupSession(ctx context.Context, conn db.DB) error {
+	if c.sessionRole != "" {
+		if _, err := conn.ExecContext(ctx, "SET ROLE "+pq.QuoteIdentifier(c.sessionRole)); err != nil {
+			return fmt.Errorf("unable to set backfill role to %q: %w", c.sessionRole, err)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.sessionSettings)) {
+		_, err := conn.ExecContext(ctx, "SELECT set_config($1, $2, false)", name, c.sessionSettings[name])
+		if err != nil {
+			return fmt.Errorf("unable to set backfill setting %q: %w", name, err)
+		}
+	}
+
+	for _, fn := range c.sessionHooks {
+		if err := fn(ctx, conn); err != nil {
+			return fmt.Errorf("unable to set up backfill session: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package templates
+
+const Bounds = `SELECT DISTINCT ON ({{ rangeAlias .BatchConfig | qi }}) {{ commaSeparate (quoteIdentifiers .PrimaryKey) }}
+FROM
+(
+  SELECT {{ commaSeparate (quoteIdentifiers .PrimaryKey) }}, ntile({{ .Ranges }}) OVER (ORDER BY {{ pagingKey .BatchConfig }}) AS {{ rangeAlias .BatchConfig | qi }}
+  FROM {{ .TableName | qi }}
+  WHERE {{ .NeedsBackfillColumn | qi }} = true
+  {{- if .Filter }}
+  AND ({{ .Filter }})
+  {{- end }}
+  {{- if .LastValue }}
+  AND ({{ pagingKey .BatchConfig }}) > ({{ commaSeparate (quoteLiterals .LastValue) }})
+  {{- end }}
+) ranges
+ORDER BY {{ rangeAlias .BatchConfig | qi }}, {{ descending .PrimaryKey }}
+`
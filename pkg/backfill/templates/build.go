@@ -4,6 +4,7 @@ package templates
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"text/template"
 
@@ -11,18 +12,85 @@ import (
 )
 
 type BatchConfig struct {
-	TableName           string
-	PrimaryKey          []string
-	LastValue           []string
+	TableName  string
+	PrimaryKey []string
+	// BatchKey is an optional list of SQL expressions by which the table is
+	// paged. The primary key is appended to it to break ties.
+	BatchKey  []string
+	LastValue []string
+	// UpperBound is an optional paging key that limits the batch to the rows
+	// up to and including it.
+	UpperBound          []string
 	BatchSize           int
 	NeedsBackfillColumn string
+	// Filter is an optional SQL condition that limits the batch to the rows
+	// that match it.
+	Filter string
+	// Prefix is the prefix of the names under which pgroll selects columns of
+	// its own in the batch. An empty prefix uses defaultPrefix.
+	Prefix string
+}
+
+// defaultPrefix is the prefix of the names of the columns pgroll selects in a
+// batch when the batch is configured without one.
+const defaultPrefix = "_pgroll_"
+
+// internalName returns the name with the prefix of the batch's internal names.
+func (cfg BatchConfig) internalName(name string) string {
+	if cfg.Prefix == "" {
+		return defaultPrefix + name
+	}
+	return cfg.Prefix + name
 }
 
 func BuildSQL(cfg BatchConfig) (string, error) {
 	return executeTemplate("sql", SQL, cfg)
 }
 
-func executeTemplate(name, content string, cfg BatchConfig) (string, error) {
+// markConfig is the configuration of the statement that marks a batch of rows
+// as backfilled.
+type markConfig struct {
+	BatchConfig
+	// UpToValue is the value of the paging key of the last row of the batch.
+	UpToValue []string
+}
+
+// BuildMarkSQL returns a statement that clears the needs backfill column of
+// the rows of the batch that follows cfg.LastValue, up to and including the
+// row with the paging key upTo.
+func BuildMarkSQL(cfg BatchConfig, upTo []string) (string, error) {
+	return executeTemplate("mark", Mark, markConfig{BatchConfig: cfg, UpToValue: upTo})
+}
+
+// boundsConfig is the configuration of the query that splits the rows of a
+// table into ranges.
+type boundsConfig struct {
+	BatchConfig
+	// Ranges is the number of ranges into which the rows are split.
+	Ranges int
+}
+
+// BuildBoundsSQL returns a query that splits the rows of the table that need
+// a backfill and follow cfg.LastValue into the given number of ranges of
+// about the same size, returning the primary key of the last row of each
+// range in order.
+func BuildBoundsSQL(cfg BatchConfig, ranges int) (string, error) {
+	return executeTemplate("bounds", Bounds, boundsConfig{BatchConfig: cfg, Ranges: ranges})
+}
+
+// batchKeyAlias returns the name under which the i-th batch key expression
+// is selected in the batch.
+func batchKeyAlias(cfg BatchConfig, i int) string {
+	return cfg.internalName(fmt.Sprintf("batch_key_%d", i))
+}
+
+// rangeAlias returns the name under which the range of each row is selected
+// when the rows are split into ranges.
+func rangeAlias(cfg BatchConfig) string {
+	return cfg.internalName("range")
+}
+
+func executeTemplate(name, content string, cfg any) (string, error) {
 	ql := pq.QuoteLiteral
 	qi := pq.QuoteIdentifier
 
@@ -33,6 +101,23 @@ func executeTemplate(name, content string, cfg BatchConfig) (string, error) {
 			"commaSeparate": func(slice []string) string {
 				return strings.Join(slice, ", ")
 			},
+			"batchKeyAlias": batchKeyAlias,
+			"rangeAlias":    rangeAlias,
+			"pagingKey": func(cfg BatchConfig) string {
+				key := make([]string, 0, len(cfg.BatchKey)+len(cfg.PrimaryKey))
+				key = append(key, cfg.BatchKey...)
+				for _, c := range cfg.PrimaryKey {
+					key = append(key, qi(c))
+				}
+				return strings.Join(key, ", ")
+			},
+			"pagingColumns": func(cfg BatchConfig) []string {
+				columns := make([]string, 0, len(cfg.BatchKey)+len(cfg.PrimaryKey))
+				for i := range cfg.BatchKey {
+					columns = append(columns, batchKeyAlias(cfg, i))
+				}
+				return append(columns, cfg.PrimaryKey...)
+			},
 			"quoteIdentifiers": func(slice []string) []string {
 				quoted := make([]string, len(slice))
 				for i, s := range slice {
@@ -68,10 +153,10 @@ func executeTemplate(name, content string, cfg BatchConfig) (string, error) {
 				}
 				return strings.Join(quoted, ", ")
 			},
-			"selectLastValue": func(columns []string) string {
+			"descending": func(columns []string) string {
 				quoted := make([]string, len(columns))
 				for i, c := range columns {
-					quoted[i] = "LAST_VALUE(" + qi(c) + ") OVER()"
+					quoted[i] = qi(c) + " DESC"
 				}
 				return strings.Join(quoted, ", ")
 			},
@@ -51,6 +51,60 @@ func TestBatchStatementBuilder(t *testing.T) {
 			},
 			expected: multipleIDColumnsWithLastValue,
 		},
+		"batch key no last value": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				BatchKey:            []string{"tenant_id", "created_at"},
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+				BatchSize:           10,
+			},
+			expected: batchKeyNoLastValue,
+		},
+		"batch key with last value": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				BatchKey:            []string{"tenant_id", "lower(email)"},
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+				LastValue:           []string{"7", "alice@example.com", "1"},
+				BatchSize:           10,
+			},
+			expected: batchKeyWithLastValue,
+		},
+		"batch key with prefix": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				BatchKey:            []string{"tenant_id"},
+				NeedsBackfillColumn: "_acme_needs_backfill",
+				BatchSize:           10,
+				Prefix:              "_acme_",
+			},
+			expected: batchKeyWithPrefix,
+		},
+		"filter with last value": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+				Filter:              "status = 'active'",
+				LastValue:           []string{"1"},
+				BatchSize:           10,
+			},
+			expected: filterWithLastValue,
+		},
+		"last value and upper bound": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+				LastValue:           []string{"1"},
+				UpperBound:          []string{"100"},
+				BatchSize:           10,
+			},
+			expected: lastValueAndUpperBound,
+		},
 	}
 
 	for name, test := range tests {
@@ -63,6 +117,95 @@ func TestBatchStatementBuilder(t *testing.T) {
 	}
 }
 
+func TestMarkStatementBuilder(t *testing.T) {
+	tests := map[string]struct {
+		config   BatchConfig
+		upTo     []string
+		expected string
+	}{
+		"first batch": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+				BatchSize:           10,
+			},
+			upTo:     []string{"10"},
+			expected: markFirstBatch,
+		},
+		"batch key and filter with last value": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				BatchKey:            []string{"tenant_id"},
+				NeedsBackfillColumn: "cdc_needs_backfill",
+				LastValue:           []string{"1", "10"},
+				Filter:              "status = 'active'",
+				BatchSize:           10,
+			},
+			upTo:     []string{"2", "20"},
+			expected: markBatchKeyFilterWithLastValue,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := BuildMarkSQL(test.config, test.upTo)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestBoundsStatementBuilder(t *testing.T) {
+	tests := map[string]struct {
+		config   BatchConfig
+		ranges   int
+		expected string
+	}{
+		"single identity column": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+			},
+			ranges:   4,
+			expected: boundsSingleIDColumn,
+		},
+		"multiple identity columns with filter and last value": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id", "zip"},
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+				Filter:              "status = 'active'",
+				LastValue:           []string{"1", "1234"},
+			},
+			ranges:   8,
+			expected: boundsMultipleIDColumnsWithFilterAndLastValue,
+		},
+		"prefix": {
+			config: BatchConfig{
+				TableName:           "table_name",
+				PrimaryKey:          []string{"id"},
+				NeedsBackfillColumn: "_acme_needs_backfill",
+				Prefix:              "_acme_",
+			},
+			ranges:   4,
+			expected: boundsWithPrefix,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := BuildBoundsSQL(test.config, test.ranges)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
 const expectSingleIDColumnNoLastValue = `WITH batch AS
 (
   SELECT "id"
@@ -80,8 +223,10 @@ update AS
   WHERE "table_name"."id" = batch."id"
   RETURNING "table_name"."id"
 )
-SELECT LAST_VALUE("id") OVER()
+SELECT "id", COUNT(*) OVER()
 FROM update
+ORDER BY "id" DESC
+LIMIT 1
 `
 
 const multipleIDColumnsNoLastValue = `WITH batch AS
@@ -101,8 +246,10 @@ update AS
   WHERE "table_name"."id" = batch."id" AND "table_name"."zip" = batch."zip"
   RETURNING "table_name"."id", "table_name"."zip"
 )
-SELECT LAST_VALUE("id") OVER(), LAST_VALUE("zip") OVER()
+SELECT "id", "zip", COUNT(*) OVER()
 FROM update
+ORDER BY "id" DESC, "zip" DESC
+LIMIT 1
 `
 
 const singleIDColumnWithLastValue = `WITH batch AS
@@ -123,8 +270,10 @@ update AS
   WHERE "table_name"."id" = batch."id"
   RETURNING "table_name"."id"
 )
-SELECT LAST_VALUE("id") OVER()
+SELECT "id", COUNT(*) OVER()
 FROM update
+ORDER BY "id" DESC
+LIMIT 1
 `
 
 const multipleIDColumnsWithLastValue = `WITH batch AS
@@ -145,6 +294,180 @@ update AS
   WHERE "table_name"."id" = batch."id" AND "table_name"."zip" = batch."zip"
   RETURNING "table_name"."id", "table_name"."zip"
 )
-SELECT LAST_VALUE("id") OVER(), LAST_VALUE("zip") OVER()
+SELECT "id", "zip", COUNT(*) OVER()
 FROM update
+ORDER BY "id" DESC, "zip" DESC
+LIMIT 1
+`
+
+const batchKeyNoLastValue = `WITH batch AS
+(
+  SELECT "id", tenant_id AS "_pgroll_batch_key_0", created_at AS "_pgroll_batch_key_1"
+  FROM "table_name"
+  WHERE "_pgroll_needs_backfill" = true
+  AND (tenant_id) IS NOT NULL
+  AND (created_at) IS NOT NULL
+  ORDER BY tenant_id, created_at, "id"
+  LIMIT 10
+  FOR NO KEY UPDATE
+),
+update AS
+(
+  UPDATE "table_name"
+  SET "id" = "table_name"."id"
+  FROM batch
+  WHERE "table_name"."id" = batch."id"
+  RETURNING "table_name"."id", batch."_pgroll_batch_key_0", batch."_pgroll_batch_key_1"
+)
+SELECT "_pgroll_batch_key_0", "_pgroll_batch_key_1", "id", COUNT(*) OVER()
+FROM update
+ORDER BY "_pgroll_batch_key_0" DESC, "_pgroll_batch_key_1" DESC, "id" DESC
+LIMIT 1
+`
+
+const batchKeyWithPrefix = `WITH batch AS
+(
+  SELECT "id", tenant_id AS "_acme_batch_key_0"
+  FROM "table_name"
+  WHERE "_acme_needs_backfill" = true
+  AND (tenant_id) IS NOT NULL
+  ORDER BY tenant_id, "id"
+  LIMIT 10
+  FOR NO KEY UPDATE
+),
+update AS
+(
+  UPDATE "table_name"
+  SET "id" = "table_name"."id"
+  FROM batch
+  WHERE "table_name"."id" = batch."id"
+  RETURNING "table_name"."id", batch."_acme_batch_key_0"
+)
+SELECT "_acme_batch_key_0", "id", COUNT(*) OVER()
+FROM update
+ORDER BY "_acme_batch_key_0" DESC, "id" DESC
+LIMIT 1
+`
+
+const batchKeyWithLastValue = `WITH batch AS
+(
+  SELECT "id", tenant_id AS "_pgroll_batch_key_0", lower(email) AS "_pgroll_batch_key_1"
+  FROM "table_name"
+  WHERE "_pgroll_needs_backfill" = true
+  AND (tenant_id) IS NOT NULL
+  AND (lower(email)) IS NOT NULL
+  AND (tenant_id, lower(email), "id") > ('7', 'alice@example.com', '1')
+  ORDER BY tenant_id, lower(email), "id"
+  LIMIT 10
+  FOR NO KEY UPDATE
+),
+update AS
+(
+  UPDATE "table_name"
+  SET "id" = "table_name"."id"
+  FROM batch
+  WHERE "table_name"."id" = batch."id"
+  RETURNING "table_name"."id", batch."_pgroll_batch_key_0", batch."_pgroll_batch_key_1"
+)
+SELECT "_pgroll_batch_key_0", "_pgroll_batch_key_1", "id", COUNT(*) OVER()
+FROM update
+ORDER BY "_pgroll_batch_key_0" DESC, "_pgroll_batch_key_1" DESC, "id" DESC
+LIMIT 1
+`
+
+const filterWithLastValue = `WITH batch AS
+(
+  SELECT "id"
+  FROM "table_name"
+  WHERE "_pgroll_needs_backfill" = true
+  AND (status = 'active')
+  AND ("id") > ('1')
+  ORDER BY "id"
+  LIMIT 10
+  FOR NO KEY UPDATE
+),
+update AS
+(
+  UPDATE "table_name"
+  SET "id" = "table_name"."id"
+  FROM batch
+  WHERE "table_name"."id" = batch."id"
+  RETURNING "table_name"."id"
+)
+SELECT "id", COUNT(*) OVER()
+FROM update
+ORDER BY "id" DESC
+LIMIT 1
+`
+
+const markFirstBatch = `UPDATE "table_name"
+SET "_pgroll_needs_backfill" = false
+WHERE "_pgroll_needs_backfill" = true
+AND ("id") <= ('10')
+`
+
+const markBatchKeyFilterWithLastValue = `UPDATE "table_name"
+SET "cdc_needs_backfill" = false
+WHERE "cdc_needs_backfill" = true
+AND (status = 'active')
+AND (tenant_id) IS NOT NULL
+AND (tenant_id, "id") > ('1', '10')
+AND (tenant_id, "id") <= ('2', '20')
+`
+
+const lastValueAndUpperBound = `WITH batch AS
+(
+  SELECT "id"
+  FROM "table_name"
+  WHERE "_pgroll_needs_backfill" = true
+  AND ("id") > ('1')
+  AND ("id") <= ('100')
+  ORDER BY "id"
+  LIMIT 10
+  FOR NO KEY UPDATE
+),
+update AS
+(
+  UPDATE "table_name"
+  SET "id" = "table_name"."id"
+  FROM batch
+  WHERE "table_name"."id" = batch."id"
+  RETURNING "table_name"."id"
+)
+SELECT "id", COUNT(*) OVER()
+FROM update
+ORDER BY "id" DESC
+LIMIT 1
+`
+
+const boundsSingleIDColumn = `SELECT DISTINCT ON ("_pgroll_range") "id"
+FROM
+(
+  SELECT "id", ntile(4) OVER (ORDER BY "id") AS "_pgroll_range"
+  FROM "table_name"
+  WHERE "_pgroll_needs_backfill" = true
+) ranges
+ORDER BY "_pgroll_range", "id" DESC
+`
+
+const boundsWithPrefix = `SELECT DISTINCT ON ("_acme_range") "id"
+FROM
+(
+  SELECT "id", ntile(4) OVER (ORDER BY "id") AS "_acme_range"
+  FROM "table_name"
+  WHERE "_acme_needs_backfill" = true
+) ranges
+ORDER BY "_acme_range", "id" DESC
+`
+
+const boundsMultipleIDColumnsWithFilterAndLastValue = `SELECT DISTINCT ON ("_pgroll_range") "id", "zip"
+FROM
+(
+  SELECT "id", "zip", ntile(8) OVER (ORDER BY "id", "zip") AS "_pgroll_range"
+  FROM "table_name"
+  WHERE "_pgroll_needs_backfill" = true
+  AND (status = 'active')
+  AND ("id", "zip") > ('1', '1234')
+) ranges
+ORDER BY "_pgroll_range", "id" DESC, "zip" DESC
 `
@@ -2,10 +2,32 @@
 
 package templates
 
+// DeferMarkSetting is the setting that stops the triggers from clearing the
+// needs backfill column of the rows they update, when it is set to 'on'.
+const DeferMarkSetting = "pgroll.defer_backfill_mark"
+
+// BackfillTriggerSetting is the setting that limits the triggers that set
+// their column for the rows updated by a backfill to the trigger whose name it
+// is set to. It lets each column of a table be backfilled by a pass of its own.
+const BackfillTriggerSetting = "pgroll.backfill_trigger"
+
+// Function is the template of the trigger function that sets a column from
+// its up or down SQL. Writes made through the latest version schema, and
+// through the version schemas of any migrations stacked on top of it, are
+// writes through the new version of the column. The columns of the row are declared as variables, so
+// that the SQL can refer to them by name. Subqueries in the SQL, such as
+// `(SELECT name FROM authors WHERE id = author_id)`, may also refer to the
+// columns of other tables by names that clash with those variables;
+// `#variable_conflict use_column` resolves such names to the columns, as SQL
+// resolves names in a correlated subquery. Any statements supplied by the
+// operations are run after the column has been set, and can read and assign
+// the fields of NEW. Triggers created for column passes only set their column
+// when BackfillTriggerSetting is unset or set to the trigger's name.
 const Function = `CREATE OR REPLACE FUNCTION {{ .Name | qi }}()
     RETURNS TRIGGER
     LANGUAGE PLPGSQL
     AS $$
+    #variable_conflict use_column
     DECLARE
       {{- $schemaName := .SchemaName  }}
       {{- $tableName := .TableName  }}
@@ -18,14 +40,30 @@ const Function = `CREATE OR REPLACE FUNCTION {{ .Name | qi }}()
       SELECT current_setting
         INTO search_path
         FROM current_setting('search_path');
+      {{- if .StackedSchemas }}
+
+      IF search_path {{- if eq .Direction "up" }} NOT IN {{- else }} IN {{- end }} ({{ .LatestSchema | ql }}{{ range .StackedSchemas }}, {{ . | ql }}{{ end }}){{ template "columnPass" . }} THEN
+      {{- else }}
 
-      IF search_path {{- if eq .Direction "up" }} != {{- else }} = {{- end }} {{ .LatestSchema | ql }} THEN
+      IF search_path {{- if eq .Direction "up" }} != {{- else }} = {{- end }} {{ .LatestSchema | ql }}{{ template "columnPass" . }} THEN
+      {{- end }}
       {{- $physicalColumn := .PhysicalColumn | qi  }}{{ range $s := .SQL }}
         NEW.{{ $physicalColumn  }} = {{ $s }};
       {{- end }}
+      {{- range $s := .Statements }}
+        {{ $s }}
+      {{- end }}
+      {{- if .DeferMark }}
+        IF current_setting('` + DeferMarkSetting + `', true) IS DISTINCT FROM 'on' THEN
+          NEW.{{ .NeedsBackfillColumn | qi }} = false;
+        END IF;
+      {{- else }}
         NEW.{{ .NeedsBackfillColumn | qi }} = false;
+      {{- end }}
       END IF;
 
       RETURN NEW;
     END; $$
-`
+{{ define "columnPass" }}
+  {{- if .ColumnPasses }} AND coalesce(current_setting('` + BackfillTriggerSetting + `', true), '') IN ('', {{ .Name | ql }}){{ end }}
+{{- end }}`
@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package templates
+
+const Mark = `UPDATE {{ .TableName | qi }}
+SET {{ .NeedsBackfillColumn | qi }} = false
+WHERE {{ .NeedsBackfillColumn | qi }} = true
+{{ if .Filter -}}
+AND ({{ .Filter }})
+{{ end -}}
+{{ range .BatchKey -}}
+AND ({{ . }}) IS NOT NULL
+{{ end -}}
+{{ if .LastValue -}}
+AND ({{ pagingKey .BatchConfig }}) > ({{ commaSeparate (quoteLiterals .LastValue) }})
+{{ end -}}
+AND ({{ pagingKey .BatchConfig }}) <= ({{ commaSeparate (quoteLiterals .UpToValue) }})
+`
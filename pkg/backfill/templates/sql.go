@@ -4,13 +4,22 @@ package templates
 
 const SQL = `WITH batch AS
 (
-  SELECT {{ commaSeparate (quoteIdentifiers .PrimaryKey) }}
+  SELECT {{ commaSeparate (quoteIdentifiers .PrimaryKey) }}{{ range $i, $key := .BatchKey }}, {{ $key }} AS {{ batchKeyAlias $ $i | qi }}{{ end }}
   FROM {{ .TableName | qi}}
   WHERE {{ .NeedsBackfillColumn | qi }} = true
+  {{ if .Filter -}}
+  AND ({{ .Filter }})
+  {{ end -}}
+  {{ range .BatchKey -}}
+  AND ({{ . }}) IS NOT NULL
+  {{ end -}}
   {{ if .LastValue -}}
-  AND ({{ commaSeparate (quoteIdentifiers .PrimaryKey) }}) > ({{ commaSeparate (quoteLiterals .LastValue) }})
+  AND ({{ pagingKey . }}) > ({{ commaSeparate (quoteLiterals .LastValue) }})
+  {{ end -}}
+  {{ if .UpperBound -}}
+  AND ({{ pagingKey . }}) <= ({{ commaSeparate (quoteLiterals .UpperBound) }})
   {{ end -}}
-  ORDER BY {{ commaSeparate (quoteIdentifiers .PrimaryKey) }}
+  ORDER BY {{ pagingKey . }}
   LIMIT {{ .BatchSize }}
   FOR NO KEY UPDATE
 ),
@@ -20,8 +29,10 @@ update AS
   SET {{ updateSetClause .TableName .PrimaryKey }}
   FROM batch
   WHERE {{ updateWhereClause .TableName .PrimaryKey }}
-  RETURNING {{ updateReturnClause .TableName .PrimaryKey }}
+  RETURNING {{ updateReturnClause .TableName .PrimaryKey }}{{ range $i, $key := .BatchKey }}, batch.{{ batchKeyAlias $ $i | qi }}{{ end }}
 )
-SELECT {{ selectLastValue .PrimaryKey }}
+SELECT {{ commaSeparate (quoteIdentifiers (pagingColumns .)) }}, COUNT(*) OVER()
 FROM update
+ORDER BY {{ descending (pagingColumns .) }}
+LIMIT 1
 `
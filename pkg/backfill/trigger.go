@@ -24,15 +24,35 @@ const (
 )
 
 type triggerConfig struct {
-	Name                string
-	Direction           TriggerDirection
-	Columns             map[string]*schema.Column
-	SchemaName          string
-	TableName           string
-	PhysicalColumn      string
-	LatestSchema        string
-	SQL                 []string
+	Name           string
+	Direction      TriggerDirection
+	Columns        map[string]*schema.Column
+	SchemaName     string
+	TableName      string
+	PhysicalColumn string
+	LatestSchema   string
+	// StackedSchemas are the version schemas of the migrations stacked on
+	// top of the migration that creates the trigger, through which writes are
+	// treated like writes through the latest version schema.
+	StackedSchemas []string
+	SQL            []string
+	// Statements are PL/pgSQL statements supplied by the operations, run
+	// after the column has been set from the SQL.
+	Statements          []string
 	NeedsBackfillColumn string
+	// Naming is the naming of the objects created for the migration
+	Naming Naming
+	// DeferMark leaves the needs backfill column to be cleared by the
+	// backfill rather than the trigger, for the rows that the backfill updates
+	// with a separate statement to mark them as backfilled.
+	DeferMark bool
+	// ColumnPasses makes the trigger set its column only in the backfill pass
+	// for the trigger, when the columns of the table are backfilled by a pass
+	// each.
+	ColumnPasses bool
+	// Operations are the operations whose SQL the trigger runs, as set with
+	// Task.SetOperation
+	Operations []string
 }
 
 type OperationTrigger struct {
@@ -42,6 +62,11 @@ type OperationTrigger struct {
 	TableName      string
 	PhysicalColumn string
 	SQL            string
+	// Statements are PL/pgSQL statements appended to the trigger function,
+	// run after the physical column has been set from the SQL. They must have
+	// been checked to be a valid list of statements, as they are inserted
+	// into the function as they are.
+	Statements string
 }
 
 type createTriggerAction struct {
@@ -50,14 +75,7 @@ type createTriggerAction struct {
 }
 
 func (a *createTriggerAction) execute(ctx context.Context) error {
-	// Parenthesize the up/down SQL if it's not parenthesized already
-	for i, sql := range a.cfg.SQL {
-		if len(sql) > 0 && sql[0] != '(' {
-			a.cfg.SQL[i] = "(" + sql + ")"
-		}
-	}
-
-	a.cfg.NeedsBackfillColumn = CNeedsBackfillColumn
+	parenthesizeSQL(a.cfg.SQL)
 
 	funcSQL, err := buildFunction(a.cfg)
 	if err != nil {
@@ -70,10 +88,23 @@ func (a *createTriggerAction) execute(ctx context.Context) error {
 	}
 
 	return a.conn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		if err := checkNeedsBackfillColumn(ctx, a.conn, a.cfg.TableName, a.cfg.NeedsBackfillColumn, a.cfg.Naming); err != nil {
+			return err
+		}
+
 		_, err := a.conn.ExecContext(ctx,
 			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s boolean DEFAULT true",
 				pq.QuoteIdentifier(a.cfg.TableName),
-				pq.QuoteIdentifier(CNeedsBackfillColumn)))
+				pq.QuoteIdentifier(a.cfg.NeedsBackfillColumn)))
+		if err != nil {
+			return err
+		}
+
+		_, err = a.conn.ExecContext(ctx,
+			fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s",
+				pq.QuoteIdentifier(a.cfg.TableName),
+				pq.QuoteIdentifier(a.cfg.NeedsBackfillColumn),
+				pq.QuoteLiteral(NeedsBackfillColumnComment)))
 		if err != nil {
 			return err
 		}
@@ -88,6 +119,53 @@ func (a *createTriggerAction) execute(ctx context.Context) error {
 	})
 }
 
+// parenthesizeSQL parenthesizes the up/down SQL if it's not parenthesized
+// already.
+func parenthesizeSQL(stmts []string) {
+	for i, sql := range stmts {
+		if len(sql) > 0 && sql[0] != '(' {
+			stmts[i] = "(" + sql + ")"
+		}
+	}
+}
+
+// checkNeedsBackfillColumn returns an error if the table has a column with the
+// name of the needs backfill column that wasn't created by pgroll, as it
+// would be dropped along with the needs backfill column once the migration is
+// completed or rolled back. Columns with the default name of the naming are
+// always taken to have been created by pgroll.
+func checkNeedsBackfillColumn(ctx context.Context, conn db.DB, tableName, columnName string, naming Naming) error {
+	if columnName == naming.NeedsBackfillColumn() {
+		return nil
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+	  SELECT EXISTS (
+	    SELECT 1
+	    FROM pg_attribute
+	    WHERE attrelid = $1::regclass AND attname = $2 AND NOT attisdropped
+	      AND col_description(attrelid, attnum) IS DISTINCT FROM $3
+	  )`,
+		pq.QuoteIdentifier(tableName), columnName, NeedsBackfillColumnComment)
+	if err != nil {
+		return err
+	}
+	if rows == nil {
+		// the statements are being recorded rather than run
+		return nil
+	}
+	defer rows.Close()
+
+	var conflict bool
+	if err := db.ScanFirstValue(rows, &conflict); err != nil {
+		return err
+	}
+	if conflict {
+		return NeedsBackfillColumnConflictError{Table: tableName, Column: columnName}
+	}
+	return nil
+}
+
 func buildFunction(cfg triggerConfig) (string, error) {
 	return executeTemplate("function", templates.Function, cfg)
 }
@@ -113,13 +191,21 @@ func executeTemplate(name, content string, cfg triggerConfig) (string, error) {
 	return buf.String(), nil
 }
 
-// TriggerFunctionName returns the name of the trigger function
-// for a given table and column.
+// TriggerFunctionPrefix returns the prefix of the names of all pgroll trigger
+// functions and triggers, with the default naming.
+func TriggerFunctionPrefix() string {
+	return Naming{}.TriggerFunctionPrefix()
+}
+
+// TriggerFunctionName returns the name of the trigger function for a given
+// table and column, with the default naming. Names that would exceed the
+// maximum length of an identifier are shortened with Identifier.
 func TriggerFunctionName(tableName, columnName string) string {
-	return "_pgroll_trigger_" + tableName + "_" + columnName
+	return Naming{}.TriggerFunctionName(tableName, columnName)
 }
 
-// TriggerName returns the name of the trigger for a given table and column.
+// TriggerName returns the name of the trigger for a given table and column,
+// with the default naming.
 func TriggerName(tableName, columnName string) string {
-	return TriggerFunctionName(tableName, columnName)
+	return Naming{}.TriggerName(tableName, columnName)
 }
@@ -37,6 +37,7 @@ func TestBuildFunction(t *testing.T) {
     RETURNS TRIGGER
     LANGUAGE PLPGSQL
     AS $$
+    #variable_conflict use_column
     DECLARE
       "id" "public"."reviews"."id"%TYPE := NEW."id";
       "product" "public"."reviews"."product"%TYPE := NEW."product";
@@ -83,6 +84,7 @@ func TestBuildFunction(t *testing.T) {
     RETURNS TRIGGER
     LANGUAGE PLPGSQL
     AS $$
+    #variable_conflict use_column
     DECLARE
       "id" "public"."reviews"."id"%TYPE := NEW."id";
       "product" "public"."reviews"."product"%TYPE := NEW."product";
@@ -127,6 +129,7 @@ func TestBuildFunction(t *testing.T) {
     RETURNS TRIGGER
     LANGUAGE PLPGSQL
     AS $$
+    #variable_conflict use_column
     DECLARE
       "id" "public"."reviews"."id"%TYPE := NEW."id";
       "product" "public"."reviews"."product"%TYPE := NEW."product";
@@ -171,6 +174,7 @@ func TestBuildFunction(t *testing.T) {
     RETURNS TRIGGER
     LANGUAGE PLPGSQL
     AS $$
+    #variable_conflict use_column
     DECLARE
       "id" "public"."reviews"."id"%TYPE := NEW."id";
       "product" "public"."reviews"."product"%TYPE := NEW."product";
@@ -189,6 +193,219 @@ func TestBuildFunction(t *testing.T) {
         NEW."_pgroll_needs_backfill" = false;
       END IF;
 
+      RETURN NEW;
+    END; $$
+`,
+		},
+		{
+			name: "up trigger for column passes",
+			config: triggerConfig{
+				Name:      "triggerName",
+				Direction: TriggerDirectionUp,
+				Columns: map[string]*schema.Column{
+					"id":       {Name: "id", Type: "int"},
+					"username": {Name: "username", Type: "text"},
+				},
+				SchemaName:          "public",
+				LatestSchema:        "public_01_migration_name",
+				TableName:           "users",
+				PhysicalColumn:      "_pgroll_new_username",
+				NeedsBackfillColumn: "_pgroll_needs_backfill",
+				DeferMark:           true,
+				ColumnPasses:        true,
+				SQL:                 []string{"upper(username)"},
+			},
+			expected: `CREATE OR REPLACE FUNCTION "triggerName"()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS $$
+    #variable_conflict use_column
+    DECLARE
+      "id" "public"."users"."id"%TYPE := NEW."id";
+      "username" "public"."users"."username"%TYPE := NEW."username";
+      latest_schema text;
+      search_path text;
+    BEGIN
+      SELECT current_setting
+        INTO search_path
+        FROM current_setting('search_path');
+
+      IF search_path != 'public_01_migration_name' AND coalesce(current_setting('pgroll.backfill_trigger', true), '') IN ('', 'triggerName') THEN
+        NEW."_pgroll_new_username" = upper(username);
+        IF current_setting('pgroll.defer_backfill_mark', true) IS DISTINCT FROM 'on' THEN
+          NEW."_pgroll_needs_backfill" = false;
+        END IF;
+      END IF;
+
+      RETURN NEW;
+    END; $$
+`,
+		},
+		{
+			name: "up trigger with deferred mark",
+			config: triggerConfig{
+				Name:      "triggerName",
+				Direction: TriggerDirectionUp,
+				Columns: map[string]*schema.Column{
+					"id":       {Name: "id", Type: "int"},
+					"username": {Name: "username", Type: "text"},
+				},
+				SchemaName:          "public",
+				LatestSchema:        "public_01_migration_name",
+				TableName:           "users",
+				PhysicalColumn:      "_pgroll_new_username",
+				NeedsBackfillColumn: "cdc_needs_backfill",
+				DeferMark:           true,
+				SQL:                 []string{"upper(username)"},
+			},
+			expected: `CREATE OR REPLACE FUNCTION "triggerName"()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS $$
+    #variable_conflict use_column
+    DECLARE
+      "id" "public"."users"."id"%TYPE := NEW."id";
+      "username" "public"."users"."username"%TYPE := NEW."username";
+      latest_schema text;
+      search_path text;
+    BEGIN
+      SELECT current_setting
+        INTO search_path
+        FROM current_setting('search_path');
+
+      IF search_path != 'public_01_migration_name' THEN
+        NEW."_pgroll_new_username" = upper(username);
+        IF current_setting('pgroll.defer_backfill_mark', true) IS DISTINCT FROM 'on' THEN
+          NEW."cdc_needs_backfill" = false;
+        END IF;
+      END IF;
+
+      RETURN NEW;
+    END; $$
+`,
+		},
+		{
+			name: "up trigger with statements",
+			config: triggerConfig{
+				Name:      "triggerName",
+				Direction: TriggerDirectionUp,
+				Columns: map[string]*schema.Column{
+					"id":      {Name: "id", Type: "int"},
+					"team_id": {Name: "team_id", Type: "int"},
+				},
+				SchemaName:          "public",
+				LatestSchema:        "public_01_migration_name",
+				TableName:           "users",
+				PhysicalColumn:      "_pgroll_new_team_id",
+				NeedsBackfillColumn: CNeedsBackfillColumn,
+				SQL:                 []string{"team_id"},
+				Statements: []string{
+					"UPDATE teams SET members = members + 1 WHERE id = team_id;",
+				},
+			},
+			expected: `CREATE OR REPLACE FUNCTION "triggerName"()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS $$
+    #variable_conflict use_column
+    DECLARE
+      "id" "public"."users"."id"%TYPE := NEW."id";
+      "team_id" "public"."users"."team_id"%TYPE := NEW."team_id";
+      latest_schema text;
+      search_path text;
+    BEGIN
+      SELECT current_setting
+        INTO search_path
+        FROM current_setting('search_path');
+
+      IF search_path != 'public_01_migration_name' THEN
+        NEW."_pgroll_new_team_id" = team_id;
+        UPDATE teams SET members = members + 1 WHERE id = team_id;
+        NEW."_pgroll_needs_backfill" = false;
+      END IF;
+
+      RETURN NEW;
+    END; $$
+`,
+		},
+		{
+			name: "up trigger with stacked migrations",
+			config: triggerConfig{
+				Name:      "triggerName",
+				Direction: TriggerDirectionUp,
+				Columns: map[string]*schema.Column{
+					"id":       {Name: "id", Type: "int"},
+					"username": {Name: "username", Type: "text"},
+				},
+				SchemaName:          "public",
+				LatestSchema:        "public_01_migration_name",
+				StackedSchemas:      []string{"public_02_stacked", "public_03_stacked"},
+				TableName:           "users",
+				PhysicalColumn:      "_pgroll_new_username",
+				NeedsBackfillColumn: CNeedsBackfillColumn,
+				SQL:                 []string{"upper(username)"},
+			},
+			expected: `CREATE OR REPLACE FUNCTION "triggerName"()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS $$
+    #variable_conflict use_column
+    DECLARE
+      "id" "public"."users"."id"%TYPE := NEW."id";
+      "username" "public"."users"."username"%TYPE := NEW."username";
+      latest_schema text;
+      search_path text;
+    BEGIN
+      SELECT current_setting
+        INTO search_path
+        FROM current_setting('search_path');
+
+      IF search_path NOT IN ('public_01_migration_name', 'public_02_stacked', 'public_03_stacked') THEN
+        NEW."_pgroll_new_username" = upper(username);
+        NEW."_pgroll_needs_backfill" = false;
+      END IF;
+
+      RETURN NEW;
+    END; $$
+`,
+		},
+		{
+			name: "down trigger with stacked migrations",
+			config: triggerConfig{
+				Name:      "triggerName",
+				Direction: TriggerDirectionDown,
+				Columns: map[string]*schema.Column{
+					"id":       {Name: "id", Type: "int"},
+					"username": {Name: "_pgroll_new_username", Type: "text"},
+				},
+				SchemaName:          "public",
+				LatestSchema:        "public_01_migration_name",
+				StackedSchemas:      []string{"public_02_stacked"},
+				TableName:           "users",
+				PhysicalColumn:      "username",
+				NeedsBackfillColumn: CNeedsBackfillColumn,
+				SQL:                 []string{"lower(username)"},
+			},
+			expected: `CREATE OR REPLACE FUNCTION "triggerName"()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS $$
+    #variable_conflict use_column
+    DECLARE
+      "id" "public"."users"."id"%TYPE := NEW."id";
+      "username" "public"."users"."_pgroll_new_username"%TYPE := NEW."_pgroll_new_username";
+      latest_schema text;
+      search_path text;
+    BEGIN
+      SELECT current_setting
+        INTO search_path
+        FROM current_setting('search_path');
+
+      IF search_path IN ('public_01_migration_name', 'public_02_stacked') THEN
+        NEW."username" = lower(username);
+        NEW."_pgroll_needs_backfill" = false;
+      END IF;
+
       RETURN NEW;
     END; $$
 `,
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/db"
+)
+
+// originalValueColumn returns the alias of the old column's value in the rows
+// sampled by the reversibility check and the dry run of a backfill.
+func (n Naming) originalValueColumn() string {
+	return n.Prefix() + "original_value"
+}
+
+// VerifyReversible checks that the down SQL of the job's column changes
+// reverses their up SQL, if enabled with WithReversibilityCheck. For a random
+// sample of the backfilled rows of each table, the down SQL is applied to the
+// backfilled value of the new column and compared with the value of the old
+// column. Columns changed by more than one operation in the migration are not
+// checked.
+func (bf *Backfill) VerifyReversible(ctx context.Context, job *Job) error {
+	if bf.verifySample <= 0 {
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(job.triggers)) {
+		trigger := job.triggers[name]
+		if trigger.Direction != TriggerDirectionDown || len(trigger.SQL) != 1 {
+			continue
+		}
+		trigger.NeedsBackfillColumn = bf.NeedsBackfillColumn()
+		trigger.Naming = bf.naming
+
+		columnType, err := getColumnType(ctx, bf.conn, trigger.TableName, trigger.PhysicalColumn)
+		if err != nil {
+			return fmt.Errorf("get type of column %q on table %q: %w", trigger.PhysicalColumn, trigger.TableName, err)
+		}
+
+		sampled, mismatches, err := countMismatches(ctx, bf.conn, reversibilityCheckSQL(trigger, columnType, bf.verifySample))
+		if err != nil {
+			return fmt.Errorf("verify down SQL for column %q on table %q: %w", trigger.PhysicalColumn, trigger.TableName, err)
+		}
+
+		if mismatches > 0 {
+			return ReversibilityMismatchError{
+				Table:      trigger.TableName,
+				Column:     trigger.PhysicalColumn,
+				Mismatches: mismatches,
+				Sampled:    sampled,
+			}
+		}
+	}
+
+	return nil
+}
+
+// reversibilityCheckSQL returns a query that counts the sampled rows of the
+// down trigger's table, and those for which the trigger's down SQL doesn't
+// reproduce the value of the old column. The down SQL is evaluated against
+// the columns of the new version of the table, as it is in the trigger.
+func reversibilityCheckSQL(trigger triggerConfig, columnType string, sampleSize int) string {
+	columns := make([]string, 0, len(trigger.Columns)+1)
+	for _, name := range slices.Sorted(maps.Keys(trigger.Columns)) {
+		columns = append(columns, fmt.Sprintf("%s AS %s",
+			pq.QuoteIdentifier(trigger.Columns[name].Name),
+			pq.QuoteIdentifier(name)))
+	}
+	columns = append(columns, fmt.Sprintf("%s AS %s",
+		pq.QuoteIdentifier(trigger.PhysicalColumn),
+		pq.QuoteIdentifier(trigger.Naming.originalValueColumn())))
+
+	return fmt.Sprintf(`SELECT count(*), count(*) FILTER (WHERE CAST((%s) AS %s) IS DISTINCT FROM %s)
+FROM (
+  SELECT %s
+  FROM %s
+  WHERE %s = false
+  ORDER BY random()
+  LIMIT %d
+) AS sample`,
+		trigger.SQL[0],
+		columnType,
+		pq.QuoteIdentifier(trigger.Naming.originalValueColumn()),
+		strings.Join(columns, ", "),
+		pq.QuoteIdentifier(trigger.TableName),
+		pq.QuoteIdentifier(trigger.NeedsBackfillColumn),
+		sampleSize)
+}
+
+// countMismatches runs the reversibility check query and returns the number of
+// sampled rows and the number of those that don't round-trip.
+func countMismatches(ctx context.Context, conn db.DB, query string) (sampled, mismatches int64, err error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(&sampled, &mismatches); err != nil {
+			return 0, 0, err
+		}
+	}
+	return sampled, mismatches, rows.Err()
+}
+
+// getColumnType returns the type of the given column, formatted as it would
+// be in a column definition.
+func getColumnType(ctx context.Context, conn db.DB, tableName, columnName string) (string, error) {
+	rows, err := conn.QueryContext(ctx, `
+	  SELECT format_type(atttypid, atttypmod)
+	  FROM pg_attribute
+	  WHERE attrelid = $1::regclass AND attname = $2 AND NOT attisdropped`,
+		pq.QuoteIdentifier(tableName), columnName)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var columnType string
+	if err := db.ScanFirstValue(rows, &columnType); err != nil {
+		return "", err
+	}
+	return columnType, nil
+}
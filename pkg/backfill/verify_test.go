@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+func TestReversibilityCheckSQL(t *testing.T) {
+	trigger := triggerConfig{
+		Direction: TriggerDirectionDown,
+		Columns: map[string]*schema.Column{
+			"id":     {Name: "id"},
+			"rating": {Name: "_pgroll_new_rating"},
+		},
+		TableName:           "reviews",
+		PhysicalColumn:      "rating",
+		NeedsBackfillColumn: CNeedsBackfillColumn,
+		SQL:                 []string{"rating::text"},
+	}
+
+	expected := `SELECT count(*), count(*) FILTER (WHERE CAST((rating::text) AS text) IS DISTINCT FROM "_pgroll_original_value")
+FROM (
+  SELECT "id" AS "id", "_pgroll_new_rating" AS "rating", "rating" AS "_pgroll_original_value"
+  FROM "reviews"
+  WHERE "_pgroll_needs_backfill" = false
+  ORDER BY random()
+  LIMIT 100
+) AS sample`
+
+	assert.Equal(t, expected, reversibilityCheckSQL(trigger, "text", 100))
+}
@@ -5,6 +5,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"time"
 
@@ -25,13 +26,36 @@ type DB interface {
 	Close() error
 }
 
-// RDB wraps a *sql.DB and retries queries using an exponential backoff (with
-// jitter) on lock_timeout errors.
+// Conn is a connection to the database, either a pool of connections such as
+// a *sql.DB or a single connection such as a *sql.Conn.
+type Conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	Close() error
+}
+
+// ErrorClassifier reports whether an error returned by a query is transient,
+// in which case the query is retried, or fatal, in which case it is returned.
+type ErrorClassifier func(err error) bool
+
+// IsRetryableError is the default ErrorClassifier. It treats lock_timeout
+// errors as retryable and every other error as fatal.
+func IsRetryableError(err error) bool {
+	pqErr := &pq.Error{}
+	return errors.As(err, &pqErr) && pqErr.Code == lockNotAvailableErrorCode
+}
+
+// RDB wraps a Conn and retries queries using an exponential backoff (with
+// jitter) on retryable errors. Errors are classified using IsRetryable, or
+// IsRetryableError if it is nil.
 type RDB struct {
-	DB *sql.DB
+	DB          Conn
+	IsRetryable ErrorClassifier
 }
 
-// ExecContext wraps sql.DB.ExecContext, retrying queries on lock_timeout errors.
+// ExecContext wraps Conn.ExecContext, retrying queries on retryable errors.
 func (db *RDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	b := backoff.New(maxBackoffDuration, backoffInterval)
 
@@ -41,8 +65,7 @@ func (db *RDB) ExecContext(ctx context.Context, query string, args ...interface{
 			return res, nil
 		}
 
-		pqErr := &pq.Error{}
-		if errors.As(err, &pqErr) && pqErr.Code == lockNotAvailableErrorCode {
+		if db.isRetryable(err) {
 			if err := sleepCtx(ctx, b.Duration()); err != nil {
 				return nil, err
 			}
@@ -53,7 +76,7 @@ func (db *RDB) ExecContext(ctx context.Context, query string, args ...interface{
 	}
 }
 
-// QueryContext wraps sql.DB.QueryContext, retrying queries on lock_timeout errors.
+// QueryContext wraps Conn.QueryContext, retrying queries on retryable errors.
 func (db *RDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	b := backoff.New(maxBackoffDuration, backoffInterval)
 
@@ -63,8 +86,7 @@ func (db *RDB) QueryContext(ctx context.Context, query string, args ...interface
 			return rows, nil
 		}
 
-		pqErr := &pq.Error{}
-		if errors.As(err, &pqErr) && pqErr.Code == lockNotAvailableErrorCode {
+		if db.isRetryable(err) {
 			if err := sleepCtx(ctx, b.Duration()); err != nil {
 				return nil, err
 			}
@@ -75,7 +97,7 @@ func (db *RDB) QueryContext(ctx context.Context, query string, args ...interface
 	}
 }
 
-// WithRetryableTransaction runs `f` in a transaction, retrying on lock_timeout errors.
+// WithRetryableTransaction runs `f` in a transaction, retrying on retryable errors.
 func (db *RDB) WithRetryableTransaction(ctx context.Context, f func(context.Context, *sql.Tx) error) error {
 	b := backoff.New(maxBackoffDuration, backoffInterval)
 
@@ -94,8 +116,7 @@ func (db *RDB) WithRetryableTransaction(ctx context.Context, f func(context.Cont
 			return errRollback
 		}
 
-		pqErr := &pq.Error{}
-		if errors.As(err, &pqErr) && pqErr.Code == lockNotAvailableErrorCode {
+		if db.isRetryable(err) {
 			if err := sleepCtx(ctx, b.Duration()); err != nil {
 				return err
 			}
@@ -110,6 +131,73 @@ func (db *RDB) Close() error {
 	return db.DB.Close()
 }
 
+func (db *RDB) isRetryable(err error) bool {
+	if db.IsRetryable != nil {
+		return db.IsRetryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// PooledConn is a connection taken from a pool that is owned by someone else,
+// such as the caller of a library function. The connection is used by a
+// single session, so settings made on it apply to every query. Closing it
+// resets the settings and returns the connection to the pool.
+type PooledConn struct {
+	*sql.Conn
+}
+
+// NewPooledConn takes a connection from the pool.
+func NewPooledConn(ctx context.Context, pool *sql.DB) (*PooledConn, error) {
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PooledConn{Conn: conn}, nil
+}
+
+// Close resets the settings of the session and returns the connection to the
+// pool. If the settings can't be reset, the connection is discarded instead.
+func (c *PooledConn) Close() error {
+	// The context of the session may have been cancelled, so the settings are
+	// reset regardless of it. RESET ALL doesn't reset the role.
+	ctx := context.Background()
+	for _, stmt := range []string{"RESET ALL", "RESET ROLE"} {
+		if _, err := c.Conn.ExecContext(ctx, stmt); err != nil {
+			// The connection is discarded, rather than returned to the pool,
+			// when Raw returns driver.ErrBadConn
+			c.Conn.Raw(func(any) error { return driver.ErrBadConn })
+			return nil
+		}
+	}
+	return c.Conn.Close()
+}
+
+// PingWithRetry pings the database, making up to `attempts` attempts in total.
+// Attempts are separated by an exponential backoff (with jitter) starting at
+// `delay`. This allows a database that is still starting up to become
+// available.
+func PingWithRetry(ctx context.Context, conn *sql.DB, attempts int, delay time.Duration) error {
+	if delay <= 0 {
+		delay = backoffInterval
+	}
+	b := backoff.New(maxBackoffDuration, delay)
+
+	var err error
+	for i := 0; i < max(attempts, 1); i++ {
+		if i > 0 {
+			if err := sleepCtx(ctx, b.Duration()); err != nil {
+				return err
+			}
+		}
+
+		if err = conn.PingContext(ctx); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
 func sleepCtx(ctx context.Context, d time.Duration) error {
 	select {
 	case <-ctx.Done():
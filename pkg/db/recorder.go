@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// RecordingDB is an implementation of `DB` that records the statements
+// executed against it instead of running them. As with `FakeDB`, queries
+// return no rows.
+type RecordingDB struct {
+	// Conn is an optional connection to the database for which statements are
+	// recorded. It is only used for side-effect free probes of the database,
+	// such as checking whether a column default can be added without a table
+	// rewrite.
+	Conn DB
+
+	// LiveQueries makes read-only queries run against Conn, rather than
+	// return no rows, so that statements can be planned from the current
+	// state of the database. Queries that may modify the database still
+	// return no rows.
+	LiveQueries bool
+
+	statements []string
+}
+
+// ExecContext records the statement, with any arguments interpolated as
+// literals.
+func (db *RecordingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	db.statements = append(db.statements, interpolate(query, args))
+	return nil, nil
+}
+
+// QueryContext returns no rows, unless LiveQueries is set and the query is
+// read-only, in which case it is run against Conn.
+func (db *RecordingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if db.LiveQueries && db.Conn != nil && isReadOnlyQuery(query) {
+		return db.Conn.QueryContext(ctx, query, args...)
+	}
+	return nil, nil
+}
+
+// WithRetryableTransaction records the statements executed by `f` between
+// `BEGIN` and `COMMIT` statements. `f` is called with a nil transaction, so
+// it must execute its statements against the `RecordingDB` itself.
+func (db *RecordingDB) WithRetryableTransaction(ctx context.Context, f func(context.Context, *sql.Tx) error) error {
+	db.statements = append(db.statements, "BEGIN")
+	if err := f(ctx, nil); err != nil {
+		return err
+	}
+	db.statements = append(db.statements, "COMMIT")
+	return nil
+}
+
+// WithComment records the comment, as SQL comment lines, before the
+// statements executed by `f`. Nothing is recorded for the comment if `f`
+// executes no statements.
+func (db *RecordingDB) WithComment(comment string, f func() error) error {
+	n := len(db.statements)
+	if err := f(); err != nil {
+		return err
+	}
+	if comment == "" || len(db.statements) == n {
+		return nil
+	}
+
+	lines := strings.Split(strings.TrimSpace(comment), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("-- "+line, " ")
+	}
+	db.statements = slices.Insert(db.statements, n, strings.Join(lines, "\n"))
+	return nil
+}
+
+func (db *RecordingDB) Close() error {
+	return nil
+}
+
+// Statements returns the recorded statements, in the order they were
+// executed.
+func (db *RecordingDB) Statements() []string {
+	return db.statements
+}
+
+// Reset discards the recorded statements.
+func (db *RecordingDB) Reset() {
+	db.statements = nil
+}
+
+var (
+	readQueryRe  = regexp.MustCompile(`(?i)^\s*(SELECT|WITH)\b`)
+	writeQueryRe = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|TRUNCATE|nextval|setval)\b`)
+)
+
+// isReadOnlyQuery returns true if the query is a SELECT that neither modifies
+// nor locks rows.
+func isReadOnlyQuery(query string) bool {
+	return readQueryRe.MatchString(query) && !writeQueryRe.MatchString(query)
+}
+
+// interpolate replaces the `$n` placeholders in the query with the
+// corresponding arguments, quoted as literals.
+func interpolate(query string, args []interface{}) string {
+	// Replace placeholders in reverse order so that `$1` does not match the
+	// prefix of `$10`
+	for i := len(args) - 1; i >= 0; i-- {
+		query = strings.ReplaceAll(query, fmt.Sprintf("$%d", i+1), pq.QuoteLiteral(fmt.Sprint(args[i])))
+	}
+	return query
+}
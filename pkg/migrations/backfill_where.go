@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"encoding/json"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// validateBackfillWhere checks that the condition limiting a backfill is a
+// single boolean expression whose column references, outside of subqueries,
+// are columns of the table.
+func validateBackfillWhere(table *schema.Table, where string) error {
+	invalid := InvalidBackfillWhereError{Table: table.Name, Where: where}
+
+	tree, err := pgq.Parse("SELECT 1 WHERE " + where)
+	if err != nil || len(tree.GetStmts()) != 1 {
+		return invalid
+	}
+	stmt := tree.GetStmts()[0].GetStmt().GetSelectStmt()
+	if stmt == nil || stmt.GetWhereClause() == nil {
+		return invalid
+	}
+
+	// Reject anything other than the condition itself, such as a trailing
+	// GROUP BY or LIMIT clause, by comparing the statement with one that
+	// contains only the condition.
+	whereOnly := &pgq.ParseResult{Stmts: []*pgq.RawStmt{{
+		Stmt: &pgq.Node{Node: &pgq.Node_SelectStmt{SelectStmt: &pgq.SelectStmt{
+			TargetList:  stmt.GetTargetList(),
+			WhereClause: stmt.GetWhereClause(),
+			Op:          pgq.SetOperation_SETOP_NONE,
+		}}},
+	}}}
+	got, err := pgq.Deparse(tree)
+	if err != nil {
+		return invalid
+	}
+	want, err := pgq.Deparse(whereOnly)
+	if err != nil || got != want {
+		return invalid
+	}
+
+	jsonTree, err := pgq.ParseToJSON("SELECT 1 WHERE " + where)
+	if err != nil {
+		return invalid
+	}
+	var node any
+	if err := json.Unmarshal([]byte(jsonTree), &node); err != nil {
+		return invalid
+	}
+
+	for _, column := range columnReferences(node) {
+		if table.GetColumn(column) == nil {
+			return ColumnDoesNotExistError{Table: table.Name, Name: column}
+		}
+	}
+
+	return nil
+}
+
+// columnReferences returns the names of the columns referenced in the JSON
+// representation of a parse tree. References inside subqueries are skipped as
+// they may refer to the columns of other tables.
+func columnReferences(node any) []string {
+	var columns []string
+
+	switch n := node.(type) {
+	case map[string]any:
+		if ref, ok := n["ColumnRef"].(map[string]any); ok {
+			fields, _ := ref["fields"].([]any)
+			if len(fields) > 0 {
+				last, _ := fields[len(fields)-1].(map[string]any)
+				if str, ok := last["String"].(map[string]any); ok {
+					if name, ok := str["sval"].(string); ok {
+						columns = append(columns, name)
+					}
+				}
+			}
+			return columns
+		}
+		for key, v := range n {
+			if key == "subselect" {
+				continue
+			}
+			columns = append(columns, columnReferences(v)...)
+		}
+	case []any:
+		for _, v := range n {
+			columns = append(columns, columnReferences(v)...)
+		}
+	}
+
+	return columns
+}
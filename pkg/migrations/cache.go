@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+)
+
+// cacheFormatVersion is the version of the format of cache entries. It is
+// part of the key of every entry, together with the version of pgroll, so
+// that entries written in another format are not used. Bump it whenever the
+// format of the entries, or the way migration files are parsed, changes.
+const cacheFormatVersion = 2
+
+// pgrollModule is the path of the pgroll module, whose version is part of the
+// key of every cache entry.
+const pgrollModule = "github.com/xataio/pgroll"
+
+// Cache is an on-disk cache of parsed migration files. Entries are keyed by a
+// hash of the file's name and contents, so an entry is no longer used once
+// the file changes.
+//
+// A nil *Cache is valid and reads migration files without caching.
+type Cache struct {
+	dir string
+
+	// version identifies the format of the cache entries and the build of
+	// pgroll that reads them
+	version string
+
+	// parsed holds the parsed operations of the migrations read through the
+	// cache, so that ParseMigration doesn't parse them again
+	mu     sync.Mutex
+	parsed map[*RawMigration][]cachedOperation
+}
+
+// cacheEntry is the gob-encoded form of a migration file stored in the cache.
+type cacheEntry struct {
+	Migration RawMigration
+
+	// Operations holds the parsed operations of the migration, or is nil if
+	// they couldn't be parsed
+	Operations []cachedOperation
+}
+
+// cachedOperation is a parsed operation. Its fields are stored as JSON, with
+// the defaults of omitted fields filled in, as gob can't tell a pointer to a
+// zero value, such as `nullable: false`, from a nil pointer.
+type cachedOperation struct {
+	Name    OpName
+	Body    []byte
+	Comment string
+}
+
+// NewCache returns a Cache that stores its entries in dir. The directory is
+// created if it does not exist.
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &Cache{
+		dir:     dir,
+		version: fmt.Sprintf("%d %s", cacheFormatVersion, buildVersion()),
+		parsed:  make(map[*RawMigration][]cachedOperation),
+	}, nil
+}
+
+// ReadRawMigration reads the migration file as a RawMigration, using the
+// cached copy if the file has not changed since it was last read. The parsed
+// operations of the migration are kept for ParseMigration.
+func (c *Cache) ReadRawMigration(dir fs.FS, filename string) (*RawMigration, error) {
+	if c == nil {
+		return ReadRawMigration(dir, filename)
+	}
+
+	file, err := dir.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening migration file: %w", err)
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	entryPath := c.entryPath(filename, byteValue)
+
+	// Use the cached entry if there is one. Unreadable entries are treated as
+	// a cache miss and overwritten below.
+	if data, err := os.ReadFile(entryPath); err == nil {
+		var entry cacheEntry
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err == nil {
+			mig := &entry.Migration
+			c.keepParsed(mig, entry.Operations)
+			return mig, nil
+		}
+	}
+
+	mig, err := decodeRawMigration(filename, byteValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrations whose operations can't be parsed are cached without them;
+	// ParseMigration reports the error
+	entry := cacheEntry{Migration: *mig}
+	if parsed, err := ParseMigration(mig); err == nil {
+		entry.Operations, err = cacheOperations(parsed.Operations)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.keepParsed(mig, entry.Operations)
+
+	// The cache is only an optimization, so a migration that can't be written
+	// to it is still returned
+	_ = c.write(entryPath, entry)
+
+	return mig, nil
+}
+
+// ParseMigration converts a RawMigration to a fully parsed Migration, as the
+// package-level ParseMigration does, reusing the operations parsed when the
+// migration was read through the cache.
+func (c *Cache) ParseMigration(raw *RawMigration) (*Migration, error) {
+	if c == nil {
+		return ParseMigration(raw)
+	}
+
+	c.mu.Lock()
+	cached, ok := c.parsed[raw]
+	c.mu.Unlock()
+	if !ok {
+		return ParseMigration(raw)
+	}
+
+	ops, err := uncacheOperations(cached)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migration{
+		Name:          raw.Name,
+		VersionSchema: raw.VersionSchema,
+		Transactional: raw.Transactional,
+		Reversible:    raw.Reversible,
+		Environments:  raw.Environments,
+		Operations:    ops,
+		Assertions:    raw.Assertions,
+	}, nil
+}
+
+// keepParsed keeps the parsed operations of a migration read through the
+// cache.
+func (c *Cache) keepParsed(mig *RawMigration, ops []cachedOperation) {
+	if ops == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.parsed[mig] = ops
+}
+
+// entryPath returns the path of the cache entry for a migration file with
+// the given name and contents.
+func (c *Cache) entryPath(filename string, contents []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.version))
+	h.Write([]byte{0})
+	h.Write([]byte(filepath.Base(filename)))
+	h.Write([]byte{0})
+	h.Write(contents)
+	return filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil))+".gob")
+}
+
+// write stores the entry at path. The entry is written to a temporary file
+// first so that concurrent readers never see a partially written entry.
+func (c *Cache) write(path string, entry cacheEntry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// cacheOperations returns the cached form of parsed operations.
+func cacheOperations(ops Operations) ([]cachedOperation, error) {
+	cached := make([]cachedOperation, len(ops))
+	for i, op := range ops {
+		body, err := json.Marshal(op)
+		if err != nil {
+			return nil, fmt.Errorf("encoding operation at index %d: %w", i, err)
+		}
+		cached[i] = cachedOperation{
+			Name:    OperationName(op),
+			Body:    body,
+			Comment: OperationComment(op),
+		}
+	}
+	return cached, nil
+}
+
+// uncacheOperations returns the operations from their cached form. The
+// defaults of omitted fields were filled in before the operations were
+// cached, so they are decoded as they are.
+func uncacheOperations(cached []cachedOperation) (Operations, error) {
+	ops := make(Operations, len(cached))
+	for i, c := range cached {
+		op, err := OperationFromName(c.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(c.Body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(op); err != nil {
+			return nil, fmt.Errorf("decode cached migration [%v]: %w", c.Name, err)
+		}
+
+		SetOperationComment(op, c.Comment)
+		ops[i] = op
+	}
+	return ops, nil
+}
+
+// GobEncode encodes the migration as JSON, as gob can't tell a pointer to a
+// zero value, such as `reversible: false`, from a nil pointer.
+func (m RawMigration) GobEncode() ([]byte, error) {
+	type migration RawMigration
+	return json.Marshal(struct {
+		Name string `json:"name"`
+		migration
+	}{Name: m.Name, migration: migration(m)})
+}
+
+// GobDecode decodes a migration encoded by GobEncode.
+func (m *RawMigration) GobDecode(data []byte) error {
+	type migration RawMigration
+	var decoded struct {
+		Name string `json:"name"`
+		migration
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = RawMigration(decoded.migration)
+	m.Name = decoded.Name
+	return nil
+}
+
+// buildVersion returns the version of the pgroll module in the running
+// binary, and the revision it was built from if it is known.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	version := info.Main.Version
+	if info.Main.Path != pgrollModule {
+		for _, dep := range info.Deps {
+			if dep.Path == pgrollModule {
+				version = dep.Version
+			}
+		}
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+			version += " " + setting.Value
+		}
+	}
+	return version
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestCacheReadRawMigration(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cached migrations match uncached migrations", func(t *testing.T) {
+		cache, err := migrations.NewCache(t.TempDir())
+		require.NoError(t, err)
+
+		dir := fstest.MapFS{
+			"01_create_table.yaml": &fstest.MapFile{Data: []byte("operations:\n  - sql:\n      up: SELECT 1\n")},
+		}
+
+		want, err := migrations.ReadRawMigration(dir, "01_create_table.yaml")
+		require.NoError(t, err)
+
+		// The first read populates the cache and the second read is served from it
+		for range 2 {
+			got, err := cache.ReadRawMigration(dir, "01_create_table.yaml")
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		}
+	})
+
+	t.Run("cached migrations keep fields set to their zero value", func(t *testing.T) {
+		cache, err := migrations.NewCache(t.TempDir())
+		require.NoError(t, err)
+
+		dir := fstest.MapFS{
+			"01_migration.json": &fstest.MapFile{Data: []byte(`{"reversible": false, "transactional": false, "operations": [{"sql": {"up": "SELECT 1"}}]}`)},
+		}
+
+		for range 2 {
+			got, err := cache.ReadRawMigration(dir, "01_migration.json")
+			require.NoError(t, err)
+			require.NotNil(t, got.Reversible)
+			assert.False(t, *got.Reversible)
+			require.NotNil(t, got.Transactional)
+			assert.False(t, *got.Transactional)
+		}
+	})
+
+	t.Run("cached operations match parsed operations", func(t *testing.T) {
+		cacheDir := t.TempDir()
+
+		dir := fstest.MapFS{
+			"01_migration.json": &fstest.MapFile{Data: []byte(`{"operations": [
+				{"comment": "add a column", "add_column": {"table": "users", "column": {"name": "age", "type": "int", "nullable": false}, "up": "0"}},
+				{"sql": {"up": "SELECT 1"}}
+			]}`)},
+		}
+
+		raw, err := migrations.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+		want, err := migrations.ParseMigration(raw)
+		require.NoError(t, err)
+
+		// The first cache populates the cache directory and the second cache
+		// reads the operations from it
+		for range 2 {
+			cache, err := migrations.NewCache(cacheDir)
+			require.NoError(t, err)
+
+			raw, err := cache.ReadRawMigration(dir, "01_migration.json")
+			require.NoError(t, err)
+			got, err := cache.ParseMigration(raw)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		}
+	})
+
+	t.Run("migrations whose operations can't be parsed are cached", func(t *testing.T) {
+		cache, err := migrations.NewCache(t.TempDir())
+		require.NoError(t, err)
+
+		dir := fstest.MapFS{
+			"01_migration.json": &fstest.MapFile{Data: []byte(`{"operations": [{"no_such_operation": {}}]}`)},
+		}
+
+		raw, err := cache.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+		_, err = cache.ParseMigration(raw)
+		assert.Error(t, err)
+	})
+
+	t.Run("migrations are read when the cache can't be written", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		cache, err := migrations.NewCache(cacheDir)
+		require.NoError(t, err)
+		require.NoError(t, os.RemoveAll(cacheDir))
+
+		dir := fstest.MapFS{
+			"01_migration.json": &fstest.MapFile{Data: []byte(`{"operations": [{"sql": {"up": "SELECT 1"}}]}`)},
+		}
+
+		got, err := cache.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+		assert.Equal(t, "01_migration", got.Name)
+	})
+
+	t.Run("changing a migration file invalidates its cache entry", func(t *testing.T) {
+		cache, err := migrations.NewCache(t.TempDir())
+		require.NoError(t, err)
+
+		dir := fstest.MapFS{
+			"01_migration.json": &fstest.MapFile{Data: []byte(`{"operations": [{"sql": {"up": "SELECT 1"}}]}`)},
+		}
+
+		_, err = cache.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+
+		dir["01_migration.json"] = &fstest.MapFile{Data: []byte(`{"operations": [{"sql": {"up": "SELECT 2"}}]}`)}
+
+		got, err := cache.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"sql": {"up": "SELECT 2"}}]`, string(got.Operations))
+	})
+
+	t.Run("corrupt cache entries are replaced", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		cache, err := migrations.NewCache(cacheDir)
+		require.NoError(t, err)
+
+		dir := fstest.MapFS{
+			"01_migration.json": &fstest.MapFile{Data: []byte(`{"operations": [{"sql": {"up": "SELECT 1"}}]}`)},
+		}
+
+		_, err = cache.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+
+		// Overwrite every cache entry with garbage
+		entries, err := filepath.Glob(filepath.Join(cacheDir, "*.gob"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.NoError(t, os.WriteFile(entries[0], []byte("not a cache entry"), 0o600))
+
+		got, err := cache.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+		assert.Equal(t, "01_migration", got.Name)
+		assert.JSONEq(t, `[{"sql": {"up": "SELECT 1"}}]`, string(got.Operations))
+	})
+
+	t.Run("a nil cache reads migrations without caching", func(t *testing.T) {
+		var cache *migrations.Cache
+
+		dir := fstest.MapFS{
+			"01_migration.json": &fstest.MapFile{Data: []byte(`{"operations": []}`)},
+		}
+
+		got, err := cache.ReadRawMigration(dir, "01_migration.json")
+		require.NoError(t, err)
+		assert.Equal(t, "01_migration", got.Name)
+	})
+}
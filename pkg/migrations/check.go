@@ -2,6 +2,14 @@
 
 package migrations
 
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+)
+
 // Validate checks that the CheckConstraint is valid
 func (c *CheckConstraint) Validate() error {
 	if c.Name == "" {
@@ -18,3 +26,61 @@ func (c *CheckConstraint) Validate() error {
 
 	return nil
 }
+
+// ErrInvalidCheckExpression is returned when the expression of a table check
+// constraint isn't a single valid expression.
+var ErrInvalidCheckExpression = errors.New("the expression is not a single valid expression")
+
+// ErrDeferrableCheckConstraint is returned when a check constraint is marked
+// DEFERRABLE or INITIALLY DEFERRED, which Postgres doesn't allow.
+var ErrDeferrableCheckConstraint = errors.New("CHECK constraints cannot be marked DEFERRABLE")
+
+// checkExpressionColumns returns the names of the columns referenced by the
+// expression of a check constraint, sorted by name.
+// An error is returned if the expression isn't a single valid expression.
+func checkExpressionColumns(expression string) ([]string, error) {
+	tree, err := pgq.Parse("SELECT " + expression)
+	if err != nil || len(tree.GetStmts()) != 1 {
+		return nil, ErrInvalidCheckExpression
+	}
+	stmt := tree.GetStmts()[0].GetStmt().GetSelectStmt()
+	if stmt == nil || len(stmt.GetTargetList()) != 1 {
+		return nil, ErrInvalidCheckExpression
+	}
+
+	// Reject anything other than the expression itself, such as a trailing
+	// FROM or WHERE clause, by comparing the statement with one that contains
+	// only the expression.
+	exprOnly := &pgq.ParseResult{Stmts: []*pgq.RawStmt{{
+		Stmt: &pgq.Node{Node: &pgq.Node_SelectStmt{SelectStmt: &pgq.SelectStmt{
+			TargetList: stmt.GetTargetList(),
+			Op:         pgq.SetOperation_SETOP_NONE,
+		}}},
+	}}}
+	got, err := pgq.Deparse(tree)
+	if err != nil {
+		return nil, ErrInvalidCheckExpression
+	}
+	want, err := pgq.Deparse(exprOnly)
+	if err != nil || got != want {
+		return nil, ErrInvalidCheckExpression
+	}
+
+	jsonTree, err := pgq.ParseToJSON("SELECT " + expression)
+	if err != nil {
+		return nil, ErrInvalidCheckExpression
+	}
+	var node any
+	if err := json.Unmarshal([]byte(jsonTree), &node); err != nil {
+		return nil, ErrInvalidCheckExpression
+	}
+
+	var columns []string
+	for _, column := range columnReferences(node) {
+		if !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+	slices.Sort(columns)
+	return columns, nil
+}
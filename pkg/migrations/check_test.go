@@ -27,3 +27,49 @@ func TestCheckConstraintValidate(t *testing.T) {
 		assert.EqualError(t, err, `length of "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx" (64) exceeds maximum length of 63`)
 	})
 }
+
+func TestCheckExpressionColumns(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		expression  string
+		wantColumns []string
+		wantErr     error
+	}{
+		"single column": {
+			expression:  "length(name) > 3",
+			wantColumns: []string{"name"},
+		},
+		"multiple columns": {
+			expression:  "ends_at > starts_at AND guests <= rooms * 4 AND ends_at < starts_at + 30",
+			wantColumns: []string{"ends_at", "guests", "rooms", "starts_at"},
+		},
+		"no columns": {
+			expression: "true",
+		},
+		"trailing clause": {
+			expression: "ends_at > starts_at FROM bookings",
+			wantErr:    ErrInvalidCheckExpression,
+		},
+		"multiple expressions": {
+			expression: "ends_at > starts_at, true",
+			wantErr:    ErrInvalidCheckExpression,
+		},
+		"multiple statements": {
+			expression: "true; DROP TABLE bookings",
+			wantErr:    ErrInvalidCheckExpression,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			columns, err := checkExpressionColumns(tc.expression)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantColumns, columns)
+		})
+	}
+}
@@ -28,6 +28,12 @@ func (c *Column) HasDefault() bool {
 	return c.Default != nil
 }
 
+// IsGenerated returns true if the column is a stored generated column, whose
+// values are computed from a generation expression
+func (c *Column) IsGenerated() bool {
+	return c.Generated != nil && c.Generated.Expression != ""
+}
+
 // HasImplicitDefault returns true if the column has an implicit default value
 func (c *Column) HasImplicitDefault() bool {
 	switch c.Type {
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// checkColumnSQL checks the up or down SQL of a column before the operation
+// makes any change to the table, so that a typo in the SQL is reported when
+// the migration starts rather than when the trigger that runs it first fires.
+//
+// The SQL is compiled by Postgres in a query that selects it from no rows of
+// the table, with the columns of the table exposed under their logical names,
+// as the trigger function declares them. `newColumns` maps the logical names
+// of columns that the operation has yet to add to their types; they are
+// exposed as NULLs of that type. As the query returns no rows, the SQL is
+// never evaluated.
+//
+// An InvalidColumnSQLError with the Postgres error message is returned if the
+// SQL doesn't compile, eg. because of a syntax error or a reference to a
+// column that doesn't exist.
+func checkColumnSQL(ctx context.Context, conn db.DB, table *schema.Table, column string, direction backfill.TriggerDirection, sqlExpr string, newColumns map[string]string) error {
+	if strings.TrimSpace(sqlExpr) == "" {
+		return nil
+	}
+
+	tableColumns := make([]string, 0, len(table.Columns)+len(newColumns))
+	for _, name := range slices.Sorted(maps.Keys(table.Columns)) {
+		if _, ok := newColumns[name]; ok {
+			continue
+		}
+		tableColumns = append(tableColumns, fmt.Sprintf("%s AS %s",
+			pq.QuoteIdentifier(table.Columns[name].Name),
+			pq.QuoteIdentifier(name)))
+	}
+	for _, name := range slices.Sorted(maps.Keys(newColumns)) {
+		tableColumns = append(tableColumns, fmt.Sprintf("NULL::%s AS %s",
+			newColumns[name],
+			pq.QuoteIdentifier(name)))
+	}
+
+	query := fmt.Sprintf("SELECT (%s) FROM (SELECT %s FROM %s) AS t LIMIT 0",
+		sqlExpr,
+		strings.Join(tableColumns, ", "),
+		pq.QuoteIdentifier(table.Name))
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err == nil {
+		// rows is nil if we have queried a fake db
+		if rows != nil {
+			rows.Close()
+		}
+		return nil
+	}
+
+	// Errors of class 42, syntax error or access rule violation, are errors in
+	// the SQL. Any other error is returned as is.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == "42" {
+		return InvalidColumnSQLError{
+			Table:     table.Name,
+			Column:    column,
+			Direction: string(direction),
+			Message:   pqErr.Message,
+		}
+	}
+	return fmt.Errorf("failed to check %s SQL for column %q: %w", direction, column, err)
+}
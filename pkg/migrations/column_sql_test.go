@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestColumnSQLIsCheckedBeforeStart(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_create_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "age", Type: "integer", Nullable: true},
+				},
+			},
+		},
+	}
+
+	addColumnMigration := func(up string) migrations.Migration {
+		return migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table:  "users",
+					Up:     up,
+					Column: migrations.Column{Name: "age_group", Type: "text", Nullable: true},
+				},
+			},
+		}
+	}
+
+	alterColumnMigration := func(up, down string) migrations.Migration {
+		return migrations.Migration{
+			Name: "02_alter_column",
+			Operations: migrations.Operations{
+				&migrations.OpAlterColumn{
+					Table:  "users",
+					Column: "age",
+					Type:   ptr("text"),
+					Up:     up,
+					Down:   down,
+				},
+			},
+		}
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "up SQL that refers to the columns of the table and the new column",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				addColumnMigration("coalesce(age_group, CASE WHEN age >= 18 THEN 'adult' ELSE 'minor' END)"),
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				MustInsert(t, db, schema, "01_create_table", "users", map[string]string{"age": "21"})
+
+				rows := MustSelect(t, db, schema, "02_add_column", "users")
+				assert.Equal(t, []map[string]any{{"id": 1, "age": 21, "age_group": "adult"}}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+		{
+			name: "up SQL that refers to a column that doesn't exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				addColumnMigration("CASE WHEN agee >= 18 THEN 'adult' ELSE 'minor' END"),
+			},
+			wantStartErr: migrations.InvalidColumnSQLError{
+				Table:     "users",
+				Column:    "age_group",
+				Direction: "up",
+				Message:   `column "agee" does not exist`,
+			},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+		{
+			name: "up SQL with a syntax error",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				alterColumnMigration("age age", "age::integer"),
+			},
+			wantStartErr: migrations.InvalidColumnSQLError{
+				Table:     "users",
+				Column:    "age",
+				Direction: "up",
+				Message:   `syntax error at or near "age"`,
+			},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+		{
+			name: "down SQL refers to the column with its new type",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				alterColumnMigration("age::text", "age + 1"),
+			},
+			wantStartErr: migrations.InvalidColumnSQLError{
+				Table:     "users",
+				Column:    "age",
+				Direction: "down",
+				Message:   "operator does not exist: text + integer",
+			},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+	})
+}
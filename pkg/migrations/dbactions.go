@@ -4,12 +4,20 @@ package migrations
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/lib/pq"
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
 )
 
 // DBAction is an interface for common database actions
@@ -18,6 +26,67 @@ type DBAction interface {
 	Execute(context.Context) error
 }
 
+// NonBlockingAction is a DBAction that does not take locks that block reads or
+// writes on the tables it acts on. Such actions may be executed ahead of the
+// other actions when completing a migration.
+type NonBlockingAction interface {
+	DBAction
+	NonBlocking()
+}
+
+// TriggerCleanupAction is a DBAction that removes the triggers and trigger
+// functions used to keep the old and new versions of a column in sync.
+type TriggerCleanupAction interface {
+	DBAction
+	// TriggerFunctions returns the names of the trigger functions the action
+	// drops, together with the triggers that use them.
+	TriggerFunctions() []string
+}
+
+// IndexBuildAction is a DBAction that builds an index concurrently. Building
+// an index on a large table can take a long time, so the progress of the
+// build may be monitored while the action executes.
+type IndexBuildAction interface {
+	DBAction
+	// IndexName returns the name of the index being built.
+	IndexName() string
+}
+
+// ConcurrentIndexAction is a DBAction that builds or drops an index
+// concurrently. Building and dropping indexes concurrently isn't supported by
+// some Postgres-compatible databases, so such actions can be made to take the
+// locks of their non-concurrent form instead.
+type ConcurrentIndexAction interface {
+	DBAction
+	// WithoutConcurrently makes the action build or drop the index without
+	// CONCURRENTLY, blocking writes to the table while it runs.
+	WithoutConcurrently()
+}
+
+// ConstraintValidationAction is a DBAction that validates a NOT VALID
+// constraint. Validating a constraint takes a SHARE UPDATE EXCLUSIVE lock,
+// which conflicts with itself, so only validations of constraints on different
+// tables can run concurrently.
+type ConstraintValidationAction interface {
+	NonBlockingAction
+	// Table returns the name of the table the constraint is defined on.
+	Table() string
+	// Constraint returns the name of the constraint being validated.
+	Constraint() string
+	// ExecuteWithConn validates the constraint using the given connection
+	// rather than the one the action was created with.
+	ExecuteWithConn(ctx context.Context, conn db.DB) error
+}
+
+// MultiStatementAction is a DBAction that may execute several statements in a
+// single query. Postgres runs the statements of such a query in an implicit
+// transaction, so they are split up when the migration is non-transactional.
+type MultiStatementAction interface {
+	DBAction
+	// Split returns an action for each of the statements.
+	Split() ([]DBAction, error)
+}
+
 type addColumnAction struct {
 	conn   db.DB
 	table  string
@@ -34,6 +103,19 @@ func NewAddColumnAction(conn db.DB, table string, c Column, withPK bool) *addCol
 }
 
 func (a *addColumnAction) Execute(ctx context.Context) error {
+	// A temporary column may have been left behind by an earlier attempt to
+	// start the migration that was interrupted. Reuse it if it has the expected
+	// type; the backfill picks up from the rows that still need it.
+	if strings.HasPrefix(a.column.Name, NamingFrom(ctx).TemporaryPrefix()) {
+		exists, err := shadowColumnExists(ctx, a.conn, a.table, a.column.Name, a.column.Type)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+	}
+
 	colSQL, err := ColumnSQLWriter{WithPK: a.withPK}.Write(a.column)
 	if err != nil {
 		return err
@@ -46,12 +128,72 @@ func (a *addColumnAction) Execute(ctx context.Context) error {
 	return err
 }
 
+// typeModifierRe matches the modifier of a type name, such as the `(255)` in
+// `varchar(255)`.
+var typeModifierRe = regexp.MustCompile(`\(([^)]*)\)`)
+
+// shadowColumnExists returns true if the temporary column already exists on
+// the table. A ShadowColumnMismatchError is returned if the existing column
+// does not have the expected type, as it can't then have been created by the
+// same operation.
+func shadowColumnExists(ctx context.Context, conn db.DB, table, column, typ string) (bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT format_type(a.atttypid, NULL),
+			format_type(a.atttypid, a.atttypmod),
+			coalesce(format_type(to_regtype($3), NULL), '')
+		FROM pg_catalog.pg_attribute a
+		WHERE a.attrelid = $1::regclass
+		AND a.attname = $2
+		AND a.attnum > 0
+		AND NOT a.attisdropped`,
+		pq.QuoteIdentifier(table), column, typ)
+	if err != nil {
+		return false, fmt.Errorf("checking for existing column %q: %w", column, err)
+	}
+	if rows == nil {
+		// if rows == nil && err != nil, then it means we have queried a fake db.
+		// In that case, the column does not exist.
+		return false, nil
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+
+	var baseType, actualType, expectedBaseType string
+	if err := rows.Scan(&baseType, &actualType, &expectedBaseType); err != nil {
+		return false, fmt.Errorf("scanning existing column %q: %w", column, err)
+	}
+
+	if baseType != expectedBaseType || typeModifier(actualType) != typeModifier(typ) {
+		return false, ShadowColumnMismatchError{
+			Table:        table,
+			Column:       column,
+			ExpectedType: typ,
+			ActualType:   actualType,
+		}
+	}
+
+	return true, nil
+}
+
+// typeModifier returns the modifier of the type name, without whitespace, or
+// the empty string if the type has no modifier.
+func typeModifier(typ string) string {
+	m := typeModifierRe.FindStringSubmatch(typ)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(m[1]), "")
+}
+
 // dropColumnAction is a DBAction that drops one or more columns from a table.
 type dropColumnAction struct {
 	conn db.DB
 
 	table   string
 	columns []string
+	cascade bool
 }
 
 func NewDropColumnAction(conn db.DB, table string, columns ...string) *dropColumnAction {
@@ -62,6 +204,17 @@ func NewDropColumnAction(conn db.DB, table string, columns ...string) *dropColum
 	}
 }
 
+// NewDropColumnCascadeAction returns a DBAction that drops one or more
+// columns from a table, along with any objects that depend on them.
+func NewDropColumnCascadeAction(conn db.DB, table string, columns ...string) *dropColumnAction {
+	return &dropColumnAction{
+		conn:    conn,
+		table:   table,
+		columns: columns,
+		cascade: true,
+	}
+}
+
 func (a *dropColumnAction) Execute(ctx context.Context) error {
 	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE IF EXISTS %s %s",
 		pq.QuoteIdentifier(a.table),
@@ -73,10 +226,124 @@ func (a *dropColumnAction) dropMultipleColumns() string {
 	cols := make([]string, len(a.columns))
 	for i, col := range a.columns {
 		cols[i] = "DROP COLUMN IF EXISTS " + pq.QuoteIdentifier(col)
+		if a.cascade {
+			cols[i] += " CASCADE"
+		}
 	}
 	return strings.Join(cols, ", ")
 }
 
+// checkColumnViewsAction is a DBAction that fails if any view depends on a
+// column.
+type checkColumnViewsAction struct {
+	conn   db.DB
+	table  string
+	column string
+}
+
+// NewCheckColumnViewsAction returns a DBAction that fails with a
+// ColumnHasDependentsError if any view depends on the column, rather than
+// letting the column's drop fail with the error from postgres. Views are not
+// part of the schema that operations are validated against, so they are
+// checked when the column is dropped. The views of pgroll's version schemas,
+// which are named after the schema, are not included.
+func NewCheckColumnViewsAction(conn db.DB, table, column string) *checkColumnViewsAction {
+	return &checkColumnViewsAction{
+		conn:   conn,
+		table:  table,
+		column: column,
+	}
+}
+
+func (a *checkColumnViewsAction) Execute(ctx context.Context) error {
+	rows, err := a.conn.QueryContext(ctx, `SELECT DISTINCT v.oid::regclass::text
+		FROM pg_catalog.pg_depend d
+		JOIN pg_catalog.pg_rewrite r ON r.oid = d.objid
+		JOIN pg_catalog.pg_class v ON v.oid = r.ev_class
+		JOIN pg_catalog.pg_namespace n ON n.oid = v.relnamespace
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+		WHERE d.classid = 'pg_catalog.pg_rewrite'::regclass
+		AND d.refobjid = to_regclass($1)
+		AND a.attname = $2
+		AND v.oid <> d.refobjid
+		AND NOT starts_with(n.nspname, current_schema() || '_')
+		ORDER BY 1`, pq.QuoteIdentifier(a.table), a.column)
+	if err != nil {
+		return fmt.Errorf("getting views that depend on column %q of table %q: %w", a.column, a.table, err)
+	}
+	if rows == nil {
+		// if rows == nil && err != nil, then it means we have queried a fake db.
+		// In that case, there are no dependents.
+		return nil
+	}
+	defer rows.Close()
+
+	var dependents []string
+	for rows.Next() {
+		var view string
+		if err := rows.Scan(&view); err != nil {
+			return fmt.Errorf("scanning views that depend on column %q of table %q: %w", a.column, a.table, err)
+		}
+		dependents = append(dependents, fmt.Sprintf("view %s", view))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(dependents) > 0 {
+		return ColumnHasDependentsError{Table: a.table, Column: a.column, Dependents: strings.Join(dependents, ", ")}
+	}
+	return nil
+}
+
+// dropNeedsBackfillColumnAction is a DBAction that drops the column that
+// marks the rows of a table to backfill.
+type dropNeedsBackfillColumnAction struct {
+	conn  db.DB
+	table string
+}
+
+// NewDropNeedsBackfillColumnAction returns a DBAction that drops the needs
+// backfill column from a table. The column may have been created with a name
+// other than the default one, so any column that pgroll marked as the needs
+// backfill column is dropped too.
+func NewDropNeedsBackfillColumnAction(conn db.DB, table string) *dropNeedsBackfillColumnAction {
+	return &dropNeedsBackfillColumnAction{
+		conn:  conn,
+		table: table,
+	}
+}
+
+func (a *dropNeedsBackfillColumnAction) Execute(ctx context.Context) error {
+	needsBackfillColumn := NamingFrom(ctx).NeedsBackfillColumn()
+	columns := []string{needsBackfillColumn}
+
+	rows, err := a.conn.QueryContext(ctx, `SELECT attname FROM pg_catalog.pg_attribute
+		WHERE attrelid = to_regclass($1)
+		AND attname <> $2
+		AND NOT attisdropped
+		AND col_description(attrelid, attnum) = $3`,
+		pq.QuoteIdentifier(a.table), needsBackfillColumn, backfill.NeedsBackfillColumnComment)
+	if err != nil {
+		return fmt.Errorf("finding needs backfill column of table %q: %w", a.table, err)
+	}
+	if rows != nil {
+		defer rows.Close()
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				return fmt.Errorf("finding needs backfill column of table %q: %w", a.table, err)
+			}
+			columns = append(columns, column)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("finding needs backfill column of table %q: %w", a.table, err)
+		}
+	}
+
+	return NewDropColumnAction(a.conn, a.table, columns...).Execute(ctx)
+}
+
 // renameTableAction is a DBAction that renames a table.
 type renameTableAction struct {
 	conn db.DB
@@ -151,48 +418,390 @@ func (a *renameConstraintAction) Execute(ctx context.Context) error {
 	return err
 }
 
-type addConstraintUsingUniqueIndexAction struct {
+// maxReportedDuplicates is the maximum number of duplicate values reported by
+// a checkUniqueValuesAction.
+const maxReportedDuplicates = 10
+
+// checkUniqueValuesAction is a DBAction that fails with a DuplicateValuesError
+// if a column contains duplicate non-NULL values. The check is skipped if the
+// unique index for the column has already been built.
+type checkUniqueValuesAction struct {
+	conn      db.DB
+	table     string
+	column    string
+	indexName string
+	// name of the column reported in errors
+	name string
+}
+
+func NewCheckUniqueValuesAction(conn db.DB, table, column, indexName, name string) *checkUniqueValuesAction {
+	return &checkUniqueValuesAction{
+		conn:      conn,
+		table:     table,
+		column:    column,
+		indexName: indexName,
+		name:      name,
+	}
+}
+
+func (a *checkUniqueValuesAction) Execute(ctx context.Context) error {
+	rows, err := a.conn.QueryContext(ctx, `SELECT EXISTS (
+			SELECT 1 FROM pg_catalog.pg_index
+			WHERE indexrelid = to_regclass($1)
+			AND indisvalid
+		)`, pq.QuoteIdentifier(a.indexName))
+	if err != nil {
+		return fmt.Errorf("checking for unique index %q: %w", a.indexName, err)
+	}
+	if rows == nil {
+		// if rows == nil && err != nil, then it means we have queried a fake db.
+		// In that case, there are no duplicates.
+		return nil
+	}
+	var indexExists bool
+	if err := db.ScanFirstValue(rows, &indexExists); err != nil {
+		return fmt.Errorf("checking for unique index %q: %w", a.indexName, err)
+	}
+	if indexExists {
+		return nil
+	}
+
+	rows, err = a.conn.QueryContext(ctx, fmt.Sprintf(`SELECT %[1]s::text FROM %[2]s
+		WHERE %[1]s IS NOT NULL
+		GROUP BY %[1]s
+		HAVING count(*) > 1
+		ORDER BY 1
+		LIMIT %[3]d`,
+		pq.QuoteIdentifier(a.column),
+		pq.QuoteIdentifier(a.table),
+		maxReportedDuplicates))
+	if err != nil {
+		return fmt.Errorf("checking column %q for duplicate values: %w", a.name, err)
+	}
+	defer rows.Close()
+
+	var duplicates []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return fmt.Errorf("checking column %q for duplicate values: %w", a.name, err)
+		}
+		duplicates = append(duplicates, value)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("checking column %q for duplicate values: %w", a.name, err)
+	}
+
+	if len(duplicates) > 0 {
+		return DuplicateValuesError{Table: a.table, Column: a.name, Values: strings.Join(duplicates, ", ")}
+	}
+	return nil
+}
+
+// NonBlocking marks the action as non-blocking; the check only reads from the
+// table.
+func (a *checkUniqueValuesAction) NonBlocking() {}
+
+// maxReportedOrphans is the maximum number of orphan rows reported by
+// checkForeignKeyOrphansAction.
+const maxReportedOrphans = 10
+
+// checkForeignKeyOrphansAction is a DBAction that reports the rows of a table
+// whose values, as computed by the up SQL of each column, reference rows that
+// are missing from the referenced table. It is run ahead of adding a foreign
+// key constraint as VALID, so that such rows are reported before the
+// migration changes the table.
+type checkForeignKeyOrphansAction struct {
 	conn       db.DB
 	table      string
+	physical   map[string]string
 	constraint string
-	indexName  string
+	columns    []string
+	up         MultiColumnUpSQL
+	reference  *TableForeignKeyReference
 }
 
-func NewAddConstraintUsingUniqueIndex(conn db.DB, table, constraint, indexName string) *addConstraintUsingUniqueIndexAction {
-	return &addConstraintUsingUniqueIndexAction{
+func NewCheckForeignKeyOrphansAction(conn db.DB, table *schema.Table, constraint string, columns []string, up MultiColumnUpSQL, reference *TableForeignKeyReference) *checkForeignKeyOrphansAction {
+	// The physical name of each column is copied, as the columns of the table
+	// are replaced by their duplicates in the schema before the action runs
+	physical := make(map[string]string, len(table.Columns))
+	for name, col := range table.Columns {
+		physical[name] = col.Name
+	}
+
+	return &checkForeignKeyOrphansAction{
+		conn:       conn,
+		table:      table.Name,
+		physical:   physical,
+		constraint: constraint,
+		columns:    columns,
+		up:         up,
+		reference:  reference,
+	}
+}
+
+func (a *checkForeignKeyOrphansAction) Execute(ctx context.Context) error {
+	// Expose the columns of the table under their logical names, which are
+	// the names the up SQL refers to
+	names := slices.Sorted(maps.Keys(a.physical))
+	tableColumns := make([]string, 0, len(names))
+	for _, name := range names {
+		tableColumns = append(tableColumns, fmt.Sprintf("%s AS %s",
+			pq.QuoteIdentifier(a.physical[name]),
+			pq.QuoteIdentifier(name)))
+	}
+
+	values := make([]string, len(a.columns))
+	reported := make([]string, len(a.columns))
+	notNull := make([]string, len(a.columns))
+	matches := make([]string, len(a.columns))
+	for i, col := range a.columns {
+		up := a.up[col]
+		if up == "" {
+			up = pq.QuoteIdentifier(col)
+		}
+		value := pq.QuoteIdentifier(fmt.Sprintf("value_%d", i))
+		values[i] = fmt.Sprintf("(%s) AS %s", up, value)
+		reported[i] = "o." + value
+		notNull[i] = fmt.Sprintf("o.%s IS NOT NULL", value)
+		matches[i] = fmt.Sprintf("r.%s = o.%s", pq.QuoteIdentifier(a.reference.Columns[i]), value)
+	}
+
+	rows, err := a.conn.QueryContext(ctx, fmt.Sprintf(`SELECT count(*) OVER (), concat_ws(', ', %[1]s)
+		FROM (SELECT %[2]s FROM (SELECT %[3]s FROM %[4]s) AS t) AS o
+		WHERE %[5]s
+		AND NOT EXISTS (SELECT 1 FROM %[6]s AS r WHERE %[7]s)
+		ORDER BY 2
+		LIMIT %[8]d`,
+		strings.Join(reported, ", "),
+		strings.Join(values, ", "),
+		strings.Join(tableColumns, ", "),
+		pq.QuoteIdentifier(a.table),
+		strings.Join(notNull, " AND "),
+		pq.QuoteIdentifier(a.reference.Table),
+		strings.Join(matches, " AND "),
+		maxReportedOrphans))
+	if err != nil {
+		return fmt.Errorf("checking foreign key %q for orphan rows: %w", a.constraint, err)
+	}
+	if rows == nil {
+		// rows is nil when a fake db is queried, in which case there are no
+		// orphan rows
+		return nil
+	}
+	defer rows.Close()
+
+	var count int
+	var orphans []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&count, &value); err != nil {
+			return fmt.Errorf("checking foreign key %q for orphan rows: %w", a.constraint, err)
+		}
+		orphans = append(orphans, "("+value+")")
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("checking foreign key %q for orphan rows: %w", a.constraint, err)
+	}
+
+	if len(orphans) > 0 {
+		return ForeignKeyOrphansError{
+			Table:           a.table,
+			Constraint:      a.constraint,
+			ReferencedTable: a.reference.Table,
+			Rows:            count,
+			Values:          strings.Join(orphans, ", "),
+		}
+	}
+	return nil
+}
+
+// maxReportedViolations is the maximum number of violating rows reported by
+// checkConstraintViolationsAction.
+const maxReportedViolations = 10
+
+// checkConstraintViolationsAction is a DBAction that reports the rows of a
+// table that violate a NOT VALID check constraint or foreign key, so that they
+// are reported before the constraint is validated.
+type checkConstraintViolationsAction struct {
+	conn       db.DB
+	table      string
+	constraint string
+}
+
+func NewCheckConstraintViolationsAction(conn db.DB, table, constraint string) *checkConstraintViolationsAction {
+	return &checkConstraintViolationsAction{
 		conn:       conn,
 		table:      table,
 		constraint: constraint,
-		indexName:  indexName,
+	}
+}
+
+func (a *checkConstraintViolationsAction) Execute(ctx context.Context) error {
+	// Look up the constraint in the catalog, as the columns of a foreign key
+	// must be matched to the referenced columns in the order in which they are
+	// declared
+	rows, err := a.conn.QueryContext(ctx, `SELECT c.contype,
+		coalesce(pg_get_expr(c.conbin, c.conrelid), ''),
+		CASE WHEN c.confrelid = 0 THEN '' ELSE c.confrelid::regclass::text END,
+		ARRAY(SELECT a.attname FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, n)
+			JOIN pg_attribute AS a ON a.attrelid = c.conrelid AND a.attnum = k.attnum ORDER BY k.n)::text[],
+		ARRAY(SELECT a.attname FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, n)
+			JOIN pg_attribute AS a ON a.attrelid = c.confrelid AND a.attnum = k.attnum ORDER BY k.n)::text[]
+		FROM pg_constraint AS c
+		WHERE c.conrelid = $1::regclass AND c.conname = $2`,
+		pq.QuoteIdentifier(a.table), a.constraint)
+	if err != nil {
+		return fmt.Errorf("checking constraint %q for violations: %w", a.constraint, err)
+	}
+	if rows == nil {
+		// rows is nil when a fake db is queried, in which case there are no
+		// violations
+		return nil
+	}
+
+	var found bool
+	var conType, expr, referencedTable string
+	var columns, referencedColumns []string
+	for rows.Next() {
+		found = true
+		if err := rows.Scan(&conType, &expr, &referencedTable, pq.Array(&columns), pq.Array(&referencedColumns)); err != nil {
+			rows.Close()
+			return fmt.Errorf("checking constraint %q for violations: %w", a.constraint, err)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("checking constraint %q for violations: %w", a.constraint, err)
+	}
+	if !found {
+		// the missing constraint is reported when it is validated
+		return nil
+	}
+
+	reported := make([]string, 0, len(columns))
+	for _, col := range columns {
+		reported = append(reported, "t."+pq.QuoteIdentifier(col))
+	}
+	if len(reported) == 0 {
+		reported = append(reported, "t.ctid")
+	}
+
+	var condition string
+	switch conType {
+	case "c":
+		condition = fmt.Sprintf("(%s) IS FALSE", expr)
+	case "f":
+		conditions := make([]string, 0, 2*len(columns))
+		matches := make([]string, 0, len(columns))
+		for i, col := range columns {
+			conditions = append(conditions, fmt.Sprintf("t.%s IS NOT NULL", pq.QuoteIdentifier(col)))
+			matches = append(matches, fmt.Sprintf("r.%s = t.%s", pq.QuoteIdentifier(referencedColumns[i]), pq.QuoteIdentifier(col)))
+		}
+		conditions = append(conditions, fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s AS r WHERE %s)",
+			referencedTable, strings.Join(matches, " AND ")))
+		condition = strings.Join(conditions, " AND ")
+	default:
+		// other constraints are always valid
+		return nil
+	}
+
+	rows, err = a.conn.QueryContext(ctx, fmt.Sprintf(`SELECT count(*) OVER (), concat_ws(', ', %[1]s)
+		FROM %[2]s AS t
+		WHERE %[3]s
+		ORDER BY 2
+		LIMIT %[4]d`,
+		strings.Join(reported, ", "),
+		pq.QuoteIdentifier(a.table),
+		condition,
+		maxReportedViolations))
+	if err != nil {
+		return fmt.Errorf("checking constraint %q for violations: %w", a.constraint, err)
+	}
+	defer rows.Close()
+
+	var count int
+	var violations []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&count, &value); err != nil {
+			return fmt.Errorf("checking constraint %q for violations: %w", a.constraint, err)
+		}
+		violations = append(violations, "("+value+")")
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("checking constraint %q for violations: %w", a.constraint, err)
+	}
+
+	if len(violations) > 0 {
+		return ConstraintViolationsError{
+			Table:      a.table,
+			Constraint: a.constraint,
+			Rows:       count,
+			Values:     strings.Join(violations, ", "),
+		}
+	}
+	return nil
+}
+
+// NonBlocking marks the action as non-blocking; the check only reads from the
+// table.
+func (a *checkConstraintViolationsAction) NonBlocking() {}
+
+type addConstraintUsingUniqueIndexAction struct {
+	conn              db.DB
+	table             string
+	constraint        string
+	indexName         string
+	initiallyDeferred bool
+	deferrable        bool
+}
+
+func NewAddConstraintUsingUniqueIndex(conn db.DB, table, constraint, indexName string, initiallyDeferred, deferrable bool) *addConstraintUsingUniqueIndexAction {
+	return &addConstraintUsingUniqueIndexAction{
+		conn:              conn,
+		table:             table,
+		constraint:        constraint,
+		indexName:         indexName,
+		initiallyDeferred: initiallyDeferred,
+		deferrable:        deferrable,
 	}
 }
 
 func (a *addConstraintUsingUniqueIndexAction) Execute(ctx context.Context) error {
-	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE IF EXISTS %s ADD CONSTRAINT %s UNIQUE USING INDEX %s",
+	writer := &ConstraintSQLWriter{InitiallyDeferred: a.initiallyDeferred, Deferrable: a.deferrable}
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE IF EXISTS %s ADD CONSTRAINT %s UNIQUE USING INDEX %s%s",
 		pq.QuoteIdentifier(a.table),
 		pq.QuoteIdentifier(a.constraint),
-		pq.QuoteIdentifier(a.indexName)))
+		pq.QuoteIdentifier(a.indexName),
+		writer.addDeferrable()))
 	return err
 }
 
 type addPrimaryKeyAction struct {
-	conn      db.DB
-	table     string
-	indexName string
+	conn              db.DB
+	table             string
+	indexName         string
+	initiallyDeferred bool
+	deferrable        bool
 }
 
-func NewAddPrimaryKeyAction(conn db.DB, table, indexName string) *addPrimaryKeyAction {
+func NewAddPrimaryKeyAction(conn db.DB, table, indexName string, initiallyDeferred, deferrable bool) *addPrimaryKeyAction {
 	return &addPrimaryKeyAction{
-		conn:      conn,
-		table:     table,
-		indexName: indexName,
+		conn:              conn,
+		table:             table,
+		indexName:         indexName,
+		initiallyDeferred: initiallyDeferred,
+		deferrable:        deferrable,
 	}
 }
 
 func (a *addPrimaryKeyAction) Execute(ctx context.Context) error {
-	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY USING INDEX %s",
+	writer := &ConstraintSQLWriter{InitiallyDeferred: a.initiallyDeferred, Deferrable: a.deferrable}
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY USING INDEX %s%s",
 		pq.QuoteIdentifier(a.table),
 		pq.QuoteIdentifier(a.indexName),
+		writer.addDeferrable(),
 	))
 	return err
 }
@@ -220,8 +829,15 @@ func (a *dropFunctionAction) Execute(ctx context.Context) error {
 	return err
 }
 
+// TriggerFunctions marks the action as removing pgroll triggers; dropping the
+// trigger functions also drops the triggers that use them.
+func (a *dropFunctionAction) TriggerFunctions() []string {
+	return a.functions
+}
+
 type createIndexConcurrentlyAction struct {
 	conn              db.DB
+	concurrently      bool
 	table             string
 	name              string
 	method            string
@@ -231,9 +847,13 @@ type createIndexConcurrentlyAction struct {
 	predicate         string
 }
 
+// NewCreateIndexConcurrentlyAction returns an action that builds an index
+// concurrently. The keys of columns are the SQL of each index element, either
+// a quoted column name or a parenthesized expression.
 func NewCreateIndexConcurrentlyAction(conn db.DB, table, name, method string, unique bool, columns map[string]IndexField, storageParameters, predicate string) *createIndexConcurrentlyAction {
 	return &createIndexConcurrentlyAction{
 		conn:              conn,
+		concurrently:      true,
 		table:             table,
 		name:              name,
 		method:            method,
@@ -249,6 +869,9 @@ func (a *createIndexConcurrentlyAction) Execute(ctx context.Context) error {
 	if a.unique {
 		stmtFmt = "CREATE UNIQUE INDEX CONCURRENTLY %s ON %s"
 	}
+	if !a.concurrently {
+		stmtFmt = strings.Replace(stmtFmt, " CONCURRENTLY", "", 1)
+	}
 	stmt := fmt.Sprintf(stmtFmt,
 		pq.QuoteIdentifier(a.name),
 		pq.QuoteIdentifier(a.table))
@@ -258,8 +881,8 @@ func (a *createIndexConcurrentlyAction) Execute(ctx context.Context) error {
 	}
 
 	colSQLs := make([]string, 0, len(a.columns))
-	for columnName, settings := range a.columns {
-		colSQL := pq.QuoteIdentifier(columnName)
+	for elem, settings := range a.columns {
+		colSQL := elem
 		// deparse collations
 		if settings.Collate != "" {
 			colSQL += " COLLATE " + settings.Collate
@@ -291,6 +914,104 @@ func (a *createIndexConcurrentlyAction) Execute(ctx context.Context) error {
 		stmt += fmt.Sprintf(" WHERE %s", a.predicate)
 	}
 	_, err := a.conn.ExecContext(ctx, stmt)
+	if err != nil && a.concurrently {
+		// A failed concurrent build leaves an INVALID index behind, which would
+		// make a retry fail because the index already exists
+		return errors.Join(err, a.dropInvalidIndex(context.WithoutCancel(ctx)))
+	}
+	return err
+}
+
+// dropInvalidIndex drops the index if it was left INVALID by a failed
+// concurrent build. A valid index of the same name is left in place.
+func (a *createIndexConcurrentlyAction) dropInvalidIndex(ctx context.Context) error {
+	rows, err := a.conn.QueryContext(ctx, `SELECT EXISTS(
+			SELECT * FROM pg_catalog.pg_index
+			WHERE indexrelid = to_regclass($1) AND NOT indisvalid
+			)`, pq.QuoteIdentifier(a.name))
+	if err != nil {
+		return fmt.Errorf("getting invalid index with name %q: %w", a.name, err)
+	}
+	if rows == nil {
+		// rows is nil when a fake db is queried, in which case there is no
+		// index to drop
+		return nil
+	}
+	var invalid bool
+	if err := db.ScanFirstValue(rows, &invalid); err != nil {
+		return fmt.Errorf("scanning invalid index with name %q: %w", a.name, err)
+	}
+	if !invalid {
+		return nil
+	}
+
+	_, err = a.conn.ExecContext(ctx, fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", pq.QuoteIdentifier(a.name)))
+	if err != nil {
+		return fmt.Errorf("failed to drop invalid index %q: %w", a.name, err)
+	}
+	return nil
+}
+
+// WithoutConcurrently makes the action build the index without CONCURRENTLY.
+func (a *createIndexConcurrentlyAction) WithoutConcurrently() {
+	a.concurrently = false
+}
+
+// IndexName marks the action as building an index.
+func (a *createIndexConcurrentlyAction) IndexName() string {
+	return a.name
+}
+
+// alterDefaultPrivilegesAction is a DBAction that grants or revokes the
+// privileges given to objects created in the future.
+type alterDefaultPrivilegesAction struct {
+	conn       db.DB
+	role       string
+	schema     string
+	grant      bool
+	privileges []string
+	objectType string
+	grantee    string
+}
+
+func NewAlterDefaultPrivilegesAction(conn db.DB, role, schema string, grant bool, privileges []string, objectType, grantee string) *alterDefaultPrivilegesAction {
+	return &alterDefaultPrivilegesAction{
+		conn:       conn,
+		role:       role,
+		schema:     schema,
+		grant:      grant,
+		privileges: privileges,
+		objectType: objectType,
+		grantee:    grantee,
+	}
+}
+
+func (a *alterDefaultPrivilegesAction) Execute(ctx context.Context) error {
+	sql := "ALTER DEFAULT PRIVILEGES"
+	if a.role != "" {
+		sql += " FOR ROLE " + pq.QuoteIdentifier(a.role)
+	}
+	if a.schema != "" {
+		sql += " IN SCHEMA " + pq.QuoteIdentifier(a.schema)
+	}
+
+	privileges := make([]string, len(a.privileges))
+	for i, p := range a.privileges {
+		privileges[i] = strings.ToUpper(p)
+	}
+
+	grantee := pq.QuoteIdentifier(a.grantee)
+	if strings.EqualFold(a.grantee, "PUBLIC") {
+		grantee = "PUBLIC"
+	}
+
+	if a.grant {
+		sql += fmt.Sprintf(" GRANT %s ON %s TO %s", strings.Join(privileges, ", "), strings.ToUpper(a.objectType), grantee)
+	} else {
+		sql += fmt.Sprintf(" REVOKE %s ON %s FROM %s", strings.Join(privileges, ", "), strings.ToUpper(a.objectType), grantee)
+	}
+
+	_, err := a.conn.ExecContext(ctx, sql)
 	return err
 }
 
@@ -321,6 +1042,58 @@ func (a *commentColumnAction) Execute(ctx context.Context) error {
 	return err
 }
 
+// alterColumnStorageAction is a DBAction that sets the storage mode of a
+// column.
+type alterColumnStorageAction struct {
+	conn    db.DB
+	table   string
+	column  string
+	storage string
+}
+
+func NewAlterColumnStorageAction(conn db.DB, table, column, storage string) *alterColumnStorageAction {
+	return &alterColumnStorageAction{
+		conn:    conn,
+		table:   table,
+		column:  column,
+		storage: storage,
+	}
+}
+
+func (a *alterColumnStorageAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET STORAGE %s",
+		pq.QuoteIdentifier(a.table),
+		pq.QuoteIdentifier(a.column),
+		a.storage))
+	return err
+}
+
+// alterColumnCompressionAction is a DBAction that sets the compression
+// method of a column.
+type alterColumnCompressionAction struct {
+	conn        db.DB
+	table       string
+	column      string
+	compression string
+}
+
+func NewAlterColumnCompressionAction(conn db.DB, table, column, compression string) *alterColumnCompressionAction {
+	return &alterColumnCompressionAction{
+		conn:        conn,
+		table:       table,
+		column:      column,
+		compression: compression,
+	}
+}
+
+func (a *alterColumnCompressionAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET COMPRESSION %s",
+		pq.QuoteIdentifier(a.table),
+		pq.QuoteIdentifier(a.column),
+		a.compression))
+	return err
+}
+
 // commentTableAction is a DBAction that adds a comment to a table.
 type commentTableAction struct {
 	conn    db.DB
@@ -345,6 +1118,35 @@ func (a *commentTableAction) Execute(ctx context.Context) error {
 	return err
 }
 
+// alterOwnerAction is a DBAction that changes the owner of a table, type or
+// other object.
+type alterOwnerAction struct {
+	conn   db.DB
+	object OwnedObject
+	owner  string
+}
+
+func NewAlterOwnerAction(conn db.DB, object OwnedObject, owner string) *alterOwnerAction {
+	return &alterOwnerAction{
+		conn:   conn,
+		object: object,
+		owner:  owner,
+	}
+}
+
+// NewAlterTableOwnerAction returns an action that changes the owner of a table.
+func NewAlterTableOwnerAction(conn db.DB, table, owner string) *alterOwnerAction {
+	return NewAlterOwnerAction(conn, OwnedObject{Type: "TABLE", Name: table}, owner)
+}
+
+func (a *alterOwnerAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER %s %s OWNER TO %s",
+		a.object.Type,
+		pq.QuoteIdentifier(a.object.Name),
+		pq.QuoteIdentifier(a.owner)))
+	return err
+}
+
 func commentToSQL(comment *string) string {
 	if comment == nil {
 		return "NULL"
@@ -370,6 +1172,11 @@ func NewCreateUniqueIndexConcurrentlyAction(conn db.DB, schemaName, indexName, t
 	}
 }
 
+// IndexName marks the action as building an index.
+func (a *createUniqueIndexConcurrentlyAction) IndexName() string {
+	return a.indexName
+}
+
 func (a *createUniqueIndexConcurrentlyAction) Execute(ctx context.Context) error {
 	quotedQualifiedIndexName := pq.QuoteIdentifier(a.indexName)
 	if a.schemaName != "" {
@@ -422,6 +1229,11 @@ func (a *createUniqueIndexConcurrentlyAction) Execute(ctx context.Context) error
 	return fmt.Errorf("failed to create unique index %q", a.indexName)
 }
 
+// NonBlocking marks the action as non-blocking; building an index
+// concurrently takes a SHARE UPDATE EXCLUSIVE lock, which does not block reads
+// or writes.
+func (a *createUniqueIndexConcurrentlyAction) NonBlocking() {}
+
 func (a *createUniqueIndexConcurrentlyAction) getCreateUniqueIndexConcurrentlySQL() string {
 	// create unique index concurrently
 	qualifiedTableName := pq.QuoteIdentifier(a.tableName)
@@ -481,50 +1293,161 @@ func (a *createUniqueIndexConcurrentlyAction) isIndexValid(ctx context.Context,
 	return isValid, nil
 }
 
-// createTableAction is a DBAction that creates a table.
+// createTableAction is a DBAction that creates a table. The table is
+// partitioned if partitionBy, the SQL of the partition key, is set.
 type createTableAction struct {
 	conn        db.DB
 	table       string
 	columns     string
 	constraints string
+	partitionBy string
 }
 
-func NewCreateTableAction(conn db.DB, table, columns, constraints string) *createTableAction {
+func NewCreateTableAction(conn db.DB, table, columns, constraints, partitionBy string) *createTableAction {
 	return &createTableAction{
 		conn:        conn,
 		table:       table,
 		columns:     columns,
 		constraints: constraints,
+		partitionBy: partitionBy,
 	}
 }
 
 func (a *createTableAction) Execute(ctx context.Context) error {
-	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s %s)",
+	sql := fmt.Sprintf("CREATE TABLE %s (%s %s)",
 		pq.QuoteIdentifier(a.table),
 		a.columns,
-		a.constraints))
+		a.constraints)
+	if a.partitionBy != "" {
+		sql += " PARTITION BY " + a.partitionBy
+	}
+	_, err := a.conn.ExecContext(ctx, sql)
+	return err
+}
+
+// createPartitionAction is a DBAction that creates a partition of a
+// partitioned table, either with the given bound or as its default partition.
+type createPartitionAction struct {
+	conn      db.DB
+	partition string
+	table     string
+	bound     string
+	isDefault bool
+}
+
+func NewCreatePartitionAction(conn db.DB, partition, table, bound string, isDefault bool) *createPartitionAction {
+	return &createPartitionAction{
+		conn:      conn,
+		partition: partition,
+		table:     table,
+		bound:     bound,
+		isDefault: isDefault,
+	}
+}
+
+func (a *createPartitionAction) Execute(ctx context.Context) error {
+	bound := "FOR VALUES " + a.bound
+	if a.isDefault {
+		bound = "DEFAULT"
+	}
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s PARTITION OF %s %s",
+		pq.QuoteIdentifier(a.partition),
+		pq.QuoteIdentifier(a.table),
+		bound))
 	return err
 }
 
+// createTableAsAction is a DBAction that creates a table from the results of
+// a query. The types of the table's columns are inferred by Postgres, so once
+// the table is created its columns are read back and recorded in the
+// in-memory schema.
+type createTableAsAction struct {
+	conn       db.DB
+	table      string
+	query      string
+	withNoData bool
+	schema     *schema.Schema
+}
+
+func NewCreateTableAsAction(conn db.DB, table, query string, withNoData bool, s *schema.Schema) *createTableAsAction {
+	return &createTableAsAction{
+		conn:       conn,
+		table:      table,
+		query:      query,
+		withNoData: withNoData,
+		schema:     s,
+	}
+}
+
+func (a *createTableAsAction) Execute(ctx context.Context) error {
+	sql := fmt.Sprintf("CREATE TABLE %s AS %s", pq.QuoteIdentifier(a.table), a.query)
+	if a.withNoData {
+		sql += " WITH NO DATA"
+	}
+	if _, err := a.conn.ExecContext(ctx, sql); err != nil {
+		return err
+	}
+
+	rows, err := a.conn.QueryContext(ctx, `SELECT attname, format_type(atttypid, atttypmod)
+		FROM pg_catalog.pg_attribute
+		WHERE attrelid = $1::regclass
+		AND attnum > 0
+		AND NOT attisdropped
+		ORDER BY attnum`, pq.QuoteIdentifier(a.table))
+	if err != nil {
+		return fmt.Errorf("reading columns of table %q: %w", a.table, err)
+	}
+	if rows == nil {
+		// if rows == nil && err != nil, then it means we have queried a fake db.
+		// In that case, the columns of the table are unknown.
+		return nil
+	}
+	defer rows.Close()
+
+	table := a.schema.GetTable(a.table)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return fmt.Errorf("reading columns of table %q: %w", a.table, err)
+		}
+		table.AddColumn(name, &schema.Column{
+			Name:     name,
+			Type:     typ,
+			Nullable: true,
+		})
+	}
+	return rows.Err()
+}
+
 // dropIndexAction is a DBAction that drops an index.
 type dropIndexAction struct {
-	conn db.DB
-	name string
+	conn         db.DB
+	name         string
+	concurrently bool
 }
 
 func NewDropIndexAction(conn db.DB, name string) *dropIndexAction {
 	return &dropIndexAction{
-		conn: conn,
-		name: name,
+		conn:         conn,
+		name:         name,
+		concurrently: true,
 	}
 }
 
 func (a *dropIndexAction) Execute(ctx context.Context) error {
-	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s",
-		pq.QuoteIdentifier(a.name)))
+	stmtFmt := "DROP INDEX CONCURRENTLY IF EXISTS %s"
+	if !a.concurrently {
+		stmtFmt = "DROP INDEX IF EXISTS %s"
+	}
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf(stmtFmt, pq.QuoteIdentifier(a.name)))
 	return err
 }
 
+// WithoutConcurrently makes the action drop the index without CONCURRENTLY.
+func (a *dropIndexAction) WithoutConcurrently() {
+	a.concurrently = false
+}
+
 // DropTableAction is a DBAction that drops a table.
 type DropTableAction struct {
 	conn  db.DB
@@ -559,13 +1482,25 @@ func NewValidateConstraintAction(conn db.DB, table, constraint string) *validate
 	}
 }
 
-func (a *validateConstraintAction) Execute(ctx context.Context) error {
-	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE IF EXISTS %s VALIDATE CONSTRAINT %s",
+func (a *validateConstraintAction) Execute(ctx context.Context) error {
+	return a.ExecuteWithConn(ctx, a.conn)
+}
+
+func (a *validateConstraintAction) ExecuteWithConn(ctx context.Context, conn db.DB) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE IF EXISTS %s VALIDATE CONSTRAINT %s",
 		pq.QuoteIdentifier(a.table),
 		pq.QuoteIdentifier(a.constraint)))
 	return err
 }
 
+// NonBlocking marks the action as non-blocking; validating a constraint takes
+// a SHARE UPDATE EXCLUSIVE lock, which does not block reads or writes.
+func (a *validateConstraintAction) NonBlocking() {}
+
+func (a *validateConstraintAction) Table() string { return a.table }
+
+func (a *validateConstraintAction) Constraint() string { return a.constraint }
+
 // CreateCheckConstraintAction creates a check constraint on a table.
 type CreateCheckConstraintAction struct {
 	conn           db.DB
@@ -596,7 +1531,7 @@ func (a *CreateCheckConstraintAction) Execute(ctx context.Context) error {
 		Name:           a.constraint,
 		SkipValidation: a.skipValidation,
 	}
-	sql += writer.WriteCheck(rewriteCheckExpression(a.check, a.columns...), a.noInherit)
+	sql += writer.WriteCheck(rewriteCheckExpression(NamingFrom(ctx), a.check, a.columns...), a.noInherit)
 	_, err := a.conn.ExecContext(ctx, sql)
 	return err
 }
@@ -606,9 +1541,9 @@ func (a *CreateCheckConstraintAction) Execute(ctx context.Context) error {
 // On migration start, however, the check is actually applied to the new (temporary)
 // column.
 // This function naively rewrites the check expression to apply to the new column.
-func rewriteCheckExpression(check string, columns ...string) string {
+func rewriteCheckExpression(naming backfill.Naming, check string, columns ...string) string {
 	for _, col := range columns {
-		check = strings.ReplaceAll(check, col, TemporaryName(col))
+		check = strings.ReplaceAll(check, col, naming.TemporaryName(col))
 	}
 	return check
 }
@@ -695,7 +1630,7 @@ func getSequenceNameForColumn(ctx context.Context, conn db.DB, tableName, column
 		SELECT pg_get_serial_sequence('%s', '%s')
 	`, pq.QuoteIdentifier(tableName), columnName)
 	rows, err := conn.QueryContext(ctx, query)
-	if err != nil {
+	if err != nil || rows == nil {
 		return ""
 	}
 	defer rows.Close()
@@ -749,6 +1684,27 @@ func (a *setNotNullAction) Execute(ctx context.Context) error {
 	return err
 }
 
+type dropNotNullAction struct {
+	conn   db.DB
+	table  string
+	column string
+}
+
+func NewDropNotNullAction(conn db.DB, table, column string) *dropNotNullAction {
+	return &dropNotNullAction{
+		conn:   conn,
+		table:  table,
+		column: column,
+	}
+}
+
+func (a *dropNotNullAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE IF EXISTS %s ALTER COLUMN %s DROP NOT NULL",
+		pq.QuoteIdentifier(a.table),
+		pq.QuoteIdentifier(a.column)))
+	return err
+}
+
 type setDefaultAction struct {
 	conn         db.DB
 	table        string
@@ -811,6 +1767,23 @@ func (a *rawSQLAction) Execute(ctx context.Context) error {
 	return err
 }
 
+// Split returns an action for each of the statements in the SQL.
+func (a *rawSQLAction) Split() ([]DBAction, error) {
+	stmts, err := pgq.SplitWithScanner(a.sql, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to split SQL into statements: %w", err)
+	}
+
+	actions := make([]DBAction, 0, len(stmts))
+	for _, stmt := range stmts {
+		if stmt == "" {
+			continue
+		}
+		actions = append(actions, NewRawSQLAction(a.conn, stmt))
+	}
+	return actions, nil
+}
+
 type setReplicaIdentityAction struct {
 	conn     db.DB
 	table    string
@@ -840,3 +1813,490 @@ func (a *setReplicaIdentityAction) Execute(ctx context.Context) error {
 		identitySQL))
 	return err
 }
+
+// createTypeAction is a DBAction that creates a composite type.
+type createTypeAction struct {
+	conn       db.DB
+	name       string
+	attributes []CompositeTypeAttribute
+}
+
+func NewCreateTypeAction(conn db.DB, name string, attributes []CompositeTypeAttribute) *createTypeAction {
+	return &createTypeAction{
+		conn:       conn,
+		name:       name,
+		attributes: attributes,
+	}
+}
+
+func (a *createTypeAction) Execute(ctx context.Context) error {
+	attrs := make([]string, len(a.attributes))
+	for i, attr := range a.attributes {
+		attrs[i] = fmt.Sprintf("%s %s", pq.QuoteIdentifier(attr.Name), attr.Type)
+	}
+
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("CREATE TYPE %s AS (%s)",
+		pq.QuoteIdentifier(a.name),
+		strings.Join(attrs, ", ")))
+	return err
+}
+
+// dropTypeAction is a DBAction that drops a type. Unless cascade is set, the
+// type is only dropped if no other objects depend on it.
+type dropTypeAction struct {
+	conn    db.DB
+	name    string
+	cascade bool
+}
+
+func NewDropTypeAction(conn db.DB, name string, cascade bool) *dropTypeAction {
+	return &dropTypeAction{
+		conn:    conn,
+		name:    name,
+		cascade: cascade,
+	}
+}
+
+func (a *dropTypeAction) Execute(ctx context.Context) error {
+	if a.cascade {
+		_, err := a.conn.ExecContext(ctx, fmt.Sprintf("DROP TYPE IF EXISTS %s CASCADE",
+			pq.QuoteIdentifier(a.name)))
+		return err
+	}
+
+	dependents, err := a.dependents(ctx)
+	if err != nil {
+		return err
+	}
+	if len(dependents) > 0 {
+		return TypeHasDependentsError{Name: a.name, Dependents: strings.Join(dependents, ", ")}
+	}
+
+	_, err = a.conn.ExecContext(ctx, fmt.Sprintf("DROP TYPE IF EXISTS %s",
+		pq.QuoteIdentifier(a.name)))
+	return err
+}
+
+// dependents returns descriptions of the objects that depend on the type,
+// ignoring those that are dropped along with it (eg its array type).
+func (a *dropTypeAction) dependents(ctx context.Context) ([]string, error) {
+	rows, err := a.conn.QueryContext(ctx, `SELECT pg_describe_object(d.classid, d.objid, d.objsubid)
+		FROM pg_catalog.pg_depend d
+		JOIN pg_catalog.pg_type t ON t.oid = d.refobjid
+		WHERE t.typname = $1
+		AND t.typnamespace = current_schema()::regnamespace
+		AND d.refclassid = 'pg_catalog.pg_type'::regclass
+		AND d.deptype = 'n'
+		ORDER BY 1`, a.name)
+	if err != nil {
+		return nil, fmt.Errorf("getting dependents of type %q: %w", a.name, err)
+	}
+	if rows == nil {
+		// if rows == nil && err != nil, then it means we have queried a fake db.
+		// In that case, there are no dependents.
+		return nil, nil
+	}
+	defer rows.Close()
+
+	var dependents []string
+	for rows.Next() {
+		var dependent string
+		if err := rows.Scan(&dependent); err != nil {
+			return nil, fmt.Errorf("scanning dependents of type %q: %w", a.name, err)
+		}
+		dependents = append(dependents, dependent)
+	}
+
+	return dependents, rows.Err()
+}
+
+// alterTriggerAction is a DBAction that enables or disables a trigger.
+type alterTriggerAction struct {
+	conn    db.DB
+	table   string
+	trigger string
+	state   string
+}
+
+func NewAlterTriggerAction(conn db.DB, table, trigger, state string) *alterTriggerAction {
+	return &alterTriggerAction{
+		conn:    conn,
+		table:   table,
+		trigger: trigger,
+		state:   state,
+	}
+}
+
+func (a *alterTriggerAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s %s TRIGGER %s",
+		pq.QuoteIdentifier(a.table),
+		a.state,
+		pq.QuoteIdentifier(a.trigger)))
+	return err
+}
+
+// createForeignTableAction is a DBAction that creates a foreign table.
+type createForeignTableAction struct {
+	conn    db.DB
+	name    string
+	server  string
+	columns []ForeignTableColumn
+	options map[string]string
+}
+
+func NewCreateForeignTableAction(conn db.DB, name, server string, columns []ForeignTableColumn, options map[string]string) *createForeignTableAction {
+	return &createForeignTableAction{
+		conn:    conn,
+		name:    name,
+		server:  server,
+		columns: columns,
+		options: options,
+	}
+}
+
+func (a *createForeignTableAction) Execute(ctx context.Context) error {
+	cols := make([]string, len(a.columns))
+	for i, col := range a.columns {
+		cols[i] = fmt.Sprintf("%s %s", pq.QuoteIdentifier(col.Name), col.Type)
+		if !col.Nullable {
+			cols[i] += " NOT NULL"
+		}
+	}
+
+	sql := fmt.Sprintf("CREATE FOREIGN TABLE %s (%s) SERVER %s",
+		pq.QuoteIdentifier(a.name),
+		strings.Join(cols, ", "),
+		pq.QuoteIdentifier(a.server))
+
+	if len(a.options) > 0 {
+		keys := slices.Sorted(maps.Keys(a.options))
+		opts := make([]string, len(keys))
+		for i, k := range keys {
+			opts[i] = fmt.Sprintf("%s %s", pq.QuoteIdentifier(k), pq.QuoteLiteral(a.options[k]))
+		}
+		sql += fmt.Sprintf(" OPTIONS (%s)", strings.Join(opts, ", "))
+	}
+
+	_, err := a.conn.ExecContext(ctx, sql)
+	return err
+}
+
+// dropForeignTableAction is a DBAction that drops a foreign table.
+type dropForeignTableAction struct {
+	conn db.DB
+	name string
+}
+
+func NewDropForeignTableAction(conn db.DB, name string) *dropForeignTableAction {
+	return &dropForeignTableAction{
+		conn: conn,
+		name: name,
+	}
+}
+
+func (a *dropForeignTableAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("DROP FOREIGN TABLE IF EXISTS %s",
+		pq.QuoteIdentifier(a.name)))
+	return err
+}
+
+// createRuleAction is a DBAction that creates a rule on a table.
+type createRuleAction struct {
+	conn    db.DB
+	table   string
+	name    string
+	event   string
+	where   string
+	instead bool
+	command string
+}
+
+func NewCreateRuleAction(conn db.DB, table, name, event, where string, instead bool, command string) *createRuleAction {
+	return &createRuleAction{
+		conn:    conn,
+		table:   table,
+		name:    name,
+		event:   event,
+		where:   where,
+		instead: instead,
+		command: command,
+	}
+}
+
+func (a *createRuleAction) Execute(ctx context.Context) error {
+	sql := fmt.Sprintf("CREATE RULE %s AS ON %s TO %s",
+		pq.QuoteIdentifier(a.name),
+		a.event,
+		pq.QuoteIdentifier(a.table))
+	if a.where != "" {
+		sql += fmt.Sprintf(" WHERE %s", a.where)
+	}
+
+	if a.instead {
+		sql += " DO INSTEAD"
+	} else {
+		sql += " DO ALSO"
+	}
+
+	// Parentheses allow the command to be a list of statements, but can't
+	// enclose NOTHING
+	command := strings.TrimSuffix(strings.TrimSpace(a.command), ";")
+	if strings.EqualFold(command, "NOTHING") {
+		sql += " NOTHING"
+	} else {
+		sql += fmt.Sprintf(" (%s)", command)
+	}
+
+	_, err := a.conn.ExecContext(ctx, sql)
+	return err
+}
+
+// dropRuleAction is a DBAction that drops a rule from a table.
+type dropRuleAction struct {
+	conn  db.DB
+	table string
+	name  string
+}
+
+func NewDropRuleAction(conn db.DB, table, name string) *dropRuleAction {
+	return &dropRuleAction{
+		conn:  conn,
+		table: table,
+		name:  name,
+	}
+}
+
+func (a *dropRuleAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("DROP RULE IF EXISTS %s ON %s",
+		pq.QuoteIdentifier(a.name),
+		pq.QuoteIdentifier(a.table)))
+	return err
+}
+
+// alterRuleAction is a DBAction that enables or disables a rule.
+type alterRuleAction struct {
+	conn  db.DB
+	table string
+	rule  string
+	state string
+}
+
+func NewAlterRuleAction(conn db.DB, table, rule, state string) *alterRuleAction {
+	return &alterRuleAction{
+		conn:  conn,
+		table: table,
+		rule:  rule,
+		state: state,
+	}
+}
+
+func (a *alterRuleAction) Execute(ctx context.Context) error {
+	_, err := a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s %s RULE %s",
+		pq.QuoteIdentifier(a.table),
+		a.state,
+		pq.QuoteIdentifier(a.rule)))
+	return err
+}
+
+// truncateTableAction is a DBAction that truncates a table, optionally
+// copying its rows into a backup table first.
+type truncateTableAction struct {
+	conn            db.DB
+	table           string
+	backup          string
+	restartIdentity bool
+	cascade         bool
+}
+
+func NewTruncateTableAction(conn db.DB, table, backup string, restartIdentity, cascade bool) *truncateTableAction {
+	return &truncateTableAction{
+		conn:            conn,
+		table:           table,
+		backup:          backup,
+		restartIdentity: restartIdentity,
+		cascade:         cascade,
+	}
+}
+
+func (a *truncateTableAction) Execute(ctx context.Context) error {
+	if a.backup != "" {
+		_, err := a.conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s AS TABLE %s",
+			pq.QuoteIdentifier(a.backup),
+			pq.QuoteIdentifier(a.table)))
+		if err != nil {
+			return err
+		}
+	}
+
+	sql := fmt.Sprintf("TRUNCATE TABLE %s", pq.QuoteIdentifier(a.table))
+	if a.restartIdentity {
+		sql += " RESTART IDENTITY"
+	}
+	if a.cascade {
+		sql += " CASCADE"
+	}
+	_, err := a.conn.ExecContext(ctx, sql)
+	return err
+}
+
+// restoreTruncatedTableAction is a DBAction that replaces the rows of a
+// truncated table with those copied into its backup table, and drops the
+// backup table. Nothing is done if the backup table doesn't exist.
+type restoreTruncatedTableAction struct {
+	conn            db.DB
+	table           string
+	backup          string
+	restartIdentity bool
+}
+
+func NewRestoreTruncatedTableAction(conn db.DB, table, backup string, restartIdentity bool) *restoreTruncatedTableAction {
+	return &restoreTruncatedTableAction{
+		conn:            conn,
+		table:           table,
+		backup:          backup,
+		restartIdentity: restartIdentity,
+	}
+}
+
+func (a *restoreTruncatedTableAction) Execute(ctx context.Context) error {
+	exists, err := a.backupExists(ctx)
+	if err != nil || !exists {
+		return err
+	}
+
+	// Generated columns can't be written to, so only the other columns are
+	// restored
+	columns, err := a.queryColumns(ctx, `
+	  SELECT quote_ident(attname)
+	  FROM pg_attribute
+	  WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped AND attgenerated = ''
+	  ORDER BY attnum`)
+	if err != nil {
+		return err
+	}
+	columnList := strings.Join(columns, ", ")
+
+	// Discard the rows written while the migration was active, as the table
+	// was empty in the new version of the schema
+	_, err = a.conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", pq.QuoteIdentifier(a.table)))
+	if err != nil {
+		return err
+	}
+
+	_, err = a.conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE SELECT %s FROM %s",
+		pq.QuoteIdentifier(a.table),
+		columnList,
+		columnList,
+		pq.QuoteIdentifier(a.backup)))
+	if err != nil {
+		return err
+	}
+
+	// Move the sequences that were restarted past the restored values
+	if a.restartIdentity {
+		columns, err := a.queryColumns(ctx, `
+		  SELECT attname
+		  FROM pg_attribute
+		  WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped
+		    AND pg_get_serial_sequence($1, attname) IS NOT NULL`)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			_, err := a.conn.ExecContext(ctx, fmt.Sprintf("SELECT setval(pg_get_serial_sequence(%s, %s), max(%s)) FROM %s HAVING max(%s) IS NOT NULL",
+				pq.QuoteLiteral(pq.QuoteIdentifier(a.table)),
+				pq.QuoteLiteral(column),
+				pq.QuoteIdentifier(column),
+				pq.QuoteIdentifier(a.table),
+				pq.QuoteIdentifier(column)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = a.conn.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", pq.QuoteIdentifier(a.backup)))
+	return err
+}
+
+func (a *restoreTruncatedTableAction) backupExists(ctx context.Context) (bool, error) {
+	rows, err := a.conn.QueryContext(ctx, "SELECT to_regclass($1) IS NOT NULL", pq.QuoteIdentifier(a.backup))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var exists bool
+	if err := db.ScanFirstValue(rows, &exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// queryColumns runs a query about the table's columns, passing it the quoted
+// name of the table, and returns the single value of each row.
+func (a *restoreTruncatedTableAction) queryColumns(ctx context.Context, query string) ([]string, error) {
+	rows, err := a.conn.QueryContext(ctx, query, pq.QuoteIdentifier(a.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// alterTableInheritAction is a DBAction that adds a table to, or removes it
+// from, the children of a parent table. Nothing is done if the table already
+// is, or isn't, a child of the parent.
+type alterTableInheritAction struct {
+	conn    db.DB
+	table   string
+	parent  string
+	inherit bool
+}
+
+func NewAlterTableInheritAction(conn db.DB, table, parent string, inherit bool) *alterTableInheritAction {
+	return &alterTableInheritAction{
+		conn:    conn,
+		table:   table,
+		parent:  parent,
+		inherit: inherit,
+	}
+}
+
+func (a *alterTableInheritAction) Execute(ctx context.Context) error {
+	rows, err := a.conn.QueryContext(ctx, `SELECT EXISTS (
+	  SELECT 1 FROM pg_inherits WHERE inhrelid = $1::regclass AND inhparent = $2::regclass)`,
+		pq.QuoteIdentifier(a.table),
+		pq.QuoteIdentifier(a.parent))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var inherits bool
+	if err := db.ScanFirstValue(rows, &inherits); err != nil {
+		return err
+	}
+	if inherits == a.inherit {
+		return nil
+	}
+
+	action := "INHERIT"
+	if !a.inherit {
+		action = "NO INHERIT"
+	}
+	_, err = a.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s %s %s",
+		pq.QuoteIdentifier(a.table),
+		action,
+		pq.QuoteIdentifier(a.parent)))
+	return err
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// defaultsNode describes the default values declared in the JSON schema for
+// the fields of an object, and for the fields of any nested objects.
+type defaultsNode struct {
+	// defaults maps field names to their default values
+	defaults map[string]any
+
+	// properties maps field names to the defaults for the nested object (or
+	// array of objects) held in that field
+	properties map[string]*defaultsNode
+}
+
+// applyDefaults returns the JSON object `data` with the default value set for
+// any field that is omitted. Fields that are set, including those explicitly
+// set to null, are left unchanged. If `data` is an array, defaults are applied
+// to each of its elements.
+func (n *defaultsNode) applyDefaults(data json.RawMessage) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if n == nil || len(trimmed) == 0 {
+		return data, nil
+	}
+
+	switch trimmed[0] {
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			withDefaults, err := n.applyDefaults(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = withDefaults
+		}
+		return json.Marshal(items)
+
+	case '{':
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		for name, value := range n.defaults {
+			if _, ok := fields[name]; ok {
+				continue
+			}
+			v, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = v
+		}
+		for name, child := range n.properties {
+			value, ok := fields[name]
+			if !ok {
+				continue
+			}
+			withDefaults, err := child.applyDefaults(value)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = withDefaults
+		}
+		return json.Marshal(fields)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestSchemaDefaultsAreAppliedToOmittedFields(t *testing.T) {
+	t.Parallel()
+
+	t.Run("identity columns default to GENERATED ALWAYS", func(t *testing.T) {
+		ops := unmarshalOperations(t, `[{"create_table": {
+			"name": "users",
+			"columns": [
+				{"name": "id", "type": "integer", "pk": true, "generated": {"identity": {}}},
+				{"name": "other_id", "type": "integer", "generated": {"identity": {"user_specified_values": "BY DEFAULT"}}}
+			]
+		}}]`)
+
+		op := ops[0].(*migrations.OpCreateTable)
+		assert.Equal(t, migrations.ColumnGeneratedIdentityUserSpecifiedValuesALWAYS, op.Columns[0].Generated.Identity.UserSpecifiedValues)
+		assert.Equal(t, migrations.ColumnGeneratedIdentityUserSpecifiedValuesBYDEFAULT, op.Columns[1].Generated.Identity.UserSpecifiedValues)
+	})
+
+	t.Run("foreign key references default to SIMPLE and NO ACTION", func(t *testing.T) {
+		ops := unmarshalOperations(t, `[{"add_column": {
+			"table": "posts",
+			"column": {"name": "user_id", "type": "integer", "references": {"name": "fk_users", "table": "users", "column": "id", "on_update": "CASCADE"}}
+		}}]`)
+
+		ref := ops[0].(*migrations.OpAddColumn).Column.References
+		assert.Equal(t, migrations.ForeignKeyMatchTypeSIMPLE, ref.MatchType)
+		assert.Equal(t, migrations.ForeignKeyActionNOACTION, ref.OnDelete)
+		assert.Equal(t, migrations.ForeignKeyActionCASCADE, ref.OnUpdate)
+	})
+
+	t.Run("indexes default to btree", func(t *testing.T) {
+		ops := unmarshalOperations(t, `[{"create_index": {"name": "idx_users_name", "table": "users", "columns": {"name": {}}}}]`)
+
+		assert.Equal(t, migrations.OpCreateIndexMethodBtree, ops[0].(*migrations.OpCreateIndex).Method)
+	})
+
+	t.Run("null values are not replaced by defaults", func(t *testing.T) {
+		ops := unmarshalOperations(t, `[{"create_table": {
+			"name": "users",
+			"columns": [{"name": "id", "type": "integer", "generated": null}]
+		}}]`)
+
+		assert.Nil(t, ops[0].(*migrations.OpCreateTable).Columns[0].Generated)
+	})
+}
+
+func unmarshalOperations(t *testing.T, data string) migrations.Operations {
+	t.Helper()
+
+	var ops migrations.Operations
+	require.NoError(t, json.Unmarshal([]byte(data), &ops))
+	require.Len(t, ops, 1)
+
+	return ops
+}
@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// dependency is an object that an operation creates or requires, identified
+// by its kind (eg. "table" or "type") and name.
+type dependency struct {
+	kind string
+	name string
+}
+
+func (d dependency) String() string {
+	return fmt.Sprintf("%s %q", d.kind, d.name)
+}
+
+// SortOperations reorders the operations in the migration so that operations
+// creating a table or type run before the operations in the same migration that
+// depend on it. The relative order of independent operations is preserved.
+//
+// An OperationDependencyCycleError is returned if the operations depend on each
+// other in a cycle and cannot be ordered.
+func (m *Migration) SortOperations(s *schema.Schema) error {
+	deps := m.operationDependencies(s)
+
+	sorted := make(Operations, 0, len(m.Operations))
+	done := make([]bool, len(m.Operations))
+	for len(sorted) < len(m.Operations) {
+		// Pick the first remaining operation whose dependencies have all been
+		// placed, so that independent operations keep their original order.
+		next := -1
+		for i := range m.Operations {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				if !done[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			return OperationDependencyCycleError{Cycle: m.describeCycle(deps, done)}
+		}
+
+		sorted = append(sorted, m.Operations[next])
+		done[next] = true
+	}
+
+	m.Operations = sorted
+	return nil
+}
+
+// validateOperationOrder returns an OperationDependencyError for the first
+// operation that depends on an object created by a later operation in the
+// same migration.
+func (m *Migration) validateOperationOrder(s *schema.Schema) error {
+	creators := m.creators(s)
+
+	for i, op := range m.Operations {
+		for _, dep := range requiredObjects(op) {
+			c, ok := creators[dep]
+			if !ok || c <= i {
+				continue
+			}
+			return OperationDependencyError{
+				Operation:  fmt.Sprintf("operations[%d] (%s)", i, OperationName(op)),
+				Dependency: dep.String(),
+				CreatedBy:  fmt.Sprintf("operations[%d] (%s)", c, OperationName(m.Operations[c])),
+			}
+		}
+	}
+
+	return nil
+}
+
+// operationDependencies returns, for each operation in the migration, the
+// indexes of the other operations that must run before it.
+func (m *Migration) operationDependencies(s *schema.Schema) [][]int {
+	creators := m.creators(s)
+
+	deps := make([][]int, len(m.Operations))
+	for i, op := range m.Operations {
+		for _, dep := range requiredObjects(op) {
+			c, ok := creators[dep]
+			if !ok || c == i || slices.Contains(deps[i], c) {
+				continue
+			}
+			deps[i] = append(deps[i], c)
+		}
+	}
+
+	return deps
+}
+
+// creators maps each object created by the migration to the index of the
+// first operation that creates it. Tables that already exist in the schema are
+// not included, as no operation in the migration is needed to create them.
+func (m *Migration) creators(s *schema.Schema) map[dependency]int {
+	creators := make(map[dependency]int)
+	for i, op := range m.Operations {
+		for _, dep := range createdObjects(op) {
+			if dep.kind == "table" && s.GetTable(dep.name) != nil {
+				continue
+			}
+			if _, ok := creators[dep]; !ok {
+				creators[dep] = i
+			}
+		}
+	}
+	return creators
+}
+
+// describeCycle returns a description of a dependency cycle among the
+// operations that have not yet been placed, eg.
+// "operations[0] (create_table) -> operations[1] (create_table) -> operations[0] (create_table)".
+func (m *Migration) describeCycle(deps [][]int, done []bool) string {
+	// Every remaining operation has at least one unplaced dependency, so
+	// following those dependencies from any of them must eventually revisit
+	// an operation.
+	start := slices.Index(done, false)
+	visited := make(map[int]int)
+	path := []int{}
+	for i := start; ; {
+		if pos, ok := visited[i]; ok {
+			path = append(path[pos:], i)
+			break
+		}
+		visited[i] = len(path)
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if !done[j] {
+				i = j
+				break
+			}
+		}
+	}
+
+	parts := make([]string, 0, len(path))
+	for _, i := range path {
+		parts = append(parts, fmt.Sprintf("operations[%d] (%s)", i, OperationName(m.Operations[i])))
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// createdObjects returns the tables and types created by the operation.
+func createdObjects(op Operation) []dependency {
+	switch o := op.(type) {
+	case *OpCreateTable:
+		return []dependency{{kind: "table", name: o.Name}}
+	case *OpRenameTable:
+		return []dependency{{kind: "table", name: o.To}}
+	case *OpCreateType:
+		return []dependency{{kind: "type", name: o.Name}}
+	case *OpCreateForeignTable:
+		return []dependency{{kind: "table", name: o.Name}}
+	case *OpCreateTableAs:
+		return []dependency{{kind: "table", name: o.Name}}
+	}
+	return nil
+}
+
+// requiredObjects returns the tables and types that must exist before the
+// operation can run.
+func requiredObjects(op Operation) []dependency {
+	var deps []dependency
+	table := func(name string) {
+		if name != "" {
+			deps = append(deps, dependency{kind: "table", name: name})
+		}
+	}
+	columns := func(cols ...Column) {
+		for _, col := range cols {
+			deps = append(deps, dependency{kind: "type", name: col.Type})
+			if col.References != nil {
+				table(col.References.Table)
+			}
+		}
+	}
+
+	switch o := op.(type) {
+	case *OpCreateTable:
+		columns(o.Columns...)
+		for _, c := range o.Constraints {
+			if c.References != nil {
+				table(c.References.Table)
+			}
+		}
+		// A self-referencing foreign key does not depend on another operation
+		deps = slices.DeleteFunc(deps, func(d dependency) bool {
+			return d.kind == "table" && d.name == o.Name
+		})
+	case *OpAddColumn:
+		table(o.Table)
+		columns(o.Column)
+	case *OpAlterColumn:
+		table(o.Table)
+		if o.References != nil {
+			table(o.References.Table)
+		}
+		if o.Type != nil {
+			deps = append(deps, dependency{kind: "type", name: *o.Type})
+		}
+	case *OpAlterTrigger:
+		table(o.Table)
+	case *OpAttachInherit:
+		table(o.Table)
+		table(o.Parent)
+	case *OpCreateConstraint:
+		table(o.Table)
+		if o.References != nil {
+			table(o.References.Table)
+		}
+	case *OpCreateForeignTable:
+		for _, col := range o.Columns {
+			deps = append(deps, dependency{kind: "type", name: col.Type})
+		}
+	case *OpCreateIndex:
+		table(o.Table)
+	case *OpCreatePartition:
+		table(o.Table)
+	case *OpCreateRule:
+		table(o.Table)
+	case *OpDetachInherit:
+		table(o.Table)
+		table(o.Parent)
+	case *OpDropColumn:
+		table(o.Table)
+	case *OpDropConstraint:
+		table(o.Table)
+	case *OpDropMultiColumnConstraint:
+		table(o.Table)
+	case *OpDropRule:
+		table(o.Table)
+	case *OpDropTable:
+		table(o.Name)
+	case *OpDropForeignTable:
+		table(o.Name)
+	case *OpRenameColumn:
+		table(o.Table)
+	case *OpRenameConstraint:
+		table(o.Table)
+	case *OpRenameTable:
+		table(o.From)
+	case *OpSetReplicaIdentity:
+		table(o.Table)
+	case *OpTruncate:
+		table(o.Table)
+	case *OpValidateConstraint:
+		table(o.Table)
+	}
+
+	return deps
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/lib/pq"
+	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/schema"
 )
@@ -27,13 +28,16 @@ type columnToDuplicate struct {
 	column         *schema.Column
 	asName         string
 	withoutNotNull bool
+	withoutDefault bool
 	withType       string
+	withGenerated  string
 }
 
 // duplicatorStmtBuilder is a helper for building SQL statements to duplicate
 // columns and constraints in a table.
 type duplicatorStmtBuilder struct {
-	table *schema.Table
+	table  *schema.Table
+	naming backfill.Naming
 }
 
 const (
@@ -47,7 +51,6 @@ func NewColumnDuplicator(conn db.DB, table *schema.Table, columns ...*schema.Col
 	for _, column := range columns {
 		cols[column.Name] = &columnToDuplicate{
 			column:   column,
-			asName:   TemporaryName(column.Name),
 			withType: column.Type,
 		}
 	}
@@ -79,7 +82,23 @@ func (d *duplicator) WithoutNotNull(columnName string) *duplicator {
 	return d
 }
 
-// WithName sets the name of the new column.
+// WithoutDefault excludes the column's default value from being duplicated.
+func (d *duplicator) WithoutDefault(columnName string) *duplicator {
+	d.columns[columnName].withoutDefault = true
+	return d
+}
+
+// WithGenerated makes the new column a stored generated column with the given
+// generation expression. Generated columns can't have a default, so the
+// column's default value is not duplicated.
+func (d *duplicator) WithGenerated(columnName, expression string) *duplicator {
+	d.columns[columnName].withGenerated = expression
+	d.columns[columnName].withoutDefault = true
+	return d
+}
+
+// WithName sets the name of the new column. Columns are duplicated under
+// their temporary name unless they are given another one.
 func (d *duplicator) WithName(columnName, asName string) *duplicator {
 	d.columns[columnName].asName = asName
 	return d
@@ -88,12 +107,24 @@ func (d *duplicator) WithName(columnName, asName string) *duplicator {
 // Duplicate duplicates a column in the table, including all constraints and
 // comments.
 func (d *duplicator) Execute(ctx context.Context) error {
+	d.stmtBuilder.naming = NamingFrom(ctx)
+
 	colNames := make([]string, 0, len(d.columns))
 	for name, c := range d.columns {
 		colNames = append(colNames, name)
+		if c.asName == "" {
+			c.asName = d.stmtBuilder.naming.TemporaryName(name)
+		}
+
+		// Reuse the duplicated column if an earlier, interrupted attempt to start
+		// the migration has already created it
+		exists, err := shadowColumnExists(ctx, d.conn, d.stmtBuilder.table.Name, c.asName, c.withType)
+		if err != nil {
+			return err
+		}
 
 		// Duplicate the column with the new type
-		if sql := d.stmtBuilder.duplicateColumn(c.column, c.asName, c.withoutNotNull, c.withType); sql != "" {
+		if sql := d.stmtBuilder.duplicateColumn(c.column, c.asName, c.withoutNotNull, c.withType, c.withGenerated); !exists && sql != "" {
 			_, err := d.conn.ExecContext(ctx, sql)
 			if err != nil {
 				return err
@@ -101,7 +132,7 @@ func (d *duplicator) Execute(ctx context.Context) error {
 		}
 
 		// Duplicate the column's default value
-		if sql := d.stmtBuilder.duplicateDefault(c.column, c.asName); sql != "" {
+		if sql := d.stmtBuilder.duplicateDefault(c.column, c.asName); !c.withoutDefault && sql != "" {
 			_, err := d.conn.ExecContext(ctx, sql)
 			err = errorIgnoringErrorCode(err, dataTypeMismatchErrorCode)
 			if err != nil {
@@ -136,7 +167,7 @@ func (d *duplicator) Execute(ctx context.Context) error {
 			continue
 		}
 		if duplicatedMember, constraintColumns := d.stmtBuilder.allConstraintColumns(uc.Columns, colNames...); duplicatedMember {
-			action := NewCreateUniqueIndexConcurrentlyAction(d.conn, "", DuplicationName(uc.Name), d.stmtBuilder.table.Name, constraintColumns...)
+			action := NewCreateUniqueIndexConcurrentlyAction(d.conn, "", d.stmtBuilder.naming.DuplicationName(uc.Name), d.stmtBuilder.table.Name, constraintColumns...)
 			if err := action.Execute(ctx); err != nil {
 				return err
 			}
@@ -169,10 +200,14 @@ func (d *duplicatorStmtBuilder) duplicateCheckConstraints(withoutConstraint []st
 		if slices.Contains(withoutConstraint, cc.Name) {
 			continue
 		}
+		if d.table.ConstraintExists(d.naming.DuplicationName(cc.Name)) {
+			// already duplicated by an earlier attempt to start the migration
+			continue
+		}
 		if duplicatedConstraintColumns := d.duplicatedConstraintColumns(cc.Columns, colNames...); len(duplicatedConstraintColumns) > 0 {
 			sql := fmt.Sprintf("ALTER TABLE %s ADD ", pq.QuoteIdentifier(d.table.Name))
-			writer := ConstraintSQLWriter{Name: DuplicationName(cc.Name), SkipValidation: true}
-			sql += writer.WriteCheck(rewriteCheckExpression(cc.Definition, duplicatedConstraintColumns...), cc.NoInherit)
+			writer := ConstraintSQLWriter{Name: d.naming.DuplicationName(cc.Name), SkipValidation: true}
+			sql += writer.WriteCheck(rewriteCheckExpression(d.naming, cc.Definition, duplicatedConstraintColumns...), cc.NoInherit)
 			stmts = append(stmts, sql)
 		}
 	}
@@ -185,11 +220,17 @@ func (d *duplicatorStmtBuilder) duplicateForeignKeyConstraints(withoutConstraint
 		if slices.Contains(withoutConstraint, fk.Name) {
 			continue
 		}
+		if d.table.ConstraintExists(d.naming.DuplicationName(fk.Name)) {
+			// already duplicated by an earlier attempt to start the migration
+			continue
+		}
 		if duplicatedMember, constraintColumns := d.allConstraintColumns(fk.Columns, colNames...); duplicatedMember {
 			sql := fmt.Sprintf("ALTER TABLE %s ADD ", pq.QuoteIdentifier(d.table.Name))
 			writer := ConstraintSQLWriter{
-				Name:    DuplicationName(fk.Name),
-				Columns: constraintColumns,
+				Name:              d.naming.DuplicationName(fk.Name),
+				Columns:           constraintColumns,
+				InitiallyDeferred: fk.InitiallyDeferred,
+				Deferrable:        fk.Deferrable,
 			}
 			sql += writer.WriteForeignKey(
 				fk.ReferencedTable,
@@ -211,6 +252,10 @@ func (d *duplicatorStmtBuilder) duplicateIndexes(withoutConstraint []string, col
 		if slices.Contains(withoutConstraint, idx.Name) {
 			continue
 		}
+		if _, ok := d.table.Indexes[d.naming.DuplicationName(idx.Name)]; ok {
+			// already duplicated by an earlier attempt to start the migration
+			continue
+		}
 		if _, ok := d.table.UniqueConstraints[idx.Name]; ok && idx.Unique {
 			// unique constraints are duplicated as unique indexes
 			continue
@@ -221,7 +266,7 @@ func (d *duplicatorStmtBuilder) duplicateIndexes(withoutConstraint []string, col
 			if idx.Unique {
 				stmtFmt = "CREATE UNIQUE INDEX CONCURRENTLY %s ON %s"
 			}
-			stmt := fmt.Sprintf(stmtFmt, pq.QuoteIdentifier(DuplicationName(idx.Name)), pq.QuoteIdentifier(d.table.Name))
+			stmt := fmt.Sprintf(stmtFmt, pq.QuoteIdentifier(d.naming.DuplicationName(idx.Name)), pq.QuoteIdentifier(d.table.Name))
 			if idx.Method != "" {
 				stmt += fmt.Sprintf(" USING %s", string(idx.Method))
 			}
@@ -264,7 +309,7 @@ func (d *duplicatorStmtBuilder) allConstraintColumns(constraintColumns []string,
 	newConstraintColumns := make([]string, len(constraintColumns))
 	for i, column := range constraintColumns {
 		if slices.Contains(duplicatedColumns, column) {
-			newConstraintColumns[i] = TemporaryName(column)
+			newConstraintColumns[i] = d.naming.TemporaryName(column)
 			duplicatedMember = true
 		} else {
 			newConstraintColumns[i] = column
@@ -278,9 +323,11 @@ func (d *duplicatorStmtBuilder) duplicateColumn(
 	asName string,
 	withoutNotNull bool,
 	withType string,
+	withGenerated string,
 ) string {
 	const (
 		cAlterTableSQL         = `ALTER TABLE %s ADD COLUMN %s %s`
+		cGeneratedSQL          = ` GENERATED ALWAYS AS (%s) STORED`
 		cAddCheckConstraintSQL = `ADD CONSTRAINT %s %s NOT VALID`
 	)
 
@@ -290,11 +337,17 @@ func (d *duplicatorStmtBuilder) duplicateColumn(
 		pq.QuoteIdentifier(asName),
 		withType)
 
+	// Generate SQL to make the new column a generated column. Adding a stored
+	// generated column rewrites the table to compute its values.
+	if withGenerated != "" {
+		sql += fmt.Sprintf(cGeneratedSQL, withGenerated)
+	}
+
 	// Generate SQL to add an unchecked NOT NULL constraint if the original column
 	// is NOT NULL. The constraint will be validated on migration completion.
 	if !column.Nullable && !withoutNotNull {
 		sql += fmt.Sprintf(", "+cAddCheckConstraintSQL,
-			pq.QuoteIdentifier(DuplicationName(NotNullConstraintName(column.Name))),
+			pq.QuoteIdentifier(d.naming.DuplicationName(d.naming.NotNullConstraintName(column.Name))),
 			fmt.Sprintf("CHECK (%s IS NOT NULL)", pq.QuoteIdentifier(asName)),
 		)
 	}
@@ -330,19 +383,22 @@ func (d *duplicatorStmtBuilder) duplicateComment(column *schema.Column, asName s
 	)
 }
 
-// DuplicationName returns the name of a duplicated column.
+// DuplicationName returns the name of a duplicated column, with the default
+// naming.
 func DuplicationName(name string) string {
-	return "_pgroll_dup_" + name
+	return backfill.Naming{}.DuplicationName(name)
 }
 
-// IsDuplicatedName returns true if the name is a duplicated column name.
+// IsDuplicatedName returns true if the name is a duplicated column name, with
+// the default naming.
 func IsDuplicatedName(name string) bool {
-	return strings.HasPrefix(name, "_pgroll_dup_")
+	return backfill.Naming{}.IsDuplicatedName(name)
 }
 
-// StripDuplicationPrefix removes the duplication prefix from a column name.
+// StripDuplicationPrefix removes the duplication prefix of the default naming
+// from a column name.
 func StripDuplicationPrefix(name string) string {
-	return strings.TrimPrefix(name, "_pgroll_dup_")
+	return backfill.Naming{}.StripDuplicationPrefix(name)
 }
 
 func errorIgnoringErrorCode(err error, code pq.ErrorCode) error {
@@ -14,6 +14,22 @@ func (e InvalidMigrationError) Error() string {
 	return e.Reason
 }
 
+// OperationError is an error found in one of the operations of a migration
+// when it is validated.
+type OperationError struct {
+	Index     int
+	Operation OpName
+	Err       error
+}
+
+func (e OperationError) Error() string {
+	return fmt.Sprintf("operations[%d] (%s): %s", e.Index, e.Operation, e.Err)
+}
+
+func (e OperationError) Unwrap() error {
+	return e.Err
+}
+
 type EmptyMigrationError struct{}
 
 func (e EmptyMigrationError) Error() string {
@@ -193,6 +209,91 @@ func (e InvalidReplicaIdentityError) Error() string {
 	return fmt.Sprintf("replica identity on table %q must be one of 'NOTHING', 'DEFAULT', 'INDEX' or 'FULL', found %q", e.Table, e.Identity)
 }
 
+type InvalidColumnStorageError struct {
+	Table   string
+	Column  string
+	Storage string
+}
+
+func (e InvalidColumnStorageError) Error() string {
+	return fmt.Sprintf("storage of column %q on table %q must be one of 'plain', 'external', 'extended' or 'main', found %q", e.Column, e.Table, e.Storage)
+}
+
+type InvalidColumnCompressionError struct {
+	Table       string
+	Column      string
+	Compression string
+}
+
+func (e InvalidColumnCompressionError) Error() string {
+	return fmt.Sprintf("compression of column %q on table %q must be one of 'pglz', 'lz4' or 'default', found %q", e.Column, e.Table, e.Compression)
+}
+
+type ColumnStorageNotSupportedError struct {
+	Table   string
+	Column  string
+	Type    string
+	Storage string
+}
+
+func (e ColumnStorageNotSupportedError) Error() string {
+	return fmt.Sprintf("column %q on table %q has type %q, which can only have storage 'plain', found %q", e.Column, e.Table, e.Type, e.Storage)
+}
+
+type ColumnCompressionNotSupportedError struct {
+	Table  string
+	Column string
+	Type   string
+}
+
+func (e ColumnCompressionNotSupportedError) Error() string {
+	return fmt.Sprintf("column %q on table %q has type %q, which does not support compression", e.Column, e.Table, e.Type)
+}
+
+type ColumnCompressionVersionError struct {
+	Table   string
+	Column  string
+	Version int
+}
+
+func (e ColumnCompressionVersionError) Error() string {
+	return fmt.Sprintf("setting the compression method of column %q on table %q requires Postgres 14 or later, but the server version is %d", e.Column, e.Table, e.Version)
+}
+
+type InvalidTriggerStateError struct {
+	Name  string
+	State string
+}
+
+func (e InvalidTriggerStateError) Error() string {
+	return fmt.Sprintf("state of trigger %q must be one of 'ENABLE', 'DISABLE', 'ENABLE REPLICA' or 'ENABLE ALWAYS', found %q", e.Name, e.State)
+}
+
+type InvalidPrivilegesActionError struct {
+	Action string
+}
+
+func (e InvalidPrivilegesActionError) Error() string {
+	return fmt.Sprintf("action must be one of 'grant' or 'revoke', found %q", e.Action)
+}
+
+type InvalidPrivilegesObjectTypeError struct {
+	ObjectType string
+}
+
+func (e InvalidPrivilegesObjectTypeError) Error() string {
+	return fmt.Sprintf("object type must be one of 'tables', 'sequences', 'functions' or 'types', found %q", e.ObjectType)
+}
+
+type InvalidPrivilegeError struct {
+	ObjectType string
+	Privilege  string
+}
+
+func (e InvalidPrivilegeError) Error() string {
+	return fmt.Sprintf("privilege %q does not apply to %s", e.Privilege, e.ObjectType)
+}
+
 type InvalidOnDeleteSettingError struct {
 	Name    string
 	Setting string
@@ -243,6 +344,109 @@ func (e AlterColumnNoChangesError) Error() string {
 	return fmt.Sprintf("alter column %q on table %q requires at least one change", e.Column, e.Table)
 }
 
+type AlterColumnInPlaceError struct {
+	Table  string
+	Column string
+}
+
+func (e AlterColumnInPlaceError) Error() string {
+	return fmt.Sprintf("alter column %q on table %q can only be made in place if it changes nothing but the nullability of the column, with no up or down SQL", e.Column, e.Table)
+}
+
+type ColumnIsNotJsonbError struct {
+	Table string
+	Name  string
+	Type  string
+}
+
+func (e ColumnIsNotJsonbError) Error() string {
+	return fmt.Sprintf("column %q on table %q must be of type jsonb, found %q", e.Name, e.Table, e.Type)
+}
+
+type InvalidJsonbPathError struct {
+	Path   string
+	Reason string
+}
+
+func (e InvalidJsonbPathError) Error() string {
+	return fmt.Sprintf("invalid jsonb path %q: %s", e.Path, e.Reason)
+}
+
+type InvalidJsonbPathOperationError struct {
+	Op     string
+	Path   string
+	Reason string
+}
+
+func (e InvalidJsonbPathOperationError) Error() string {
+	return fmt.Sprintf("invalid jsonb path operation %q on path %q: %s", e.Op, e.Path, e.Reason)
+}
+
+type JsonbTransformConflictError struct {
+	Table  string
+	Column string
+}
+
+func (e JsonbTransformConflictError) Error() string {
+	return fmt.Sprintf("alter column %q on table %q cannot set both jsonb and up or down", e.Column, e.Table)
+}
+
+type ColumnHasDependentsError struct {
+	Table      string
+	Column     string
+	Dependents string
+}
+
+func (e ColumnHasDependentsError) Error() string {
+	return fmt.Sprintf("column %q on table %q cannot be dropped because other objects depend on it (%s); drop them first or set cascade", e.Column, e.Table, e.Dependents)
+}
+
+type TypeInUseError struct {
+	Name   string
+	Table  string
+	Column string
+}
+
+func (e TypeInUseError) Error() string {
+	return fmt.Sprintf("type %q is used by column %q on table %q; set cascade to drop it anyway", e.Name, e.Column, e.Table)
+}
+
+type TypeHasDependentsError struct {
+	Name       string
+	Dependents string
+}
+
+func (e TypeHasDependentsError) Error() string {
+	return fmt.Sprintf("cannot drop type %q because other objects depend on it (%s); set cascade to drop them too", e.Name, e.Dependents)
+}
+
+type OperationDependencyError struct {
+	Operation  string
+	Dependency string
+	CreatedBy  string
+}
+
+func (e OperationDependencyError) Error() string {
+	return fmt.Sprintf("%s depends on %s, which is not created until the later operation %s; reorder the operations in the migration", e.Operation, e.Dependency, e.CreatedBy)
+}
+
+type OperationDependencyCycleError struct {
+	Cycle string
+}
+
+func (e OperationDependencyCycleError) Error() string {
+	return fmt.Sprintf("operations cannot be ordered because they depend on each other: %s", e.Cycle)
+}
+
+type MigrationConflictError struct {
+	Migration string
+	Object    string
+}
+
+func (e MigrationConflictError) Error() string {
+	return fmt.Sprintf("migration conflicts with active migration %q on %s; complete or roll it back first", e.Migration, e.Object)
+}
+
 // maxIdentifierLength is the maximum length of a valid identifier:
 // https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-SYNTAX-IDENTIFIERS
 const maxIdentifierLength = 63
@@ -281,6 +485,24 @@ func (e PrimaryKeysAreAlreadySetError) Error() string {
 	return fmt.Sprintf("table %q already has a primary key configuration in columns list", e.Table)
 }
 
+type TableHasPrimaryKeyError struct {
+	Table string
+}
+
+func (e TableHasPrimaryKeyError) Error() string {
+	return fmt.Sprintf("table %q already has a primary key", e.Table)
+}
+
+type InvalidPrimaryKeyIndexError struct {
+	Table  string
+	Index  string
+	Reason string
+}
+
+func (e InvalidPrimaryKeyIndexError) Error() string {
+	return fmt.Sprintf("index %q on table %q can't be used as a primary key: %s", e.Index, e.Table, e.Reason)
+}
+
 type InvalidGeneratedColumnError struct {
 	Table  string
 	Column string
@@ -290,6 +512,64 @@ func (e InvalidGeneratedColumnError) Error() string {
 	return fmt.Sprintf("column %q on table %q is invalid: only one of generated.expression and generated.identity may be set", e.Column, e.Table)
 }
 
+type InvalidGeneratedExpressionError struct {
+	Table      string
+	Column     string
+	Expression string
+	Reason     string
+}
+
+func (e InvalidGeneratedExpressionError) Error() string {
+	return fmt.Sprintf("generation expression %q for column %q on table %q is invalid: %s", e.Expression, e.Column, e.Table, e.Reason)
+}
+
+type ColumnAlreadyGeneratedError struct {
+	Table  string
+	Column string
+}
+
+func (e ColumnAlreadyGeneratedError) Error() string {
+	return fmt.Sprintf("column %q on table %q is already a generated column", e.Column, e.Table)
+}
+
+type ColumnNotGeneratedError struct {
+	Table  string
+	Column string
+}
+
+func (e ColumnNotGeneratedError) Error() string {
+	return fmt.Sprintf("column %q on table %q is not a generated column", e.Column, e.Table)
+}
+
+type GeneratedColumnDefaultError struct {
+	Table  string
+	Column string
+}
+
+func (e GeneratedColumnDefaultError) Error() string {
+	return fmt.Sprintf("column %q on table %q can't have a default: it is converted to a generated column", e.Column, e.Table)
+}
+
+type InvalidTypeNameError struct {
+	Table  string
+	Column string
+	Type   string
+}
+
+func (e InvalidTypeNameError) Error() string {
+	return fmt.Sprintf("type %q of column %q on table %q is not a valid type name", e.Type, e.Column, e.Table)
+}
+
+type GeneratedColumnConflictError struct {
+	Table  string
+	Column string
+	Field  string
+}
+
+func (e GeneratedColumnConflictError) Error() string {
+	return fmt.Sprintf("column %q on table %q is a generated column and can't have %s", e.Column, e.Table, e.Field)
+}
+
 type UpSQLMustBeColumnDefaultError struct {
 	Column string
 }
@@ -297,3 +577,239 @@ type UpSQLMustBeColumnDefaultError struct {
 func (e UpSQLMustBeColumnDefaultError) Error() string {
 	return fmt.Sprintf(`volatile default expression for column %q; "up" must be equal to "default"`, e.Column)
 }
+
+type TriggerDoesNotExistError struct {
+	Table string
+	Name  string
+}
+
+func (e TriggerDoesNotExistError) Error() string {
+	return fmt.Sprintf("trigger %q on table %q does not exist", e.Name, e.Table)
+}
+
+type PgrollTriggerError struct {
+	Name string
+}
+
+func (e PgrollTriggerError) Error() string {
+	return fmt.Sprintf("trigger %q is managed by pgroll and cannot be altered", e.Name)
+}
+
+type DuplicateValuesError struct {
+	Table  string
+	Column string
+	Values string
+}
+
+func (e DuplicateValuesError) Error() string {
+	return fmt.Sprintf("column %q on table %q can't be made unique; it contains duplicate values: %s", e.Column, e.Table, e.Values)
+}
+
+type ForeignKeyOrphansError struct {
+	Table           string
+	Constraint      string
+	ReferencedTable string
+	Rows            int
+	Values          string
+}
+
+func (e ForeignKeyOrphansError) Error() string {
+	return fmt.Sprintf("foreign key %q on table %q can't be validated immediately; %d rows reference values missing from table %q: %s", e.Constraint, e.Table, e.Rows, e.ReferencedTable, e.Values)
+}
+
+type BackfillNullValuesError struct {
+	Table  string
+	Column string
+}
+
+func (e BackfillNullValuesError) Error() string {
+	return fmt.Sprintf(`backfilling NOT NULL column %q on table %q produced NULL values; "up" must return a value for every row`, e.Column, e.Table)
+}
+
+type AssertionFailedError struct {
+	Name  string
+	Value string
+}
+
+func (e AssertionFailedError) Error() string {
+	return fmt.Sprintf("assertion %q failed: query returned %s", e.Name, e.Value)
+}
+
+type InvalidIndexExpressionError struct {
+	Table      string
+	Name       string
+	Expression string
+}
+
+func (e InvalidIndexExpressionError) Error() string {
+	return fmt.Sprintf("index %q on table %q: %q is neither a column nor a valid expression", e.Name, e.Table, e.Expression)
+}
+
+type ShadowColumnMismatchError struct {
+	Table        string
+	Column       string
+	ExpectedType string
+	ActualType   string
+}
+
+func (e ShadowColumnMismatchError) Error() string {
+	return fmt.Sprintf("column %q on table %q already exists with type %q, expected %q; drop it before starting the migration again",
+		e.Column, e.Table, e.ActualType, e.ExpectedType)
+}
+
+type RuleAlreadyExistsError struct {
+	Table string
+	Name  string
+}
+
+func (e RuleAlreadyExistsError) Error() string {
+	return fmt.Sprintf("rule %q on table %q already exists", e.Name, e.Table)
+}
+
+type RuleDoesNotExistError struct {
+	Table string
+	Name  string
+}
+
+func (e RuleDoesNotExistError) Error() string {
+	return fmt.Sprintf("rule %q on table %q does not exist", e.Name, e.Table)
+}
+
+type InvalidRuleEventError struct {
+	Name  string
+	Event string
+}
+
+func (e InvalidRuleEventError) Error() string {
+	return fmt.Sprintf("event of rule %q must be one of 'INSERT', 'UPDATE' or 'DELETE', found %q", e.Name, e.Event)
+}
+
+type InvalidBackfillWhereError struct {
+	Table string
+	Where string
+}
+
+func (e InvalidBackfillWhereError) Error() string {
+	return fmt.Sprintf("backfill_where for table %q: %q is not a valid condition", e.Table, e.Where)
+}
+
+type InvalidTriggerStatementsError struct {
+	Table  string
+	Column string
+	Reason string
+}
+
+func (e InvalidTriggerStatementsError) Error() string {
+	return fmt.Sprintf("trigger statements for column %q of table %q are invalid: %s", e.Column, e.Table, e.Reason)
+}
+
+type InvalidColumnSQLError struct {
+	Table     string
+	Column    string
+	Direction string
+	Message   string
+}
+
+func (e InvalidColumnSQLError) Error() string {
+	return fmt.Sprintf("%s SQL for column %q on table %q is invalid: %s", e.Direction, e.Column, e.Table, e.Message)
+}
+
+type TruncateNotReversibleError struct {
+	Table string
+}
+
+func (e TruncateNotReversibleError) Error() string {
+	return fmt.Sprintf("truncating table %q can't be rolled back: set preserve_data to keep a copy of its rows, or declare the migration forward-only", e.Table)
+}
+
+type TruncateCascadeWithPreserveDataError struct {
+	Table string
+}
+
+func (e TruncateCascadeWithPreserveDataError) Error() string {
+	return fmt.Sprintf("truncate of table %q can't set both preserve_data and cascade: the rows of the referencing tables would not be restored on rollback", e.Table)
+}
+
+type TableAlreadyInheritsError struct {
+	Table  string
+	Parent string
+}
+
+func (e TableAlreadyInheritsError) Error() string {
+	return fmt.Sprintf("table %q already inherits from table %q", e.Table, e.Parent)
+}
+
+type TableDoesNotInheritError struct {
+	Table  string
+	Parent string
+}
+
+func (e TableDoesNotInheritError) Error() string {
+	return fmt.Sprintf("table %q does not inherit from table %q", e.Table, e.Parent)
+}
+
+type InheritColumnMismatchError struct {
+	Table  string
+	Parent string
+	Column string
+	Reason string
+}
+
+func (e InheritColumnMismatchError) Error() string {
+	return fmt.Sprintf("table %q can't inherit from table %q: column %q %s", e.Table, e.Parent, e.Column, e.Reason)
+}
+
+type InvalidPartitionByError struct {
+	Table  string
+	Reason string
+}
+
+func (e InvalidPartitionByError) Error() string {
+	return fmt.Sprintf("invalid partition key for table %q: %s", e.Table, e.Reason)
+}
+
+type TableIsNotPartitionedError struct {
+	Table string
+}
+
+func (e TableIsNotPartitionedError) Error() string {
+	return fmt.Sprintf("table %q is not partitioned", e.Table)
+}
+
+type InvalidPartitionBoundError struct {
+	Table     string
+	Partition string
+}
+
+func (e InvalidPartitionBoundError) Error() string {
+	return fmt.Sprintf("partition %q of table %q must either have a bound or be the default partition", e.Partition, e.Table)
+}
+
+type PartitionKeyNotIncludedError struct {
+	Table      string
+	Constraint string
+}
+
+func (e PartitionKeyNotIncludedError) Error() string {
+	return fmt.Sprintf("%s on partitioned table %q must include all the columns of the partition key", e.Constraint, e.Table)
+}
+
+type ConstraintCannotBeValidatedError struct {
+	Table      string
+	Constraint string
+}
+
+func (e ConstraintCannotBeValidatedError) Error() string {
+	return fmt.Sprintf("constraint %q on table %q can't be validated; only check constraints and foreign keys can be validated", e.Constraint, e.Table)
+}
+
+type ConstraintViolationsError struct {
+	Table      string
+	Constraint string
+	Rows       int
+	Values     string
+}
+
+func (e ConstraintViolationsError) Error() string {
+	return fmt.Sprintf("constraint %q on table %q can't be validated; %d rows violate it: %s", e.Constraint, e.Table, e.Rows, e.Values)
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+// FormatMigration parses the contents of a migration file and re-serializes
+// it in canonical form. The file extension determines both the input and the
+// output format.
+//
+// The canonical form is deterministic: object keys are written in a stable
+// order, indentation is normalized and fields omitted from the original file
+// are written out with their default values.
+func FormatMigration(filename string, contents []byte) ([]byte, error) {
+	var format MigrationFormat
+	switch filepath.Ext(filename) {
+	case ".json":
+		format = JSONMigrationFormat
+	case ".yaml", ".yml":
+		format = YAMLMigrationFormat
+	default:
+		return nil, fmt.Errorf("%s: %w", filename, ErrInvalidMigrationFormat)
+	}
+
+	raw, err := decodeRawMigration(filename, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	mig, err := ParseMigration(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, format).Write(mig); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestFormatMigration(t *testing.T) {
+	t.Parallel()
+
+	t.Run("yaml migrations are written in canonical form", func(t *testing.T) {
+		input := `
+operations:
+    - rename_constraint:
+        table: people
+        to:   name_length_check
+        from: name_length
+`
+		want := `operations:
+- rename_constraint:
+    from: name_length
+    table: people
+    to: name_length_check
+`
+		got, err := migrations.FormatMigration("01_rename_constraint.yaml", []byte(input))
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	})
+
+	t.Run("json migrations are written in canonical form", func(t *testing.T) {
+		input := `{"operations": [{"drop_table": {"name": "users"}}]}`
+		want := `{
+  "operations": [
+    {
+      "drop_table": {
+        "name": "users"
+      }
+    }
+  ]
+}
+`
+		got, err := migrations.FormatMigration("01_drop_table.json", []byte(input))
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	})
+
+	t.Run("formatting is idempotent", func(t *testing.T) {
+		input := `{"operations": [{"create_index": {"name": "idx_users_name", "table": "users", "columns": {"name": {}}}}]}`
+
+		once, err := migrations.FormatMigration("01_create_index.json", []byte(input))
+		require.NoError(t, err)
+		twice, err := migrations.FormatMigration("01_create_index.json", once)
+		require.NoError(t, err)
+		assert.Equal(t, string(once), string(twice))
+	})
+
+	t.Run("invalid migrations are rejected", func(t *testing.T) {
+		_, err := migrations.FormatMigration("01_invalid.yaml", []byte("operations:\n- unknown_op: {}\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported file extensions are rejected", func(t *testing.T) {
+		_, err := migrations.FormatMigration("01_migration.sql", []byte("SELECT 1"))
+		assert.ErrorIs(t, err, migrations.ErrInvalidMigrationFormat)
+	})
+}
@@ -2,7 +2,13 @@
 
 package migrations
 
-import "github.com/pterm/pterm"
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
 
 // Logger is responsible for logging all migration steps.
 type Logger interface {
@@ -14,23 +20,60 @@ type Logger interface {
 	LogOperationStart(Operation)
 	LogOperationComplete(Operation)
 	LogOperationRollback(Operation)
+	LogOperationDone(op Operation, duration time.Duration)
 
-	LogBackfillStart(table string)
+	LogTriggerCreated(table, trigger string, duration time.Duration)
+	LogBackfillStart(table string, key []string)
+	LogBackfillBatch(table string, rows int64, duration time.Duration)
+	LogBackfillSkipped(table, operation string)
 	LogBackfillComplete(table string)
 	LogSchemaCreation(migration, schema string)
 	LogSchemaDeletion(migration, schema string)
 
 	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
 }
 
+// LogFormat is the format in which a Logger writes its events.
+type LogFormat string
+
+const (
+	// LogFormatText writes events as human-readable text.
+	LogFormatText LogFormat = "text"
+	// LogFormatJSON writes each event as a JSON object on its own line.
+	LogFormatJSON LogFormat = "json"
+)
+
+// Migration phases reported with each event by the JSON logger.
+const (
+	phaseStart    = "start"
+	phaseComplete = "complete"
+	phaseRollback = "rollback"
+)
+
 type migrationLogger struct {
 	logger pterm.Logger
+	format LogFormat
+
+	// the migration being run and its phase, reported with each event in the
+	// JSON format
+	migration string
+	phase     string
 }
 
 type noopLogger struct{}
 
 func NewLogger() Logger {
-	return &migrationLogger{logger: pterm.DefaultLogger}
+	return &migrationLogger{logger: pterm.DefaultLogger, format: LogFormatText}
+}
+
+// NewJSONLogger returns a Logger that writes each event to w as a JSON
+// object, along with the name of the migration and the phase it is in.
+func NewJSONLogger(w io.Writer) Logger {
+	return &migrationLogger{
+		logger: *pterm.DefaultLogger.WithFormatter(pterm.LogFormatterJSON).WithWriter(w),
+		format: LogFormatJSON,
+	}
 }
 
 func NewNoopLogger() Logger {
@@ -38,66 +81,99 @@ func NewNoopLogger() Logger {
 }
 
 func (l *migrationLogger) LogMigrationStart(m *Migration) {
-	l.logger.Info("starting migration", l.logger.Args(
+	l.migration, l.phase = m.Name, phaseStart
+	l.logger.Info("starting migration", l.args(
 		"name", m.Name,
 		"operation_count", len(m.Operations),
 	))
 }
 
 func (l *migrationLogger) LogMigrationComplete(m *Migration) {
-	l.logger.Info("completing migration", l.logger.Args(
+	l.migration, l.phase = m.Name, phaseComplete
+	l.logger.Info("completing migration", l.args(
 		"name", m.Name,
 		"operation_count", len(m.Operations),
 	))
 }
 
 func (l *migrationLogger) LogMigrationRollback(m *Migration) {
-	l.logger.Info("rolling back migration", l.logger.Args(
+	l.migration, l.phase = m.Name, phaseRollback
+	l.logger.Info("rolling back migration", l.args(
 		"name", m.Name,
 		"operation_count", len(m.Operations),
 	))
 }
 
 func (l *migrationLogger) LogMigrationRollbackComplete(m *Migration) {
-	l.logger.Info("rolled back migration", l.logger.Args(
+	l.logger.Info("rolled back migration", l.args(
 		"name", m.Name,
 		"operation_count", len(m.Operations),
 	))
 }
 
-func (l *migrationLogger) LogBackfillStart(table string) {
-	l.logger.Info("backfilling started", l.logger.Args("table", table))
+func (l *migrationLogger) LogBackfillStart(table string, key []string) {
+	l.logger.Info("backfilling started", l.args("table", table, "key", strings.Join(key, ", ")))
 }
 
 func (l *migrationLogger) LogBackfillComplete(table string) {
-	l.logger.Info("backfilling completed", l.logger.Args("table", table))
+	l.logger.Info("backfilling completed", l.args("table", table))
 }
 
 func (l *migrationLogger) LogSchemaCreation(migration, schema string) {
-	l.logger.Info("created versioned schema for migration", l.logger.Args("migration", migration, "schema_name", schema))
+	l.logger.Info("created versioned schema for migration", l.args("migration", migration, "schema_name", schema))
 }
 
 func (l *migrationLogger) LogSchemaDeletion(migration, schema string) {
-	l.logger.Info("dropped versioned schema for migration", l.logger.Args("migration", migration, "schema_name", schema))
+	l.logger.Info("dropped versioned schema for migration", l.args("migration", migration, "schema_name", schema))
+}
+
+func (l *migrationLogger) LogOperationStart(op Operation) {
+	l.logger.Info("starting operation", l.args(l.extractOpArgs(op)...))
 }
 
-func (l migrationLogger) LogOperationStart(op Operation) {
-	l.logger.Info("starting operation", l.logger.Args(l.extractOpArgs(op)...))
+func (l *migrationLogger) LogOperationComplete(op Operation) {
+	l.logger.Info("completing operation", l.args(l.extractOpArgs(op)...))
 }
 
-func (l migrationLogger) LogOperationComplete(op Operation) {
-	l.logger.Info("completing operation", l.logger.Args(l.extractOpArgs(op)...))
+func (l *migrationLogger) LogOperationRollback(op Operation) {
+	l.logger.Info("rolling back operation", l.args(l.extractOpArgs(op)...))
 }
 
-func (l migrationLogger) LogOperationRollback(op Operation) {
-	l.logger.Info("rolling back operation", l.logger.Args(l.extractOpArgs(op)...))
+func (l *migrationLogger) LogOperationDone(op Operation, duration time.Duration) {
+	l.logger.Info("operation done", l.args(append(l.extractOpArgs(op), "duration_ms", duration.Milliseconds())...))
 }
 
-func (l migrationLogger) Info(msg string, args ...any) {
+func (l *migrationLogger) LogTriggerCreated(table, trigger string, duration time.Duration) {
+	l.logger.Info("created trigger", l.args("table", table, "trigger", trigger, "duration_ms", duration.Milliseconds()))
+}
+
+func (l *migrationLogger) LogBackfillBatch(table string, rows int64, duration time.Duration) {
+	l.logger.Info("backfill batch committed", l.args("table", table, "rows_affected", rows, "duration_ms", duration.Milliseconds()))
+}
+
+func (l *migrationLogger) LogBackfillSkipped(table, operation string) {
+	l.logger.Info("backfill skipped; no rows left to backfill", l.args("table", table, "operation", operation))
+}
+
+func (l *migrationLogger) Info(msg string, args ...any) {
 	l.logger.Info(msg, l.logger.Args(args))
 }
 
-func (l migrationLogger) extractOpArgs(op Operation) []any {
+func (l *migrationLogger) Warn(msg string, args ...any) {
+	l.logger.Warn(msg, l.args(args...))
+}
+
+// args returns the logger arguments for an event. In the JSON format, the
+// migration and its phase are added to the arguments so that each event can
+// be attributed without the events that precede it.
+func (l *migrationLogger) args(args ...any) []pterm.LoggerArgument {
+	if l.format == LogFormatJSON && l.migration != "" {
+		args = append([]any{"migration", l.migration, "phase", l.phase}, args...)
+	}
+	return l.logger.Args(args...)
+}
+
+func (l *migrationLogger) extractOpArgs(op Operation) []any {
 	switch o := op.(type) {
 	case *OpAddColumn:
 		return []any{
@@ -114,6 +190,20 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			"column", o.Column,
 			"table", o.Table,
 		}
+	case *OpAlterDefaultPrivileges:
+		return []any{
+			"operation", OpNameAlterDefaultPrivileges,
+			"action", o.Action,
+			"object_type", o.ObjectType,
+			"grantee", o.Grantee,
+		}
+	case *OpAlterTrigger:
+		return []any{
+			"operation", OpNameAlterTrigger,
+			"name", o.Name,
+			"table", o.Table,
+			"state", o.State,
+		}
 	case *OpChangeType:
 		return []any{
 			"operation", OpNameAlterColumn,
@@ -128,6 +218,17 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			"name", o.Name,
 			"type", o.Type,
 		}
+	case *OpCreateForeignTable:
+		return []any{
+			"operation", OpNameCreateForeignTable,
+			"name", o.Name,
+			"server", o.Server,
+		}
+	case *OpCreateTableAs:
+		return []any{
+			"operation", OpNameCreateTableAs,
+			"name", o.Name,
+		}
 	case *OpCreateIndex:
 		return []any{
 			"operation", OpNameCreateIndex,
@@ -135,6 +236,12 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			"table", o.Table,
 			"index_type", o.Method,
 		}
+	case *OpAttachInherit:
+		return []any{
+			"operation", OpNameAttachInherit,
+			"table", o.Table,
+			"parent", o.Parent,
+		}
 	case *OpCreateTable:
 		return []any{
 			"operation", OpNameCreateTable,
@@ -142,6 +249,35 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			"columns", getColumnNames(o.Columns),
 			"comment", o.Comment,
 			"constraints", getConstraintNames(o.Constraints),
+			"checks", getCheckNames(o.Checks),
+		}
+	case *OpCreatePartition:
+		return []any{
+			"operation", OpNameCreatePartition,
+			"name", o.Name,
+			"table", o.Table,
+			"bound", o.Bound,
+			"default", o.Default,
+		}
+	case *OpCreateRule:
+		return []any{
+			"operation", OpNameCreateRule,
+			"name", o.Name,
+			"table", o.Table,
+			"event", o.Event,
+			"instead", o.Instead,
+		}
+	case *OpCreateType:
+		return []any{
+			"operation", OpNameCreateType,
+			"name", o.Name,
+			"attributes", getAttributeNames(o.Attributes),
+		}
+	case *OpDetachInherit:
+		return []any{
+			"operation", OpNameDetachInherit,
+			"table", o.Table,
+			"parent", o.Parent,
 		}
 	case *OpDropColumn:
 		return []any{
@@ -167,11 +303,27 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			"constraint", o.Name,
 			"table", o.Table,
 		}
+	case *OpDropRule:
+		return []any{
+			"operation", OpNameDropRule,
+			"name", o.Name,
+			"table", o.Table,
+		}
 	case *OpDropTable:
 		return []any{
 			"operation", OpNameDropTable,
 			"name", o.Name,
 		}
+	case *OpDropForeignTable:
+		return []any{
+			"operation", OpNameDropForeignTable,
+			"name", o.Name,
+		}
+	case *OpDropType:
+		return []any{
+			"operation", OpNameDropType,
+			"name", o.Name,
+		}
 	case *OpRawSQL:
 		return []any{
 			"operation", OpRawSQLName,
@@ -216,6 +368,13 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			args = append(args, "comment", *o.Comment)
 		}
 		return args
+	case *OpSetCompression:
+		return []any{
+			"operation", OpNameAlterColumn,
+			"column", o.Column,
+			"table", o.Table,
+			"compression", o.Compression,
+		}
 	case *OpSetDefault:
 		args := []any{
 			"operation", OpNameAlterColumn,
@@ -226,6 +385,16 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			args = append(args, "default", *o.Default)
 		}
 		return args
+	case *OpSetGenerated:
+		args := []any{
+			"operation", OpNameAlterColumn,
+			"table", o.Table,
+			"column", o.Column,
+		}
+		if o.Expression != nil {
+			args = append(args, "generated", *o.Expression)
+		}
+		return args
 	case *OpSetForeignKey:
 		return []any{
 			"operation", OpNameAlterColumn,
@@ -240,6 +409,12 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			"table", o.Table,
 			"nullable", false,
 		}
+	case *OpSetPrimaryKey:
+		return []any{
+			"operation", OpNameSetPrimaryKey,
+			"table", o.Table,
+			"index", o.Index,
+		}
 	case *OpSetReplicaIdentity:
 		return []any{
 			"operation", OpNameSetReplicaIdentity,
@@ -247,6 +422,35 @@ func (l migrationLogger) extractOpArgs(op Operation) []any {
 			"identity_type", o.Identity.Type,
 			"identity_index", o.Identity.Index,
 		}
+	case *OpSetStorage:
+		return []any{
+			"operation", OpNameAlterColumn,
+			"column", o.Column,
+			"table", o.Table,
+			"storage", o.Storage,
+		}
+	case *OpTruncate:
+		return []any{
+			"operation", OpNameTruncate,
+			"table", o.Table,
+			"preserve_data", o.PreserveData,
+			"restart_identity", o.RestartIdentity,
+			"cascade", o.Cascade,
+		}
+	case *OpValidateConstraint:
+		return []any{
+			"operation", OpNameValidateConstraint,
+			"name", o.Name,
+			"table", o.Table,
+		}
+	case *OpTransformJsonb:
+		return []any{
+			"operation", OpNameAlterColumn,
+			"column", o.Column,
+			"table", o.Table,
+			"jsonb_up_operations", len(o.Up),
+			"jsonb_down_operations", len(o.Down),
+		}
 	case *OpSetUnique:
 		return []any{
 			"operation", OpNameAlterColumn,
@@ -275,15 +479,36 @@ func getConstraintNames(cons []Constraint) []string {
 	return constraints
 }
 
-func (l *noopLogger) LogMigrationStart(m *Migration)             {}
-func (l *noopLogger) LogMigrationComplete(m *Migration)          {}
-func (l *noopLogger) LogMigrationRollback(m *Migration)          {}
-func (l *noopLogger) LogMigrationRollbackComplete(m *Migration)  {}
-func (l *noopLogger) LogBackfillStart(table string)              {}
-func (l *noopLogger) LogBackfillComplete(table string)           {}
-func (l *noopLogger) LogSchemaCreation(migration, schema string) {}
-func (l *noopLogger) LogSchemaDeletion(migration, schema string) {}
-func (l *noopLogger) LogOperationStart(op Operation)             {}
-func (l *noopLogger) LogOperationComplete(op Operation)          {}
-func (l *noopLogger) LogOperationRollback(op Operation)          {}
-func (l *noopLogger) Info(msg string, args ...any)               {}
+func getCheckNames(checks []TableCheck) []string {
+	names := make([]string, len(checks))
+	for i, c := range checks {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func getAttributeNames(attrs []CompositeTypeAttribute) []string {
+	attributes := make([]string, len(attrs))
+	for i, a := range attrs {
+		attributes[i] = a.Name
+	}
+	return attributes
+}
+
+func (l *noopLogger) LogMigrationStart(m *Migration)                                    {}
+func (l *noopLogger) LogMigrationComplete(m *Migration)                                 {}
+func (l *noopLogger) LogMigrationRollback(m *Migration)                                 {}
+func (l *noopLogger) LogMigrationRollbackComplete(m *Migration)                         {}
+func (l *noopLogger) LogBackfillStart(table string, key []string)                       {}
+func (l *noopLogger) LogBackfillBatch(table string, rows int64, duration time.Duration) {}
+func (l *noopLogger) LogBackfillSkipped(table, operation string)                        {}
+func (l *noopLogger) LogTriggerCreated(table, trigger string, duration time.Duration)   {}
+func (l *noopLogger) LogBackfillComplete(table string)                                  {}
+func (l *noopLogger) LogSchemaCreation(migration, schema string)                        {}
+func (l *noopLogger) LogSchemaDeletion(migration, schema string)                        {}
+func (l *noopLogger) LogOperationStart(op Operation)                                    {}
+func (l *noopLogger) LogOperationComplete(op Operation)                                 {}
+func (l *noopLogger) LogOperationRollback(op Operation)                                 {}
+func (l *noopLogger) LogOperationDone(op Operation, duration time.Duration)             {}
+func (l *noopLogger) Info(msg string, args ...any)                                      {}
+func (l *noopLogger) Warn(msg string, args ...any)                                      {}
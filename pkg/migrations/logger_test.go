@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestJSONLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := migrations.NewJSONLogger(&buf)
+
+	op := &migrations.OpDropTable{Name: "users"}
+	logger.LogMigrationStart(&migrations.Migration{Name: "01_drop_table", Operations: migrations.Operations{op}})
+	logger.LogOperationDone(op, 1500*time.Millisecond)
+	logger.LogBackfillBatch("users", 1000, 250*time.Millisecond)
+	logger.LogMigrationComplete(&migrations.Migration{Name: "01_drop_table"})
+	logger.LogOperationDone(op, 20*time.Millisecond)
+
+	var events []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		events = append(events, event)
+	}
+	require.Len(t, events, 5)
+
+	assert.Equal(t, "01_drop_table", events[1]["migration"])
+	assert.Equal(t, "start", events[1]["phase"])
+	assert.Equal(t, "drop_table", events[1]["operation"])
+	assert.InDelta(t, 1500, events[1]["duration_ms"], 0)
+
+	assert.Equal(t, "start", events[2]["phase"])
+	assert.Equal(t, "users", events[2]["table"])
+	assert.InDelta(t, 1000, events[2]["rows_affected"], 0)
+	assert.InDelta(t, 250, events[2]["duration_ms"], 0)
+
+	assert.Equal(t, "complete", events[4]["phase"])
+	assert.InDelta(t, 20, events[4]["duration_ms"], 0)
+}
@@ -4,8 +4,12 @@ package migrations
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 
 	_ "github.com/lib/pq"
 
@@ -25,11 +29,11 @@ type Operation interface {
 	// Complete will update the database schema to match the current version
 	// after calling Start.
 	// This method should be called once the previous version is no longer used.
-	Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error)
+	Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error)
 
 	// Rollback will revert the changes made by Start. It is not possible to
 	// rollback a completed migration.
-	Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error)
+	Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error)
 
 	// Validate returns a descriptive error if the operation cannot be applied to the given schema.
 	Validate(ctx context.Context, s *schema.Schema) error
@@ -66,17 +70,118 @@ type RequiresSchemaRefreshOperation interface {
 	RequiresSchemaRefresh()
 }
 
+// downSQLOptionalKey is the context key marking that operations are validated
+// as part of a forward-only migration.
+type downSQLOptionalKey struct{}
+
+// withoutDownSQL returns a context in which operations don't require `down`
+// expressions to be valid.
+func withoutDownSQL(ctx context.Context) context.Context {
+	return context.WithValue(ctx, downSQLOptionalKey{}, true)
+}
+
+// downSQLRequired returns true if operations validated with the context must
+// define their `down` expressions.
+func downSQLRequired(ctx context.Context) bool {
+	optional, _ := ctx.Value(downSQLOptionalKey{}).(bool)
+	return !optional
+}
+
+// rollbackRequired returns true if operations validated with the context must
+// be able to undo their changes when the migration is rolled back.
+func rollbackRequired(ctx context.Context) bool {
+	optional, _ := ctx.Value(downSQLOptionalKey{}).(bool)
+	return !optional
+}
+
+// namingKey is the context key of the naming of the objects that operations
+// create for their own use.
+type namingKey struct{}
+
+// WithNaming returns a context in which operations name the columns, triggers
+// and other objects they create for their own use with the given naming. The
+// objects of a migration must be named with the same naming when it is
+// started, completed and rolled back. Operations whose context has no naming
+// use the default naming.
+func WithNaming(ctx context.Context, n backfill.Naming) context.Context {
+	return context.WithValue(ctx, namingKey{}, n)
+}
+
+// NamingFrom returns the naming of the objects created by operations run with
+// the context, or the default naming if the context has none.
+func NamingFrom(ctx context.Context) backfill.Naming {
+	n, _ := ctx.Value(namingKey{}).(backfill.Naming)
+	return n
+}
+
+// NonTransactionalOperation is an operation that can be part of a migration
+// that is declared as non-transactional, because none of the statements it
+// executes needs to run in a transaction.
+type NonTransactionalOperation interface {
+	NonTransactional()
+}
+
+// LegacyOperation is an operation that manages a Postgres feature whose use is
+// discouraged. Migrations may use it, but it is reported when the migration is
+// validated.
+type LegacyOperation interface {
+	// Legacy returns why the use of the operation is discouraged.
+	Legacy() string
+}
+
+// LossyOperation is an operation that irrecoverably discards data once the
+// migration is completed. It is reported when the migration is validated.
+type LossyOperation interface {
+	// Lossy returns the data that is discarded by the operation.
+	Lossy() string
+}
+
+// PartialOperation is an operation that only applies to data written after the
+// migration is started, leaving existing data as it is. It is reported when
+// the migration is validated.
+type PartialOperation interface {
+	// Partial returns the data that is left as it is by the operation, or an
+	// empty string if the operation applies to all data.
+	Partial() string
+}
+
+// OwnedOperation is an operation that creates objects which are owned by the
+// default object owner of the migration, if one is set.
+type OwnedOperation interface {
+	// OwnedObjects returns the objects created by the operation whose owner is
+	// not set by the operation itself.
+	OwnedObjects() []OwnedObject
+}
+
+// OwnedObject is an object created by an OwnedOperation.
+type OwnedObject struct {
+	// Type is the type of the object as it is named in ALTER ... OWNER TO, eg.
+	// TABLE or TYPE.
+	Type string
+
+	// Name is the name of the object.
+	Name string
+}
+
 type (
 	Operations []Operation
 	Migration  struct {
-		Name          string     `json:"-"`
-		VersionSchema string     `json:"version_schema,omitempty"`
-		Operations    Operations `json:"operations"`
+		Name          string               `json:"-"`
+		VersionSchema string               `json:"version_schema,omitempty"`
+		Transactional *bool                `json:"transactional,omitempty"`
+		Reversible    *bool                `json:"reversible,omitempty"`
+		Environments  []string             `json:"environments,omitempty"`
+		Operations    Operations           `json:"operations"`
+		Assertions    []MigrationAssertion `json:"assertions,omitempty"`
 	}
 	RawMigration struct {
-		Name          string          `json:"-"`
-		VersionSchema string          `json:"version_schema,omitempty"`
-		Operations    json.RawMessage `json:"operations"`
+		Name          string               `json:"-"`
+		VersionSchema string               `json:"version_schema,omitempty"`
+		Transactional *bool                `json:"transactional,omitempty"`
+		Reversible    *bool                `json:"reversible,omitempty"`
+		Environments  []string             `json:"environments,omitempty"`
+		Operations    json.RawMessage      `json:"operations"`
+		Assertions    []MigrationAssertion `json:"assertions,omitempty"`
 	}
 
 	StartResult struct {
@@ -94,24 +199,234 @@ func (m *Migration) VersionSchemaName() string {
 	return m.Name
 }
 
+// Checksum returns a hash of the contents of the migration, as they are
+// recorded in the pgroll state when the migration is started. Migrations read
+// from files that differ only in their formatting have the same checksum. The
+// name of the migration is not included.
+func (m *Migration) Checksum() (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal migration: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// IsTransactional returns false if the migration is declared as
+// non-transactional, in which case every statement it executes is run on its
+// own, outside of any transaction block.
+func (m *Migration) IsTransactional() bool {
+	return m.Transactional == nil || *m.Transactional
+}
+
+// IsReversible returns false if the migration is declared as forward-only.
+// Forward-only migrations don't need `down` expressions, don't create down
+// triggers and can't be rolled back.
+func (m *Migration) IsReversible() bool {
+	return m.Reversible == nil || *m.Reversible
+}
+
+// AppliesTo returns true if the migration is applied in the given
+// environment. Migrations that are not tagged with any environment are
+// applied in every environment.
+func (m *Migration) AppliesTo(environment string) bool {
+	return len(m.Environments) == 0 || slices.Contains(m.Environments, environment)
+}
+
+// LegacyWarnings returns a warning for each legacy operation in the migration.
+func (m *Migration) LegacyWarnings() []string {
+	var warnings []string
+	for i, op := range m.Operations {
+		if legacyOp, ok := op.(LegacyOperation); ok {
+			warnings = append(warnings, fmt.Sprintf("operations[%d] (%s) is a legacy operation: %s",
+				i, OperationName(op), legacyOp.Legacy()))
+		}
+	}
+	return warnings
+}
+
+// Warnings returns every warning for the migration: its legacy operations,
+// the operations that discard data, the operations that leave existing data
+// as it is and whether it is forward-only.
+func (m *Migration) Warnings() []string {
+	warnings := m.LegacyWarnings()
+	for i, op := range m.Operations {
+		if lossyOp, ok := op.(LossyOperation); ok {
+			warnings = append(warnings, fmt.Sprintf("operations[%d] (%s) is a lossy operation: %s",
+				i, OperationName(op), lossyOp.Lossy()))
+		}
+	}
+	for i, op := range m.Operations {
+		if partialOp, ok := op.(PartialOperation); ok && partialOp.Partial() != "" {
+			warnings = append(warnings, fmt.Sprintf("operations[%d] (%s) is a partial operation: %s",
+				i, OperationName(op), partialOp.Partial()))
+		}
+	}
+	for i, op := range m.Operations {
+		if raw, ok := op.(*OpRawSQL); ok {
+			for _, table := range persistentTempTables(raw.Up) {
+				warnings = append(warnings, fmt.Sprintf("operations[%d] (%s) creates temporary table %q without ON COMMIT DROP: it outlives the migration on a pooled connection, use temp_tables instead",
+					i, OperationName(op), table))
+			}
+		}
+	}
+	if !m.IsReversible() {
+		warnings = append(warnings, fmt.Sprintf("migration %q is forward-only and can't be rolled back", m.Name))
+	}
+	return warnings
+}
+
 // Validate will check that the migration can be applied to the given schema
 // returns a descriptive error if the migration is invalid
 func (m *Migration) Validate(ctx context.Context, s *schema.Schema) error {
+	errs := m.validate(ctx, s, false)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	var opErr OperationError
+	if errors.As(errs[0], &opErr) {
+		return opErr.Err
+	}
+	return errs[0]
+}
+
+// ValidateAll checks that the migration can be applied to the given schema,
+// like Validate, but carries on past the first invalid operation and returns
+// every error that it finds. Errors in an operation are returned as an
+// OperationError. The operations after an invalid one are validated against
+// the schema as updated by the valid operations only, so an error may cause
+// further errors in the operations that depend on it.
+func (m *Migration) ValidateAll(ctx context.Context, s *schema.Schema) []error {
+	return m.validate(ctx, s, true)
+}
+
+// validate returns the errors found in the migration, stopping at the first
+// one unless all is set.
+func (m *Migration) validate(ctx context.Context, s *schema.Schema, all bool) []error {
+	if !m.IsReversible() {
+		ctx = withoutDownSQL(ctx)
+	}
+
+	var errs []error
+	report := func(err error) bool {
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return err != nil && !all
+	}
+
+	if report(m.validateAssertions()) {
+		return errs
+	}
+
+	if report(m.validateEnvironments()) {
+		return errs
+	}
+
 	for _, op := range m.Operations {
 		if isolatedOp, ok := op.(IsolatedOperation); ok {
 			if isolatedOp.IsIsolated() && len(m.Operations) > 1 {
-				return InvalidMigrationError{Reason: fmt.Sprintf("operation %q cannot be executed with other operations", OperationName(op))}
+				if report(InvalidMigrationError{Reason: fmt.Sprintf("operation %q cannot be executed with other operations", OperationName(op))}) {
+					return errs
+				}
+			}
+		}
+	}
+
+	if !m.IsTransactional() {
+		for _, op := range m.Operations {
+			if _, ok := op.(NonTransactionalOperation); !ok {
+				if report(InvalidMigrationError{Reason: fmt.Sprintf("operation %q can't be part of a non-transactional migration", OperationName(op))}) {
+					return errs
+				}
+			}
+			if raw, ok := op.(*OpRawSQL); ok && len(raw.TempTables) > 0 {
+				// The statements of non-transactional migrations run on their
+				// own, so the temporary tables would be dropped before the up
+				// SQL runs
+				if report(InvalidMigrationError{Reason: "temp_tables can't be used in a non-transactional migration"}) {
+					return errs
+				}
 			}
 		}
 	}
 
+	if report(m.validateOperationOrder(s)) {
+		return errs
+	}
+
+	if report(m.validateRenamedTables()) {
+		return errs
+	}
+
+	for i, op := range m.Operations {
+		if err := op.Validate(ctx, s); err != nil {
+			if report(OperationError{Index: i, Operation: OperationName(op), Err: err}) {
+				return errs
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateAssertions checks that each assertion has a unique name and a
+// query.
+func (m *Migration) validateAssertions() error {
+	names := make(map[string]struct{}, len(m.Assertions))
+	for _, a := range m.Assertions {
+		if a.Name == "" {
+			return FieldRequiredError{Name: "name"}
+		}
+		if a.Query == "" {
+			return FieldRequiredError{Name: "query"}
+		}
+		if _, ok := names[a.Name]; ok {
+			return InvalidMigrationError{Reason: fmt.Sprintf("assertion %q is declared more than once", a.Name)}
+		}
+		names[a.Name] = struct{}{}
+	}
+	return nil
+}
+
+// validateRenamedTables checks that no operation creates a table with the old
+// name of a table renamed earlier in the migration. Renamed tables keep their
+// old name in the database until the migration completes, so such a table
+// can't be created when the migration starts, and rolling the failed start
+// back would drop the renamed table instead.
+func (m *Migration) validateRenamedTables() error {
+	renamed := make(map[string]bool)
 	for _, op := range m.Operations {
-		err := op.Validate(ctx, s)
-		if err != nil {
-			return err
+		var created string
+		switch o := op.(type) {
+		case *OpRenameTable:
+			renamed[o.From] = true
+		case *OpCreateTable:
+			created = o.Name
+		case *OpCreateTableAs:
+			created = o.Name
+		case *OpCreateForeignTable:
+			created = o.Name
+		}
+		if renamed[created] {
+			return InvalidMigrationError{Reason: fmt.Sprintf("table %q can't be created in the migration that renames it, as the renamed table keeps its name until the migration completes", created)}
 		}
 	}
+	return nil
+}
 
+// validateEnvironments checks that each environment the migration is tagged
+// with is named and is listed only once.
+func (m *Migration) validateEnvironments() error {
+	for i, env := range m.Environments {
+		if env == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("environments[%d]", i)}
+		}
+		if slices.Contains(m.Environments[:i], env) {
+			return InvalidMigrationError{Reason: fmt.Sprintf("environment %q is listed more than once", env)}
+		}
+	}
 	return nil
 }
 
@@ -64,6 +64,772 @@ func TestOnCompleteSQLMigrationsAreNotIsolated(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestNonTransactionalMigrationsRejectTransactionalOperations(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name:          "non_transactional",
+		Transactional: ptr(false),
+		Operations: migrations.Operations{
+			&migrations.OpCreateIndex{
+				Name:    "idx_foo",
+				Table:   "foo",
+				Columns: migrations.OpCreateIndexColumns{"bar": {}},
+			},
+			&migrations.OpAddColumn{
+				Table:  "foo",
+				Column: migrations.Column{Name: "baz", Type: "text", Nullable: true},
+			},
+		},
+	}
+
+	err := migration.Validate(context.TODO(), schema.New())
+	assert.ErrorIs(t, err, migrations.InvalidMigrationError{
+		Reason: `operation "add_column" can't be part of a non-transactional migration`,
+	})
+}
+
+func TestNonTransactionalMigrationsValid(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name:          "non_transactional",
+		Transactional: ptr(false),
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: `CREATE INDEX CONCURRENTLY idx_foo ON foo (bar); CREATE INDEX CONCURRENTLY idx_baz ON foo (baz)`,
+			},
+		},
+	}
+
+	err := migration.Validate(context.TODO(), schema.New())
+	assert.NoError(t, err)
+	assert.False(t, migration.IsTransactional())
+}
+
+func TestNonTransactionalMigrationsRejectTempTables(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name:          "non_transactional",
+		Transactional: ptr(false),
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				TempTables: []migrations.TempTable{{Name: "stale", Query: "SELECT id FROM foo"}},
+				Up:         "DELETE FROM foo WHERE id IN (SELECT id FROM stale)",
+			},
+		},
+	}
+
+	err := migration.Validate(context.TODO(), schema.New())
+	assert.ErrorIs(t, err, migrations.InvalidMigrationError{
+		Reason: "temp_tables can't be used in a non-transactional migration",
+	})
+}
+
+func TestRawSQLTempTablesValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		op      *migrations.OpRawSQL
+		wantErr error
+	}{
+		"temp tables": {
+			op: &migrations.OpRawSQL{
+				TempTables: []migrations.TempTable{
+					{Name: "stale", Query: "SELECT id FROM foo WHERE updated_at < now() - interval '1 year'"},
+					{Name: "orphans", Query: "SELECT id FROM bar"},
+				},
+				Up: "DELETE FROM foo WHERE id IN (SELECT id FROM stale UNION SELECT id FROM orphans)",
+			},
+		},
+		"missing name": {
+			op: &migrations.OpRawSQL{
+				TempTables: []migrations.TempTable{{Query: "SELECT 1"}},
+				Up:         "SELECT 1",
+			},
+			wantErr: migrations.FieldRequiredError{Name: "temp_tables[0].name"},
+		},
+		"missing query": {
+			op: &migrations.OpRawSQL{
+				TempTables: []migrations.TempTable{{Name: "stale"}},
+				Up:         "SELECT 1",
+			},
+			wantErr: migrations.FieldRequiredError{Name: "temp_tables[0].query"},
+		},
+		"duplicate name": {
+			op: &migrations.OpRawSQL{
+				TempTables: []migrations.TempTable{
+					{Name: "stale", Query: "SELECT 1"},
+					{Name: "stale", Query: "SELECT 2"},
+				},
+				Up: "SELECT 1",
+			},
+			wantErr: migrations.InvalidMigrationError{Reason: `temporary table "stale" is declared more than once`},
+		},
+		"transaction control in up": {
+			op: &migrations.OpRawSQL{
+				TempTables: []migrations.TempTable{{Name: "stale", Query: "SELECT 1 AS id"}},
+				Up:         "DELETE FROM foo WHERE id IN (SELECT id FROM stale); COMMIT; DELETE FROM bar",
+			},
+			wantErr: migrations.InvalidMigrationError{Reason: "up can't begin or end transactions when temp_tables are set, as the temporary tables are dropped when the transaction that creates them commits"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.op.Validate(context.TODO(), schema.New())
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestRawSQLEffectsAreAppliedToTheSchema(t *testing.T) {
+	t.Parallel()
+
+	migs := []migrations.Migration{
+		{
+			Name: "01_create_table",
+			Operations: migrations.Operations{
+				&migrations.OpRawSQL{
+					Up: "CREATE TABLE users (id serial PRIMARY KEY, name text, legacy text)",
+					Effects: &migrations.RawSQLEffects{
+						AddTables: []migrations.RawSQLTable{
+							{
+								Name: "users",
+								Columns: []migrations.RawSQLColumn{
+									{Name: "id", Type: "serial"},
+									{Name: "name", Type: "text", Nullable: true},
+									{Name: "legacy", Type: "text", Nullable: true},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table:  "users",
+					Column: migrations.Column{Name: "email", Type: "text", Nullable: true},
+				},
+			},
+		},
+		{
+			Name: "03_drop_column",
+			Operations: migrations.Operations{
+				&migrations.OpRawSQL{
+					Up: "ALTER TABLE users DROP COLUMN legacy",
+					Effects: &migrations.RawSQLEffects{
+						DropColumns: []migrations.RawSQLDroppedColumn{{Table: "users", Column: "legacy"}},
+					},
+				},
+			},
+		},
+	}
+
+	s := schema.New()
+	for _, mig := range migs {
+		require.NoError(t, mig.Validate(context.TODO(), s))
+	}
+
+	table := s.GetTable("users")
+	require.NotNil(t, table)
+	assert.NotNil(t, table.GetColumn("name"))
+	assert.NotNil(t, table.GetColumn("email"))
+	assert.Nil(t, table.GetColumn("legacy"))
+}
+
+func TestRawSQLEffectsValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		effects *migrations.RawSQLEffects
+		wantErr error
+	}{
+		"table added twice": {
+			effects: &migrations.RawSQLEffects{
+				AddTables: []migrations.RawSQLTable{{Name: "users"}, {Name: "users"}},
+			},
+			wantErr: migrations.TableAlreadyExistsError{Name: "users"},
+		},
+		"column without a type": {
+			effects: &migrations.RawSQLEffects{
+				AddTables: []migrations.RawSQLTable{
+					{Name: "users", Columns: []migrations.RawSQLColumn{{Name: "id"}}},
+				},
+			},
+			wantErr: migrations.ColumnIsInvalidError{Table: "users", Name: "id"},
+		},
+		"column added to a missing table": {
+			effects: &migrations.RawSQLEffects{
+				AddColumns: []migrations.RawSQLAddedColumn{
+					{Table: "users", Column: migrations.RawSQLColumn{Name: "email", Type: "text"}},
+				},
+			},
+			wantErr: migrations.TableDoesNotExistError{Name: "users"},
+		},
+		"missing table dropped": {
+			effects: &migrations.RawSQLEffects{DropTables: []string{"users"}},
+			wantErr: migrations.TableDoesNotExistError{Name: "users"},
+		},
+		"missing column name": {
+			effects: &migrations.RawSQLEffects{
+				DropColumns: []migrations.RawSQLDroppedColumn{{Table: "users"}},
+			},
+			wantErr: migrations.FieldRequiredError{Name: "effects.drop_columns[0].column"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			op := &migrations.OpRawSQL{Up: "SELECT 1", Effects: tc.effects}
+			err := op.Validate(context.TODO(), schema.New())
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestRawSQLEffectConflicts(t *testing.T) {
+	t.Parallel()
+
+	s := schema.New()
+	s.AddTable("users", &schema.Table{
+		Name: "users",
+		Columns: map[string]*schema.Column{
+			"id":   {Name: "id", Type: "integer"},
+			"name": {Name: "name", Type: "text", Nullable: true},
+		},
+	})
+
+	op := &migrations.OpRawSQL{
+		Up: "SELECT 1",
+		Effects: &migrations.RawSQLEffects{
+			AddTables: []migrations.RawSQLTable{
+				{
+					Name: "users",
+					Columns: []migrations.RawSQLColumn{
+						{Name: "id", Type: "int"},
+						{Name: "name", Type: "text"},
+					},
+				},
+				{Name: "orders"},
+			},
+			DropColumns: []migrations.RawSQLDroppedColumn{{Table: "users", Column: "id"}},
+		},
+	}
+
+	assert.Equal(t, []string{
+		`column "id" declared as dropped from table "users" still exists`,
+		`column "name" on table "users" is declared with nullable false but is nullable true`,
+		`table "orders" declared as added does not exist`,
+	}, op.EffectConflicts(s))
+}
+
+func TestTablesCantBeCreatedWithTheOldNameOfARenamedTable(t *testing.T) {
+	t.Parallel()
+
+	s := schema.New()
+	s.AddTable("users", &schema.Table{
+		Name:    "users",
+		Columns: map[string]*schema.Column{"id": {Name: "id", Type: "integer"}},
+	})
+
+	migration := migrations.Migration{
+		Name: "rename_table",
+		Operations: migrations.Operations{
+			&migrations.OpRenameTable{From: "users", To: "people"},
+			&migrations.OpCreateTable{
+				Name:    "users",
+				Columns: []migrations.Column{{Name: "id", Type: "serial", Pk: true}},
+			},
+		},
+	}
+
+	err := migration.Validate(context.TODO(), s)
+	assert.ErrorIs(t, err, migrations.InvalidMigrationError{Reason: `table "users" can't be created in the migration that renames it, as the renamed table keeps its name until the migration completes`})
+}
+
+func TestRenamesAreRolledBackInTheInMemorySchema(t *testing.T) {
+	t.Parallel()
+
+	newSchema := func() *schema.Schema {
+		s := schema.New()
+		s.AddTable("users", &schema.Table{
+			Name: "users",
+			Columns: map[string]*schema.Column{
+				"id":   {Name: "id", Type: "integer"},
+				"name": {Name: "name", Type: "text"},
+			},
+			UniqueConstraints: map[string]*schema.UniqueConstraint{
+				"unique_name": {Name: "unique_name", Columns: []string{"name"}},
+			},
+		})
+		return s
+	}
+
+	migration := migrations.Migration{
+		Name: "rename_table",
+		Operations: migrations.Operations{
+			&migrations.OpRenameTable{From: "users", To: "people"},
+			&migrations.OpRenameColumn{Table: "people", From: "name", To: "full_name"},
+		},
+	}
+
+	s := newSchema()
+	require.NoError(t, migration.UpdateVirtualSchema(context.TODO(), s))
+
+	// Roll the operations back in reverse order
+	for i := len(migration.Operations) - 1; i >= 0; i-- {
+		_, err := migration.Operations[i].Rollback(context.TODO(), migrations.NewNoopLogger(), nil, s)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, newSchema(), s)
+}
+
+func TestForwardOnlyMigrationsDontRequireDownSQL(t *testing.T) {
+	t.Parallel()
+
+	s := &schema.Schema{
+		Name: "public",
+		Tables: map[string]*schema.Table{
+			"users": {
+				Name: "users",
+				Columns: map[string]*schema.Column{
+					"id":   {Name: "id", Type: "integer"},
+					"name": {Name: "name", Type: "text"},
+				},
+			},
+		},
+	}
+
+	op := &migrations.OpAlterColumn{
+		Table:  "users",
+		Column: "name",
+		Type:   ptr("varchar(255)"),
+		Up:     "name",
+	}
+
+	t.Run("reversible migrations require down SQL", func(t *testing.T) {
+		migration := migrations.Migration{
+			Name:       "change_type",
+			Operations: migrations.Operations{op},
+		}
+
+		err := migration.Validate(context.TODO(), s)
+		assert.ErrorIs(t, err, migrations.FieldRequiredError{Name: "down"})
+		assert.True(t, migration.IsReversible())
+	})
+
+	t.Run("forward-only migrations don't require down SQL", func(t *testing.T) {
+		migration := migrations.Migration{
+			Name:       "change_type",
+			Reversible: ptr(false),
+			Operations: migrations.Operations{op},
+		}
+
+		err := migration.Validate(context.TODO(), s)
+		assert.NoError(t, err)
+		assert.False(t, migration.IsReversible())
+	})
+}
+
+func TestMigrationsApplyToTheirEnvironments(t *testing.T) {
+	t.Parallel()
+
+	untagged := migrations.Migration{Name: "untagged"}
+	tagged := migrations.Migration{Name: "tagged", Environments: []string{"staging", "development"}}
+
+	assert.True(t, untagged.AppliesTo("production"))
+	assert.True(t, untagged.AppliesTo(""))
+	assert.True(t, tagged.AppliesTo("staging"))
+	assert.True(t, tagged.AppliesTo("development"))
+	assert.False(t, tagged.AppliesTo("production"))
+	assert.False(t, tagged.AppliesTo(""))
+}
+
+func TestMigrationEnvironmentsAreValidated(t *testing.T) {
+	t.Parallel()
+
+	ops := migrations.Operations{&migrations.OpRawSQL{Up: "SELECT 1"}}
+
+	t.Run("environments must be named", func(t *testing.T) {
+		migration := migrations.Migration{
+			Name:         "fixtures",
+			Environments: []string{"staging", ""},
+			Operations:   ops,
+		}
+
+		err := migration.Validate(context.TODO(), schema.New())
+		assert.ErrorIs(t, err, migrations.FieldRequiredError{Name: "environments[1]"})
+	})
+
+	t.Run("environments must be listed once", func(t *testing.T) {
+		migration := migrations.Migration{
+			Name:         "fixtures",
+			Environments: []string{"staging", "staging"},
+			Operations:   ops,
+		}
+
+		err := migration.Validate(context.TODO(), schema.New())
+		assert.ErrorAs(t, err, &migrations.InvalidMigrationError{})
+	})
+}
+
+func TestValidateAllReportsEveryError(t *testing.T) {
+	t.Parallel()
+
+	// Validation updates the schema, so each validation needs its own copy
+	newSchema := func() *schema.Schema {
+		return &schema.Schema{
+			Name: "public",
+			Tables: map[string]*schema.Table{
+				"users": {
+					Name: "users",
+					Columns: map[string]*schema.Column{
+						"id":   {Name: "id", Type: "integer"},
+						"name": {Name: "name", Type: "text"},
+					},
+				},
+			},
+		}
+	}
+
+	migration := migrations.Migration{
+		Name: "invalid",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table:  "users",
+				Column: migrations.Column{Name: "email", Type: "text", Nullable: true},
+			},
+			&migrations.OpDropColumn{
+				Table:  "users",
+				Column: "doesntexist",
+			},
+			&migrations.OpAddColumn{
+				Table:  "users",
+				Column: migrations.Column{Name: "email", Type: "text", Nullable: true},
+			},
+			&migrations.OpAddColumn{
+				Table:  "users",
+				Column: migrations.Column{Name: "age", Type: "int eger", Nullable: true},
+			},
+		},
+	}
+
+	errs := migration.ValidateAll(context.TODO(), newSchema())
+	assert.Equal(t, []error{
+		migrations.OperationError{
+			Index:     1,
+			Operation: migrations.OpNameDropColumn,
+			Err:       migrations.ColumnDoesNotExistError{Table: "users", Name: "doesntexist"},
+		},
+		migrations.OperationError{
+			Index:     2,
+			Operation: migrations.OpNameAddColumn,
+			Err:       migrations.ColumnAlreadyExistsError{Table: "users", Name: "email"},
+		},
+		migrations.OperationError{
+			Index:     3,
+			Operation: migrations.OpNameAddColumn,
+			Err:       migrations.InvalidTypeNameError{Table: "users", Column: "age", Type: "int eger"},
+		},
+	}, errs)
+
+	// Validate stops at the first error
+	err := migration.Validate(context.TODO(), newSchema())
+	assert.ErrorIs(t, err, migrations.ColumnDoesNotExistError{Table: "users", Name: "doesntexist"})
+}
+
+func TestColumnTypesMustBeTypeNames(t *testing.T) {
+	t.Parallel()
+
+	for _, typeName := range []string{"integer", "varchar(255)", "timestamp with time zone", "public.mood[]", `"MyType"`} {
+		migration := migrations.Migration{
+			Name: "create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name:    "items",
+					Columns: []migrations.Column{{Name: "value", Type: typeName}},
+				},
+			},
+		}
+		assert.NoError(t, migration.Validate(context.TODO(), schema.New()), typeName)
+	}
+
+	for _, typeName := range []string{"varchar(", "integer; DROP TABLE users", "integer FROM users", "int4 + 1"} {
+		migration := migrations.Migration{
+			Name: "create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name:    "items",
+					Columns: []migrations.Column{{Name: "value", Type: typeName}},
+				},
+			},
+		}
+		err := migration.Validate(context.TODO(), schema.New())
+		assert.ErrorIs(t, err, migrations.InvalidTypeNameError{Table: "items", Column: "value", Type: typeName})
+	}
+}
+
+func TestLegacyWarningsReportLegacyOperations(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name: "rules",
+		Operations: migrations.Operations{
+			&migrations.OpCreateRule{
+				Table:   "users",
+				Name:    "protect_users",
+				Event:   migrations.OpCreateRuleEventDELETE,
+				Instead: true,
+				Command: "NOTHING",
+			},
+			&migrations.OpAddColumn{
+				Table:  "users",
+				Column: migrations.Column{Name: "email", Type: "text", Nullable: true},
+			},
+			&migrations.OpDropRule{
+				Table: "users",
+				Name:  "audit_users",
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"operations[0] (create_rule) is a legacy operation: rules are a legacy Postgres feature; prefer triggers",
+		"operations[2] (drop_rule) is a legacy operation: rules are a legacy Postgres feature; prefer triggers",
+	}, migration.LegacyWarnings())
+}
+
+func TestWarningsReportLossyAndForwardOnlyMigrations(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name:       "cleanup",
+		Reversible: ptr(false),
+		Operations: migrations.Operations{
+			&migrations.OpDropColumn{
+				Table:  "users",
+				Column: "email",
+			},
+			&migrations.OpDropRule{
+				Table: "users",
+				Name:  "audit_users",
+			},
+			&migrations.OpTruncate{
+				Table: "events",
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"operations[1] (drop_rule) is a legacy operation: rules are a legacy Postgres feature; prefer triggers",
+		`operations[0] (drop_column) is a lossy operation: the values of column "email" of table "users" are deleted when the migration is completed`,
+		`operations[2] (truncate) is a lossy operation: the rows of table "events" are deleted when the migration is started and can't be restored by a rollback`,
+		`migration "cleanup" is forward-only and can't be rolled back`,
+	}, migration.Warnings())
+}
+
+func TestWarningsReportPersistentTempTables(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name: "cleanup",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: `CREATE TEMP TABLE stale AS SELECT id FROM users WHERE last_seen < now() - interval '1 year';
+					CREATE TEMP TABLE keep (id int) ON COMMIT DELETE ROWS;
+					CREATE TEMP TABLE dropped (id int) ON COMMIT DROP;
+					SELECT id INTO TEMP selected FROM users;
+					CREATE TABLE permanent (id int);
+					DELETE FROM users WHERE id IN (SELECT id FROM stale)`,
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		`operations[0] (sql) creates temporary table "stale" without ON COMMIT DROP: it outlives the migration on a pooled connection, use temp_tables instead`,
+		`operations[0] (sql) creates temporary table "keep" without ON COMMIT DROP: it outlives the migration on a pooled connection, use temp_tables instead`,
+		`operations[0] (sql) creates temporary table "selected" without ON COMMIT DROP: it outlives the migration on a pooled connection, use temp_tables instead`,
+	}, migration.Warnings())
+}
+
+func TestWarningsReportPartialOperations(t *testing.T) {
+	t.Parallel()
+
+	lz4 := migrations.OpAlterColumnCompressionLz4
+	migration := migrations.Migration{
+		Name: "compress",
+		Operations: migrations.Operations{
+			&migrations.OpAlterColumn{
+				Table:       "documents",
+				Column:      "body",
+				Compression: &lz4,
+			},
+			&migrations.OpAlterColumn{
+				Table:       "documents",
+				Column:      "title",
+				Compression: &lz4,
+				Type:        ptr("varchar(255)"),
+				Up:          "title",
+				Down:        "title",
+			},
+		},
+	}
+
+	// Only the change to the compression method alone leaves existing values
+	// as they are; the second operation rewrites the column
+	assert.Equal(t, []string{
+		`operations[0] (alter_column) is a partial operation: existing values of column "body" on table "documents" are not recompressed; only values written after the migration is started use the lz4 compression method`,
+	}, migration.Warnings())
+}
+
+func TestOperationsDependingOnLaterOperationsAreInvalid(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "orders",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "user_id", Type: "integer", References: &migrations.ForeignKeyReference{
+						Name:   "fk_users_id",
+						Table:  "users",
+						Column: "id",
+					}},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+				},
+			},
+		},
+	}
+
+	err := migration.Validate(context.TODO(), schema.New())
+	assert.ErrorIs(t, err, migrations.OperationDependencyError{
+		Operation:  "operations[0] (create_table)",
+		Dependency: `table "users"`,
+		CreatedBy:  "operations[1] (create_table)",
+	})
+}
+
+func TestSortOperations(t *testing.T) {
+	t.Parallel()
+
+	createUsers := &migrations.OpCreateTable{
+		Name:    "users",
+		Columns: []migrations.Column{{Name: "id", Type: "serial", Pk: true}},
+	}
+	createOrders := &migrations.OpCreateTable{
+		Name: "orders",
+		Columns: []migrations.Column{
+			{Name: "id", Type: "serial", Pk: true},
+			{Name: "user_id", Type: "integer", References: &migrations.ForeignKeyReference{
+				Name:   "fk_users_id",
+				Table:  "users",
+				Column: "id",
+			}},
+		},
+	}
+	createProducts := &migrations.OpCreateTable{
+		Name:    "products",
+		Columns: []migrations.Column{{Name: "id", Type: "serial", Pk: true}},
+	}
+	addOrderProduct := &migrations.OpAddColumn{
+		Table: "orders",
+		Column: migrations.Column{Name: "product_id", Type: "integer", Nullable: true, References: &migrations.ForeignKeyReference{
+			Name:   "fk_products_id",
+			Table:  "products",
+			Column: "id",
+		}},
+	}
+	createAddressType := &migrations.OpCreateType{
+		Name:       "address",
+		Attributes: []migrations.CompositeTypeAttribute{{Name: "street", Type: "text"}},
+	}
+	addUserAddress := &migrations.OpAddColumn{
+		Table:  "users",
+		Column: migrations.Column{Name: "address", Type: "address", Nullable: true},
+	}
+
+	tests := map[string]struct {
+		operations migrations.Operations
+		want       migrations.Operations
+	}{
+		"operations in dependency order are unchanged": {
+			operations: migrations.Operations{createUsers, createOrders},
+			want:       migrations.Operations{createUsers, createOrders},
+		},
+		"referenced table is created first": {
+			operations: migrations.Operations{createOrders, createUsers},
+			want:       migrations.Operations{createUsers, createOrders},
+		},
+		"independent operations keep their order": {
+			operations: migrations.Operations{addOrderProduct, createOrders, createProducts, createUsers},
+			want:       migrations.Operations{createProducts, createUsers, createOrders, addOrderProduct},
+		},
+		"type is created before columns that use it": {
+			operations: migrations.Operations{createUsers, addUserAddress, createAddressType},
+			want:       migrations.Operations{createUsers, createAddressType, addUserAddress},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			migration := migrations.Migration{Name: "01_migration", Operations: tc.operations}
+
+			err := migration.SortOperations(schema.New())
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, migration.Operations)
+
+			// The sorted migration passes validation
+			assert.NoError(t, migration.Validate(context.TODO(), schema.New()))
+		})
+	}
+}
+
+func TestSortOperationsReportsCycles(t *testing.T) {
+	t.Parallel()
+
+	migration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "a",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "b_id", Type: "integer", References: &migrations.ForeignKeyReference{Name: "fk_b", Table: "b", Column: "id"}},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "b",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "a_id", Type: "integer", References: &migrations.ForeignKeyReference{Name: "fk_a", Table: "a", Column: "id"}},
+				},
+			},
+		},
+	}
+
+	err := migration.SortOperations(schema.New())
+	assert.ErrorIs(t, err, migrations.OperationDependencyCycleError{
+		Cycle: "operations[0] (create_table) -> operations[1] (create_table) -> operations[0] (create_table)",
+	})
+}
+
 func TestCollectFilesFromDir(t *testing.T) {
 	t.Parallel()
 
@@ -170,3 +936,60 @@ func exampleMigration(t *testing.T) []byte {
 
 	return bytes
 }
+
+func TestOperationComments(t *testing.T) {
+	t.Parallel()
+
+	t.Run("comments are read alongside operations", func(t *testing.T) {
+		var ops migrations.Operations
+		err := json.Unmarshal([]byte(`[
+			{"comment": "Add the email column", "add_column": {"table": "users", "column": {"name": "email", "type": "text", "nullable": true}}},
+			{"drop_column": {"table": "users", "column": "name"}}
+		]`), &ops)
+		require.NoError(t, err)
+		require.Len(t, ops, 2)
+
+		assert.Equal(t, "Add the email column", migrations.OperationComment(ops[0]))
+		assert.Empty(t, migrations.OperationComment(ops[1]))
+
+		// Comments are kept when the operations are serialized again
+		data, err := json.Marshal(ops)
+		require.NoError(t, err)
+
+		var roundTripped migrations.Operations
+		require.NoError(t, json.Unmarshal(data, &roundTripped))
+		assert.Equal(t, "Add the email column", migrations.OperationComment(roundTripped[0]))
+		assert.Empty(t, migrations.OperationComment(roundTripped[1]))
+	})
+
+	t.Run("a comment without an operation is invalid", func(t *testing.T) {
+		var ops migrations.Operations
+		err := json.Unmarshal([]byte(`[{"comment": "Nothing to do"}]`), &ops)
+		assert.Error(t, err)
+	})
+}
+
+func TestMigrationChecksum(t *testing.T) {
+	t.Parallel()
+
+	dir := fstest.MapFS{
+		"01_add_column.json": &fstest.MapFile{Data: []byte(`{"operations": [{"add_column": {"table": "users", "up": "'none'", "column": {"name": "bio", "type": "text"}}}]}`)},
+		"01_add_column.yaml": &fstest.MapFile{Data: []byte("operations:\n  - add_column:\n      column: {name: bio, type: text}\n      table: users\n      up: \"'none'\"\n")},
+		"02_add_column.yaml": &fstest.MapFile{Data: []byte("operations:\n  - add_column:\n      column: {name: bio, type: varchar(255)}\n      table: users\n      up: \"'none'\"\n")},
+	}
+
+	checksum := func(filename string) string {
+		t.Helper()
+		mig, err := migrations.ReadMigration(dir, filename)
+		require.NoError(t, err)
+		sum, err := mig.Checksum()
+		require.NoError(t, err)
+		return sum
+	}
+
+	// The same migration in another format has the same checksum
+	assert.Equal(t, checksum("01_add_column.json"), checksum("01_add_column.yaml"))
+
+	// A different migration has a different checksum
+	assert.NotEqual(t, checksum("01_add_column.yaml"), checksum("02_add_column.yaml"))
+}
@@ -4,9 +4,11 @@ package migrations
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
+
+	pgq "github.com/xataio/pg_query_go/v6"
 
 	"github.com/xataio/pgroll/internal/defaults"
 	"github.com/xataio/pgroll/pkg/backfill"
@@ -27,6 +29,27 @@ func (o *OpAddColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schema
 		return nil, TableDoesNotExistError{Name: o.Table}
 	}
 
+	// Qualify the sequences used by the DEFAULT, so that the trigger that
+	// backfills it finds them whatever the search_path of the session that
+	// fires the trigger
+	if o.Column.HasDefault() {
+		qualified, err := defaults.QualifySequences(ctx, conn, *o.Column.Default)
+		if err != nil {
+			return nil, fmt.Errorf("failed to qualify sequences in default: %w", err)
+		}
+		if o.Up == *o.Column.Default {
+			o.Up = qualified
+		}
+		o.Column.Default = &qualified
+	}
+
+	// Check the up SQL before the table is changed. The up SQL can refer to
+	// the new column, which doesn't exist yet
+	if err := checkColumnSQL(ctx, conn, table, o.Column.Name, backfill.TriggerDirectionUp, o.Up,
+		map[string]string{o.Column.Name: o.Column.Type}); err != nil {
+		return nil, err
+	}
+
 	// If the column has a DEFAULT, check if it can be added using the fast path
 	// optimization
 	fastPathDefault := false
@@ -38,28 +61,41 @@ func (o *OpAddColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schema
 		fastPathDefault = v
 	}
 
-	action, err := addColumn(conn, *o, table, fastPathDefault)
+	// The generation expression refers to the physical columns of the table,
+	// so it can't reference a column that is added or changed by the migration
+	if o.Column.IsGenerated() {
+		if err := checkGeneratedColumnReferences(table, o.Column.Name, o.Column.Generated.Expression); err != nil {
+			return nil, err
+		}
+	}
+
+	action, err := addColumn(ctx, conn, *o, table, fastPathDefault)
 	if err != nil {
 		return nil, err
 	}
 	dbActions := []DBAction{action}
 
 	if o.Column.Comment != nil {
-		dbActions = append(dbActions, NewCommentColumnAction(conn, table.Name, TemporaryName(o.Column.Name), o.Column.Comment))
+		dbActions = append(dbActions, NewCommentColumnAction(conn, table.Name, NamingFrom(ctx).TemporaryName(o.Column.Name), o.Column.Comment))
 	}
 
 	// If the column is `NOT NULL` and there is no default value (either because
 	// the column as no DEFAULT or because the default value cannot be set using
 	// the fast path optimization), add a NOT NULL constraint to the column which
 	// will be validated on migration completion.
+	//
+	// If the new column was left behind by an earlier, interrupted attempt to
+	// start the migration, its constraints may already exist too.
+	resuming := table.GetColumn(NamingFrom(ctx).TemporaryName(o.Column.Name)) != nil
 	skipInherit := false
 	skipValidate := true
-	if !o.Column.IsNullable() && (o.Column.Default == nil || !fastPathDefault) {
+	if !o.Column.IsNullable() && (o.Column.Default == nil || !fastPathDefault) &&
+		!(resuming && table.ConstraintExists(NamingFrom(ctx).NotNullConstraintName(o.Column.Name))) {
 		dbActions = append(dbActions,
 			NewCreateCheckConstraintAction(
 				conn,
 				table.Name,
-				NotNullConstraintName(o.Column.Name),
+				NamingFrom(ctx).NotNullConstraintName(o.Column.Name),
 				fmt.Sprintf("%s IS NOT NULL", o.Column.Name),
 				[]string{o.Column.Name},
 				skipInherit,
@@ -67,7 +103,7 @@ func (o *OpAddColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schema
 			))
 	}
 
-	if o.Column.Check != nil {
+	if o.Column.Check != nil && !(resuming && table.ConstraintExists(o.Column.Check.Name)) {
 		dbActions = append(dbActions,
 			NewCreateCheckConstraintAction(
 				conn,
@@ -80,17 +116,6 @@ func (o *OpAddColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schema
 			))
 	}
 
-	if o.Column.Unique {
-		dbActions = append(dbActions,
-			NewCreateUniqueIndexConcurrentlyAction(
-				conn,
-				s.Name,
-				UniqueIndexName(o.Column.Name),
-				table.Name,
-				TemporaryName(o.Column.Name),
-			))
-	}
-
 	// If the column has a DEFAULT that cannot be set using the fast path
 	// optimization, the `up` SQL expression must be used to set the DEFAULT
 	// value for the column.
@@ -104,18 +129,20 @@ func (o *OpAddColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schema
 	if o.Up != "" {
 		task = backfill.NewTask(table,
 			backfill.OperationTrigger{
-				Name:           backfill.TriggerName(o.Table, o.Column.Name),
+				Name:           NamingFrom(ctx).TriggerName(o.Table, o.Column.Name),
 				Direction:      backfill.TriggerDirectionUp,
 				Columns:        table.Columns,
 				TableName:      table.Name,
-				PhysicalColumn: TemporaryName(o.Column.Name),
+				PhysicalColumn: NamingFrom(ctx).TemporaryName(o.Column.Name),
 				SQL:            o.Up,
+				Statements:     o.UpTriggerStatements,
 			},
 		)
+		task.SetFilter(o.BackfillWhere)
 	}
 
 	tmpColumn := toSchemaColumn(o.Column)
-	tmpColumn.Name = TemporaryName(o.Column.Name)
+	tmpColumn.Name = NamingFrom(ctx).TemporaryName(o.Column.Name)
 	table.AddColumn(o.Column.Name, tmpColumn)
 
 	return &StartResult{Actions: dbActions, BackfillTask: task}, nil
@@ -132,20 +159,35 @@ func toSchemaColumn(c Column) *schema.Column {
 	if c.Comment != nil {
 		tmpColumn.Comment = *c.Comment
 	}
+	if c.Generated != nil && c.Generated.Expression != "" {
+		tmpColumn.Generated = &c.Generated.Expression
+	}
 	return tmpColumn
 }
 
-func (o *OpAddColumn) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpAddColumn) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
-	dbActions := []DBAction{
-		NewRenameColumnAction(conn, o.Table, TemporaryName(o.Column.Name), o.Column.Name),
-		NewDropFunctionAction(conn, backfill.TriggerFunctionName(o.Table, o.Column.Name)),
-		NewDropColumnAction(conn, o.Table, backfill.CNeedsBackfillColumn),
+	var dbActions []DBAction
+
+	// The unique index for a unique column is built once the column has been
+	// backfilled. Both actions are non-blocking, so they run before the rest
+	// of the migration is completed and a failure leaves the migration active.
+	if o.Column.Unique {
+		dbActions = append(dbActions,
+			NewCheckUniqueValuesAction(conn, o.Table, NamingFrom(ctx).TemporaryName(o.Column.Name), NamingFrom(ctx).UniqueIndexName(o.Column.Name), o.Column.Name),
+			NewCreateUniqueIndexConcurrentlyAction(conn, s.Name, NamingFrom(ctx).UniqueIndexName(o.Column.Name), o.Table, NamingFrom(ctx).TemporaryName(o.Column.Name)),
+		)
 	}
 
+	dbActions = append(dbActions,
+		NewRenameColumnAction(conn, o.Table, NamingFrom(ctx).TemporaryName(o.Column.Name), o.Column.Name),
+		NewDropFunctionAction(conn, NamingFrom(ctx).TriggerFunctionName(o.Table, o.Column.Name)),
+		NewDropNeedsBackfillColumnAction(conn, o.Table),
+	)
+
 	if !o.Column.IsNullable() && o.Column.Default == nil {
-		dbActions = append(dbActions, upgradeNotNullConstraintToNotNullAttribute(conn, o.Table, o.Column.Name)...)
+		dbActions = append(dbActions, upgradeNotNullConstraintToNotNullAttribute(ctx, conn, o.Table, o.Column.Name)...)
 	}
 
 	if o.Column.Check != nil {
@@ -156,25 +198,27 @@ func (o *OpAddColumn) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBActi
 		dbActions = append(dbActions, NewAddConstraintUsingUniqueIndex(conn,
 			o.Table,
 			o.Column.Name,
-			UniqueIndexName(o.Column.Name)))
+			NamingFrom(ctx).UniqueIndexName(o.Column.Name),
+			false,
+			false))
 	}
 
 	// If the column has a DEFAULT that could not be set using the fast-path
 	// optimization, set it here.
-	column := s.GetTable(o.Table).GetColumn(TemporaryName(o.Column.Name))
+	column := s.GetTable(o.Table).GetColumn(NamingFrom(ctx).TemporaryName(o.Column.Name))
 	if o.Column.HasDefault() && column.Default == nil {
 		dbActions = append(dbActions, NewSetDefaultValueAction(conn, o.Table, o.Column.Name, *o.Column.Default))
 
 		// Validate the `NOT NULL` constraint on the column if necessary
 		if !o.Column.IsNullable() {
-			dbActions = append(dbActions, upgradeNotNullConstraintToNotNullAttribute(conn, o.Table, o.Column.Name)...)
+			dbActions = append(dbActions, upgradeNotNullConstraintToNotNullAttribute(ctx, conn, o.Table, o.Column.Name)...)
 		}
 	}
 
 	return dbActions, nil
 }
 
-func (o *OpAddColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpAddColumn) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	table := s.GetTable(o.Table)
@@ -188,8 +232,8 @@ func (o *OpAddColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBActi
 
 	return []DBAction{
 		NewDropColumnAction(conn, table.Name, column.Name),
-		NewDropFunctionAction(conn, backfill.TriggerFunctionName(o.Table, o.Column.Name)),
-		NewDropColumnAction(conn, table.Name, backfill.CNeedsBackfillColumn),
+		NewDropFunctionAction(conn, NamingFrom(ctx).TriggerFunctionName(o.Table, o.Column.Name)),
+		NewDropNeedsBackfillColumnAction(conn, table.Name),
 	}, nil
 }
 
@@ -203,6 +247,10 @@ func (o *OpAddColumn) Validate(ctx context.Context, s *schema.Schema) error {
 		return ColumnIsInvalidError{Table: o.Table, Name: o.Column.Name}
 	}
 
+	if err := validateTypeName(o.Table, o.Column.Name, o.Column.Type); err != nil {
+		return err
+	}
+
 	table := s.GetTable(o.Table)
 	if table == nil {
 		return TableDoesNotExistError{Name: o.Table}
@@ -236,6 +284,25 @@ func (o *OpAddColumn) Validate(ctx context.Context, s *schema.Schema) error {
 		return InvalidGeneratedColumnError{Table: o.Table, Column: o.Column.Name}
 	}
 
+	if o.Column.Generated != nil && o.Column.Generated.Identity != nil {
+		return errors.New("adding identity columns is not supported")
+	}
+
+	// Postgres computes the values of generated columns, both for the existing
+	// rows and for the rows written while the migration is active, so they
+	// can't have a default or be backfilled with `up` SQL
+	if o.Column.IsGenerated() {
+		if o.Column.Default != nil {
+			return GeneratedColumnConflictError{Table: o.Table, Column: o.Column.Name, Field: "default"}
+		}
+		if o.Up != "" {
+			return GeneratedColumnConflictError{Table: o.Table, Column: o.Column.Name, Field: "up"}
+		}
+		if err := validateGeneratedExpression(table, o.Column.Name, o.Column.Generated.Expression); err != nil {
+			return err
+		}
+	}
+
 	if !o.Column.IsNullable() && o.Column.Default == nil && o.Up == "" && !o.Column.HasImplicitDefault() && o.Column.Generated == nil {
 		return FieldRequiredError{Name: "up"}
 	}
@@ -244,16 +311,41 @@ func (o *OpAddColumn) Validate(ctx context.Context, s *schema.Schema) error {
 		return errors.New("adding primary key columns is not supported")
 	}
 
+	if o.Up != "" {
+		if err := validateSubqueryTables(s, o.Up); err != nil {
+			return err
+		}
+	}
+
+	if o.BackfillWhere != "" {
+		if o.Up == "" {
+			return FieldRequiredError{Name: "up"}
+		}
+		if err := validateBackfillWhere(table, o.BackfillWhere); err != nil {
+			return err
+		}
+	}
+
+	if o.UpTriggerStatements != "" {
+		if o.Up == "" {
+			return FieldRequiredError{Name: "up"}
+		}
+		if err := validateTriggerStatements(ctx, o.Table, o.Column.Name, o.UpTriggerStatements); err != nil {
+			return err
+		}
+	}
+
 	// Update the schema to ensure that the new column is visible to validation of
 	// subsequent operations.
 	table.AddColumn(o.Column.Name, &schema.Column{
-		Name: TemporaryName(o.Column.Name),
+		Name:      NamingFrom(ctx).TemporaryName(o.Column.Name),
+		Generated: toSchemaColumn(o.Column).Generated,
 	})
 
 	return nil
 }
 
-func addColumn(conn db.DB, o OpAddColumn, t *schema.Table, fastPathDefault bool) (DBAction, error) {
+func addColumn(ctx context.Context, conn db.DB, o OpAddColumn, t *schema.Table, fastPathDefault bool) (DBAction, error) {
 	// don't add non-nullable columns with no default directly
 	// they are handled by:
 	// - adding the column as nullable
@@ -265,8 +357,8 @@ func addColumn(conn db.DB, o OpAddColumn, t *schema.Table, fastPathDefault bool)
 		o.Column.Nullable = true
 	}
 
-	if o.Column.Generated != nil {
-		return nil, fmt.Errorf("adding generated columns to existing tables is not supported")
+	if o.Column.Generated != nil && o.Column.Generated.Identity != nil {
+		return nil, fmt.Errorf("adding identity columns to existing tables is not supported")
 	}
 
 	// Don't add a column with a CHECK constraint directly.
@@ -296,34 +388,72 @@ func addColumn(conn db.DB, o OpAddColumn, t *schema.Table, fastPathDefault bool)
 		o.Column.Nullable = true
 	}
 
-	o.Column.Name = TemporaryName(o.Column.Name)
+	o.Column.Name = NamingFrom(ctx).TemporaryName(o.Column.Name)
 
 	withPK := true
 	return NewAddColumnAction(conn, t.Name, o.Column, withPK), nil
 }
 
+// checkGeneratedColumnReferences returns an error if the generation expression
+// of a new column references a column that is added or changed by the
+// migration. Such columns only exist under a temporary name until the
+// migration completes, while the expression is evaluated against the physical
+// columns of the table.
+func checkGeneratedColumnReferences(table *schema.Table, column, expression string) error {
+	jsonTree, err := pgq.ParseToJSON("SELECT " + expression)
+	if err != nil {
+		return InvalidGeneratedExpressionError{Table: table.Name, Column: column, Expression: expression, Reason: "it is not a valid expression"}
+	}
+	var node any
+	if err := json.Unmarshal([]byte(jsonTree), &node); err != nil {
+		return InvalidGeneratedExpressionError{Table: table.Name, Column: column, Expression: expression, Reason: "it is not a valid expression"}
+	}
+
+	for _, ref := range columnRefs(node) {
+		name := ref[len(ref)-1]
+		if referenced := table.GetColumn(name); referenced != nil && referenced.Name != name {
+			return InvalidGeneratedExpressionError{
+				Table:      table.Name,
+				Column:     column,
+				Expression: expression,
+				Reason:     fmt.Sprintf("it references column %q, which is added or changed by the migration", name),
+			}
+		}
+	}
+	return nil
+}
+
 // upgradeNotNullConstraintToNotNullAttribute validates and upgrades a NOT NULL
 // constraint to a NOT NULL column attribute. The constraint is removed after
 // the column attribute is added.
-func upgradeNotNullConstraintToNotNullAttribute(conn db.DB, tableName, columnName string) []DBAction {
+func upgradeNotNullConstraintToNotNullAttribute(ctx context.Context, conn db.DB, tableName, columnName string) []DBAction {
 	return []DBAction{
-		NewValidateConstraintAction(conn, tableName, NotNullConstraintName(columnName)),
+		NewValidateConstraintAction(conn, tableName, NamingFrom(ctx).NotNullConstraintName(columnName)),
 		NewSetNotNullAction(conn, tableName, columnName),
-		NewDropConstraintAction(conn, tableName, NotNullConstraintName(columnName)),
+		NewDropConstraintAction(conn, tableName, NamingFrom(ctx).NotNullConstraintName(columnName)),
 	}
 }
 
-// UniqueIndexName returns the name of the unique index for the given column
+// UniqueIndexName returns the name of the unique index for the given column,
+// with the default naming.
 func UniqueIndexName(columnName string) string {
-	return "_pgroll_uniq_" + columnName
+	return backfill.Naming{}.UniqueIndexName(columnName)
 }
 
-// NotNullConstraintName returns the name of the NOT NULL constraint for the given column
+// NotNullConstraintName returns the name of the NOT NULL constraint for the
+// given column, with the default naming.
 func NotNullConstraintName(columnName string) string {
-	return "_pgroll_check_not_null_" + columnName
+	return backfill.Naming{}.NotNullConstraintName(columnName)
 }
 
-// IsNotNullConstraintName returns true if the given name is a NOT NULL constraint name
+// IsNotNullConstraintName returns true if the given name is a NOT NULL
+// constraint name, with the default naming.
 func IsNotNullConstraintName(name string) bool {
-	return strings.HasPrefix(name, "_pgroll_check_not_null_")
+	return backfill.Naming{}.IsNotNullConstraintName(name)
+}
+
+// StripNotNullConstraintPrefix returns the name of the column that the given
+// NOT NULL constraint name was created for, with the default naming.
+func StripNotNullConstraintPrefix(name string) string {
+	return backfill.Naming{}.StripNotNullConstraintPrefix(name)
 }
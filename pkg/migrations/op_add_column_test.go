@@ -12,6 +12,7 @@ import (
 	"github.com/xataio/pgroll/internal/testutils"
 	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
 )
 
 func TestAddColumn(t *testing.T) {
@@ -1226,6 +1227,379 @@ func TestAddColumnWithUpSql(t *testing.T) {
 	})
 }
 
+func TestAddColumnWithBackfillWhere(t *testing.T) {
+	t.Parallel()
+
+	createProductsMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "products",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "varchar(255)",
+					},
+					{
+						Name: "active",
+						Type: "boolean",
+					},
+				},
+			},
+		},
+	}
+
+	insertProductsMigration := migrations.Migration{
+		Name: "02_insert_products",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: "INSERT INTO products (name, active) VALUES ('apple', true), ('banana', false)",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "only rows matching backfill_where are backfilled",
+			migrations: []migrations.Migration{
+				createProductsMigration,
+				insertProductsMigration,
+				{
+					Name: "03_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table:         "products",
+							Up:            "UPPER(name)",
+							BackfillWhere: "active",
+							Column: migrations.Column{
+								Name:     "description",
+								Type:     "varchar(255)",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				res := MustSelect(t, db, schema, "03_add_column", "products")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "apple", "active": true, "description": "APPLE"},
+					{"id": 2, "name": "banana", "active": false, "description": nil},
+				}, res)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustNotExist(t, db, schema, "products", migrations.TemporaryName("description"))
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				res := MustSelect(t, db, schema, "03_add_column", "products")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "apple", "active": true, "description": "APPLE"},
+					{"id": 2, "name": "banana", "active": false, "description": nil},
+				}, res)
+			},
+		},
+		{
+			name: "backfill_where must only reference columns of the table",
+			migrations: []migrations.Migration{
+				createProductsMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table:         "products",
+							Up:            "UPPER(name)",
+							BackfillWhere: "discontinued = false",
+							Column: migrations.Column{
+								Name:     "description",
+								Type:     "varchar(255)",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnDoesNotExistError{Table: "products", Name: "discontinued"},
+		},
+		{
+			name: "backfill_where must be a single condition",
+			migrations: []migrations.Migration{
+				createProductsMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table:         "products",
+							Up:            "UPPER(name)",
+							BackfillWhere: "active LIMIT 1",
+							Column: migrations.Column{
+								Name:     "description",
+								Type:     "varchar(255)",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidBackfillWhereError{Table: "products", Where: "active LIMIT 1"},
+		},
+		{
+			name: "backfill_where requires up SQL",
+			migrations: []migrations.Migration{
+				createProductsMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table:         "products",
+							BackfillWhere: "active",
+							Column: migrations.Column{
+								Name:     "description",
+								Type:     "varchar(255)",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "up"},
+		},
+	})
+}
+
+func TestAddColumnWithUpTriggerStatements(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_add_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "products",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "name", Type: "varchar(255)"},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "product_log",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "product_id", Type: "integer"},
+					{Name: "name", Type: "varchar(255)"},
+				},
+			},
+		},
+	}
+
+	insertProductsMigration := migrations.Migration{
+		Name: "02_insert_products",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: "INSERT INTO products (name) VALUES ('apple'), ('banana')",
+			},
+		},
+	}
+
+	addColumn := func(stmts string) *migrations.OpAddColumn {
+		return &migrations.OpAddColumn{
+			Table:               "products",
+			Up:                  "UPPER(name)",
+			UpTriggerStatements: stmts,
+			Column: migrations.Column{
+				Name:     "description",
+				Type:     "varchar(255)",
+				Nullable: true,
+			},
+		}
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "up trigger statements run for backfilled rows and writes to the old version",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				insertProductsMigration,
+				{
+					Name: "03_add_column",
+					Operations: migrations.Operations{
+						addColumn("INSERT INTO product_log (product_id, name) VALUES (NEW.id, NEW.name);"),
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				MustInsert(t, db, schema, "02_insert_products", "products", map[string]string{
+					"name": "cherry",
+				})
+
+				res := MustSelect(t, db, schema, "03_add_column", "product_log")
+				assert.ElementsMatch(t, []map[string]any{
+					{"id": 1, "product_id": 1, "name": "apple"},
+					{"id": 2, "product_id": 2, "name": "banana"},
+					{"id": 3, "product_id": 3, "name": "cherry"},
+				}, res)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustNotExist(t, db, schema, "products", migrations.TemporaryName("description"))
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				res := MustSelect(t, db, schema, "03_add_column", "products")
+				assert.ElementsMatch(t, []map[string]any{
+					{"id": 1, "name": "apple", "description": "APPLE"},
+					{"id": 2, "name": "banana", "description": "BANANA"},
+					{"id": 3, "name": "cherry", "description": "CHERRY"},
+				}, res)
+			},
+		},
+		{
+			name: "up trigger statements must not return",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						addColumn("IF NEW.name IS NULL THEN RETURN NULL; END IF;"),
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidTriggerStatementsError{
+				Table:  "products",
+				Column: "description",
+				Reason: "they must not return from the trigger function",
+			},
+		},
+		{
+			name: "up trigger statements require up SQL",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table:               "products",
+							UpTriggerStatements: "NEW.name := lower(NEW.name);",
+							Column: migrations.Column{
+								Name:     "description",
+								Type:     "varchar(255)",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "up"},
+		},
+	})
+}
+
+func TestAddColumnWithUpSubquery(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "authors",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+					{Name: "name", Type: "text"},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "books",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+					{Name: "title", Type: "text"},
+					{Name: "author_id", Type: "integer"},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "add column populated from a correlated subquery on another table",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_insert_rows",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "INSERT INTO authors VALUES (1, 'Ursula'), (2, 'Terry'); INSERT INTO books VALUES (1, 'Earthsea', 1)",
+						},
+					},
+				},
+				{
+					Name: "03_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table: "books",
+							// `id` and `name` are columns of both tables; in the subquery
+							// they refer to the columns of authors
+							Up: "(SELECT name FROM authors WHERE id = author_id)",
+							Column: migrations.Column{
+								Name:     "author_name",
+								Type:     "text",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Inserting via the old view fires the trigger, which evaluates the
+				// subquery for the new row
+				MustInsert(t, db, schema, "02_insert_rows", "books", map[string]string{
+					"id":        "2",
+					"title":     "Mort",
+					"author_id": "2",
+				})
+
+				// The existing row was backfilled from the subquery
+				res := MustSelect(t, db, schema, "03_add_column", "books")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "title": "Earthsea", "author_id": 1, "author_name": "Ursula"},
+					{"id": 2, "title": "Mort", "author_id": 2, "author_name": "Terry"},
+				}, res)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "books", "author_name")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				res := MustSelect(t, db, schema, "03_add_column", "books")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "title": "Earthsea", "author_id": 1, "author_name": "Ursula"},
+					{"id": 2, "title": "Mort", "author_id": 2, "author_name": "Terry"},
+				}, res)
+			},
+		},
+		{
+			name: "up SQL must only select from tables that exist",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table: "books",
+							Up:    "(SELECT name FROM publishers WHERE publishers.id = author_id)",
+							Column: migrations.Column{
+								Name:     "publisher_name",
+								Type:     "text",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "publishers"},
+		},
+	})
+}
+
 func TestAddNotNullColumnWithNoDefault(t *testing.T) {
 	t.Parallel()
 
@@ -1782,6 +2156,100 @@ func TestAddColumnWithVolatileAndNonVolatileDefaults(t *testing.T) {
 	})
 }
 
+func TestAddColumnWithSequenceDefaultInAnotherSchema(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "add not null column defaulting to a sequence in a schema on the search_path",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_sequence",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "CREATE SCHEMA shared; CREATE SEQUENCE shared.ticket_seq",
+						},
+					},
+				},
+				{
+					Name: "02_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "users",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "integer",
+									Pk:   true,
+								},
+								{
+									Name: "name",
+									Type: "text",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "03_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table: "users",
+							Up:    "nextval('ticket_seq')",
+							Column: migrations.Column{
+								Name:    "ticket",
+								Type:    "bigint",
+								Default: ptr("nextval('ticket_seq')"),
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Inserting via the old view fires the trigger that backfills the
+				// column, although the shared schema isn't on the search_path of
+				// the session
+				MustInsert(t, db, schema, "02_create_table", "users", map[string]string{
+					"id":   "1",
+					"name": "alice",
+				})
+
+				// Inserting via the new view uses the default of the view
+				MustInsert(t, db, schema, "03_add_column", "users", map[string]string{
+					"id":   "2",
+					"name": "bob",
+				})
+
+				res := MustSelect(t, db, schema, "03_add_column", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "alice", "ticket": 1},
+					{"id": 2, "name": "bob", "ticket": 2},
+				}, res)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "users", "ticket")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The default of the column still refers to the sequence in the
+				// shared schema
+				MustInsert(t, db, schema, "03_add_column", "users", map[string]string{
+					"id":   "3",
+					"name": "carl",
+				})
+
+				// The rows left from before the rollback were backfilled from the
+				// sequence when the migration was restarted
+				res := MustSelect(t, db, schema, "03_add_column", "users")
+				tickets := make([]any, 0, len(res))
+				for _, row := range res {
+					tickets = append(tickets, row["ticket"])
+				}
+				assert.ElementsMatch(t, []any{3, 4, 5}, tickets)
+			},
+		},
+	}, roll.WithSearchPath("shared"))
+}
+
 func TestAddColumnValidation(t *testing.T) {
 	t.Parallel()
 
@@ -2122,6 +2590,112 @@ func TestAddColumnWithCheckConstraint(t *testing.T) {
 	}})
 }
 
+func TestAddUniqueColumn(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "varchar(255)",
+					},
+				},
+			},
+		},
+	}
+
+	addUniqueColumnMigration := migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "users",
+				Up:    "lower(name)",
+				Column: migrations.Column{
+					Name:     "username",
+					Type:     "varchar(255)",
+					Nullable: true,
+					Unique:   true,
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "unique index is built and attached on completion",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				addUniqueColumnMigration,
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The unique index is not built until the column has been backfilled
+				// and the migration is completed
+				IndexMustNotExist(t, db, schema, "users", migrations.UniqueIndexName("username"))
+
+				MustInsert(t, db, schema, "01_add_table", "users", map[string]string{
+					"name": "Alice",
+				})
+				MustInsert(t, db, schema, "02_add_column", "users", map[string]string{
+					"name":     "Bob",
+					"username": "bob",
+				})
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The table has been cleaned up
+				TableMustBeCleanedUp(t, db, schema, "users", "username")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The unique constraint has been added to the column
+				UniqueConstraintMustExist(t, db, schema, "users", "username")
+
+				// Inserting a duplicate username fails
+				MustNotInsert(t, db, schema, "02_add_column", "users", map[string]string{
+					"name":     "Alice Smith",
+					"username": "alice",
+				}, testutils.UniqueViolationErrorCode)
+
+				// Multiple NULL usernames are allowed
+				MustInsert(t, db, schema, "02_add_column", "users", map[string]string{
+					"name": "Carl",
+				})
+				MustInsert(t, db, schema, "02_add_column", "users", map[string]string{
+					"name": "Dana",
+				})
+			},
+		},
+		{
+			name: "completion fails and reports duplicate values",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				addUniqueColumnMigration,
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Both names are backfilled to the same username
+				MustInsert(t, db, schema, "01_add_table", "users", map[string]string{
+					"name": "Alice",
+				})
+				MustInsert(t, db, schema, "01_add_table", "users", map[string]string{
+					"name": "alice",
+				})
+			},
+			wantCompleteErr: migrations.DuplicateValuesError{
+				Table:  "users",
+				Column: "username",
+				Values: "alice",
+			},
+		},
+	})
+}
+
 func TestAddColumnWithComment(t *testing.T) {
 	t.Parallel()
 
@@ -2178,6 +2752,172 @@ func TestAddColumnWithComment(t *testing.T) {
 	}})
 }
 
+func TestAddGeneratedColumn(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_create_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "products",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "price",
+						Type: "integer",
+					},
+					{
+						Name: "quantity",
+						Type: "integer",
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "add a stored generated column",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_insert_product",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "INSERT INTO products (price, quantity) VALUES (25, 4)",
+						},
+					},
+				},
+				{
+					Name: "03_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table: "products",
+							Column: migrations.Column{
+								Name:      "total",
+								Type:      "integer",
+								Generated: &migrations.ColumnGenerated{Expression: "price * quantity"},
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Postgres computes the column for rows written through the old
+				// version of the schema
+				MustInsert(t, db, schema, "02_insert_product", "products", map[string]string{
+					"price":    "3",
+					"quantity": "2",
+				})
+
+				// The column is computed for existing rows without a backfill
+				res := MustSelect(t, db, schema, "03_add_column", "products")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 25, "quantity": 4, "total": 100},
+					{"id": 2, "price": 3, "quantity": 2, "total": 6},
+				}, res)
+
+				// No trigger is created for the generated column
+				TriggerMustNotExist(t, db, schema, "products", backfill.TriggerName("products", "total"))
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustNotExist(t, db, schema, "products", migrations.TemporaryName("total"))
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				MustInsert(t, db, schema, "03_add_column", "products", map[string]string{
+					"price":    "1",
+					"quantity": "5",
+				})
+
+				res := MustSelect(t, db, schema, "03_add_column", "products")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 25, "quantity": 4, "total": 100},
+					{"id": 2, "price": 3, "quantity": 2, "total": 6},
+					{"id": 3, "price": 1, "quantity": 5, "total": 5},
+				}, res)
+			},
+		},
+		{
+			name: "generated columns can't have a default",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table: "products",
+							Column: migrations.Column{
+								Name:      "total",
+								Type:      "integer",
+								Default:   ptr("0"),
+								Generated: &migrations.ColumnGenerated{Expression: "price * quantity"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.GeneratedColumnConflictError{Table: "products", Column: "total", Field: "default"},
+		},
+		{
+			name: "generated columns can't have up SQL",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table: "products",
+							Up:    "price * quantity",
+							Column: migrations.Column{
+								Name:      "total",
+								Type:      "integer",
+								Generated: &migrations.ColumnGenerated{Expression: "price * quantity"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.GeneratedColumnConflictError{Table: "products", Column: "total", Field: "up"},
+		},
+		{
+			name: "generated columns can't reference columns changed by the migration",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "products",
+							Column: "quantity",
+							Type:   ptr("bigint"),
+							Up:     "quantity",
+							Down:   "quantity",
+						},
+						&migrations.OpAddColumn{
+							Table: "products",
+							Column: migrations.Column{
+								Name:      "total",
+								Type:      "integer",
+								Generated: &migrations.ColumnGenerated{Expression: "price * quantity"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidGeneratedExpressionError{
+				Table:      "products",
+				Column:     "total",
+				Expression: "price * quantity",
+				Reason:     `it references column "quantity", which is added or changed by the migration`,
+			},
+		},
+	})
+}
+
 func TestAddColumnInMultiOperationMigrations(t *testing.T) {
 	t.Parallel()
 
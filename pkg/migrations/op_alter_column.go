@@ -29,11 +29,66 @@ func (o *OpAlterColumn) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 	if column == nil {
 		return nil, ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
 	}
-	ops := o.subOperations()
+	ops, err := o.subOperations()
+	if err != nil {
+		return nil, err
+	}
+
+	// A new compression method only applies to the values written after it is
+	// set, so a change to it alone is made to the column itself rather than to
+	// a duplicate of the column that has to be backfilled.
+	if o.setsCompressionOnly() {
+		if err := checkCompressionSupported(ctx, conn, o.Table, o.Column); err != nil {
+			return nil, err
+		}
+		column.Compression = compressionMethod(*o.Compression)
+		return &StartResult{Actions: []DBAction{
+			NewAlterColumnCompressionAction(conn, table.Name, column.Name, string(*o.Compression)),
+		}}, nil
+	}
+
+	// A change to the nullability alone can be made to the column itself,
+	// without a duplicate of the column to backfill
+	if o.InPlace {
+		return o.startInPlace(ctx, conn, table, column), nil
+	}
+
+	// Generated columns can't be written to, so there is no trigger to copy
+	// values to the new column if it is generated, or from the new column if
+	// the old one is.
+	setGenerated := setGeneratedOperation(ops)
+	toGenerated := setGenerated != nil && setGenerated.Expression != nil
+	fromGenerated := setGenerated != nil && setGenerated.Expression == nil
+
+	// Check the up and down SQL before the column is duplicated. In the down
+	// SQL, the column refers to the new column, which has the new type
+	if !toGenerated {
+		if err := checkColumnSQL(ctx, conn, table, o.Column, backfill.TriggerDirectionUp, o.Up, nil); err != nil {
+			return nil, err
+		}
+	}
+	if !fromGenerated {
+		var newColumns map[string]string
+		if o.Type != nil {
+			newColumns = map[string]string{o.Column: *o.Type}
+		}
+		if err := checkColumnSQL(ctx, conn, table, o.Column, backfill.TriggerDirectionDown, o.Down, newColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	upSQL, err := o.upSQLForOperations(ops, column)
+	if err != nil {
+		return nil, err
+	}
+	downSQL, err := o.downSQLForOperations(ops)
+	if err != nil {
+		return nil, err
+	}
 
 	// Duplicate the column on the underlying table.
 	d := duplicatorForOperations(ops, conn, table, column).
-		WithName(column.Name, TemporaryName(o.Column))
+		WithName(column.Name, NamingFrom(ctx).TemporaryName(o.Column))
 	if err := d.Execute(ctx); err != nil {
 		return nil, fmt.Errorf("failed to duplicate column: %w", err)
 	}
@@ -47,16 +102,19 @@ func (o *OpAlterColumn) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 
 	// Add a trigger to copy values from the old column to the new, rewriting values using the `up` SQL.
 	triggers := make([]backfill.OperationTrigger, 0)
-	triggers = append(triggers,
-		backfill.OperationTrigger{
-			Name:           backfill.TriggerName(o.Table, o.Column),
-			Direction:      backfill.TriggerDirectionUp,
-			TableName:      table.Name,
-			Columns:        upColumns,
-			PhysicalColumn: TemporaryName(o.Column),
-			SQL:            o.upSQLForOperations(ops),
-		},
-	)
+	if !toGenerated {
+		triggers = append(triggers,
+			backfill.OperationTrigger{
+				Name:           NamingFrom(ctx).TriggerName(o.Table, o.Column),
+				Direction:      backfill.TriggerDirectionUp,
+				TableName:      table.Name,
+				Columns:        upColumns,
+				PhysicalColumn: NamingFrom(ctx).TemporaryName(o.Column),
+				SQL:            upSQL,
+				Statements:     o.UpTriggerStatements,
+			},
+		)
+	}
 
 	// Add the new column to the internal schema representation. This is done
 	// here, before creation of the down trigger, so that the trigger can declare
@@ -64,21 +122,25 @@ func (o *OpAlterColumn) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 	// physical column name. in the down trigger first.
 	oldPhysicalColumn := column.Name
 	table.AddColumn(o.Column, &schema.Column{
-		Name: TemporaryName(o.Column),
+		Name: NamingFrom(ctx).TemporaryName(o.Column),
 	})
 
 	// Add a trigger to copy values from the new column to the old.
-	triggers = append(triggers,
-		backfill.OperationTrigger{
-			Name:           backfill.TriggerName(o.Table, TemporaryName(o.Column)),
-			Direction:      backfill.TriggerDirectionDown,
-			TableName:      table.Name,
-			Columns:        table.Columns,
-			PhysicalColumn: oldPhysicalColumn,
-			SQL:            o.downSQLForOperations(ops),
-		},
-	)
+	if !fromGenerated {
+		triggers = append(triggers,
+			backfill.OperationTrigger{
+				Name:           NamingFrom(ctx).TriggerName(o.Table, NamingFrom(ctx).TemporaryName(o.Column)),
+				Direction:      backfill.TriggerDirectionDown,
+				TableName:      table.Name,
+				Columns:        table.Columns,
+				PhysicalColumn: oldPhysicalColumn,
+				SQL:            downSQL,
+				Statements:     o.DownTriggerStatements,
+			},
+		)
+	}
 	task := backfill.NewTask(table, triggers...)
+	task.SetFilter(o.BackfillWhere)
 
 	var dbActions []DBAction
 	// perform any operation specific start steps
@@ -94,15 +156,26 @@ func (o *OpAlterColumn) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 	return &StartResult{Actions: dbActions, BackfillTask: task}, nil
 }
 
-func (o *OpAlterColumn) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpAlterColumn) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
-	ops := o.subOperations()
+	if o.setsCompressionOnly() {
+		return nil, nil
+	}
+
+	if o.InPlace {
+		return o.completeInPlace(ctx, conn, s)
+	}
+
+	ops, err := o.subOperations()
+	if err != nil {
+		return nil, err
+	}
 
 	dbActions := make([]DBAction, 0)
 	// Perform any operation specific completion steps
 	for _, op := range ops {
-		actions, err := op.Complete(l, conn, s)
+		actions, err := op.Complete(ctx, l, conn, s)
 		if err != nil {
 			return nil, err
 		}
@@ -120,18 +193,18 @@ func (o *OpAlterColumn) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAc
 	}
 
 	return append(dbActions, []DBAction{
-		NewAlterSequenceOwnerAction(conn, o.Table, o.Column, TemporaryName(o.Column)),
+		NewAlterSequenceOwnerAction(conn, o.Table, o.Column, NamingFrom(ctx).TemporaryName(o.Column)),
 		NewDropColumnAction(conn, o.Table, o.Column),
 		NewDropFunctionAction(conn,
-			backfill.TriggerFunctionName(o.Table, o.Column),
-			backfill.TriggerFunctionName(o.Table, TemporaryName(o.Column)),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, o.Column),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, NamingFrom(ctx).TemporaryName(o.Column)),
 		),
-		NewDropColumnAction(conn, o.Table, backfill.CNeedsBackfillColumn),
+		NewDropNeedsBackfillColumnAction(conn, o.Table),
 		NewRenameDuplicatedColumnAction(conn, table, column.Name),
 	}...), nil
 }
 
-func (o *OpAlterColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpAlterColumn) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	table := s.GetTable(o.Table)
@@ -143,11 +216,30 @@ func (o *OpAlterColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAc
 		return nil, ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
 	}
 
+	// Restore the compression method the column had before the migration, as
+	// recorded in the schema after the previous migration
+	if o.setsCompressionOnly() {
+		compression := column.Compression
+		if compression == "" {
+			compression = string(OpAlterColumnCompressionDefault)
+		}
+		return []DBAction{
+			NewAlterColumnCompressionAction(conn, table.Name, column.Name, compression),
+		}, nil
+	}
+
+	if o.InPlace {
+		return o.rollbackInPlace(ctx, conn, table, column), nil
+	}
+
 	// Perform any operation specific rollback steps
 	dbActions := make([]DBAction, 0)
-	ops := o.subOperations()
+	ops, err := o.subOperations()
+	if err != nil {
+		return nil, err
+	}
 	for _, ops := range ops {
-		actions, err := ops.Rollback(l, conn, nil)
+		actions, err := ops.Rollback(ctx, l, conn, s)
 		if err != nil {
 			return nil, err
 		}
@@ -157,10 +249,10 @@ func (o *OpAlterColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAc
 	dbActions = append(dbActions,
 		NewDropColumnAction(conn, table.Name, column.Name),
 		NewDropFunctionAction(conn,
-			backfill.TriggerFunctionName(o.Table, o.Column),
-			backfill.TriggerFunctionName(o.Table, TemporaryName(o.Column)),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, o.Column),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, NamingFrom(ctx).TemporaryName(o.Column)),
 		),
-		NewDropColumnAction(conn, table.Name, backfill.CNeedsBackfillColumn),
+		NewDropNeedsBackfillColumnAction(conn, table.Name),
 	)
 
 	return dbActions, nil
@@ -176,13 +268,51 @@ func (o *OpAlterColumn) Validate(ctx context.Context, s *schema.Schema) error {
 		return ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
 	}
 
-	ops := o.subOperations()
+	ops, err := o.subOperations()
+	if err != nil {
+		return err
+	}
 
 	// Ensure that at least one sub-operation or rename is present
 	if len(ops) == 0 {
 		return AlterColumnNoChangesError{Table: o.Table, Column: o.Column}
 	}
 
+	if o.InPlace {
+		return o.validateInPlace(table.GetColumn(o.Column))
+	}
+
+	// The jsonb path operations replace the `up` and `down` SQL
+	if o.Jsonb != nil && (o.Up != "" || o.Down != "") {
+		return JsonbTransformConflictError{Table: o.Table, Column: o.Column}
+	}
+
+	if o.BackfillWhere != "" {
+		if err := validateBackfillWhere(table, o.BackfillWhere); err != nil {
+			return err
+		}
+	}
+
+	if o.UpTriggerStatements != "" {
+		if err := validateTriggerStatements(ctx, o.Table, o.Column, o.UpTriggerStatements); err != nil {
+			return err
+		}
+	}
+	if o.DownTriggerStatements != "" {
+		if err := validateTriggerStatements(ctx, o.Table, o.Column, o.DownTriggerStatements); err != nil {
+			return err
+		}
+	}
+
+	if err := o.validateStorage(table.GetColumn(o.Column)); err != nil {
+		return err
+	}
+
+	// Generated columns can't have a default, so it can't be set or dropped
+	if _, err := o.Generated.Get(); err == nil && o.Default.IsSpecified() {
+		return GeneratedColumnDefaultError{Table: o.Table, Column: o.Column}
+	}
+
 	// Validate the sub-operations in isolation
 	for _, op := range ops {
 		if err := op.Validate(ctx, s); err != nil {
@@ -193,16 +323,52 @@ func (o *OpAlterColumn) Validate(ctx context.Context, s *schema.Schema) error {
 	return nil
 }
 
-func (o *OpAlterColumn) subOperations() []Operation {
+func (o *OpAlterColumn) subOperations() ([]Operation, error) {
 	var ops []Operation
 
+	// When the column is restructured with jsonb path operations, the `up` and
+	// `down` SQL for the other sub-operations are derived from them.
+	up, down := o.Up, o.Down
+	if o.Jsonb != nil {
+		var err error
+		if up, err = jsonbTransformSQL(o.Column, o.Jsonb.Up); err != nil {
+			return nil, err
+		}
+		if down, err = jsonbTransformSQL(o.Column, o.Jsonb.Down); err != nil {
+			return nil, err
+		}
+	}
+
 	if o.Type != nil {
 		ops = append(ops, &OpChangeType{
 			Table:  o.Table,
 			Column: o.Column,
 			Type:   *o.Type,
-			Up:     o.Up,
-			Down:   o.Down,
+			Up:     up,
+			Down:   down,
+		})
+	}
+	if o.Generated.IsSpecified() {
+		// o.Generated is either an expression or `null`.
+		var expression *string
+		if e, err := o.Generated.Get(); err == nil {
+			expression = &e
+		}
+
+		ops = append(ops, &OpSetGenerated{
+			Table:      o.Table,
+			Column:     o.Column,
+			Expression: expression,
+			Up:         up,
+			Down:       down,
+		})
+	}
+	if o.Jsonb != nil {
+		ops = append(ops, &OpTransformJsonb{
+			Table:  o.Table,
+			Column: o.Column,
+			Up:     o.Jsonb.Up,
+			Down:   o.Jsonb.Down,
 		})
 	}
 	if o.Check != nil {
@@ -210,8 +376,8 @@ func (o *OpAlterColumn) subOperations() []Operation {
 			Table:  o.Table,
 			Column: o.Column,
 			Check:  *o.Check,
-			Up:     o.Up,
-			Down:   o.Down,
+			Up:     up,
+			Down:   down,
 		})
 	}
 	if o.References != nil {
@@ -219,24 +385,24 @@ func (o *OpAlterColumn) subOperations() []Operation {
 			Table:      o.Table,
 			Column:     o.Column,
 			References: *o.References,
-			Up:         o.Up,
-			Down:       o.Down,
+			Up:         up,
+			Down:       down,
 		})
 	}
 	if o.Nullable != nil && !*o.Nullable {
 		ops = append(ops, &OpSetNotNull{
 			Table:  o.Table,
 			Column: o.Column,
-			Up:     o.Up,
-			Down:   o.Down,
+			Up:     up,
+			Down:   down,
 		})
 	}
 	if o.Nullable != nil && *o.Nullable {
 		ops = append(ops, &OpDropNotNull{
 			Table:  o.Table,
 			Column: o.Column,
-			Up:     o.Up,
-			Down:   o.Down,
+			Up:     up,
+			Down:   down,
 		})
 	}
 	if o.Unique != nil {
@@ -244,8 +410,8 @@ func (o *OpAlterColumn) subOperations() []Operation {
 			Table:  o.Table,
 			Column: o.Column,
 			Name:   o.Unique.Name,
-			Up:     o.Up,
-			Down:   o.Down,
+			Up:     up,
+			Down:   down,
 		})
 	}
 	if o.Default.IsSpecified() {
@@ -259,8 +425,26 @@ func (o *OpAlterColumn) subOperations() []Operation {
 			Table:   o.Table,
 			Column:  o.Column,
 			Default: defaultPtr,
-			Up:      o.Up,
-			Down:    o.Down,
+			Up:      up,
+			Down:    down,
+		})
+	}
+	if o.Storage != nil {
+		ops = append(ops, &OpSetStorage{
+			Table:   o.Table,
+			Column:  o.Column,
+			Storage: *o.Storage,
+			Up:      up,
+			Down:    down,
+		})
+	}
+	if o.Compression != nil {
+		ops = append(ops, &OpSetCompression{
+			Table:       o.Table,
+			Column:      o.Column,
+			Compression: *o.Compression,
+			Up:          up,
+			Down:        down,
 		})
 	}
 	if o.Comment.IsSpecified() {
@@ -273,12 +457,178 @@ func (o *OpAlterColumn) subOperations() []Operation {
 			Table:   o.Table,
 			Column:  o.Column,
 			Comment: comment,
-			Up:      o.Up,
-			Down:    o.Down,
+			Up:      up,
+			Down:    down,
 		})
 	}
 
-	return ops
+	return ops, nil
+}
+
+// setsCompressionOnly returns true if the only change the operation makes is
+// to the compression method of the column, with no `up` SQL to rewrite its
+// values and no trigger statements to run.
+func (o *OpAlterColumn) setsCompressionOnly() bool {
+	ops, err := o.subOperations()
+	if err != nil || len(ops) != 1 || o.Up != "" || o.BackfillWhere != "" || o.UpTriggerStatements != "" || o.DownTriggerStatements != "" {
+		return false
+	}
+	_, ok := ops[0].(*OpSetCompression)
+	return ok
+}
+
+// startInPlace changes the nullability of the column itself. A NOT NULL
+// constraint is added as a NOT VALID check constraint, which rejects NULLs
+// written through either version of the schema from the start of the
+// migration. The check is validated on migration completion, and Postgres
+// then uses it to set NOT NULL on the column without scanning the table. A
+// NOT NULL constraint is dropped straight away, so that NULLs can be written
+// through the new version of the schema.
+func (o *OpAlterColumn) startInPlace(ctx context.Context, conn db.DB, table *schema.Table, column *schema.Column) *StartResult {
+	if *o.Nullable {
+		column.Nullable = true
+		return &StartResult{Actions: []DBAction{
+			NewDropNotNullAction(conn, table.Name, column.Name),
+		}}
+	}
+
+	// Add the check constraint, unless an earlier, interrupted attempt to
+	// start the migration has already added it
+	var dbActions []DBAction
+	if !table.ConstraintExists(NamingFrom(ctx).NotNullConstraintName(o.Column)) {
+		dbActions = append(dbActions, notNullCheckAction(conn, table.Name, column.Name, NamingFrom(ctx).NotNullConstraintName(o.Column)))
+	}
+	column.Nullable = false
+	return &StartResult{Actions: dbActions}
+}
+
+// completeInPlace validates the check constraint added by startInPlace and
+// replaces it with a NOT NULL constraint on the column.
+func (o *OpAlterColumn) completeInPlace(ctx context.Context, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	if *o.Nullable {
+		return nil, nil
+	}
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+	column := table.GetColumn(o.Column)
+	if column == nil {
+		return nil, ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
+	}
+
+	return []DBAction{
+		NewValidateConstraintAction(conn, table.Name, NamingFrom(ctx).NotNullConstraintName(o.Column)),
+		NewSetNotNullAction(conn, table.Name, column.Name),
+		NewDropConstraintAction(conn, table.Name, NamingFrom(ctx).NotNullConstraintName(o.Column)),
+	}, nil
+}
+
+// rollbackInPlace drops the check constraint added by startInPlace, or adds
+// back the NOT NULL constraint that it dropped. The NOT NULL constraint can't
+// be added back if NULLs have been written to the column since the migration
+// was started.
+func (o *OpAlterColumn) rollbackInPlace(ctx context.Context, conn db.DB, table *schema.Table, column *schema.Column) []DBAction {
+	constraint := NamingFrom(ctx).NotNullConstraintName(o.Column)
+	if !*o.Nullable {
+		return []DBAction{NewDropConstraintAction(conn, table.Name, constraint)}
+	}
+
+	return []DBAction{
+		// Drop the check constraint left by an earlier, failed rollback
+		NewDropConstraintAction(conn, table.Name, constraint),
+		notNullCheckAction(conn, table.Name, column.Name, constraint),
+		NewValidateConstraintAction(conn, table.Name, constraint),
+		NewSetNotNullAction(conn, table.Name, column.Name),
+		NewDropConstraintAction(conn, table.Name, constraint),
+	}
+}
+
+// notNullCheckAction returns an action that adds a NOT VALID check constraint
+// that the column is NOT NULL.
+func notNullCheckAction(conn db.DB, table, column, constraint string) DBAction {
+	skipInherit := false
+	skipValidate := true
+	return NewCreateCheckConstraintAction(conn, table, constraint,
+		fmt.Sprintf("%s IS NOT NULL", pq.QuoteIdentifier(column)),
+		nil,
+		skipInherit,
+		skipValidate)
+}
+
+// validateInPlace checks that an operation made in place changes nothing but
+// the nullability of the column, and that the column doesn't already have the
+// nullability.
+func (o *OpAlterColumn) validateInPlace(column *schema.Column) error {
+	ops, err := o.subOperations()
+	if err != nil {
+		return err
+	}
+	if o.Nullable == nil || len(ops) != 1 || o.Up != "" || o.Down != "" || o.BackfillWhere != "" || o.UpTriggerStatements != "" || o.DownTriggerStatements != "" {
+		return AlterColumnInPlaceError{Table: o.Table, Column: o.Column}
+	}
+	if *o.Nullable && column.Nullable {
+		return ColumnIsNullableError{Table: o.Table, Name: o.Column}
+	}
+	if !*o.Nullable && !column.Nullable {
+		return ColumnIsNotNullableError{Table: o.Table, Name: o.Column}
+	}
+	return nil
+}
+
+// Partial returns the data that is left as it is by an operation that only
+// changes the compression method of the column.
+func (o *OpAlterColumn) Partial() string {
+	if !o.setsCompressionOnly() {
+		return ""
+	}
+	return fmt.Sprintf("existing values of column %q on table %q are not recompressed; only values written after the migration is started use the %s compression method",
+		o.Column, o.Table, *o.Compression)
+}
+
+// compressionMethod returns the compression method recorded in the schema for
+// a column with the given compression.
+func compressionMethod(c OpAlterColumnCompression) string {
+	if c == OpAlterColumnCompressionDefault {
+		return ""
+	}
+	return string(c)
+}
+
+// validateStorage checks the storage mode and compression method set by the
+// operation, and that the type of the column after the operation supports
+// them.
+func (o *OpAlterColumn) validateStorage(column *schema.Column) error {
+	typ := column.Type
+	if o.Type != nil {
+		typ = *o.Type
+	}
+
+	if o.Storage != nil {
+		switch *o.Storage {
+		case OpAlterColumnStoragePlain:
+		case OpAlterColumnStorageExternal, OpAlterColumnStorageExtended, OpAlterColumnStorageMain:
+			if isFixedLengthType(typ) {
+				return ColumnStorageNotSupportedError{Table: o.Table, Column: o.Column, Type: typ, Storage: string(*o.Storage)}
+			}
+		default:
+			return InvalidColumnStorageError{Table: o.Table, Column: o.Column, Storage: string(*o.Storage)}
+		}
+	}
+
+	if o.Compression != nil {
+		switch *o.Compression {
+		case OpAlterColumnCompressionPglz, OpAlterColumnCompressionLz4, OpAlterColumnCompressionDefault:
+			if isFixedLengthType(typ) {
+				return ColumnCompressionNotSupportedError{Table: o.Table, Column: o.Column, Type: typ}
+			}
+		default:
+			return InvalidColumnCompressionError{Table: o.Table, Column: o.Column, Compression: string(*o.Compression)}
+		}
+	}
+
+	return nil
 }
 
 // duplicatorForOperations returns a Duplicator for the given operations
@@ -291,6 +641,15 @@ func duplicatorForOperations(ops []Operation, conn db.DB, table *schema.Table, c
 			d = d.WithoutNotNull(column.Name)
 		case *OpChangeType:
 			d = d.WithType(column.Name, op.Type)
+		case *OpSetDefault:
+			// The new default is set on the duplicated column by the set default
+			// operation. Skip copying the old default, which may not be valid for
+			// the new column type.
+			d = d.WithoutDefault(column.Name)
+		case *OpSetGenerated:
+			if op.Expression != nil {
+				d = d.WithGenerated(column.Name, *op.Expression)
+			}
 		}
 	}
 	return d
@@ -298,34 +657,69 @@ func duplicatorForOperations(ops []Operation, conn db.DB, table *schema.Table, c
 
 // downSQLForOperations returns the `down` SQL for the given operations, applying
 // an appropriate default if no `down` SQL is provided.
-func (o *OpAlterColumn) downSQLForOperations(ops []Operation) string {
+func (o *OpAlterColumn) downSQLForOperations(ops []Operation) (string, error) {
 	if o.Down != "" {
-		return o.Down
+		return o.Down, nil
+	}
+
+	for _, op := range ops {
+		if op, ok := op.(*OpTransformJsonb); ok {
+			return op.DownSQL()
+		}
+	}
+
+	// The old column keeps the values the generation expression computes for
+	// the new one
+	if op := setGeneratedOperation(ops); op != nil && op.Expression != nil {
+		return *op.Expression, nil
 	}
 
 	for _, op := range ops {
 		switch (op).(type) {
-		case *OpSetUnique, *OpSetNotNull, *OpSetDefault, *OpSetComment:
-			return pq.QuoteIdentifier(o.Column)
+		case *OpSetUnique, *OpSetNotNull, *OpSetDefault, *OpSetComment, *OpSetStorage, *OpSetCompression:
+			return pq.QuoteIdentifier(o.Column), nil
 		}
 	}
 
-	return ""
+	return "", nil
 }
 
 // upSQLForOperations returns the `up` SQL for the given operations, applying
 // an appropriate default if no `up` SQL is provided.
-func (o *OpAlterColumn) upSQLForOperations(ops []Operation) string {
+func (o *OpAlterColumn) upSQLForOperations(ops []Operation, column *schema.Column) (string, error) {
 	if o.Up != "" {
-		return o.Up
+		return o.Up, nil
+	}
+
+	for _, op := range ops {
+		if op, ok := op.(*OpTransformJsonb); ok {
+			return op.UpSQL()
+		}
+	}
+
+	// The values of a generated column aren't available to triggers, so the
+	// new column is computed from the old column's generation expression
+	if op := setGeneratedOperation(ops); op != nil && op.Expression == nil && column.Generated != nil {
+		return *column.Generated, nil
 	}
 
 	for _, op := range ops {
 		switch (op).(type) {
-		case *OpDropNotNull, *OpSetDefault, *OpSetComment:
-			return pq.QuoteIdentifier(o.Column)
+		case *OpDropNotNull, *OpSetDefault, *OpSetComment, *OpSetStorage, *OpSetCompression:
+			return pq.QuoteIdentifier(o.Column), nil
 		}
 	}
 
-	return ""
+	return "", nil
+}
+
+// setGeneratedOperation returns the operation among the given operations that
+// converts the column to or from a generated column, if there is one.
+func setGeneratedOperation(ops []Operation) *OpSetGenerated {
+	for _, op := range ops {
+		if op, ok := op.(*OpSetGenerated); ok {
+			return op
+		}
+	}
+	return nil
 }
@@ -4,8 +4,11 @@ package migrations_test
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/lib/pq"
 	"github.com/oapi-codegen/nullable"
 	"github.com/stretchr/testify/assert"
 
@@ -677,6 +680,92 @@ func TestAlterPrimaryKeyColumns(t *testing.T) {
 	})
 }
 
+func TestAlterColumnWithLongColumnNames(t *testing.T) {
+	t.Parallel()
+
+	// Two columns whose names are the maximum length of an identifier and only
+	// differ in their last characters, so that the names of their temporary
+	// columns, triggers and constraints all have to be shortened
+	line1 := "customer_shipping_address_" + strings.Repeat("x", 34) + "_l1"
+	line2 := "customer_shipping_address_" + strings.Repeat("x", 34) + "_l2"
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "can alter columns with names of the maximum identifier length",
+			migrations: []migrations.Migration{
+				{
+					Name:          "01_create_table",
+					VersionSchema: "create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "orders",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: line1, Type: "text", Nullable: true},
+								{Name: line2, Type: "text", Nullable: true},
+							},
+						},
+					},
+				},
+				{
+					Name:          "02_alter_columns",
+					VersionSchema: "alter_columns",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:    "orders",
+							Column:   line1,
+							Nullable: ptr(false),
+							Up:       fmt.Sprintf("COALESCE(%s, 'unknown')", pq.QuoteIdentifier(line1)),
+							Down:     pq.QuoteIdentifier(line1),
+						},
+						&migrations.OpAlterColumn{
+							Table:    "orders",
+							Column:   line2,
+							Nullable: ptr(false),
+							Up:       fmt.Sprintf("COALESCE(%s, '')", pq.QuoteIdentifier(line2)),
+							Down:     pq.QuoteIdentifier(line2),
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The temporary columns have distinct names that fit in an identifier
+				tmp1, tmp2 := migrations.TemporaryName(line1), migrations.TemporaryName(line2)
+				assert.LessOrEqual(t, len(tmp1), 63)
+				assert.LessOrEqual(t, len(tmp2), 63)
+				assert.NotEqual(t, tmp1, tmp2)
+				ColumnMustExist(t, db, schema, "orders", tmp1)
+				ColumnMustExist(t, db, schema, "orders", tmp2)
+
+				// Inserting a NULL through the new version fails
+				MustNotInsert(t, db, schema, "alter_columns", "orders", map[string]string{
+					line1: "1 Main Street",
+				}, testutils.CheckViolationErrorCode)
+
+				// Rows inserted through the old version are written into each
+				// temporary column by its own trigger
+				MustInsert(t, db, schema, "create_table", "orders", map[string]string{
+					"id": "1",
+				})
+				rows := MustSelect(t, db, schema, "alter_columns", "orders")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, line1: "unknown", line2: ""},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "orders", line1, line2)
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "orders", line1, line2)
+
+				MustNotInsert(t, db, schema, "alter_columns", "orders", map[string]string{
+					line2: "Apartment 2",
+				}, testutils.NotNullViolationErrorCode)
+			},
+		},
+	})
+}
+
 func TestAlterColumnValidation(t *testing.T) {
 	t.Parallel()
 
@@ -753,6 +842,26 @@ func TestAlterColumnValidation(t *testing.T) {
 			},
 			wantStartErr: migrations.ColumnDoesNotExistError{Table: "posts", Name: "doesntexist"},
 		},
+		{
+			name: "backfill_where must only reference columns of the table",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "01_alter_column",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:         "posts",
+							Column:        "title",
+							Type:          ptr("varchar(255)"),
+							Up:            "title",
+							Down:          "title",
+							BackfillWhere: "author_id = 1",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnDoesNotExistError{Table: "posts", Name: "author_id"},
+		},
 		{
 			name: "cant make no changes",
 			migrations: []migrations.Migration{
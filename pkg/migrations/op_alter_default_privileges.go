@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpAlterDefaultPrivileges)(nil)
+	_ Createable = (*OpAlterDefaultPrivileges)(nil)
+)
+
+// defaultPrivileges lists the privileges that can be granted by default on
+// each type of object.
+var defaultPrivileges = map[OpAlterDefaultPrivilegesObjectType][]string{
+	OpAlterDefaultPrivilegesObjectTypeTables:    {"ALL", "SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER", "MAINTAIN"},
+	OpAlterDefaultPrivilegesObjectTypeSequences: {"ALL", "USAGE", "SELECT", "UPDATE"},
+	OpAlterDefaultPrivilegesObjectTypeFunctions: {"ALL", "EXECUTE"},
+	OpAlterDefaultPrivilegesObjectTypeTypes:     {"ALL", "USAGE"},
+}
+
+func (o *OpAlterDefaultPrivileges) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	return &StartResult{Actions: []DBAction{
+		NewAlterDefaultPrivilegesAction(conn, o.Role, o.Schema, o.Action == OpAlterDefaultPrivilegesActionGrant, o.Privileges, string(o.ObjectType), o.Grantee),
+	}}, nil
+}
+
+func (o *OpAlterDefaultPrivileges) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpAlterDefaultPrivileges) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	// Reverse the change made on start: revoke what was granted, or grant what
+	// was revoked
+	return []DBAction{
+		NewAlterDefaultPrivilegesAction(conn, o.Role, o.Schema, o.Action != OpAlterDefaultPrivilegesActionGrant, o.Privileges, string(o.ObjectType), o.Grantee),
+	}, nil
+}
+
+func (o *OpAlterDefaultPrivileges) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Grantee == "" {
+		return FieldRequiredError{Name: "grantee"}
+	}
+
+	if o.Action != OpAlterDefaultPrivilegesActionGrant && o.Action != OpAlterDefaultPrivilegesActionRevoke {
+		return InvalidPrivilegesActionError{Action: string(o.Action)}
+	}
+
+	allowed, ok := defaultPrivileges[o.ObjectType]
+	if !ok {
+		return InvalidPrivilegesObjectTypeError{ObjectType: string(o.ObjectType)}
+	}
+
+	if len(o.Privileges) == 0 {
+		return FieldRequiredError{Name: "privileges"}
+	}
+	for _, p := range o.Privileges {
+		if !slices.Contains(allowed, strings.ToUpper(p)) {
+			return InvalidPrivilegeError{ObjectType: string(o.ObjectType), Privilege: p}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// createRoleMigration creates a role. Roles are shared by all databases in
+// the cluster, so the role is only created if it does not already exist.
+func createRoleMigration(role string) migrations.Migration {
+	return migrations.Migration{
+		Name: "01_create_role",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: fmt.Sprintf(`DO $$ BEGIN CREATE ROLE %[1]s; EXCEPTION WHEN duplicate_object THEN NULL; END $$`, role),
+			},
+		},
+	}
+}
+
+func TestAlterDefaultPrivileges(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "grant default privileges on tables",
+			migrations: []migrations.Migration{
+				createRoleMigration("default_privileges_reader"),
+				{
+					Name: "02_alter_default_privileges",
+					Operations: migrations.Operations{
+						&migrations.OpAlterDefaultPrivileges{
+							Schema:     testutils.TestSchema(),
+							Action:     migrations.OpAlterDefaultPrivilegesActionGrant,
+							ObjectType: migrations.OpAlterDefaultPrivilegesObjectTypeTables,
+							Privileges: []string{"select", "INSERT"},
+							Grantee:    "default_privileges_reader",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Tables created from now on are granted to the role
+				TablePrivilegesMustBe(t, db, schema, "after_start", "default_privileges_reader", true)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The default privileges have been revoked again
+				TablePrivilegesMustBe(t, db, schema, "after_rollback", "default_privileges_reader", false)
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TablePrivilegesMustBe(t, db, schema, "after_complete", "default_privileges_reader", true)
+			},
+		},
+	})
+}
+
+func TestAlterDefaultPrivilegesValidation(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "grantee is required",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_alter_default_privileges",
+					Operations: migrations.Operations{
+						&migrations.OpAlterDefaultPrivileges{
+							Action:     migrations.OpAlterDefaultPrivilegesActionGrant,
+							ObjectType: migrations.OpAlterDefaultPrivilegesObjectTypeTables,
+							Privileges: []string{"SELECT"},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "grantee"},
+		},
+		{
+			name: "object type must be valid",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_alter_default_privileges",
+					Operations: migrations.Operations{
+						&migrations.OpAlterDefaultPrivileges{
+							Action:     migrations.OpAlterDefaultPrivilegesActionGrant,
+							ObjectType: "views",
+							Privileges: []string{"SELECT"},
+							Grantee:    "PUBLIC",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidPrivilegesObjectTypeError{ObjectType: "views"},
+		},
+		{
+			name: "privileges must apply to the object type",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_alter_default_privileges",
+					Operations: migrations.Operations{
+						&migrations.OpAlterDefaultPrivileges{
+							Action:     migrations.OpAlterDefaultPrivilegesActionRevoke,
+							ObjectType: migrations.OpAlterDefaultPrivilegesObjectTypeSequences,
+							Privileges: []string{"EXECUTE"},
+							Grantee:    "PUBLIC",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidPrivilegeError{ObjectType: "sequences", Privilege: "EXECUTE"},
+		},
+		{
+			name: "action must be valid",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_alter_default_privileges",
+					Operations: migrations.Operations{
+						&migrations.OpAlterDefaultPrivileges{
+							Action:     "allow",
+							ObjectType: migrations.OpAlterDefaultPrivilegesObjectTypeTables,
+							Privileges: []string{"SELECT"},
+							Grantee:    "PUBLIC",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidPrivilegesActionError{Action: "allow"},
+		},
+	})
+}
+
+// TablePrivilegesMustBe creates a table and checks whether the role has been
+// granted SELECT on it by the default privileges.
+func TablePrivilegesMustBe(t *testing.T, db *sql.DB, schema, table, role string, want bool) {
+	t.Helper()
+
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE %s.%s (id integer)", schema, table))
+	require.NoError(t, err)
+
+	var granted bool
+	err = db.QueryRow("SELECT has_table_privilege($1, $2, 'SELECT')", role, schema+"."+table).Scan(&granted)
+	require.NoError(t, err)
+	assert.Equal(t, want, granted)
+}
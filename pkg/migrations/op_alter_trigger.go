@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpAlterTrigger)(nil)
+	_ Createable = (*OpAlterTrigger)(nil)
+)
+
+func (o *OpAlterTrigger) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	// The state of the trigger in the in-memory schema is left unchanged so
+	// that the previous state is available to Rollback.
+	return &StartResult{Actions: []DBAction{
+		NewAlterTriggerAction(conn, table.Name, o.Name, string(o.State)),
+	}}, nil
+}
+
+func (o *OpAlterTrigger) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpAlterTrigger) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, nil
+	}
+
+	// Restore the state the trigger was in before the migration started
+	trigger := table.GetTrigger(o.Name)
+	if trigger == nil || trigger.State == string(o.State) {
+		return nil, nil
+	}
+
+	return []DBAction{
+		NewAlterTriggerAction(conn, table.Name, o.Name, trigger.State),
+	}, nil
+}
+
+func (o *OpAlterTrigger) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	// Altering the triggers pgroll uses to backfill columns would corrupt any
+	// migration that is in progress
+	if strings.HasPrefix(o.Name, NamingFrom(ctx).TriggerFunctionPrefix()) {
+		return PgrollTriggerError{Name: o.Name}
+	}
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+
+	if table.GetTrigger(o.Name) == nil {
+		return TriggerDoesNotExistError{Table: o.Table, Name: o.Name}
+	}
+
+	states := []OpAlterTriggerState{
+		OpAlterTriggerStateENABLE,
+		OpAlterTriggerStateDISABLE,
+		OpAlterTriggerStateENABLEREPLICA,
+		OpAlterTriggerStateENABLEALWAYS,
+	}
+	if !slices.Contains(states, o.State) {
+		return InvalidTriggerStateError{Name: o.Name, State: string(o.State)}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestAlterTrigger(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "varchar(255)",
+					},
+				},
+			},
+		},
+	}
+
+	createTriggerMigration := migrations.Migration{
+		Name: "02_create_trigger",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: `CREATE FUNCTION uppercase_name() RETURNS trigger LANGUAGE plpgsql AS $$
+					BEGIN
+						NEW.name := upper(NEW.name);
+						RETURN NEW;
+					END $$;
+					CREATE TRIGGER uppercase_name BEFORE INSERT ON users FOR EACH ROW EXECUTE FUNCTION uppercase_name()`,
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "disable a trigger",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createTriggerMigration,
+				{
+					Name: "03_disable_trigger",
+					Operations: migrations.Operations{
+						&migrations.OpAlterTrigger{
+							Table: "users",
+							Name:  "uppercase_name",
+							State: migrations.OpAlterTriggerStateDISABLE,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The trigger has been disabled
+				TriggerStateMustBe(t, db, schema, "users", "uppercase_name", "D")
+
+				// Inserted names are no longer uppercased
+				MustInsert(t, db, schema, "03_disable_trigger", "users", map[string]string{
+					"name": "alice",
+				})
+				rows := MustSelect(t, db, schema, "03_disable_trigger", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "alice"},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The trigger has been enabled again
+				TriggerStateMustBe(t, db, schema, "users", "uppercase_name", "O")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The trigger remains disabled
+				TriggerStateMustBe(t, db, schema, "users", "uppercase_name", "D")
+			},
+		},
+		{
+			name: "rollback restores the previous state of the trigger",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createTriggerMigration,
+				{
+					Name: "03_enable_replica_trigger",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "ALTER TABLE users ENABLE REPLICA TRIGGER uppercase_name",
+						},
+					},
+				},
+				{
+					Name: "04_enable_always_trigger",
+					Operations: migrations.Operations{
+						&migrations.OpAlterTrigger{
+							Table: "users",
+							Name:  "uppercase_name",
+							State: migrations.OpAlterTriggerStateENABLEALWAYS,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TriggerStateMustBe(t, db, schema, "users", "uppercase_name", "A")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The trigger is back in its ENABLE REPLICA state
+				TriggerStateMustBe(t, db, schema, "users", "uppercase_name", "R")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TriggerStateMustBe(t, db, schema, "users", "uppercase_name", "A")
+			},
+		},
+	})
+}
+
+func TestAlterTriggerValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+				},
+			},
+		},
+	}
+
+	createTriggerMigration := migrations.Migration{
+		Name: "02_create_trigger",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: `CREATE FUNCTION noop() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$;
+					CREATE TRIGGER noop BEFORE INSERT ON users FOR EACH ROW EXECUTE FUNCTION noop()`,
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "table must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createTriggerMigration,
+				{
+					Name: "03_disable_trigger",
+					Operations: migrations.Operations{
+						&migrations.OpAlterTrigger{
+							Table: "doesntexist",
+							Name:  "noop",
+							State: migrations.OpAlterTriggerStateDISABLE,
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "doesntexist"},
+		},
+		{
+			name: "trigger must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createTriggerMigration,
+				{
+					Name: "03_disable_trigger",
+					Operations: migrations.Operations{
+						&migrations.OpAlterTrigger{
+							Table: "users",
+							Name:  "doesntexist",
+							State: migrations.OpAlterTriggerStateDISABLE,
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TriggerDoesNotExistError{Table: "users", Name: "doesntexist"},
+		},
+		{
+			name: "state must be valid",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createTriggerMigration,
+				{
+					Name: "03_disable_trigger",
+					Operations: migrations.Operations{
+						&migrations.OpAlterTrigger{
+							Table: "users",
+							Name:  "noop",
+							State: "PAUSE",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidTriggerStateError{Name: "noop", State: "PAUSE"},
+		},
+		{
+			name: "pgroll's backfill triggers can not be altered",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_disable_trigger",
+					Operations: migrations.Operations{
+						&migrations.OpAlterTrigger{
+							Table: "users",
+							Name:  backfill.TriggerName("users", "id"),
+							State: migrations.OpAlterTriggerStateDISABLE,
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.PgrollTriggerError{Name: backfill.TriggerName("users", "id")},
+		},
+	})
+}
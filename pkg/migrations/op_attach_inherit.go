@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpAttachInherit)(nil)
+	_ Createable = (*OpAttachInherit)(nil)
+)
+
+func (o *OpAttachInherit) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+	parent := s.GetTable(o.Parent)
+	if parent == nil {
+		return nil, TableDoesNotExistError{Name: o.Parent}
+	}
+
+	table.Inherits = append(table.Inherits, parent.Name)
+
+	return &StartResult{Actions: []DBAction{
+		NewAlterTableInheritAction(conn, table.Name, parent.Name, true),
+	}}, nil
+}
+
+func (o *OpAttachInherit) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpAttachInherit) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	table := s.GetTable(o.Table)
+	parent := s.GetTable(o.Parent)
+	if table == nil || parent == nil {
+		return nil, nil
+	}
+
+	return []DBAction{
+		NewAlterTableInheritAction(conn, table.Name, parent.Name, false),
+	}, nil
+}
+
+func (o *OpAttachInherit) Validate(ctx context.Context, s *schema.Schema) error {
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+	parent := s.GetTable(o.Parent)
+	if parent == nil {
+		return TableDoesNotExistError{Name: o.Parent}
+	}
+
+	if o.Table == o.Parent {
+		return InvalidMigrationError{Reason: fmt.Sprintf("table %q can't inherit from itself", o.Table)}
+	}
+
+	if table.InheritsFrom(parent.Name) {
+		return TableAlreadyInheritsError{Table: o.Table, Parent: o.Parent}
+	}
+
+	if err := validateInheritColumns(table, parent, o.Table, o.Parent); err != nil {
+		return err
+	}
+
+	table.Inherits = append(table.Inherits, parent.Name)
+	return nil
+}
+
+// validateInheritColumns checks that the child table has each of the parent
+// table's columns, with the same type and without allowing NULLs where the
+// parent doesn't, as Postgres requires for the child to inherit from the
+// parent.
+func validateInheritColumns(table, parent *schema.Table, tableName, parentName string) error {
+	for _, name := range slices.Sorted(maps.Keys(parent.Columns)) {
+		parentColumn := parent.Columns[name]
+
+		column := table.GetColumn(name)
+		if column == nil {
+			return InheritColumnMismatchError{Table: tableName, Parent: parentName, Column: name, Reason: "is missing from the table"}
+		}
+		if column.Type != parentColumn.Type {
+			return InheritColumnMismatchError{
+				Table:  tableName,
+				Parent: parentName,
+				Column: name,
+				Reason: fmt.Sprintf("has type %q in the table but %q in the parent", column.Type, parentColumn.Type),
+			}
+		}
+		if column.Nullable && !parentColumn.Nullable {
+			return InheritColumnMismatchError{Table: tableName, Parent: parentName, Column: name, Reason: "must be NOT NULL as it is in the parent"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestAttachInherit(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "measurements",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "value", Type: "numeric", Nullable: true},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "measurements_2024",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "value", Type: "numeric", Nullable: true},
+					{Name: "region", Type: "text", Nullable: true},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "attach a child table to a parent table",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_attach_inherit",
+					Operations: migrations.Operations{
+						&migrations.OpAttachInherit{
+							Table:  "measurements_2024",
+							Parent: "measurements",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustInherit(t, db, schema, "measurements_2024", "measurements")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotInherit(t, db, schema, "measurements_2024", "measurements")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustInherit(t, db, schema, "measurements_2024", "measurements")
+			},
+		},
+	})
+}
+
+func TestAttachInheritValidation(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "measurements",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "value", Type: "numeric", Nullable: true},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "missing_column",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "different_type",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "value", Type: "text", Nullable: true},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "nullable_column",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Nullable: true},
+					{Name: "value", Type: "numeric", Nullable: true},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "parent table must exist",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_attach_inherit",
+					Operations: migrations.Operations{
+						&migrations.OpAttachInherit{
+							Table:  "missing_column",
+							Parent: "doesntexist",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "doesntexist"},
+		},
+		{
+			name: "child table must have the parent's columns",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_attach_inherit",
+					Operations: migrations.Operations{
+						&migrations.OpAttachInherit{
+							Table:  "missing_column",
+							Parent: "measurements",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InheritColumnMismatchError{
+				Table:  "missing_column",
+				Parent: "measurements",
+				Column: "value",
+				Reason: "is missing from the table",
+			},
+		},
+		{
+			name: "child table columns must have the same types as the parent's",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_attach_inherit",
+					Operations: migrations.Operations{
+						&migrations.OpAttachInherit{
+							Table:  "different_type",
+							Parent: "measurements",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InheritColumnMismatchError{
+				Table:  "different_type",
+				Parent: "measurements",
+				Column: "value",
+				Reason: `has type "text" in the table but "numeric" in the parent`,
+			},
+		},
+		{
+			name: "child table columns must be NOT NULL where the parent's are",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_attach_inherit",
+					Operations: migrations.Operations{
+						&migrations.OpAttachInherit{
+							Table:  "nullable_column",
+							Parent: "measurements",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InheritColumnMismatchError{
+				Table:  "nullable_column",
+				Parent: "measurements",
+				Column: "id",
+				Reason: "must be NOT NULL as it is in the parent",
+			},
+		},
+	})
+}
@@ -31,24 +31,28 @@ func (o *OpChangeType) Start(ctx context.Context, l Logger, conn db.DB, s *schem
 	return &StartResult{BackfillTask: backfill.NewTask(table)}, nil
 }
 
-func (o *OpChangeType) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpChangeType) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return nil, nil
 }
 
-func (o *OpChangeType) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpChangeType) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	return nil, nil
 }
 
 func (o *OpChangeType) Validate(ctx context.Context, s *schema.Schema) error {
+	if err := validateTypeName(o.Table, o.Column, o.Type); err != nil {
+		return err
+	}
+
 	if o.Up == "" {
 		return FieldRequiredError{Name: "up"}
 	}
 
-	if o.Down == "" {
+	if o.Down == "" && downSQLRequired(ctx) {
 		return FieldRequiredError{Name: "down"}
 	}
 	return nil
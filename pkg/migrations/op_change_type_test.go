@@ -6,6 +6,8 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/oapi-codegen/nullable"
+
 	"github.com/xataio/pgroll/internal/testutils"
 
 	"github.com/stretchr/testify/assert"
@@ -298,6 +300,86 @@ func TestChangeColumnType(t *testing.T) {
 				}, rows)
 			},
 		},
+		{
+			name: "changing column type can replace an incompatible default on the column",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "users",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "integer",
+									Pk:   true,
+								},
+								{
+									Name:     "age",
+									Type:     "text",
+									Default:  ptr("'unknown'"),
+									Nullable: true,
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "02_change_type",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:   "users",
+							Column:  "age",
+							Type:    ptr("integer"),
+							Default: nullable.NewNullableWithValue("18"),
+							Up:      "CASE WHEN age = 'unknown' THEN 18 ELSE CAST(age AS integer) END",
+							Down:    "CAST(age AS text)",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// A row can be inserted into the new version of the table.
+				MustInsert(t, db, schema, "02_change_type", "users", map[string]string{
+					"id": "1",
+				})
+
+				// The newly inserted row contains the new default value.
+				rows := MustSelect(t, db, schema, "02_change_type", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "age": 18},
+				}, rows)
+
+				// A row can be inserted into the old version of the table.
+				MustInsert(t, db, schema, "01_add_table", "users", map[string]string{
+					"id": "2",
+				})
+
+				// The row inserted into the old version of the table uses the old
+				// default, which is converted by the up SQL.
+				rows = MustSelect(t, db, schema, "01_add_table", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "age": "18"},
+					{"id": 2, "age": "unknown"},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// A row can be inserted into the new version of the table.
+				MustInsert(t, db, schema, "02_change_type", "users", map[string]string{
+					"id": "3",
+				})
+
+				// The newly inserted row contains the new default value.
+				rows := MustSelect(t, db, schema, "02_change_type", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "age": 18},
+					{"id": 2, "age": 18},
+					{"id": 3, "age": 18},
+				}, rows)
+			},
+		},
 		{
 			name: "changing column type preserves any compatible defaults on the column",
 			migrations: []migrations.Migration{
@@ -12,8 +12,11 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 
 	"sigs.k8s.io/yaml"
+
+	"github.com/xataio/pgroll/pkg/backfill"
 )
 
 type OpName string
@@ -34,6 +37,21 @@ const (
 	OpNameDropMultiColumnConstraint OpName = "drop_multicolumn_constraint"
 	OpRawSQLName                    OpName = "sql"
 	OpCreateConstraintName          OpName = "create_constraint"
+	OpNameCreateType                OpName = "create_type"
+	OpNameDropType                  OpName = "drop_type"
+	OpNameAlterTrigger              OpName = "alter_trigger"
+	OpNameCreateForeignTable        OpName = "create_foreign_table"
+	OpNameDropForeignTable          OpName = "drop_foreign_table"
+	OpNameCreateTableAs             OpName = "create_table_as"
+	OpNameAlterDefaultPrivileges    OpName = "alter_default_privileges"
+	OpNameSetPrimaryKey             OpName = "set_primary_key"
+	OpNameCreateRule                OpName = "create_rule"
+	OpNameDropRule                  OpName = "drop_rule"
+	OpNameTruncate                  OpName = "truncate"
+	OpNameAttachInherit             OpName = "attach_inherit"
+	OpNameDetachInherit             OpName = "detach_inherit"
+	OpNameCreatePartition           OpName = "create_partition"
+	OpNameValidateConstraint        OpName = "validate_constraint"
 )
 
 // AllNonDeprecatedOperations contains the list of operations
@@ -53,21 +71,39 @@ var AllNonDeprecatedOperations = []string{
 	string(OpNameDropMultiColumnConstraint),
 	string(OpRawSQLName),
 	string(OpCreateConstraintName),
+	string(OpNameCreateType),
+	string(OpNameDropType),
+	string(OpNameAlterTrigger),
+	string(OpNameCreateForeignTable),
+	string(OpNameDropForeignTable),
+	string(OpNameCreateTableAs),
+	string(OpNameAlterDefaultPrivileges),
+	string(OpNameSetPrimaryKey),
+	string(OpNameCreateRule),
+	string(OpNameDropRule),
+	string(OpNameTruncate),
+	string(OpNameAttachInherit),
+	string(OpNameDetachInherit),
+	string(OpNameCreatePartition),
+	string(OpNameValidateConstraint),
 }
 
-const (
-	temporaryPrefix = "_pgroll_new_"
-	deletedPrefix   = "_pgroll_del_"
-)
-
-// TemporaryName returns a temporary name for a given name.
+// TemporaryName returns a temporary name for a given name, with the default
+// naming. Operations name their objects with the naming of their context.
 func TemporaryName(name string) string {
-	return temporaryPrefix + name
+	return backfill.Naming{}.TemporaryName(name)
 }
 
-// DeletionName returns the deleted name for a given name.
+// DeletionName returns the deleted name for a given name, with the default
+// naming.
 func DeletionName(name string) string {
-	return deletedPrefix + name
+	return backfill.Naming{}.DeletionName(name)
+}
+
+// TruncatedName returns the name of the table that holds a copy of the rows of
+// a truncated table, with the default naming.
+func TruncatedName(name string) string {
+	return backfill.Naming{}.TruncatedName(name)
 }
 
 // CollectFilesFromDir returns a list of migration files in a directory.
@@ -105,6 +141,13 @@ func ReadRawMigration(dir fs.FS, filename string) (*RawMigration, error) {
 		return nil, err
 	}
 
+	return decodeRawMigration(filename, byteValue)
+}
+
+// decodeRawMigration decodes the contents of a migration file as a
+// RawMigration. The file extension determines the format of the contents.
+func decodeRawMigration(filename string, byteValue []byte) (*RawMigration, error) {
+	var err error
 	mig := RawMigration{}
 	switch filepath.Ext(filename) {
 	case ".json":
@@ -134,7 +177,11 @@ func ParseMigration(raw *RawMigration) (*Migration, error) {
 	return &Migration{
 		Name:          raw.Name,
 		VersionSchema: raw.VersionSchema,
+		Transactional: raw.Transactional,
+		Reversible:    raw.Reversible,
+		Environments:  raw.Environments,
 		Operations:    ops,
+		Assertions:    raw.Assertions,
 	}, nil
 }
 
@@ -148,6 +195,34 @@ func ReadMigration(dir fs.FS, filename string) (*Migration, error) {
 	return ParseMigration(raw)
 }
 
+// operationCommentKey is the key of the comment of an operation, set alongside
+// the operation in its operation object.
+const operationCommentKey = "comment"
+
+// operationComments holds the comment of each operation that has one. The
+// comment is kept outside of the operation, as several operations already
+// have a `comment` field for the comment set on the object they create.
+var operationComments sync.Map
+
+// OperationComment returns the comment that annotates the SQL executed for the
+// operation, or an empty string if the operation has no comment.
+func OperationComment(op Operation) string {
+	if comment, ok := operationComments.Load(op); ok {
+		return comment.(string)
+	}
+	return ""
+}
+
+// SetOperationComment sets the comment that annotates the SQL executed for
+// the operation. An empty comment removes the operation's comment.
+func SetOperationComment(op Operation, comment string) {
+	if comment == "" {
+		operationComments.Delete(op)
+		return
+	}
+	operationComments.Store(op, comment)
+}
+
 // UnmarshalJSON deserializes the list of operations from a JSON array.
 func (v *Operations) UnmarshalJSON(data []byte) error {
 	var tmp []map[string]json.RawMessage
@@ -162,8 +237,19 @@ func (v *Operations) UnmarshalJSON(data []byte) error {
 
 	ops := make([]Operation, len(tmp))
 	for i, opObj := range tmp {
+		var comment string
+		if rawComment, ok := opObj[operationCommentKey]; ok {
+			if err := json.Unmarshal(rawComment, &comment); err != nil {
+				return fmt.Errorf("decode comment of operation at index %d: %w", i, err)
+			}
+			delete(opObj, operationCommentKey)
+		}
+
 		var opName OpName
 		var logBody json.RawMessage
+		if len(opObj) == 0 {
+			return fmt.Errorf("no operation in operation object at index %d", i)
+		}
 		if len(opObj) != 1 {
 			return fmt.Errorf("multiple keys in operation object at index %d: %v",
 				i, strings.Join(slices.Collect(maps.Keys(opObj)), ", "))
@@ -178,12 +264,19 @@ func (v *Operations) UnmarshalJSON(data []byte) error {
 			return err
 		}
 
+		// Fill in the defaults declared in the JSON schema for omitted fields
+		logBody, err = operationDefaults[opName].applyDefaults(logBody)
+		if err != nil {
+			return fmt.Errorf("apply defaults to migration [%v]: %w", opName, err)
+		}
+
 		dec := json.NewDecoder(bytes.NewReader(logBody))
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(item); err != nil {
 			return fmt.Errorf("decode migration [%v]: %w", opName, err)
 		}
 
+		SetOperationComment(item, comment)
 		ops[i] = item
 	}
 
@@ -206,7 +299,15 @@ func (v Operations) MarshalJSON() ([]byte, error) {
 			buf.WriteByte(',')
 		}
 
-		buf.WriteString(`{"`)
+		buf.WriteByte('{')
+		if comment := OperationComment(op); comment != "" {
+			buf.WriteString(`"` + operationCommentKey + `":`)
+			if err := enc.Encode(comment); err != nil {
+				return nil, fmt.Errorf("unable to encode comment of op [%v]: %w", i, err)
+			}
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('"')
 		buf.WriteString(string(OperationName(op)))
 		buf.WriteString(`":`)
 		if err := enc.Encode(op); err != nil {
@@ -266,6 +367,51 @@ func OperationName(op Operation) OpName {
 	case *OpDropMultiColumnConstraint:
 		return OpNameDropMultiColumnConstraint
 
+	case *OpCreateType:
+		return OpNameCreateType
+
+	case *OpDropType:
+		return OpNameDropType
+
+	case *OpAlterTrigger:
+		return OpNameAlterTrigger
+
+	case *OpCreateForeignTable:
+		return OpNameCreateForeignTable
+
+	case *OpDropForeignTable:
+		return OpNameDropForeignTable
+
+	case *OpCreateTableAs:
+		return OpNameCreateTableAs
+
+	case *OpAlterDefaultPrivileges:
+		return OpNameAlterDefaultPrivileges
+
+	case *OpSetPrimaryKey:
+		return OpNameSetPrimaryKey
+
+	case *OpCreateRule:
+		return OpNameCreateRule
+
+	case *OpDropRule:
+		return OpNameDropRule
+
+	case *OpTruncate:
+		return OpNameTruncate
+
+	case *OpAttachInherit:
+		return OpNameAttachInherit
+
+	case *OpDetachInherit:
+		return OpNameDetachInherit
+
+	case *OpCreatePartition:
+		return OpNameCreatePartition
+
+	case *OpValidateConstraint:
+		return OpNameValidateConstraint
+
 	}
 
 	panic(fmt.Errorf("unknown operation for %T", op))
@@ -318,6 +464,51 @@ func OperationFromName(name OpName) (Operation, error) {
 	case OpNameDropMultiColumnConstraint:
 		return &OpDropMultiColumnConstraint{}, nil
 
+	case OpNameCreateType:
+		return &OpCreateType{}, nil
+
+	case OpNameDropType:
+		return &OpDropType{}, nil
+
+	case OpNameAlterTrigger:
+		return &OpAlterTrigger{}, nil
+
+	case OpNameCreateForeignTable:
+		return &OpCreateForeignTable{}, nil
+
+	case OpNameDropForeignTable:
+		return &OpDropForeignTable{}, nil
+
+	case OpNameCreateTableAs:
+		return &OpCreateTableAs{}, nil
+
+	case OpNameAlterDefaultPrivileges:
+		return &OpAlterDefaultPrivileges{}, nil
+
+	case OpNameSetPrimaryKey:
+		return &OpSetPrimaryKey{}, nil
+
+	case OpNameCreateRule:
+		return &OpCreateRule{}, nil
+
+	case OpNameDropRule:
+		return &OpDropRule{}, nil
+
+	case OpNameTruncate:
+		return &OpTruncate{}, nil
+
+	case OpNameAttachInherit:
+		return &OpAttachInherit{}, nil
+
+	case OpNameDetachInherit:
+		return &OpDetachInherit{}, nil
+
+	case OpNameCreatePartition:
+		return &OpCreatePartition{}, nil
+
+	case OpNameValidateConstraint:
+		return &OpValidateConstraint{}, nil
+
 	}
 	return nil, fmt.Errorf("unknown migration type: %v", name)
 }
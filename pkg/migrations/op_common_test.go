@@ -155,6 +155,69 @@ func TableMustNotExist(t *testing.T, db *sql.DB, schema, table string) {
 	}
 }
 
+func TypeMustExist(t *testing.T, db *sql.DB, schema, typ string) {
+	t.Helper()
+	if !typeExists(t, db, schema, typ) {
+		t.Fatalf("Expected type %q to exist", typ)
+	}
+}
+
+func TypeMustNotExist(t *testing.T, db *sql.DB, schema, typ string) {
+	t.Helper()
+	if typeExists(t, db, schema, typ) {
+		t.Fatalf("Expected type %q to not exist", typ)
+	}
+}
+
+func ForeignTableMustExist(t *testing.T, db *sql.DB, schema, table string) {
+	t.Helper()
+	if !foreignTableExists(t, db, schema, table) {
+		t.Fatalf("Expected foreign table %q to exist", table)
+	}
+}
+
+func ForeignTableMustNotExist(t *testing.T, db *sql.DB, schema, table string) {
+	t.Helper()
+	if foreignTableExists(t, db, schema, table) {
+		t.Fatalf("Expected foreign table %q to not exist", table)
+	}
+}
+
+func RuleMustExist(t *testing.T, db *sql.DB, schema, table, rule string) {
+	t.Helper()
+	if !ruleExists(t, db, schema, table, rule) {
+		t.Fatalf("Expected rule %q to exist", rule)
+	}
+}
+
+func RuleMustNotExist(t *testing.T, db *sql.DB, schema, table, rule string) {
+	t.Helper()
+	if ruleExists(t, db, schema, table, rule) {
+		t.Fatalf("Expected rule %q to not exist", rule)
+	}
+}
+
+func TableMustInherit(t *testing.T, db *sql.DB, schema, table, parent string) {
+	t.Helper()
+	if !tableInherits(t, db, schema, table, parent) {
+		t.Fatalf("Expected table %q to inherit from table %q", table, parent)
+	}
+}
+
+func TableMustNotInherit(t *testing.T, db *sql.DB, schema, table, parent string) {
+	t.Helper()
+	if tableInherits(t, db, schema, table, parent) {
+		t.Fatalf("Expected table %q to not inherit from table %q", table, parent)
+	}
+}
+
+func TableMustBePartitionOf(t *testing.T, db *sql.DB, schema, table, parent string) {
+	t.Helper()
+	if !tableIsPartitionOf(t, db, schema, table, parent) {
+		t.Fatalf("Expected table %q to be a partition of table %q", table, parent)
+	}
+}
+
 func ColumnMustExist(t *testing.T, db *sql.DB, schema, table, column string) {
 	t.Helper()
 	if !columnExists(t, db, schema, table, column) {
@@ -190,6 +253,41 @@ func ColumnMustNotHaveComment(t *testing.T, db *sql.DB, schema, table, column st
 	}
 }
 
+func ColumnMustHaveStorage(t *testing.T, db *sql.DB, schema, table, column, expectedStorage string) {
+	t.Helper()
+	if actual := columnStorage(t, db, schema, table, column); actual != expectedStorage {
+		t.Fatalf("Expected column %q to have storage %q, got %q", column, expectedStorage, actual)
+	}
+}
+
+func ColumnMustHaveCompression(t *testing.T, db *sql.DB, schema, table, column, expectedCompression string) {
+	t.Helper()
+	if actual := columnCompression(t, db, schema, table, column); actual != expectedCompression {
+		t.Fatalf("Expected column %q to have compression %q, got %q", column, expectedCompression, actual)
+	}
+}
+
+func ColumnMustHaveAttnum(t *testing.T, db *sql.DB, schema, table, column string, expectedAttnum int) {
+	t.Helper()
+	if actual := columnAttnum(t, db, schema, table, column); actual != expectedAttnum {
+		t.Fatalf("Expected column %q to have attnum %d, got %d", column, expectedAttnum, actual)
+	}
+}
+
+func ColumnMustBeGenerated(t *testing.T, db *sql.DB, schema, table, column string) {
+	t.Helper()
+	if !columnIsGenerated(t, db, schema, table, column) {
+		t.Fatalf("Expected column %q to be a generated column", column)
+	}
+}
+
+func ColumnMustNotBeGenerated(t *testing.T, db *sql.DB, schema, table, column string) {
+	t.Helper()
+	if columnIsGenerated(t, db, schema, table, column) {
+		t.Fatalf("Expected column %q not to be a generated column", column)
+	}
+}
+
 func ColumnMustHaveDefault(t *testing.T, db *sql.DB, schema, table, column, expectedDefault string) {
 	t.Helper()
 	if !columnHasDefault(t, db, schema, table, column, &expectedDefault) {
@@ -253,6 +351,20 @@ func NotInheritableCheckConstraintMustExist(t *testing.T, db *sql.DB, schema, ta
 	}
 }
 
+func ValidatedConstraintMustExist(t *testing.T, db *sql.DB, schema, table, constraint string) {
+	t.Helper()
+	if !constraintValidated(t, db, schema, table, constraint) {
+		t.Fatalf("Expected constraint %q to be validated", constraint)
+	}
+}
+
+func NotValidatedConstraintMustExist(t *testing.T, db *sql.DB, schema, table, constraint string) {
+	t.Helper()
+	if constraintValidated(t, db, schema, table, constraint) {
+		t.Fatalf("Expected constraint %q to not be validated", constraint)
+	}
+}
+
 func UniqueConstraintMustExist(t *testing.T, db *sql.DB, schema, table, constraint string) {
 	t.Helper()
 	if !uniqueConstraintExists(t, db, schema, table, constraint) {
@@ -295,6 +407,13 @@ func TableForeignKeyMustExist(t *testing.T, db *sql.DB, schema, table, constrain
 	}
 }
 
+func DeferrableConstraintMustExist(t *testing.T, db *sql.DB, schema, table, constraint string, initiallyDeferred bool) {
+	t.Helper()
+	if !deferrableConstraintExists(t, db, schema, table, constraint, initiallyDeferred) {
+		t.Fatalf("Expected deferrable constraint %q to exist", constraint)
+	}
+}
+
 func PrimaryKeyConstraintMustExist(t *testing.T, db *sql.DB, schema, table, constraint string) {
 	t.Helper()
 	if !primaryKeyConstraintExists(t, db, schema, table, constraint) {
@@ -330,6 +449,50 @@ func IndexMustNotExist(t *testing.T, db *sql.DB, schema, table, index string) {
 	}
 }
 
+func TriggerStateMustBe(t *testing.T, db *sql.DB, schema, table, trigger, state string) {
+	t.Helper()
+
+	var actualState string
+	err := db.QueryRow(`
+    SELECT tg.tgenabled
+    FROM pg_trigger tg
+    JOIN pg_class c ON c.oid = tg.tgrelid
+    JOIN pg_namespace n ON n.oid = c.relnamespace
+    WHERE n.nspname = $1
+    AND c.relname = $2
+    AND tg.tgname = $3;
+  `, schema, table, trigger).Scan(&actualState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state != actualState {
+		t.Fatalf("Expected state of trigger %q to be %q, got %q", trigger, state, actualState)
+	}
+}
+
+func RuleStateMustBe(t *testing.T, db *sql.DB, schema, table, rule, state string) {
+	t.Helper()
+
+	var actualState string
+	err := db.QueryRow(`
+    SELECT rw.ev_enabled
+    FROM pg_rewrite rw
+    JOIN pg_class c ON c.oid = rw.ev_class
+    JOIN pg_namespace n ON n.oid = c.relnamespace
+    WHERE n.nspname = $1
+    AND c.relname = $2
+    AND rw.rulename = $3;
+  `, schema, table, rule).Scan(&actualState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state != actualState {
+		t.Fatalf("Expected state of rule %q to be %q, got %q", rule, state, actualState)
+	}
+}
+
 func ReplicaIdentityMustBe(t *testing.T, db *sql.DB, schema, table, replicaIdentity string) {
 	t.Helper()
 
@@ -411,6 +574,26 @@ func CheckIndexDefinition(t *testing.T, db *sql.DB, schema, table, index, expect
 	}
 }
 
+// ConstraintDefinition returns the definition of the named constraint, as
+// reported by pg_get_constraintdef.
+func ConstraintDefinition(t *testing.T, db *sql.DB, schema, table, constraint string) string {
+	t.Helper()
+
+	var def string
+	err := db.QueryRow(`
+    SELECT pg_get_constraintdef(oid)
+    FROM pg_catalog.pg_constraint
+    WHERE conrelid = $1::regclass
+    AND conname = $2
+  `,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)), constraint).Scan(&def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return def
+}
+
 func checkConstraintExists(t *testing.T, db *sql.DB, schema, table, constraint string, noInherit bool) bool {
 	t.Helper()
 
@@ -432,6 +615,23 @@ func checkConstraintExists(t *testing.T, db *sql.DB, schema, table, constraint s
 	return exists
 }
 
+func constraintValidated(t *testing.T, db *sql.DB, schema, table, constraint string) bool {
+	t.Helper()
+
+	var validated bool
+	err := db.QueryRow(`
+    SELECT convalidated
+    FROM pg_catalog.pg_constraint
+    WHERE conrelid = $1::regclass
+    AND conname = $2`,
+		fmt.Sprintf("%s.%s", schema, table), constraint).Scan(&validated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return validated
+}
+
 func uniqueConstraintExists(t *testing.T, db *sql.DB, schema, table, constraint string) bool {
 	t.Helper()
 
@@ -452,6 +652,27 @@ func uniqueConstraintExists(t *testing.T, db *sql.DB, schema, table, constraint
 	return exists
 }
 
+func deferrableConstraintExists(t *testing.T, db *sql.DB, schema, table, constraint string, initiallyDeferred bool) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRow(`
+    SELECT EXISTS (
+      SELECT 1
+      FROM pg_catalog.pg_constraint
+      WHERE conrelid = $1::regclass
+      AND conname = $2
+      AND condeferrable
+      AND condeferred = $3
+    )`,
+		fmt.Sprintf("%s.%s", schema, table), constraint, initiallyDeferred).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
 func referentialAction(a migrations.ForeignKeyAction) string {
 	switch a {
 	case migrations.ForeignKeyActionNOACTION:
@@ -583,6 +804,67 @@ func triggerExists(t *testing.T, db *sql.DB, schema, table, trigger string) bool
 	return exists
 }
 
+func ruleExists(t *testing.T, db *sql.DB, schema, table, rule string) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRow(`
+    SELECT EXISTS (
+      SELECT 1
+      FROM pg_catalog.pg_rewrite
+      WHERE ev_class = $1::regclass
+      AND rulename = $2
+    )`,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)), rule).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
+func tableInherits(t *testing.T, db *sql.DB, schema, table, parent string) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRow(`
+    SELECT EXISTS (
+      SELECT 1
+      FROM pg_catalog.pg_inherits
+      WHERE inhrelid = $1::regclass
+      AND inhparent = $2::regclass
+    )`,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)),
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(parent))).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
+func tableIsPartitionOf(t *testing.T, db *sql.DB, schema, table, parent string) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRow(`
+    SELECT EXISTS (
+      SELECT 1
+      FROM pg_catalog.pg_inherits AS inh
+      JOIN pg_catalog.pg_class AS c ON c.oid = inh.inhrelid
+      WHERE inh.inhrelid = $1::regclass
+      AND inh.inhparent = $2::regclass
+      AND c.relispartition
+    )`,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)),
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(parent))).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
 func functionExists(t *testing.T, db *sql.DB, schema, functionName string) bool {
 	t.Helper()
 
@@ -621,6 +903,46 @@ func tableExists(t *testing.T, db *sql.DB, schema, table string) bool {
 	return exists
 }
 
+func foreignTableExists(t *testing.T, db *sql.DB, schema, table string) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM pg_catalog.pg_class c
+			JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = $1
+			AND c.relname = $2
+			AND c.relkind = 'f'
+		)`,
+		schema, table).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
+func typeExists(t *testing.T, db *sql.DB, schema, typ string) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM pg_catalog.pg_type
+			WHERE typname = $1
+			AND typnamespace = $2::regnamespace
+		)`,
+		typ, schema).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
 func tableMustHaveColumnCount(t *testing.T, db *sql.DB, schema, table string, n int) bool {
 	t.Helper()
 
@@ -717,6 +1039,79 @@ func columnHasComment(t *testing.T, db *sql.DB, schema, table, column string, ex
 	return actualComment != nil && *expectedComment == *actualComment
 }
 
+func columnStorage(t *testing.T, db *sql.DB, schema, table, column string) string {
+	t.Helper()
+
+	var storage string
+	err := db.QueryRow(`
+    SELECT CASE attstorage
+      WHEN 'p' THEN 'plain'
+      WHEN 'e' THEN 'external'
+      WHEN 'x' THEN 'extended'
+      WHEN 'm' THEN 'main'
+    END
+    FROM pg_attribute
+    WHERE attrelid = $1::regclass AND attname = $2`,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)), column,
+	).Scan(&storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func columnAttnum(t *testing.T, db *sql.DB, schema, table, column string) int {
+	t.Helper()
+
+	var attnum int
+	err := db.QueryRow(`
+    SELECT attnum
+    FROM pg_attribute
+    WHERE attrelid = $1::regclass AND attname = $2`,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)), column,
+	).Scan(&attnum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attnum
+}
+
+func columnIsGenerated(t *testing.T, db *sql.DB, schema, table, column string) bool {
+	t.Helper()
+
+	var generated bool
+	err := db.QueryRow(`
+    SELECT attgenerated = 's'
+    FROM pg_attribute
+    WHERE attrelid = $1::regclass AND attname = $2`,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)), column,
+	).Scan(&generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return generated
+}
+
+func columnCompression(t *testing.T, db *sql.DB, schema, table, column string) string {
+	t.Helper()
+
+	var compression string
+	err := db.QueryRow(`
+    SELECT CASE attcompression
+      WHEN 'p' THEN 'pglz'
+      WHEN 'l' THEN 'lz4'
+      ELSE 'default'
+    END
+    FROM pg_attribute
+    WHERE attrelid = $1::regclass AND attname = $2`,
+		fmt.Sprintf("%s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)), column,
+	).Scan(&compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compression
+}
+
 func columnHasDefault(t *testing.T, db *sql.DB, schema, table, column string, expectedDefault *string) bool {
 	t.Helper()
 
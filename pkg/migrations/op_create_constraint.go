@@ -4,6 +4,7 @@ package migrations
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/db"
@@ -31,12 +32,20 @@ func (o *OpCreateConstraint) Start(ctx context.Context, l Logger, conn db.DB, s
 		}
 	}
 
+	var dbActions []DBAction
+
+	// Report rows that would violate a foreign key validated immediately
+	// before the table is changed
+	if o.Type == OpCreateConstraintTypeForeignKey && o.References.Validate == ForeignKeyValidationImmediate {
+		dbActions = append(dbActions, NewCheckForeignKeyOrphansAction(conn, table, o.Name, o.Columns, o.Up, o.References))
+	}
+
 	// Duplicate each column using its final name after migration completion
 	d := NewColumnDuplicator(conn, table, columns...)
 	for _, colName := range o.Columns {
-		d = d.WithName(table.GetColumn(colName).Name, TemporaryName(colName))
+		d = d.WithName(table.GetColumn(colName).Name, NamingFrom(ctx).TemporaryName(colName))
 	}
-	dbActions := []DBAction{d}
+	dbActions = append(dbActions, d)
 
 	// Copy the columns from table columns, so we can use it later
 	// in the down trigger with the physical name
@@ -51,11 +60,11 @@ func (o *OpCreateConstraint) Start(ctx context.Context, l Logger, conn db.DB, s
 		upSQL := o.Up[colName]
 		triggers = append(triggers,
 			backfill.OperationTrigger{
-				Name:           backfill.TriggerName(o.Table, colName),
+				Name:           NamingFrom(ctx).TriggerName(o.Table, colName),
 				Direction:      backfill.TriggerDirectionUp,
 				Columns:        upColumns,
 				TableName:      table.Name,
-				PhysicalColumn: TemporaryName(colName),
+				PhysicalColumn: NamingFrom(ctx).TemporaryName(colName),
 				SQL:            upSQL,
 			},
 		)
@@ -66,13 +75,13 @@ func (o *OpCreateConstraint) Start(ctx context.Context, l Logger, conn db.DB, s
 		// physical column name in the down trigger first.
 		oldPhysicalColumn := table.GetColumn(colName).Name
 		table.AddColumn(colName, &schema.Column{
-			Name: TemporaryName(colName),
+			Name: NamingFrom(ctx).TemporaryName(colName),
 		})
 
 		downSQL := o.Down[colName]
 		triggers = append(triggers,
 			backfill.OperationTrigger{
-				Name:           backfill.TriggerName(o.Table, TemporaryName(colName)),
+				Name:           NamingFrom(ctx).TriggerName(o.Table, NamingFrom(ctx).TemporaryName(colName)),
 				Direction:      backfill.TriggerDirectionDown,
 				Columns:        table.Columns,
 				TableName:      table.Name,
@@ -87,7 +96,7 @@ func (o *OpCreateConstraint) Start(ctx context.Context, l Logger, conn db.DB, s
 	switch o.Type {
 	case OpCreateConstraintTypeUnique, OpCreateConstraintTypePrimaryKey:
 		dbActions = append(dbActions,
-			NewCreateUniqueIndexConcurrentlyAction(conn, s.Name, o.Name, table.Name, temporaryNames(o.Columns)...),
+			NewCreateUniqueIndexConcurrentlyAction(conn, s.Name, o.Name, table.Name, temporaryNames(ctx, o.Columns)...),
 		)
 		return &StartResult{Actions: dbActions, BackfillTask: task}, nil
 
@@ -98,8 +107,12 @@ func (o *OpCreateConstraint) Start(ctx context.Context, l Logger, conn db.DB, s
 		return &StartResult{Actions: dbActions, BackfillTask: task}, nil
 
 	case OpCreateConstraintTypeForeignKey:
+		// A foreign key validated immediately is added as VALID while the new
+		// columns are still empty, so that the backfill checks every row
+		// against it and no separate validation is needed on completion
+		skipValidation := o.References.Validate != ForeignKeyValidationImmediate
 		dbActions = append(dbActions,
-			NewCreateFKConstraintAction(conn, table.Name, o.Name, temporaryNames(o.Columns), o.References, false, false, true),
+			NewCreateFKConstraintAction(conn, table.Name, o.Name, temporaryNames(ctx, o.Columns), o.References, o.InitiallyDeferred, o.isDeferrable(), skipValidation),
 		)
 		return &StartResult{Actions: dbActions, BackfillTask: task}, nil
 	}
@@ -107,21 +120,14 @@ func (o *OpCreateConstraint) Start(ctx context.Context, l Logger, conn db.DB, s
 	return &StartResult{Actions: dbActions, BackfillTask: task}, nil
 }
 
-func (o *OpCreateConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpCreateConstraint) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	dbActions := make([]DBAction, 0)
 	switch o.Type {
 	case OpCreateConstraintTypeUnique:
-		uniqueOp := &OpSetUnique{
-			Table: o.Table,
-			Name:  o.Name,
-		}
-		actions, err := uniqueOp.Complete(l, conn, s)
-		if err != nil {
-			return nil, err
-		}
-		dbActions = append(dbActions, actions...)
+		// Create a unique constraint using the unique index
+		dbActions = append(dbActions, NewAddConstraintUsingUniqueIndex(conn, o.Table, o.Name, o.Name, o.InitiallyDeferred, o.isDeferrable()))
 	case OpCreateConstraintTypeCheck:
 		checkOp := &OpSetCheckConstraint{
 			Table: o.Table,
@@ -129,29 +135,33 @@ func (o *OpCreateConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([
 				Name: o.Name,
 			},
 		}
-		actions, err := checkOp.Complete(l, conn, s)
+		actions, err := checkOp.Complete(ctx, l, conn, s)
 		if err != nil {
 			return nil, err
 		}
 		dbActions = append(dbActions, actions...)
 	case OpCreateConstraintTypeForeignKey:
+		if o.References.Validate == ForeignKeyValidationImmediate {
+			// the constraint was added as VALID when the migration started
+			break
+		}
 		fkOp := &OpSetForeignKey{
 			Table: o.Table,
 			References: ForeignKeyReference{
 				Name: o.Name,
 			},
 		}
-		actions, err := fkOp.Complete(l, conn, s)
+		actions, err := fkOp.Complete(ctx, l, conn, s)
 		if err != nil {
 			return nil, err
 		}
 		dbActions = append(dbActions, actions...)
 	case OpCreateConstraintTypePrimaryKey:
-		dbActions = append(dbActions, NewAddPrimaryKeyAction(conn, o.Table, o.Name))
+		dbActions = append(dbActions, NewAddPrimaryKeyAction(conn, o.Table, o.Name, o.InitiallyDeferred, o.isDeferrable()))
 	}
 
 	for _, col := range o.Columns {
-		dbActions = append(dbActions, NewAlterSequenceOwnerAction(conn, o.Table, col, TemporaryName(col)))
+		dbActions = append(dbActions, NewAlterSequenceOwnerAction(conn, o.Table, col, NamingFrom(ctx).TemporaryName(col)))
 	}
 
 	dbActions = append(dbActions, NewDropColumnAction(conn, o.Table, o.Columns...))
@@ -169,14 +179,14 @@ func (o *OpCreateConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([
 		dbActions = append(dbActions, NewRenameDuplicatedColumnAction(conn, table, column.Name))
 	}
 	dbActions = append(dbActions,
-		o.removeTriggers(conn),
-		NewDropColumnAction(conn, o.Table, backfill.CNeedsBackfillColumn),
+		o.removeTriggers(ctx, conn),
+		NewDropNeedsBackfillColumnAction(conn, o.Table),
 	)
 
 	return dbActions, nil
 }
 
-func (o *OpCreateConstraint) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpCreateConstraint) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	table := s.GetTable(o.Table)
@@ -185,17 +195,17 @@ func (o *OpCreateConstraint) Rollback(l Logger, conn db.DB, s *schema.Schema) ([
 	}
 
 	return []DBAction{
-		NewDropColumnAction(conn, table.Name, temporaryNames(o.Columns)...),
-		o.removeTriggers(conn),
-		NewDropColumnAction(conn, table.Name, backfill.CNeedsBackfillColumn),
+		NewDropColumnAction(conn, table.Name, temporaryNames(ctx, o.Columns)...),
+		o.removeTriggers(ctx, conn),
+		NewDropNeedsBackfillColumnAction(conn, table.Name),
 	}, nil
 }
 
-func (o *OpCreateConstraint) removeTriggers(conn db.DB) DBAction {
+func (o *OpCreateConstraint) removeTriggers(ctx context.Context, conn db.DB) DBAction {
 	dropFuncs := make([]string, 0, len(o.Columns)*2)
 	for _, column := range o.Columns {
-		dropFuncs = append(dropFuncs, backfill.TriggerFunctionName(o.Table, column))
-		dropFuncs = append(dropFuncs, backfill.TriggerFunctionName(o.Table, TemporaryName(column)))
+		dropFuncs = append(dropFuncs, NamingFrom(ctx).TriggerFunctionName(o.Table, column))
+		dropFuncs = append(dropFuncs, NamingFrom(ctx).TriggerFunctionName(o.Table, NamingFrom(ctx).TemporaryName(column)))
 	}
 	return NewDropFunctionAction(conn, dropFuncs...)
 }
@@ -230,7 +240,7 @@ func (o *OpCreateConstraint) Validate(ctx context.Context, s *schema.Schema) err
 				Name:  col,
 			}
 		}
-		if _, ok := o.Down[col]; !ok {
+		if _, ok := o.Down[col]; !ok && downSQLRequired(ctx) {
 			return ColumnMigrationMissingError{
 				Table: o.Table,
 				Name:  col,
@@ -243,14 +253,38 @@ func (o *OpCreateConstraint) Validate(ctx context.Context, s *schema.Schema) err
 		if len(o.Columns) == 0 {
 			return FieldRequiredError{Name: "columns"}
 		}
+		if !table.IncludesPartitionKey(o.Columns) {
+			return PartitionKeyNotIncludedError{Table: o.Table, Constraint: fmt.Sprintf("unique constraint %q", o.Name)}
+		}
+	case OpCreateConstraintTypePrimaryKey:
+		if !table.IncludesPartitionKey(o.Columns) {
+			return PartitionKeyNotIncludedError{Table: o.Table, Constraint: "primary key"}
+		}
 	case OpCreateConstraintTypeCheck:
 		if o.Check == nil || *o.Check == "" {
 			return FieldRequiredError{Name: "check"}
 		}
+		if o.isDeferrable() {
+			return CheckConstraintError{
+				Table: o.Table,
+				Name:  o.Name,
+				Err:   ErrDeferrableCheckConstraint,
+			}
+		}
 	case OpCreateConstraintTypeForeignKey:
 		if o.References == nil {
 			return FieldRequiredError{Name: "references"}
 		}
+		switch o.References.Validate {
+		case "", ForeignKeyValidationDeferred:
+		case ForeignKeyValidationImmediate:
+			if len(o.References.Columns) != len(o.Columns) {
+				return InvalidMigrationError{Reason: fmt.Sprintf("foreign key %q must reference as many columns as it constrains", o.Name)}
+			}
+		default:
+			return InvalidMigrationError{Reason: fmt.Sprintf("invalid validate setting %q for foreign key %q: must be %q or %q",
+				o.References.Validate, o.Name, ForeignKeyValidationDeferred, ForeignKeyValidationImmediate)}
+		}
 		table := s.GetTable(o.References.Table)
 		if table == nil {
 			return TableDoesNotExistError{Name: o.References.Table}
@@ -268,10 +302,16 @@ func (o *OpCreateConstraint) Validate(ctx context.Context, s *schema.Schema) err
 	return nil
 }
 
-func temporaryNames(columns []string) []string {
+// isDeferrable returns true if the constraint is to be created DEFERRABLE. As
+// in Postgres, INITIALLY DEFERRED implies DEFERRABLE.
+func (o *OpCreateConstraint) isDeferrable() bool {
+	return o.Deferrable || o.InitiallyDeferred
+}
+
+func temporaryNames(ctx context.Context, columns []string) []string {
 	names := make([]string, len(columns))
 	for i, col := range columns {
-		names[i] = TemporaryName(col)
+		names[i] = NamingFrom(ctx).TemporaryName(col)
 	}
 	return names
 }
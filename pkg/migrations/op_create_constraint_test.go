@@ -4,6 +4,7 @@ package migrations_test
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -1668,3 +1669,342 @@ func TestCreateConstraintValidation(t *testing.T) {
 		},
 	})
 }
+
+func TestCreateForeignKeyConstraintValidatedImmediately(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "posts",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+					{Name: "user_id", Type: "integer", Nullable: true},
+				},
+			},
+		},
+	}
+
+	createConstraintMigration := func(up string) migrations.Migration {
+		return migrations.Migration{
+			Name: "03_create_constraint",
+			Operations: migrations.Operations{
+				&migrations.OpCreateConstraint{
+					Name:    "fk_posts_user",
+					Table:   "posts",
+					Type:    migrations.OpCreateConstraintTypeForeignKey,
+					Columns: []string{"user_id"},
+					References: &migrations.TableForeignKeyReference{
+						Table:    "users",
+						Columns:  []string{"id"},
+						Validate: migrations.ForeignKeyValidationImmediate,
+					},
+					Up:   migrations.MultiColumnUpSQL{"user_id": up},
+					Down: migrations.MultiColumnDownSQL{"user_id": "user_id"},
+				},
+			},
+		}
+	}
+
+	insertRowsMigration := func(posts string) migrations.Migration {
+		return migrations.Migration{
+			Name: "02_insert_rows",
+			Operations: migrations.Operations{
+				&migrations.OpRawSQL{
+					Up: "INSERT INTO users VALUES (1), (2); INSERT INTO posts VALUES " + posts,
+				},
+			},
+		}
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "a foreign key validated immediately is valid once the migration starts",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				insertRowsMigration("(1, 1), (2, 2), (3, NULL)"),
+				createConstraintMigration("user_id"),
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The constraint is added as VALID rather than NOT VALID
+				ValidatedForeignKeyMustExist(t, db, schema, "posts", "fk_posts_user")
+
+				// Inserting a row that violates the constraint into the new schema fails
+				MustNotInsert(t, db, schema, "03_create_constraint", "posts", map[string]string{
+					"id":      "4",
+					"user_id": "3",
+				}, testutils.FKViolationErrorCode)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "posts", "user_id")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ValidatedForeignKeyMustExist(t, db, schema, "posts", "fk_posts_user")
+				TableMustBeCleanedUp(t, db, schema, "posts", "user_id")
+			},
+		},
+		{
+			name: "rows that reference missing rows are reported before the table is changed",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				insertRowsMigration("(1, 1), (2, 4), (3, 3), (4, 4)"),
+				createConstraintMigration("user_id"),
+			},
+			wantStartErr: migrations.ForeignKeyOrphansError{
+				Table:           "posts",
+				Constraint:      "fk_posts_user",
+				ReferencedTable: "users",
+				Rows:            3,
+				Values:          "(3), (4), (4)",
+			},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+		{
+			name: "rows are checked against the values computed by up",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				insertRowsMigration("(1, 1), (2, 3)"),
+				createConstraintMigration("CASE WHEN user_id IN (SELECT id FROM users) THEN user_id END"),
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				ValidatedForeignKeyMustExist(t, db, schema, "posts", "fk_posts_user")
+
+				// The post that references a missing user is backfilled with NULL
+				rows := MustSelect(t, db, schema, "03_create_constraint", "posts")
+				assert.ElementsMatch(t, []map[string]any{
+					{"id": 1, "user_id": 1},
+					{"id": 2, "user_id": nil},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+		{
+			name: "an invalid validate setting is rejected",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpCreateConstraint{
+							Name:    "fk_posts_user",
+							Table:   "posts",
+							Type:    migrations.OpCreateConstraintTypeForeignKey,
+							Columns: []string{"user_id"},
+							References: &migrations.TableForeignKeyReference{
+								Table:    "users",
+								Columns:  []string{"id"},
+								Validate: "never",
+							},
+							Up:   migrations.MultiColumnUpSQL{"user_id": "user_id"},
+							Down: migrations.MultiColumnDownSQL{"user_id": "user_id"},
+						},
+					},
+				},
+			},
+			wantStartErr:  migrations.InvalidMigrationError{Reason: `invalid validate setting "never" for foreign key "fk_posts_user": must be "deferred" or "immediate"`},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+	})
+}
+
+func TestCreateDeferrableConstraint(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+					{Name: "name", Type: "text", Nullable: true},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "posts",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "user_id", Type: "integer", Nullable: true},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create deferrable unique constraint",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpCreateConstraint{
+							Name:              "unique_name",
+							Table:             "users",
+							Type:              migrations.OpCreateConstraintTypeUnique,
+							Columns:           []string{"name"},
+							Deferrable:        true,
+							InitiallyDeferred: true,
+							Up:                migrations.MultiColumnUpSQL{"name": "name"},
+							Down:              migrations.MultiColumnDownSQL{"name": "name"},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The index has been created on the underlying table.
+				IndexMustExist(t, db, schema, "users", "unique_name")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "users", "name")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				DeferrableConstraintMustExist(t, db, schema, "users", "unique_name", true)
+				TableMustBeCleanedUp(t, db, schema, "users", "name")
+
+				// Swapping two names in one transaction succeeds, as uniqueness is
+				// checked on commit
+				MustInsert(t, db, schema, "02_create_constraint", "users", map[string]string{"id": "1", "name": "alice"})
+				MustInsert(t, db, schema, "02_create_constraint", "users", map[string]string{"id": "2", "name": "bob"})
+				mustExecInTransaction(t, db,
+					fmt.Sprintf("UPDATE %s.users SET name = 'bob' WHERE id = 1", schema),
+					fmt.Sprintf("UPDATE %s.users SET name = 'alice' WHERE id = 2", schema),
+				)
+			},
+		},
+		{
+			name: "create initially deferred foreign key",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpCreateConstraint{
+							Name:    "fk_posts_user",
+							Table:   "posts",
+							Type:    migrations.OpCreateConstraintTypeForeignKey,
+							Columns: []string{"user_id"},
+							References: &migrations.TableForeignKeyReference{
+								Table:   "users",
+								Columns: []string{"id"},
+							},
+							// INITIALLY DEFERRED implies DEFERRABLE
+							InitiallyDeferred: true,
+							Up:                migrations.MultiColumnUpSQL{"user_id": "user_id"},
+							Down:              migrations.MultiColumnDownSQL{"user_id": "user_id"},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TableForeignKeyMustExist(t, db, schema, "posts", "fk_posts_user", true, true)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "posts", "user_id")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableForeignKeyMustExist(t, db, schema, "posts", "fk_posts_user", true, true)
+				TableMustBeCleanedUp(t, db, schema, "posts", "user_id")
+
+				// A post can be inserted before the user it references in the same
+				// transaction
+				mustExecInTransaction(t, db,
+					fmt.Sprintf("INSERT INTO %s.posts (id, user_id) VALUES (1, 3)", schema),
+					fmt.Sprintf("INSERT INTO %s.users (id) VALUES (3)", schema),
+				)
+			},
+		},
+		{
+			name: "create deferrable primary key",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpCreateConstraint{
+							Name:       "posts_pkey",
+							Table:      "posts",
+							Type:       migrations.OpCreateConstraintTypePrimaryKey,
+							Columns:    []string{"id"},
+							Deferrable: true,
+							Up:         migrations.MultiColumnUpSQL{"id": "id"},
+							Down:       migrations.MultiColumnDownSQL{"id": "id"},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				IndexMustExist(t, db, schema, "posts", "posts_pkey")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "posts", "id")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				PrimaryKeyConstraintMustExist(t, db, schema, "posts", "posts_pkey")
+				DeferrableConstraintMustExist(t, db, schema, "posts", "posts_pkey", false)
+			},
+		},
+		{
+			name: "check constraints can't be deferrable",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpCreateConstraint{
+							Name:       "check_name",
+							Table:      "users",
+							Type:       migrations.OpCreateConstraintTypeCheck,
+							Check:      ptr("length(name) > 0"),
+							Columns:    []string{"name"},
+							Deferrable: true,
+							Up:         migrations.MultiColumnUpSQL{"name": "name"},
+							Down:       migrations.MultiColumnDownSQL{"name": "name"},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.CheckConstraintError{
+				Table: "users",
+				Name:  "check_name",
+				Err:   migrations.ErrDeferrableCheckConstraint,
+			},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+	})
+}
+
+// mustExecInTransaction runs the statements in a single transaction and fails
+// the test if any of them, or the commit, fails.
+func mustExecInTransaction(t *testing.T, db *sql.DB, stmts ...string) {
+	t.Helper()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
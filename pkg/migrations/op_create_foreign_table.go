@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation      = (*OpCreateForeignTable)(nil)
+	_ Createable     = (*OpCreateForeignTable)(nil)
+	_ OwnedOperation = (*OpCreateForeignTable)(nil)
+)
+
+func (o *OpCreateForeignTable) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	// Foreign tables hold no data of their own, so they need no backfill or
+	// triggers. They are not added to the in-memory schema and so are not
+	// exposed through the version schema views.
+	return &StartResult{Actions: []DBAction{
+		NewCreateForeignTableAction(conn, o.Name, o.Server, o.Columns, o.Options),
+	}}, nil
+}
+
+func (o *OpCreateForeignTable) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpCreateForeignTable) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	return []DBAction{NewDropForeignTableAction(conn, o.Name)}, nil
+}
+
+// OwnedObjects returns the foreign table created by the operation.
+func (o *OpCreateForeignTable) OwnedObjects() []OwnedObject {
+	return []OwnedObject{{Type: "FOREIGN TABLE", Name: o.Name}}
+}
+
+func (o *OpCreateForeignTable) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	if err := ValidateIdentifierLength(o.Name); err != nil {
+		return err
+	}
+
+	if s.GetTable(o.Name) != nil {
+		return TableAlreadyExistsError{Name: o.Name}
+	}
+
+	if o.Server == "" {
+		return FieldRequiredError{Name: "server"}
+	}
+
+	if len(o.Columns) == 0 {
+		return FieldRequiredError{Name: "columns"}
+	}
+
+	seen := make(map[string]struct{}, len(o.Columns))
+	for _, col := range o.Columns {
+		if col.Name == "" {
+			return FieldRequiredError{Name: "name"}
+		}
+		if col.Type == "" {
+			return FieldRequiredError{Name: "type"}
+		}
+		if err := ValidateIdentifierLength(col.Name); err != nil {
+			return err
+		}
+		if _, ok := seen[col.Name]; ok {
+			return InvalidMigrationError{Reason: fmt.Sprintf("duplicate column %q in foreign table %q", col.Name, o.Name)}
+		}
+		seen[col.Name] = struct{}{}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// createServerMigration creates a foreign server. Creating a foreign table
+// does not connect to the server, so it need not point at a real database.
+var createServerMigration = migrations.Migration{
+	Name: "01_create_server",
+	Operations: migrations.Operations{
+		&migrations.OpRawSQL{
+			Up: `CREATE EXTENSION IF NOT EXISTS postgres_fdw;
+				CREATE SERVER IF NOT EXISTS remote FOREIGN DATA WRAPPER postgres_fdw OPTIONS (host 'localhost', dbname 'remote')`,
+		},
+	},
+}
+
+func TestCreateForeignTable(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create foreign table",
+			migrations: []migrations.Migration{
+				createServerMigration,
+				{
+					Name: "02_create_foreign_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateForeignTable{
+							Name:   "remote_users",
+							Server: "remote",
+							Columns: []migrations.ForeignTableColumn{
+								{Name: "id", Type: "integer"},
+								{Name: "name", Type: "text", Nullable: true},
+							},
+							Options: migrations.OpCreateForeignTableOptions{
+								"schema_name": "public",
+								"table_name":  "users",
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The foreign table has been created in the physical schema
+				ForeignTableMustExist(t, db, schema, "remote_users")
+				ColumnMustHaveType(t, db, schema, "remote_users", "id", "integer")
+				ColumnMustHaveType(t, db, schema, "remote_users", "name", "text")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The foreign table has been dropped
+				ForeignTableMustNotExist(t, db, schema, "remote_users")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ForeignTableMustExist(t, db, schema, "remote_users")
+			},
+		},
+	})
+}
+
+func TestCreateForeignTableValidation(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "server is required",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_foreign_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateForeignTable{
+							Name:    "remote_users",
+							Columns: []migrations.ForeignTableColumn{{Name: "id", Type: "integer"}},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "server"},
+		},
+		{
+			name: "columns are required",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_foreign_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateForeignTable{
+							Name:   "remote_users",
+							Server: "remote",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "columns"},
+		},
+		{
+			name: "name must not clash with an existing table",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name:    "users",
+							Columns: []migrations.Column{{Name: "id", Type: "serial", Pk: true}},
+						},
+					},
+				},
+				{
+					Name: "02_create_foreign_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateForeignTable{
+							Name:    "users",
+							Server:  "remote",
+							Columns: []migrations.ForeignTableColumn{{Name: "id", Type: "integer"}},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableAlreadyExistsError{Name: "users"},
+		},
+	})
+}
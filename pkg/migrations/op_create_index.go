@@ -5,16 +5,20 @@ package migrations
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/lib/pq"
+	pgq "github.com/xataio/pg_query_go/v6"
 
 	"github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/schema"
 )
 
 var (
-	_ Operation  = (*OpCreateIndex)(nil)
-	_ Createable = (*OpCreateIndex)(nil)
+	_ Operation                 = (*OpCreateIndex)(nil)
+	_ Createable                = (*OpCreateIndex)(nil)
+	_ NonTransactionalOperation = (*OpCreateIndex)(nil)
 )
 
 func (o *OpCreateIndex) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
@@ -25,40 +29,53 @@ func (o *OpCreateIndex) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 		return nil, TableDoesNotExistError{Name: o.Table}
 	}
 
-	cols := make(map[string]IndexField, len(o.Columns))
+	elems := make(map[string]IndexField, len(o.Columns))
 	for name, settings := range map[string]IndexField(o.Columns) {
+		isExpr, err := isIndexExpression(table, name)
+		if err != nil {
+			return nil, InvalidIndexExpressionError{Table: o.Table, Name: o.Name, Expression: name}
+		}
+		if isExpr {
+			elems["("+name+")"] = settings
+			continue
+		}
 		physicalName := table.PhysicalColumnNamesFor(name)
-		cols[physicalName[0]] = settings
-	}
-
-	dbActions := []DBAction{
-		NewCreateIndexConcurrentlyAction(
-			conn,
-			table.Name,
-			o.Name,
-			string(o.Method),
-			o.Unique,
-			cols,
-			o.StorageParameters,
-			o.Predicate,
-		),
+		elems[pq.QuoteIdentifier(physicalName[0])] = settings
 	}
 
-	return &StartResult{Actions: dbActions}, nil
+	action := NewCreateIndexConcurrentlyAction(
+		conn,
+		table.Name,
+		o.Name,
+		string(o.Method),
+		o.Unique,
+		elems,
+		o.StorageParameters,
+		o.Predicate,
+	)
+	// Postgres can't build the index of a partitioned table concurrently
+	action.concurrently = !table.IsPartitioned()
+
+	return &StartResult{Actions: []DBAction{action}}, nil
 }
 
-func (o *OpCreateIndex) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpCreateIndex) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	// No-op
 	return nil, nil
 }
 
-func (o *OpCreateIndex) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpCreateIndex) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
-	// drop the index concurrently
-	return []DBAction{NewDropIndexAction(conn, o.Name)}, nil
+	// drop the index concurrently, unless it is the index of a partitioned
+	// table
+	action := NewDropIndexAction(conn, o.Name)
+	if table := s.GetTable(o.Table); table != nil && table.IsPartitioned() {
+		action.concurrently = false
+	}
+	return []DBAction{action}, nil
 }
 
 func (o *OpCreateIndex) Validate(ctx context.Context, s *schema.Schema) error {
@@ -75,12 +92,22 @@ func (o *OpCreateIndex) Validate(ctx context.Context, s *schema.Schema) error {
 		return TableDoesNotExistError{Name: o.Table}
 	}
 
+	// Each entry is either the name of a column or an expression
 	for column := range map[string]IndexField(o.Columns) {
-		if table.GetColumn(column) == nil {
+		isExpr, err := isIndexExpression(table, column)
+		if err != nil {
+			return InvalidIndexExpressionError{Table: o.Table, Name: o.Name, Expression: column}
+		}
+		if !isExpr && table.GetColumn(column) == nil {
 			return ColumnDoesNotExistError{Table: o.Table, Name: column}
 		}
 	}
 
+	// The unique indexes of a partitioned table must include its partition key
+	if o.Unique && !table.IncludesPartitionKey(slices.Collect(maps.Keys(o.Columns))) {
+		return PartitionKeyNotIncludedError{Table: o.Table, Constraint: fmt.Sprintf("unique index %q", o.Name)}
+	}
+
 	// Index names must be unique across the entire schema.
 	for _, table := range s.Tables {
 		_, ok := table.Indexes[o.Name]
@@ -92,6 +119,54 @@ func (o *OpCreateIndex) Validate(ctx context.Context, s *schema.Schema) error {
 	return nil
 }
 
+// isIndexExpression returns true if the entry in an index's columns is an
+// expression, such as `lower(email)`, rather than the name of a column. Entries
+// that are not the name of a column of the table must parse as a single SQL
+// expression; an entry that parses as a plain column reference is a column name.
+func isIndexExpression(table *schema.Table, entry string) (bool, error) {
+	if table.GetColumn(entry) != nil {
+		return false, nil
+	}
+
+	tree, err := pgq.Parse("SELECT " + entry)
+	if err != nil {
+		return false, err
+	}
+	if len(tree.GetStmts()) != 1 {
+		return false, fmt.Errorf("not a single expression")
+	}
+	stmt := tree.GetStmts()[0].GetStmt().GetSelectStmt()
+	if stmt == nil || len(stmt.GetTargetList()) != 1 {
+		return false, fmt.Errorf("not a single expression")
+	}
+	target := stmt.GetTargetList()[0].GetResTarget()
+	if target == nil || target.GetName() != "" {
+		return false, fmt.Errorf("not a single expression")
+	}
+
+	// Reject anything other than the expression itself, such as a FROM clause,
+	// by comparing the statement with one that contains only the expression.
+	exprOnly := &pgq.ParseResult{Stmts: []*pgq.RawStmt{{
+		Stmt: &pgq.Node{Node: &pgq.Node_SelectStmt{SelectStmt: &pgq.SelectStmt{
+			TargetList: stmt.GetTargetList(),
+			Op:         pgq.SetOperation_SETOP_NONE,
+		}}},
+	}}}
+	got, err := pgq.Deparse(tree)
+	if err != nil {
+		return false, err
+	}
+	want, err := pgq.Deparse(exprOnly)
+	if err != nil {
+		return false, err
+	}
+	if got != want {
+		return false, fmt.Errorf("not a single expression")
+	}
+
+	return target.GetVal().GetColumnRef() == nil, nil
+}
+
 func quoteColumnNames(columns []string) (quoted []string) {
 	for _, col := range columns {
 		quoted = append(quoted, pq.QuoteIdentifier(col))
@@ -118,3 +193,7 @@ func ParseCreateIndexMethod(method string) (OpCreateIndexMethod, error) {
 		return OpCreateIndexMethodBtree, fmt.Errorf("unknown method: %s", method)
 	}
 }
+
+// NonTransactional allows create_index in non-transactional migrations, as the
+// index is built with CREATE INDEX CONCURRENTLY.
+func (o *OpCreateIndex) NonTransactional() {}
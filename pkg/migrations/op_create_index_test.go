@@ -3,11 +3,16 @@
 package migrations_test
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/migrations"
 )
 
@@ -366,6 +371,152 @@ func TestCreateIndexOnMultipleColumns(t *testing.T) {
 	}})
 }
 
+func TestCreateIndexOnExpressions(t *testing.T) {
+	t.Parallel()
+
+	createTable := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "email",
+						Type: "varchar(255)",
+					},
+					{
+						Name:     "data",
+						Type:     "jsonb",
+						Nullable: true,
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create index on a function call expression",
+			migrations: []migrations.Migration{
+				createTable,
+				{
+					Name: "02_create_index",
+					Operations: migrations.Operations{
+						&migrations.OpCreateIndex{
+							Name:    "idx_users_email_lower",
+							Table:   "users",
+							Columns: map[string]migrations.IndexField{"lower(email)": {}},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The index has been created on the underlying table.
+				IndexMustExist(t, db, schema, "users", "idx_users_email_lower")
+				CheckIndexDefinition(t, db, schema, "users", "idx_users_email_lower", fmt.Sprintf("CREATE INDEX idx_users_email_lower ON %s.users USING btree (lower((email)::text))", schema))
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The index has been dropped from the the underlying table.
+				IndexMustNotExist(t, db, schema, "users", "idx_users_email_lower")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// Complete is a no-op.
+			},
+		},
+		{
+			name: "create index on a json path expression",
+			migrations: []migrations.Migration{
+				createTable,
+				{
+					Name: "02_create_index",
+					Operations: migrations.Operations{
+						&migrations.OpCreateIndex{
+							Name:    "idx_users_data_key",
+							Table:   "users",
+							Columns: map[string]migrations.IndexField{"(data->>'key')": {Sort: migrations.IndexFieldSortDESC}},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The index has been created on the underlying table.
+				IndexMustExist(t, db, schema, "users", "idx_users_data_key")
+				CheckIndexDefinition(t, db, schema, "users", "idx_users_data_key", fmt.Sprintf("CREATE INDEX idx_users_data_key ON %s.users USING btree (((data ->> 'key'::text)) DESC)", schema))
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The index has been dropped from the the underlying table.
+				IndexMustNotExist(t, db, schema, "users", "idx_users_data_key")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// Complete is a no-op.
+			},
+		},
+		{
+			name: "an expression index can be dropped",
+			migrations: []migrations.Migration{
+				createTable,
+				{
+					Name: "02_create_index",
+					Operations: migrations.Operations{
+						&migrations.OpCreateIndex{
+							Name:    "idx_users_email_lower",
+							Table:   "users",
+							Columns: map[string]migrations.IndexField{"lower(email)": {}},
+						},
+					},
+				},
+				{
+					Name: "03_drop_index",
+					Operations: migrations.Operations{
+						&migrations.OpDropIndex{
+							Name: "idx_users_email_lower",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The index is only dropped on completion.
+				IndexMustExist(t, db, schema, "users", "idx_users_email_lower")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				IndexMustExist(t, db, schema, "users", "idx_users_email_lower")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				IndexMustNotExist(t, db, schema, "users", "idx_users_email_lower")
+			},
+		},
+		{
+			name: "an invalid expression is rejected",
+			migrations: []migrations.Migration{
+				createTable,
+				{
+					Name: "02_create_index",
+					Operations: migrations.Operations{
+						&migrations.OpCreateIndex{
+							Name:    "idx_users_email_lower",
+							Table:   "users",
+							Columns: map[string]migrations.IndexField{"lower(email) FROM users": {}},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidIndexExpressionError{
+				Table:      "users",
+				Name:       "idx_users_email_lower",
+				Expression: "lower(email) FROM users",
+			},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+	})
+}
+
 func TestCreateIndexInMultiOperationMigrations(t *testing.T) {
 	t.Parallel()
 
@@ -577,3 +728,32 @@ func TestCreateIndexInMultiOperationMigrations(t *testing.T) {
 		},
 	})
 }
+
+func TestCreateIndexConcurrentlyDropsInvalidIndexOnFailure(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithConnectionToContainer(t, func(conn *sql.DB, _ string) {
+		ctx := context.Background()
+
+		_, err := conn.ExecContext(ctx, `CREATE TABLE users (id integer, name text);
+			INSERT INTO users VALUES (1, 'alice'), (2, 'alice')`)
+		require.NoError(t, err)
+
+		action := migrations.NewCreateIndexConcurrentlyAction(&db.RDB{DB: conn}, "users", "idx_users_name", "", true,
+			map[string]migrations.IndexField{`"name"`: {}}, "", "")
+
+		// The unique index can't be built over the duplicate names
+		require.Error(t, action.Execute(ctx))
+
+		// The INVALID index left by the failed build has been dropped
+		var exists bool
+		err = conn.QueryRowContext(ctx, "SELECT to_regclass('idx_users_name') IS NOT NULL").Scan(&exists)
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		// So the build can be retried once the duplicates are removed
+		_, err = conn.ExecContext(ctx, "DELETE FROM users WHERE id = 2")
+		require.NoError(t, err)
+		require.NoError(t, action.Execute(ctx))
+	})
+}
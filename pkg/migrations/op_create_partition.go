@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation      = (*OpCreatePartition)(nil)
+	_ Createable     = (*OpCreatePartition)(nil)
+	_ OwnedOperation = (*OpCreatePartition)(nil)
+)
+
+func (o *OpCreatePartition) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	addPartitionToSchema(s, table, o.Name)
+
+	return &StartResult{Actions: []DBAction{
+		NewCreatePartitionAction(conn, o.Name, table.Name, o.Bound, o.Default),
+	}}, nil
+}
+
+func (o *OpCreatePartition) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpCreatePartition) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	return []DBAction{NewDropTableAction(conn, o.Name)}, nil
+}
+
+// OwnedObjects returns the partition created by the operation.
+func (o *OpCreatePartition) OwnedObjects() []OwnedObject {
+	return []OwnedObject{{Type: "TABLE", Name: o.Name}}
+}
+
+func (o *OpCreatePartition) Validate(ctx context.Context, s *schema.Schema) error {
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+	if !table.IsPartitioned() {
+		return TableIsNotPartitionedError{Table: o.Table}
+	}
+
+	err := validatePartitions(s, o.Table, []Partition{{
+		Name:    o.Name,
+		Bound:   o.Bound,
+		Default: o.Default,
+	}})
+	if err != nil {
+		return err
+	}
+
+	addPartitionToSchema(s, table, o.Name)
+	return nil
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestCreatePartition(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_create_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "orders",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "region", Type: "text"},
+				},
+				PartitionBy: &migrations.PartitionBy{
+					Type:    migrations.PartitionByTypeList,
+					Columns: []string{"region"},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create a partition of a partitioned table",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_create_partition",
+					Operations: migrations.Operations{
+						&migrations.OpCreatePartition{
+							Table: "orders",
+							Name:  "orders_eu",
+							Bound: "IN ('eu')",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "orders_eu", "orders")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotExist(t, db, schema, "orders_eu")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "orders_eu", "orders")
+			},
+		},
+		{
+			name: "create the default partition of a partitioned table",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_create_partition",
+					Operations: migrations.Operations{
+						&migrations.OpCreatePartition{
+							Table:   "orders",
+							Name:    "orders_other",
+							Default: true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "orders_other", "orders")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotExist(t, db, schema, "orders_other")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "orders_other", "orders")
+			},
+		},
+	})
+}
+
+func TestCreatePartitionValidation(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "orders",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "region", Type: "text"},
+				},
+				PartitionBy: &migrations.PartitionBy{
+					Type:    migrations.PartitionByTypeList,
+					Columns: []string{"region"},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "table must exist",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_partition",
+					Operations: migrations.Operations{
+						&migrations.OpCreatePartition{Table: "doesntexist", Name: "orders_eu", Bound: "IN ('eu')"},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "doesntexist"},
+		},
+		{
+			name: "table must be partitioned",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_partition",
+					Operations: migrations.Operations{
+						&migrations.OpCreatePartition{Table: "users", Name: "users_1", Bound: "IN (1)"},
+					},
+				},
+			},
+			wantStartErr: migrations.TableIsNotPartitionedError{Table: "users"},
+		},
+		{
+			name: "partition must not already exist",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_partition",
+					Operations: migrations.Operations{
+						&migrations.OpCreatePartition{Table: "orders", Name: "users", Bound: "IN ('eu')"},
+					},
+				},
+			},
+			wantStartErr: migrations.TableAlreadyExistsError{Name: "users"},
+		},
+		{
+			name: "partition can't have both a bound and be the default partition",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_partition",
+					Operations: migrations.Operations{
+						&migrations.OpCreatePartition{Table: "orders", Name: "orders_eu", Bound: "IN ('eu')", Default: true},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidPartitionBoundError{Table: "orders", Partition: "orders_eu"},
+		},
+	})
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"slices"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation       = (*OpCreateRule)(nil)
+	_ Createable      = (*OpCreateRule)(nil)
+	_ LegacyOperation = (*OpCreateRule)(nil)
+)
+
+func (o *OpCreateRule) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	return &StartResult{Actions: []DBAction{
+		NewCreateRuleAction(conn, table.Name, o.Name, string(o.Event), o.Where, o.Instead, o.Command),
+	}}, nil
+}
+
+func (o *OpCreateRule) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpCreateRule) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, nil
+	}
+
+	return []DBAction{NewDropRuleAction(conn, table.Name, o.Name)}, nil
+}
+
+func (o *OpCreateRule) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	if err := ValidateIdentifierLength(o.Name); err != nil {
+		return err
+	}
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+
+	if table.GetRule(o.Name) != nil {
+		return RuleAlreadyExistsError{Table: o.Table, Name: o.Name}
+	}
+
+	events := []OpCreateRuleEvent{
+		OpCreateRuleEventINSERT,
+		OpCreateRuleEventUPDATE,
+		OpCreateRuleEventDELETE,
+	}
+	if !slices.Contains(events, o.Event) {
+		return InvalidRuleEventError{Name: o.Name, Event: string(o.Event)}
+	}
+
+	if o.Command == "" {
+		return FieldRequiredError{Name: "command"}
+	}
+
+	return nil
+}
+
+func (o *OpCreateRule) Legacy() string {
+	return "rules are a legacy Postgres feature; prefer triggers"
+}
@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestCreateRule(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_add_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "varchar(255)",
+					},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "audit",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "varchar(255)",
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create a rule that runs instead of the original statement",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_rule",
+					Operations: migrations.Operations{
+						&migrations.OpCreateRule{
+							Table:   "users",
+							Name:    "protect_users",
+							Event:   migrations.OpCreateRuleEventDELETE,
+							Instead: true,
+							Command: "NOTHING",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				RuleMustExist(t, db, schema, "users", "protect_users")
+
+				// Deletes are ignored
+				MustInsert(t, db, schema, "02_create_rule", "users", map[string]string{
+					"name": "alice",
+				})
+				MustDelete(t, db, schema, "02_create_rule", "users", map[string]string{
+					"name": "alice",
+				})
+				rows := MustSelect(t, db, schema, "02_create_rule", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "alice"},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				RuleMustNotExist(t, db, schema, "users", "protect_users")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				RuleMustExist(t, db, schema, "users", "protect_users")
+			},
+		},
+		{
+			name: "create a conditional rule that runs in addition to the original statement",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_create_rule",
+					Operations: migrations.Operations{
+						&migrations.OpCreateRule{
+							Table:   "users",
+							Name:    "audit_admins",
+							Event:   migrations.OpCreateRuleEventINSERT,
+							Where:   "new.name = 'admin'",
+							Command: "INSERT INTO audit (name) VALUES (new.name); INSERT INTO audit (name) VALUES ('done')",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				MustInsert(t, db, schema, "02_create_rule", "users", map[string]string{
+					"name": "alice",
+				})
+				MustInsert(t, db, schema, "02_create_rule", "users", map[string]string{
+					"name": "admin",
+				})
+
+				// Both users are inserted, and only the admin is audited
+				users := MustSelect(t, db, schema, "02_create_rule", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "alice"},
+					{"id": 2, "name": "admin"},
+				}, users)
+				audit := MustSelect(t, db, schema, "02_create_rule", "audit")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "admin"},
+					{"id": 2, "name": "done"},
+				}, audit)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				RuleMustNotExist(t, db, schema, "users", "audit_admins")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				RuleMustExist(t, db, schema, "users", "audit_admins")
+			},
+		},
+	})
+}
+
+func TestCreateRuleValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+				},
+			},
+		},
+	}
+
+	createRuleMigration := migrations.Migration{
+		Name: "02_create_rule",
+		Operations: migrations.Operations{
+			&migrations.OpCreateRule{
+				Table:   "users",
+				Name:    "protect_users",
+				Event:   migrations.OpCreateRuleEventDELETE,
+				Instead: true,
+				Command: "NOTHING",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "table must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_create_rule",
+					Operations: migrations.Operations{
+						&migrations.OpCreateRule{
+							Table:   "doesntexist",
+							Name:    "protect_users",
+							Event:   migrations.OpCreateRuleEventDELETE,
+							Command: "NOTHING",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "doesntexist"},
+		},
+		{
+			name: "rule must not already exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createRuleMigration,
+				{
+					Name: "03_create_rule",
+					Operations: migrations.Operations{
+						&migrations.OpCreateRule{
+							Table:   "users",
+							Name:    "protect_users",
+							Event:   migrations.OpCreateRuleEventUPDATE,
+							Command: "NOTHING",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.RuleAlreadyExistsError{Table: "users", Name: "protect_users"},
+		},
+		{
+			name: "event must be valid",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_create_rule",
+					Operations: migrations.Operations{
+						&migrations.OpCreateRule{
+							Table:   "users",
+							Name:    "protect_users",
+							Event:   "SELECT",
+							Command: "NOTHING",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidRuleEventError{Name: "protect_users", Event: "SELECT"},
+		},
+		{
+			name: "command is required",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_create_rule",
+					Operations: migrations.Operations{
+						&migrations.OpCreateRule{
+							Table: "users",
+							Name:  "protect_users",
+							Event: migrations.OpCreateRuleEventDELETE,
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "command"},
+		},
+	})
+}
@@ -13,8 +13,9 @@ import (
 )
 
 var (
-	_ Operation  = (*OpCreateTable)(nil)
-	_ Createable = (*OpCreateTable)(nil)
+	_ Operation      = (*OpCreateTable)(nil)
+	_ Createable     = (*OpCreateTable)(nil)
+	_ OwnedOperation = (*OpCreateTable)(nil)
 )
 
 func (o *OpCreateTable) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
@@ -30,9 +31,15 @@ func (o *OpCreateTable) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 	if err != nil {
 		return nil, fmt.Errorf("failed to create constraints SQL: %w", err)
 	}
+	constraintsSQL += checksToSQL(o.Checks)
 
 	dbActions := make([]DBAction, 0)
-	dbActions = append(dbActions, NewCreateTableAction(conn, o.Name, columnsSQL, constraintsSQL))
+	dbActions = append(dbActions, NewCreateTableAction(conn, o.Name, columnsSQL, constraintsSQL, partitionByToSQL(o.PartitionBy)))
+
+	// Create the partitions of a partitioned table
+	for _, p := range o.Partitions {
+		dbActions = append(dbActions, NewCreatePartitionAction(conn, p.Name, o.Name, p.Bound, p.Default))
+	}
 
 	// Add comments to any columns that have them
 	for _, col := range o.Columns {
@@ -46,25 +53,46 @@ func (o *OpCreateTable) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 		dbActions = append(dbActions, NewCommentTableAction(conn, o.Name, o.Comment))
 	}
 
+	// Transfer ownership of the table if an owner is specified
+	if o.Owner != "" {
+		dbActions = append(dbActions, NewAlterTableOwnerAction(conn, o.Name, o.Owner))
+		for _, p := range o.Partitions {
+			dbActions = append(dbActions, NewAlterTableOwnerAction(conn, p.Name, o.Owner))
+		}
+	}
+
 	// Update the in-memory schema representation with the new table
 	o.updateSchema(s)
 
 	return &StartResult{Actions: dbActions}, nil
 }
 
-func (o *OpCreateTable) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpCreateTable) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	// No-op
 	return nil, nil
 }
 
-func (o *OpCreateTable) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpCreateTable) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	return []DBAction{NewDropTableAction(conn, o.Name)}, nil
 }
 
+// OwnedObjects returns the table and partitions created by the operation, or
+// none if the operation sets their owner itself.
+func (o *OpCreateTable) OwnedObjects() []OwnedObject {
+	if o.Owner != "" {
+		return nil
+	}
+	objects := []OwnedObject{{Type: "TABLE", Name: o.Name}}
+	for _, p := range o.Partitions {
+		objects = append(objects, OwnedObject{Type: "TABLE", Name: p.Name})
+	}
+	return objects
+}
+
 func (o *OpCreateTable) Validate(ctx context.Context, s *schema.Schema) error {
 	if err := ValidateIdentifierLength(o.Name); err != nil {
 		return err
@@ -75,6 +103,10 @@ func (o *OpCreateTable) Validate(ctx context.Context, s *schema.Schema) error {
 		return TableAlreadyExistsError{Name: o.Name}
 	}
 
+	if err := o.validatePartitioning(s); err != nil {
+		return err
+	}
+
 	hasPrimaryKeyColumns := false
 	for _, col := range o.Columns {
 		if err := ValidateIdentifierLength(col.Name); err != nil {
@@ -86,6 +118,10 @@ func (o *OpCreateTable) Validate(ctx context.Context, s *schema.Schema) error {
 			return ColumnIsInvalidError{Table: o.Name, Name: col.Name}
 		}
 
+		if err := validateTypeName(o.Name, col.Name, col.Type); err != nil {
+			return err
+		}
+
 		// Ensure that any foreign key references are valid, ie. the referenced
 		// table and column exist.
 		if col.References != nil {
@@ -135,7 +171,7 @@ func (o *OpCreateTable) Validate(ctx context.Context, s *schema.Schema) error {
 				return CheckConstraintError{
 					Table: o.Name,
 					Name:  c.Name,
-					Err:   fmt.Errorf("CHECK constraints cannot be marked DEFERRABLE"),
+					Err:   ErrDeferrableCheckConstraint,
 				}
 			}
 			if c.IndexParameters != nil {
@@ -186,6 +222,10 @@ func (o *OpCreateTable) Validate(ctx context.Context, s *schema.Schema) error {
 		}
 	}
 
+	if err := o.validateChecks(); err != nil {
+		return err
+	}
+
 	// Update the schema to ensure that the new table is visible to validation of
 	// subsequent operations.
 	o.updateSchema(s)
@@ -193,6 +233,145 @@ func (o *OpCreateTable) Validate(ctx context.Context, s *schema.Schema) error {
 	return nil
 }
 
+// validateChecks checks that each table check constraint has a unique name
+// and an expression that references only columns of the table.
+func (o *OpCreateTable) validateChecks() error {
+	names := make(map[string]bool, len(o.Constraints)+len(o.Checks))
+	for _, c := range o.Constraints {
+		names[c.Name] = true
+	}
+
+	for i, c := range o.Checks {
+		if c.Name == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("checks[%d].name", i)}
+		}
+		if err := ValidateIdentifierLength(c.Name); err != nil {
+			return fmt.Errorf("invalid check constraint: %w", err)
+		}
+		if c.Expression == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("checks[%d].expression", i)}
+		}
+		if names[c.Name] {
+			return ConstraintAlreadyExistsError{Table: o.Name, Constraint: c.Name}
+		}
+		names[c.Name] = true
+
+		columns, err := checkExpressionColumns(c.Expression)
+		if err != nil {
+			return CheckConstraintError{Table: o.Name, Name: c.Name, Err: err}
+		}
+		for _, name := range columns {
+			if !slices.ContainsFunc(o.Columns, func(col Column) bool { return col.Name == name }) {
+				return ColumnDoesNotExistError{Table: o.Name, Name: name}
+			}
+		}
+	}
+	return nil
+}
+
+// validatePartitioning checks the partition key and partitions of the table.
+// As in Postgres, the primary key and unique constraints of a partitioned
+// table must include all the columns of its partition key.
+func (o *OpCreateTable) validatePartitioning(s *schema.Schema) error {
+	if o.PartitionBy == nil {
+		if len(o.Partitions) > 0 {
+			return TableIsNotPartitionedError{Table: o.Name}
+		}
+		return nil
+	}
+
+	switch o.PartitionBy.Type {
+	case PartitionByTypeRange, PartitionByTypeList, PartitionByTypeHash:
+	default:
+		return InvalidPartitionByError{Table: o.Name, Reason: fmt.Sprintf("unknown partitioning type %q", o.PartitionBy.Type)}
+	}
+
+	hasColumns := len(o.PartitionBy.Columns) > 0
+	hasExpression := o.PartitionBy.Expression != ""
+	if hasColumns == hasExpression {
+		return InvalidPartitionByError{Table: o.Name, Reason: "exactly one of columns or expression must be set"}
+	}
+	if o.PartitionBy.Type == PartitionByTypeList && len(o.PartitionBy.Columns) > 1 {
+		return InvalidPartitionByError{Table: o.Name, Reason: "list partitioning takes a single column"}
+	}
+	for _, name := range o.PartitionBy.Columns {
+		if !slices.ContainsFunc(o.Columns, func(c Column) bool { return c.Name == name }) {
+			return ColumnDoesNotExistError{Table: o.Name, Name: name}
+		}
+	}
+
+	key := &schema.Table{PartitionBy: o.partitionKey()}
+	var primaryKey []string
+	for _, col := range o.Columns {
+		if col.Pk {
+			primaryKey = append(primaryKey, col.Name)
+		}
+		if col.Unique && !key.IncludesPartitionKey([]string{col.Name}) {
+			return PartitionKeyNotIncludedError{Table: o.Name, Constraint: fmt.Sprintf("unique constraint on column %q", col.Name)}
+		}
+	}
+	if len(primaryKey) > 0 && !key.IncludesPartitionKey(primaryKey) {
+		return PartitionKeyNotIncludedError{Table: o.Name, Constraint: "primary key"}
+	}
+	for _, c := range o.Constraints {
+		switch c.Type {
+		case ConstraintTypePrimaryKey:
+			if !key.IncludesPartitionKey(c.Columns) {
+				return PartitionKeyNotIncludedError{Table: o.Name, Constraint: "primary key"}
+			}
+		case ConstraintTypeUnique:
+			if !key.IncludesPartitionKey(c.Columns) {
+				return PartitionKeyNotIncludedError{Table: o.Name, Constraint: fmt.Sprintf("unique constraint %q", c.Name)}
+			}
+		}
+	}
+
+	return validatePartitions(s, o.Name, o.Partitions)
+}
+
+// validatePartitions checks the partitions to create for the partitioned
+// table.
+func validatePartitions(s *schema.Schema, table string, partitions []Partition) error {
+	seen := make(map[string]bool, len(partitions))
+	hasDefault := false
+	for _, p := range partitions {
+		if p.Name == "" {
+			return FieldRequiredError{Name: "name"}
+		}
+		if err := ValidateIdentifierLength(p.Name); err != nil {
+			return err
+		}
+		if seen[p.Name] || p.Name == table || s.GetTable(p.Name) != nil {
+			return TableAlreadyExistsError{Name: p.Name}
+		}
+		seen[p.Name] = true
+
+		if (p.Bound == "") != p.Default {
+			return InvalidPartitionBoundError{Table: table, Partition: p.Name}
+		}
+		if p.Default {
+			if hasDefault {
+				return InvalidMigrationError{Reason: fmt.Sprintf("table %q can have only one default partition", table)}
+			}
+			hasDefault = true
+		}
+	}
+	return nil
+}
+
+// partitionKey returns the partition key recorded in the schema for the
+// table, if it is partitioned.
+func (o *OpCreateTable) partitionKey() *schema.PartitionKey {
+	if o.PartitionBy == nil {
+		return nil
+	}
+	return &schema.PartitionKey{
+		Type:       string(o.PartitionBy.Type),
+		Columns:    o.PartitionBy.Columns,
+		Expression: o.PartitionBy.Expression,
+	}
+}
+
 // updateSchema updates the in-memory schema representation with the details of
 // the new table.
 func (o *OpCreateTable) updateSchema(s *schema.Schema) *schema.Schema {
@@ -212,6 +391,9 @@ func (o *OpCreateTable) updateSchema(s *schema.Schema) *schema.Schema {
 		if col.Comment != nil {
 			columns[col.Name].Comment = *col.Comment
 		}
+		if col.Generated != nil && col.Generated.Expression != "" {
+			columns[col.Name].Generated = &col.Generated.Expression
+		}
 	}
 
 	uniqueConstraints := make(map[string]*schema.UniqueConstraint, 0)
@@ -253,6 +435,16 @@ func (o *OpCreateTable) updateSchema(s *schema.Schema) *schema.Schema {
 		}
 	}
 
+	for _, c := range o.Checks {
+		// The expression has been validated, so its columns can be found
+		checkColumns, _ := checkExpressionColumns(c.Expression)
+		checkConstraints[c.Name] = &schema.CheckConstraint{
+			Name:       c.Name,
+			Columns:    checkColumns,
+			Definition: c.Expression,
+		}
+	}
+
 	s.AddTable(o.Name, &schema.Table{
 		Name:               o.Name,
 		Columns:            columns,
@@ -261,11 +453,51 @@ func (o *OpCreateTable) updateSchema(s *schema.Schema) *schema.Schema {
 		PrimaryKey:         primaryKeys,
 		ForeignKeys:        foreignKeys,
 		ExcludeConstraints: excludeConstraints,
+		PartitionBy:        o.partitionKey(),
 	})
 
+	for _, p := range o.Partitions {
+		addPartitionToSchema(s, s.GetTable(o.Name), p.Name)
+	}
+
 	return s
 }
 
+// addPartitionToSchema adds a partition of the given partitioned table to the
+// in-memory schema. The partition has the columns and primary key of the
+// partitioned table.
+func addPartitionToSchema(s *schema.Schema, parent *schema.Table, name string) {
+	columns := make(map[string]*schema.Column, len(parent.Columns))
+	for colName, col := range parent.Columns {
+		c := *col
+		columns[colName] = &c
+	}
+
+	s.AddTable(name, &schema.Table{
+		Name:               name,
+		Columns:            columns,
+		UniqueConstraints:  make(map[string]*schema.UniqueConstraint),
+		CheckConstraints:   make(map[string]*schema.CheckConstraint),
+		PrimaryKey:         slices.Clone(parent.PrimaryKey),
+		ForeignKeys:        make(map[string]*schema.ForeignKey),
+		ExcludeConstraints: make(map[string]*schema.ExcludeConstraint),
+		PartitionOf:        parent.Name,
+	})
+}
+
+// partitionByToSQL returns the SQL of the partition key of a partitioned
+// table, or an empty string if the table isn't partitioned.
+func partitionByToSQL(p *PartitionBy) string {
+	if p == nil {
+		return ""
+	}
+	key := "(" + p.Expression + ")"
+	if len(p.Columns) > 0 {
+		key = strings.Join(quoteColumnNames(p.Columns), ", ")
+	}
+	return fmt.Sprintf("%s (%s)", strings.ToUpper(string(p.Type)), key)
+}
+
 func columnsToSQL(cols []Column) (string, error) {
 	var sql string
 	var primaryKeys []string
@@ -298,6 +530,17 @@ func columnsToSQL(cols []Column) (string, error) {
 	return sql, nil
 }
 
+// checksToSQL returns the SQL of the table check constraints, to follow the
+// other constraints of the table.
+func checksToSQL(checks []TableCheck) string {
+	var sql string
+	for _, c := range checks {
+		writer := &ConstraintSQLWriter{Name: c.Name}
+		sql += ", " + writer.WriteCheck(c.Expression, false)
+	}
+	return sql
+}
+
 func constraintsToSQL(constraints []Constraint) (string, error) {
 	constraintsSQL := make([]string, len(constraints))
 	for i, c := range constraints {
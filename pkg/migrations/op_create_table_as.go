@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation      = (*OpCreateTableAs)(nil)
+	_ Createable     = (*OpCreateTableAs)(nil)
+	_ OwnedOperation = (*OpCreateTableAs)(nil)
+)
+
+func (o *OpCreateTableAs) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	// Add the table to the in-memory schema. Its columns are only known once
+	// the table has been created, so the create action fills them in.
+	s.AddTable(o.Name, &schema.Table{
+		Name:    o.Name,
+		Columns: make(map[string]*schema.Column),
+	})
+
+	return &StartResult{Actions: []DBAction{
+		NewCreateTableAsAction(conn, o.Name, o.Query, o.WithNoData, s),
+	}}, nil
+}
+
+func (o *OpCreateTableAs) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpCreateTableAs) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	return []DBAction{NewDropTableAction(conn, o.Name)}, nil
+}
+
+// OwnedObjects returns the table created by the operation.
+func (o *OpCreateTableAs) OwnedObjects() []OwnedObject {
+	return []OwnedObject{{Type: "TABLE", Name: o.Name}}
+}
+
+func (o *OpCreateTableAs) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	if err := ValidateIdentifierLength(o.Name); err != nil {
+		return err
+	}
+
+	if s.GetTable(o.Name) != nil {
+		return TableAlreadyExistsError{Name: o.Name}
+	}
+
+	if o.Query == "" {
+		return FieldRequiredError{Name: "query"}
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+var createUsersMigration = migrations.Migration{
+	Name: "01_create_table",
+	Operations: migrations.Operations{
+		&migrations.OpCreateTable{
+			Name: "users",
+			Columns: []migrations.Column{
+				{Name: "id", Type: "serial", Pk: true},
+				{Name: "name", Type: "varchar(255)"},
+			},
+		},
+	},
+}
+
+func TestCreateTableAs(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create table as",
+			migrations: []migrations.Migration{
+				createUsersMigration,
+				{
+					Name: "02_create_table_as",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTableAs{
+							Name:  "user_names",
+							Query: "SELECT id AS user_id, upper(name) AS upper_name FROM users",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The table has been created with columns inferred from the query
+				TableMustExist(t, db, schema, "user_names")
+				ColumnMustHaveType(t, db, schema, "user_names", "user_id", "integer")
+				ColumnMustHaveType(t, db, schema, "user_names", "upper_name", "text")
+
+				// The table is exposed through the new version schema
+				ViewMustExist(t, db, schema, "02_create_table_as", "user_names")
+				MustInsert(t, db, schema, "02_create_table_as", "user_names", map[string]string{
+					"user_id":    "1",
+					"upper_name": "ALICE",
+				})
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The table has been dropped
+				TableMustNotExist(t, db, schema, "user_names")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustExist(t, db, schema, "user_names")
+				ViewMustExist(t, db, schema, "02_create_table_as", "user_names")
+			},
+		},
+		{
+			name: "create table as copies the rows returned by the query",
+			migrations: []migrations.Migration{
+				createUsersMigration,
+				{
+					Name: "02_insert_users",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "INSERT INTO users (name) VALUES ('alice'), ('bob')",
+						},
+					},
+				},
+				{
+					Name: "03_create_table_as",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTableAs{
+							Name:  "user_names",
+							Query: "SELECT upper(name) AS upper_name FROM users",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				rows := MustSelect(t, db, schema, "03_create_table_as", "user_names")
+				assert.ElementsMatch(t, []map[string]any{
+					{"upper_name": "ALICE"},
+					{"upper_name": "BOB"},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotExist(t, db, schema, "user_names")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				rows := MustSelect(t, db, schema, "03_create_table_as", "user_names")
+				assert.Len(t, rows, 2)
+			},
+		},
+		{
+			name: "create table as with no data",
+			migrations: []migrations.Migration{
+				createUsersMigration,
+				{
+					Name: "02_insert_users",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "INSERT INTO users (name) VALUES ('alice'), ('bob')",
+						},
+					},
+				},
+				{
+					Name: "03_create_table_as",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTableAs{
+							Name:       "user_names",
+							Query:      "SELECT name FROM users",
+							WithNoData: true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustHaveType(t, db, schema, "user_names", "name", "character varying(255)")
+				rows := MustSelect(t, db, schema, "03_create_table_as", "user_names")
+				assert.Empty(t, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotExist(t, db, schema, "user_names")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				rows := MustSelect(t, db, schema, "03_create_table_as", "user_names")
+				assert.Empty(t, rows)
+			},
+		},
+	})
+}
+
+func TestCreateTableAsValidation(t *testing.T) {
+	t.Parallel()
+
+	invalidName := strings.Repeat("x", 64)
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "query is required",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table_as",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTableAs{
+							Name: "user_names",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "query"},
+		},
+		{
+			name: "table must not already exist",
+			migrations: []migrations.Migration{
+				createUsersMigration,
+				{
+					Name: "02_create_table_as",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTableAs{
+							Name:  "users",
+							Query: "SELECT 1 AS one",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableAlreadyExistsError{Name: "users"},
+		},
+		{
+			name: "table name must not be too long",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table_as",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTableAs{
+							Name:  invalidName,
+							Query: "SELECT 1 AS one",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ValidateIdentifierLength(invalidName),
+		},
+	})
+}
@@ -647,6 +647,116 @@ func TestCreateTable(t *testing.T) {
 				}, testutils.CheckViolationErrorCode)
 			},
 		},
+		{
+			name: "create table with multi-column table checks",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "bookings",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "starts_at", Type: "date"},
+								{Name: "ends_at", Type: "date"},
+								{Name: "guests", Type: "integer"},
+								{Name: "rooms", Type: "integer"},
+							},
+							Checks: []migrations.TableCheck{
+								{Name: "check_dates", Expression: "ends_at > starts_at"},
+								{Name: "check_occupancy", Expression: "guests <= rooms * 4"},
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The check constraints exist on the new table.
+				CheckConstraintMustExist(t, db, schema, "bookings", "check_dates")
+				CheckConstraintMustExist(t, db, schema, "bookings", "check_occupancy")
+
+				// Inserting a row that satisfies both check constraints succeeds.
+				MustInsert(t, db, schema, "01_create_table", "bookings", map[string]string{
+					"starts_at": "2024-01-01", "ends_at": "2024-01-03", "guests": "4", "rooms": "1",
+				})
+
+				// Inserting a row that violates either check constraint fails.
+				MustNotInsert(t, db, schema, "01_create_table", "bookings", map[string]string{
+					"starts_at": "2024-01-03", "ends_at": "2024-01-01", "guests": "4", "rooms": "1",
+				}, testutils.CheckViolationErrorCode)
+				MustNotInsert(t, db, schema, "01_create_table", "bookings", map[string]string{
+					"starts_at": "2024-01-01", "ends_at": "2024-01-03", "guests": "5", "rooms": "1",
+				}, testutils.CheckViolationErrorCode)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The table has been dropped, so the check constraints are gone.
+				TableMustNotExist(t, db, schema, "bookings")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The check constraints exist on the new table.
+				CheckConstraintMustExist(t, db, schema, "bookings", "check_dates")
+				CheckConstraintMustExist(t, db, schema, "bookings", "check_occupancy")
+
+				// Inserting a row that violates a check constraint fails.
+				MustNotInsert(t, db, schema, "01_create_table", "bookings", map[string]string{
+					"starts_at": "2024-01-01", "ends_at": "2024-01-01", "guests": "1", "rooms": "1",
+				}, testutils.CheckViolationErrorCode)
+			},
+		},
+		{
+			name: "table checks can be dropped by name",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "bookings",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "starts_at", Type: "date"},
+								{Name: "ends_at", Type: "date"},
+							},
+							Checks: []migrations.TableCheck{
+								{Name: "check_dates", Expression: "ends_at > starts_at"},
+							},
+						},
+					},
+				},
+				{
+					Name: "02_drop_check",
+					Operations: migrations.Operations{
+						&migrations.OpDropMultiColumnConstraint{
+							Table: "bookings",
+							Name:  "check_dates",
+							Up: migrations.MultiColumnUpSQL{
+								"starts_at": "starts_at",
+								"ends_at":   "ends_at",
+							},
+							Down: migrations.MultiColumnDownSQL{
+								"starts_at": "starts_at",
+								"ends_at":   "GREATEST(ends_at, starts_at + 1)",
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Rows that violate the dropped check constraint can be inserted
+				// into the new version of the table.
+				MustInsert(t, db, schema, "02_drop_check", "bookings", map[string]string{
+					"starts_at": "2024-01-03", "ends_at": "2024-01-01",
+				})
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				CheckConstraintMustExist(t, db, schema, "bookings", "check_dates")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				CheckConstraintMustNotExist(t, db, schema, "bookings", "check_dates")
+				MustInsert(t, db, schema, "02_drop_check", "bookings", map[string]string{
+					"starts_at": "2024-01-03", "ends_at": "2024-01-01",
+				})
+			},
+		},
 		{
 			name: "create table with column and table comments",
 			migrations: []migrations.Migration{
@@ -1464,6 +1574,72 @@ func TestCreateTableValidation(t *testing.T) {
 			},
 			wantStartErr: migrations.FieldRequiredError{Name: "check"},
 		},
+		{
+			name: "table check referencing a missing column",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "bookings",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "starts_at", Type: "date"},
+							},
+							Checks: []migrations.TableCheck{
+								{Name: "check_dates", Expression: "ends_at > starts_at"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnDoesNotExistError{Table: "bookings", Name: "ends_at"},
+		},
+		{
+			name: "table check with the name of a constraint",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "bookings",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "starts_at", Type: "date"},
+							},
+							Constraints: []migrations.Constraint{
+								{Name: "check_dates", Type: migrations.ConstraintTypeCheck, Check: "starts_at > '2000-01-01'"},
+							},
+							Checks: []migrations.TableCheck{
+								{Name: "check_dates", Expression: "starts_at < '2100-01-01'"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ConstraintAlreadyExistsError{Table: "bookings", Constraint: "check_dates"},
+		},
+		{
+			name: "table check with more than an expression",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "bookings",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "starts_at", Type: "date"},
+							},
+							Checks: []migrations.TableCheck{
+								{Name: "check_dates", Expression: "starts_at > now() FROM bookings"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.CheckConstraintError{Table: "bookings", Name: "check_dates", Err: migrations.ErrInvalidCheckExpression},
+		},
 		{
 			name: "multiple primary key definitions",
 			migrations: []migrations.Migration{
@@ -1550,3 +1726,227 @@ func TestCreateTableValidationInMultiOperationMigrations(t *testing.T) {
 		},
 	})
 }
+
+func TestCreatePartitionedTable(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create a range partitioned table with partitions",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "measurements",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "integer"},
+								{Name: "taken_at", Type: "date"},
+								{Name: "value", Type: "numeric", Nullable: true},
+							},
+							Constraints: []migrations.Constraint{
+								{Name: "measurements_pk", Type: migrations.ConstraintTypePrimaryKey, Columns: []string{"id", "taken_at"}},
+							},
+							PartitionBy: &migrations.PartitionBy{
+								Type:    migrations.PartitionByTypeRange,
+								Columns: []string{"taken_at"},
+							},
+							Partitions: []migrations.Partition{
+								{Name: "measurements_2024", Bound: "FROM ('2024-01-01') TO ('2025-01-01')"},
+								{Name: "measurements_default", Default: true},
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "measurements_2024", "measurements")
+				TableMustBePartitionOf(t, db, schema, "measurements_default", "measurements")
+
+				// Data can be inserted into the partitioned table
+				MustInsert(t, db, schema, "01_create_table", "measurements", map[string]string{
+					"id":       "1",
+					"taken_at": "2024-06-01",
+				})
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotExist(t, db, schema, "measurements")
+				TableMustNotExist(t, db, schema, "measurements_2024")
+				TableMustNotExist(t, db, schema, "measurements_default")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "measurements_2024", "measurements")
+				TableMustBePartitionOf(t, db, schema, "measurements_default", "measurements")
+			},
+		},
+		{
+			name: "create a hash partitioned table partitioned by an expression",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "users",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "integer"},
+								{Name: "email", Type: "text"},
+							},
+							PartitionBy: &migrations.PartitionBy{
+								Type:       migrations.PartitionByTypeHash,
+								Expression: "lower(email)",
+							},
+							Partitions: []migrations.Partition{
+								{Name: "users_0", Bound: "WITH (MODULUS 2, REMAINDER 0)"},
+								{Name: "users_1", Bound: "WITH (MODULUS 2, REMAINDER 1)"},
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "users_0", "users")
+				TableMustBePartitionOf(t, db, schema, "users_1", "users")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotExist(t, db, schema, "users")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBePartitionOf(t, db, schema, "users_0", "users")
+			},
+		},
+	})
+}
+
+func TestCreatePartitionedTableValidation(t *testing.T) {
+	t.Parallel()
+
+	columns := []migrations.Column{
+		{Name: "id", Type: "integer", Pk: true},
+		{Name: "region", Type: "text"},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "partitions require a partition key",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name:       "orders",
+							Columns:    []migrations.Column{{Name: "id", Type: "integer"}},
+							Partitions: []migrations.Partition{{Name: "orders_eu", Bound: "IN ('eu')"}},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableIsNotPartitionedError{Table: "orders"},
+		},
+		{
+			name: "partition key columns must exist",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name:    "orders",
+							Columns: []migrations.Column{{Name: "id", Type: "integer"}},
+							PartitionBy: &migrations.PartitionBy{
+								Type:    migrations.PartitionByTypeList,
+								Columns: []string{"doesntexist"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnDoesNotExistError{Table: "orders", Name: "doesntexist"},
+		},
+		{
+			name: "partition key must have either columns or an expression",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name:    "orders",
+							Columns: []migrations.Column{{Name: "id", Type: "integer"}},
+							PartitionBy: &migrations.PartitionBy{
+								Type:       migrations.PartitionByTypeHash,
+								Columns:    []string{"id"},
+								Expression: "id % 10",
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidPartitionByError{Table: "orders", Reason: "exactly one of columns or expression must be set"},
+		},
+		{
+			name: "primary key must include the partition key",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name:    "orders",
+							Columns: columns,
+							PartitionBy: &migrations.PartitionBy{
+								Type:    migrations.PartitionByTypeList,
+								Columns: []string{"region"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.PartitionKeyNotIncludedError{Table: "orders", Constraint: "primary key"},
+		},
+		{
+			name: "unique constraints must include the partition key",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "orders",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "integer"},
+								{Name: "region", Type: "text"},
+							},
+							Constraints: []migrations.Constraint{
+								{Name: "orders_id_unique", Type: migrations.ConstraintTypeUnique, Columns: []string{"id"}},
+							},
+							PartitionBy: &migrations.PartitionBy{
+								Type:    migrations.PartitionByTypeList,
+								Columns: []string{"region"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.PartitionKeyNotIncludedError{Table: "orders", Constraint: `unique constraint "orders_id_unique"`},
+		},
+		{
+			name: "partitions must have a bound or be the default partition",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "orders",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "integer"},
+								{Name: "region", Type: "text"},
+							},
+							PartitionBy: &migrations.PartitionBy{
+								Type:    migrations.PartitionByTypeList,
+								Columns: []string{"region"},
+							},
+							Partitions: []migrations.Partition{{Name: "orders_eu"}},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidPartitionBoundError{Table: "orders", Partition: "orders_eu"},
+		},
+	})
+}
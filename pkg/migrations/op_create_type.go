@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation      = (*OpCreateType)(nil)
+	_ Createable     = (*OpCreateType)(nil)
+	_ OwnedOperation = (*OpCreateType)(nil)
+)
+
+func (o *OpCreateType) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	return &StartResult{Actions: []DBAction{NewCreateTypeAction(conn, o.Name, o.Attributes)}}, nil
+}
+
+func (o *OpCreateType) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpCreateType) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	// Drop the type, failing if other objects have been made to depend on it
+	// unless cascade is set
+	return []DBAction{NewDropTypeAction(conn, o.Name, o.Cascade)}, nil
+}
+
+// OwnedObjects returns the type created by the operation.
+func (o *OpCreateType) OwnedObjects() []OwnedObject {
+	return []OwnedObject{{Type: "TYPE", Name: o.Name}}
+}
+
+func (o *OpCreateType) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	if err := ValidateIdentifierLength(o.Name); err != nil {
+		return err
+	}
+
+	if len(o.Attributes) == 0 {
+		return FieldRequiredError{Name: "attributes"}
+	}
+
+	seen := make(map[string]struct{}, len(o.Attributes))
+	for _, attr := range o.Attributes {
+		if attr.Name == "" {
+			return FieldRequiredError{Name: "name"}
+		}
+		if attr.Type == "" {
+			return FieldRequiredError{Name: "type"}
+		}
+		if err := ValidateIdentifierLength(attr.Name); err != nil {
+			return err
+		}
+		if _, ok := seen[attr.Name]; ok {
+			return InvalidMigrationError{Reason: fmt.Sprintf("duplicate attribute %q in type %q", attr.Name, o.Name)}
+		}
+		seen[attr.Name] = struct{}{}
+	}
+
+	return nil
+}
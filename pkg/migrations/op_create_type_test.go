@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestCreateType(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_create_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "text",
+					},
+				},
+			},
+		},
+	}
+
+	addressType := &migrations.OpCreateType{
+		Name: "address",
+		Attributes: []migrations.CompositeTypeAttribute{
+			{Name: "street", Type: "text"},
+			{Name: "city", Type: "text"},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "create type and add a column using it",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_create_type",
+					Operations: migrations.Operations{
+						addressType,
+						&migrations.OpAddColumn{
+							Table: "users",
+							Column: migrations.Column{
+								Name:     "address",
+								Type:     "address",
+								Nullable: true,
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The type has been created.
+				TypeMustExist(t, db, schema, "address")
+
+				// Values of the type can be inserted into the new column.
+				MustInsert(t, db, schema, "02_create_type", "users", map[string]string{
+					"name":    "alice",
+					"address": "(1 Main St,London)",
+				})
+
+				rows := MustSelect(t, db, schema, "02_create_type", "users")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "alice", "address": []byte(`("1 Main St",London)`)},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The type has been dropped.
+				TypeMustNotExist(t, db, schema, "address")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The type and the column using it exist.
+				TypeMustExist(t, db, schema, "address")
+				ColumnMustExist(t, db, schema, "users", "address")
+			},
+		},
+		{
+			name: "rollback fails when other objects depend on the type",
+			migrations: []migrations.Migration{
+				{
+					Name:       "01_create_type",
+					Operations: migrations.Operations{addressType},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Create a function that depends on the type.
+				_, err := db.Exec(fmt.Sprintf(`CREATE FUNCTION %s.city(a %s.address) RETURNS text AS 'SELECT ($1).city' LANGUAGE sql`, schema, schema))
+				require.NoError(t, err)
+			},
+			wantRollbackErr: migrations.TypeHasDependentsError{
+				Name:       "address",
+				Dependents: "function city(address)",
+			},
+		},
+		{
+			name: "rollback drops dependent objects when cascade is set",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_type",
+					Operations: migrations.Operations{
+						&migrations.OpCreateType{
+							Name:       "address",
+							Attributes: addressType.Attributes,
+							Cascade:    true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Create a function that depends on the type.
+				_, err := db.Exec(fmt.Sprintf(`CREATE FUNCTION %s.city(a %s.address) RETURNS text AS 'SELECT ($1).city' LANGUAGE sql`, schema, schema))
+				require.NoError(t, err)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The type and the function depending on it have been dropped.
+				TypeMustNotExist(t, db, schema, "address")
+				FunctionMustNotExist(t, db, schema, "city")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TypeMustExist(t, db, schema, "address")
+			},
+		},
+	})
+}
+
+func TestCreateTypeValidation(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "attributes are required",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_type",
+					Operations: migrations.Operations{
+						&migrations.OpCreateType{Name: "address"},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "attributes"},
+		},
+		{
+			name: "attribute names must be unique",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_type",
+					Operations: migrations.Operations{
+						&migrations.OpCreateType{
+							Name: "address",
+							Attributes: []migrations.CompositeTypeAttribute{
+								{Name: "city", Type: "text"},
+								{Name: "city", Type: "varchar(255)"},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidMigrationError{Reason: `duplicate attribute "city" in type "address"`},
+		},
+	})
+}
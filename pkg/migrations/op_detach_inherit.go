@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"slices"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpDetachInherit)(nil)
+	_ Createable = (*OpDetachInherit)(nil)
+)
+
+func (o *OpDetachInherit) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+	parent := s.GetTable(o.Parent)
+	if parent == nil {
+		return nil, TableDoesNotExistError{Name: o.Parent}
+	}
+
+	table.Inherits = slices.DeleteFunc(table.Inherits, func(name string) bool {
+		return name == parent.Name
+	})
+
+	return &StartResult{Actions: []DBAction{
+		NewAlterTableInheritAction(conn, table.Name, parent.Name, false),
+	}}, nil
+}
+
+func (o *OpDetachInherit) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpDetachInherit) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	table := s.GetTable(o.Table)
+	parent := s.GetTable(o.Parent)
+	if table == nil || parent == nil {
+		return nil, nil
+	}
+
+	return []DBAction{
+		NewAlterTableInheritAction(conn, table.Name, parent.Name, true),
+	}, nil
+}
+
+func (o *OpDetachInherit) Validate(ctx context.Context, s *schema.Schema) error {
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+	parent := s.GetTable(o.Parent)
+	if parent == nil {
+		return TableDoesNotExistError{Name: o.Parent}
+	}
+
+	if !table.InheritsFrom(parent.Name) {
+		return TableDoesNotInheritError{Table: o.Table, Parent: o.Parent}
+	}
+
+	table.Inherits = slices.DeleteFunc(table.Inherits, func(name string) bool {
+		return name == parent.Name
+	})
+	return nil
+}
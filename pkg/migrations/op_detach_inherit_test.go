@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestDetachInherit(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "measurements",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "measurements_2024",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer"},
+				},
+			},
+		},
+	}
+
+	attachInheritMigration := migrations.Migration{
+		Name: "02_attach_inherit",
+		Operations: migrations.Operations{
+			&migrations.OpAttachInherit{
+				Table:  "measurements_2024",
+				Parent: "measurements",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "detach a child table from its parent table",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				attachInheritMigration,
+				{
+					Name: "03_detach_inherit",
+					Operations: migrations.Operations{
+						&migrations.OpDetachInherit{
+							Table:  "measurements_2024",
+							Parent: "measurements",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotInherit(t, db, schema, "measurements_2024", "measurements")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustInherit(t, db, schema, "measurements_2024", "measurements")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotInherit(t, db, schema, "measurements_2024", "measurements")
+			},
+		},
+		{
+			name: "child table must inherit from the parent table",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_detach_inherit",
+					Operations: migrations.Operations{
+						&migrations.OpDetachInherit{
+							Table:  "measurements_2024",
+							Parent: "measurements",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotInheritError{Table: "measurements_2024", Parent: "measurements"},
+		},
+	})
+}
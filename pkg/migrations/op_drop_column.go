@@ -4,6 +4,12 @@ package migrations
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	pgq "github.com/xataio/pg_query_go/v6"
 
 	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/db"
@@ -11,8 +17,9 @@ import (
 )
 
 var (
-	_ Operation  = (*OpDropColumn)(nil)
-	_ Createable = (*OpDropColumn)(nil)
+	_ Operation      = (*OpDropColumn)(nil)
+	_ Createable     = (*OpDropColumn)(nil)
+	_ LossyOperation = (*OpDropColumn)(nil)
 )
 
 func (o *OpDropColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
@@ -22,7 +29,7 @@ func (o *OpDropColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schem
 	if o.Down != "" {
 		task = backfill.NewTask(nil,
 			backfill.OperationTrigger{
-				Name:           backfill.TriggerName(o.Table, o.Column),
+				Name:           NamingFrom(ctx).TriggerName(o.Table, o.Column),
 				Direction:      backfill.TriggerDirectionDown,
 				Columns:        s.GetTable(o.Table).Columns,
 				TableName:      s.GetTable(o.Table).Name,
@@ -41,22 +48,36 @@ func (o *OpDropColumn) Start(ctx context.Context, l Logger, conn db.DB, s *schem
 		return nil, ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
 	}
 
-	s.GetTable(o.Table).RemoveColumn(o.Column)
+	// Objects that depend on the column are dropped along with it when the
+	// migration completes, so they are removed from the new version of the
+	// schema too
+	if o.Cascade {
+		removeColumnDependents(s, table, column.Name)
+	}
+	table.RemoveColumn(o.Column)
 
 	return &StartResult{BackfillTask: task}, nil
 }
 
-func (o *OpDropColumn) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropColumn) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
-	return []DBAction{
-		NewDropColumnAction(conn, o.Table, o.Column),
-		NewDropFunctionAction(conn, backfill.TriggerFunctionName(o.Table, o.Column)),
-		NewDropColumnAction(conn, o.Table, backfill.CNeedsBackfillColumn),
-	}, nil
+	var actions []DBAction
+	if o.Cascade {
+		actions = append(actions, NewDropColumnCascadeAction(conn, o.Table, o.Column))
+	} else {
+		actions = append(actions,
+			NewCheckColumnViewsAction(conn, o.Table, o.Column),
+			NewDropColumnAction(conn, o.Table, o.Column))
+	}
+
+	return append(actions,
+		NewDropFunctionAction(conn, NamingFrom(ctx).TriggerFunctionName(o.Table, o.Column)),
+		NewDropNeedsBackfillColumnAction(conn, o.Table),
+	), nil
 }
 
-func (o *OpDropColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropColumn) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	table := s.GetTable(o.Table)
@@ -64,10 +85,17 @@ func (o *OpDropColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAct
 	// Mark the column as no longer deleted so thats it's visible to preceding
 	// rollback operations in the same migration
 	s.GetTable(o.Table).UnRemoveColumn(o.Column)
+	if o.Cascade {
+		for name, c := range table.Columns {
+			if c.Deleted && c.Generated != nil && slices.Contains(expressionColumns(*c.Generated), o.Column) {
+				table.UnRemoveColumn(name)
+			}
+		}
+	}
 
 	return []DBAction{
-		NewDropFunctionAction(conn, backfill.TriggerFunctionName(o.Table, o.Column)),
-		NewDropColumnAction(conn, table.Name, backfill.CNeedsBackfillColumn),
+		NewDropFunctionAction(conn, NamingFrom(ctx).TriggerFunctionName(o.Table, o.Column)),
+		NewDropNeedsBackfillColumnAction(conn, table.Name),
 	}, nil
 }
 
@@ -77,8 +105,162 @@ func (o *OpDropColumn) Validate(ctx context.Context, s *schema.Schema) error {
 	if table == nil {
 		return TableDoesNotExistError{Name: o.Table}
 	}
-	if table.GetColumn(o.Column) == nil {
+	column := table.GetColumn(o.Column)
+	if column == nil {
 		return ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
 	}
+
+	// Refuse to drop a column that other objects depend on, unless cascade is
+	// set. These objects would otherwise either be dropped along with the
+	// column or cause the drop to fail on migration completion.
+	if !o.Cascade {
+		if dependents := columnDependents(s, table, column.Name); len(dependents) > 0 {
+			return ColumnHasDependentsError{
+				Table:      o.Table,
+				Column:     o.Column,
+				Dependents: strings.Join(dependents, ", "),
+			}
+		}
+	}
+
 	return nil
 }
+
+// columnDependents returns a description of each object in the schema that
+// depends on the column with the given physical name. Indexes and constraints
+// defined only on the column itself are not included, as they are expected to
+// be dropped along with it. Views are not part of the schema, so they are
+// checked when the migration completes.
+func columnDependents(s *schema.Schema, table *schema.Table, column string) []string {
+	var dependents []string
+
+	for _, name := range dependentGeneratedColumns(table, column) {
+		dependents = append(dependents, fmt.Sprintf("generated column %q", name))
+	}
+	for _, idx := range table.Indexes {
+		if dependsOnColumn(indexColumns(idx), column) {
+			dependents = append(dependents, fmt.Sprintf("index %q", idx.Name))
+		}
+	}
+	for _, cc := range table.CheckConstraints {
+		if dependsOnColumn(cc.Columns, column) {
+			dependents = append(dependents, fmt.Sprintf("constraint %q", cc.Name))
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		if dependsOnColumn(fk.Columns, column) {
+			dependents = append(dependents, fmt.Sprintf("constraint %q", fk.Name))
+		}
+	}
+
+	// Foreign keys on any table that reference the column
+	for _, other := range s.Tables {
+		if other.Deleted {
+			continue
+		}
+		for _, fk := range other.ForeignKeys {
+			if fk.ReferencedTable == table.Name && slices.Contains(fk.ReferencedColumns, column) {
+				dependents = append(dependents, fmt.Sprintf("constraint %q on table %q", fk.Name, other.Name))
+			}
+		}
+	}
+
+	slices.Sort(dependents)
+	return dependents
+}
+
+// removeColumnDependents removes the objects reported by columnDependents from
+// the schema, along with the indexes and constraints defined only on the
+// column.
+func removeColumnDependents(s *schema.Schema, table *schema.Table, column string) {
+	for _, name := range dependentGeneratedColumns(table, column) {
+		table.RemoveColumn(name)
+	}
+	for name, idx := range table.Indexes {
+		if slices.Contains(indexColumns(idx), column) {
+			delete(table.Indexes, name)
+		}
+	}
+	for name, cc := range table.CheckConstraints {
+		if slices.Contains(cc.Columns, column) {
+			delete(table.CheckConstraints, name)
+		}
+	}
+	for name, uc := range table.UniqueConstraints {
+		if slices.Contains(uc.Columns, column) {
+			delete(table.UniqueConstraints, name)
+		}
+	}
+	for name, fk := range table.ForeignKeys {
+		if slices.Contains(fk.Columns, column) {
+			delete(table.ForeignKeys, name)
+		}
+	}
+	for _, other := range s.Tables {
+		for name, fk := range other.ForeignKeys {
+			if fk.ReferencedTable == table.Name && slices.Contains(fk.ReferencedColumns, column) {
+				delete(other.ForeignKeys, name)
+			}
+		}
+	}
+}
+
+// dependentGeneratedColumns returns the names of the generated columns of the
+// table whose expressions reference the column, in order.
+func dependentGeneratedColumns(table *schema.Table, column string) []string {
+	var names []string
+	for name, c := range table.Columns {
+		if c.Deleted || c.Name == column || c.Generated == nil {
+			continue
+		}
+		if slices.Contains(expressionColumns(*c.Generated), column) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// indexColumns returns the columns that an index refers to in its keys,
+// its key expressions or its predicate.
+func indexColumns(idx *schema.Index) []string {
+	columns := slices.Clone(idx.Columns)
+	for _, expression := range idx.Expressions {
+		columns = append(columns, expressionColumns(expression)...)
+	}
+	if idx.Predicate != nil {
+		columns = append(columns, expressionColumns(*idx.Predicate)...)
+	}
+	slices.Sort(columns)
+	return slices.Compact(columns)
+}
+
+// dependsOnColumn returns true if the columns include the column and any
+// other column, so that the object defined on them is not expected to be
+// dropped along with the column.
+func dependsOnColumn(columns []string, column string) bool {
+	return slices.Contains(columns, column) && slices.ContainsFunc(columns, func(c string) bool { return c != column })
+}
+
+// expressionColumns returns the names of the columns referenced by an SQL
+// expression, or nothing if the expression can't be parsed.
+func expressionColumns(expression string) []string {
+	jsonTree, err := pgq.ParseToJSON("SELECT " + expression)
+	if err != nil {
+		return nil
+	}
+	var node any
+	if err := json.Unmarshal([]byte(jsonTree), &node); err != nil {
+		return nil
+	}
+
+	var columns []string
+	for _, ref := range columnRefs(node) {
+		columns = append(columns, ref[len(ref)-1])
+	}
+	return columns
+}
+
+func (o *OpDropColumn) Lossy() string {
+	return fmt.Sprintf("the values of column %q of table %q are deleted when the migration is completed", o.Column, o.Table)
+}
@@ -409,3 +409,329 @@ func TestDropColumnInMultiOperationMigrations(t *testing.T) {
 		},
 	})
 }
+
+func TestDropColumnWithDependents(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "text",
+					},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "posts",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "user_id",
+						Type: "integer",
+						References: &migrations.ForeignKeyReference{
+							Name:   "fk_posts_user_id",
+							Table:  "users",
+							Column: "id",
+						},
+					},
+					{
+						Name: "title",
+						Type: "text",
+					},
+					{
+						Name: "slug",
+						Type: "text",
+					},
+				},
+				Constraints: []migrations.Constraint{
+					{
+						Name:    "unique_title_slug",
+						Type:    migrations.ConstraintTypeUnique,
+						Columns: []string{"title", "slug"},
+					},
+				},
+			},
+		},
+	}
+
+	createOrdersMigration := migrations.Migration{
+		Name: "01_create_orders",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "orders",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "price",
+						Type: "integer",
+					},
+					{
+						Name: "quantity",
+						Type: "integer",
+					},
+					{
+						Name:      "total",
+						Type:      "integer",
+						Nullable:  true,
+						Generated: &migrations.ColumnGenerated{Expression: "price * quantity"},
+					},
+					{
+						Name: "status",
+						Type: "text",
+					},
+					{
+						Name:     "archived_at",
+						Type:     "timestamptz",
+						Nullable: true,
+					},
+					{
+						Name:     "note",
+						Type:     "text",
+						Nullable: true,
+					},
+				},
+			},
+			&migrations.OpCreateIndex{
+				Name:      "idx_orders_open_status",
+				Table:     "orders",
+				Columns:   map[string]migrations.IndexField{"status": {}},
+				Predicate: "archived_at IS NULL",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "can't drop a column that is referenced by a stored generated column",
+			migrations: []migrations.Migration{
+				createOrdersMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:  "orders",
+							Column: "price",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnHasDependentsError{
+				Table:      "orders",
+				Column:     "price",
+				Dependents: `generated column "total"`,
+			},
+		},
+		{
+			name: "can't drop a column that is referenced by the predicate of a partial index",
+			migrations: []migrations.Migration{
+				createOrdersMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:  "orders",
+							Column: "archived_at",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnHasDependentsError{
+				Table:      "orders",
+				Column:     "archived_at",
+				Dependents: `index "idx_orders_open_status"`,
+			},
+		},
+		{
+			name: "can't complete the drop of a column that is selected by a view",
+			migrations: []migrations.Migration{
+				createOrdersMigration,
+				{
+					Name: "02_create_view",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up:   "CREATE VIEW order_notes AS SELECT id, note FROM orders",
+							Down: "DROP VIEW order_notes",
+						},
+					},
+				},
+				{
+					Name: "03_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:  "orders",
+							Column: "note",
+						},
+					},
+				},
+			},
+			wantCompleteErr: migrations.ColumnHasDependentsError{
+				Table:      "orders",
+				Column:     "note",
+				Dependents: "view order_notes",
+			},
+		},
+		{
+			name: "can drop a column that is referenced by a stored generated column with cascade",
+			migrations: []migrations.Migration{
+				createOrdersMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:   "orders",
+							Column:  "price",
+							Cascade: true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The generated column is hidden from the new version of the schema
+				versionSchema := roll.VersionedSchemaName(schema, "02_drop_column")
+				ColumnMustNotExist(t, db, versionSchema, "orders", "price")
+				ColumnMustNotExist(t, db, versionSchema, "orders", "total")
+
+				// but remains in the old version
+				ColumnMustExist(t, db, roll.VersionedSchemaName(schema, "01_create_orders"), "orders", "total")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustExist(t, db, schema, "orders", "price")
+				ColumnMustExist(t, db, schema, "orders", "total")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column and the generated column that depended on it have
+				// been dropped
+				ColumnMustNotExist(t, db, schema, "orders", "price")
+				ColumnMustNotExist(t, db, schema, "orders", "total")
+				ColumnMustExist(t, db, schema, "orders", "quantity")
+			},
+		},
+		{
+			name: "can drop a column that is referenced by the predicate of a partial index with cascade",
+			migrations: []migrations.Migration{
+				createOrdersMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:   "orders",
+							Column:  "archived_at",
+							Cascade: true,
+						},
+					},
+				},
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				IndexMustExist(t, db, schema, "orders", "idx_orders_open_status")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column and the partial index that depended on it have been
+				// dropped
+				ColumnMustNotExist(t, db, schema, "orders", "archived_at")
+				IndexMustNotExist(t, db, schema, "orders", "idx_orders_open_status")
+				ColumnMustExist(t, db, schema, "orders", "status")
+			},
+		},
+		{
+			name: "can't drop a column that is part of a multi-column index",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:  "posts",
+							Column: "title",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnHasDependentsError{
+				Table:      "posts",
+				Column:     "title",
+				Dependents: `index "unique_title_slug"`,
+			},
+		},
+		{
+			name: "can't drop a column that is referenced by a foreign key",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:  "users",
+							Column: "id",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnHasDependentsError{
+				Table:      "users",
+				Column:     "id",
+				Dependents: `constraint "fk_posts_user_id" on table "posts"`,
+			},
+		},
+		{
+			name: "can drop a column that is part of a multi-column index with cascade",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:   "posts",
+							Column:  "title",
+							Cascade: true,
+						},
+					},
+				},
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column and the index that depended on it have been dropped
+				ColumnMustNotExist(t, db, schema, "posts", "title")
+				IndexMustNotExist(t, db, schema, "posts", "unique_title_slug")
+			},
+		},
+		{
+			name: "can drop a column that is referenced by a foreign key with cascade",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:   "users",
+							Column:  "id",
+							Cascade: true,
+						},
+					},
+				},
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column has been dropped
+				ColumnMustNotExist(t, db, schema, "users", "id")
+
+				// The referencing column remains
+				ColumnMustExist(t, db, schema, "posts", "user_id")
+			},
+		},
+	})
+}
@@ -45,11 +45,11 @@ func (o *OpDropConstraint) Start(ctx context.Context, l Logger, conn db.DB, s *s
 	triggers := make([]backfill.OperationTrigger, 0)
 	triggers = append(triggers,
 		backfill.OperationTrigger{
-			Name:           backfill.TriggerName(o.Table, column.Name),
+			Name:           NamingFrom(ctx).TriggerName(o.Table, column.Name),
 			Direction:      backfill.TriggerDirectionUp,
 			Columns:        upColumns,
 			TableName:      o.Table,
-			PhysicalColumn: TemporaryName(column.Name),
+			PhysicalColumn: NamingFrom(ctx).TemporaryName(column.Name),
 			SQL:            o.upSQL(column.Name),
 		},
 	)
@@ -58,13 +58,13 @@ func (o *OpDropConstraint) Start(ctx context.Context, l Logger, conn db.DB, s *s
 	// here, before creation of the down trigger, so that the trigger can declare
 	// a variable for the new column.
 	table.AddColumn(column.Name, &schema.Column{
-		Name: TemporaryName(column.Name),
+		Name: NamingFrom(ctx).TemporaryName(column.Name),
 	})
 
 	// Add a trigger to copy values from the new column to the old, rewriting values using the `down` SQL.
 	triggers = append(triggers,
 		backfill.OperationTrigger{
-			Name:           backfill.TriggerName(o.Table, TemporaryName(column.Name)),
+			Name:           NamingFrom(ctx).TriggerName(o.Table, NamingFrom(ctx).TemporaryName(column.Name)),
 			Direction:      backfill.TriggerDirectionDown,
 			Columns:        table.Columns,
 			TableName:      o.Table,
@@ -75,7 +75,7 @@ func (o *OpDropConstraint) Start(ctx context.Context, l Logger, conn db.DB, s *s
 	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(table, triggers...)}, nil
 }
 
-func (o *OpDropConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropConstraint) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	// We have already validated that there is single column related to this constraint.
@@ -84,16 +84,16 @@ func (o *OpDropConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([]D
 
 	return []DBAction{
 		NewDropFunctionAction(conn,
-			backfill.TriggerFunctionName(o.Table, column.Name),
-			backfill.TriggerFunctionName(o.Table, TemporaryName(column.Name))),
-		NewAlterSequenceOwnerAction(conn, o.Table, column.Name, TemporaryName(column.Name)),
-		NewDropColumnAction(conn, table.Name, backfill.CNeedsBackfillColumn),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, column.Name),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, NamingFrom(ctx).TemporaryName(column.Name))),
+		NewAlterSequenceOwnerAction(conn, o.Table, column.Name, NamingFrom(ctx).TemporaryName(column.Name)),
+		NewDropNeedsBackfillColumnAction(conn, table.Name),
 		NewDropColumnAction(conn, o.Table, column.Name),
 		NewRenameDuplicatedColumnAction(conn, table, column.Name),
 	}, nil
 }
 
-func (o *OpDropConstraint) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropConstraint) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	// We have already validated that there is single column related to this constraint.
@@ -101,11 +101,11 @@ func (o *OpDropConstraint) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]D
 	columnName := table.GetConstraintColumns(o.Name)[0]
 
 	return []DBAction{
-		NewDropColumnAction(conn, o.Table, TemporaryName(columnName)),
+		NewDropColumnAction(conn, o.Table, NamingFrom(ctx).TemporaryName(columnName)),
 		NewDropFunctionAction(conn,
-			backfill.TriggerFunctionName(o.Table, columnName),
-			backfill.TriggerFunctionName(o.Table, TemporaryName(columnName))),
-		NewDropColumnAction(conn, table.Name, backfill.CNeedsBackfillColumn),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, columnName),
+			NamingFrom(ctx).TriggerFunctionName(o.Table, NamingFrom(ctx).TemporaryName(columnName))),
+		NewDropNeedsBackfillColumnAction(conn, table.Name),
 	}, nil
 }
 
@@ -134,7 +134,7 @@ func (o *OpDropConstraint) Validate(ctx context.Context, s *schema.Schema) error
 		}
 	}
 
-	if o.Down == "" {
+	if o.Down == "" && downSQLRequired(ctx) {
 		return FieldRequiredError{Name: "down"}
 	}
 
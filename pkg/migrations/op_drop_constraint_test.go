@@ -16,6 +16,10 @@ import (
 func TestDropConstraint(t *testing.T) {
 	t.Parallel()
 
+	// definition holds the definition of a constraint, recorded after a
+	// migration has been started, for comparison after it is rolled back.
+	var definition string
+
 	ExecuteTests(t, TestCases{
 		{
 			name: "drop check constraint with default up sql",
@@ -878,6 +882,77 @@ func TestDropConstraint(t *testing.T) {
 				ColumnMustHaveComment(t, db, schema, "posts", "title", "the title of the post")
 			},
 		},
+		{
+			name: "rolling back restores the exact constraint definition",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_add_tables",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "users",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "serial",
+									Pk:   true,
+								},
+							},
+						},
+						&migrations.OpCreateTable{
+							Name: "posts",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "serial",
+									Pk:   true,
+								},
+								{
+									Name:     "user_id",
+									Type:     "integer",
+									Nullable: true,
+									References: &migrations.ForeignKeyReference{
+										Name:     "fk_users_id",
+										Table:    "users",
+										Column:   "id",
+										OnDelete: migrations.ForeignKeyActionCASCADE,
+									},
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "02_drop_fk_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpDropConstraint{
+							Table: "posts",
+							Name:  "fk_users_id",
+							Down:  "user_id",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The constraint is left untouched on the original column until the
+				// migration is completed.
+				definition = ConstraintDefinition(t, db, schema, "posts", "fk_users_id")
+				assert.Contains(t, definition, "ON DELETE CASCADE")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The constraint still has the definition it had before the migration
+				// was started, without it having to be restated in the migration.
+				assert.Equal(t, definition, ConstraintDefinition(t, db, schema, "posts", "fk_users_id"))
+
+				// The table is cleaned up; temporary columns, trigger functions and triggers no longer exist.
+				TableMustBeCleanedUp(t, db, schema, "posts", "user_id")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The constraint has been dropped, so a post can reference a missing user.
+				MustInsert(t, db, schema, "02_drop_fk_constraint", "posts", map[string]string{
+					"user_id": "4",
+				})
+			},
+		},
 	})
 }
 
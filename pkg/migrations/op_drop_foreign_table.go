@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpDropForeignTable)(nil)
+	_ Createable = (*OpDropForeignTable)(nil)
+)
+
+func (o *OpDropForeignTable) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	// The foreign table remains available until the migration is completed
+	return nil, nil
+}
+
+func (o *OpDropForeignTable) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	return []DBAction{NewDropForeignTableAction(conn, o.Name)}, nil
+}
+
+func (o *OpDropForeignTable) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpDropForeignTable) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestDropForeignTable(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "drop foreign table",
+			migrations: []migrations.Migration{
+				createServerMigration,
+				{
+					Name: "02_create_foreign_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateForeignTable{
+							Name:    "remote_users",
+							Server:  "remote",
+							Columns: []migrations.ForeignTableColumn{{Name: "id", Type: "integer"}},
+						},
+					},
+				},
+				{
+					Name: "03_drop_foreign_table",
+					Operations: migrations.Operations{
+						&migrations.OpDropForeignTable{
+							Name: "remote_users",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The foreign table remains until the migration is completed
+				ForeignTableMustExist(t, db, schema, "remote_users")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ForeignTableMustExist(t, db, schema, "remote_users")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ForeignTableMustNotExist(t, db, schema, "remote_users")
+			},
+		},
+	})
+}
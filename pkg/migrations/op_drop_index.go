@@ -10,8 +10,9 @@ import (
 )
 
 var (
-	_ Operation  = (*OpDropIndex)(nil)
-	_ Createable = (*OpDropIndex)(nil)
+	_ Operation                 = (*OpDropIndex)(nil)
+	_ Createable                = (*OpDropIndex)(nil)
+	_ NonTransactionalOperation = (*OpDropIndex)(nil)
 )
 
 func (o *OpDropIndex) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
@@ -21,7 +22,7 @@ func (o *OpDropIndex) Start(ctx context.Context, l Logger, conn db.DB, s *schema
 	return nil, nil
 }
 
-func (o *OpDropIndex) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropIndex) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
@@ -29,7 +30,7 @@ func (o *OpDropIndex) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBActi
 	}, nil
 }
 
-func (o *OpDropIndex) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropIndex) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	// no-op
@@ -45,3 +46,7 @@ func (o *OpDropIndex) Validate(ctx context.Context, s *schema.Schema) error {
 	}
 	return IndexDoesNotExistError{Name: o.Name}
 }
+
+// NonTransactional allows drop_index in non-transactional migrations, as the
+// index is dropped with DROP INDEX CONCURRENTLY.
+func (o *OpDropIndex) NonTransactional() {}
@@ -42,7 +42,7 @@ func (o *OpDropMultiColumnConstraint) Start(ctx context.Context, l Logger, conn
 
 	d := NewColumnDuplicator(conn, table, columns...).WithoutConstraint(o.Name)
 	for _, colName := range constraintColumns {
-		d = d.WithName(table.GetColumn(colName).Name, TemporaryName(colName))
+		d = d.WithName(table.GetColumn(colName).Name, NamingFrom(ctx).TemporaryName(colName))
 	}
 	dbActions := []DBAction{d}
 
@@ -58,11 +58,11 @@ func (o *OpDropMultiColumnConstraint) Start(ctx context.Context, l Logger, conn
 		// Add a trigger to copy values from the old column to the new, rewriting values using the `up` SQL.
 		triggers = append(triggers,
 			backfill.OperationTrigger{
-				Name:           backfill.TriggerName(o.Table, columnName),
+				Name:           NamingFrom(ctx).TriggerName(o.Table, columnName),
 				Direction:      backfill.TriggerDirectionUp,
 				Columns:        upColumns,
 				TableName:      table.Name,
-				PhysicalColumn: TemporaryName(columnName),
+				PhysicalColumn: NamingFrom(ctx).TemporaryName(columnName),
 				SQL:            o.upSQL(columnName),
 			},
 		)
@@ -73,13 +73,13 @@ func (o *OpDropMultiColumnConstraint) Start(ctx context.Context, l Logger, conn
 		// physical column name in the down trigger first.
 		oldPhysicalColumn := table.GetColumn(columnName).Name
 		table.AddColumn(columnName, &schema.Column{
-			Name: TemporaryName(columnName),
+			Name: NamingFrom(ctx).TemporaryName(columnName),
 		})
 
 		// Add a trigger to copy values from the new column to the old, rewriting values using the `down` SQL.
 		triggers = append(triggers,
 			backfill.OperationTrigger{
-				Name:           backfill.TriggerName(o.Table, TemporaryName(columnName)),
+				Name:           NamingFrom(ctx).TriggerName(o.Table, NamingFrom(ctx).TemporaryName(columnName)),
 				Direction:      backfill.TriggerDirectionDown,
 				Columns:        table.Columns,
 				TableName:      table.Name,
@@ -92,7 +92,7 @@ func (o *OpDropMultiColumnConstraint) Start(ctx context.Context, l Logger, conn
 	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(table, triggers...)}, nil
 }
 
-func (o *OpDropMultiColumnConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropMultiColumnConstraint) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	table := s.GetTable(o.Table)
@@ -102,10 +102,10 @@ func (o *OpDropMultiColumnConstraint) Complete(l Logger, conn db.DB, s *schema.S
 		column := table.GetColumn(columnName)
 		dbActions = append(dbActions,
 			NewDropFunctionAction(conn,
-				backfill.TriggerFunctionName(o.Table, columnName),
-				backfill.TriggerFunctionName(o.Table, TemporaryName(columnName))),
-			NewAlterSequenceOwnerAction(conn, o.Table, columnName, TemporaryName(columnName)),
-			NewDropColumnAction(conn, o.Table, backfill.CNeedsBackfillColumn),
+				NamingFrom(ctx).TriggerFunctionName(o.Table, columnName),
+				NamingFrom(ctx).TriggerFunctionName(o.Table, NamingFrom(ctx).TemporaryName(columnName))),
+			NewAlterSequenceOwnerAction(conn, o.Table, columnName, NamingFrom(ctx).TemporaryName(columnName)),
+			NewDropNeedsBackfillColumnAction(conn, o.Table),
 			NewDropColumnAction(conn, o.Table, columnName),
 			NewRenameDuplicatedColumnAction(conn, table, column.Name),
 		)
@@ -114,7 +114,7 @@ func (o *OpDropMultiColumnConstraint) Complete(l Logger, conn db.DB, s *schema.S
 	return dbActions, nil
 }
 
-func (o *OpDropMultiColumnConstraint) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropMultiColumnConstraint) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	table := s.GetTable(o.Table)
@@ -122,11 +122,11 @@ func (o *OpDropMultiColumnConstraint) Rollback(l Logger, conn db.DB, s *schema.S
 	dbAction := make([]DBAction, 0)
 	for _, columnName := range table.GetConstraintColumns(o.Name) {
 		dbAction = append(dbAction,
-			NewDropColumnAction(conn, table.Name, TemporaryName(columnName)),
+			NewDropColumnAction(conn, table.Name, NamingFrom(ctx).TemporaryName(columnName)),
 			NewDropFunctionAction(conn,
-				backfill.TriggerFunctionName(o.Table, columnName),
-				backfill.TriggerFunctionName(o.Table, TemporaryName(columnName))),
-			NewDropColumnAction(conn, table.Name, backfill.CNeedsBackfillColumn),
+				NamingFrom(ctx).TriggerFunctionName(o.Table, columnName),
+				NamingFrom(ctx).TriggerFunctionName(o.Table, NamingFrom(ctx).TemporaryName(columnName))),
+			NewDropNeedsBackfillColumnAction(conn, table.Name),
 		)
 	}
 
@@ -147,14 +147,14 @@ func (o *OpDropMultiColumnConstraint) Validate(ctx context.Context, s *schema.Sc
 		return ConstraintDoesNotExistError{Table: o.Table, Constraint: o.Name}
 	}
 
-	if o.Down == nil {
+	if o.Down == nil && downSQLRequired(ctx) {
 		return FieldRequiredError{Name: "down"}
 	}
 
 	// Ensure that `down` migrations are present for all columns covered by the
 	// constraint to be dropped.
 	for _, columnName := range table.GetConstraintColumns(o.Name) {
-		if _, ok := o.Down[columnName]; !ok {
+		if _, ok := o.Down[columnName]; !ok && downSQLRequired(ctx) {
 			return ColumnMigrationMissingError{
 				Table: o.Table,
 				Name:  columnName,
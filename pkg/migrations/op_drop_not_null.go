@@ -31,12 +31,12 @@ func (o *OpDropNotNull) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 	return &StartResult{BackfillTask: backfill.NewTask(table)}, nil
 }
 
-func (o *OpDropNotNull) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropNotNull) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 	return nil, nil
 }
 
-func (o *OpDropNotNull) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropNotNull) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 	return nil, nil
 }
@@ -47,7 +47,7 @@ func (o *OpDropNotNull) Validate(ctx context.Context, s *schema.Schema) error {
 		return ColumnIsNullableError{Table: o.Table, Name: o.Column}
 	}
 
-	if o.Down == "" {
+	if o.Down == "" && downSQLRequired(ctx) {
 		return FieldRequiredError{Name: "down"}
 	}
 
@@ -4,11 +4,14 @@ package migrations_test
 
 import (
 	"database/sql"
+	"fmt"
 	"testing"
 
 	"github.com/xataio/pgroll/internal/testutils"
 
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/xataio/pgroll/pkg/migrations"
 )
@@ -739,6 +742,70 @@ func TestDropNotNullInMultiOperationMigrations(t *testing.T) {
 	})
 }
 
+func TestDropNotNullInPlace(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "drop not null in place",
+			migrations: []migrations.Migration{
+				{
+					Name:          "01_add_table",
+					VersionSchema: "add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "users",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "name", Type: "text"},
+							},
+						},
+					},
+				},
+				{
+					Name:          "02_drop_not_null",
+					VersionSchema: "drop_not_null",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:    "users",
+							Column:   "name",
+							Nullable: ptr(true),
+							InPlace:  true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The column is not duplicated
+				ColumnMustNotExist(t, db, schema, "users", migrations.TemporaryName("name"))
+				ColumnMustHaveAttnum(t, db, schema, "users", "name", 2)
+
+				// NULLs can be written through the new version of the schema
+				MustInsert(t, db, schema, "drop_not_null", "users", map[string]string{
+					"name": "alice",
+				})
+				MustInsert(t, db, schema, "drop_not_null", "users", map[string]string{})
+
+				// The NOT NULL constraint can't be added back on rollback while
+				// the column has NULLs
+				_, err := db.Exec(fmt.Sprintf("DELETE FROM %s.users WHERE name IS NULL", pq.QuoteIdentifier(schema)))
+				require.NoError(t, err)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The NOT NULL constraint has been added back
+				ColumnMustHaveAttnum(t, db, schema, "users", "name", 2)
+				CheckConstraintMustNotExist(t, db, schema, "users", migrations.NotNullConstraintName("name"))
+				MustNotInsert(t, db, schema, "add_table", "users", map[string]string{}, testutils.NotNullViolationErrorCode)
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustHaveAttnum(t, db, schema, "users", "name", 2)
+
+				MustInsert(t, db, schema, "drop_not_null", "users", map[string]string{})
+			},
+		},
+	})
+}
+
 func TestDropNotNullValidation(t *testing.T) {
 	t.Parallel()
 
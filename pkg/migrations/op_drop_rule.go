@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation       = (*OpDropRule)(nil)
+	_ Createable      = (*OpDropRule)(nil)
+	_ LegacyOperation = (*OpDropRule)(nil)
+)
+
+func (o *OpDropRule) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	// Rules rewrite statements on the table itself, so they can't be kept for
+	// the old schema version only. The rule is disabled rather than dropped so
+	// that Rollback can restore it; the rule in the in-memory schema is left
+	// unchanged so that its previous state is available to Rollback.
+	return &StartResult{Actions: []DBAction{
+		NewAlterRuleAction(conn, table.Name, o.Name, "DISABLE"),
+	}}, nil
+}
+
+func (o *OpDropRule) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	return []DBAction{NewDropRuleAction(conn, table.Name, o.Name)}, nil
+}
+
+func (o *OpDropRule) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, nil
+	}
+
+	// Restore the state the rule was in before the migration started
+	state := "ENABLE"
+	if rule := table.GetRule(o.Name); rule != nil {
+		state = rule.State
+	}
+
+	return []DBAction{NewAlterRuleAction(conn, table.Name, o.Name, state)}, nil
+}
+
+func (o *OpDropRule) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+
+	if table.GetRule(o.Name) == nil {
+		return RuleDoesNotExistError{Table: o.Table, Name: o.Name}
+	}
+
+	return nil
+}
+
+func (o *OpDropRule) Legacy() string {
+	return "rules are a legacy Postgres feature; prefer triggers"
+}
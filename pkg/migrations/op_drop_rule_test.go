@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestDropRule(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "varchar(255)",
+					},
+				},
+			},
+		},
+	}
+
+	createRuleMigration := migrations.Migration{
+		Name: "02_create_rule",
+		Operations: migrations.Operations{
+			&migrations.OpCreateRule{
+				Table:   "users",
+				Name:    "protect_users",
+				Event:   migrations.OpCreateRuleEventDELETE,
+				Instead: true,
+				Command: "NOTHING",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "drop a rule",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createRuleMigration,
+				{
+					Name: "03_drop_rule",
+					Operations: migrations.Operations{
+						&migrations.OpDropRule{
+							Table: "users",
+							Name:  "protect_users",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The rule is disabled until the migration is completed
+				RuleStateMustBe(t, db, schema, "users", "protect_users", "D")
+
+				// Deletes are no longer ignored
+				MustInsert(t, db, schema, "03_drop_rule", "users", map[string]string{
+					"name": "alice",
+				})
+				MustDelete(t, db, schema, "03_drop_rule", "users", map[string]string{
+					"name": "alice",
+				})
+				rows := MustSelect(t, db, schema, "03_drop_rule", "users")
+				assert.Empty(t, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The rule has been enabled again
+				RuleStateMustBe(t, db, schema, "users", "protect_users", "O")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				RuleMustNotExist(t, db, schema, "users", "protect_users")
+			},
+		},
+		{
+			name: "rollback restores the previous state of the rule",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				createRuleMigration,
+				{
+					Name: "03_enable_always_rule",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "ALTER TABLE users ENABLE ALWAYS RULE protect_users",
+						},
+					},
+				},
+				{
+					Name: "04_drop_rule",
+					Operations: migrations.Operations{
+						&migrations.OpDropRule{
+							Table: "users",
+							Name:  "protect_users",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				RuleStateMustBe(t, db, schema, "users", "protect_users", "D")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The rule is back in its ENABLE ALWAYS state
+				RuleStateMustBe(t, db, schema, "users", "protect_users", "A")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				RuleMustNotExist(t, db, schema, "users", "protect_users")
+			},
+		},
+	})
+}
+
+func TestDropRuleValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "table must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_drop_rule",
+					Operations: migrations.Operations{
+						&migrations.OpDropRule{
+							Table: "doesntexist",
+							Name:  "protect_users",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "doesntexist"},
+		},
+		{
+			name: "rule must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_drop_rule",
+					Operations: migrations.Operations{
+						&migrations.OpDropRule{
+							Table: "users",
+							Name:  "doesntexist",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.RuleDoesNotExistError{Table: "users", Name: "doesntexist"},
+		},
+	})
+}
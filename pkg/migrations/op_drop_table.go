@@ -4,14 +4,16 @@ package migrations
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/schema"
 )
 
 var (
-	_ Operation  = (*OpDropTable)(nil)
-	_ Createable = (*OpDropTable)(nil)
+	_ Operation      = (*OpDropTable)(nil)
+	_ Createable     = (*OpDropTable)(nil)
+	_ LossyOperation = (*OpDropTable)(nil)
 )
 
 func (o *OpDropTable) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
@@ -27,21 +29,21 @@ func (o *OpDropTable) Start(ctx context.Context, l Logger, conn db.DB, s *schema
 	// Soft-delete the table in order that a create table operation in the same
 	// migration can create a table with the same name
 	dbActions := []DBAction{
-		NewRenameTableAction(conn, table.Name, DeletionName(table.Name)),
+		NewRenameTableAction(conn, table.Name, NamingFrom(ctx).DeletionName(table.Name)),
 	}
 	return &StartResult{Actions: dbActions}, nil
 }
 
-func (o *OpDropTable) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropTable) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
 		// Perform the actual deletion of the soft-deleted table
-		NewDropTableAction(conn, DeletionName(o.Name)),
+		NewDropTableAction(conn, NamingFrom(ctx).DeletionName(o.Name)),
 	}, nil
 }
 
-func (o *OpDropTable) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpDropTable) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	// Mark the table as no longer deleted so that it is visible to preceding
@@ -52,7 +54,7 @@ func (o *OpDropTable) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBActi
 	table := s.GetTable(o.Name)
 
 	return []DBAction{
-		NewRenameTableAction(conn, DeletionName(table.Name), table.Name),
+		NewRenameTableAction(conn, NamingFrom(ctx).DeletionName(table.Name), table.Name),
 	}, nil
 }
 
@@ -66,3 +68,7 @@ func (o *OpDropTable) Validate(ctx context.Context, s *schema.Schema) error {
 	s.RemoveTable(table.Name)
 	return nil
 }
+
+func (o *OpDropTable) Lossy() string {
+	return fmt.Sprintf("the rows of table %q are deleted when the migration is completed", o.Name)
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpDropType)(nil)
+	_ Createable = (*OpDropType)(nil)
+)
+
+func (o *OpDropType) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	// The type remains available to the old schema version until the
+	// migration is completed
+	return nil, nil
+}
+
+func (o *OpDropType) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	return []DBAction{NewDropTypeAction(conn, o.Name, o.Cascade)}, nil
+}
+
+func (o *OpDropType) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpDropType) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Name == "" {
+		return FieldRequiredError{Name: "name"}
+	}
+
+	if o.Cascade {
+		return nil
+	}
+
+	// Refuse to drop a type that is still used by a column. Column types from
+	// the schema may be qualified with the schema name.
+	qualifiedName := s.Name + "." + o.Name
+	for _, table := range s.Tables {
+		for _, column := range table.Columns {
+			if column.Deleted {
+				continue
+			}
+			if column.Type == o.Name || column.Type == qualifiedName {
+				return TypeInUseError{Name: o.Name, Table: table.Name, Column: column.Name}
+			}
+		}
+	}
+
+	return nil
+}
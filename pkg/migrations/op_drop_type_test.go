@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestDropType(t *testing.T) {
+	t.Parallel()
+
+	createTypeMigration := migrations.Migration{
+		Name: "01_create_type",
+		Operations: migrations.Operations{
+			&migrations.OpCreateType{
+				Name: "address",
+				Attributes: []migrations.CompositeTypeAttribute{
+					{Name: "street", Type: "text"},
+					{Name: "city", Type: "text"},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name:     "address",
+						Type:     "address",
+						Nullable: true,
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "drop type",
+			migrations: []migrations.Migration{
+				createTypeMigration,
+				{
+					Name: "02_drop_column",
+					Operations: migrations.Operations{
+						&migrations.OpDropColumn{
+							Table:  "users",
+							Column: "address",
+						},
+					},
+				},
+				{
+					Name: "03_drop_type",
+					Operations: migrations.Operations{
+						&migrations.OpDropType{Name: "address"},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The type still exists until the migration is completed.
+				TypeMustExist(t, db, schema, "address")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The type still exists.
+				TypeMustExist(t, db, schema, "address")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The type has been dropped.
+				TypeMustNotExist(t, db, schema, "address")
+			},
+		},
+		{
+			name: "drop type used by a column with cascade",
+			migrations: []migrations.Migration{
+				createTypeMigration,
+				{
+					Name: "02_drop_type",
+					Operations: migrations.Operations{
+						&migrations.OpDropType{Name: "address", Cascade: true},
+					},
+				},
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The type and the column using it have been dropped.
+				TypeMustNotExist(t, db, schema, "address")
+				ColumnMustNotExist(t, db, schema, "users", "address")
+			},
+		},
+		{
+			name: "drop type used by a column",
+			migrations: []migrations.Migration{
+				createTypeMigration,
+				{
+					Name: "02_drop_type",
+					Operations: migrations.Operations{
+						&migrations.OpDropType{Name: "address"},
+					},
+				},
+			},
+			wantStartErr: migrations.TypeInUseError{Name: "address", Table: "users", Column: "address"},
+		},
+	})
+}
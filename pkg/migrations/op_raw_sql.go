@@ -10,8 +10,9 @@ import (
 )
 
 var (
-	_ Operation  = (*OpRawSQL)(nil)
-	_ Createable = (*OpRawSQL)(nil)
+	_ Operation                 = (*OpRawSQL)(nil)
+	_ Createable                = (*OpRawSQL)(nil)
+	_ NonTransactionalOperation = (*OpRawSQL)(nil)
 )
 
 func (o *OpRawSQL) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
@@ -22,22 +23,22 @@ func (o *OpRawSQL) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Sc
 	}
 
 	dbActions := []DBAction{
-		NewRawSQLAction(conn, o.Up),
+		NewRawSQLAction(conn, tempTablesSQL(o.TempTables, o.Up)),
 	}
 	return &StartResult{Actions: dbActions}, nil
 }
 
-func (o *OpRawSQL) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRawSQL) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	if !o.OnComplete {
 		return nil, nil
 	}
 
-	return []DBAction{NewRawSQLAction(conn, o.Up)}, nil
+	return []DBAction{NewRawSQLAction(conn, tempTablesSQL(o.TempTables, o.Up))}, nil
 }
 
-func (o *OpRawSQL) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRawSQL) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	if o.Down == "" {
@@ -56,7 +57,11 @@ func (o *OpRawSQL) Validate(ctx context.Context, s *schema.Schema) error {
 		return InvalidMigrationError{Reason: "down is not allowed with onComplete"}
 	}
 
-	return nil
+	if err := validateTempTables(o.TempTables, o.Up); err != nil {
+		return err
+	}
+
+	return validateEffects(o.Effects, s)
 }
 
 // IsIsolated returns true if the operation is isolated and should be run with other operations.
@@ -65,3 +70,7 @@ func (o *OpRawSQL) IsIsolated() bool {
 }
 
 func (o *OpRawSQL) RequiresSchemaRefresh() {}
+
+// NonTransactional allows raw_sql in non-transactional migrations, so that its
+// SQL can run statements that are not allowed in a transaction block.
+func (o *OpRawSQL) NonTransactional() {}
@@ -6,6 +6,8 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/xataio/pgroll/pkg/migrations"
 )
 
@@ -183,5 +185,146 @@ func TestRawSQL(t *testing.T) {
 				})
 			},
 		},
+		{
+			name: "raw SQL with temp tables",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: `
+								CREATE TABLE products (id serial PRIMARY KEY, price int);
+								INSERT INTO products (price) VALUES (5), (50)
+							`,
+							Down: `
+								DROP TABLE products
+							`,
+						},
+					},
+				},
+				{
+					Name: "02_raise_prices",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							TempTables: []migrations.TempTable{
+								{Name: "cheap", Query: "SELECT id FROM products WHERE price < 10"},
+							},
+							Up: `
+								UPDATE products SET price = price * 2 WHERE id IN (SELECT id FROM cheap)
+							`,
+						},
+					},
+				},
+				{
+					Name: "03_raise_prices_again",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							// The temporary table of the previous migration has been
+							// dropped, so the same name can be used again
+							TempTables: []migrations.TempTable{
+								{Name: "cheap", Query: "SELECT id FROM products WHERE price < 20"},
+							},
+							Up: `
+								UPDATE products SET price = price + 1 WHERE id IN (SELECT id FROM cheap)
+							`,
+						},
+					},
+				},
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				rows := MustSelect(t, db, schema, "03_raise_prices_again", "products")
+				assert.ElementsMatch(t, []map[string]any{
+					{"id": 1, "price": 11},
+					{"id": 2, "price": 50},
+				}, rows)
+			},
+		},
+		{
+			name: "temp tables can't be used with transaction control statements",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_raw_sql",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							TempTables: []migrations.TempTable{
+								{Name: "ids", Query: "SELECT 1 AS id"},
+							},
+							Up: "SELECT id FROM ids; COMMIT; SELECT id FROM ids",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidMigrationError{Reason: "up can't begin or end transactions when temp_tables are set, as the temporary tables are dropped when the transaction that creates them commits"},
+		},
+		{
+			name: "raw SQL with effects",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: `
+								CREATE TABLE test_table (id serial PRIMARY KEY, name text)
+							`,
+							Down: `
+								DROP TABLE IF EXISTS test_table
+							`,
+							Effects: &migrations.RawSQLEffects{
+								AddTables: []migrations.RawSQLTable{
+									{
+										Name: "test_table",
+										Columns: []migrations.RawSQLColumn{
+											{Name: "id", Type: "serial"},
+											{Name: "name", Type: "text", Nullable: true},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				MustInsert(t, db, schema, "01_create_table", "test_table", map[string]string{
+					"name": "foo",
+				})
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustNotExist(t, db, schema, "test_table")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				MustInsert(t, db, schema, "01_create_table", "test_table", map[string]string{
+					"name": "bar",
+				})
+				rows := MustSelect(t, db, schema, "01_create_table", "test_table")
+				assert.Equal(t, []map[string]any{{"id": 1, "name": "bar"}}, rows)
+			},
+		},
+		{
+			name: "raw SQL effects can't add a table that exists",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name:    "test_table",
+							Columns: []migrations.Column{{Name: "id", Type: "serial", Pk: true}},
+						},
+					},
+				},
+				{
+					Name: "02_raw_sql",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "SELECT 1",
+							Effects: &migrations.RawSQLEffects{
+								AddTables: []migrations.RawSQLTable{{Name: "test_table"}},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableAlreadyExistsError{Name: "test_table"},
+		},
 	})
 }
@@ -32,7 +32,7 @@ func (o *OpRenameColumn) Start(ctx context.Context, l Logger, conn db.DB, s *sch
 	return nil, nil
 }
 
-func (o *OpRenameColumn) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRenameColumn) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
@@ -40,12 +40,18 @@ func (o *OpRenameColumn) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBA
 	}, nil
 }
 
-func (o *OpRenameColumn) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRenameColumn) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
-	// Rename the column back to the original name in the in-memory schema.
+	// Rename the column back to the original name in the in-memory schema,
+	// along with any constraints that reference it. The column keeps its
+	// original name in the database until the migration completes.
 	table := s.GetTable(o.Table)
+	if table == nil || table.GetColumn(o.To) == nil {
+		return nil, nil
+	}
 	table.RenameColumn(o.To, o.From)
+	table.RenameConstraintColumns(o.To, o.From)
 
 	return nil, nil
 }
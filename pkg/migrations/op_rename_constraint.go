@@ -18,7 +18,7 @@ func (o *OpRenameConstraint) Start(ctx context.Context, l Logger, conn db.DB, s
 	return nil, nil
 }
 
-func (o *OpRenameConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRenameConstraint) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
@@ -27,7 +27,7 @@ func (o *OpRenameConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([
 	}, nil
 }
 
-func (o *OpRenameConstraint) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRenameConstraint) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	// no-op
@@ -17,16 +17,24 @@ func (o *OpRenameTable) Start(ctx context.Context, l Logger, conn db.DB, s *sche
 	return nil, s.RenameTable(o.From, o.To)
 }
 
-func (o *OpRenameTable) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRenameTable) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{NewRenameTableAction(conn, o.From, o.To)}, nil
 }
 
-func (o *OpRenameTable) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpRenameTable) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
-	s.RenameTable(o.To, o.From)
+	// The table keeps its original name in the database until the migration
+	// completes, so only the in-memory schema needs to be restored. The old
+	// name is restored even if a later operation in the migration left a table
+	// of that name in the in-memory schema, so that the rollback of earlier
+	// operations finds the table they changed.
+	if table, ok := s.Tables[o.To]; ok {
+		delete(s.Tables, o.To)
+		s.AddTable(o.From, table)
+	}
 	return nil, nil
 }
 
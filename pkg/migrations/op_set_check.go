@@ -44,7 +44,7 @@ func (o *OpSetCheckConstraint) Start(ctx context.Context, l Logger, conn db.DB,
 	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(table)}, nil
 }
 
-func (o *OpSetCheckConstraint) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetCheckConstraint) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
@@ -53,7 +53,7 @@ func (o *OpSetCheckConstraint) Complete(l Logger, conn db.DB, s *schema.Schema)
 	}, nil
 }
 
-func (o *OpSetCheckConstraint) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetCheckConstraint) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	return nil, nil
@@ -84,7 +84,7 @@ func (o *OpSetCheckConstraint) Validate(ctx context.Context, s *schema.Schema) e
 		return FieldRequiredError{Name: "up"}
 	}
 
-	if o.Down == "" {
+	if o.Down == "" && downSQLRequired(ctx) {
 		return FieldRequiredError{Name: "down"}
 	}
 
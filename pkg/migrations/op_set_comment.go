@@ -30,13 +30,13 @@ func (o *OpSetComment) Start(ctx context.Context, l Logger, conn db.DB, s *schem
 	}
 
 	dbActions := []DBAction{
-		NewCommentColumnAction(conn, o.Table, TemporaryName(o.Column), o.Comment),
+		NewCommentColumnAction(conn, o.Table, NamingFrom(ctx).TemporaryName(o.Column), o.Comment),
 	}
 
 	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(tbl)}, nil
 }
 
-func (o *OpSetComment) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetComment) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
@@ -44,7 +44,7 @@ func (o *OpSetComment) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAct
 	}, nil
 }
 
-func (o *OpSetComment) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetComment) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	return nil, nil
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// OpSetCompression is an operation that sets the compression method of a
+// column.
+type OpSetCompression struct {
+	Table       string                   `json:"table"`
+	Column      string                   `json:"column"`
+	Compression OpAlterColumnCompression `json:"compression"`
+	Up          string                   `json:"up"`
+	Down        string                   `json:"down"`
+}
+
+var _ Operation = (*OpSetCompression)(nil)
+
+func (o *OpSetCompression) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	tbl := s.GetTable(o.Table)
+	if tbl == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	// Set the compression method of the new column before it is backfilled,
+	// so that the backfilled values are compressed using it. The new column
+	// replaces the old one on completion.
+	dbActions := []DBAction{
+		NewAlterColumnCompressionAction(conn, o.Table, NamingFrom(ctx).TemporaryName(o.Column), string(o.Compression)),
+	}
+
+	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(tbl)}, nil
+}
+
+func (o *OpSetCompression) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	return nil, nil
+}
+
+func (o *OpSetCompression) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	return nil, nil
+}
+
+func (o *OpSetCompression) Validate(ctx context.Context, s *schema.Schema) error {
+	return nil
+}
+
+// minCompressionVersionNum is the first server_version_num of Postgres that
+// supports setting the compression method of a column.
+const minCompressionVersionNum = 140000
+
+// checkCompressionSupported returns an error if the server doesn't support
+// setting the compression method of a column.
+func checkCompressionSupported(ctx context.Context, conn db.DB, table, column string) error {
+	rows, err := conn.QueryContext(ctx, "SELECT current_setting('server_version_num')::int")
+	if err != nil {
+		return fmt.Errorf("failed to get server version: %w", err)
+	}
+	if rows == nil {
+		// the statements are being recorded rather than run
+		return nil
+	}
+	defer rows.Close()
+
+	var version int
+	if err := db.ScanFirstValue(rows, &version); err != nil {
+		return fmt.Errorf("failed to get server version: %w", err)
+	}
+	if version < minCompressionVersionNum {
+		return ColumnCompressionVersionError{Table: table, Column: column, Version: version}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestSetCompression(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name:          "01_add_table",
+		VersionSchema: "add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "documents",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "body",
+						Type: "text",
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name:              "set column compression in place",
+			minPgMajorVersion: 14,
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name:          "02_set_compression",
+					VersionSchema: "set_compression",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:       "documents",
+							Column:      "body",
+							Compression: ptr(migrations.OpAlterColumnCompressionPglz),
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Inserting rows into the old and new schemas succeeds
+				MustInsert(t, db, schema, "add_table", "documents", map[string]string{
+					"body": "alice",
+				})
+				MustInsert(t, db, schema, "set_compression", "documents", map[string]string{
+					"body": "bob",
+				})
+
+				// The compression is set on the column itself, without duplicating it
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "pglz")
+				ColumnMustNotExist(t, db, schema, "documents", migrations.TemporaryName("body"))
+
+				// Both schema views have the expected rows
+				for _, version := range []string{"add_table", "set_compression"} {
+					rows := MustSelect(t, db, schema, version, "documents")
+					assert.Equal(t, []map[string]any{
+						{"id": 1, "body": "alice"},
+						{"id": 2, "body": "bob"},
+					}, rows)
+				}
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The column has its previous compression again
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "default")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column has the new compression
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "pglz")
+				TableMustBeCleanedUp(t, db, schema, "documents", "body")
+			},
+		},
+		{
+			name:              "rolling back restores the compression set by an earlier migration",
+			minPgMajorVersion: 14,
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name:          "02_set_lz4",
+					VersionSchema: "set_lz4",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:       "documents",
+							Column:      "body",
+							Compression: ptr(migrations.OpAlterColumnCompressionLz4),
+						},
+					},
+				},
+				{
+					Name:          "03_set_pglz",
+					VersionSchema: "set_pglz",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:       "documents",
+							Column:      "body",
+							Compression: ptr(migrations.OpAlterColumnCompressionPglz),
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "pglz")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "lz4")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "pglz")
+			},
+		},
+		{
+			name:              "set column compression along with a type change",
+			minPgMajorVersion: 14,
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name:          "02_set_compression",
+					VersionSchema: "set_compression",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:       "documents",
+							Column:      "body",
+							Type:        ptr("varchar(1000)"),
+							Compression: ptr(migrations.OpAlterColumnCompressionPglz),
+							Up:          "body",
+							Down:        "body",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The old column keeps its compression
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "default")
+
+				// The new column has the new compression
+				ColumnMustHaveCompression(t, db, schema, "documents", migrations.TemporaryName("body"), "pglz")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The column keeps its compression
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "default")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column has the new type and compression
+				ColumnMustHaveType(t, db, schema, "documents", "body", "character varying(1000)")
+				ColumnMustHaveCompression(t, db, schema, "documents", "body", "pglz")
+			},
+		},
+	})
+}
+
+func TestSetCompressionValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "documents",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "created_at",
+						Type: "timestamptz",
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "invalid compression method",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_compression",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:       "documents",
+							Column:      "created_at",
+							Compression: ptr(migrations.OpAlterColumnCompression("zstd")),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidColumnCompressionError{Table: "documents", Column: "created_at", Compression: "zstd"},
+		},
+		{
+			name: "compression for a fixed-length type",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_compression",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:       "documents",
+							Column:      "created_at",
+							Compression: ptr(migrations.OpAlterColumnCompressionLz4),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnCompressionNotSupportedError{Table: "documents", Column: "created_at", Type: "timestamp with time zone"},
+		},
+	})
+}
@@ -63,7 +63,7 @@ func (o *OpSetForeignKey) Start(ctx context.Context, l Logger, conn db.DB, s *sc
 	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(table)}, nil
 }
 
-func (o *OpSetForeignKey) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetForeignKey) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
@@ -72,7 +72,7 @@ func (o *OpSetForeignKey) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DB
 	}, nil
 }
 
-func (o *OpSetForeignKey) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetForeignKey) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	return nil, nil
@@ -103,7 +103,7 @@ func (o *OpSetForeignKey) Validate(ctx context.Context, s *schema.Schema) error
 		return FieldRequiredError{Name: "up"}
 	}
 
-	if o.Down == "" {
+	if o.Down == "" && downSQLRequired(ctx) {
 		return FieldRequiredError{Name: "down"}
 	}
 
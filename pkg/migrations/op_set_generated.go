@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// OpSetGenerated is an operation that converts a column to a stored generated
+// column, or a generated column back to a regular column.
+//
+// The conversion is made on the column duplicated by the alter column
+// operation. A column converted to a generated column is added with its
+// generation expression, which rewrites the table and computes the values of
+// all existing rows. A column converted to a regular column is backfilled
+// using the generation expression of the original column, so that it keeps
+// its current values.
+type OpSetGenerated struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	// Expression is the generation expression of the column, or nil to
+	// convert the column to a regular column.
+	Expression *string `json:"expression"`
+	Up         string  `json:"up"`
+	Down       string  `json:"down"`
+}
+
+var _ Operation = (*OpSetGenerated)(nil)
+
+func (o *OpSetGenerated) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+	column := table.GetColumn(o.Column)
+	if column == nil {
+		return nil, ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
+	}
+
+	// The generation expression is set when the column is duplicated
+	column.Generated = o.Expression
+	column.Default = nil
+
+	return &StartResult{BackfillTask: backfill.NewTask(table)}, nil
+}
+
+func (o *OpSetGenerated) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	return nil, nil
+}
+
+func (o *OpSetGenerated) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	return nil, nil
+}
+
+func (o *OpSetGenerated) Validate(ctx context.Context, s *schema.Schema) error {
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+	column := table.GetColumn(o.Column)
+	if column == nil {
+		return ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
+	}
+
+	if o.Expression == nil {
+		if column.Generated == nil {
+			return ColumnNotGeneratedError{Table: o.Table, Column: o.Column}
+		}
+		return nil
+	}
+
+	if column.Generated != nil {
+		return ColumnAlreadyGeneratedError{Table: o.Table, Column: o.Column}
+	}
+	return validateGeneratedExpression(table, o.Column, *o.Expression)
+}
+
+// validateGeneratedExpression checks that the generation expression of the
+// column is a single expression that only references the other columns of the
+// same row. The column's own value can't be referenced, as the original
+// column is dropped when the migration completes, and neither can the values
+// of other generated columns.
+func validateGeneratedExpression(table *schema.Table, column, expression string) error {
+	invalid := func(reason string) error {
+		return InvalidGeneratedExpressionError{Table: table.Name, Column: column, Expression: expression, Reason: reason}
+	}
+
+	tree, err := pgq.Parse("SELECT " + expression)
+	if err != nil || len(tree.GetStmts()) != 1 {
+		return invalid("it is not a valid expression")
+	}
+	stmt := tree.GetStmts()[0].GetStmt().GetSelectStmt()
+	if stmt == nil || len(stmt.GetTargetList()) != 1 || stmt.GetTargetList()[0].GetResTarget().GetName() != "" {
+		return invalid("it is not a single expression")
+	}
+
+	// Reject anything other than the expression itself, such as a FROM
+	// clause, by comparing the statement with one that contains only the
+	// expression.
+	targetOnly := &pgq.ParseResult{Stmts: []*pgq.RawStmt{{
+		Stmt: &pgq.Node{Node: &pgq.Node_SelectStmt{SelectStmt: &pgq.SelectStmt{
+			TargetList: stmt.GetTargetList(),
+			Op:         pgq.SetOperation_SETOP_NONE,
+		}}},
+	}}}
+	got, err := pgq.Deparse(tree)
+	if err != nil {
+		return invalid("it is not a valid expression")
+	}
+	want, err := pgq.Deparse(targetOnly)
+	if err != nil || got != want {
+		return invalid("it is not a single expression")
+	}
+
+	jsonTree, err := pgq.ParseToJSON("SELECT " + expression)
+	if err != nil {
+		return invalid("it is not a valid expression")
+	}
+	var node any
+	if err := json.Unmarshal([]byte(jsonTree), &node); err != nil {
+		return invalid("it is not a valid expression")
+	}
+
+	if containsNode(node, "SubLink") {
+		return invalid("it contains a subquery")
+	}
+
+	for _, ref := range columnRefs(node) {
+		switch {
+		case len(ref) != 1 || ref[0] == "*":
+			return invalid("it references a column by a qualified name or *")
+		case ref[0] == column:
+			return invalid("it references the column itself")
+		}
+		referenced := table.GetColumn(ref[0])
+		if referenced == nil {
+			return ColumnDoesNotExistError{Table: table.Name, Name: ref[0]}
+		}
+		if referenced.Generated != nil {
+			return invalid("it references generated column " + ref[0])
+		}
+	}
+
+	return nil
+}
+
+// containsNode returns true if the JSON representation of a parse tree
+// contains a node of the given type.
+func containsNode(node any, nodeType string) bool {
+	switch n := node.(type) {
+	case map[string]any:
+		for key, v := range n {
+			if key == nodeType || containsNode(v, nodeType) {
+				return true
+			}
+		}
+	case []any:
+		for _, v := range n {
+			if containsNode(v, nodeType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// columnRefs returns the fields of the column references in the JSON
+// representation of a parse tree. A `*` field is returned as "*".
+func columnRefs(node any) [][]string {
+	var refs [][]string
+
+	switch n := node.(type) {
+	case map[string]any:
+		if ref, ok := n["ColumnRef"].(map[string]any); ok {
+			fields, _ := ref["fields"].([]any)
+			names := make([]string, 0, len(fields))
+			for _, f := range fields {
+				field, _ := f.(map[string]any)
+				if str, ok := field["String"].(map[string]any); ok {
+					name, _ := str["sval"].(string)
+					names = append(names, name)
+				} else {
+					names = append(names, "*")
+				}
+			}
+			return append(refs, names)
+		}
+		for _, v := range n {
+			refs = append(refs, columnRefs(v)...)
+		}
+	case []any:
+		for _, v := range n {
+			refs = append(refs, columnRefs(v)...)
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,291 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/oapi-codegen/nullable"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestSetGenerated(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "convert a column to a generated column",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "orders",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "price", Type: "integer"},
+								{Name: "quantity", Type: "integer"},
+								{Name: "total", Type: "integer", Default: ptr("0")},
+							},
+						},
+					},
+				},
+				{
+					// A row whose total was maintained incorrectly by the application
+					Name: "02_insert_orders",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "INSERT INTO orders (price, quantity, total) VALUES (10, 2, 99)",
+						},
+					},
+				},
+				{
+					Name: "03_set_generated",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:     "orders",
+							Column:    "total",
+							Generated: nullable.NewNullableWithValue("price * quantity"),
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Rows inserted into the new schema get a generated total, which is
+				// copied to the old schema
+				MustInsert(t, db, schema, "03_set_generated", "orders", map[string]string{
+					"price": "3", "quantity": "4",
+				})
+
+				// Rows inserted into the old schema keep their total in the old
+				// schema
+				MustInsert(t, db, schema, "02_insert_orders", "orders", map[string]string{
+					"price": "5", "quantity": "5", "total": "1",
+				})
+
+				// The new schema has generated totals for all rows
+				rows := MustSelect(t, db, schema, "03_set_generated", "orders")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 10, "quantity": 2, "total": 20},
+					{"id": 2, "price": 3, "quantity": 4, "total": 12},
+					{"id": 3, "price": 5, "quantity": 5, "total": 25},
+				}, rows)
+
+				// The old schema has the totals written to it
+				rows = MustSelect(t, db, schema, "02_insert_orders", "orders")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 10, "quantity": 2, "total": 99},
+					{"id": 2, "price": 3, "quantity": 4, "total": 12},
+					{"id": 3, "price": 5, "quantity": 5, "total": 1},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The column is a regular column with its original values
+				ColumnMustNotBeGenerated(t, db, schema, "orders", "total")
+				ColumnMustHaveDefault(t, db, schema, "orders", "total", "0")
+
+				rows := MustSelect(t, db, schema, "02_insert_orders", "orders")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 10, "quantity": 2, "total": 99},
+					{"id": 2, "price": 3, "quantity": 4, "total": 12},
+					{"id": 3, "price": 5, "quantity": 5, "total": 1},
+				}, rows)
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustBeGenerated(t, db, schema, "orders", "total")
+
+				MustInsert(t, db, schema, "03_set_generated", "orders", map[string]string{
+					"price": "2", "quantity": "2",
+				})
+
+				rows := MustSelect(t, db, schema, "03_set_generated", "orders")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 10, "quantity": 2, "total": 20},
+					{"id": 2, "price": 3, "quantity": 4, "total": 12},
+					{"id": 3, "price": 5, "quantity": 5, "total": 25},
+					{"id": 4, "price": 2, "quantity": 2, "total": 4},
+				}, rows)
+			},
+		},
+		{
+			name: "convert a generated column to a regular column",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "orders",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "serial", Pk: true},
+								{Name: "price", Type: "integer"},
+								{Name: "quantity", Type: "integer"},
+								{
+									Name:      "total",
+									Type:      "integer",
+									Nullable:  true,
+									Generated: &migrations.ColumnGenerated{Expression: "price * quantity"},
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "02_insert_orders",
+					Operations: migrations.Operations{
+						&migrations.OpRawSQL{
+							Up: "INSERT INTO orders (price, quantity) VALUES (2, 3)",
+						},
+					},
+				},
+				{
+					Name: "03_drop_generated",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:     "orders",
+							Column:    "total",
+							Generated: nullable.NewNullNullable[string](),
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The total of rows inserted into the new schema can be set
+				MustInsert(t, db, schema, "03_drop_generated", "orders", map[string]string{
+					"price": "1", "quantity": "1", "total": "100",
+				})
+
+				// Rows inserted into the old schema get the generated total
+				MustInsert(t, db, schema, "02_insert_orders", "orders", map[string]string{
+					"price": "2", "quantity": "2",
+				})
+
+				// The new schema keeps the values of the generated column
+				rows := MustSelect(t, db, schema, "03_drop_generated", "orders")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 2, "quantity": 3, "total": 6},
+					{"id": 2, "price": 1, "quantity": 1, "total": 100},
+					{"id": 3, "price": 2, "quantity": 2, "total": 4},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustBeGenerated(t, db, schema, "orders", "total")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustNotBeGenerated(t, db, schema, "orders", "total")
+
+				// The total set for the second row was discarded on rollback, and
+				// the generated value was kept when the migration was restarted
+				rows := MustSelect(t, db, schema, "03_drop_generated", "orders")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "price": 2, "quantity": 3, "total": 6},
+					{"id": 2, "price": 1, "quantity": 1, "total": 1},
+					{"id": 3, "price": 2, "quantity": 2, "total": 4},
+				}, rows)
+			},
+		},
+	})
+}
+
+func TestSetGeneratedValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "orders",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "price", Type: "integer"},
+					{Name: "quantity", Type: "integer"},
+					{Name: "total", Type: "integer"},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "generation expression with a subquery",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_generated",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:     "orders",
+							Column:    "total",
+							Generated: nullable.NewNullableWithValue("price * (SELECT max(quantity) FROM orders)"),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidGeneratedExpressionError{
+				Table:      "orders",
+				Column:     "total",
+				Expression: "price * (SELECT max(quantity) FROM orders)",
+				Reason:     "it contains a subquery",
+			},
+		},
+		{
+			name: "generation expression that references the column itself",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_generated",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:     "orders",
+							Column:    "total",
+							Generated: nullable.NewNullableWithValue("total + 1"),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidGeneratedExpressionError{
+				Table:      "orders",
+				Column:     "total",
+				Expression: "total + 1",
+				Reason:     "it references the column itself",
+			},
+		},
+		{
+			name: "generation expression and default",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_generated",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:     "orders",
+							Column:    "total",
+							Generated: nullable.NewNullableWithValue("price * quantity"),
+							Default:   nullable.NewNullableWithValue("0"),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.GeneratedColumnDefaultError{Table: "orders", Column: "total"},
+		},
+		{
+			name: "convert a regular column to a regular column",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_drop_generated",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:     "orders",
+							Column:    "total",
+							Generated: nullable.NewNullNullable[string](),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnNotGeneratedError{Table: "orders", Column: "total"},
+		},
+	})
+}
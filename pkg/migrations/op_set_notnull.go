@@ -32,25 +32,28 @@ func (o *OpSetNotNull) Start(ctx context.Context, l Logger, conn db.DB, s *schem
 		return nil, ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
 	}
 
-	// Add an unchecked NOT NULL constraint to the new column.
+	// Add an unchecked NOT NULL constraint to the new column, unless an earlier,
+	// interrupted attempt to start the migration has already added it.
 	skipInherit := false
 	skipValidate := true // We will validate the constraint later in the Complete step.
-	dbActions := []DBAction{
-		NewCreateCheckConstraintAction(
-			conn,
-			table.Name,
-			NotNullConstraintName(o.Column),
-			fmt.Sprintf("%s IS NOT NULL", o.Column),
-			[]string{o.Column},
-			skipInherit,
-			skipValidate,
-		),
+	var dbActions []DBAction
+	if !table.ConstraintExists(NamingFrom(ctx).NotNullConstraintName(o.Column)) {
+		dbActions = append(dbActions,
+			NewCreateCheckConstraintAction(
+				conn,
+				table.Name,
+				NamingFrom(ctx).NotNullConstraintName(o.Column),
+				fmt.Sprintf("%s IS NOT NULL", o.Column),
+				[]string{o.Column},
+				skipInherit,
+				skipValidate,
+			))
 	}
 
 	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(table)}, nil
 }
 
-func (o *OpSetNotNull) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetNotNull) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationComplete(o)
 
 	return []DBAction{
@@ -58,15 +61,15 @@ func (o *OpSetNotNull) Complete(l Logger, conn db.DB, s *schema.Schema) ([]DBAct
 		// The constraint must be valid because:
 		// * Existing NULL values in the old column were rewritten using the `up` SQL during backfill.
 		// * New NULL values written to the old column during the migration period were also rewritten using `up` SQL.
-		NewValidateConstraintAction(conn, o.Table, NotNullConstraintName(o.Column)),
+		NewValidateConstraintAction(conn, o.Table, NamingFrom(ctx).NotNullConstraintName(o.Column)),
 		// Use the validated constraint to add `NOT NULL` to the new column
-		NewSetNotNullAction(conn, o.Table, TemporaryName(o.Column)),
+		NewSetNotNullAction(conn, o.Table, NamingFrom(ctx).TemporaryName(o.Column)),
 		// Drop the NOT NULL constraint
-		NewDropConstraintAction(conn, o.Table, NotNullConstraintName(o.Column)),
+		NewDropConstraintAction(conn, o.Table, NamingFrom(ctx).NotNullConstraintName(o.Column)),
 	}, nil
 }
 
-func (o *OpSetNotNull) Rollback(l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+func (o *OpSetNotNull) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
 	l.LogOperationRollback(o)
 
 	return nil, nil
@@ -547,6 +547,205 @@ func TestSetNotNull(t *testing.T) {
 	})
 }
 
+func TestSetNotNullWithTypeChange(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "reviews",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name:     "rating",
+						Type:     "text",
+						Nullable: true,
+					},
+				},
+			},
+		},
+	}
+
+	insertRowsMigration := migrations.Migration{
+		Name: "02_insert_rows",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: "INSERT INTO reviews (rating) VALUES ('5'), (NULL)",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "change type and set not null in a single operation",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				insertRowsMigration,
+				{
+					Name: "03_alter_column",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:    "reviews",
+							Column:   "rating",
+							Type:     ptr("integer"),
+							Nullable: ptr(false),
+							Up:       "SELECT CASE WHEN rating IS NULL THEN 0 ELSE rating::integer END",
+							Down:     "rating::text",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// A single new column has the new type
+				ColumnMustHaveType(t, db, schema, "reviews", migrations.TemporaryName("rating"), "integer")
+
+				// Existing rows have been backfilled with non-NULL values
+				rows := MustSelect(t, db, schema, "03_alter_column", "reviews")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "rating": 5},
+					{"id": 2, "rating": 0},
+				}, rows)
+
+				// Inserting a NULL into the new `rating` column fails
+				MustNotInsert(t, db, schema, "03_alter_column", "reviews", map[string]string{"rating": "NULL"}, testutils.CheckViolationErrorCode)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The table is cleaned up; temporary columns, trigger functions and triggers no longer exist.
+				TableMustBeCleanedUp(t, db, schema, "reviews", "rating")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column has the new type and is NOT NULL
+				ColumnMustHaveType(t, db, schema, "reviews", "rating", "integer")
+				MustNotInsert(t, db, schema, "03_alter_column", "reviews", map[string]string{"rating": "NULL"}, testutils.NotNullViolationErrorCode)
+
+				// The table is cleaned up; temporary columns, trigger functions and triggers no longer exist.
+				TableMustBeCleanedUp(t, db, schema, "reviews", "rating")
+			},
+		},
+		{
+			name: "starting fails if the backfill produces NULL values",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				insertRowsMigration,
+				{
+					Name: "03_alter_column",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:    "reviews",
+							Column:   "rating",
+							Type:     ptr("integer"),
+							Nullable: ptr(false),
+							Up:       "rating::integer",
+							Down:     "rating::text",
+						},
+					},
+				},
+			},
+			wantStartErr:  migrations.BackfillNullValuesError{Table: "reviews", Column: "rating"},
+			afterStart:    func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {},
+		},
+	})
+}
+
+func TestSetNotNullInPlace(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name:          "01_add_table",
+		VersionSchema: "add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "reviews",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "product", Type: "text"},
+					{Name: "review", Type: "text", Nullable: true},
+				},
+			},
+		},
+	}
+	insertRowsMigration := migrations.Migration{
+		Name: "02_insert_rows",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: "INSERT INTO reviews (product, review) VALUES ('apple', 'crisp'), ('banana', 'soft')",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "set not null in place",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				insertRowsMigration,
+				{
+					Name:          "03_set_not_null",
+					VersionSchema: "set_not_null",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:    "reviews",
+							Column:   "review",
+							Nullable: ptr(false),
+							InPlace:  true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The column is not duplicated
+				ColumnMustNotExist(t, db, schema, "reviews", migrations.TemporaryName("review"))
+				ColumnMustHaveAttnum(t, db, schema, "reviews", "review", 3)
+
+				// The NOT VALID check constraint exists
+				CheckConstraintMustExist(t, db, schema, "reviews", migrations.NotNullConstraintName("review"))
+
+				// NULLs can't be written through either version of the schema
+				MustNotInsert(t, db, schema, "set_not_null", "reviews", map[string]string{
+					"product": "carrot",
+				}, testutils.CheckViolationErrorCode)
+				MustNotInsert(t, db, schema, "add_table", "reviews", map[string]string{
+					"product": "carrot",
+				}, testutils.CheckViolationErrorCode)
+
+				MustInsert(t, db, schema, "add_table", "reviews", map[string]string{
+					"product": "carrot",
+					"review":  "crunchy",
+				})
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The check constraint has been dropped
+				CheckConstraintMustNotExist(t, db, schema, "reviews", migrations.NotNullConstraintName("review"))
+				ColumnMustHaveAttnum(t, db, schema, "reviews", "review", 3)
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The table was not rewritten
+				ColumnMustHaveAttnum(t, db, schema, "reviews", "review", 3)
+
+				// The check constraint has been replaced by NOT NULL
+				CheckConstraintMustNotExist(t, db, schema, "reviews", migrations.NotNullConstraintName("review"))
+				MustNotInsert(t, db, schema, "set_not_null", "reviews", map[string]string{
+					"product": "carrot",
+				}, testutils.NotNullViolationErrorCode)
+
+				rows := MustSelect(t, db, schema, "set_not_null", "reviews")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "product": "apple", "review": "crisp"},
+					{"id": 2, "product": "banana", "review": "soft"},
+					{"id": 5, "product": "carrot", "review": "crunchy"},
+				}, rows)
+			},
+		},
+	})
+}
+
 func TestSetNotNullInMultiOperationMigrations(t *testing.T) {
 	t.Parallel()
 
@@ -872,6 +1071,44 @@ func TestSetNotNullValidation(t *testing.T) {
 			},
 			wantStartErr: migrations.FieldRequiredError{Name: "up"},
 		},
+		{
+			name: "an in place change can't have up SQL",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_nullable",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:    "reviews",
+							Column:   "review",
+							Nullable: ptr(false),
+							InPlace:  true,
+							Up:       "coalesce(review, 'none')",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.AlterColumnInPlaceError{Table: "reviews", Column: "review"},
+		},
+		{
+			name: "an in place change can only change the nullability",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_nullable",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:    "reviews",
+							Column:   "review",
+							Nullable: ptr(false),
+							Type:     ptr("varchar(255)"),
+							InPlace:  true,
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.AlterColumnInPlaceError{Table: "reviews", Column: "review"},
+		},
 		{
 			name: "column is nullable",
 			migrations: []migrations.Migration{
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpSetPrimaryKey)(nil)
+	_ Createable = (*OpSetPrimaryKey)(nil)
+)
+
+func (o *OpSetPrimaryKey) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	// The primary key is added on completion, so that rolling back leaves the
+	// index untouched
+	return &StartResult{}, nil
+}
+
+func (o *OpSetPrimaryKey) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	// The index is unique and its columns are NOT NULL, so adding the primary
+	// key only takes a brief lock on the table and doesn't scan it
+	return []DBAction{NewAddPrimaryKeyAction(conn, table.Name, o.Index, false, false)}, nil
+}
+
+func (o *OpSetPrimaryKey) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpSetPrimaryKey) Validate(ctx context.Context, s *schema.Schema) error {
+	if o.Index == "" {
+		return FieldRequiredError{Name: "index"}
+	}
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+
+	if len(table.PrimaryKey) > 0 {
+		return TableHasPrimaryKeyError{Table: o.Table}
+	}
+
+	index, ok := table.Indexes[o.Index]
+	if !ok {
+		return IndexDoesNotExistError{Name: o.Index}
+	}
+
+	invalid := func(reason string) error {
+		return InvalidPrimaryKeyIndexError{Table: o.Table, Index: o.Index, Reason: reason}
+	}
+	if !index.Unique {
+		return invalid("the index is not unique")
+	}
+	if index.Predicate != nil {
+		return invalid("the index is partial")
+	}
+	if len(index.Expressions) > 0 {
+		return invalid("the index is defined on expressions")
+	}
+	if index.Method != "" && index.Method != "btree" {
+		return invalid("the index is not a btree index")
+	}
+	if _, ok := table.UniqueConstraints[o.Index]; ok {
+		return invalid("the index backs a unique constraint")
+	}
+
+	// the index refers to columns by their physical names
+	for _, name := range slices.Sorted(maps.Keys(table.Columns)) {
+		if column := table.Columns[name]; column.Nullable && slices.Contains(index.Columns, column.Name) {
+			return ColumnIsNullableError{Table: o.Table, Name: name}
+		}
+	}
+
+	return nil
+}
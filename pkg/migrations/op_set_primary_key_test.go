@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestSetPrimaryKey(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := func(idNullable bool) migrations.Migration {
+		return migrations.Migration{
+			Name: "01_create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name: "users",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "integer", Nullable: idNullable},
+						{Name: "name", Type: "text", Nullable: true},
+					},
+				},
+			},
+		}
+	}
+
+	createIndexMigration := func(index *migrations.OpCreateIndex) migrations.Migration {
+		return migrations.Migration{
+			Name:       "02_create_index",
+			Operations: migrations.Operations{index},
+		}
+	}
+
+	setPrimaryKeyMigration := migrations.Migration{
+		Name: "03_set_primary_key",
+		Operations: migrations.Operations{
+			&migrations.OpSetPrimaryKey{
+				Table: "users",
+				Index: "idx_users_id",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "promote a unique index to the primary key",
+			migrations: []migrations.Migration{
+				createTableMigration(false),
+				createIndexMigration(&migrations.OpCreateIndex{
+					Name:    "idx_users_id",
+					Table:   "users",
+					Columns: migrations.OpCreateIndexColumns{"id": {}},
+					Unique:  true,
+				}),
+				setPrimaryKeyMigration,
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The index exists but the primary key is only added on completion
+				IndexMustExist(t, db, schema, "users", "idx_users_id")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The index is kept
+				IndexMustExist(t, db, schema, "users", "idx_users_id")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The index backs the primary key
+				PrimaryKeyConstraintMustExist(t, db, schema, "users", "idx_users_id")
+				ColumnMustBePK(t, db, schema, "users", "id")
+			},
+		},
+		{
+			name: "an index that doesn't exist can't be promoted",
+			migrations: []migrations.Migration{
+				createTableMigration(false),
+				setPrimaryKeyMigration,
+			},
+			wantStartErr: migrations.IndexDoesNotExistError{Name: "idx_users_id"},
+		},
+		{
+			name: "an index on a nullable column can't be promoted",
+			migrations: []migrations.Migration{
+				createTableMigration(true),
+				createIndexMigration(&migrations.OpCreateIndex{
+					Name:    "idx_users_id",
+					Table:   "users",
+					Columns: migrations.OpCreateIndexColumns{"id": {}},
+					Unique:  true,
+				}),
+				setPrimaryKeyMigration,
+			},
+			wantStartErr: migrations.ColumnIsNullableError{Table: "users", Name: "id"},
+		},
+		{
+			name: "a non-unique index can't be promoted",
+			migrations: []migrations.Migration{
+				createTableMigration(false),
+				createIndexMigration(&migrations.OpCreateIndex{
+					Name:    "idx_users_id",
+					Table:   "users",
+					Columns: migrations.OpCreateIndexColumns{"id": {}},
+				}),
+				setPrimaryKeyMigration,
+			},
+			wantStartErr: migrations.InvalidPrimaryKeyIndexError{
+				Table:  "users",
+				Index:  "idx_users_id",
+				Reason: "the index is not unique",
+			},
+		},
+		{
+			name: "a partial index can't be promoted",
+			migrations: []migrations.Migration{
+				createTableMigration(false),
+				createIndexMigration(&migrations.OpCreateIndex{
+					Name:      "idx_users_id",
+					Table:     "users",
+					Columns:   migrations.OpCreateIndexColumns{"id": {}},
+					Unique:    true,
+					Predicate: "id > 0",
+				}),
+				setPrimaryKeyMigration,
+			},
+			wantStartErr: migrations.InvalidPrimaryKeyIndexError{
+				Table:  "users",
+				Index:  "idx_users_id",
+				Reason: "the index is partial",
+			},
+		},
+		{
+			name: "a table that already has a primary key can't get another one",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_create_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "users",
+							Columns: []migrations.Column{
+								{Name: "id", Type: "integer", Pk: true},
+								{Name: "email", Type: "text"},
+							},
+						},
+					},
+				},
+				createIndexMigration(&migrations.OpCreateIndex{
+					Name:    "idx_users_id",
+					Table:   "users",
+					Columns: migrations.OpCreateIndexColumns{"email": {}},
+					Unique:  true,
+				}),
+				setPrimaryKeyMigration,
+			},
+			wantStartErr: migrations.TableHasPrimaryKeyError{Table: "users"},
+		},
+	})
+}
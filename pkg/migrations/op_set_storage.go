@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// OpSetStorage is an operation that sets the storage mode of a column.
+type OpSetStorage struct {
+	Table   string               `json:"table"`
+	Column  string               `json:"column"`
+	Storage OpAlterColumnStorage `json:"storage"`
+	Up      string               `json:"up"`
+	Down    string               `json:"down"`
+}
+
+var _ Operation = (*OpSetStorage)(nil)
+
+func (o *OpSetStorage) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	tbl := s.GetTable(o.Table)
+	if tbl == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	// Set the storage mode of the new column before it is backfilled, so that
+	// the backfilled values are stored using it. The new column replaces the
+	// old one on completion.
+	dbActions := []DBAction{
+		NewAlterColumnStorageAction(conn, o.Table, NamingFrom(ctx).TemporaryName(o.Column), string(o.Storage)),
+	}
+
+	return &StartResult{Actions: dbActions, BackfillTask: backfill.NewTask(tbl)}, nil
+}
+
+func (o *OpSetStorage) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	return nil, nil
+}
+
+func (o *OpSetStorage) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	return nil, nil
+}
+
+func (o *OpSetStorage) Validate(ctx context.Context, s *schema.Schema) error {
+	return nil
+}
+
+// fixedLengthTypes are the built-in types whose values have a fixed length.
+// Values of these types are never compressed or stored out of line, so they
+// can only have the 'plain' storage mode.
+var fixedLengthTypes = map[string]bool{
+	`"char"`:                      true,
+	"bigint":                      true,
+	"bigserial":                   true,
+	"bool":                        true,
+	"boolean":                     true,
+	"date":                        true,
+	"double precision":            true,
+	"float":                       true,
+	"float4":                      true,
+	"float8":                      true,
+	"int":                         true,
+	"int2":                        true,
+	"int4":                        true,
+	"int8":                        true,
+	"integer":                     true,
+	"interval":                    true,
+	"macaddr":                     true,
+	"macaddr8":                    true,
+	"money":                       true,
+	"oid":                         true,
+	"pg_lsn":                      true,
+	"point":                       true,
+	"real":                        true,
+	"serial":                      true,
+	"serial2":                     true,
+	"serial4":                     true,
+	"serial8":                     true,
+	"smallint":                    true,
+	"smallserial":                 true,
+	"time":                        true,
+	"time with time zone":         true,
+	"time without time zone":      true,
+	"timestamp":                   true,
+	"timestamp with time zone":    true,
+	"timestamp without time zone": true,
+	"timestamptz":                 true,
+	"timetz":                      true,
+	"uuid":                        true,
+}
+
+var typeModifierRegex = regexp.MustCompile(`\s*\([^)]*\)`)
+
+// isFixedLengthType reports whether the given type is a built-in type whose
+// values have a fixed length. Type modifiers, such as the precision of a
+// timestamp, are ignored. Arrays and unknown types are assumed to have values
+// of variable length.
+func isFixedLengthType(typ string) bool {
+	typ = typeModifierRegex.ReplaceAllString(strings.ToLower(strings.TrimSpace(typ)), "")
+	return fixedLengthTypes[strings.Join(strings.Fields(typ), " ")]
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestSetStorage(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "set column storage with default up and down SQL",
+			migrations: []migrations.Migration{
+				{
+					Name:          "01_add_table",
+					VersionSchema: "add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "documents",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "serial",
+									Pk:   true,
+								},
+								{
+									Name: "body",
+									Type: "text",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name:          "02_set_storage",
+					VersionSchema: "set_storage",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:   "documents",
+							Column:  "body",
+							Storage: ptr(migrations.OpAlterColumnStorageExternal),
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Inserting rows into the old and new schemas succeeds
+				MustInsert(t, db, schema, "add_table", "documents", map[string]string{
+					"body": "alice",
+				})
+				MustInsert(t, db, schema, "set_storage", "documents", map[string]string{
+					"body": "bob",
+				})
+
+				// The old column keeps its storage
+				ColumnMustHaveStorage(t, db, schema, "documents", "body", "extended")
+
+				// The new column has the new storage
+				ColumnMustHaveStorage(t, db, schema, "documents", migrations.TemporaryName("body"), "external")
+
+				// Both schema views have the expected rows
+				for _, version := range []string{"add_table", "set_storage"} {
+					rows := MustSelect(t, db, schema, version, "documents")
+					assert.Equal(t, []map[string]any{
+						{"id": 1, "body": "alice"},
+						{"id": 2, "body": "bob"},
+					}, rows)
+				}
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The column keeps its storage
+				ColumnMustHaveStorage(t, db, schema, "documents", "body", "extended")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The column has the new storage
+				ColumnMustHaveStorage(t, db, schema, "documents", "body", "external")
+
+				// The new schema view has the expected rows
+				rows := MustSelect(t, db, schema, "set_storage", "documents")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "body": "alice"},
+					{"id": 2, "body": "bob"},
+				}, rows)
+			},
+		},
+		{
+			name: "set storage of a fixed-length column to plain",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "documents",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "serial",
+									Pk:   true,
+								},
+								{
+									Name: "size",
+									Type: "integer",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "02_set_storage",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:   "documents",
+							Column:  "size",
+							Storage: ptr(migrations.OpAlterColumnStoragePlain),
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustHaveStorage(t, db, schema, "documents", "size", "plain")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ColumnMustHaveStorage(t, db, schema, "documents", "size", "plain")
+			},
+		},
+	})
+}
+
+func TestSetStorageValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "documents",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "body",
+						Type: "text",
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "invalid storage mode",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_storage",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:   "documents",
+							Column:  "body",
+							Storage: ptr(migrations.OpAlterColumnStorage("compressed")),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidColumnStorageError{Table: "documents", Column: "body", Storage: "compressed"},
+		},
+		{
+			name: "non-plain storage for a fixed-length type",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_storage",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:   "documents",
+							Column:  "id",
+							Storage: ptr(migrations.OpAlterColumnStorageMain),
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnStorageNotSupportedError{Table: "documents", Column: "id", Type: "integer", Storage: "main"},
+		},
+		{
+			name: "non-plain storage for a column changed to a fixed-length type",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_set_storage",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:   "documents",
+							Column:  "body",
+							Type:    ptr("bigint"),
+							Storage: ptr(migrations.OpAlterColumnStorageExternal),
+							Up:      "length(body)",
+							Down:    "body::text",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnStorageNotSupportedError{Table: "documents", Column: "body", Type: "bigint", Storage: "external"},
+		},
+	})
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// OpTransformJsonb is an operation that restructures the value of a jsonb
+// column using a list of path operations in each direction.
+type OpTransformJsonb struct {
+	Table  string               `json:"table"`
+	Column string               `json:"column"`
+	Up     []JsonbPathOperation `json:"up"`
+	Down   []JsonbPathOperation `json:"down"`
+}
+
+var _ Operation = (*OpTransformJsonb)(nil)
+
+func (o *OpTransformJsonb) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	return &StartResult{BackfillTask: backfill.NewTask(table)}, nil
+}
+
+func (o *OpTransformJsonb) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	return nil, nil
+}
+
+func (o *OpTransformJsonb) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	return nil, nil
+}
+
+func (o *OpTransformJsonb) Validate(ctx context.Context, s *schema.Schema) error {
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+	column := table.GetColumn(o.Column)
+	if column == nil {
+		return ColumnDoesNotExistError{Table: o.Table, Name: o.Column}
+	}
+	if column.Type != "jsonb" {
+		return ColumnIsNotJsonbError{Table: o.Table, Name: o.Column, Type: column.Type}
+	}
+
+	for _, op := range append(o.Up, o.Down...) {
+		if err := op.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpSQL returns the SQL expression that applies the `up` path operations to
+// the column.
+func (o *OpTransformJsonb) UpSQL() (string, error) {
+	return jsonbTransformSQL(o.Column, o.Up)
+}
+
+// DownSQL returns the SQL expression that applies the `down` path operations
+// to the column.
+func (o *OpTransformJsonb) DownSQL() (string, error) {
+	return jsonbTransformSQL(o.Column, o.Down)
+}
+
+// Validate checks that the path operation is well-formed.
+func (op JsonbPathOperation) Validate() error {
+	if _, err := parseJsonbPath(op.Path); err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case JsonbPathOperationOpSet:
+		if op.Value == "" {
+			return FieldRequiredError{Name: "value"}
+		}
+	case JsonbPathOperationOpRemove:
+		if op.Value != "" {
+			return InvalidJsonbPathOperationError{Op: string(op.Op), Path: op.Path, Reason: "value must not be set"}
+		}
+	default:
+		return InvalidJsonbPathOperationError{Op: string(op.Op), Path: op.Path, Reason: `op must be one of "set" or "remove"`}
+	}
+
+	return nil
+}
+
+// jsonbTransformSQL builds an SQL expression that applies each of the path
+// operations in turn to the given column, eg:
+//
+//	jsonb_set("payload" #- ARRAY['old'], ARRAY['new'], COALESCE("payload" -> 'old', 'null'::jsonb), true)
+//
+// jsonb_set returns NULL if the value it sets is NULL, so a value that
+// evaluates to NULL, such as a key missing from the document, is set as a JSON
+// null rather than wiping out the whole document.
+func jsonbTransformSQL(column string, ops []JsonbPathOperation) (string, error) {
+	sql := pq.QuoteIdentifier(column)
+
+	for _, op := range ops {
+		path, err := parseJsonbPath(op.Path)
+		if err != nil {
+			return "", err
+		}
+
+		switch op.Op {
+		case JsonbPathOperationOpSet:
+			sql = fmt.Sprintf("jsonb_set(%s, %s, COALESCE(%s, 'null'::jsonb), true)", sql, jsonbPathArray(path), op.Value)
+		case JsonbPathOperationOpRemove:
+			sql = fmt.Sprintf("(%s #- %s)", sql, jsonbPathArray(path))
+		}
+	}
+
+	return sql, nil
+}
+
+// jsonbPathArray returns the path elements as a Postgres text array
+// expression, quoting each element.
+func jsonbPathArray(path []string) string {
+	elems := make([]string, len(path))
+	for i, e := range path {
+		elems[i] = pq.QuoteLiteral(e)
+	}
+	return fmt.Sprintf("ARRAY[%s]::text[]", strings.Join(elems, ", "))
+}
+
+// parseJsonbPath parses a path in Postgres text array syntax, eg
+// `{address,city}` or `{tags,0}`, into its elements. Elements may be
+// double-quoted to include commas, braces or whitespace.
+func parseJsonbPath(path string) ([]string, error) {
+	invalid := func(reason string) error {
+		return InvalidJsonbPathError{Path: path, Reason: reason}
+	}
+
+	trimmed := strings.TrimSpace(path)
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") || len(trimmed) < 2 {
+		return nil, invalid("path must be enclosed in braces")
+	}
+	body := trimmed[1 : len(trimmed)-1]
+	if strings.TrimSpace(body) == "" {
+		return nil, invalid("path must contain at least one element")
+	}
+
+	var elems []string
+	for i := 0; i <= len(body); {
+		// Skip leading whitespace
+		for i < len(body) && body[i] == ' ' {
+			i++
+		}
+
+		var elem strings.Builder
+		if i < len(body) && body[i] == '"' {
+			i++
+			closed := false
+			for i < len(body) {
+				c := body[i]
+				if c == '\\' && i+1 < len(body) {
+					elem.WriteByte(body[i+1])
+					i += 2
+					continue
+				}
+				if c == '"' {
+					closed = true
+					i++
+					break
+				}
+				elem.WriteByte(c)
+				i++
+			}
+			if !closed {
+				return nil, invalid("unterminated quoted element")
+			}
+			for i < len(body) && body[i] == ' ' {
+				i++
+			}
+		} else {
+			for i < len(body) && body[i] != ',' {
+				if strings.ContainsRune(`{}"\`, rune(body[i])) {
+					return nil, invalid(fmt.Sprintf("unexpected character %q; quote the element", body[i]))
+				}
+				elem.WriteByte(body[i])
+				i++
+			}
+			if strings.TrimSpace(elem.String()) == "" {
+				return nil, invalid("path elements must not be empty")
+			}
+			trimmedElem := strings.TrimSpace(elem.String())
+			elem.Reset()
+			elem.WriteString(trimmedElem)
+		}
+
+		elems = append(elems, elem.String())
+
+		if i == len(body) {
+			break
+		}
+		if body[i] != ',' {
+			return nil, invalid(fmt.Sprintf("expected ',' at position %d", i+1))
+		}
+		i++
+		if i == len(body) {
+			return nil, invalid("path elements must not be empty")
+		}
+	}
+
+	return elems, nil
+}
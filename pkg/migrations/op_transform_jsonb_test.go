@@ -0,0 +1,333 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestTransformJsonb(t *testing.T) {
+	t.Parallel()
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "restructure a jsonb column",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "events",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "serial",
+									Pk:   true,
+								},
+								{
+									Name: "payload",
+									Type: "jsonb",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "payload",
+							Jsonb: &migrations.JsonbTransform{
+								Up: []migrations.JsonbPathOperation{
+									{Op: migrations.JsonbPathOperationOpSet, Path: "{address,city}", Value: "payload -> 'city'"},
+									{Op: migrations.JsonbPathOperationOpRemove, Path: "{city}"},
+								},
+								Down: []migrations.JsonbPathOperation{
+									{Op: migrations.JsonbPathOperationOpSet, Path: "{city}", Value: "payload #> '{address,city}'"},
+									{Op: migrations.JsonbPathOperationOpRemove, Path: "{address}"},
+								},
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Inserting into the old view works.
+				MustInsert(t, db, schema, "01_add_table", "events", map[string]string{
+					"payload": `{"city": "London"}`,
+				})
+
+				// The value has been restructured in the new view.
+				rows := MustSelect(t, db, schema, "02_transform_jsonb", "events")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "payload": []byte(`{"address": {"city": "London"}}`)},
+				}, rows)
+
+				// Inserting into the new view works.
+				MustInsert(t, db, schema, "02_transform_jsonb", "events", map[string]string{
+					"payload": `{"address": {"city": "Paris"}}`,
+				})
+
+				// The value has been restructured in the old view.
+				rows = MustSelect(t, db, schema, "01_add_table", "events")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "payload": []byte(`{"city": "London"}`)},
+					{"id": 2, "payload": []byte(`{"city": "Paris"}`)},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The table is cleaned up; temporary columns, trigger functions and triggers no longer exist.
+				TableMustBeCleanedUp(t, db, schema, "events", "payload")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				// The table is cleaned up; temporary columns, trigger functions and triggers no longer exist.
+				TableMustBeCleanedUp(t, db, schema, "events", "payload")
+
+				// The values have been restructured.
+				rows := MustSelect(t, db, schema, "02_transform_jsonb", "events")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "payload": []byte(`{"address": {"city": "London"}}`)},
+					{"id": 2, "payload": []byte(`{"address": {"city": "Paris"}}`)},
+				}, rows)
+			},
+		},
+		{
+			name: "moving a key that is missing from the document keeps the rest of the document",
+			migrations: []migrations.Migration{
+				{
+					Name: "01_add_table",
+					Operations: migrations.Operations{
+						&migrations.OpCreateTable{
+							Name: "events",
+							Columns: []migrations.Column{
+								{
+									Name: "id",
+									Type: "serial",
+									Pk:   true,
+								},
+								{
+									Name: "payload",
+									Type: "jsonb",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "payload",
+							Jsonb: &migrations.JsonbTransform{
+								Up: []migrations.JsonbPathOperation{
+									{Op: migrations.JsonbPathOperationOpSet, Path: "{town}", Value: "payload -> 'city'"},
+									{Op: migrations.JsonbPathOperationOpRemove, Path: "{city}"},
+								},
+								Down: []migrations.JsonbPathOperation{
+									{Op: migrations.JsonbPathOperationOpSet, Path: "{city}", Value: "payload -> 'town'"},
+									{Op: migrations.JsonbPathOperationOpRemove, Path: "{town}"},
+								},
+							},
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// Insert a document without the moved key into the old view.
+				MustInsert(t, db, schema, "01_add_table", "events", map[string]string{
+					"payload": `{"country": "UK"}`,
+				})
+
+				// The missing key is set to null in the new view, rather than the
+				// whole document being set to NULL.
+				rows := MustSelect(t, db, schema, "02_transform_jsonb", "events")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "payload": []byte(`{"town": null, "country": "UK"}`)},
+				}, rows)
+
+				// Insert a document without the moved key into the new view.
+				MustInsert(t, db, schema, "02_transform_jsonb", "events", map[string]string{
+					"payload": `{"country": "FR"}`,
+				})
+
+				// The missing key is set to null in the old view.
+				rows = MustSelect(t, db, schema, "01_add_table", "events")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "payload": []byte(`{"country": "UK"}`)},
+					{"id": 2, "payload": []byte(`{"city": null, "country": "FR"}`)},
+				}, rows)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "events", "payload")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				TableMustBeCleanedUp(t, db, schema, "events", "payload")
+
+				rows := MustSelect(t, db, schema, "02_transform_jsonb", "events")
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "payload": []byte(`{"town": null, "country": "UK"}`)},
+					{"id": 2, "payload": []byte(`{"country": "FR"}`)},
+				}, rows)
+			},
+		},
+	})
+}
+
+func TestTransformJsonbValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "events",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "text",
+					},
+					{
+						Name: "payload",
+						Type: "jsonb",
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "column must be jsonb",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "name",
+							Jsonb: &migrations.JsonbTransform{
+								Up:   []migrations.JsonbPathOperation{{Op: migrations.JsonbPathOperationOpRemove, Path: "{a}"}},
+								Down: []migrations.JsonbPathOperation{},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ColumnIsNotJsonbError{Table: "events", Name: "name", Type: "text"},
+		},
+		{
+			name: "path must be well-formed",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "payload",
+							Jsonb: &migrations.JsonbTransform{
+								Up:   []migrations.JsonbPathOperation{{Op: migrations.JsonbPathOperationOpRemove, Path: "address.city"}},
+								Down: []migrations.JsonbPathOperation{},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidJsonbPathError{Path: "address.city", Reason: "path must be enclosed in braces"},
+		},
+		{
+			name: "path elements must not be empty",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "payload",
+							Jsonb: &migrations.JsonbTransform{
+								Up:   []migrations.JsonbPathOperation{},
+								Down: []migrations.JsonbPathOperation{{Op: migrations.JsonbPathOperationOpRemove, Path: "{address,,city}"}},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.InvalidJsonbPathError{Path: "{address,,city}", Reason: "path elements must not be empty"},
+		},
+		{
+			name: "set requires a value",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "payload",
+							Jsonb: &migrations.JsonbTransform{
+								Up:   []migrations.JsonbPathOperation{{Op: migrations.JsonbPathOperationOpSet, Path: "{a}"}},
+								Down: []migrations.JsonbPathOperation{},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.FieldRequiredError{Name: "value"},
+		},
+		{
+			name: "jsonb cannot be combined with up SQL",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "payload",
+							Up:     "payload",
+							Jsonb: &migrations.JsonbTransform{
+								Up:   []migrations.JsonbPathOperation{{Op: migrations.JsonbPathOperationOpRemove, Path: "{a}"}},
+								Down: []migrations.JsonbPathOperation{},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.JsonbTransformConflictError{Table: "events", Column: "payload"},
+		},
+		{
+			name: "quoted path elements are valid",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_transform_jsonb",
+					Operations: migrations.Operations{
+						&migrations.OpAlterColumn{
+							Table:  "events",
+							Column: "payload",
+							Jsonb: &migrations.JsonbTransform{
+								Up:   []migrations.JsonbPathOperation{{Op: migrations.JsonbPathOperationOpRemove, Path: `{"first, last",0}`}},
+								Down: []migrations.JsonbPathOperation{},
+							},
+						},
+					},
+				},
+			},
+			wantStartErr: nil,
+		},
+	})
+}
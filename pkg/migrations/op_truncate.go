@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation      = (*OpTruncate)(nil)
+	_ Createable     = (*OpTruncate)(nil)
+	_ LossyOperation = (*OpTruncate)(nil)
+)
+
+func (o *OpTruncate) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	// The rows are copied into a backup table before the table is truncated
+	// so that Rollback can restore them
+	var backup string
+	if o.PreserveData {
+		backup = NamingFrom(ctx).TruncatedName(table.Name)
+	}
+
+	return &StartResult{Actions: []DBAction{
+		NewTruncateTableAction(conn, table.Name, backup, o.RestartIdentity, o.Cascade),
+	}}, nil
+}
+
+func (o *OpTruncate) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	if !o.PreserveData {
+		return nil, nil
+	}
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	return []DBAction{NewDropTableAction(conn, NamingFrom(ctx).TruncatedName(table.Name))}, nil
+}
+
+func (o *OpTruncate) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	if !o.PreserveData {
+		return nil, nil
+	}
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, nil
+	}
+
+	return []DBAction{
+		NewRestoreTruncatedTableAction(conn, table.Name, NamingFrom(ctx).TruncatedName(table.Name), o.RestartIdentity),
+	}, nil
+}
+
+func (o *OpTruncate) Validate(ctx context.Context, s *schema.Schema) error {
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+
+	if o.PreserveData && o.Cascade {
+		return TruncateCascadeWithPreserveDataError{Table: o.Table}
+	}
+
+	// Without a copy of the rows, the truncation can only be part of a
+	// migration that is never rolled back
+	if !o.PreserveData && rollbackRequired(ctx) {
+		return TruncateNotReversibleError{Table: o.Table}
+	}
+
+	return nil
+}
+
+func (o *OpTruncate) Lossy() string {
+	if o.PreserveData {
+		return fmt.Sprintf("the rows of table %q are deleted when the migration is completed", o.Table)
+	}
+	return fmt.Sprintf("the rows of table %q are deleted when the migration is started and can't be restored by a rollback", o.Table)
+}
@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "staging_users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+					{
+						Name: "name",
+						Type: "varchar(255)",
+					},
+				},
+			},
+		},
+	}
+
+	insertRowsMigration := migrations.Migration{
+		Name: "02_insert_rows",
+		Operations: migrations.Operations{
+			&migrations.OpRawSQL{
+				Up: "INSERT INTO staging_users (name) VALUES ('alice'), ('bob')",
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "truncate a table, preserving its rows until completion",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				insertRowsMigration,
+				{
+					Name: "03_truncate",
+					Operations: migrations.Operations{
+						&migrations.OpTruncate{
+							Table:        "staging_users",
+							PreserveData: true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The table has been truncated in both versions of the schema
+				assert.Empty(t, MustSelect(t, db, schema, "03_truncate", "staging_users"))
+				assert.Empty(t, MustSelect(t, db, schema, "02_insert_rows", "staging_users"))
+
+				// The rows have been copied into the backup table
+				TableMustExist(t, db, schema, migrations.TruncatedName("staging_users"))
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The rows have been restored
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "alice"},
+					{"id": 2, "name": "bob"},
+				}, MustSelect(t, db, schema, "02_insert_rows", "staging_users"))
+
+				// The backup table has been dropped
+				TableMustNotExist(t, db, schema, migrations.TruncatedName("staging_users"))
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				assert.Empty(t, MustSelect(t, db, schema, "03_truncate", "staging_users"))
+
+				// The backup table has been dropped
+				TableMustNotExist(t, db, schema, migrations.TruncatedName("staging_users"))
+			},
+		},
+		{
+			name: "restarted sequences are moved past the restored rows on rollback",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				insertRowsMigration,
+				{
+					Name: "03_truncate",
+					Operations: migrations.Operations{
+						&migrations.OpTruncate{
+							Table:           "staging_users",
+							PreserveData:    true,
+							RestartIdentity: true,
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The sequence has been restarted
+				MustInsert(t, db, schema, "03_truncate", "staging_users", map[string]string{
+					"name": "carol",
+				})
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "carol"},
+				}, MustSelect(t, db, schema, "03_truncate", "staging_users"))
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The row written during the migration is replaced by the
+				// restored rows, and new rows don't collide with them
+				MustInsert(t, db, schema, "02_insert_rows", "staging_users", map[string]string{
+					"name": "dave",
+				})
+				assert.Equal(t, []map[string]any{
+					{"id": 1, "name": "alice"},
+					{"id": 2, "name": "bob"},
+					{"id": 3, "name": "dave"},
+				}, MustSelect(t, db, schema, "02_insert_rows", "staging_users"))
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				assert.Empty(t, MustSelect(t, db, schema, "03_truncate", "staging_users"))
+			},
+		},
+		{
+			name: "truncate without preserving rows in a forward-only migration",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				insertRowsMigration,
+				{
+					Name:       "03_truncate",
+					Reversible: ptr(false),
+					Operations: migrations.Operations{
+						&migrations.OpTruncate{
+							Table: "staging_users",
+						},
+					},
+				},
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				assert.Empty(t, MustSelect(t, db, schema, "03_truncate", "staging_users"))
+				TableMustNotExist(t, db, schema, migrations.TruncatedName("staging_users"))
+			},
+			wantRollbackErr: roll.ErrIrreversibleMigration,
+		},
+	})
+}
+
+func TestTruncateValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_add_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "staging_users",
+				Columns: []migrations.Column{
+					{
+						Name: "id",
+						Type: "serial",
+						Pk:   true,
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "table must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_truncate",
+					Operations: migrations.Operations{
+						&migrations.OpTruncate{
+							Table:        "doesntexist",
+							PreserveData: true,
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "doesntexist"},
+		},
+		{
+			name: "truncate without preserving rows must be forward-only",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_truncate",
+					Operations: migrations.Operations{
+						&migrations.OpTruncate{
+							Table: "staging_users",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TruncateNotReversibleError{Table: "staging_users"},
+		},
+		{
+			name: "preserve_data can't be combined with cascade",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_truncate",
+					Operations: migrations.Operations{
+						&migrations.OpTruncate{
+							Table:        "staging_users",
+							PreserveData: true,
+							Cascade:      true,
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TruncateCascadeWithPreserveDataError{Table: "staging_users"},
+		},
+	})
+}
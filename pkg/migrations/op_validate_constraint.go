@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+var (
+	_ Operation  = (*OpValidateConstraint)(nil)
+	_ Createable = (*OpValidateConstraint)(nil)
+)
+
+func (o *OpValidateConstraint) Start(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) (*StartResult, error) {
+	l.LogOperationStart(o)
+
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return nil, TableDoesNotExistError{Name: o.Table}
+	}
+
+	// The constraint is validated when the migration is completed
+	markConstraintValidated(table, o.Name)
+
+	// Report the rows that violate the constraint before the migration is
+	// started, rather than failing to validate it on completion
+	return &StartResult{Actions: []DBAction{
+		NewCheckConstraintViolationsAction(conn, table.Name, o.Name),
+	}}, nil
+}
+
+func (o *OpValidateConstraint) Complete(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationComplete(o)
+
+	return []DBAction{
+		NewValidateConstraintAction(conn, o.Table, o.Name),
+	}, nil
+}
+
+func (o *OpValidateConstraint) Rollback(ctx context.Context, l Logger, conn db.DB, s *schema.Schema) ([]DBAction, error) {
+	l.LogOperationRollback(o)
+
+	// No-op
+	return nil, nil
+}
+
+func (o *OpValidateConstraint) Validate(ctx context.Context, s *schema.Schema) error {
+	table := s.GetTable(o.Table)
+	if table == nil {
+		return TableDoesNotExistError{Name: o.Table}
+	}
+
+	if !table.ConstraintExists(o.Name) {
+		return ConstraintDoesNotExistError{Table: o.Table, Constraint: o.Name}
+	}
+
+	// Only check constraints and foreign keys can be NOT VALID
+	_, isCheck := table.CheckConstraints[o.Name]
+	_, isForeignKey := table.ForeignKeys[o.Name]
+	if !isCheck && !isForeignKey {
+		return ConstraintCannotBeValidatedError{Table: o.Table, Constraint: o.Name}
+	}
+
+	return nil
+}
+
+// markConstraintValidated marks a check constraint or foreign key of the
+// table as validated.
+func markConstraintValidated(table *schema.Table, name string) {
+	if cc, ok := table.CheckConstraints[name]; ok {
+		cc.NotValid = false
+	}
+	if fk, ok := table.ForeignKeys[name]; ok {
+		fk.NotValid = false
+	}
+}
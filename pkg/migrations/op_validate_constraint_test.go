@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+func TestValidateConstraint(t *testing.T) {
+	t.Parallel()
+
+	createTablesMigration := migrations.Migration{
+		Name: "01_create_tables",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+					{Name: "name", Type: "text"},
+				},
+			},
+			&migrations.OpCreateTable{
+				Name: "posts",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+					{Name: "title", Type: "text"},
+					{Name: "user_id", Type: "integer", Nullable: true},
+				},
+			},
+		},
+	}
+
+	addNotValidConstraintsMigration := func(inserts string) migrations.Migration {
+		return migrations.Migration{
+			Name: "02_add_not_valid_constraints",
+			Operations: migrations.Operations{
+				&migrations.OpRawSQL{
+					Up: `INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob');` + inserts + `
+					ALTER TABLE posts ADD CONSTRAINT title_length CHECK (length(title) > 3) NOT VALID;
+					ALTER TABLE posts ADD CONSTRAINT fk_posts_users FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID;`,
+				},
+			},
+		}
+	}
+
+	validateConstraintMigration := func(table, constraint string) migrations.Migration {
+		return migrations.Migration{
+			Name: "03_validate_constraint",
+			Operations: migrations.Operations{
+				&migrations.OpValidateConstraint{
+					Table: table,
+					Name:  constraint,
+				},
+			},
+		}
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "validate a check constraint",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				addNotValidConstraintsMigration(`INSERT INTO posts (id, title, user_id) VALUES (1, 'hello', 1);`),
+				validateConstraintMigration("posts", "title_length"),
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				// The constraint is validated on completion
+				NotValidatedConstraintMustExist(t, db, schema, "posts", "title_length")
+
+				// Rows that violate the constraint can't be inserted
+				MustNotInsert(t, db, schema, "03_validate_constraint", "posts", map[string]string{
+					"id":    "2",
+					"title": "hi",
+				}, testutils.CheckViolationErrorCode)
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				// The constraint is left NOT VALID
+				NotValidatedConstraintMustExist(t, db, schema, "posts", "title_length")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ValidatedConstraintMustExist(t, db, schema, "posts", "title_length")
+			},
+		},
+		{
+			name: "validate a foreign key",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				addNotValidConstraintsMigration(`INSERT INTO posts (id, title, user_id) VALUES (1, 'hello', 1), (2, 'world', NULL);`),
+				validateConstraintMigration("posts", "fk_posts_users"),
+			},
+			afterStart: func(t *testing.T, db *sql.DB, schema string) {
+				NotValidatedConstraintMustExist(t, db, schema, "posts", "fk_posts_users")
+			},
+			afterRollback: func(t *testing.T, db *sql.DB, schema string) {
+				NotValidatedConstraintMustExist(t, db, schema, "posts", "fk_posts_users")
+			},
+			afterComplete: func(t *testing.T, db *sql.DB, schema string) {
+				ValidatedForeignKeyMustExist(t, db, schema, "posts", "fk_posts_users")
+			},
+		},
+		{
+			name: "rows that violate a check constraint are reported when the migration starts",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				addNotValidConstraintsMigration(`INSERT INTO posts (id, title, user_id) VALUES (1, 'hello', 1), (2, 'hi', 1), (3, 'yo', 2);`),
+				validateConstraintMigration("posts", "title_length"),
+			},
+			wantStartErr: migrations.ConstraintViolationsError{
+				Table:      "posts",
+				Constraint: "title_length",
+				Rows:       2,
+				Values:     "(hi), (yo)",
+			},
+		},
+		{
+			name: "rows that reference missing rows are reported when the migration starts",
+			migrations: []migrations.Migration{
+				createTablesMigration,
+				addNotValidConstraintsMigration(`INSERT INTO posts (id, title, user_id) VALUES (1, 'hello', 1), (2, 'world', 3), (3, 'again', NULL);`),
+				validateConstraintMigration("posts", "fk_posts_users"),
+			},
+			wantStartErr: migrations.ConstraintViolationsError{
+				Table:      "posts",
+				Constraint: "fk_posts_users",
+				Rows:       1,
+				Values:     "(3)",
+			},
+		},
+	})
+}
+
+func TestValidateConstraintValidation(t *testing.T) {
+	t.Parallel()
+
+	createTableMigration := migrations.Migration{
+		Name: "01_create_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "users",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "integer", Pk: true},
+					{Name: "name", Type: "text"},
+				},
+				Constraints: []migrations.Constraint{
+					{
+						Name:    "unique_name",
+						Type:    migrations.ConstraintTypeUnique,
+						Columns: []string{"name"},
+					},
+				},
+			},
+		},
+	}
+
+	ExecuteTests(t, TestCases{
+		{
+			name: "table must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_validate_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpValidateConstraint{
+							Table: "doesntexist",
+							Name:  "unique_name",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.TableDoesNotExistError{Name: "doesntexist"},
+		},
+		{
+			name: "constraint must exist",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_validate_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpValidateConstraint{
+							Table: "users",
+							Name:  "doesntexist",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ConstraintDoesNotExistError{Table: "users", Constraint: "doesntexist"},
+		},
+		{
+			name: "unique constraints can't be validated",
+			migrations: []migrations.Migration{
+				createTableMigration,
+				{
+					Name: "02_validate_constraint",
+					Operations: migrations.Operations{
+						&migrations.OpValidateConstraint{
+							Table: "users",
+							Name:  "unique_name",
+						},
+					},
+				},
+			},
+			wantStartErr: migrations.ConstraintCannotBeValidatedError{Table: "users", Constraint: "unique_name"},
+		},
+	})
+}
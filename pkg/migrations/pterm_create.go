@@ -144,6 +144,10 @@ func (o *OpCreateConstraint) Create() {
 		reference.OnUpdate = getFkAction("on_update")
 		o.References = &reference
 	}
+	if o.Type != OpCreateConstraintTypeCheck {
+		o.Deferrable, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("deferrable").WithDefaultValue(false).Show()
+		o.InitiallyDeferred, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("initially_deferred").WithDefaultValue(false).Show()
+	}
 	upMigrations := make(map[string]string, len(o.Columns))
 	downMigrations := make(map[string]string, len(o.Columns))
 	for _, columnName := range o.Columns {
@@ -212,6 +216,7 @@ func (o *OpDropColumn) Create() {
 	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
 	o.Column, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("column").Show()
 	o.Down, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("down").Show()
+	o.Cascade, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("cascade").Show()
 }
 
 func (o *OpDropMultiColumnConstraint) Create() {
@@ -230,14 +235,167 @@ func (o *OpDropMultiColumnConstraint) Create() {
 	}
 }
 
+func (o *OpAlterDefaultPrivileges) Create() {
+	o.Role, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("role").Show()
+	o.Schema, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("schema").Show()
+	action, _ := pterm.DefaultInteractiveSelect.
+		WithDefaultText("action").
+		WithOptions([]string{"grant", "revoke"}).
+		Show()
+	o.Action = OpAlterDefaultPrivilegesAction(action)
+	objectType, _ := pterm.DefaultInteractiveSelect.
+		WithDefaultText("object_type").
+		WithOptions([]string{"tables", "sequences", "functions", "types"}).
+		Show()
+	o.ObjectType = OpAlterDefaultPrivilegesObjectType(objectType)
+	privileges, _ := pterm.DefaultInteractiveTextInput.WithDefaultText("privileges (comma separated)").Show()
+	for _, p := range strings.Split(privileges, ",") {
+		o.Privileges = append(o.Privileges, strings.TrimSpace(p))
+	}
+	o.Grantee, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("grantee").Show()
+}
+
+func (o *OpSetPrimaryKey) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Index, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("index").Show()
+}
+
+func (o *OpTruncate) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.PreserveData, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("preserve_data").Show()
+	o.RestartIdentity, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("restart_identity").Show()
+	o.Cascade, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("cascade").Show()
+}
+
+func (o *OpAttachInherit) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Parent, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("parent").Show()
+}
+
+func (o *OpDetachInherit) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Parent, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("parent").Show()
+}
+
+func (o *OpCreatePartition) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+	o.Default, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("default").Show()
+	if !o.Default {
+		o.Bound, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("bound").Show()
+	}
+}
+
+func (o *OpValidateConstraint) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+}
+
+func (o *OpAlterTrigger) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+	state, _ := pterm.DefaultInteractiveSelect.
+		WithDefaultText("state").
+		WithOptions([]string{"ENABLE", "DISABLE", "ENABLE REPLICA", "ENABLE ALWAYS"}).
+		Show()
+	o.State = OpAlterTriggerState(state)
+}
+
+func (o *OpCreateForeignTable) Create() {
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+	o.Server, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("server").Show()
+
+	addColumns := true
+	for addColumns {
+		var col ForeignTableColumn
+		col.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+		col.Type, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("type").Show()
+		col.Nullable, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("nullable").Show()
+		o.Columns = append(o.Columns, col)
+
+		addColumns, _ = pterm.DefaultInteractiveConfirm.
+			WithDefaultText("Add more columns").
+			Show()
+	}
+
+	addOptions, _ := pterm.DefaultInteractiveConfirm.
+		WithDefaultText("Add options").
+		Show()
+	for addOptions {
+		if o.Options == nil {
+			o.Options = make(OpCreateForeignTableOptions)
+		}
+		key, _ := pterm.DefaultInteractiveTextInput.WithDefaultText("option").Show()
+		o.Options[key], _ = pterm.DefaultInteractiveTextInput.WithDefaultText("value").Show()
+
+		addOptions, _ = pterm.DefaultInteractiveConfirm.
+			WithDefaultText("Add more options").
+			Show()
+	}
+}
+
+func (o *OpCreateTableAs) Create() {
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+	o.Query, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("query").Show()
+	o.WithNoData, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("with_no_data").Show()
+}
+
+func (o *OpCreateRule) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+	event, _ := pterm.DefaultInteractiveSelect.
+		WithDefaultText("event").
+		WithOptions([]string{"INSERT", "UPDATE", "DELETE"}).
+		Show()
+	o.Event = OpCreateRuleEvent(event)
+	o.Where, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("where").Show()
+	o.Instead, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("instead").Show()
+	o.Command, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("command").Show()
+}
+
+func (o *OpCreateType) Create() {
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+
+	addAttributes, _ := pterm.DefaultInteractiveConfirm.
+		WithDefaultText("Add attributes").
+		WithDefaultValue(true).
+		Show()
+	for addAttributes {
+		var attr CompositeTypeAttribute
+		attr.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+		attr.Type, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("type").Show()
+		o.Attributes = append(o.Attributes, attr)
+
+		addAttributes, _ = pterm.DefaultInteractiveConfirm.
+			WithDefaultText("Add more attributes").
+			Show()
+	}
+
+	o.Cascade, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("cascade").Show()
+}
+
+func (o *OpDropForeignTable) Create() {
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+}
+
 func (o *OpDropIndex) Create() {
 	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
 }
 
+func (o *OpDropRule) Create() {
+	o.Table, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("table").Show()
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+}
+
 func (o *OpDropTable) Create() {
 	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
 }
 
+func (o *OpDropType) Create() {
+	o.Name, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("name").Show()
+	o.Cascade, _ = pterm.DefaultInteractiveConfirm.WithDefaultText("cascade").Show()
+}
+
 func (o *OpRawSQL) Create() {
 	o.Up, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("up").Show()
 	o.Down, _ = pterm.DefaultInteractiveTextInput.WithDefaultText("down").Show()
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// validateEffects checks the schema changes declared by a raw SQL operation
+// against the schema and applies them to it, so that the operations that
+// follow are validated against the schema the SQL produces.
+func validateEffects(e *RawSQLEffects, s *schema.Schema) error {
+	if e == nil {
+		return nil
+	}
+
+	for i, name := range e.DropTables {
+		if name == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("effects.drop_tables[%d]", i)}
+		}
+		if s.GetTable(name) == nil {
+			return TableDoesNotExistError{Name: name}
+		}
+		s.RemoveTable(name)
+	}
+
+	for i, dc := range e.DropColumns {
+		if dc.Table == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("effects.drop_columns[%d].table", i)}
+		}
+		if dc.Column == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("effects.drop_columns[%d].column", i)}
+		}
+		table := s.GetTable(dc.Table)
+		if table == nil {
+			return TableDoesNotExistError{Name: dc.Table}
+		}
+		if table.GetColumn(dc.Column) == nil {
+			return ColumnDoesNotExistError{Table: dc.Table, Name: dc.Column}
+		}
+		table.RemoveColumn(dc.Column)
+	}
+
+	for i, t := range e.AddTables {
+		if t.Name == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("effects.add_tables[%d].name", i)}
+		}
+		if err := ValidateIdentifierLength(t.Name); err != nil {
+			return err
+		}
+		if s.GetTable(t.Name) != nil {
+			return TableAlreadyExistsError{Name: t.Name}
+		}
+		table := &schema.Table{Name: t.Name}
+		for _, col := range t.Columns {
+			if err := validateEffectColumn(t.Name, col); err != nil {
+				return err
+			}
+			if table.GetColumn(col.Name) != nil {
+				return ColumnAlreadyExistsError{Table: t.Name, Name: col.Name}
+			}
+			table.AddColumn(col.Name, effectColumn(col))
+		}
+		s.AddTable(t.Name, table)
+	}
+
+	for i, ac := range e.AddColumns {
+		if ac.Table == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("effects.add_columns[%d].table", i)}
+		}
+		table := s.GetTable(ac.Table)
+		if table == nil {
+			return TableDoesNotExistError{Name: ac.Table}
+		}
+		if err := validateEffectColumn(ac.Table, ac.Column); err != nil {
+			return err
+		}
+		if table.GetColumn(ac.Column.Name) != nil {
+			return ColumnAlreadyExistsError{Table: ac.Table, Name: ac.Column.Name}
+		}
+		table.AddColumn(ac.Column.Name, effectColumn(ac.Column))
+	}
+
+	return nil
+}
+
+func validateEffectColumn(table string, col RawSQLColumn) error {
+	if col.Name == "" || col.Type == "" {
+		return ColumnIsInvalidError{Table: table, Name: col.Name}
+	}
+	return ValidateIdentifierLength(col.Name)
+}
+
+func effectColumn(col RawSQLColumn) *schema.Column {
+	return &schema.Column{
+		Name:     col.Name,
+		Type:     col.Type,
+		Nullable: col.Nullable,
+	}
+}
+
+// EffectConflicts returns a description of each schema change declared in the
+// effects of the operation that isn't found in the schema after its SQL has
+// run. Column types aren't compared, as the same type can be spelled in more
+// than one way.
+func (o *OpRawSQL) EffectConflicts(s *schema.Schema) []string {
+	if o.Effects == nil {
+		return nil
+	}
+
+	var conflicts []string
+	checkColumn := func(name string, table *schema.Table, col RawSQLColumn) {
+		actual := table.GetColumn(col.Name)
+		switch {
+		case actual == nil:
+			conflicts = append(conflicts, fmt.Sprintf("column %q declared as added to table %q does not exist", col.Name, name))
+		case actual.Nullable != col.Nullable:
+			conflicts = append(conflicts, fmt.Sprintf("column %q on table %q is declared with nullable %t but is nullable %t", col.Name, name, col.Nullable, actual.Nullable))
+		}
+	}
+
+	for _, name := range o.Effects.DropTables {
+		if s.GetTable(name) != nil {
+			conflicts = append(conflicts, fmt.Sprintf("table %q declared as dropped still exists", name))
+		}
+	}
+	for _, dc := range o.Effects.DropColumns {
+		if table := s.GetTable(dc.Table); table != nil && table.GetColumn(dc.Column) != nil {
+			conflicts = append(conflicts, fmt.Sprintf("column %q declared as dropped from table %q still exists", dc.Column, dc.Table))
+		}
+	}
+	for _, t := range o.Effects.AddTables {
+		table := s.GetTable(t.Name)
+		if table == nil {
+			conflicts = append(conflicts, fmt.Sprintf("table %q declared as added does not exist", t.Name))
+			continue
+		}
+		for _, col := range t.Columns {
+			checkColumn(t.Name, table, col)
+		}
+	}
+	for _, ac := range o.Effects.AddColumns {
+		table := s.GetTable(ac.Table)
+		if table == nil {
+			conflicts = append(conflicts, fmt.Sprintf("table %q with column %q declared as added does not exist", ac.Table, ac.Column.Name))
+			continue
+		}
+		checkColumn(ac.Table, table, ac.Column)
+	}
+
+	return conflicts
+}
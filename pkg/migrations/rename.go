@@ -20,7 +20,6 @@ import (
 type renameDuplicatedColumnAction struct {
 	conn  db.DB
 	table *schema.Table
-	from  string
 	to    string
 }
 
@@ -28,7 +27,6 @@ func NewRenameDuplicatedColumnAction(conn db.DB, table *schema.Table, column str
 	return &renameDuplicatedColumnAction{
 		conn:  conn,
 		table: table,
-		from:  TemporaryName(column),
 		to:    column,
 	}
 }
@@ -38,7 +36,10 @@ func (a *renameDuplicatedColumnAction) Execute(ctx context.Context) error {
 		cRenameIndexSQL = `ALTER INDEX IF EXISTS %s RENAME TO %s`
 	)
 
-	err := NewRenameColumnAction(a.conn, a.table.Name, a.from, a.to).Execute(ctx)
+	naming := NamingFrom(ctx)
+	from := naming.TemporaryName(a.to)
+
+	err := NewRenameColumnAction(a.conn, a.table.Name, from, a.to).Execute(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to rename duplicated column %q: %w", a.to, err)
 	}
@@ -46,12 +47,12 @@ func (a *renameDuplicatedColumnAction) Execute(ctx context.Context) error {
 	// Rename any foreign keys on the duplicated column from their temporary name
 	// to their original name
 	for _, fk := range a.table.ForeignKeys {
-		if !IsDuplicatedName(fk.Name) {
+		if !naming.IsDuplicatedName(fk.Name) {
 			continue
 		}
 
-		if slices.Contains(fk.Columns, a.from) {
-			err = NewRenameConstraintAction(a.conn, a.table.Name, fk.Name, StripDuplicationPrefix(fk.Name)).Execute(ctx)
+		if slices.Contains(fk.Columns, from) {
+			err = NewRenameConstraintAction(a.conn, a.table.Name, fk.Name, naming.StripDuplicationPrefix(fk.Name)).Execute(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to rename foreign key constraint %q: %w", fk.Name, err)
 			}
@@ -62,17 +63,17 @@ func (a *renameDuplicatedColumnAction) Execute(ctx context.Context) error {
 	// Validate and rename any temporary `CHECK` constraints on the duplicated
 	// column.
 	for _, cc := range a.table.CheckConstraints {
-		if !IsDuplicatedName(cc.Name) {
+		if !naming.IsDuplicatedName(cc.Name) {
 			continue
 		}
 
-		if slices.Contains(cc.Columns, a.from) {
+		if slices.Contains(cc.Columns, from) {
 			err := NewValidateConstraintAction(a.conn, a.table.Name, cc.Name).Execute(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to validate check constraint %q: %w", cc.Name, err)
 			}
 
-			err = NewRenameConstraintAction(a.conn, a.table.Name, cc.Name, StripDuplicationPrefix(cc.Name)).Execute(ctx)
+			err = NewRenameConstraintAction(a.conn, a.table.Name, cc.Name, naming.StripDuplicationPrefix(cc.Name)).Execute(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to rename check constraint %q: %w", cc.Name, err)
 			}
@@ -81,14 +82,14 @@ func (a *renameDuplicatedColumnAction) Execute(ctx context.Context) error {
 			// If the constraint is a `NOT NULL` constraint, convert the duplicated
 			// unchecked `NOT NULL` constraint into a `NOT NULL` attribute on the
 			// column.
-			if IsNotNullConstraintName(StripDuplicationPrefix(cc.Name)) {
+			if naming.IsNotNullConstraintName(naming.StripDuplicationPrefix(cc.Name)) {
 				// Apply `NOT NULL` attribute to the column. This uses the validated constraint
 				if err := NewSetNotNullAction(a.conn, a.table.Name, a.to).Execute(ctx); err != nil {
 					return fmt.Errorf("failed to set column not null: %w", err)
 				}
 
 				// Drop the constraint
-				err = NewDropConstraintAction(a.conn, a.table.Name, NotNullConstraintName(a.to)).Execute(ctx)
+				err = NewDropConstraintAction(a.conn, a.table.Name, naming.NotNullConstraintName(a.to)).Execute(ctx)
 				if err != nil {
 					return fmt.Errorf("failed to drop not null constraint: %w", err)
 				}
@@ -99,14 +100,14 @@ func (a *renameDuplicatedColumnAction) Execute(ctx context.Context) error {
 	// Rename any indexes on the duplicated column and use unique indexes to
 	// create `UNIQUE` constraints.
 	for _, idx := range a.table.Indexes {
-		if !IsDuplicatedName(idx.Name) || !slices.Contains(idx.Columns, a.from) {
+		if !naming.IsDuplicatedName(idx.Name) || !slices.Contains(idx.Columns, from) {
 			continue
 		}
 
 		// Rename the index to its original name
 		renameIndexSQL := fmt.Sprintf(cRenameIndexSQL,
 			pq.QuoteIdentifier(idx.Name),
-			pq.QuoteIdentifier(StripDuplicationPrefix(idx.Name)),
+			pq.QuoteIdentifier(naming.StripDuplicationPrefix(idx.Name)),
 		)
 
 		_, err = a.conn.ExecContext(ctx, renameIndexSQL)
@@ -117,12 +118,15 @@ func (a *renameDuplicatedColumnAction) Execute(ctx context.Context) error {
 		// Index no longer exists, remove it from the table
 		delete(a.table.Indexes, idx.Name)
 
-		if _, ok := a.table.UniqueConstraints[StripDuplicationPrefix(idx.Name)]; idx.Unique && ok {
-			// Create a unique constraint using the unique index
+		if uc, ok := a.table.UniqueConstraints[naming.StripDuplicationPrefix(idx.Name)]; idx.Unique && ok {
+			// Create a unique constraint using the unique index, keeping the
+			// deferrability of the original constraint
 			err := NewAddConstraintUsingUniqueIndex(a.conn,
 				a.table.Name,
-				StripDuplicationPrefix(idx.Name),
-				StripDuplicationPrefix(idx.Name),
+				naming.StripDuplicationPrefix(idx.Name),
+				naming.StripDuplicationPrefix(idx.Name),
+				uc.InitiallyDeferred,
+				uc.Deferrable,
 			).Execute(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to create unique constraint from index %q: %w", idx.Name, err)
@@ -131,7 +135,7 @@ func (a *renameDuplicatedColumnAction) Execute(ctx context.Context) error {
 	}
 
 	if slices.Contains(a.table.PrimaryKey, a.to) {
-		err := NewAddPrimaryKeyAction(a.conn, a.table.Name, primaryKeyName(a.table.Name)).Execute(ctx)
+		err := NewAddPrimaryKeyAction(a.conn, a.table.Name, primaryKeyName(a.table.Name), false, false).Execute(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to re-add primary key constraint: %w", err)
 		}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by tools/build-schema-defaults. DO NOT EDIT.
+
+package migrations
+
+// operationDefaults maps operation names to the default values of their fields.
+var operationDefaults = map[OpName]*defaultsNode{
+	"add_column":           defaultsOpAddColumn,
+	"alter_column":         defaultsOpAlterColumn,
+	"create_index":         defaultsOpCreateIndex,
+	"create_table":         defaultsOpCreateTable,
+	"drop_column":          defaultsOpDropColumn,
+	"drop_constraint":      defaultsOpDropConstraint,
+	"sql":                  defaultsOpRawSQL,
+	"create_constraint":    defaultsOpCreateConstraint,
+	"create_type":          defaultsOpCreateType,
+	"drop_type":            defaultsOpDropType,
+	"create_foreign_table": defaultsOpCreateForeignTable,
+	"create_table_as":      defaultsOpCreateTableAs,
+	"create_rule":          defaultsOpCreateRule,
+	"truncate":             defaultsOpTruncate,
+	"create_partition":     defaultsOpCreatePartition,
+}
+
+var defaultsOpAddColumn = &defaultsNode{
+	defaults: map[string]any{
+		"up": "",
+	},
+	properties: map[string]*defaultsNode{
+		"column": defaultsColumn,
+	},
+}
+
+var defaultsOpAlterColumn = &defaultsNode{
+	defaults: map[string]any{
+		"down": "",
+		"up":   "",
+	},
+	properties: map[string]*defaultsNode{
+		"check":      defaultsCheckConstraint,
+		"jsonb":      defaultsJsonbTransform,
+		"references": defaultsForeignKeyReference,
+	},
+}
+
+var defaultsOpCreateConstraint = &defaultsNode{
+	defaults: map[string]any{
+		"deferrable":         false,
+		"initially_deferred": false,
+		"no_inherit":         false,
+	},
+	properties: map[string]*defaultsNode{
+		"index_parameters": &defaultsNode{
+			defaults: map[string]any{
+				"storage_parameters": "",
+				"tablespace":         "",
+			},
+		},
+		"references": defaultsTableForeignKeyReference,
+	},
+}
+
+var defaultsOpCreateForeignTable = &defaultsNode{
+	properties: map[string]*defaultsNode{
+		"columns": defaultsForeignTableColumn,
+	},
+}
+
+var defaultsOpCreateIndex = &defaultsNode{
+	defaults: map[string]any{
+		"method":             "btree",
+		"predicate":          "",
+		"storage_parameters": "",
+		"unique":             false,
+	},
+}
+
+var defaultsOpCreatePartition = &defaultsNode{
+	defaults: map[string]any{
+		"default": false,
+	},
+}
+
+var defaultsOpCreateRule = &defaultsNode{
+	defaults: map[string]any{
+		"instead": false,
+	},
+}
+
+var defaultsOpCreateTable = &defaultsNode{
+	properties: map[string]*defaultsNode{
+		"columns":     defaultsColumn,
+		"constraints": defaultsConstraint,
+		"partitions":  defaultsPartition,
+	},
+}
+
+var defaultsOpCreateTableAs = &defaultsNode{
+	defaults: map[string]any{
+		"with_no_data": false,
+	},
+}
+
+var defaultsOpCreateType = &defaultsNode{
+	defaults: map[string]any{
+		"cascade": false,
+	},
+}
+
+var defaultsOpDropColumn = &defaultsNode{
+	defaults: map[string]any{
+		"cascade": false,
+		"down":    "",
+	},
+}
+
+var defaultsOpDropConstraint = &defaultsNode{
+	defaults: map[string]any{
+		"down": "",
+	},
+}
+
+var defaultsOpDropType = &defaultsNode{
+	defaults: map[string]any{
+		"cascade": false,
+	},
+}
+
+var defaultsOpRawSQL = &defaultsNode{
+	defaults: map[string]any{
+		"down":       "",
+		"onComplete": false,
+	},
+	properties: map[string]*defaultsNode{
+		"effects": defaultsRawSQLEffects,
+	},
+}
+
+var defaultsOpTruncate = &defaultsNode{
+	defaults: map[string]any{
+		"cascade":          false,
+		"preserve_data":    false,
+		"restart_identity": false,
+	},
+}
+
+var defaultsCheckConstraint = &defaultsNode{
+	defaults: map[string]any{
+		"no_inherit": false,
+	},
+}
+
+var defaultsColumn = &defaultsNode{
+	defaults: map[string]any{
+		"nullable": false,
+		"pk":       false,
+		"unique":   false,
+	},
+	properties: map[string]*defaultsNode{
+		"check": defaultsCheckConstraint,
+		"generated": &defaultsNode{
+			defaults: map[string]any{
+				"expression": "",
+			},
+			properties: map[string]*defaultsNode{
+				"identity": &defaultsNode{
+					defaults: map[string]any{
+						"sequence_options":      "",
+						"user_specified_values": "ALWAYS",
+					},
+				},
+			},
+		},
+		"references": defaultsForeignKeyReference,
+	},
+}
+
+var defaultsConstraint = &defaultsNode{
+	defaults: map[string]any{
+		"check":              "",
+		"deferrable":         false,
+		"initially_deferred": false,
+		"no_inherit":         false,
+		"nulls_not_distinct": false,
+	},
+	properties: map[string]*defaultsNode{
+		"exclude": &defaultsNode{
+			defaults: map[string]any{
+				"elements":     "",
+				"index_method": "",
+				"predicate":    "",
+			},
+		},
+		"index_parameters": &defaultsNode{
+			defaults: map[string]any{
+				"storage_parameters": "",
+				"tablespace":         "",
+			},
+		},
+		"references": defaultsTableForeignKeyReference,
+	},
+}
+
+var defaultsForeignKeyReference = &defaultsNode{
+	defaults: map[string]any{
+		"deferrable":         false,
+		"initially_deferred": false,
+		"match_type":         "SIMPLE",
+		"on_delete":          "NO ACTION",
+		"on_update":          "NO ACTION",
+	},
+}
+
+var defaultsForeignTableColumn = &defaultsNode{
+	defaults: map[string]any{
+		"nullable": false,
+	},
+}
+
+var defaultsJsonbTransform = &defaultsNode{
+	properties: map[string]*defaultsNode{
+		"down": defaultsJsonbPathOperation,
+		"up":   defaultsJsonbPathOperation,
+	},
+}
+
+var defaultsPartition = &defaultsNode{
+	defaults: map[string]any{
+		"default": false,
+	},
+}
+
+var defaultsRawSQLEffects = &defaultsNode{
+	properties: map[string]*defaultsNode{
+		"add_columns": defaultsRawSQLAddedColumn,
+		"add_tables":  defaultsRawSQLTable,
+	},
+}
+
+var defaultsTableForeignKeyReference = &defaultsNode{
+	defaults: map[string]any{
+		"match_type": "SIMPLE",
+		"on_delete":  "NO ACTION",
+		"on_update":  "NO ACTION",
+		"validate":   "deferred",
+	},
+}
+
+var defaultsJsonbPathOperation = &defaultsNode{
+	defaults: map[string]any{
+		"value": "",
+	},
+}
+
+var defaultsRawSQLAddedColumn = &defaultsNode{
+	properties: map[string]*defaultsNode{
+		"column": defaultsRawSQLColumn,
+	},
+}
+
+var defaultsRawSQLTable = &defaultsNode{
+	properties: map[string]*defaultsNode{
+		"columns": defaultsRawSQLColumn,
+	},
+}
+
+var defaultsRawSQLColumn = &defaultsNode{
+	defaults: map[string]any{
+		"nullable": false,
+	},
+}
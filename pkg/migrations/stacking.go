@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+)
+
+// touchedObjects are the tables, columns and types that the operations of a
+// migration create, change or depend on.
+type touchedObjects struct {
+	// columns maps each table to the columns of it that are touched. A table
+	// with no columns is touched as a whole.
+	columns map[string]map[string]bool
+	types   map[string]bool
+
+	// opaque is the name of the first operation whose objects can't be
+	// determined, if any.
+	opaque OpName
+}
+
+// StackingConflict returns a MigrationConflictError if the migration touches
+// any table or type that is touched by `active`, a migration that has been
+// started but not completed. Migrations that touch disjoint tables can be
+// active at the same time, each with its own version schema. The conflict is
+// reported on the most specific object that the two migrations share.
+func (m *Migration) StackingConflict(active *Migration) error {
+	ours := m.touchedObjects()
+	theirs := active.touchedObjects()
+
+	for _, opaque := range []OpName{theirs.opaque, ours.opaque} {
+		if opaque != "" {
+			return MigrationConflictError{
+				Migration: active.Name,
+				Object:    fmt.Sprintf("the objects of a %q operation, which can't be determined", opaque),
+			}
+		}
+	}
+
+	for _, table := range slices.Sorted(maps.Keys(ours.columns)) {
+		theirColumns, ok := theirs.columns[table]
+		if !ok {
+			continue
+		}
+		for _, column := range slices.Sorted(maps.Keys(ours.columns[table])) {
+			if theirColumns[column] {
+				return MigrationConflictError{
+					Migration: active.Name,
+					Object:    fmt.Sprintf("column %q of table %q", column, table),
+				}
+			}
+		}
+		return MigrationConflictError{
+			Migration: active.Name,
+			Object:    fmt.Sprintf("table %q", table),
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(ours.types)) {
+		if theirs.types[name] {
+			return MigrationConflictError{
+				Migration: active.Name,
+				Object:    fmt.Sprintf("type %q", name),
+			}
+		}
+	}
+
+	return nil
+}
+
+// touchedObjects returns the tables, columns and types touched by the
+// operations of the migration. Tables referenced by foreign keys and by the
+// subqueries of up and down SQL are touched too, as their columns are read
+// while the migration is active.
+func (m *Migration) touchedObjects() touchedObjects {
+	t := touchedObjects{
+		columns: make(map[string]map[string]bool),
+		types:   make(map[string]bool),
+	}
+	table := func(name string, columns ...string) {
+		if name == "" {
+			return
+		}
+		if t.columns[name] == nil {
+			t.columns[name] = make(map[string]bool)
+		}
+		for _, column := range columns {
+			t.columns[name][column] = true
+		}
+	}
+	sql := func(expressions ...string) {
+		for _, expr := range expressions {
+			for _, name := range sqlTables("SELECT " + expr) {
+				table(name)
+			}
+		}
+	}
+
+	for _, op := range m.Operations {
+		for _, dep := range append(createdObjects(op), requiredObjects(op)...) {
+			if dep.kind == "table" {
+				table(dep.name)
+			}
+		}
+
+		switch o := op.(type) {
+		case *OpAddColumn:
+			table(o.Table, o.Column.Name)
+			sql(o.Up)
+		case *OpAlterColumn:
+			table(o.Table, o.Column)
+			sql(o.Up, o.Down)
+		case *OpDropColumn:
+			table(o.Table, o.Column)
+			sql(o.Down)
+		case *OpRenameColumn:
+			table(o.Table, o.From, o.To)
+		case *OpCreateIndex:
+			table(o.Table, slices.Collect(maps.Keys(o.Columns))...)
+		case *OpCreateConstraint:
+			table(o.Table, o.Columns...)
+			sql(slices.Collect(maps.Values(o.Up))...)
+			sql(slices.Collect(maps.Values(o.Down))...)
+		case *OpDropMultiColumnConstraint:
+			table(o.Table, slices.Collect(maps.Keys(o.Down))...)
+		case *OpSetPrimaryKey:
+			table(o.Table)
+		case *OpCreateTableAs:
+			table(o.Name)
+			for _, name := range sqlTables(o.Query) {
+				table(name)
+			}
+		case *OpCreateType:
+			t.types[o.Name] = true
+		case *OpDropType:
+			t.types[o.Name] = true
+		case *OpRawSQL, *OpDropIndex:
+			// raw SQL can touch any object, and an index is dropped by name
+			// without naming its table
+			if t.opaque == "" {
+				t.opaque = OperationName(op)
+			}
+		}
+	}
+
+	return t
+}
+
+// sqlTables returns the unqualified tables referenced by the SQL statement.
+// SQL that can't be parsed references no tables.
+func sqlTables(sql string) []string {
+	jsonTree, err := pgq.ParseToJSON(sql)
+	if err != nil {
+		return nil
+	}
+	var node any
+	if err := json.Unmarshal([]byte(jsonTree), &node); err != nil {
+		return nil
+	}
+	return tableReferences(node)
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackingConflict(t *testing.T) {
+	t.Parallel()
+
+	active := &Migration{
+		Name: "01_add_column",
+		Operations: Operations{
+			&OpAddColumn{
+				Table:  "users",
+				Up:     "(SELECT name FROM teams WHERE teams.id = team_id)",
+				Column: Column{Name: "team_name", Type: "text", Nullable: true},
+			},
+			&OpCreateType{Name: "mood"},
+		},
+	}
+
+	tests := map[string]struct {
+		migration *Migration
+		wantErr   error
+	}{
+		"disjoint tables": {
+			migration: &Migration{
+				Name: "02_add_column",
+				Operations: Operations{
+					&OpAddColumn{
+						Table:  "products",
+						Column: Column{Name: "price", Type: "integer", Nullable: true},
+					},
+					&OpCreateTable{
+						Name:    "orders",
+						Columns: []Column{{Name: "id", Type: "serial", Pk: true}},
+					},
+				},
+			},
+		},
+		"same column": {
+			migration: &Migration{
+				Name: "02_alter_column",
+				Operations: Operations{
+					&OpAlterColumn{
+						Table:  "users",
+						Column: "team_name",
+						Type:   ptr("varchar(255)"),
+						Up:     "team_name",
+						Down:   "team_name",
+					},
+				},
+			},
+			wantErr: MigrationConflictError{Migration: "01_add_column", Object: `column "team_name" of table "users"`},
+		},
+		"same table": {
+			migration: &Migration{
+				Name: "02_create_index",
+				Operations: Operations{
+					&OpCreateIndex{
+						Name:    "idx_users_email",
+						Table:   "users",
+						Columns: OpCreateIndexColumns{"email": {}},
+					},
+				},
+			},
+			wantErr: MigrationConflictError{Migration: "01_add_column", Object: `table "users"`},
+		},
+		"table read by up SQL": {
+			migration: &Migration{
+				Name: "02_drop_column",
+				Operations: Operations{
+					&OpDropColumn{Table: "teams", Column: "name"},
+				},
+			},
+			wantErr: MigrationConflictError{Migration: "01_add_column", Object: `table "teams"`},
+		},
+		"table referenced by a foreign key": {
+			migration: &Migration{
+				Name: "02_create_table",
+				Operations: Operations{
+					&OpCreateTable{
+						Name: "posts",
+						Columns: []Column{
+							{Name: "id", Type: "serial", Pk: true},
+							{Name: "user_id", Type: "integer", References: &ForeignKeyReference{Name: "fk_posts_users", Table: "users", Column: "id"}},
+						},
+					},
+				},
+			},
+			wantErr: MigrationConflictError{Migration: "01_add_column", Object: `table "users"`},
+		},
+		"same type": {
+			migration: &Migration{
+				Name: "02_drop_type",
+				Operations: Operations{
+					&OpDropType{Name: "mood"},
+				},
+			},
+			wantErr: MigrationConflictError{Migration: "01_add_column", Object: `type "mood"`},
+		},
+		"raw SQL": {
+			migration: &Migration{
+				Name: "02_sql",
+				Operations: Operations{
+					&OpRawSQL{Up: "CREATE TABLE audit (id integer)"},
+				},
+			},
+			wantErr: MigrationConflictError{Migration: "01_add_column", Object: `the objects of a "sql" operation, which can't be determined`},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.migration.StackingConflict(active)
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"encoding/json"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// validateSubqueryTables checks that the tables that the subqueries of an up
+// or down SQL expression select from exist in the schema. Tables qualified
+// with a schema and the names of common table expressions are not checked.
+// Expressions that can't be parsed are left for Postgres to reject when the
+// trigger that evaluates them is created.
+func validateSubqueryTables(s *schema.Schema, sql string) error {
+	jsonTree, err := pgq.ParseToJSON("SELECT " + sql)
+	if err != nil {
+		return nil
+	}
+	var node any
+	if err := json.Unmarshal([]byte(jsonTree), &node); err != nil {
+		return nil
+	}
+
+	ctes := make(map[string]bool)
+	for _, name := range cteNames(node) {
+		ctes[name] = true
+	}
+
+	for _, table := range tableReferences(node) {
+		if ctes[table] {
+			continue
+		}
+		if s.GetTable(table) == nil {
+			return TableDoesNotExistError{Name: table}
+		}
+	}
+	return nil
+}
+
+// tableReferences returns the names of the unqualified tables referenced in
+// the JSON representation of a parse tree.
+func tableReferences(node any) []string {
+	var tables []string
+
+	switch n := node.(type) {
+	case map[string]any:
+		if rv, ok := n["RangeVar"].(map[string]any); ok {
+			if _, qualified := rv["schemaname"]; !qualified {
+				if name, ok := rv["relname"].(string); ok {
+					tables = append(tables, name)
+				}
+			}
+			return tables
+		}
+		for _, v := range n {
+			tables = append(tables, tableReferences(v)...)
+		}
+	case []any:
+		for _, v := range n {
+			tables = append(tables, tableReferences(v)...)
+		}
+	}
+
+	return tables
+}
+
+// cteNames returns the names of the common table expressions defined in the
+// JSON representation of a parse tree.
+func cteNames(node any) []string {
+	var names []string
+
+	switch n := node.(type) {
+	case map[string]any:
+		if cte, ok := n["CommonTableExpr"].(map[string]any); ok {
+			if name, ok := cte["ctename"].(string); ok {
+				names = append(names, name)
+			}
+		}
+		for _, v := range n {
+			names = append(names, cteNames(v)...)
+		}
+	case []any:
+		for _, v := range n {
+			names = append(names, cteNames(v)...)
+		}
+	}
+
+	return names
+}
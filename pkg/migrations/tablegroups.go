@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import "slices"
+
+// TableGroups partitions the operations of the migration into groups that
+// touch disjoint tables and types, so that each group can be committed on its
+// own. Each group holds the indexes of its operations in migration order, and
+// the groups are ordered by their first operation. Operations whose objects
+// can't be determined, or that touch no table or type, are groups of their own
+// and no group spans them, so they run after every operation before them and
+// before every operation after them.
+func (m *Migration) TableGroups() [][]int {
+	// types created by the migration order the operations that use them
+	created := make(map[string]bool)
+	for _, op := range m.Operations {
+		for _, dep := range createdObjects(op) {
+			if dep.kind == "type" {
+				created[dep.name] = true
+			}
+		}
+	}
+
+	var groups [][]int
+	// group maps each object touched since the last barrier to its group
+	group := make(map[string]int)
+	for i, op := range m.Operations {
+		keys := objectKeys(op, created)
+		if keys == nil {
+			groups = append(groups, []int{i})
+			clear(group)
+			continue
+		}
+
+		// merge the groups of all the objects touched by the operation into
+		// the earliest of them
+		target := -1
+		for _, key := range keys {
+			if g, ok := group[key]; ok && (target == -1 || g < target) {
+				target = g
+			}
+		}
+		if target == -1 {
+			groups = append(groups, nil)
+			target = len(groups) - 1
+		}
+		for _, key := range keys {
+			g, ok := group[key]
+			if !ok || g == target {
+				continue
+			}
+			groups[target] = append(groups[target], groups[g]...)
+			groups[g] = nil
+			for k, kg := range group {
+				if kg == g {
+					group[k] = target
+				}
+			}
+		}
+		groups[target] = append(groups[target], i)
+		for _, key := range keys {
+			group[key] = target
+		}
+	}
+
+	result := make([][]int, 0, len(groups))
+	for _, g := range groups {
+		if len(g) > 0 {
+			slices.Sort(g)
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// objectKeys returns keys for the tables and types touched by the operation,
+// including the tables it depends on and the types created by the migration
+// that it uses, or nil if the operation touches no objects or its objects
+// can't be determined.
+func objectKeys(op Operation, created map[string]bool) []string {
+	switch o := op.(type) {
+	case *OpRawSQL, *OpDropIndex:
+		// raw SQL can touch any object, and an index is dropped by name
+		// without naming its table
+		return nil
+	case *OpDropType:
+		return []string{"type:" + o.Name}
+	}
+
+	var keys []string
+	for _, dep := range append(createdObjects(op), requiredObjects(op)...) {
+		switch {
+		case dep.kind == "table":
+			keys = append(keys, "table:"+dep.name)
+		case dep.kind == "type" && created[dep.name]:
+			keys = append(keys, "type:"+dep.name)
+		}
+	}
+	return keys
+}
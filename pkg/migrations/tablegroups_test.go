@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableGroups(t *testing.T) {
+	t.Parallel()
+
+	addColumn := func(table, column string) *OpAddColumn {
+		return &OpAddColumn{
+			Table:  table,
+			Column: Column{Name: column, Type: "text", Nullable: true},
+		}
+	}
+
+	tests := map[string]struct {
+		operations Operations
+		want       [][]int
+	}{
+		"disjoint tables": {
+			operations: Operations{
+				addColumn("users", "name"),
+				addColumn("products", "name"),
+				addColumn("users", "email"),
+			},
+			want: [][]int{{0, 2}, {1}},
+		},
+		"operations joined by a foreign key": {
+			operations: Operations{
+				addColumn("users", "name"),
+				addColumn("products", "name"),
+				&OpCreateConstraint{
+					Name:       "fk_products_users",
+					Type:       OpCreateConstraintTypeForeignKey,
+					Table:      "products",
+					Columns:    []string{"user_id"},
+					References: &TableForeignKeyReference{Table: "users", Columns: []string{"id"}},
+				},
+			},
+			want: [][]int{{0, 1, 2}},
+		},
+		"operations joined by a type created by the migration": {
+			operations: Operations{
+				&OpCreateType{Name: "mood"},
+				addColumn("products", "name"),
+				&OpAddColumn{
+					Table:  "users",
+					Column: Column{Name: "mood", Type: "mood", Nullable: true},
+				},
+			},
+			want: [][]int{{0, 2}, {1}},
+		},
+		"raw SQL is a barrier": {
+			operations: Operations{
+				addColumn("users", "name"),
+				&OpRawSQL{Up: "SELECT 1"},
+				addColumn("users", "email"),
+				addColumn("products", "name"),
+			},
+			want: [][]int{{0}, {1}, {2}, {3}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &Migration{Name: "01_migration", Operations: tt.operations}
+			assert.Equal(t, tt.want, m.TableGroups())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	pgq "github.com/xataio/pg_query_go/v6"
+)
+
+// tempTablesSQL returns the statements that create the temporary tables ahead
+// of the SQL that uses them. The tables are dropped when the transaction that
+// creates them commits, so that they never outlive the operation on the
+// pooled connection that runs it.
+func tempTablesSQL(tables []TempTable, sql string) string {
+	if len(tables) == 0 {
+		return sql
+	}
+
+	var b strings.Builder
+	for _, t := range tables {
+		fmt.Fprintf(&b, "CREATE TEMPORARY TABLE %s ON COMMIT DROP AS %s;\n", pq.QuoteIdentifier(t.Name), t.Query)
+	}
+	b.WriteString(sql)
+	return b.String()
+}
+
+// validateTempTables checks the temporary tables declared by a raw SQL
+// operation and that its up SQL runs in the transaction that creates them.
+func validateTempTables(tables []TempTable, up string) error {
+	names := make(map[string]bool, len(tables))
+	for i, t := range tables {
+		if t.Name == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("temp_tables[%d].name", i)}
+		}
+		if err := ValidateIdentifierLength(t.Name); err != nil {
+			return err
+		}
+		if t.Query == "" {
+			return FieldRequiredError{Name: fmt.Sprintf("temp_tables[%d].query", i)}
+		}
+		if names[t.Name] {
+			return InvalidMigrationError{Reason: fmt.Sprintf("temporary table %q is declared more than once", t.Name)}
+		}
+		names[t.Name] = true
+	}
+
+	if len(tables) > 0 && controlsTransactions(up) {
+		return InvalidMigrationError{Reason: "up can't begin or end transactions when temp_tables are set, as the temporary tables are dropped when the transaction that creates them commits"}
+	}
+
+	return nil
+}
+
+// controlsTransactions returns true if the SQL contains a transaction control
+// statement, such as BEGIN or COMMIT. SQL that can't be parsed is left for
+// Postgres to reject.
+func controlsTransactions(sql string) bool {
+	tree, err := pgq.Parse(sql)
+	if err != nil {
+		return false
+	}
+	for _, stmt := range tree.GetStmts() {
+		if stmt.GetStmt().GetTransactionStmt() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// persistentTempTables returns the names of the temporary tables created by
+// the SQL that are not dropped when the transaction that creates them commits.
+// Such tables remain on the pooled connection that ran the SQL, where they can
+// clash with the tables created by a later migration that happens to run on
+// the same connection. SQL that can't be parsed is ignored.
+func persistentTempTables(sql string) []string {
+	tree, err := pgq.Parse(sql)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	add := func(rel *pgq.RangeVar, onCommit pgq.OnCommitAction) {
+		if rel.GetRelpersistence() == "t" && onCommit != pgq.OnCommitAction_ONCOMMIT_DROP {
+			names = append(names, rel.GetRelname())
+		}
+	}
+	for _, stmt := range tree.GetStmts() {
+		node := stmt.GetStmt()
+		switch {
+		case node.GetCreateStmt() != nil:
+			add(node.GetCreateStmt().GetRelation(), node.GetCreateStmt().GetOncommit())
+		case node.GetCreateTableAsStmt() != nil:
+			into := node.GetCreateTableAsStmt().GetInto()
+			add(into.GetRel(), into.GetOnCommit())
+		case node.GetSelectStmt().GetIntoClause() != nil:
+			into := node.GetSelectStmt().GetIntoClause()
+			add(into.GetRel(), into.GetOnCommit())
+		}
+	}
+	return names
+}
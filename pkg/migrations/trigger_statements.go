@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+)
+
+// validateTriggerStatements checks that the statements that an operation
+// appends to the trigger function of a column are a list of PL/pgSQL
+// statements that can be inserted into the function without changing its
+// structure. The statements may only assign to the fields of NEW, other than
+// the columns that pgroll creates, and to variables that they declare
+// themselves; they may not refer to OLD, which is not set when the trigger
+// fires on an insert, or return from the function, which would skip the rest
+// of it. The columns that pgroll creates are those named with the naming of
+// the context.
+func validateTriggerStatements(ctx context.Context, table, column, stmts string) error {
+	invalid := func(reason string) error {
+		return InvalidTriggerStatementsError{Table: table, Column: column, Reason: reason}
+	}
+
+	// The generated function's body is quoted with $$
+	if strings.Contains(stmts, "$$") {
+		return invalid("they must not contain $$")
+	}
+
+	// Parsing the statements as the body of a trigger function checks that
+	// they are complete statements, that blocks and control structures are
+	// closed and that they only assign to known variables
+	jsonTree, err := pgq.ParsePlPgSqlToJSON("CREATE FUNCTION pgroll_trigger_statements() RETURNS trigger LANGUAGE plpgsql AS $pgroll$ BEGIN\n" +
+		stmts + "\nEND; $pgroll$")
+	if err != nil {
+		return invalid(err.Error())
+	}
+
+	var tree []struct {
+		Function struct {
+			NewVarno int              `json:"new_varno"`
+			OldVarno int              `json:"old_varno"`
+			Datums   []map[string]any `json:"datums"`
+			Action   any              `json:"action"`
+		} `json:"PLpgSQL_function"`
+	}
+	if err := json.Unmarshal([]byte(jsonTree), &tree); err != nil || len(tree) != 1 {
+		return invalid("they can't be parsed")
+	}
+	fn := tree[0].Function
+
+	// Record fields are the only datums that can refer to OLD or to pgroll's
+	// columns
+	for _, datum := range fn.Datums {
+		field, ok := datum["PLpgSQL_recfield"].(map[string]any)
+		if !ok {
+			continue
+		}
+		parent, _ := field["recparentno"].(float64)
+		name, _ := field["fieldname"].(string)
+		switch {
+		case int(parent) == fn.OldVarno:
+			return invalid("they must not refer to OLD")
+		case int(parent) == fn.NewVarno && NamingFrom(ctx).IsInternalName(name):
+			return invalid("they must not refer to the columns created by pgroll")
+		}
+	}
+
+	if containsReturn(fn.Action) {
+		return invalid("they must not return from the trigger function")
+	}
+
+	return nil
+}
+
+// containsReturn returns true if the JSON representation of a PL/pgSQL statement
+// contains a RETURN statement. The function's implicit final RETURN, which
+// has no line number, is ignored.
+func containsReturn(node any) bool {
+	switch n := node.(type) {
+	case map[string]any:
+		for key, v := range n {
+			switch key {
+			case "PLpgSQL_stmt_return", "PLpgSQL_stmt_return_next", "PLpgSQL_stmt_return_query":
+				if stmt, _ := v.(map[string]any); len(stmt) > 0 {
+					return true
+				}
+			}
+			if containsReturn(v) {
+				return true
+			}
+		}
+	case []any:
+		for _, v := range n {
+			if containsReturn(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTriggerStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		stmts      string
+		wantReason string
+	}{
+		"statement using the new row": {
+			stmts: "UPDATE teams SET members = members + 1 WHERE id = NEW.team_id;",
+		},
+		"assignment to a field of the new row": {
+			stmts: "NEW.updated_at := now();",
+		},
+		"block with its own variables": {
+			stmts: `DECLARE
+  n integer;
+BEGIN
+  SELECT count(*) INTO n FROM teams;
+  IF n > 10 THEN
+    RAISE NOTICE 'many teams';
+  END IF;
+END;`,
+		},
+		"dollar quotes": {
+			stmts:      "PERFORM $$ $$;",
+			wantReason: "they must not contain $$",
+		},
+		"incomplete statement": {
+			stmts:      "UPDATE teams SET",
+			wantReason: `syntax error at or near "END"`,
+		},
+		"unbalanced block": {
+			stmts:      "NULL; END; BEGIN NULL;",
+			wantReason: `syntax error at or near "BEGIN"`,
+		},
+		"assignment to an unknown variable": {
+			stmts:      "search_path := 'public';",
+			wantReason: `"search_path" is not a known variable`,
+		},
+		"reference to the old row": {
+			stmts:      "IF OLD.team_id <> NEW.team_id THEN NULL; END IF;",
+			wantReason: "they must not refer to OLD",
+		},
+		"reference to a pgroll column": {
+			stmts:      "NEW._pgroll_needs_backfill := true;",
+			wantReason: "they must not refer to the columns created by pgroll",
+		},
+		"return": {
+			stmts:      "IF NEW.team_id IS NULL THEN RETURN NULL; END IF;",
+			wantReason: "they must not return from the trigger function",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateTriggerStatements(context.Background(), "users", "team_id", tc.stmts)
+			if tc.wantReason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, InvalidTriggerStatementsError{Table: "users", Column: "team_id", Reason: tc.wantReason}, err)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrations
+
+import (
+	pgq "github.com/xataio/pg_query_go/v6"
+)
+
+// validateTypeName checks that the type of a column is a single,
+// syntactically valid type name, such as `varchar(255)`, `timestamp with time
+// zone` or `public.mood[]`. Whether the type exists is only known to the
+// database and is checked when the migration is started.
+func validateTypeName(table, column, typeName string) error {
+	invalid := InvalidTypeNameError{Table: table, Column: column, Type: typeName}
+
+	tree, err := pgq.Parse("SELECT NULL::" + typeName)
+	if err != nil || len(tree.GetStmts()) != 1 {
+		return invalid
+	}
+	stmt := tree.GetStmts()[0].GetStmt().GetSelectStmt()
+	if stmt == nil || len(stmt.GetTargetList()) != 1 {
+		return invalid
+	}
+	target := stmt.GetTargetList()[0].GetResTarget()
+	if target.GetName() != "" || target.GetVal().GetTypeCast() == nil {
+		return invalid
+	}
+
+	// Reject anything other than the type name itself, such as a FROM clause,
+	// by comparing the statement with one that contains only the cast.
+	castOnly := &pgq.ParseResult{Stmts: []*pgq.RawStmt{{
+		Stmt: &pgq.Node{Node: &pgq.Node_SelectStmt{SelectStmt: &pgq.SelectStmt{
+			TargetList: stmt.GetTargetList(),
+			Op:         pgq.SetOperation_SETOP_NONE,
+		}}},
+	}}}
+	got, err := pgq.Deparse(tree)
+	if err != nil {
+		return invalid
+	}
+	want, err := pgq.Deparse(castOnly)
+	if err != nil || got != want {
+		return invalid
+	}
+
+	return nil
+}
@@ -73,6 +73,15 @@ type ColumnGeneratedIdentityUserSpecifiedValues string
 const ColumnGeneratedIdentityUserSpecifiedValuesALWAYS ColumnGeneratedIdentityUserSpecifiedValues = "ALWAYS"
 const ColumnGeneratedIdentityUserSpecifiedValuesBYDEFAULT ColumnGeneratedIdentityUserSpecifiedValues = "BY DEFAULT"
 
+// Composite type attribute definition
+type CompositeTypeAttribute struct {
+	// Name of the attribute
+	Name string `json:"name"`
+
+	// Postgres type of the attribute
+	Type string `json:"type"`
+}
+
 // Constraint definition
 type Constraint struct {
 	// Check constraint expression
@@ -154,6 +163,11 @@ const ForeignKeyMatchTypeFULL ForeignKeyMatchType = "FULL"
 const ForeignKeyMatchTypePARTIAL ForeignKeyMatchType = "PARTIAL"
 const ForeignKeyMatchTypeSIMPLE ForeignKeyMatchType = "SIMPLE"
 
+type ForeignKeyValidation string
+
+const ForeignKeyValidationDeferred ForeignKeyValidation = "deferred"
+const ForeignKeyValidationImmediate ForeignKeyValidation = "immediate"
+
 // Foreign key reference definition
 type ForeignKeyReference struct {
 	// Name of the referenced column
@@ -181,6 +195,18 @@ type ForeignKeyReference struct {
 	Table string `json:"table"`
 }
 
+// Foreign table column definition
+type ForeignTableColumn struct {
+	// Name of the column
+	Name string `json:"name"`
+
+	// Indicates if the column is nullable
+	Nullable bool `json:"nullable,omitempty"`
+
+	// Postgres type of the column
+	Type string `json:"type"`
+}
+
 // Index field and its settings
 type IndexField struct {
 	// Collation for the index element
@@ -215,6 +241,42 @@ type IndexFieldSort string
 const IndexFieldSortASC IndexFieldSort = "ASC"
 const IndexFieldSortDESC IndexFieldSort = "DESC"
 
+// Path operation applied to a jsonb value
+type JsonbPathOperation struct {
+	// Type of the path operation
+	Op JsonbPathOperationOp `json:"op"`
+
+	// Path to operate on, in Postgres text array syntax, e.g. {address,city}
+	Path string `json:"path"`
+
+	// SQL expression for the jsonb value to set at the path (for set operations)
+	Value string `json:"value,omitempty"`
+}
+
+type JsonbPathOperationOp string
+
+const JsonbPathOperationOpRemove JsonbPathOperationOp = "remove"
+const JsonbPathOperationOpSet JsonbPathOperationOp = "set"
+
+// Path operations that restructure a jsonb column
+type JsonbTransform struct {
+	// Path operations for down migration
+	Down []JsonbPathOperation `json:"down"`
+
+	// Path operations for up migration
+	Up []JsonbPathOperation `json:"up"`
+}
+
+// Data consistency assertion checked before a migration is completed
+type MigrationAssertion struct {
+	// Name of the assertion
+	Name string `json:"name"`
+
+	// SQL query returning a single count, expected to be zero, or a single
+	// boolean, expected to be true
+	Query string `json:"query"`
+}
+
 // Map of column names to down SQL expressions
 type MultiColumnDownSQL map[string]string
 
@@ -223,6 +285,10 @@ type MultiColumnUpSQL map[string]string
 
 // Add column operation
 type OpAddColumn struct {
+	// Condition that limits the backfill to the rows that match it; other rows are
+	// not backfilled
+	BackfillWhere string `json:"backfill_where,omitempty"`
+
 	// Column to add
 	Column Column `json:"column"`
 
@@ -231,10 +297,18 @@ type OpAddColumn struct {
 
 	// SQL expression for up migration
 	Up string `json:"up,omitempty"`
+
+	// PL/pgSQL statements run by the up trigger after it sets the new column from
+	// the up SQL
+	UpTriggerStatements string `json:"up_trigger_statements,omitempty"`
 }
 
 // Alter column operation
 type OpAlterColumn struct {
+	// Condition that limits the backfill to the rows that match it; other rows are
+	// not backfilled
+	BackfillWhere string `json:"backfill_where,omitempty"`
+
 	// Add check constraint to the column
 	Check *CheckConstraint `json:"check,omitempty"`
 
@@ -244,6 +318,10 @@ type OpAlterColumn struct {
 	// New comment on the column
 	Comment nullable.Nullable[string] `json:"comment,omitempty"`
 
+	// New compression method of the column. Only applies to values written after
+	// the change
+	Compression *OpAlterColumnCompression `json:"compression,omitempty"`
+
 	// Default value of the column. Setting to null will drop the default if it was
 	// set previously.
 	Default nullable.Nullable[string] `json:"default,omitempty"`
@@ -251,6 +329,24 @@ type OpAlterColumn struct {
 	// SQL expression for down migration
 	Down string `json:"down"`
 
+	// PL/pgSQL statements run by the down trigger after it sets the old column
+	// from the down SQL
+	DownTriggerStatements string `json:"down_trigger_statements,omitempty"`
+
+	// Expression that generates the values of the column, converting it to a
+	// stored generated column. Setting to null converts a generated column to a
+	// regular column that keeps its current values.
+	Generated nullable.Nullable[string] `json:"generated,omitempty"`
+
+	// Change the nullability of the column in place, without duplicating and
+	// backfilling it. Only allowed if the nullability is the only change to the
+	// column
+	InPlace bool `json:"in_place,omitempty"`
+
+	// Path operations to restructure the jsonb value of the column (for jsonb
+	// transform operation)
+	Jsonb *JsonbTransform `json:"jsonb,omitempty"`
+
 	// Indicates if the column is nullable (for add/remove not null constraint
 	// operation)
 	Nullable *bool `json:"nullable,omitempty"`
@@ -258,6 +354,9 @@ type OpAlterColumn struct {
 	// Add foreign key constraint to the column
 	References *ForeignKeyReference `json:"references,omitempty"`
 
+	// New storage mode of the column
+	Storage *OpAlterColumnStorage `json:"storage,omitempty"`
+
 	// Name of the table
 	Table string `json:"table"`
 
@@ -269,6 +368,86 @@ type OpAlterColumn struct {
 
 	// SQL expression for up migration
 	Up string `json:"up"`
+
+	// PL/pgSQL statements run by the up trigger after it sets the new column from
+	// the up SQL
+	UpTriggerStatements string `json:"up_trigger_statements,omitempty"`
+}
+
+type OpAlterColumnCompression string
+
+const OpAlterColumnCompressionDefault OpAlterColumnCompression = "default"
+const OpAlterColumnCompressionLz4 OpAlterColumnCompression = "lz4"
+const OpAlterColumnCompressionPglz OpAlterColumnCompression = "pglz"
+
+type OpAlterColumnStorage string
+
+const OpAlterColumnStorageExtended OpAlterColumnStorage = "extended"
+const OpAlterColumnStorageExternal OpAlterColumnStorage = "external"
+const OpAlterColumnStorageMain OpAlterColumnStorage = "main"
+const OpAlterColumnStoragePlain OpAlterColumnStorage = "plain"
+
+// Alter default privileges operation
+type OpAlterDefaultPrivileges struct {
+	// Whether to grant or revoke the privileges
+	Action OpAlterDefaultPrivilegesAction `json:"action"`
+
+	// Role to grant the privileges to or revoke them from, or PUBLIC
+	Grantee string `json:"grantee"`
+
+	// Type of the objects that the default privileges apply to
+	ObjectType OpAlterDefaultPrivilegesObjectType `json:"object_type"`
+
+	// Privileges to grant or revoke, eg. SELECT or ALL
+	Privileges []string `json:"privileges"`
+
+	// Role whose future objects the default privileges apply to. Defaults to the
+	// role running the migration
+	Role string `json:"role,omitempty"`
+
+	// Schema whose future objects the default privileges apply to. Defaults to
+	// all schemas
+	Schema string `json:"schema,omitempty"`
+}
+
+type OpAlterDefaultPrivilegesAction string
+
+const OpAlterDefaultPrivilegesActionGrant OpAlterDefaultPrivilegesAction = "grant"
+const OpAlterDefaultPrivilegesActionRevoke OpAlterDefaultPrivilegesAction = "revoke"
+
+type OpAlterDefaultPrivilegesObjectType string
+
+const OpAlterDefaultPrivilegesObjectTypeFunctions OpAlterDefaultPrivilegesObjectType = "functions"
+const OpAlterDefaultPrivilegesObjectTypeSequences OpAlterDefaultPrivilegesObjectType = "sequences"
+const OpAlterDefaultPrivilegesObjectTypeTables OpAlterDefaultPrivilegesObjectType = "tables"
+const OpAlterDefaultPrivilegesObjectTypeTypes OpAlterDefaultPrivilegesObjectType = "types"
+
+// Alter trigger operation
+type OpAlterTrigger struct {
+	// Name of the trigger
+	Name string `json:"name"`
+
+	// State to set the trigger to
+	State OpAlterTriggerState `json:"state"`
+
+	// Name of the table on which the trigger is defined
+	Table string `json:"table"`
+}
+
+type OpAlterTriggerState string
+
+const OpAlterTriggerStateDISABLE OpAlterTriggerState = "DISABLE"
+const OpAlterTriggerStateENABLE OpAlterTriggerState = "ENABLE"
+const OpAlterTriggerStateENABLEALWAYS OpAlterTriggerState = "ENABLE ALWAYS"
+const OpAlterTriggerStateENABLEREPLICA OpAlterTriggerState = "ENABLE REPLICA"
+
+// Attach inherit operation
+type OpAttachInherit struct {
+	// Name of the parent table
+	Parent string `json:"parent"`
+
+	// Name of the child table
+	Table string `json:"table"`
 }
 
 // Add constraint to table operation
@@ -279,12 +458,18 @@ type OpCreateConstraint struct {
 	// Columns to add constraint to
 	Columns []string `json:"columns,omitempty"`
 
+	// Deferrable constraint
+	Deferrable bool `json:"deferrable,omitempty"`
+
 	// SQL expressions for down migrations
 	Down MultiColumnDownSQL `json:"down"`
 
 	// IndexParameters corresponds to the JSON schema field "index_parameters".
 	IndexParameters *OpCreateConstraintIndexParameters `json:"index_parameters,omitempty"`
 
+	// Initially deferred constraint
+	InitiallyDeferred bool `json:"initially_deferred,omitempty"`
+
 	// Name of the constraint
 	Name string `json:"name"`
 
@@ -322,9 +507,27 @@ const OpCreateConstraintTypeForeignKey OpCreateConstraintType = "foreign_key"
 const OpCreateConstraintTypePrimaryKey OpCreateConstraintType = "primary_key"
 const OpCreateConstraintTypeUnique OpCreateConstraintType = "unique"
 
+// Create foreign table operation
+type OpCreateForeignTable struct {
+	// Columns of the foreign table
+	Columns []ForeignTableColumn `json:"columns"`
+
+	// Name of the foreign table
+	Name string `json:"name"`
+
+	// Options for the foreign data wrapper, such as the name of the remote table
+	Options OpCreateForeignTableOptions `json:"options,omitempty"`
+
+	// Name of the foreign server
+	Server string `json:"server"`
+}
+
+// Options for the foreign data wrapper, such as the name of the remote table
+type OpCreateForeignTableOptions map[string]string
+
 // Create index operation
 type OpCreateIndex struct {
-	// Names and settings of columns on which to define the index
+	// Names and settings of columns, or expressions, on which to define the index
 	Columns OpCreateIndexColumns `json:"columns"`
 
 	// Index method to use for the index: btree, hash, gist, spgist, gin, brin
@@ -346,7 +549,7 @@ type OpCreateIndex struct {
 	Unique bool `json:"unique,omitempty"`
 }
 
-// Names and settings of columns on which to define the index
+// Names and settings of columns, or expressions, on which to define the index
 type OpCreateIndexColumns map[string]IndexField
 
 type OpCreateIndexMethod string
@@ -358,8 +561,60 @@ const OpCreateIndexMethodGist OpCreateIndexMethod = "gist"
 const OpCreateIndexMethodHash OpCreateIndexMethod = "hash"
 const OpCreateIndexMethodSpgist OpCreateIndexMethod = "spgist"
 
+// Create rule operation. Rules are a legacy Postgres feature; prefer triggers
+// for new behaviour
+type OpCreateRule struct {
+	// Command, or semicolon-separated commands, run by the rule; NOTHING to run no
+	// command
+	Command string `json:"command"`
+
+	// Event on which the rule fires
+	Event OpCreateRuleEvent `json:"event"`
+
+	// Run the command instead of the original statement rather than in addition to
+	// it
+	Instead bool `json:"instead,omitempty"`
+
+	// Name of the rule
+	Name string `json:"name"`
+
+	// Name of the table the rule applies to
+	Table string `json:"table"`
+
+	// Condition under which the rule fires
+	Where string `json:"where,omitempty"`
+}
+
+type OpCreateRuleEvent string
+
+const OpCreateRuleEventDELETE OpCreateRuleEvent = "DELETE"
+const OpCreateRuleEventINSERT OpCreateRuleEvent = "INSERT"
+const OpCreateRuleEventUPDATE OpCreateRuleEvent = "UPDATE"
+
+// Create partition operation
+type OpCreatePartition struct {
+	// Partition bound, such as "FROM ('2024-01-01') TO ('2024-02-01')", "IN
+	// ('eu', 'us')" or "WITH (MODULUS 4, REMAINDER 0)". Required unless the
+	// partition is the default partition
+	Bound string `json:"bound,omitempty"`
+
+	// Make the partition the default partition of the table, holding the rows
+	// that don't fit in any other partition
+	Default bool `json:"default,omitempty"`
+
+	// Name of the partition
+	Name string `json:"name"`
+
+	// Name of the partitioned table
+	Table string `json:"table"`
+}
+
 // Create table operation
 type OpCreateTable struct {
+	// Check constraints to add to the table. Each can reference any of the
+	// table's columns
+	Checks []TableCheck `json:"checks,omitempty"`
+
 	// Columns corresponds to the JSON schema field "columns".
 	Columns []Column `json:"columns"`
 
@@ -371,10 +626,50 @@ type OpCreateTable struct {
 
 	// Name of the table
 	Name string `json:"name"`
+
+	// Role to set as the owner of the table. Overrides the default object owner
+	Owner string `json:"owner,omitempty"`
+
+	// Partition the table by the given partition key
+	PartitionBy *PartitionBy `json:"partition_by,omitempty"`
+
+	// Partitions to create along with the partitioned table
+	Partitions []Partition `json:"partitions,omitempty"`
+}
+
+// Create table as operation
+type OpCreateTableAs struct {
+	// Name of the table
+	Name string `json:"name"`
+
+	// SELECT query whose results populate the table. The types of the table's
+	// columns are inferred from the query
+	Query string `json:"query"`
+
+	// Create the table with the columns returned by the query, but without any
+	// rows
+	WithNoData bool `json:"with_no_data,omitempty"`
+}
+
+// Create composite type operation
+type OpCreateType struct {
+	// Attributes of the composite type
+	Attributes []CompositeTypeAttribute `json:"attributes"`
+
+	// Drop objects that depend on the type when the operation is rolled back
+	Cascade bool `json:"cascade,omitempty"`
+
+	// Name of the composite type
+	Name string `json:"name"`
 }
 
 // Drop column operation
 type OpDropColumn struct {
+	// Drop objects that depend on the column, such as generated columns,
+	// multi-column or partial indexes, multi-column constraints, foreign keys
+	// that reference it and views
+	Cascade bool `json:"cascade,omitempty"`
+
 	// Name of the column
 	Column string `json:"column"`
 
@@ -400,6 +695,21 @@ type OpDropConstraint struct {
 	Up string `json:"up"`
 }
 
+// Detach inherit operation
+type OpDetachInherit struct {
+	// Name of the parent table
+	Parent string `json:"parent"`
+
+	// Name of the child table
+	Table string `json:"table"`
+}
+
+// Drop foreign table operation
+type OpDropForeignTable struct {
+	// Name of the foreign table
+	Name string `json:"name"`
+}
+
 // Drop index operation
 type OpDropIndex struct {
 	// Index name
@@ -421,20 +731,46 @@ type OpDropMultiColumnConstraint struct {
 	Up MultiColumnUpSQL `json:"up,omitempty"`
 }
 
+// Drop rule operation. Rules are a legacy Postgres feature
+type OpDropRule struct {
+	// Name of the rule
+	Name string `json:"name"`
+
+	// Name of the table the rule applies to
+	Table string `json:"table"`
+}
+
 // Drop table operation
 type OpDropTable struct {
 	// Name of the table
 	Name string `json:"name"`
 }
 
+// Drop type operation
+type OpDropType struct {
+	// Drop objects that depend on the type
+	Cascade bool `json:"cascade,omitempty"`
+
+	// Name of the type
+	Name string `json:"name"`
+}
+
 // Raw SQL operation
 type OpRawSQL struct {
 	// SQL expression for down migration
 	Down string `json:"down,omitempty"`
 
+	// Schema changes made by the SQL, applied to the schema when later
+	// operations are validated
+	Effects *RawSQLEffects `json:"effects,omitempty"`
+
 	// SQL expression will run on complete step (rather than on start)
 	OnComplete bool `json:"onComplete,omitempty"`
 
+	// Temporary tables created before the up SQL runs, in the same transaction.
+	// They are dropped when the transaction commits
+	TempTables []TempTable `json:"temp_tables,omitempty"`
+
 	// SQL expression for up migration
 	Up string `json:"up"`
 }
@@ -472,6 +808,15 @@ type OpRenameTable struct {
 	To string `json:"to"`
 }
 
+// Set primary key operation
+type OpSetPrimaryKey struct {
+	// Name of the existing unique index to promote to the primary key
+	Index string `json:"index"`
+
+	// Name of the table
+	Table string `json:"table"`
+}
+
 // Set replica identity operation
 type OpSetReplicaIdentity struct {
 	// Replica identity to set
@@ -481,22 +826,149 @@ type OpSetReplicaIdentity struct {
 	Table string `json:"table"`
 }
 
+// Truncate table operation
+type OpTruncate struct {
+	// Also truncate the tables that have foreign keys referencing the table
+	Cascade bool `json:"cascade,omitempty"`
+
+	// Keep a copy of the table's rows until the migration is completed, so that
+	// rolling back the migration restores them
+	PreserveData bool `json:"preserve_data,omitempty"`
+
+	// Restart the sequences owned by the table's columns
+	RestartIdentity bool `json:"restart_identity,omitempty"`
+
+	// Name of the table
+	Table string `json:"table"`
+}
+
+// Validate constraint operation
+type OpValidateConstraint struct {
+	// Name of the constraint
+	Name string `json:"name"`
+
+	// Name of the table
+	Table string `json:"table"`
+}
+
+// Partition of a partitioned table
+type Partition struct {
+	// Partition bound, such as "FROM ('2024-01-01') TO ('2024-02-01')", "IN
+	// ('eu', 'us')" or "WITH (MODULUS 4, REMAINDER 0)". Required unless the
+	// partition is the default partition
+	Bound string `json:"bound,omitempty"`
+
+	// Make the partition the default partition of the table, holding the rows
+	// that don't fit in any other partition
+	Default bool `json:"default,omitempty"`
+
+	// Name of the partition
+	Name string `json:"name"`
+}
+
+// Partition key definition
+type PartitionBy struct {
+	// Columns of the partition key
+	Columns []string `json:"columns,omitempty"`
+
+	// Expression of the partition key, used instead of columns
+	Expression string `json:"expression,omitempty"`
+
+	// Partitioning strategy
+	Type PartitionByType `json:"type"`
+}
+
+type PartitionByType string
+
+const PartitionByTypeHash PartitionByType = "hash"
+const PartitionByTypeList PartitionByType = "list"
+const PartitionByTypeRange PartitionByType = "range"
+
 // PgRoll migration definition
 type PgRollMigration struct {
+	// Data consistency assertions to check before the migration is completed
+	Assertions []MigrationAssertion `json:"assertions,omitempty"`
+
 	// Name of the migration
 	Name *string `json:"name,omitempty"`
 
 	// Operations corresponds to the JSON schema field "operations".
 	Operations PgRollOperations `json:"operations"`
 
+	// Whether the migration can be rolled back; set to false to declare it
+	// forward-only, in which case down expressions are optional and no down
+	// triggers are created
+	Reversible *bool `json:"reversible,omitempty"`
+
+	// Whether the migration may run several statements in a single transaction;
+	// set to false to run each statement on its own
+	Transactional *bool `json:"transactional,omitempty"`
+
 	// Name of the version schema to use for this migration
 	VersionSchema *string `json:"version_schema,omitempty"`
 }
 
 type PgRollOperation interface{}
 
+// Comment emitted before the SQL generated for the operation
+type PgRollOperationComment string
+
 type PgRollOperations []interface{}
 
+// Column added to an existing table by a raw SQL operation
+type RawSQLAddedColumn struct {
+	// Definition of the column
+	Column RawSQLColumn `json:"column"`
+
+	// Name of the table
+	Table string `json:"table"`
+}
+
+// Column definition in the effects of a raw SQL operation
+type RawSQLColumn struct {
+	// Name of the column
+	Name string `json:"name"`
+
+	// Indicates if the column is nullable
+	Nullable bool `json:"nullable,omitempty"`
+
+	// Postgres type of the column
+	Type string `json:"type"`
+}
+
+// Column dropped from a table by a raw SQL operation
+type RawSQLDroppedColumn struct {
+	// Name of the column
+	Column string `json:"column"`
+
+	// Name of the table
+	Table string `json:"table"`
+}
+
+// Schema changes made by a raw SQL operation
+type RawSQLEffects struct {
+	// Columns added to existing tables
+	AddColumns []RawSQLAddedColumn `json:"add_columns,omitempty"`
+
+	// Tables created
+	AddTables []RawSQLTable `json:"add_tables,omitempty"`
+
+	// Columns dropped from existing tables
+	DropColumns []RawSQLDroppedColumn `json:"drop_columns,omitempty"`
+
+	// Names of the tables dropped
+	DropTables []string `json:"drop_tables,omitempty"`
+}
+
+// Table created by a raw SQL operation
+type RawSQLTable struct {
+	// Columns of the table
+	Columns []RawSQLColumn `json:"columns,omitempty"`
+
+	// Name of the table
+	Name string `json:"name"`
+}
+
 // Replica identity definition
 type ReplicaIdentity struct {
 	// Name of the index to use as replica identity
@@ -506,6 +978,15 @@ type ReplicaIdentity struct {
 	Type string `json:"type"`
 }
 
+// Table check constraint definition
+type TableCheck struct {
+	// Boolean expression that the rows of the table must satisfy
+	Expression string `json:"expression"`
+
+	// Name of the check constraint
+	Name string `json:"name"`
+}
+
 // Table level foreign key reference definition
 type TableForeignKeyReference struct {
 	// Columns to reference
@@ -525,6 +1006,18 @@ type TableForeignKeyReference struct {
 
 	// Name of the table
 	Table string `json:"table"`
+
+	// When the existing rows are checked against the foreign key constraint
+	Validate ForeignKeyValidation `json:"validate,omitempty"`
+}
+
+// Temporary table definition
+type TempTable struct {
+	// Name of the temporary table
+	Name string `json:"name"`
+
+	// SELECT query whose results populate the temporary table
+	Query string `json:"query"`
 }
 
 // Unique constraint definition
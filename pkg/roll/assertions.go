@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// checkAssertions runs each of the migration's assertions and returns an
+// AssertionFailedError for the first one that does not hold. When version
+// schemas are enabled, the queries run with the new version schema first on
+// the search path, so they can refer to tables and columns by the names they
+// have after the migration.
+func (m *Roll) checkAssertions(ctx context.Context, migration *migrations.Migration) error {
+	if len(migration.Assertions) == 0 {
+		return nil
+	}
+
+	searchPath := pq.QuoteIdentifier(m.schema)
+	if !m.disableVersionSchemas {
+		searchPath = pq.QuoteIdentifier(VersionedSchemaName(m.schema, migration.VersionSchemaName())) + ", " + searchPath
+	}
+
+	for _, assertion := range migration.Assertions {
+		var value any
+		err := m.pgConn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+searchPath); err != nil {
+				return err
+			}
+			return tx.QueryRowContext(ctx, assertion.Query).Scan(&value)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to run assertion %q: %w", assertion.Name, err)
+		}
+
+		ok, err := assertionHolds(value)
+		if err != nil {
+			return fmt.Errorf("unable to check assertion %q: %w", assertion.Name, err)
+		}
+		if !ok {
+			return migrations.AssertionFailedError{
+				Name:  assertion.Name,
+				Value: formatAssertionValue(value),
+			}
+		}
+	}
+
+	return nil
+}
+
+// assertionHolds reports whether the value returned by an assertion query
+// indicates success: either true, or a count of zero offending rows. A NULL
+// result never holds.
+func assertionHolds(value any) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case int64:
+		return v == 0, nil
+	case float64:
+		return v == 0, nil
+	case []byte:
+		return assertionHolds(string(v))
+	case string:
+		// Parse counts first, as ParseBool also accepts "0" and "1"
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f == 0, nil
+		}
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("query must return a boolean or a count, got %q", formatAssertionValue(value))
+}
+
+func formatAssertionValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return strings.TrimSpace(string(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
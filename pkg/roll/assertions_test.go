@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestAssertionsAreCheckedBeforeCompletion(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		assertions []migrations.MigrationAssertion
+		wantErr    error
+	}{
+		"assertions that hold allow the migration to complete": {
+			assertions: []migrations.MigrationAssertion{
+				{Name: "all_backfilled", Query: "SELECT count(*) FROM users WHERE name_length IS NULL"},
+				{Name: "lengths_positive", Query: "SELECT bool_and(name_length > 0) FROM users"},
+			},
+		},
+		"a count of offending rows fails the migration": {
+			assertions: []migrations.MigrationAssertion{
+				{Name: "short_names", Query: "SELECT count(*) FROM users WHERE name_length > 3"},
+			},
+			wantErr: migrations.AssertionFailedError{Name: "short_names", Value: "2"},
+		},
+		"a false result fails the migration": {
+			assertions: []migrations.MigrationAssertion{
+				{Name: "all_short", Query: "SELECT bool_and(name_length <= 3) FROM users"},
+			},
+			wantErr: migrations.AssertionFailedError{Name: "all_short", Value: "false"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+				ctx := context.Background()
+
+				// Create a table with some rows
+				err := mig.Start(ctx, &migrations.Migration{
+					Name:       "01_create_table",
+					Operations: migrations.Operations{createTableOp("users")},
+				}, backfill.NewConfig())
+				require.NoError(t, err)
+				require.NoError(t, mig.Complete(ctx))
+
+				_, err = db.ExecContext(ctx, "INSERT INTO users (id, name) VALUES (1, 'bob'), (2, 'alice'), (3, 'carol')")
+				require.NoError(t, err)
+
+				// Start a migration that backfills a new column and asserts on its values
+				err = mig.Start(ctx, &migrations.Migration{
+					Name: "02_add_column",
+					Operations: migrations.Operations{
+						&migrations.OpAddColumn{
+							Table: "users",
+							Up:    "length(name)",
+							Column: migrations.Column{
+								Name:     "name_length",
+								Type:     "integer",
+								Nullable: true,
+							},
+						},
+					},
+					Assertions: tc.assertions,
+				}, backfill.NewConfig())
+				require.NoError(t, err)
+
+				err = mig.Complete(ctx)
+				if tc.wantErr == nil {
+					require.NoError(t, err)
+					return
+				}
+				require.ErrorIs(t, err, tc.wantErr)
+
+				// The migration is still active and can be rolled back
+				active, err := mig.State().IsActiveMigrationPeriod(ctx, "public")
+				require.NoError(t, err)
+				assert.True(t, active)
+				require.NoError(t, mig.Rollback(ctx))
+			})
+		})
+	}
+}
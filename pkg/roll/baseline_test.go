@@ -38,7 +38,8 @@ func TestBaseline(t *testing.T) {
 				Name: "public",
 				Tables: map[string]*schema.Table{
 					"users": {
-						Name: "users",
+						Name:            "users",
+						ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 						Columns: map[string]*schema.Column{
 							"id": {
 								Name:         "id",
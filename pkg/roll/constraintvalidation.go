@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// DefaultConstraintValidationConcurrency is the default maximum number of
+// tables on which constraints are validated concurrently when completing a
+// migration.
+const DefaultConstraintValidationConcurrency = 4
+
+// validateConstraints runs the constraint validations, validating constraints
+// on different tables concurrently, each table on its own connection. The
+// constraints on a single table are validated one after another, as their
+// validations would otherwise block each other. All validations are attempted
+// and any failures are reported together.
+func (m *Roll) validateConstraints(ctx context.Context, validations []migrations.ConstraintValidationAction) error {
+	// group the validations by table, keeping the order in which the tables
+	// were first seen
+	var tables []string
+	byTable := make(map[string][]migrations.ConstraintValidationAction)
+	for _, v := range validations {
+		if _, ok := byTable[v.Table()]; !ok {
+			tables = append(tables, v.Table())
+		}
+		byTable[v.Table()] = append(byTable[v.Table()], v)
+	}
+
+	workers := min(m.constraintValidationConcurrency, len(tables))
+	if workers <= 1 {
+		var errs []error
+		for _, table := range tables {
+			errs = append(errs, validateTableConstraints(ctx, m.pgConn, byTable[table])...)
+		}
+		return errors.Join(errs...)
+	}
+
+	// open a connection for each worker. The connections are opened with the
+	// same session settings as the migration connection, and are limited to a
+	// single session so that those settings apply to every validation.
+	conns := make([]db.DB, 0, workers)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for range workers {
+		conn, err := m.openConn(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to open connection for constraint validation: %w", err)
+		}
+		conns = append(conns, &db.RDB{DB: conn, IsRetryable: m.errorClassifier})
+	}
+
+	// errors are collected per table so that they are reported in a
+	// deterministic order
+	tableErrs := make([][]error, len(tables))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				tableErrs[i] = validateTableConstraints(ctx, conn, byTable[tables[i]])
+			}
+		}()
+	}
+	for i := range tables {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for _, e := range tableErrs {
+		errs = append(errs, e...)
+	}
+	return errors.Join(errs...)
+}
+
+// validateTableConstraints validates the constraints on a single table using
+// the given connection, returning an error for each failed validation.
+func validateTableConstraints(ctx context.Context, conn db.DB, validations []migrations.ConstraintValidationAction) []error {
+	var errs []error
+	for _, v := range validations {
+		if err := v.ExecuteWithConn(ctx, conn); err != nil {
+			errs = append(errs, fmt.Errorf("unable to validate constraint %q on table %q: %w",
+				v.Constraint(), v.Table(), err))
+		}
+	}
+	return errs
+}
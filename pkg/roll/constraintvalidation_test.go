@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+var constraintValidationTables = []string{"users", "orders", "products"}
+
+// addNameChecksMigration adds a check constraint on the name column of each
+// of the tables.
+func addNameChecksMigration(tables []string) *migrations.Migration {
+	ops := make(migrations.Operations, 0, len(tables))
+	for _, table := range tables {
+		ops = append(ops, &migrations.OpAlterColumn{
+			Table:  table,
+			Column: "name",
+			Check: &migrations.CheckConstraint{
+				Name:       table + "_name_length",
+				Constraint: "length(name) > 3",
+			},
+			Up:   "name",
+			Down: "name",
+		})
+	}
+	return &migrations.Migration{Name: "02_add_checks", Operations: ops}
+}
+
+func TestConstraintsAreValidatedConcurrentlyOnComplete(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithConstraintValidationConcurrency(2)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		ops := make(migrations.Operations, 0, len(constraintValidationTables))
+		for _, table := range constraintValidationTables {
+			ops = append(ops, createTableOp(table))
+		}
+		err := mig.Start(ctx, &migrations.Migration{Name: "01_create_tables", Operations: ops}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		for _, table := range constraintValidationTables {
+			_, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, name) VALUES (1, 'alice')", table))
+			require.NoError(t, err)
+		}
+
+		err = mig.Start(ctx, addNameChecksMigration(constraintValidationTables), backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// All constraints have been validated
+		for _, table := range constraintValidationTables {
+			var validated bool
+			err := db.QueryRowContext(ctx, "SELECT convalidated FROM pg_constraint WHERE conname = $1",
+				table+"_name_length").Scan(&validated)
+			require.NoError(t, err)
+			assert.True(t, validated, "constraint on table %q is not validated", table)
+		}
+	})
+}
+
+func TestConstraintValidationFailuresAreReportedTogether(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithConstraintValidationConcurrency(2)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		ops := make(migrations.Operations, 0, len(constraintValidationTables))
+		for _, table := range constraintValidationTables {
+			ops = append(ops, createTableOp(table))
+		}
+		err := mig.Start(ctx, &migrations.Migration{Name: "01_create_tables", Operations: ops}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		for _, table := range constraintValidationTables {
+			_, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, name) VALUES (1, 'alice')", table))
+			require.NoError(t, err)
+		}
+
+		err = mig.Start(ctx, addNameChecksMigration(constraintValidationTables), backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Replace the constraints on two of the tables with constraints that
+		// the existing rows violate
+		failing := constraintValidationTables[:2]
+		for _, table := range failing {
+			_, err := db.ExecContext(ctx, fmt.Sprintf(
+				"ALTER TABLE %[1]s DROP CONSTRAINT %[1]s_name_length, ADD CONSTRAINT %[1]s_name_length CHECK (false) NOT VALID",
+				table))
+			require.NoError(t, err)
+		}
+
+		// Both failures are reported
+		err = mig.Complete(ctx)
+		require.Error(t, err)
+		for _, table := range failing {
+			assert.ErrorContains(t, err, fmt.Sprintf("unable to validate constraint %q on table %q", table+"_name_length", table))
+		}
+
+		// The migration is still active
+		status, err := mig.Status(ctx, "public")
+		require.NoError(t, err)
+		assert.Equal(t, roll.InProgressMigrationStatus, status.Status)
+	})
+}
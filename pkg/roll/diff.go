@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/schema"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+// DiffFormat is the output format of a schema diff.
+type DiffFormat string
+
+const (
+	// DiffFormatText renders the diff as a readable list of changes.
+	DiffFormatText DiffFormat = "text"
+	// DiffFormatJSON renders the diff as JSON.
+	DiffFormatJSON DiffFormat = "json"
+)
+
+// ErrUnknownDiffFormat is returned when a diff is requested in a format that
+// is not supported.
+var ErrUnknownDiffFormat = fmt.Errorf("unknown diff format")
+
+// Diff returns the differences between the schema after migration `from` and
+// the schema after migration `to`. If `to` is empty, `from` is compared with
+// the latest version of the live schema. Columns and tables renamed by the
+// migrations applied between the two are reported as renames.
+func (m *Roll) Diff(ctx context.Context, from, to string) (*schema.SchemaDiff, error) {
+	fromSchema, err := m.schemaAfterMigration(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var toSchema *schema.Schema
+	if to == "" {
+		physical, err := m.state.ReadSchema(ctx, m.schema)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read schema: %w", err)
+		}
+		toSchema, err = m.latestVirtualSchema(ctx, physical)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		toSchema, err = m.schemaAfterMigration(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	renames, err := m.renamesBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema.Diff(fromSchema, toSchema, renames), nil
+}
+
+// schemaAfterMigration reads the schema recorded after migration `name` was
+// applied
+func (m *Roll) schemaAfterMigration(ctx context.Context, name string) (*schema.Schema, error) {
+	s, err := m.state.SchemaAfterMigration(ctx, m.schema, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %q", state.ErrMigrationNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema after migration %q: %w", name, err)
+	}
+	return s, nil
+}
+
+// WriteDiff writes the differences between the schema after migration `from`
+// and the schema after migration `to` to w. If `to` is empty, `from` is
+// compared with the latest version of the live schema.
+func (m *Roll) WriteDiff(ctx context.Context, w io.Writer, from, to string, format DiffFormat) error {
+	if format != DiffFormatText && format != DiffFormatJSON {
+		return fmt.Errorf("%w: %q", ErrUnknownDiffFormat, format)
+	}
+
+	diff, err := m.Diff(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	if format == DiffFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	return writeDiffText(w, diff)
+}
+
+// renamesBetween collects the table and column renames performed by the
+// migrations applied after `from` up to and including `to`. If `to` was
+// applied before `from`, the renames are inverted.
+func (m *Roll) renamesBetween(ctx context.Context, from, to string) (*schema.Renames, error) {
+	history, err := m.state.SchemaHistory(ctx, m.schema)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema history: %w", err)
+	}
+
+	fromIdx, toIdx := -1, len(history)-1
+	if to != "" {
+		toIdx = -1
+	}
+	for i, entry := range history {
+		if entry.Migration.Name == from {
+			fromIdx = i
+		}
+		if to != "" && entry.Migration.Name == to {
+			toIdx = i
+		}
+	}
+
+	invert := false
+	if toIdx < fromIdx {
+		fromIdx, toIdx = toIdx, fromIdx
+		invert = true
+	}
+
+	renames := schema.NewRenames()
+	for _, entry := range history[fromIdx+1 : toIdx+1] {
+		mig, err := migrations.ParseMigration(&entry.Migration)
+		if err != nil {
+			return nil, fmt.Errorf("parsing migration %q: %w", entry.Migration.Name, err)
+		}
+		for _, op := range mig.Operations {
+			switch op := op.(type) {
+			case *migrations.OpRenameTable:
+				renames.RenameTable(op.From, op.To)
+			case *migrations.OpRenameColumn:
+				renames.RenameColumn(op.Table, op.From, op.To)
+			}
+		}
+	}
+
+	if invert {
+		return renames.Invert(), nil
+	}
+	return renames, nil
+}
+
+// writeDiffText writes the diff as one line per change, with the changes to
+// the replica identity, columns, indexes and constraints of a table indented
+// below it
+func writeDiffText(w io.Writer, diff *schema.SchemaDiff) error {
+	if diff.IsEmpty() {
+		_, err := fmt.Fprintln(w, "No differences")
+		return err
+	}
+
+	var b strings.Builder
+	for _, t := range diff.Tables {
+		fmt.Fprintf(&b, "%s table %s%s\n", changeMarker(t.Change), t.Name, renamedFrom(t.From))
+
+		if t.ReplicaIdentity != nil {
+			fmt.Fprintf(&b, "    ~ replica identity %s -> %s\n", t.ReplicaIdentity.From, t.ReplicaIdentity.To)
+		}
+
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "    %s column %s%s%s\n", changeMarker(c.Change), c.Name, renamedFrom(c.From), columnChanges(c))
+		}
+		for _, idx := range t.Indexes {
+			fmt.Fprintf(&b, "    %s index %s%s\n", changeMarker(idx.Change), idx.Name, objectDefinition(idx))
+		}
+		for _, con := range t.Constraints {
+			name := con.Type
+			if con.Name != "" {
+				name = fmt.Sprintf("%s %s", con.Type, con.Name)
+			}
+			fmt.Fprintf(&b, "    %s constraint %s%s\n", changeMarker(con.Change), name, objectDefinition(con))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func changeMarker(change schema.ChangeKind) string {
+	switch change {
+	case schema.ChangeAdded:
+		return "+"
+	case schema.ChangeDropped:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+func renamedFrom(from string) string {
+	if from == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (renamed from %s)", from)
+}
+
+func columnChanges(c schema.ColumnDiff) string {
+	switch c.Change {
+	case schema.ChangeAdded:
+		return ": " + c.Type.To
+	case schema.ChangeDropped:
+		return ": " + c.Type.From
+	}
+
+	var changes []string
+	if c.Type != nil {
+		changes = append(changes, fmt.Sprintf("type %s -> %s", c.Type.From, c.Type.To))
+	}
+	if c.Nullable != nil {
+		changes = append(changes, fmt.Sprintf("%s -> %s", c.Nullable.From, c.Nullable.To))
+	}
+	if c.Default != nil {
+		changes = append(changes, fmt.Sprintf("default %s -> %s", orNone(c.Default.From), orNone(c.Default.To)))
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(changes, ", ")
+}
+
+func objectDefinition(o schema.ObjectDiff) string {
+	if o.Definition == "" {
+		return ""
+	}
+	return ": " + o.Definition
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
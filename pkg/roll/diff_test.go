@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+	"github.com/xataio/pgroll/pkg/schema"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Rename a column and add another one
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_change_users",
+			Operations: migrations.Operations{
+				&migrations.OpRenameColumn{Table: "users", From: "name", To: "full_name"},
+				&migrations.OpAddColumn{
+					Table:  "users",
+					Column: migrations.Column{Name: "email", Type: "text", Nullable: true},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// The renamed column is reported as a rename rather than a drop and an add
+		diff, err := mig.Diff(ctx, "01_create_table", "02_change_users")
+		require.NoError(t, err)
+		assert.Equal(t, []schema.TableDiff{
+			{
+				Name:   "users",
+				Change: schema.ChangeModified,
+				Columns: []schema.ColumnDiff{
+					{Name: "email", Change: schema.ChangeAdded, Type: &schema.ValueChange{To: "text"}},
+					{Name: "full_name", From: "name", Change: schema.ChangeRenamed},
+				},
+			},
+		}, diff.Tables)
+
+		// Comparing the versions the other way around reverses the changes
+		diff, err = mig.Diff(ctx, "02_change_users", "01_create_table")
+		require.NoError(t, err)
+		assert.Equal(t, []schema.TableDiff{
+			{
+				Name:   "users",
+				Change: schema.ChangeModified,
+				Columns: []schema.ColumnDiff{
+					{Name: "email", Change: schema.ChangeDropped, Type: &schema.ValueChange{From: "text"}},
+					{Name: "name", From: "full_name", Change: schema.ChangeRenamed},
+				},
+			},
+		}, diff.Tables)
+
+		// Without a second migration the live schema is the target
+		var out strings.Builder
+		err = mig.WriteDiff(ctx, &out, "01_create_table", "", roll.DiffFormatText)
+		require.NoError(t, err)
+		assert.Equal(t, `~ table users
+    + column email: text
+    ~ column full_name (renamed from name)
+`, out.String())
+
+		out.Reset()
+		err = mig.WriteDiff(ctx, &out, "02_change_users", "", roll.DiffFormatText)
+		require.NoError(t, err)
+		assert.Equal(t, "No differences\n", out.String())
+	})
+}
+
+func TestDiffRejectsUnknownMigrationsAndFormats(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		_, err := mig.Diff(ctx, "01_does_not_exist", "")
+		assert.ErrorIs(t, err, state.ErrMigrationNotFound)
+
+		var out strings.Builder
+		err = mig.WriteDiff(ctx, &out, "01_does_not_exist", "", roll.DiffFormat("yaml"))
+		assert.ErrorIs(t, err, roll.ErrUnknownDiffFormat)
+		assert.Empty(t, out.String())
+	})
+}
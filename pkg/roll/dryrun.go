@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// The phases of a migration in which the statements of a dry run are
+// executed.
+const (
+	PhaseStart    = "start"
+	PhaseBackfill = "backfill"
+	PhaseComplete = "complete"
+)
+
+// SQLGroup holds the statements that a migration executes for one of its
+// operations, or for the migration as a whole, in one phase.
+type SQLGroup struct {
+	// Phase is the phase in which the statements are executed.
+	Phase string
+
+	// Operation is the name of the operation that executes the statements,
+	// or empty for statements executed for the migration as a whole, such as
+	// those that create the version schema.
+	Operation migrations.OpName
+
+	// Statements are the statements, in the order they are executed.
+	Statements []string
+}
+
+// sqlGroups splits the statements recorded by a RecordingDB into groups.
+type sqlGroups struct {
+	rec    *db.RecordingDB
+	n      int
+	groups []SQLGroup
+}
+
+// add groups the statements recorded since the last call to add. Nothing is
+// added if no statements were recorded.
+func (g *sqlGroups) add(phase string, op migrations.Operation) {
+	statements := g.rec.Statements()[g.n:]
+	g.n = len(g.rec.Statements())
+	if len(statements) == 0 {
+		return
+	}
+
+	group := SQLGroup{Phase: phase, Statements: statements}
+	if op != nil {
+		group.Operation = migrations.OperationName(op)
+	}
+	g.groups = append(g.groups, group)
+}
+
+// dryRun returns a copy of the Roll that records the statements it executes
+// rather than running them. Read-only queries, such as those that introspect
+// the schema, still run against the database.
+func (m *Roll) dryRun() (*Roll, *sqlGroups) {
+	rec := &db.RecordingDB{Conn: m.pgConn, LiveQueries: true}
+	dry := *m
+	dry.pgConn = rec
+	dry.logger = migrations.NewNoopLogger()
+	return &dry, &sqlGroups{rec: rec}
+}
+
+// DryRunStart returns the statements that Start would execute to start the
+// migration and backfill the affected tables, grouped by operation and
+// phase, without modifying the database. The backfill of each table is
+// shown as a single statement that loops over its batches. Changes to
+// pgroll's own state are not included.
+//
+// Each operation plans its statements from the schema as it is before the
+// migration starts, so queries an operation makes about objects created by
+// an earlier operation of the same migration find nothing.
+func (m *Roll) DryRunStart(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) ([]SQLGroup, error) {
+	groups, _, err := m.dryRunStart(ctx, migration, cfg)
+	return groups, err
+}
+
+// DryRunBackfill counts the rows of each table that the backfill of the
+// migration would update, and the rows whose values the up SQL of each column
+// would change, without starting the migration or updating any row. Up to
+// `samples` of the changes to each column are returned too. The backfill is
+// planned as by DryRunStart and the rows are counted as by backfill.DryRun.
+func (m *Roll) DryRunBackfill(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config, samples int) ([]backfill.TableDryRun, error) {
+	_, job, err := m.dryRunStart(ctx, migration, cfg)
+	if err != nil {
+		return nil, err
+	}
+	bf := backfill.New(m.pgConn, cfg)
+	bf.SetNaming(m.naming)
+	return bf.DryRun(ctx, job, samples)
+}
+
+// dryRunStart records the statements that Start would execute, as
+// DryRunStart does, and also returns the backfill job that Start would run.
+func (m *Roll) dryRunStart(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) ([]SQLGroup, *backfill.Job, error) {
+	hasExistingSchema, err := m.state.HasExistingSchemaWithoutHistory(ctx, m.schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check for existing schema: %w", err)
+	}
+	if hasExistingSchema {
+		return nil, nil, ErrExistingSchemaWithoutHistory
+	}
+
+	active, err := m.state.IsActiveMigrationPeriod(ctx, m.schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	if active {
+		return nil, nil, fmt.Errorf("a migration for schema %q is already in progress", m.schema)
+	}
+
+	// Nothing is executed against the database, so the schema only needs to
+	// be read once
+	defer m.withSchemaCache()()
+
+	if m.reorderOperations {
+		s, err := m.readSchema(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read schema: %w", err)
+		}
+		if err := migration.SortOperations(s); err != nil {
+			return nil, nil, fmt.Errorf("unable to reorder operations of migration '%s': %w", migration.Name, err)
+		}
+	}
+
+	if err := m.Validate(ctx, migration); err != nil {
+		return nil, nil, err
+	}
+
+	ctx = migrations.WithNaming(ctx, m.naming)
+	dry, groups := m.dryRun()
+	rec := groups.rec
+
+	s, err := dry.readSchema(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	job := backfill.NewJob(m.schema, VersionedSchemaName(m.schema, migration.VersionSchemaName()))
+	for _, op := range migration.Operations {
+		startOp, err := op.Start(ctx, dry.logger, rec, s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to collect actions for start %q migration: %w", migration.Name, err)
+		}
+		if startOp == nil {
+			continue
+		}
+
+		startOp.Actions = append(startOp.Actions, m.ownerActions(rec, op)...)
+
+		startOp.Actions, err = m.migrationActions(migration, startOp.Actions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to collect actions for start %q migration: %w", migration.Name, err)
+		}
+
+		if err := executeWithComment(ctx, rec, op, startOp.Actions); err != nil {
+			return nil, nil, fmt.Errorf("unable to record start operation of %q: %w", migration.Name, err)
+		}
+		groups.add(PhaseStart, op)
+
+		if startOp.BackfillTask != nil {
+			if !migration.IsReversible() {
+				startOp.BackfillTask.RemoveDownTriggers()
+			}
+			job.AddTask(startOp.BackfillTask)
+		}
+	}
+
+	if !m.disableVersionSchemas {
+		if err := dry.ensureViews(ctx, s, migration); err != nil {
+			return nil, nil, err
+		}
+		groups.add(PhaseStart, nil)
+	}
+
+	bf := backfill.New(rec, cfg)
+	bf.SetNaming(m.naming)
+	if err := bf.CreateTriggers(ctx, job); err != nil {
+		return nil, nil, err
+	}
+	for _, table := range job.Tables {
+		if _, err := rec.ExecContext(ctx, bf.LoopSQL(table.Name, job.Filter(table.Name))); err != nil {
+			return nil, nil, err
+		}
+	}
+	groups.add(PhaseBackfill, nil)
+
+	return groups.groups, job, nil
+}
+
+// DryRunComplete returns the statements that Complete would execute to
+// complete the active migration, grouped by operation and phase, without
+// modifying the database. The migration's assertions are not checked, and
+// changes to pgroll's own state are not included.
+func (m *Roll) DryRunComplete(ctx context.Context) ([]SQLGroup, error) {
+	active, err := m.activeMigrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get active migration: %w", err)
+	}
+	migration := active[0]
+
+	ctx, err = m.withMigrationNaming(ctx, migration)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing is executed against the database, so the schema only needs to
+	// be read once
+	defer m.withSchemaCache()()
+
+	dry, groups := m.dryRun()
+	rec := groups.rec
+
+	if len(active) == 1 {
+		if err := dry.dropWriteGuards(ctx); err != nil {
+			return nil, fmt.Errorf("unable to drop write guards: %w", err)
+		}
+	}
+
+	prevVersion, err := m.previousVersion(ctx, active)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get name of previous version: %w", err)
+	}
+	if prevVersion != nil {
+		_, err := rec.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE",
+			pq.QuoteIdentifier(VersionedSchemaName(m.schema, *prevVersion))))
+		if err != nil {
+			return nil, err
+		}
+	}
+	groups.add(PhaseComplete, nil)
+
+	s, err := dry.readSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	refreshViews := false
+	for _, op := range migration.Operations {
+		actions, err := op.Complete(ctx, dry.logger, rec, s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for complete operation: %w", err)
+		}
+		actions, err = m.migrationActions(migration, actions)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for complete operation: %w", err)
+		}
+		if m.keepTriggers {
+			actions, err = dry.disableKeptTriggers(ctx, rec, actions)
+			if err != nil {
+				return nil, fmt.Errorf("unable to disable kept triggers: %w", err)
+			}
+		}
+		if err := executeWithComment(ctx, rec, op, actions); err != nil {
+			return nil, fmt.Errorf("unable to record complete operation: %w", err)
+		}
+		groups.add(PhaseComplete, op)
+
+		if _, ok := op.(migrations.RequiresSchemaRefreshOperation); ok {
+			refreshViews = true
+		}
+	}
+
+	if refreshViews && !m.disableVersionSchemas {
+		if err := dry.ensureViews(ctx, s, migration); err != nil {
+			return nil, err
+		}
+	}
+	groups.add(PhaseComplete, nil)
+
+	return groups.groups, nil
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table with a completed migration
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		migration := &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "users",
+					Up:    "length(name)",
+					Column: migrations.Column{
+						Name: "name_length",
+						Type: "integer",
+					},
+				},
+			},
+		}
+
+		// Dry run the start of a migration that requires a backfill
+		groups, err := mig.DryRunStart(ctx, migration, backfill.NewConfig())
+		require.NoError(t, err)
+		require.Len(t, groups, 3)
+
+		assert.Equal(t, roll.PhaseStart, groups[0].Phase)
+		assert.Equal(t, migrations.OpNameAddColumn, groups[0].Operation)
+		assert.Contains(t, strings.Join(groups[0].Statements, "\n"), `ADD COLUMN "_pgroll_new_name_length"`)
+
+		assert.Equal(t, roll.PhaseStart, groups[1].Phase)
+		assert.Empty(t, groups[1].Operation)
+		assert.Contains(t, strings.Join(groups[1].Statements, "\n"), `CREATE SCHEMA IF NOT EXISTS "public_02_add_column"`)
+
+		assert.Equal(t, roll.PhaseBackfill, groups[2].Phase)
+		assert.Contains(t, strings.Join(groups[2].Statements, "\n"), `CREATE OR REPLACE FUNCTION "_pgroll_trigger_users_name_length"`)
+
+		// The dry run does not change the database
+		var exists bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'users' AND column_name = '_pgroll_new_name_length'
+		)`).Scan(&exists)
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		// Dry run the completion of the started migration
+		require.NoError(t, mig.Start(ctx, migration, backfill.NewConfig()))
+
+		groups, err = mig.DryRunComplete(ctx)
+		require.NoError(t, err)
+
+		var complete []string
+		for _, group := range groups {
+			assert.Equal(t, roll.PhaseComplete, group.Phase)
+			complete = append(complete, group.Statements...)
+		}
+		assert.Contains(t, strings.Join(complete, "\n"), `DROP SCHEMA IF EXISTS "public_01_create_table" CASCADE`)
+		assert.Contains(t, strings.Join(complete, "\n"), `RENAME COLUMN "_pgroll_new_name_length" TO "name_length"`)
+
+		// The migration is still active
+		active, err := mig.State().IsActiveMigrationPeriod(ctx, "public")
+		require.NoError(t, err)
+		assert.True(t, active)
+	})
+}
+
+func TestDryRunBackfill(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err = db.ExecContext(ctx, "INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'BOB'), (3, 'carol'), (4, NULL)")
+		require.NoError(t, err)
+
+		migration := &migrations.Migration{
+			Name: "02_lower_names",
+			Operations: migrations.Operations{
+				&migrations.OpAlterColumn{
+					Table:         "users",
+					Column:        "name",
+					Type:          ptr("text"),
+					Up:            "lower(name)",
+					Down:          "name",
+					BackfillWhere: "id < 4",
+				},
+			},
+		}
+
+		tables, err := mig.DryRunBackfill(ctx, migration, backfill.NewConfig(), 5)
+		require.NoError(t, err)
+
+		// Three rows match the filter, and the up SQL changes one of them
+		bob, lowerBob := "BOB", "bob"
+		assert.Equal(t, []backfill.TableDryRun{{
+			Table: "users",
+			Rows:  3,
+			Columns: []backfill.ColumnDryRun{{
+				Column:  "name",
+				Changed: 1,
+				Samples: []backfill.ValueChange{{Before: &bob, After: &lowerBob}},
+			}},
+		}}, tables)
+
+		// No row was updated and the migration wasn't started
+		var names []string
+		rows, err := db.QueryContext(ctx, "SELECT coalesce(name, 'NULL') FROM users ORDER BY id")
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			require.NoError(t, rows.Scan(&name))
+			names = append(names, name)
+		}
+		require.NoError(t, rows.Err())
+		assert.Equal(t, []string{"alice", "BOB", "carol", "NULL"}, names)
+
+		active, err := mig.State().IsActiveMigrationPeriod(ctx, "public")
+		require.NoError(t, err)
+		assert.False(t, active)
+	})
+}
+
+func TestConcurrentIndexesCanBeDisabled(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithConcurrentIndexes(false)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, _ *sql.DB) {
+		ctx := context.Background()
+
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		groups, err := mig.DryRunStart(ctx, &migrations.Migration{
+			Name: "02_create_index",
+			Operations: migrations.Operations{
+				&migrations.OpCreateIndex{
+					Name:    "idx_users_name",
+					Table:   "users",
+					Columns: map[string]migrations.IndexField{"name": {}},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NotEmpty(t, groups)
+
+		// The index is built without CONCURRENTLY
+		statements := strings.Join(groups[0].Statements, "\n")
+		assert.Contains(t, statements, `CREATE INDEX "idx_users_name" ON "users"`)
+		assert.NotContains(t, statements, "CONCURRENTLY")
+	})
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// Skip records the migration in the schema history without applying it. It is
+// used for migrations that are not tagged for the environment being migrated,
+// so that the order of the migrations in the history still matches the order
+// of the migration files.
+func (m *Roll) Skip(ctx context.Context, migration *migrations.Migration) error {
+	m.logger.Info("skipping migration", "migration", migration.Name, "schema", m.schema)
+
+	return m.state.Skip(ctx, m.schema, migration)
+}
+
+// ValidateForEnvironment checks that the migrations can be applied one after
+// the other in the given environment, skipping those that are not tagged for
+// it. It catches migrations that depend on the changes made by a skipped
+// migration before any of the migrations is applied.
+//
+// The migrations are validated against the in-memory schema only, so checks
+// that need the database, such as the existence of roles, are left to each
+// migration's own validation when it is started.
+func (m *Roll) ValidateForEnvironment(ctx context.Context, rawMigs []*migrations.RawMigration, environment string) error {
+	if m.skipValidation {
+		return nil
+	}
+
+	s, err := m.readSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range rawMigs {
+		// Parse the migrations again, as validation may update the operations
+		// that are later started
+		migration, err := m.migrationCache.ParseMigration(raw)
+		if err != nil {
+			return err
+		}
+		if !migration.AppliesTo(environment) {
+			continue
+		}
+		if err := migration.Validate(ctx, s); err != nil {
+			return fmt.Errorf("migration '%s' is invalid in environment %q: %w", migration.Name, environment, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestSkip(t *testing.T) {
+	t.Parallel()
+
+	t.Run("skipped migrations are part of the schema history", func(t *testing.T) {
+		fs := fstest.MapFS{
+			"01_create_table.json":  &fstest.MapFile{Data: createTableMigration(t, "01_create_table", "users", nil)},
+			"02_seed_fixtures.json": &fstest.MapFile{Data: createTableMigration(t, "02_seed_fixtures", "fixtures", []string{"staging"})},
+			"03_create_table.json":  &fstest.MapFile{Data: createTableMigration(t, "03_create_table", "orders", nil)},
+		}
+
+		testutils.WithMigratorAndConnectionToContainer(t, func(roll *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			first, err := migrations.ReadMigration(fs, "01_create_table.json")
+			require.NoError(t, err)
+			require.NoError(t, roll.Start(ctx, first, backfill.NewConfig()))
+			require.NoError(t, roll.Complete(ctx))
+
+			second, err := migrations.ReadMigration(fs, "02_seed_fixtures.json")
+			require.NoError(t, err)
+			require.NoError(t, roll.Skip(ctx, second))
+
+			// The skipped migration is recorded, so only the third migration is
+			// left to apply
+			migs, err := roll.UnappliedMigrations(ctx, fs)
+			require.NoError(t, err)
+			require.Len(t, migs, 1)
+			require.Equal(t, "03_create_table", migs[0].Name)
+
+			// The skipped migration made no changes to the schema
+			var exists bool
+			err = db.QueryRowContext(ctx, "SELECT to_regclass('fixtures') IS NOT NULL").Scan(&exists)
+			require.NoError(t, err)
+			require.False(t, exists)
+		})
+	})
+
+	t.Run("migrations can't be skipped while a migration is active", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(roll *roll.Roll, _ *sql.DB) {
+			ctx := context.Background()
+
+			fs := fstest.MapFS{
+				"01_create_table.json":  &fstest.MapFile{Data: createTableMigration(t, "01_create_table", "users", nil)},
+				"02_seed_fixtures.json": &fstest.MapFile{Data: createTableMigration(t, "02_seed_fixtures", "fixtures", []string{"staging"})},
+			}
+
+			first, err := migrations.ReadMigration(fs, "01_create_table.json")
+			require.NoError(t, err)
+			second, err := migrations.ReadMigration(fs, "02_seed_fixtures.json")
+			require.NoError(t, err)
+
+			require.NoError(t, roll.Start(ctx, first, backfill.NewConfig()))
+			require.Error(t, roll.Skip(ctx, second))
+		})
+	})
+}
+
+func TestValidateForEnvironment(t *testing.T) {
+	t.Parallel()
+
+	rawMigs := func(t *testing.T) []*migrations.RawMigration {
+		t.Helper()
+
+		fs := fstest.MapFS{
+			"01_create_table.json": &fstest.MapFile{Data: createTableMigration(t, "01_create_table", "fixtures", []string{"staging"})},
+			"02_add_column.json": &fstest.MapFile{Data: migrationJSON(t, &migrations.Migration{
+				Operations: migrations.Operations{
+					&migrations.OpAddColumn{
+						Table:  "fixtures",
+						Column: migrations.Column{Name: "name", Type: "text", Nullable: true},
+					},
+				},
+			})},
+		}
+
+		var migs []*migrations.RawMigration
+		for _, file := range []string{"01_create_table.json", "02_add_column.json"} {
+			mig, err := migrations.ReadRawMigration(fs, file)
+			require.NoError(t, err)
+			migs = append(migs, mig)
+		}
+		return migs
+	}
+
+	t.Run("migrations that don't depend on skipped migrations are valid", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(roll *roll.Roll, _ *sql.DB) {
+			err := roll.ValidateForEnvironment(context.Background(), rawMigs(t), "staging")
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("migrations that depend on skipped migrations are invalid", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(roll *roll.Roll, _ *sql.DB) {
+			err := roll.ValidateForEnvironment(context.Background(), rawMigs(t), "production")
+			require.ErrorIs(t, err, migrations.TableDoesNotExistError{Name: "fixtures"})
+		})
+	})
+}
+
+func createTableMigration(t *testing.T, name, table string, environments []string) []byte {
+	t.Helper()
+
+	return migrationJSON(t, &migrations.Migration{
+		Name:         name,
+		Environments: environments,
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: table,
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+				},
+			},
+		},
+	})
+}
+
+func migrationJSON(t *testing.T, mig *migrations.Migration) []byte {
+	t.Helper()
+
+	bytes, err := json.Marshal(mig)
+	require.NoError(t, err)
+
+	return bytes
+}
@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// The kinds of work that take time in proportion to the size of a table.
+const (
+	WorkIndexBuild = "index build"
+	WorkBackfill   = "backfill"
+	WorkValidation = "validation"
+)
+
+// The rates at which work is assumed to progress. They are deliberately
+// conservative round numbers; actual rates depend on the hardware, the
+// configuration of the database and the load on it.
+const (
+	// indexBuildBytesPerSecond is the rate at which an index build reads the
+	// table. A concurrent build reads the table twice.
+	indexBuildBytesPerSecond = 64 << 20
+
+	// validationBytesPerSecond is the rate at which a constraint is validated
+	// against the rows of the table.
+	validationBytesPerSecond = 256 << 20
+
+	// backfillRowsPerSecond is the rate at which rows are backfilled, not
+	// counting the delay between batches.
+	backfillRowsPerSecond = 10_000
+)
+
+// WorkEstimate is the estimated duration of a piece of work that scans or
+// updates a table.
+type WorkEstimate struct {
+	// Phase is the phase of the migration in which the work is done.
+	Phase string
+
+	// Operation is the name of the operation that does the work, or empty for
+	// backfills, which are shared by all of the operations on a table.
+	Operation migrations.OpName
+
+	// Work is the kind of work: an index build, a backfill or a validation.
+	Work string
+
+	// Table is the table that is scanned or updated.
+	Table string
+
+	// Rows is the estimated number of rows of the table.
+	Rows int64
+
+	// Bytes is the size of the table on disk.
+	Bytes int64
+
+	// Batches is the number of batches of a backfill.
+	Batches int64
+
+	// Duration is the estimated duration of the work.
+	Duration time.Duration
+}
+
+// MigrationEstimate is the estimated duration of a migration.
+type MigrationEstimate struct {
+	// Work is the work done by the migration that takes time in proportion to
+	// the size of the tables, in the order it is done.
+	Work []WorkEstimate
+
+	// Total is the sum of the durations of the work.
+	Total time.Duration
+}
+
+// Estimate returns rough estimates of the time taken by the index builds,
+// backfills and constraint validations of the migration, computed from the
+// size of each table and the backfill settings in cfg. The work is planned
+// from a dry run of Start, so nothing is executed against the database. The
+// estimates assume fixed rates of work and are only meant to give an order of
+// magnitude; work that doesn't depend on the size of a table, such as adding
+// a column, is not included.
+func (m *Roll) Estimate(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) (*MigrationEstimate, error) {
+	groups, job, err := m.dryRunStart(ctx, migration, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &MigrationEstimate{}
+	validations := make(map[string]int)
+
+	for _, group := range groups {
+		if group.Operation == "" {
+			continue
+		}
+		for _, stmt := range group.Statements {
+			work, err := tableWork(stmt)
+			if err != nil {
+				return nil, err
+			}
+			for _, w := range work {
+				if w.work == WorkValidation {
+					// A constraint added as NOT VALID is validated on completion,
+					// unless the operation validates it straight away
+					key := w.table + "." + w.constraint
+					if i, ok := validations[key]; ok {
+						estimate.Work[i].Phase = PhaseStart
+						continue
+					}
+					validations[key] = len(estimate.Work)
+				}
+
+				rows, bytes, err := m.tableSize(ctx, w.regclass)
+				if err != nil {
+					return nil, err
+				}
+				estimate.Work = append(estimate.Work, WorkEstimate{
+					Phase:     w.phase,
+					Operation: group.Operation,
+					Work:      w.work,
+					Table:     w.table,
+					Rows:      rows,
+					Bytes:     bytes,
+					Duration:  bytesDuration(bytes*int64(w.scans), w.rate),
+				})
+			}
+		}
+	}
+
+	bf := backfill.New(m.pgConn, cfg)
+	bf.SetNaming(m.naming)
+	var backfilled []string
+	for _, table := range job.Tables {
+		if slices.Contains(backfilled, table.Name) {
+			continue
+		}
+		backfilled = append(backfilled, table.Name)
+
+		te, err := bf.EstimateTable(ctx, table.Name)
+		if err != nil {
+			return nil, err
+		}
+		_, bytes, err := m.tableSize(ctx, pq.QuoteIdentifier(table.Name))
+		if err != nil {
+			return nil, err
+		}
+		estimate.Work = append(estimate.Work, WorkEstimate{
+			Phase:    PhaseBackfill,
+			Work:     WorkBackfill,
+			Table:    table.Name,
+			Rows:     te.Rows,
+			Bytes:    bytes,
+			Batches:  te.Batches,
+			Duration: time.Duration(te.Rows)*time.Second/backfillRowsPerSecond + time.Duration(te.Batches)*te.BatchDelay,
+		})
+	}
+
+	for _, w := range estimate.Work {
+		estimate.Total += w.Duration
+	}
+
+	return estimate, nil
+}
+
+// work is a piece of work done by a statement that scans a table.
+type work struct {
+	phase      string
+	work       string
+	table      string
+	regclass   string
+	constraint string
+	scans      int
+	rate       int64
+}
+
+// tableWork returns the index builds and constraint validations done by the
+// statement.
+func tableWork(stmt string) ([]work, error) {
+	tree, err := pgq.Parse(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse statement %q: %w", stmt, err)
+	}
+
+	var result []work
+	for _, raw := range tree.GetStmts() {
+		node := raw.GetStmt()
+
+		if idx := node.GetIndexStmt(); idx != nil {
+			scans := 1
+			if idx.GetConcurrent() {
+				scans = 2
+			}
+			result = append(result, work{
+				phase:    PhaseStart,
+				work:     WorkIndexBuild,
+				table:    idx.GetRelation().GetRelname(),
+				regclass: regclass(idx.GetRelation()),
+				scans:    scans,
+				rate:     indexBuildBytesPerSecond,
+			})
+		}
+
+		if alter := node.GetAlterTableStmt(); alter != nil {
+			table, rc := alter.GetRelation().GetRelname(), regclass(alter.GetRelation())
+			for _, c := range alter.GetCmds() {
+				cmd := c.GetAlterTableCmd()
+				switch cmd.GetSubtype() {
+				case pgq.AlterTableType_AT_AddConstraint:
+					constraint := cmd.GetDef().GetConstraint()
+					if !constraint.GetSkipValidation() {
+						continue
+					}
+					result = append(result, work{
+						phase:      PhaseComplete,
+						work:       WorkValidation,
+						table:      table,
+						regclass:   rc,
+						constraint: constraint.GetConname(),
+						scans:      1,
+						rate:       validationBytesPerSecond,
+					})
+				case pgq.AlterTableType_AT_ValidateConstraint:
+					result = append(result, work{
+						phase:      PhaseStart,
+						work:       WorkValidation,
+						table:      table,
+						regclass:   rc,
+						constraint: cmd.GetName(),
+						scans:      1,
+						rate:       validationBytesPerSecond,
+					})
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// regclass returns the quoted, and if the statement qualifies it,
+// schema-qualified name of the relation
+func regclass(rel *pgq.RangeVar) string {
+	if rel.GetSchemaname() != "" {
+		return pq.QuoteIdentifier(rel.GetSchemaname()) + "." + pq.QuoteIdentifier(rel.GetRelname())
+	}
+	return pq.QuoteIdentifier(rel.GetRelname())
+}
+
+// tableSize returns the estimated number of rows and the size on disk of the
+// table with the given quoted name. Both are zero if the table doesn't exist
+// yet, because it is created by the migration.
+func (m *Roll) tableSize(ctx context.Context, table string) (int64, int64, error) {
+	rows, err := m.pgConn.QueryContext(ctx, `
+	  SELECT GREATEST(c.reltuples, 0)::bigint, pg_relation_size(c.oid)
+	  FROM pg_class c
+	  WHERE c.oid = to_regclass($1)`, table)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to get size of table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var tuples, bytes int64
+	if rows.Next() {
+		if err := rows.Scan(&tuples, &bytes); err != nil {
+			return 0, 0, fmt.Errorf("unable to scan size of table %s: %w", table, err)
+		}
+	}
+	return tuples, bytes, rows.Err()
+}
+
+// bytesDuration returns the time taken to process the given number of bytes at
+// the given rate.
+func bytesDuration(bytes, bytesPerSecond int64) time.Duration {
+	return time.Duration(float64(bytes) / float64(bytesPerSecond) * float64(time.Second))
+}
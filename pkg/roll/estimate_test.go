@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestEstimate(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		err := mig.Start(ctx, &migrations.Migration{
+			Name: "01_create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name: "users",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "integer", Pk: true},
+						{Name: "name", Type: "text"},
+					},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err = db.ExecContext(ctx, "INSERT INTO users (id, name) SELECT i, 'user ' || i FROM generate_series(1, 2500) AS i")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "ANALYZE users")
+		require.NoError(t, err)
+
+		migration := &migrations.Migration{
+			Name: "02_index_and_check",
+			Operations: migrations.Operations{
+				&migrations.OpCreateIndex{Name: "idx_users_name", Table: "users", Columns: migrations.OpCreateIndexColumns{"name": {}}},
+				addNameChecksMigration([]string{"users"}).Operations[0],
+			},
+		}
+
+		estimate, err := mig.Estimate(ctx, migration, backfill.NewConfig(backfill.WithBatchSize(1000)))
+		require.NoError(t, err)
+
+		type work struct {
+			phase     string
+			operation migrations.OpName
+			work      string
+			table     string
+		}
+		var got []work
+		var total int64
+		for _, w := range estimate.Work {
+			got = append(got, work{w.Phase, w.Operation, w.Work, w.Table})
+			assert.Equal(t, int64(2500), w.Rows, "rows of %s on %s", w.Work, w.Table)
+			assert.Positive(t, w.Bytes, "size of %s on %s", w.Work, w.Table)
+			total += int64(w.Duration)
+		}
+
+		// The index is built on start, the check constraint is added as NOT VALID
+		// and validated on completion, and the table is backfilled in 3 batches
+		assert.Equal(t, []work{
+			{roll.PhaseStart, migrations.OpNameCreateIndex, roll.WorkIndexBuild, "users"},
+			{roll.PhaseComplete, migrations.OpNameAlterColumn, roll.WorkValidation, "users"},
+			{roll.PhaseBackfill, "", roll.WorkBackfill, "users"},
+		}, got)
+		assert.Equal(t, int64(3), estimate.Work[2].Batches)
+		assert.Equal(t, total, int64(estimate.Total))
+
+		// Nothing was executed against the database
+		var exists bool
+		err = db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_users_name')").Scan(&exists)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
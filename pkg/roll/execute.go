@@ -6,11 +6,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 
 	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/migrations"
 	"github.com/xataio/pgroll/pkg/schema"
 )
@@ -19,7 +23,8 @@ func (m *Roll) Validate(ctx context.Context, migration *migrations.Migration) er
 	if m.skipValidation {
 		return nil
 	}
-	lastSchema, err := m.state.ReadSchema(ctx, m.schema)
+	ctx = migrations.WithNaming(ctx, m.naming)
+	lastSchema, err := m.readSchema(ctx)
 	if err != nil {
 		return err
 	}
@@ -27,10 +32,67 @@ func (m *Roll) Validate(ctx context.Context, migration *migrations.Migration) er
 	if err != nil {
 		return fmt.Errorf("migration '%s' is invalid: %w", migration.Name, err)
 	}
+	for _, op := range migration.Operations {
+		if err := m.validateRoles(ctx, op); err != nil {
+			return fmt.Errorf("migration '%s' is invalid: %w", migration.Name, err)
+		}
+	}
 	return nil
 }
 
+// validateRoles checks that the roles named by the operation exist and, for
+// the roles that the operation acts as, that the current role is a member of
+// them.
+func (m *Roll) validateRoles(ctx context.Context, op migrations.Operation) error {
+	if createTable, ok := op.(*migrations.OpCreateTable); ok && createTable.Owner != "" {
+		if err := checkRole(ctx, m.pgConn, createTable.Owner); err != nil {
+			return fmt.Errorf("invalid owner for table %q: %w", createTable.Name, err)
+		}
+	}
+	if alterPrivileges, ok := op.(*migrations.OpAlterDefaultPrivileges); ok {
+		// Altering the default privileges of another role requires
+		// membership of that role
+		if alterPrivileges.Role != "" {
+			if err := checkRole(ctx, m.pgConn, alterPrivileges.Role); err != nil {
+				return fmt.Errorf("invalid role for default privileges: %w", err)
+			}
+		}
+		if !strings.EqualFold(alterPrivileges.Grantee, "PUBLIC") {
+			if err := checkRoleExists(ctx, m.pgConn, alterPrivileges.Grantee); err != nil {
+				return fmt.Errorf("invalid grantee for default privileges: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ownerActions returns the actions that transfer ownership of the objects
+// created by the operation to the default object owner, if one is set.
+func (m *Roll) ownerActions(conn db.DB, op migrations.Operation) []migrations.DBAction {
+	owned, ok := op.(migrations.OwnedOperation)
+	if !ok || m.objectOwner == "" {
+		return nil
+	}
+
+	var actions []migrations.DBAction
+	for _, object := range owned.OwnedObjects() {
+		actions = append(actions, migrations.NewAlterOwnerAction(conn, object, m.objectOwner))
+	}
+	return actions
+}
+
 // Start will apply the required changes to enable supporting the new schema version
+//
+// Starting a migration that has already been started with the same contents
+// runs or resumes its backfill if the previous start was interrupted, and
+// otherwise does nothing, as does starting a migration that has already been
+// completed. A migration with the same name as one that has been started but
+// different contents is rejected with ErrMismatchedMigration.
+//
+// Everything needed to complete or roll back the migration is recorded in the
+// pgroll state schema, so the process that started a migration may exit
+// once Start returns. Complete or Rollback can then be called from another
+// process, on a Roll instance created with the same schema and state schema.
 func (m *Roll) Start(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) error {
 	// Fail early if we have existing schema without migration history
 	hasExistingSchema, err := m.state.HasExistingSchemaWithoutHistory(ctx, m.schema)
@@ -41,7 +103,51 @@ func (m *Roll) Start(ctx context.Context, migration *migrations.Migration, cfg *
 		return ErrExistingSchemaWithoutHistory
 	}
 
+	// Starting a migration that has already been started continues it, so
+	// that it is safe to start the same migration more than once
+	started, done, err := m.appliedMigration(ctx, migration)
+	if err != nil {
+		return err
+	}
+	if done {
+		m.logger.Info("migration is already complete", "migration", migration.Name, "schema", m.schema)
+		return nil
+	}
+	if started {
+		ctx, err = m.withMigrationNaming(ctx, migration)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Continue the backfill of the migration if it was interrupted the last
+	// time the migration was started
+	unfinished, err := m.state.HasUnfinishedBackfill(ctx, m.schema, migration.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check for an unfinished backfill: %w", err)
+	}
+	if unfinished {
+		return m.resumeBackfill(ctx, migration, cfg)
+	}
+	if started {
+		return m.continueStart(ctx, migration, cfg)
+	}
+
 	m.logger.LogMigrationStart(migration)
+	ctx = migrations.WithNaming(ctx, m.naming)
+
+	// Cache the introspected schema until the migration has started
+	defer m.withSchemaCache()()
+
+	if m.reorderOperations {
+		s, err := m.readSchema(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to read schema: %w", err)
+		}
+		if err := migration.SortOperations(s); err != nil {
+			return fmt.Errorf("unable to reorder operations of migration '%s': %w", migration.Name, err)
+		}
+	}
 
 	if err := m.Validate(ctx, migration); err != nil {
 		return err
@@ -53,23 +159,25 @@ func (m *Roll) Start(ctx context.Context, migration *migrations.Migration, cfg *
 	}
 
 	// perform backfills for the tables that require it
-	return m.performBackfills(ctx, job, cfg)
+	return m.performBackfills(ctx, migration, job, cfg)
 }
 
 // StartDDLOperations performs the DDL operations for the migration. This does
 // not include running backfills for any modified tables.
 func (m *Roll) StartDDLOperations(ctx context.Context, migration *migrations.Migration) (*backfill.Job, error) {
-	// check if there is an active migration, create one otherwise
-	active, err := m.state.IsActiveMigrationPeriod(ctx, m.schema)
+	defer m.withSchemaCache()()
+	ctx = migrations.WithNaming(ctx, m.naming)
+
+	// check if there is an active migration, create one otherwise. With
+	// migration stacking, the migration is started on top of any active
+	// migrations that it doesn't conflict with.
+	active, err := m.checkStacking(ctx, migration)
 	if err != nil {
 		return nil, err
 	}
-	if active {
-		return nil, fmt.Errorf("a migration for schema %q is already in progress", m.schema)
-	}
 
 	// create a new active migration (guaranteed to be unique by constraints)
-	if err = m.state.Start(ctx, m.schema, migration); err != nil {
+	if err = m.state.Start(ctx, m.schema, migration, m.naming); err != nil {
 		return nil, fmt.Errorf("unable to start migration: %w", err)
 	}
 
@@ -78,6 +186,7 @@ func (m *Roll) StartDDLOperations(ctx context.Context, migration *migrations.Mig
 		if err := m.migrationHooks.BeforeStartDDL(m); err != nil {
 			return nil, fmt.Errorf("failed to execute BeforeStartDDL hook: %w", err)
 		}
+		m.invalidateSchema()
 	}
 
 	// defer execution of any AfterStartDDL hooks
@@ -92,48 +201,108 @@ func (m *Roll) StartDDLOperations(ctx context.Context, migration *migrations.Mig
 	versionSchemaName := VersionedSchemaName(m.schema, migration.VersionSchemaName())
 
 	// Reread the latest schema as validation may have updated the schema object
-	// in memory.
-	newSchema, err := m.state.ReadSchema(ctx, m.schema)
+	// in memory. A stacked migration starts from the schema as seen by the
+	// version of the last active migration, so that the views of its version
+	// include the changes made by the active migrations.
+	var newSchema *schema.Schema
+	if len(active) > 0 {
+		newSchema, err = m.virtualSchema(ctx, active)
+	} else {
+		newSchema, err = m.readSchema(ctx)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("unable to read schema: %w", err)
 	}
 
 	// execute operations
-	job := backfill.NewJob(m.schema, versionSchemaName)
-	for _, op := range migration.Operations {
-		startOp, err := op.Start(ctx, m.logger, m.pgConn, newSchema)
+	var tasks []*backfill.Task
+	startOperation := func(ctx context.Context, conn db.DB, i int) error {
+		op := migration.Operations[i]
+		opStart := time.Now()
+		startOp, err := op.Start(ctx, m.logger, conn, newSchema)
 		if err != nil {
-			return nil, fmt.Errorf("unable to collect actions for start %q migration: %w", migration.Name, err)
+			return fmt.Errorf("unable to collect actions for start %q migration: %w", migration.Name, err)
 		}
 		if startOp == nil {
-			continue
+			return nil
+		}
+
+		// transfer ownership of new objects to the default object owner, unless
+		// the operation specifies its own owner
+		startOp.Actions = append(startOp.Actions, m.ownerActions(conn, op)...)
+
+		startOp.Actions, err = m.migrationActions(migration, startOp.Actions)
+		if err != nil {
+			return fmt.Errorf("unable to collect actions for start %q migration: %w", migration.Name, err)
 		}
 
 		for _, action := range startOp.Actions {
-			if err := action.Execute(ctx); err != nil {
-				errRollback := m.Rollback(ctx)
-				if errRollback != nil {
-					return nil, errors.Join(
-						fmt.Errorf("unable to execute start operation of %q: %w", migration.Name, err),
-						fmt.Errorf("unable to roll back failed operation: %w", errRollback))
-				}
-				return nil, fmt.Errorf("failed to start %q migration, changes rolled back: %w", migration.Name, err)
+			if err := m.executeAction(ctx, action); err != nil {
+				return actionError{err: err}
 			}
 		}
+		if len(startOp.Actions) > 0 {
+			m.invalidateSchema()
+		}
 		// refresh schema when the op is isolated and requires a refresh (for example raw sql)
 		// we don't want to refresh the schema if the operation is not isolated as it would
 		// override changes made by other operations
 		if _, ok := op.(migrations.RequiresSchemaRefreshOperation); ok {
 			if isolatedOp, ok := op.(migrations.IsolatedOperation); ok && isolatedOp.IsIsolated() {
-				newSchema, err = m.state.ReadSchema(ctx, m.schema)
+				refreshed, err := m.readSchema(ctx)
 				if err != nil {
-					return nil, fmt.Errorf("unable to refresh schema: %w", err)
+					return fmt.Errorf("unable to refresh schema: %w", err)
+				}
+				*newSchema = *refreshed
+				if rawSQL, ok := op.(*migrations.OpRawSQL); ok {
+					m.warnEffectConflicts(rawSQL, newSchema)
 				}
 			}
 		}
 		if startOp.BackfillTask != nil {
-			job.AddTask(startOp.BackfillTask)
+			// forward-only migrations don't keep the old version of the schema
+			// in sync with writes made through the new one
+			if !migration.IsReversible() {
+				startOp.BackfillTask.RemoveDownTriggers()
+			}
+			startOp.BackfillTask.SetOperation(operationLabel(i, op))
+			tasks = append(tasks, startOp.BackfillTask)
+		}
+		m.logger.LogOperationDone(op, time.Since(opStart))
+		return nil
+	}
+
+	// a per-table transaction that is rolled back leaves neither its changes
+	// to the in-memory schema nor its backfill tasks behind
+	checkpoint := func() (func() error, error) {
+		restoreSchema, err := schemaCheckpoint(newSchema)()
+		if err != nil {
+			return nil, err
 		}
+		n := len(tasks)
+		return func() error {
+			tasks = tasks[:n]
+			return restoreSchema()
+		}, nil
+	}
+
+	if err := m.runOperations(ctx, migration, startOperation, checkpoint); err != nil {
+		var actionErr actionError
+		if !errors.As(err, &actionErr) {
+			return nil, err
+		}
+		errRollback := m.rollback(ctx)
+		if errRollback != nil {
+			return nil, errors.Join(
+				fmt.Errorf("unable to execute start operation of %q: %w", migration.Name, actionErr.err),
+				fmt.Errorf("unable to roll back failed operation: %w", errRollback))
+		}
+		return nil, fmt.Errorf("failed to start %q migration, changes rolled back: %w", migration.Name, actionErr.err)
+	}
+
+	job := backfill.NewJob(m.schema, versionSchemaName)
+	for _, task := range tasks {
+		job.AddTask(task)
 	}
 
 	// create views for the new version
@@ -143,9 +312,49 @@ func (m *Roll) StartDDLOperations(ctx context.Context, migration *migrations.Mig
 		}
 	}
 
+	// treat writes through the new version like writes through the versions
+	// of the active migrations it is stacked on
+	if len(active) > 0 {
+		if err := m.stackTriggers(ctx, active, versionSchemaName); err != nil {
+			if errRollback := m.rollback(ctx); errRollback != nil {
+				return nil, errors.Join(err, fmt.Errorf("unable to roll back stacked migration: %w", errRollback))
+			}
+			return nil, fmt.Errorf("failed to start %q migration, changes rolled back: %w", migration.Name, err)
+		}
+	}
+
 	return job, nil
 }
 
+// actionError is an error executing the actions of an operation, as opposed
+// to collecting them. Starting a migration is rolled back on such errors.
+type actionError struct {
+	err error
+}
+
+func (e actionError) Error() string {
+	return e.err.Error()
+}
+
+func (e actionError) Unwrap() error {
+	return e.err
+}
+
+// operationLabel describes the operation at index i of a migration, eg. "2
+// (add_column)", for errors that need to say which operation they come from.
+func operationLabel(i int, op migrations.Operation) string {
+	return fmt.Sprintf("%d (%s)", i+1, migrations.OperationName(op))
+}
+
+// warnEffectConflicts logs a warning for each schema change declared in the
+// effects of a raw SQL operation that isn't found in the schema once its SQL
+// has run.
+func (m *Roll) warnEffectConflicts(op *migrations.OpRawSQL, s *schema.Schema) {
+	for _, conflict := range op.EffectConflicts(s) {
+		m.logger.Warn("raw SQL effects conflict with the schema", "conflict", conflict)
+	}
+}
+
 func (m *Roll) ensureViews(ctx context.Context, schema *schema.Schema, mig *migrations.Migration) error {
 	versionSchema := VersionedSchemaName(m.schema, mig.VersionSchemaName())
 	_, err := m.pgConn.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pq.QuoteIdentifier(versionSchema)))
@@ -153,6 +362,15 @@ func (m *Roll) ensureViews(ctx context.Context, schema *schema.Schema, mig *migr
 		return err
 	}
 
+	if m.objectOwner != "" {
+		_, err = m.pgConn.ExecContext(ctx, fmt.Sprintf("ALTER SCHEMA %s OWNER TO %s",
+			pq.QuoteIdentifier(versionSchema),
+			pq.QuoteIdentifier(m.objectOwner)))
+		if err != nil {
+			return err
+		}
+	}
+
 	// create views in the new schema
 	for name, table := range schema.Tables {
 		if table.Deleted {
@@ -170,17 +388,72 @@ func (m *Roll) ensureViews(ctx context.Context, schema *schema.Schema, mig *migr
 }
 
 // Complete will update the database schema to match the current version
+//
+// The active migration is read from the pgroll state schema, so Complete need
+// not be called on the Roll instance that started the migration.
 func (m *Roll) Complete(ctx context.Context) error {
-	// get current ongoing migration
-	migration, err := m.state.GetActiveMigration(ctx, m.schema)
+	// get current ongoing migration, the oldest if migrations are stacked
+	active, err := m.activeMigrations(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get active migration: %w", err)
 	}
+	migration := active[0]
+	stacked := len(active) > 1
+
+	ctx, err = m.withMigrationNaming(ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	// kept triggers are disabled by the names of their trigger functions,
+	// which the triggers of the stacked migrations may share
+	if stacked && m.keepTriggers {
+		return fmt.Errorf("triggers can't be kept when completing %q, as other migrations are stacked on it", migration.Name)
+	}
 
 	m.logger.LogMigrationComplete(migration)
 
+	// Cache the introspected schema until the migration has completed
+	defer m.withSchemaCache()()
+
+	// Check the migration's assertions against the backfilled data. A failing
+	// assertion leaves the migration active so that the data can be fixed.
+	if err := m.checkAssertions(ctx, migration); err != nil {
+		return err
+	}
+
+	// Allow writes to tables that were backfilled without triggers again,
+	// once it is known that none of their rows were updated or deleted while
+	// the migration was active. Migrations are only stacked on migrations
+	// without write guards, so any write guards belong to the last of the
+	// stacked migrations.
+	if !stacked {
+		if err := m.checkWriteGuards(ctx); err != nil {
+			return err
+		}
+		if err := m.dropWriteGuards(ctx); err != nil {
+			return fmt.Errorf("unable to drop write guards: %w", err)
+		}
+	}
+
+	// Run the non-blocking parts of completion, such as constraint validation,
+	// before anything else. These can be slow on large tables, so running them
+	// first keeps them out of the window in which heavier locks are taken, and
+	// any failure leaves the old version schema in place.
+	if err := m.executeNonBlockingCompleteActions(ctx, migration); err != nil {
+		return fmt.Errorf("unable to execute non-blocking complete operations: %w", err)
+	}
+
+	// Set the maintenance flag, if enabled, for the rest of the completion, in
+	// which heavier locks are taken
+	endMaintenance, err := m.startMaintenance(ctx, migration)
+	if err != nil {
+		return fmt.Errorf("unable to enter maintenance mode: %w", err)
+	}
+	defer endMaintenance()
+
 	// Drop the old version schema if there is one
-	prevVersion, err := m.state.PreviousVersion(ctx, m.schema)
+	prevVersion, err := m.previousVersion(ctx, active)
 	if err != nil {
 		return fmt.Errorf("unable to get name of previous version: %w", err)
 	}
@@ -193,7 +466,7 @@ func (m *Roll) Complete(ctx context.Context) error {
 	}
 
 	// read the current schema
-	currentSchema, err := m.state.ReadSchema(ctx, m.schema)
+	currentSchema, err := m.readSchema(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to read schema: %w", err)
 	}
@@ -203,6 +476,7 @@ func (m *Roll) Complete(ctx context.Context) error {
 		if err := m.migrationHooks.BeforeCompleteDDL(m); err != nil {
 			return fmt.Errorf("failed to execute BeforeCompleteDDL hook: %w", err)
 		}
+		m.invalidateSchema()
 	}
 
 	// defer execution of any AfterCompleteDDL hooks
@@ -212,31 +486,57 @@ func (m *Roll) Complete(ctx context.Context) error {
 
 	// execute operations
 	refreshViews := false
-	for _, op := range migration.Operations {
-		actions, err := op.Complete(m.logger, m.pgConn, currentSchema)
+	completeOperation := func(ctx context.Context, conn db.DB, i int) error {
+		op := migration.Operations[i]
+		opStart := time.Now()
+		actions, err := op.Complete(ctx, m.logger, conn, currentSchema)
+		if err != nil {
+			return fmt.Errorf("unable to collect actions for complete operation: %w", err)
+		}
+		actions, err = m.migrationActions(migration, actions)
 		if err != nil {
 			return fmt.Errorf("unable to collect actions for complete operation: %w", err)
 		}
 
+		// kept triggers are disabled before the columns they refer to are
+		// renamed or dropped, as writes to the table would fail otherwise
+		if m.keepTriggers {
+			actions, err = m.disableKeptTriggers(ctx, conn, actions)
+			if err != nil {
+				return fmt.Errorf("unable to disable kept triggers: %w", err)
+			}
+		}
+
 		for _, action := range actions {
-			if err := action.Execute(ctx); err != nil {
+			if err := m.executeAction(ctx, action); err != nil {
 				return fmt.Errorf("unable to execute complete operation: %w", err)
 			}
+			m.invalidateSchema()
 		}
 
-		currentSchema, err = m.state.ReadSchema(ctx, m.schema)
+		// re-read the schema only if the operation changed it
+		refreshed, err := m.readSchemaWith(ctx, conn)
 		if err != nil {
 			return fmt.Errorf("unable to read schema: %w", err)
 		}
+		*currentSchema = *refreshed
 
 		if _, ok := op.(migrations.RequiresSchemaRefreshOperation); ok {
 			refreshViews = true
 		}
+		if rawSQL, ok := op.(*migrations.OpRawSQL); ok && rawSQL.OnComplete {
+			m.warnEffectConflicts(rawSQL, currentSchema)
+		}
+		m.logger.LogOperationDone(op, time.Since(opStart))
+		return nil
+	}
+	if err := m.runOperations(ctx, migration, completeOperation, schemaCheckpoint(currentSchema)); err != nil {
+		return err
 	}
 
 	// recreate views for the new version (if some operations require it, ie SQL)
 	if refreshViews && !m.disableVersionSchemas {
-		currentSchema, err = m.state.ReadSchema(ctx, m.schema)
+		currentSchema, err = m.readSchema(ctx)
 		if err != nil {
 			return fmt.Errorf("unable to read schema: %w", err)
 		}
@@ -258,16 +558,44 @@ func (m *Roll) Complete(ctx context.Context) error {
 	return nil
 }
 
-// Rollback will revert the changes made by the migration
+// Rollback will revert the changes made by the migration. Migrations that are
+// declared as forward-only can't be rolled back.
 func (m *Roll) Rollback(ctx context.Context) error {
-	// get current ongoing migration
-	migration, err := m.state.GetActiveMigration(ctx, m.schema)
+	active, err := m.activeMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get active migration: %w", err)
+	}
+	migration := active[len(active)-1]
+
+	if !migration.IsReversible() {
+		return fmt.Errorf("unable to roll back %q: %w", migration.Name, ErrIrreversibleMigration)
+	}
+
+	return m.rollback(ctx)
+}
+
+// rollback rolls back the active migration, whether or not it is reversible.
+// It is used to undo a migration that failed to start.
+func (m *Roll) rollback(ctx context.Context) error {
+	// get current ongoing migration, the newest if migrations are stacked
+	active, err := m.activeMigrations(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get active migration: %w", err)
 	}
+	migration := active[len(active)-1]
+
+	ctx, err = m.withMigrationNaming(ctx, migration)
+	if err != nil {
+		return err
+	}
 
 	m.logger.LogMigrationRollback(migration)
 
+	// allow writes to tables that were backfilled without triggers again
+	if err := m.dropWriteGuards(ctx); err != nil {
+		return fmt.Errorf("unable to drop write guards: %w", err)
+	}
+
 	// delete the schema and views for the new version
 	versionSchema := VersionedSchemaName(m.schema, migration.VersionSchemaName())
 	_, err = m.pgConn.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", pq.QuoteIdentifier(versionSchema)))
@@ -277,29 +605,20 @@ func (m *Roll) Rollback(ctx context.Context) error {
 
 	m.logger.LogSchemaDeletion(migration.Name, versionSchema)
 
-	// get the name of the previous migration
-	previousMigration, err := m.state.PreviousMigration(ctx, m.schema)
+	// get the schema as seen by the version of the migration
+	schema, err := m.virtualSchema(ctx, active)
 	if err != nil {
-		return fmt.Errorf("unable to get name of previous version: %w", err)
-	}
-
-	// get the schema after the previous migration was applied
-	schema := schema.New()
-	if previousMigration != nil {
-		schema, err = m.state.SchemaAfterMigration(ctx, m.schema, *previousMigration)
-		if err != nil {
-			return fmt.Errorf("unable to read schema: %w", err)
-		}
-	}
-
-	// update the in-memory schema with the results of applying the migration
-	if err := migration.UpdateVirtualSchema(ctx, schema); err != nil {
-		return fmt.Errorf("unable to replay changes to in-memory schema: %w", err)
+		return err
 	}
 
 	// roll back operations in reverse order
 	for i := len(migration.Operations) - 1; i >= 0; i-- {
-		actions, err := migration.Operations[i].Rollback(m.logger, m.pgConn, schema)
+		opStart := time.Now()
+		actions, err := migration.Operations[i].Rollback(ctx, m.logger, m.pgConn, schema)
+		if err != nil {
+			return fmt.Errorf("unable to collect actions for rollback operation: %w", err)
+		}
+		actions, err = m.migrationActions(migration, actions)
 		if err != nil {
 			return fmt.Errorf("unable to collect actions for rollback operation: %w", err)
 		}
@@ -308,6 +627,7 @@ func (m *Roll) Rollback(ctx context.Context) error {
 				return fmt.Errorf("unable to execute rollback operation: %w", err)
 			}
 		}
+		m.logger.LogOperationDone(migration.Operations[i], time.Since(opStart))
 	}
 
 	// roll back the migration
@@ -321,6 +641,313 @@ func (m *Roll) Rollback(ctx context.Context) error {
 	return nil
 }
 
+// executeNonBlockingCompleteActions executes those actions from the
+// migration's completion that implement migrations.NonBlockingAction.
+//
+// Operations are considered in order up to the first operation that renames
+// a table, column or constraint or runs raw SQL; the completion actions of
+// later operations may refer to objects by names that only exist once the
+// preceding operations have completed. Non-blocking actions are idempotent, so
+// they are run again as part of normal completion, where they are cheap.
+//
+// Constraint validations are run last, concurrently across tables; see
+// validateConstraints.
+func (m *Roll) executeNonBlockingCompleteActions(ctx context.Context, migration *migrations.Migration) error {
+	currentSchema, err := m.readSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	logger := migrations.NewNoopLogger()
+	var validations []migrations.ConstraintValidationAction
+operations:
+	for _, op := range migration.Operations {
+		switch op.(type) {
+		case *migrations.OpRenameTable, *migrations.OpRenameColumn, *migrations.OpRenameConstraint, *migrations.OpRawSQL:
+			break operations
+		}
+
+		actions, err := op.Complete(ctx, logger, m.pgConn, currentSchema)
+		if err != nil {
+			return fmt.Errorf("unable to collect actions for complete operation: %w", err)
+		}
+
+		for _, action := range actions {
+			if v, ok := action.(migrations.ConstraintValidationAction); ok {
+				validations = append(validations, v)
+				continue
+			}
+			if _, ok := action.(migrations.NonBlockingAction); !ok {
+				continue
+			}
+			if err := m.executeAction(ctx, action); err != nil {
+				return fmt.Errorf("unable to execute complete operation: %w", err)
+			}
+			m.invalidateSchema()
+		}
+	}
+
+	if len(validations) == 0 {
+		return nil
+	}
+	defer m.invalidateSchema()
+	return m.validateConstraints(ctx, validations)
+}
+
+// disableKeptTriggers disables the triggers that the trigger cleanup actions
+// among `actions` would drop, in place of the cleanup, and returns the other
+// actions.
+func (m *Roll) disableKeptTriggers(ctx context.Context, conn db.DB, actions []migrations.DBAction) ([]migrations.DBAction, error) {
+	var functions []string
+	kept := make([]migrations.DBAction, 0, len(actions))
+	for _, action := range actions {
+		if cleanup, ok := action.(migrations.TriggerCleanupAction); ok {
+			functions = append(functions, cleanup.TriggerFunctions()...)
+			continue
+		}
+		kept = append(kept, action)
+	}
+	if len(functions) == 0 {
+		return kept, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT c.relname, t.tgname
+		FROM pg_catalog.pg_trigger t
+		JOIN pg_catalog.pg_class c ON c.oid = t.tgrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_catalog.pg_proc p ON p.oid = t.tgfoid
+		WHERE n.nspname = $1
+			AND NOT t.tgisinternal
+			AND p.proname = ANY($2)`,
+		m.schema, pq.StringArray(functions))
+	if err != nil {
+		return nil, err
+	}
+
+	type trigger struct{ table, name string }
+	var triggers []trigger
+	for rows.Next() {
+		var t trigger
+		if err := rows.Scan(&t.table, &t.name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, t := range triggers {
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DISABLE TRIGGER %s",
+			pq.QuoteIdentifier(t.table),
+			pq.QuoteIdentifier(t.name)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return kept, nil
+}
+
+// dropWriteGuards drops the write guards that reject updates and deletes on
+// tables backfilled without triggers. Migrations are never stacked on a migration
+// with write guards, so all write guards in the schema belong to the newest
+// active migration.
+func (m *Roll) dropWriteGuards(ctx context.Context) error {
+	guards, err := m.writeGuards(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(guards) == 0 {
+		return nil
+	}
+	return migrations.NewDropFunctionAction(m.pgConn, guards...).Execute(ctx)
+}
+
+// writeGuards returns the names of the write guards in the schema, as named
+// by the naming of the migration run with the context.
+func (m *Roll) writeGuards(ctx context.Context) ([]string, error) {
+	naming := migrations.NamingFrom(ctx)
+	rows, err := m.pgConn.QueryContext(ctx, `SELECT c.relname, t.tgname
+		FROM pg_catalog.pg_trigger t
+		JOIN pg_catalog.pg_class c ON c.oid = t.tgrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1
+			AND NOT t.tgisinternal
+			AND starts_with(t.tgname, $2)`,
+		m.schema, naming.TriggerFunctionPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	var guards []string
+	for rows.Next() {
+		var table, name string
+		if err := rows.Scan(&table, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if naming.IsWriteGuardName(table, name) {
+			guards = append(guards, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return guards, nil
+}
+
+// Bits of pg_trigger.tgtype
+const (
+	triggerTypeRow    = 1 << 0
+	triggerTypeUpdate = 1 << 4
+)
+
+// checkWriteGuards checks that the tables backfilled without triggers, whose
+// row triggers fire on INSERT only, are still guarded by an enabled write
+// guard. As long as the guard is in place, none of the rows of the table can
+// have been updated or deleted, leaving the two versions of the schema out of
+// sync. An ErrWriteGuardDisabled error is returned for the first table whose
+// guard was disabled or dropped; such a migration should be rolled back.
+func (m *Roll) checkWriteGuards(ctx context.Context) error {
+	naming := migrations.NamingFrom(ctx)
+	rows, err := m.pgConn.QueryContext(ctx, `SELECT c.relname, t.tgname, t.tgtype, t.tgenabled
+		FROM pg_catalog.pg_trigger t
+		JOIN pg_catalog.pg_class c ON c.oid = t.tgrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1
+			AND NOT t.tgisinternal
+			AND starts_with(t.tgname, $2)`,
+		m.schema, naming.TriggerFunctionPrefix())
+	if err != nil {
+		return err
+	}
+
+	// insertOnly holds the tables with enabled row triggers that only fire on
+	// INSERT, and guarded the tables with a write guard that always fires
+	insertOnly := make(map[string]bool)
+	guarded := make(map[string]bool)
+	for rows.Next() {
+		var table, name, enabled string
+		var typ int16
+		if err := rows.Scan(&table, &name, &typ, &enabled); err != nil {
+			rows.Close()
+			return err
+		}
+		switch {
+		case naming.IsWriteGuardName(table, name):
+			guarded[table] = enabled == "A"
+		case enabled != "D" && typ&triggerTypeRow != 0 && typ&triggerTypeUpdate == 0:
+			insertOnly[table] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, table := range slices.Sorted(maps.Keys(insertOnly)) {
+		if !guarded[table] {
+			return fmt.Errorf("%w: table %q may have had rows updated or deleted without triggers; roll back the migration", ErrWriteGuardDisabled, table)
+		}
+	}
+	return nil
+}
+
+// Cleanup removes any pgroll triggers and trigger functions left in the schema
+// by completing a migration with `WithKeepTriggers`. It returns the names of
+// the trigger functions that were dropped. Cleanup fails if a migration is in
+// progress, as the triggers are still in use in that case.
+func (m *Roll) Cleanup(ctx context.Context) ([]string, error) {
+	active, err := m.state.IsActiveMigrationPeriod(ctx, m.schema)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, fmt.Errorf("a migration for schema %q is in progress; complete or roll it back before cleaning up", m.schema)
+	}
+
+	// The migrations that left triggers behind may have been started with
+	// different prefixes
+	prefixes, err := m.state.InternalPrefixes(ctx, m.schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read internal prefixes: %w", err)
+	}
+	triggerPrefixes := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		triggerPrefixes = append(triggerPrefixes, backfill.NewNaming(prefix).TriggerFunctionPrefix())
+	}
+
+	rows, err := m.pgConn.QueryContext(ctx, `SELECT p.proname
+		FROM pg_catalog.pg_proc p
+		JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = $1
+			AND EXISTS (SELECT 1 FROM unnest($2::text[]) AS tp (prefix) WHERE starts_with(p.proname, tp.prefix))
+		ORDER BY p.proname`,
+		m.schema, pq.StringArray(triggerPrefixes))
+	if err != nil {
+		return nil, err
+	}
+
+	var functions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		functions = append(functions, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(functions) == 0 {
+		return nil, nil
+	}
+
+	if err := migrations.NewDropFunctionAction(m.pgConn, functions...).Execute(ctx); err != nil {
+		return nil, fmt.Errorf("unable to drop trigger functions: %w", err)
+	}
+
+	return functions, nil
+}
+
+// viewWithClause returns the WITH clause of the view that exposes the named
+// table in version schemas, or an empty string if the view has no options.
+func (m *Roll) viewWithClause(name string) string {
+	// Create views with the security_invoker option for PG 15+
+	//
+	// This ensures that any row level security permissions on the underlying
+	// table are respected. `security_invoker` views are not supported in PG 14
+	// and below. The option can be disabled with `WithSecurityInvokerViews`,
+	// or for a single view with `WithViewOptions`.
+	opts := m.viewOptions[name]
+	securityInvoker := !m.disableSecurityInvokerViews
+	if opts.SecurityInvoker != nil {
+		securityInvoker = *opts.SecurityInvoker
+	}
+
+	var options []string
+	if securityInvoker && m.PGVersion() >= PGVersion15 {
+		options = append(options, "security_invoker = true")
+	}
+	if opts.SecurityBarrier {
+		options = append(options, "security_barrier = true")
+	}
+
+	if len(options) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("WITH (%s)", strings.Join(options, ", "))
+}
+
 // create view creates a view for the new version of the schema
 func (m *Roll) ensureView(ctx context.Context, version, name string, table *schema.Table) error {
 	columns := make([]string, 0, len(table.Columns))
@@ -334,15 +961,7 @@ func (m *Roll) ensureView(ctx context.Context, version, name string, table *sche
 		}
 	}
 
-	// Create view with security_invoker option for PG 15+
-	//
-	// This ensures that any row level security permissions on the underlying
-	// table are respected. `security_invoker` views are not supported in PG 14
-	// and below.
-	withOptions := ""
-	if m.PGVersion() >= PGVersion15 {
-		withOptions = "WITH (security_invoker = true)"
-	}
+	withOptions := m.viewWithClause(name)
 
 	// We must set column default values for the views directly, as the
 	// values are not kept from the underlying tables.
@@ -354,8 +973,18 @@ func (m *Roll) ensureView(ctx context.Context, version, name string, table *sche
 			pq.QuoteIdentifier(column),
 			defaultVal)
 	}
+
+	// Transfer ownership of the view to the object owner, if one is set
+	var setViewOwner string
+	if m.objectOwner != "" {
+		setViewOwner = fmt.Sprintf("ALTER VIEW %s.%s OWNER TO %s; ",
+			pq.QuoteIdentifier(VersionedSchemaName(m.schema, version)),
+			pq.QuoteIdentifier(name),
+			pq.QuoteIdentifier(m.objectOwner))
+	}
+
 	_, err := m.pgConn.ExecContext(ctx,
-		fmt.Sprintf("BEGIN; DROP VIEW IF EXISTS %s.%s; CREATE VIEW %s.%s %s AS SELECT %s FROM %s; %s COMMIT",
+		fmt.Sprintf("BEGIN; DROP VIEW IF EXISTS %s.%s; CREATE VIEW %s.%s %s AS SELECT %s FROM %s; %s %s COMMIT",
 			pq.QuoteIdentifier(VersionedSchemaName(m.schema, version)),
 			pq.QuoteIdentifier(name),
 			pq.QuoteIdentifier(VersionedSchemaName(m.schema, version)),
@@ -363,23 +992,142 @@ func (m *Roll) ensureView(ctx context.Context, version, name string, table *sche
 			withOptions,
 			strings.Join(columns, ","),
 			pq.QuoteIdentifier(table.Name),
-			addDefaultsToView))
+			addDefaultsToView,
+			setViewOwner))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (m *Roll) performBackfills(ctx context.Context, job *backfill.Job, cfg *backfill.Config) error {
+// migrationActions returns the actions to execute for an operation of the
+// migration. For non-transactional migrations, actions that execute several
+// statements in one query are split so that each statement runs on its own,
+// outside of the implicit transaction that Postgres uses for such queries.
+// Index actions are made non-concurrent if concurrent indexes are disabled.
+func (m *Roll) migrationActions(migration *migrations.Migration, actions []migrations.DBAction) ([]migrations.DBAction, error) {
+	if m.disableConcurrentIndexes {
+		for _, action := range actions {
+			if idx, ok := action.(migrations.ConcurrentIndexAction); ok {
+				idx.WithoutConcurrently()
+			}
+		}
+	}
+
+	if migration.IsTransactional() {
+		return actions, nil
+	}
+
+	split := make([]migrations.DBAction, 0, len(actions))
+	for _, action := range actions {
+		multi, ok := action.(migrations.MultiStatementAction)
+		if !ok {
+			split = append(split, action)
+			continue
+		}
+		stmts, err := multi.Split()
+		if err != nil {
+			return nil, err
+		}
+		split = append(split, stmts...)
+	}
+	return split, nil
+}
+
+func (m *Roll) performBackfills(ctx context.Context, migration *migrations.Migration, job *backfill.Job, cfg *backfill.Config) error {
 	bf := backfill.New(m.pgConn, cfg)
+	bf.SetNaming(migrations.NamingFrom(ctx))
+	bf.AddTriggerCallback(m.logger.LogTriggerCreated)
+	bf.AddBatchCallback(m.logger.LogBackfillBatch)
+	bf.AddSkipCallback(m.logger.LogBackfillSkipped)
 
-	bf.CreateTriggers(ctx, job)
+	// Run the batches in a session of their own, so that its role and settings
+	// don't apply to the DDL run on the main connection
+	if cfg.SeparateSession() {
+		conn, err := m.openBackfillConn(ctx, cfg)
+		if err != nil {
+			errRollback := m.rollback(ctx)
+
+			return errors.Join(err, errRollback)
+		}
+		defer conn.Close()
+		bf.SetBatchConn(&db.RDB{DB: conn, IsRetryable: m.errorClassifier})
+	}
+
+	// Open a connection for each of the passes that backfill the columns of a
+	// table at once
+	if n := cfg.ColumnConcurrency(); n > 0 {
+		conns := make([]db.DB, 0, n)
+		for range n {
+			conn, err := m.openBackfillConn(ctx, cfg)
+			if err != nil {
+				errRollback := m.rollback(ctx)
 
+				return errors.Join(err, errRollback)
+			}
+			defer conn.Close()
+			conns = append(conns, &db.RDB{DB: conn, IsRetryable: m.errorClassifier})
+		}
+		bf.SetColumnConns(conns...)
+	}
+
+	// Open a connection for each of the ranges of a table's rows that are
+	// backfilled at once
+	if n := cfg.Parallelism(); n > 0 {
+		conns := make([]db.DB, 0, n)
+		for range n {
+			conn, err := m.openBackfillConn(ctx, cfg)
+			if err != nil {
+				errRollback := m.rollback(ctx)
+
+				return errors.Join(err, errRollback)
+			}
+			defer conn.Close()
+			conns = append(conns, &db.RDB{DB: conn, IsRetryable: m.errorClassifier})
+		}
+		bf.SetRangeConns(conns...)
+	}
+
+	// Record the progress of each backfill so that it can be resumed if the
+	// backfill is interrupted
+	tables := make([]string, 0, len(job.Tables))
 	for _, table := range job.Tables {
-		m.logger.LogBackfillStart(table.Name)
+		tables = append(tables, table.Name)
+	}
+	if err := m.state.StartBackfill(ctx, m.schema, migration.Name, tables); err != nil {
+		return err
+	}
+	bf.SetProgress(&backfillProgress{state: m.state, schema: m.schema, migration: migration.Name})
+
+	// Run the up and down SQL of the triggers once before creating them, so
+	// that errors in the SQL fail the migration before any row is backfilled
+	if err := bf.CheckTriggers(ctx, job); err != nil {
+		errRollback := m.rollback(ctx)
 
-		if err := bf.Start(ctx, table); err != nil {
-			errRollback := m.Rollback(ctx)
+		return errors.Join(
+			fmt.Errorf("unable to check backfill triggers: %w", err),
+			errRollback)
+	}
+
+	if err := bf.CreateTriggers(ctx, job); err != nil {
+		errRollback := m.rollback(ctx)
+
+		return errors.Join(
+			fmt.Errorf("unable to create backfill triggers: %w", err),
+			errRollback)
+	}
+
+	for _, table := range job.Tables {
+		// tables without a key are backfilled using the needs backfill column
+		key := bf.PagingKey(table)
+		if len(key) == 0 {
+			key = []string{pq.QuoteIdentifier(bf.NeedsBackfillColumn())}
+		}
+		m.logger.LogBackfillStart(table.Name, key)
+
+		if err := bf.Start(ctx, table, job.Filter(table.Name)); err != nil {
+			err = backfillError(bf.Naming(), table.Name, err)
+			errRollback := m.rollback(ctx)
 
 			return errors.Join(
 				fmt.Errorf("unable to backfill table %q: %w", table.Name, err),
@@ -389,9 +1137,48 @@ func (m *Roll) performBackfills(ctx context.Context, job *backfill.Job, cfg *bac
 		m.logger.LogBackfillComplete(table.Name)
 	}
 
+	if err := bf.VerifyReversible(ctx, job); err != nil {
+		errRollback := m.rollback(ctx)
+
+		return errors.Join(
+			fmt.Errorf("unable to verify reversibility of backfilled columns: %w", err),
+			errRollback)
+	}
+
 	return nil
 }
 
+// openBackfillConn opens a connection limited to a single session, and sets
+// the session up as configured for the batches of the backfill.
+func (m *Roll) openBackfillConn(ctx context.Context, cfg *backfill.Config) (db.Conn, error) {
+	conn, err := m.openConn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open connection for backfill: %w", err)
+	}
+
+	if err := cfg.SetupSession(ctx, &db.RDB{DB: conn, IsRetryable: m.errorClassifier}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+const checkViolationErrorCode pq.ErrorCode = "23514"
+
+// backfillError reports a violation of the NOT NULL constraint that pgroll
+// adds to a column as a BackfillNullValuesError, as it means that the `up` SQL
+// returned NULL for some of the rows being backfilled.
+func backfillError(naming backfill.Naming, table string, err error) error {
+	pqErr := &pq.Error{}
+	if errors.As(err, &pqErr) && pqErr.Code == checkViolationErrorCode && naming.IsNotNullConstraintName(pqErr.Constraint) {
+		return migrations.BackfillNullValuesError{
+			Table:  table,
+			Column: naming.StripNotNullConstraintPrefix(pqErr.Constraint),
+		}
+	}
+	return err
+}
+
 func VersionedSchemaName(schema string, version string) string {
 	return schema + "_" + version
 }
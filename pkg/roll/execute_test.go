@@ -5,15 +5,19 @@ package roll_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/xataio/pgroll/internal/testutils"
 	"github.com/xataio/pgroll/pkg/backfill"
+	pgrolldb "github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/migrations"
 	"github.com/xataio/pgroll/pkg/roll"
 	"github.com/xataio/pgroll/pkg/state"
@@ -343,6 +347,140 @@ func TestRollbackOnMigrationStartFailure(t *testing.T) {
 	})
 }
 
+func TestRenamesAreRolledBackWhenStartIsInterrupted(t *testing.T) {
+	t.Parallel()
+
+	// A migration that renames a table and one of its columns around adding a
+	// column to the renamed table
+	renameMigration := func() *migrations.Migration {
+		return &migrations.Migration{
+			Name: "02_rename_table",
+			Operations: migrations.Operations{
+				&migrations.OpRenameTable{From: "table1", To: "people"},
+				addColumnOp("people"),
+				&migrations.OpRenameColumn{Table: "people", From: "name", To: "full_name"},
+			},
+		}
+	}
+
+	for _, killAt := range []int{1, 2} {
+		t.Run(fmt.Sprintf("when killed before operation %d", killAt), func(t *testing.T) {
+			t.Parallel()
+
+			logger := &killingLogger{Logger: migrations.NewNoopLogger(), migration: "02_rename_table", at: killAt}
+			opts := []roll.Option{roll.WithLogger(logger)}
+
+			testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, cSchema, opts, func(mig *roll.Roll, db *sql.DB) {
+				ctx := context.Background()
+
+				err := mig.Start(ctx, &migrations.Migration{
+					Name:       "01_create_table",
+					Operations: migrations.Operations{createTableOp("table1")},
+				}, backfill.NewConfig())
+				require.NoError(t, err)
+				require.NoError(t, mig.Complete(ctx))
+
+				_, err = db.ExecContext(ctx, "INSERT INTO table1 (id, name) VALUES (1, 'alice')")
+				require.NoError(t, err)
+
+				// Start the migration and kill it between two of its operations
+				require.PanicsWithValue(t, errKilled, func() {
+					_ = mig.Start(ctx, renameMigration(), backfill.NewConfig())
+				})
+
+				require.NoError(t, mig.Rollback(ctx))
+
+				// The previous migration is the latest one again
+				status, err := mig.Status(ctx, cSchema)
+				require.NoError(t, err)
+				assert.Equal(t, "01_create_table", status.Version)
+				assert.Equal(t, roll.CompleteMigrationStatus, status.Status)
+
+				// The table has its original name and columns
+				assert.True(t, tableExists(t, db, cSchema, "table1"))
+				assert.False(t, tableExists(t, db, cSchema, "people"))
+				assert.Equal(t, []string{"id", "name"}, tableColumns(t, db, cSchema, "table1"))
+				assert.False(t, schemaExists(t, db, roll.VersionedSchemaName(cSchema, "02_rename_table")))
+
+				// The old version schema still presents the table under its old name
+				rows := MustSelect(t, db, cSchema, "01_create_table", "table1")
+				assert.Equal(t, []map[string]any{{"id": 1, "name": "alice"}}, rows)
+
+				// The migration can be started and completed again
+				require.NoError(t, mig.Start(ctx, renameMigration(), backfill.NewConfig()))
+				require.NoError(t, mig.Complete(ctx))
+
+				rows = MustSelect(t, db, cSchema, "02_rename_table", "people")
+				assert.Equal(t, []map[string]any{{"id": 1, "full_name": "alice", "age": nil}}, rows)
+			})
+		})
+	}
+
+	t.Run("the old names are presented until the migration completes", func(t *testing.T) {
+		t.Parallel()
+
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			err := mig.Start(ctx, &migrations.Migration{
+				Name:       "01_create_table",
+				Operations: migrations.Operations{createTableOp("table1")},
+			}, backfill.NewConfig())
+			require.NoError(t, err)
+			require.NoError(t, mig.Complete(ctx))
+
+			_, err = db.ExecContext(ctx, "INSERT INTO table1 (id, name) VALUES (1, 'alice')")
+			require.NoError(t, err)
+
+			require.NoError(t, mig.Start(ctx, renameMigration(), backfill.NewConfig()))
+
+			// Both versions of the schema present the table under their own names
+			rows := MustSelect(t, db, cSchema, "01_create_table", "table1")
+			assert.Equal(t, []map[string]any{{"id": 1, "name": "alice"}}, rows)
+			rows = MustSelect(t, db, cSchema, "02_rename_table", "people")
+			assert.Equal(t, []map[string]any{{"id": 1, "full_name": "alice", "age": nil}}, rows)
+
+			require.NoError(t, mig.Rollback(ctx))
+
+			// Rolling back leaves the old version schema in place
+			rows = MustSelect(t, db, cSchema, "01_create_table", "table1")
+			assert.Equal(t, []map[string]any{{"id": 1, "name": "alice"}}, rows)
+			assert.Equal(t, []string{"id", "name"}, tableColumns(t, db, cSchema, "table1"))
+		})
+	})
+}
+
+// errKilled is the value with which killingLogger panics.
+var errKilled = errors.New("killed")
+
+// killingLogger panics when the operation at index `at` of `migration` starts,
+// leaving the migration as it would be if pgroll was killed between two of its
+// operations. It only panics once, so the migration can be started again.
+type killingLogger struct {
+	migrations.Logger
+
+	migration string
+	at        int
+
+	current string
+	started int
+	killed  bool
+}
+
+func (l *killingLogger) LogMigrationStart(m *migrations.Migration) {
+	l.current, l.started = m.Name, 0
+	l.Logger.LogMigrationStart(m)
+}
+
+func (l *killingLogger) LogOperationStart(op migrations.Operation) {
+	if l.current == l.migration && l.started == l.at && !l.killed {
+		l.killed = true
+		panic(errKilled)
+	}
+	l.started++
+	l.Logger.LogOperationStart(op)
+}
+
 func TestSchemaOptionIsRespected(t *testing.T) {
 	t.Parallel()
 
@@ -531,6 +669,80 @@ func TestViewsAreCreatedWithSecurityInvokerTrue(t *testing.T) {
 	})
 }
 
+func TestViewsAreCreatedWithoutSecurityInvokerWhenDisabled(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithSecurityInvokerViews(false)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+		version := "1_create_table"
+
+		if mig.PGVersion() < roll.PGVersion15 {
+			t.Skip("Skipping test for postgres < 15 as `security_invoker` views are not supported")
+		}
+
+		// Start a migration to create a simple `users` table
+		if err := mig.Start(ctx, &migrations.Migration{Name: version, Operations: migrations.Operations{createTableOp("users")}}, backfill.NewConfig()); err != nil {
+			t.Fatalf("Failed to start migration: %v", err)
+		}
+
+		// Get the options set on the versioned view
+		var reloptions sql.NullString
+		err := db.QueryRowContext(ctx, `SELECT array_to_string(reloptions, ',')
+			FROM pg_catalog.pg_class
+			WHERE oid = 'public_1_create_table.users'::regclass`).Scan(&reloptions)
+		if err != nil {
+			t.Fatalf("Failed to get view options: %v", err)
+		}
+
+		// Ensure that the view is not a `security_invoker` view
+		assert.False(t, reloptions.Valid, "expected no view options, got %q", reloptions.String)
+	})
+}
+
+func TestViewOptionsAreSetPerView(t *testing.T) {
+	t.Parallel()
+
+	securityInvoker := false
+	opts := []roll.Option{roll.WithViewOptions("users", roll.ViewOptions{
+		SecurityInvoker: &securityInvoker,
+		SecurityBarrier: true,
+	})}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Start a migration to create a `users` and an `orders` table
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "1_create_tables",
+			Operations: migrations.Operations{createTableOp("users"), createTableOp("orders")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		viewOptions := func(view string) sql.NullString {
+			t.Helper()
+
+			var reloptions sql.NullString
+			err := db.QueryRowContext(ctx, `SELECT array_to_string(reloptions, ',')
+				FROM pg_catalog.pg_class
+				WHERE oid = $1::regclass`, "public_1_create_tables."+view).Scan(&reloptions)
+			require.NoError(t, err)
+			return reloptions
+		}
+
+		// The `users` view has its own options
+		assert.Equal(t, sql.NullString{String: "security_barrier=true", Valid: true}, viewOptions("users"))
+
+		// The `orders` view has the options set for all views
+		if mig.PGVersion() >= roll.PGVersion15 {
+			assert.Equal(t, sql.NullString{String: "security_invoker=true", Valid: true}, viewOptions("orders"))
+		} else {
+			assert.False(t, viewOptions("orders").Valid)
+		}
+	})
+}
+
 func TestStatusMethodReturnsCorrectStatus(t *testing.T) {
 	t.Parallel()
 
@@ -559,167 +771,1742 @@ func TestStatusMethodReturnsCorrectStatus(t *testing.T) {
 		status, err = mig.Status(ctx, "public")
 		assert.NoError(t, err)
 
-		// Ensure that the status shows "In progress"
-		assert.Equal(t, &roll.Status{
-			Schema:  "public",
-			Version: "01_create_table",
-			Status:  roll.InProgressMigrationStatus,
-		}, status)
+		// Ensure that the status shows "In progress"
+		assert.Equal(t, &roll.Status{
+			Schema:  "public",
+			Version: "01_create_table",
+			Status:  roll.InProgressMigrationStatus,
+		}, status)
+
+		// Rollback the migration
+		err = mig.Rollback(ctx)
+		assert.NoError(t, err)
+
+		// Get the migration status
+		status, err = mig.Status(ctx, "public")
+		assert.NoError(t, err)
+
+		// Ensure that the status shows "No migrations"
+		assert.Equal(t, &roll.Status{
+			Schema:  "public",
+			Version: "",
+			Status:  roll.NoneMigrationStatus,
+		}, status)
+
+		// Start and complete a migration
+		err = mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: []migrations.Operation{createTableOp("table1")},
+		}, backfill.NewConfig())
+		assert.NoError(t, err)
+		err = mig.Complete(ctx)
+		assert.NoError(t, err)
+
+		// Get the migration status
+		status, err = mig.Status(ctx, "public")
+		assert.NoError(t, err)
+
+		// The status includes the hash of the schema after the completed migration
+		schemaHash, err := mig.State().SchemaHash(ctx, "public", "01_create_table")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, schemaHash)
+
+		// Ensure that the status shows "Complete"
+		assert.Equal(t, &roll.Status{
+			Schema:     "public",
+			Version:    "01_create_table",
+			Status:     roll.CompleteMigrationStatus,
+			SchemaHash: schemaHash,
+		}, status)
+	})
+}
+
+func TestMigrationProgress(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err = db.ExecContext(ctx, "INSERT INTO table1 (id, name) SELECT i, 'name ' || i FROM generate_series(1, 5) AS i")
+		require.NoError(t, err)
+
+		addColumn := addColumnOp("table1")
+		addColumn.Up = "length(name)"
+		err = mig.Start(ctx, &migrations.Migration{
+			Name:       "02_add_column",
+			Operations: migrations.Operations{addColumn},
+		}, backfill.NewConfig(backfill.WithBatchSize(2)))
+		require.NoError(t, err)
+
+		// The progress of an active migration includes its operations and the
+		// backfill of each of its tables
+		progress, err := mig.MigrationProgress(ctx, "public", "02_add_column")
+		require.NoError(t, err)
+		assert.Equal(t, roll.InProgressMigrationStatus, progress.Status)
+		assert.Equal(t, []string{"add_column"}, progress.Operations)
+		assert.False(t, progress.StartedAt.IsZero())
+		require.Len(t, progress.Backfill, 1)
+		assert.Equal(t, "table1", progress.Backfill[0].Table)
+		assert.Equal(t, int64(5), progress.Backfill[0].RowsDone)
+		assert.True(t, progress.Backfill[0].Done)
+		assert.False(t, progress.Terminal())
+
+		require.NoError(t, mig.Complete(ctx))
+
+		progress, err = mig.MigrationProgress(ctx, "public", "02_add_column")
+		require.NoError(t, err)
+		assert.Equal(t, roll.CompleteMigrationStatus, progress.Status)
+		assert.True(t, progress.Terminal())
+
+		// A migration that has been rolled back is no longer recorded
+		err = mig.Start(ctx, &migrations.Migration{
+			Name:       "03_create_table",
+			Operations: migrations.Operations{createTableOp("table2")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Rollback(ctx))
+
+		progress, err = mig.MigrationProgress(ctx, "public", "03_create_table")
+		require.NoError(t, err)
+		assert.Equal(t, roll.RolledBackMigrationStatus, progress.Status)
+		assert.True(t, progress.Terminal())
+	})
+}
+
+func TestRoleIsRespected(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", []roll.Option{roll.WithRole("pgroll")}, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Start a create table migration
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		assert.NoError(t, err)
+
+		// Complete the create table migration
+		err = mig.Complete(ctx)
+		assert.NoError(t, err)
+
+		// Ensure that the table exists in the correct schema and owned by the correct role
+		var exists bool
+		err = db.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1
+				FROM pg_catalog.pg_tables
+				WHERE tablename = $1
+					AND schemaname = $2
+					AND tableowner = $3
+		)`, "table1", "public", "pgroll").Scan(&exists)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestObjectOwnerIsRespected(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", []roll.Option{roll.WithObjectOwner("pgroll")}, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Start a create table migration
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Ensure that the table is owned by the object owner
+		var tableOwner string
+		err = db.QueryRowContext(ctx, "SELECT tableowner FROM pg_catalog.pg_tables WHERE schemaname = 'public' AND tablename = 'table1'").
+			Scan(&tableOwner)
+		require.NoError(t, err)
+		assert.Equal(t, "pgroll", tableOwner)
+
+		// Ensure that the version schema is owned by the object owner
+		var schemaOwner string
+		err = db.QueryRowContext(ctx, "SELECT pg_get_userbyid(nspowner) FROM pg_catalog.pg_namespace WHERE nspname = 'public_01_create_table'").
+			Scan(&schemaOwner)
+		require.NoError(t, err)
+		assert.Equal(t, "pgroll", schemaOwner)
+
+		// Ensure that the version schema view is owned by the object owner
+		var viewOwner string
+		err = db.QueryRowContext(ctx, "SELECT viewowner FROM pg_catalog.pg_views WHERE schemaname = 'public_01_create_table' AND viewname = 'table1'").
+			Scan(&viewOwner)
+		require.NoError(t, err)
+		assert.Equal(t, "pgroll", viewOwner)
+	})
+}
+
+func TestObjectOwnerCanBeOverriddenByCreateTableOperation(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", []roll.Option{roll.WithObjectOwner("pgroll")}, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a role to own the table
+		_, err := db.ExecContext(ctx, "CREATE ROLE table1_owner")
+		require.NoError(t, err)
+
+		// Start a create table migration that sets its own owner
+		op := createTableOp("table1")
+		op.Owner = "table1_owner"
+		err = mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{op},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Ensure that the table is owned by the role given in the operation
+		var tableOwner string
+		err = db.QueryRowContext(ctx, "SELECT tableowner FROM pg_catalog.pg_tables WHERE schemaname = 'public' AND tablename = 'table1'").
+			Scan(&tableOwner)
+		require.NoError(t, err)
+		assert.Equal(t, "table1_owner", tableOwner)
+	})
+}
+
+func TestObjectOwnerIsRespectedByCreateTableAsOperation(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", []roll.Option{roll.WithObjectOwner("pgroll")}, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Start a create table as migration
+		err := mig.Start(ctx, &migrations.Migration{
+			Name: "01_create_table_as",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTableAs{Name: "table1", Query: "SELECT 1 AS id"},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Ensure that the table is owned by the object owner
+		var tableOwner string
+		err = db.QueryRowContext(ctx, "SELECT tableowner FROM pg_catalog.pg_tables WHERE schemaname = 'public' AND tablename = 'table1'").
+			Scan(&tableOwner)
+		require.NoError(t, err)
+		assert.Equal(t, "pgroll", tableOwner)
+	})
+}
+
+func TestObjectOwnerIsRespectedByCreatePartitionOperation(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", []roll.Option{roll.WithObjectOwner("pgroll")}, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a partitioned table
+		err := mig.Start(ctx, &migrations.Migration{
+			Name: "01_create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name: "orders",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "region", Type: "text"},
+					},
+					PartitionBy: &migrations.PartitionBy{
+						Type:    migrations.PartitionByTypeList,
+						Columns: []string{"region"},
+					},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Start a create partition migration
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_create_partition",
+			Operations: migrations.Operations{
+				&migrations.OpCreatePartition{Table: "orders", Name: "orders_eu", Bound: "IN ('eu')"},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Ensure that the partition is owned by the object owner
+		var tableOwner string
+		err = db.QueryRowContext(ctx, "SELECT tableowner FROM pg_catalog.pg_tables WHERE schemaname = 'public' AND tablename = 'orders_eu'").
+			Scan(&tableOwner)
+		require.NoError(t, err)
+		assert.Equal(t, "pgroll", tableOwner)
+	})
+}
+
+func TestObjectOwnerIsRespectedByCreateForeignTableOperation(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", []roll.Option{roll.WithObjectOwner("pgroll")}, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a foreign server; creating a foreign table does not connect
+		// to it
+		_, err := db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS postgres_fdw;
+			CREATE SERVER IF NOT EXISTS remote FOREIGN DATA WRAPPER postgres_fdw OPTIONS (host 'localhost', dbname 'remote')`)
+		require.NoError(t, err)
+
+		// Start a create foreign table migration
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "01_create_foreign_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateForeignTable{
+					Name:    "remote_users",
+					Server:  "remote",
+					Columns: []migrations.ForeignTableColumn{{Name: "id", Type: "integer"}},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Ensure that the foreign table is owned by the object owner
+		var tableOwner string
+		err = db.QueryRowContext(ctx, "SELECT pg_get_userbyid(relowner) FROM pg_catalog.pg_class WHERE oid = 'public.remote_users'::regclass").
+			Scan(&tableOwner)
+		require.NoError(t, err)
+		assert.Equal(t, "pgroll", tableOwner)
+	})
+}
+
+func TestCreateTableOperationWithNonExistentOwnerIsRejected(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		op := createTableOp("table1")
+		op.Owner = "no_such_role"
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{op},
+		}, backfill.NewConfig())
+		assert.ErrorIs(t, err, roll.ErrRoleDoesNotExist)
+
+		// Ensure that the table was not created
+		var exists bool
+		err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pg_catalog.pg_tables WHERE schemaname = 'public' AND tablename = 'table1')").
+			Scan(&exists)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestNonExistentObjectOwnerIsRejected(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithConnectionToContainer(t, func(db *sql.DB, connStr string) {
+		ctx := context.Background()
+
+		st, err := state.New(ctx, connStr, "pgroll")
+		require.NoError(t, err)
+
+		_, err = roll.New(ctx, connStr, "public", st, roll.WithObjectOwner("no_such_role"))
+		assert.ErrorIs(t, err, roll.ErrRoleDoesNotExist)
+	})
+}
+
+func TestOperationsAreReorderedWhenEnabled(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithReorderOperations(true)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Add a column referencing the table before the table is created
+		err := mig.Start(ctx, &migrations.Migration{
+			Name: "01_create_tables",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "table1",
+					Column: migrations.Column{
+						Name:     "table2_id",
+						Type:     "integer",
+						Nullable: true,
+						References: &migrations.ForeignKeyReference{
+							Name:   "fk_table2",
+							Table:  "table2",
+							Column: "id",
+						},
+					},
+				},
+				createTableOp("table2"),
+				createTableOp("table1"),
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Ensure that the foreign key column was added
+		var exists bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS(
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'table1' AND column_name = 'table2_id')`).Scan(&exists)
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestCompleteWithKeepTriggersLeavesDisabledTriggersUntilCleanup(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithKeepTriggers(true)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Change the type of a column, which requires up and down triggers
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_alter_column",
+			Operations: migrations.Operations{
+				&migrations.OpAlterColumn{
+					Table:  "table1",
+					Column: "name",
+					Type:   ptr("text"),
+					Up:     "name",
+					Down:   "name",
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Ensure that the triggers were kept, but are disabled
+		var kept, enabled int
+		err = db.QueryRowContext(ctx, `SELECT count(*), count(*) FILTER (WHERE tgenabled <> 'D')
+			FROM pg_catalog.pg_trigger
+			WHERE tgrelid = 'public.table1'::regclass AND starts_with(tgname, '_pgroll_trigger_')`).
+			Scan(&kept, &enabled)
+		require.NoError(t, err)
+		assert.Positive(t, kept)
+		assert.Zero(t, enabled)
+
+		// Ensure that the table can still be written to
+		_, err = db.ExecContext(ctx, "INSERT INTO public.table1 (id, name) VALUES (1, 'alice')")
+		require.NoError(t, err)
+
+		// Remove the kept triggers
+		functions, err := mig.Cleanup(ctx)
+		require.NoError(t, err)
+		assert.NotEmpty(t, functions)
+
+		// Ensure that no pgroll trigger functions remain
+		var remaining int
+		err = db.QueryRowContext(ctx, `SELECT count(*)
+			FROM pg_catalog.pg_proc
+			WHERE pronamespace = 'public'::regnamespace AND starts_with(proname, '_pgroll_trigger_')`).
+			Scan(&remaining)
+		require.NoError(t, err)
+		assert.Zero(t, remaining)
+	})
+}
+
+func TestCompleteWithKeepTriggersDisablesTriggersBeforeCompleteDDL(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithKeepTriggers(true)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Change the type of a column, and write to the table once the column
+		// has been renamed into place by the completion
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_alter_column",
+			Operations: migrations.Operations{
+				&migrations.OpAlterColumn{
+					Table:  "table1",
+					Column: "name",
+					Type:   ptr("text"),
+					Up:     "name",
+					Down:   "name",
+				},
+				&migrations.OpRawSQL{
+					Up:         "INSERT INTO table1 (id, name) VALUES (1, 'alice')",
+					OnComplete: true,
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// The write succeeds, as the kept triggers, which refer to the
+		// renamed column, were disabled before the column was renamed
+		require.NoError(t, mig.Complete(ctx))
+
+		rows := MustSelect(t, db, "public", "02_alter_column", "table1")
+		assert.Equal(t, []map[string]any{{"id": 1, "name": "alice"}}, rows)
+	})
+}
+
+func TestMigrationHooksAreInvoked(t *testing.T) {
+	t.Parallel()
+
+	options := []roll.Option{roll.WithMigrationHooks(roll.MigrationHooks{
+		BeforeStartDDL: func(m *roll.Roll) error {
+			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE before_start_ddl (id integer)")
+			return err
+		},
+		AfterStartDDL: func(m *roll.Roll) error {
+			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE after_start_ddl (id integer)")
+			return err
+		},
+		BeforeCompleteDDL: func(m *roll.Roll) error {
+			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE before_complete_ddl (id integer)")
+			return err
+		},
+		AfterCompleteDDL: func(m *roll.Roll) error {
+			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE after_complete_ddl (id integer)")
+			return err
+		},
+	})}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", options, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Start a create table migration
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		assert.NoError(t, err)
+
+		// Ensure that both the before_start_ddl and after_start_ddl tables were created
+		assert.True(t, tableExists(t, db, "public", "before_start_ddl"))
+		assert.True(t, tableExists(t, db, "public", "after_start_ddl"))
+
+		// Complete the migration
+		err = mig.Complete(ctx)
+		assert.NoError(t, err)
+
+		// Ensure that both the before_complete_ddl and after_complete_ddl tables were created
+		assert.True(t, tableExists(t, db, "public", "before_complete_ddl"))
+		assert.True(t, tableExists(t, db, "public", "after_complete_ddl"))
+	})
+}
+
+func TestSchemaChangesMadeByHooksAreSeenByTheMigration(t *testing.T) {
+	t.Parallel()
+
+	options := []roll.Option{roll.WithMigrationHooks(roll.MigrationHooks{
+		BeforeStartDDL: func(m *roll.Roll) error {
+			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE before_start_ddl (id integer)")
+			return err
+		},
+	})}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", options, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Start a migration; the schema is read before and after the hook runs
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Ensure that the table created by the hook has a view in the new
+		// version schema
+		var exists bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS(
+			SELECT 1 FROM pg_catalog.pg_views
+			WHERE schemaname = 'public_01_create_table' AND viewname = 'before_start_ddl'
+		)`).Scan(&exists)
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestConstraintsAreValidatedBeforeCompleteDDL(t *testing.T) {
+	t.Parallel()
+
+	// Record whether the check constraint has been validated by the time the
+	// DDL phase of migration completion begins
+	var validatedBeforeDDL bool
+	options := []roll.Option{roll.WithMigrationHooks(roll.MigrationHooks{
+		BeforeCompleteDDL: func(m *roll.Roll) error {
+			rows, err := m.PgConn().QueryContext(context.Background(),
+				"SELECT convalidated FROM pg_catalog.pg_constraint WHERE conname = 'age_check'")
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				if err := rows.Scan(&validatedBeforeDDL); err != nil {
+					return err
+				}
+			}
+			return rows.Err()
+		},
+	})}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", options, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("table1")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		err = mig.Complete(ctx)
+		require.NoError(t, err)
+
+		// Start a migration that adds a column with a check constraint
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "table1",
+					Column: migrations.Column{
+						Name:     "age",
+						Type:     "integer",
+						Nullable: true,
+						Check: &migrations.CheckConstraint{
+							Name:       "age_check",
+							Constraint: "age > 0",
+						},
+					},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// Complete the migration
+		err = mig.Complete(ctx)
+		require.NoError(t, err)
+
+		// Ensure that the constraint was validated before the DDL phase began
+		assert.True(t, validatedBeforeDDL)
+	})
+}
+
+func TestCallbacksAreInvokedOnMigrationStart(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table
+		_, err := db.ExecContext(ctx, "CREATE TABLE users (id SERIAL PRIMARY KEY, name text)")
+		require.NoError(t, err)
+
+		// Insert some data
+		_, err = db.ExecContext(ctx,
+			"INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')")
+		require.NoError(t, err)
+
+		// Define a mock callback
+		invoked := false
+		cb := func(n, total int64) { invoked = true }
+
+		backfillConfig := backfill.NewConfig()
+		backfillConfig.AddCallback(cb)
+
+		// Start a migration that requires a backfill
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_change_type",
+			Operations: migrations.Operations{
+				&migrations.OpAlterColumn{
+					Table:  "users",
+					Column: "name",
+					Type:   ptr("varchar(255)"),
+					Up:     "name",
+					Down:   "name",
+				},
+			},
+		}, backfillConfig)
+		require.NoError(t, err)
+
+		// Ensure that the callback was invoked
+		assert.True(t, invoked)
+	})
+}
+
+func TestBackfillIsSkippedWhenNotNeeded(t *testing.T) {
+	t.Parallel()
+
+	// A migration that adds two columns to the same table, each backfilled
+	// for a different part of its rows
+	addColumnsMigration := func() *migrations.Migration {
+		return &migrations.Migration{
+			Name: "02_add_columns",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table:         "items",
+					Up:            "upper(name)",
+					BackfillWhere: "region = 'eu'",
+					Column:        migrations.Column{Name: "name_upper", Type: "text", Nullable: true},
+				},
+				&migrations.OpAddColumn{
+					Table:         "items",
+					Up:            "lower(name)",
+					BackfillWhere: "id > 8",
+					Column:        migrations.Column{Name: "name_lower", Type: "text", Nullable: true},
+				},
+			},
+		}
+	}
+
+	logger := &batchKillingLogger{Logger: migrations.NewNoopLogger(), at: 2}
+	opts := []roll.Option{roll.WithLogger(logger)}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, cSchema, opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		err := mig.Start(ctx, &migrations.Migration{
+			Name: "01_create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name: "items",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "serial", Pk: true},
+						{Name: "name", Type: "text"},
+						{Name: "region", Type: "text"},
+					},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err = db.ExecContext(ctx, `INSERT INTO items (name, region)
+			SELECT 'Item ' || i, CASE WHEN i <= 4 THEN 'eu' ELSE 'us' END FROM generate_series(1, 10) AS i`)
+		require.NoError(t, err)
+
+		cfg := func() *backfill.Config {
+			return backfill.NewConfig(backfill.WithBatchSize(2), backfill.WithOnlyIfNeeded(true))
+		}
+
+		// Kill the start of the migration once the second batch, the last one
+		// with rows in the eu region, has been committed
+		require.PanicsWithValue(t, errKilled, func() {
+			_ = mig.Start(ctx, addColumnsMigration(), cfg())
+		})
+
+		// Starting the migration again only backfills the column whose rows
+		// are left to backfill
+		require.NoError(t, mig.Start(ctx, addColumnsMigration(), cfg()))
+		assert.Equal(t, []string{"1 (add_column)"}, logger.skipped)
+
+		rows := MustSelect(t, db, cSchema, "02_add_columns", "items")
+		require.Len(t, rows, 10)
+		for _, row := range rows {
+			id := row["id"].(int)
+			if id <= 4 {
+				assert.Equal(t, strings.ToUpper(row["name"].(string)), row["name_upper"])
+			} else {
+				assert.Nil(t, row["name_upper"])
+			}
+			if id > 8 {
+				assert.Equal(t, strings.ToLower(row["name"].(string)), row["name_lower"])
+			} else {
+				assert.Nil(t, row["name_lower"])
+			}
+		}
+	})
+}
+
+// batchKillingLogger panics once the given number of backfill batches have
+// been committed, as if the process running the migration had been killed,
+// and records the operations whose backfill is skipped.
+type batchKillingLogger struct {
+	migrations.Logger
+
+	at      int
+	batches int
+	skipped []string
+}
+
+func (l *batchKillingLogger) LogBackfillBatch(table string, rows int64, duration time.Duration) {
+	l.batches++
+	if l.batches == l.at {
+		panic(errKilled)
+	}
+	l.Logger.LogBackfillBatch(table, rows, duration)
+}
+
+func (l *batchKillingLogger) LogBackfillSkipped(table, operation string) {
+	l.skipped = append(l.skipped, operation)
+	l.Logger.LogBackfillSkipped(table, operation)
+}
+
+func TestBackfillPagesByBatchKey(t *testing.T) {
+	t.Parallel()
+
+	addColumnMigration := &migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "events",
+				Up:    "upper(name)",
+				Column: migrations.Column{
+					Name:     "name_upper",
+					Type:     "text",
+					Nullable: true,
+				},
+			},
+		},
+	}
+
+	t.Run("all rows are backfilled when paging by the batch key", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			// Create a table with an index on its natural ordering
+			_, err := db.ExecContext(ctx, "CREATE TABLE events (id SERIAL PRIMARY KEY, tenant_id int, created_at timestamptz, name text)")
+			require.NoError(t, err)
+			_, err = db.ExecContext(ctx, "CREATE INDEX idx_events_tenant_created ON events (tenant_id, created_at)")
+			require.NoError(t, err)
+
+			// Insert rows, several of which share the same batch key
+			_, err = db.ExecContext(ctx, `INSERT INTO events (tenant_id, created_at, name)
+				SELECT i % 3, '2024-01-01'::timestamptz + (i % 4) * interval '1 day', 'event ' || i
+				FROM generate_series(1, 25) AS i`)
+			require.NoError(t, err)
+
+			backfillConfig := backfill.NewConfig(
+				backfill.WithBatchSize(2),
+				backfill.WithBatchKey("events", "tenant_id", "created_at"),
+			)
+
+			err = mig.Start(ctx, addColumnMigration, backfillConfig)
+			require.NoError(t, err)
+
+			// Ensure that every row was backfilled
+			var pending int
+			err = db.QueryRowContext(ctx,
+				"SELECT count(*) FROM public_02_add_column.events WHERE name_upper IS DISTINCT FROM upper(name)").
+				Scan(&pending)
+			require.NoError(t, err)
+			assert.Equal(t, 0, pending)
+		})
+	})
+
+	t.Run("a batch key without a supporting index is rejected", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			_, err := db.ExecContext(ctx, "CREATE TABLE events (id SERIAL PRIMARY KEY, tenant_id int, created_at timestamptz, name text)")
+			require.NoError(t, err)
+			_, err = db.ExecContext(ctx, "INSERT INTO events (tenant_id, created_at, name) VALUES (1, now(), 'a')")
+			require.NoError(t, err)
+
+			backfillConfig := backfill.NewConfig(
+				backfill.WithBatchKey("events", "tenant_id", "created_at"),
+			)
+
+			err = mig.Start(ctx, addColumnMigration, backfillConfig)
+			assert.ErrorIs(t, err, backfill.BatchKeyIndexMissingError{Table: "events", Key: "tenant_id, created_at"})
+		})
+	})
+}
+
+func TestBackfillWideRows(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table with wide rows, more of them than fit in a batch
+		_, err := db.ExecContext(ctx, "CREATE TABLE documents (id SERIAL PRIMARY KEY, body text)")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, `INSERT INTO documents (body)
+			SELECT repeat(md5(i::text), 1000) FROM generate_series(1, 250) AS i`)
+		require.NoError(t, err)
+
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "documents",
+					Up:    "length(body)",
+					Column: migrations.Column{
+						Name:     "body_length",
+						Type:     "integer",
+						Nullable: true,
+					},
+				},
+			},
+		}, backfill.NewConfig(backfill.WithBatchSize(100)))
+		require.NoError(t, err)
+
+		// Ensure that every row was backfilled, including those of the last,
+		// partial batch
+		var pending int
+		err = db.QueryRowContext(ctx,
+			"SELECT count(*) FROM public_02_add_column.documents WHERE body_length IS DISTINCT FROM 32000").
+			Scan(&pending)
+		require.NoError(t, err)
+		assert.Equal(t, 0, pending)
+	})
+}
+
+func TestBackfillWithoutTriggers(t *testing.T) {
+	t.Parallel()
+
+	addColumnMigration := &migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "events",
+				Up:    "upper(name)",
+				Column: migrations.Column{
+					Name:     "name_upper",
+					Type:     "text",
+					Nullable: true,
+				},
+			},
+		},
+	}
+
+	triggerNames := func(t *testing.T, db *sql.DB) []string {
+		t.Helper()
+
+		rows, err := db.QueryContext(context.Background(), `SELECT tgname FROM pg_trigger
+			WHERE tgrelid = 'public.events'::regclass AND NOT tgisinternal ORDER BY tgname`)
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var names []string
+		for rows.Next() {
+			var name string
+			require.NoError(t, rows.Scan(&name))
+			names = append(names, name)
+		}
+		require.NoError(t, rows.Err())
+		return names
+	}
+
+	setup := func(t *testing.T, db *sql.DB) {
+		t.Helper()
+
+		_, err := db.ExecContext(context.Background(), "CREATE TABLE events (id SERIAL PRIMARY KEY, name text)")
+		require.NoError(t, err)
+		_, err = db.ExecContext(context.Background(), "INSERT INTO events (name) VALUES ('alice'), ('bob')")
+		require.NoError(t, err)
+	}
+
+	t.Run("the table is backfilled and guarded against writes", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, db)
+
+			err := mig.Start(ctx, addColumnMigration, backfill.NewConfig(backfill.WithoutTriggers("events")))
+			require.NoError(t, err)
+
+			// Every row was backfilled
+			var pending int
+			err = db.QueryRowContext(ctx,
+				"SELECT count(*) FROM public_02_add_column.events WHERE name_upper IS DISTINCT FROM upper(name)").
+				Scan(&pending)
+			require.NoError(t, err)
+			assert.Equal(t, 0, pending)
+
+			// The row trigger is kept for inserts, alongside the write guard
+			assert.ElementsMatch(t, []string{
+				backfill.TriggerFunctionName("events", "name_upper"),
+				backfill.WriteGuardName("events"),
+			}, triggerNames(t, db))
+
+			// Inserted rows are still filled in by the trigger
+			var nameUpper string
+			err = db.QueryRowContext(ctx, "INSERT INTO events (name) VALUES ('carl') RETURNING name_upper").
+				Scan(&nameUpper)
+			require.NoError(t, err)
+			assert.Equal(t, "CARL", nameUpper)
+
+			// Updates and deletes are rejected
+			_, err = db.ExecContext(ctx, "UPDATE events SET name = 'dave' WHERE id = 1")
+			require.Error(t, err)
+			_, err = db.ExecContext(ctx, "DELETE FROM events WHERE id = 1")
+			require.Error(t, err)
+
+			// Completing the migration removes the write guard
+			require.NoError(t, mig.Complete(ctx))
+			assert.Empty(t, triggerNames(t, db))
+
+			_, err = db.ExecContext(ctx, "UPDATE events SET name = 'dave' WHERE id = 1")
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("completing fails if the write guard was disabled", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, db)
+
+			err := mig.Start(ctx, addColumnMigration, backfill.NewConfig(backfill.WithoutTriggers("events")))
+			require.NoError(t, err)
+
+			// Rows updated while the guard is disabled are not seen by the
+			// trigger, so the migration can't be completed
+			_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE events DISABLE TRIGGER %s",
+				pq.QuoteIdentifier(backfill.WriteGuardName("events"))))
+			require.NoError(t, err)
+			_, err = db.ExecContext(ctx, "UPDATE events SET name = 'dave' WHERE id = 1")
+			require.NoError(t, err)
+
+			err = mig.Complete(ctx)
+			require.ErrorIs(t, err, roll.ErrWriteGuardDisabled)
+
+			// The migration can still be rolled back
+			require.NoError(t, mig.Rollback(ctx))
+			assert.Empty(t, triggerNames(t, db))
+		})
+	})
+
+	t.Run("rolling back removes the write guard", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, db)
+
+			err := mig.Start(ctx, addColumnMigration, backfill.NewConfig(backfill.WithoutTriggers("events")))
+			require.NoError(t, err)
+			require.NoError(t, mig.Rollback(ctx))
+
+			assert.Empty(t, triggerNames(t, db))
+
+			_, err = db.ExecContext(ctx, "INSERT INTO events (name) VALUES ('carl')")
+			require.NoError(t, err)
+		})
+	})
+}
+
+func TestBackfillWithAutovacuumDisabled(t *testing.T) {
+	t.Parallel()
+
+	addColumnMigration := &migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "events",
+				Up:    "upper(name)",
+				Column: migrations.Column{
+					Name:     "name_upper",
+					Type:     "text",
+					Nullable: true,
+				},
+			},
+		},
+	}
+
+	reloptions := func(t *testing.T, db *sql.DB) []string {
+		t.Helper()
+
+		var options []string
+		err := db.QueryRowContext(context.Background(),
+			"SELECT coalesce(reloptions, '{}') FROM pg_class WHERE oid = 'public.events'::regclass").
+			Scan(pq.Array(&options))
+		require.NoError(t, err)
+		return options
+	}
+
+	testCases := map[string]struct {
+		setupSQL string
+		want     []string
+	}{
+		"an unset autovacuum setting is reset after the backfill": {
+			setupSQL: "CREATE TABLE events (id SERIAL PRIMARY KEY, name text)",
+			want:     []string{},
+		},
+		"an explicit autovacuum setting is restored after the backfill": {
+			setupSQL: "CREATE TABLE events (id SERIAL PRIMARY KEY, name text) WITH (autovacuum_enabled = true)",
+			want:     []string{"autovacuum_enabled=true"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+				ctx := context.Background()
+
+				_, err := db.ExecContext(ctx, tc.setupSQL)
+				require.NoError(t, err)
+				_, err = db.ExecContext(ctx, "INSERT INTO events (name) VALUES ('alice'), ('bob')")
+				require.NoError(t, err)
+
+				// Record the table's storage parameters while it is backfilled
+				var duringBackfill []string
+				cfg := backfill.NewConfig(backfill.WithAutovacuumDisabled(true))
+				cfg.AddCallback(func(int64, int64) {
+					duringBackfill = reloptions(t, db)
+				})
+
+				err = mig.Start(ctx, addColumnMigration, cfg)
+				require.NoError(t, err)
+
+				assert.Equal(t, []string{"autovacuum_enabled=false"}, duringBackfill)
+				assert.Equal(t, tc.want, reloptions(t, db))
+			})
+		})
+	}
+}
+
+func TestBackfillWithIsolationLevel(t *testing.T) {
+	t.Parallel()
+
+	// The up SQL records the isolation level of the transaction that
+	// backfills each row
+	addColumnMigration := &migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "events",
+				Up:    "current_setting('transaction_isolation')",
+				Column: migrations.Column{
+					Name:     "isolation",
+					Type:     "text",
+					Nullable: true,
+				},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		level backfill.IsolationLevel
+		want  string
+	}{
+		"batches run at the session's default level": {
+			want: "read committed",
+		},
+		"batches run at REPEATABLE READ": {
+			level: backfill.IsolationLevelRepeatableRead,
+			want:  "repeatable read",
+		},
+		"batches run at SERIALIZABLE": {
+			level: backfill.IsolationLevelSerializable,
+			want:  "serializable",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+				ctx := context.Background()
+
+				_, err := db.ExecContext(ctx, `CREATE TABLE events (id SERIAL PRIMARY KEY, name text);
+					INSERT INTO events (name) VALUES ('alice'), ('bob'), ('carol')`)
+				require.NoError(t, err)
+
+				cfg := backfill.NewConfig(backfill.WithBatchSize(2), backfill.WithIsolationLevel(tc.level))
+				require.NoError(t, mig.Start(ctx, addColumnMigration, cfg))
+
+				rows, err := db.QueryContext(ctx, "SELECT DISTINCT _pgroll_new_isolation FROM events")
+				require.NoError(t, err)
+				defer rows.Close()
+
+				var levels []string
+				for rows.Next() {
+					var level string
+					require.NoError(t, rows.Scan(&level))
+					levels = append(levels, level)
+				}
+				require.NoError(t, rows.Err())
+				assert.Equal(t, []string{tc.want}, levels)
+			})
+		})
+	}
+}
+
+func TestBackfillInSeparateSession(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		_, err := db.ExecContext(ctx, `CREATE TABLE events (id SERIAL PRIMARY KEY, name text);
+			INSERT INTO events (name) VALUES ('alice'), ('bob'), ('carol')`)
+		require.NoError(t, err)
+
+		// The up SQL records the settings of the session that backfills each row
+		cfg := backfill.NewConfig(
+			backfill.WithBatchSize(2),
+			backfill.WithSessionSettings(map[string]string{
+				"application_name":   "pgroll_backfill",
+				"synchronous_commit": "off",
+			}),
+			backfill.WithSessionHook(func(ctx context.Context, conn pgrolldb.DB) error {
+				_, err := conn.ExecContext(ctx, "SET lock_timeout TO '1234ms'")
+				return err
+			}),
+		)
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "events",
+					Up: `current_setting('application_name') || ' ' ||
+						current_setting('synchronous_commit') || ' ' ||
+						current_setting('lock_timeout')`,
+					Column: migrations.Column{
+						Name:     "session",
+						Type:     "text",
+						Nullable: true,
+					},
+				},
+			},
+		}, cfg)
+		require.NoError(t, err)
+
+		var sessions []string
+		rows, err := db.QueryContext(ctx, "SELECT DISTINCT _pgroll_new_session FROM events")
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var session string
+			require.NoError(t, rows.Scan(&session))
+			sessions = append(sessions, session)
+		}
+		require.NoError(t, rows.Err())
+		assert.Equal(t, []string{"pgroll_backfill off 1234ms"}, sessions)
+
+		// The settings of the backfill session don't apply to the DDL session
+		settingRows, err := mig.PgConn().QueryContext(ctx, "SHOW application_name")
+		require.NoError(t, err)
+		defer settingRows.Close()
+		var applicationName string
+		require.NoError(t, pgrolldb.ScanFirstValue(settingRows, &applicationName))
+		assert.Equal(t, "pgroll", applicationName)
+	})
+}
+
+func TestBackfillColumnsConcurrently(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		_, err := db.ExecContext(ctx, `CREATE TABLE events (id SERIAL PRIMARY KEY, name text);
+			INSERT INTO events (name) VALUES ('alice'), ('bob'), ('carol'), ('dave'), ('eve')`)
+		require.NoError(t, err)
+
+		// The up SQL of each column records the backend that backfills it
+		addColumn := func(name string) *migrations.OpAddColumn {
+			return &migrations.OpAddColumn{
+				Table: "events",
+				Up:    "upper(name) || ' ' || pg_backend_pid()",
+				Column: migrations.Column{
+					Name:     name,
+					Type:     "text",
+					Nullable: true,
+				},
+			}
+		}
+		cfg := backfill.NewConfig(backfill.WithBatchSize(2), backfill.WithColumnConcurrency(2))
+		err = mig.Start(ctx, &migrations.Migration{
+			Name:       "02_add_columns",
+			Operations: migrations.Operations{addColumn("first"), addColumn("second")},
+		}, cfg)
+		require.NoError(t, err)
+
+		// Each column was backfilled in a pass of its own, on its own connection
+		rows, err := db.QueryContext(ctx, `SELECT _pgroll_new_first, _pgroll_new_second, _pgroll_needs_backfill
+			FROM events ORDER BY id`)
+		require.NoError(t, err)
+		defer rows.Close()
+		names := []string{"ALICE", "BOB", "CAROL", "DAVE", "EVE"}
+		for i := 0; rows.Next(); i++ {
+			var first, second string
+			var needsBackfill bool
+			require.NoError(t, rows.Scan(&first, &second, &needsBackfill))
+			assert.True(t, strings.HasPrefix(first, names[i]+" "))
+			assert.True(t, strings.HasPrefix(second, names[i]+" "))
+			assert.NotEqual(t, first, second)
+			assert.False(t, needsBackfill)
+		}
+		require.NoError(t, rows.Err())
+
+		require.NoError(t, mig.Complete(ctx))
+	})
+}
+
+func TestBackfillRangesInParallel(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		_, err := db.ExecContext(ctx, `CREATE TABLE events (id SERIAL PRIMARY KEY, name text);
+			INSERT INTO events (name) SELECT 'event ' || i FROM generate_series(1, 100) AS i`)
+		require.NoError(t, err)
+
+		// The progress of the ranges is reported together, one batch at a time
+		var done []int64
+		cfg := backfill.NewConfig(backfill.WithBatchSize(10), backfill.WithParallelism(4))
+		cfg.AddCallback(func(n, _ int64) {
+			done = append(done, n)
+		})
+
+		// The up SQL records the backend that backfills each row
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "events",
+					Up:    "pg_backend_pid()",
+					Column: migrations.Column{
+						Name:     "backend",
+						Type:     "integer",
+						Nullable: true,
+					},
+				},
+			},
+		}, cfg)
+		require.NoError(t, err)
+
+		// Every row was backfilled, by one backend for each range
+		var backfilled, backends int
+		err = db.QueryRowContext(ctx, `SELECT count(*) FILTER (WHERE NOT _pgroll_needs_backfill), count(DISTINCT _pgroll_new_backend)
+			FROM events`).Scan(&backfilled, &backends)
+		require.NoError(t, err)
+		assert.Equal(t, 100, backfilled)
+		assert.Equal(t, 4, backends)
+
+		require.NotEmpty(t, done)
+		assert.Equal(t, int64(100), done[len(done)-1])
+
+		require.NoError(t, mig.Complete(ctx))
+	})
+}
+
+func TestBackfillWithNeedsBackfillColumn(t *testing.T) {
+	t.Parallel()
+
+	addColumnMigration := &migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "events",
+				Up:    "upper(name)",
+				Column: migrations.Column{
+					Name:     "name_upper",
+					Type:     "text",
+					Nullable: true,
+				},
+			},
+		},
+	}
+
+	columnExists := func(t *testing.T, db *sql.DB, column string) bool {
+		t.Helper()
+
+		var exists bool
+		err := db.QueryRowContext(context.Background(), `SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'events' AND column_name = $1)`, column).
+			Scan(&exists)
+		require.NoError(t, err)
+		return exists
+	}
+
+	setup := func(t *testing.T, db *sql.DB) {
+		t.Helper()
+
+		_, err := db.ExecContext(context.Background(), "CREATE TABLE events (id SERIAL PRIMARY KEY, name text)")
+		require.NoError(t, err)
+		_, err = db.ExecContext(context.Background(), "INSERT INTO events (name) VALUES ('alice'), ('bob'), ('carl')")
+		require.NoError(t, err)
+	}
+
+	t.Run("rows are backfilled and marked in separate statements", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, db)
+
+			cfg := backfill.NewConfig(
+				backfill.WithBatchSize(2),
+				backfill.WithNeedsBackfillColumn("cdc_needs_backfill"),
+				backfill.WithSeparateBackfillMark(true),
+			)
+			err := mig.Start(ctx, addColumnMigration, cfg)
+			require.NoError(t, err)
+
+			// Every row was backfilled and marked as backfilled
+			var pending int
+			err = db.QueryRowContext(ctx,
+				"SELECT count(*) FROM events WHERE _pgroll_new_name_upper IS DISTINCT FROM upper(name) OR cdc_needs_backfill").
+				Scan(&pending)
+			require.NoError(t, err)
+			assert.Equal(t, 0, pending)
+			assert.False(t, columnExists(t, db, backfill.CNeedsBackfillColumn))
+
+			// Rows written through the new version of the schema are marked as
+			// backfilled by the triggers
+			_, err = db.ExecContext(ctx, "INSERT INTO events (name) VALUES ('dana')")
+			require.NoError(t, err)
+			err = db.QueryRowContext(ctx, "SELECT count(*) FROM events WHERE cdc_needs_backfill").Scan(&pending)
+			require.NoError(t, err)
+			assert.Equal(t, 0, pending)
+
+			// Completing the migration drops the column
+			require.NoError(t, mig.Complete(ctx))
+			assert.False(t, columnExists(t, db, "cdc_needs_backfill"))
+		})
+	})
+
+	t.Run("rolling back drops the column", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, db)
+
+			cfg := backfill.NewConfig(backfill.WithNeedsBackfillColumn("cdc_needs_backfill"))
+			err := mig.Start(ctx, addColumnMigration, cfg)
+			require.NoError(t, err)
+			require.NoError(t, mig.Rollback(ctx))
+
+			assert.False(t, columnExists(t, db, "cdc_needs_backfill"))
+		})
+	})
+
+	t.Run("a column with the same name is not taken over", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, db)
+
+			_, err := db.ExecContext(ctx, "ALTER TABLE events ADD COLUMN cdc_needs_backfill boolean")
+			require.NoError(t, err)
+
+			cfg := backfill.NewConfig(backfill.WithNeedsBackfillColumn("cdc_needs_backfill"))
+			err = mig.Start(ctx, addColumnMigration, cfg)
+			require.ErrorAs(t, err, &backfill.NeedsBackfillColumnConflictError{})
+
+			assert.True(t, columnExists(t, db, "cdc_needs_backfill"))
+		})
+	})
+}
+
+func TestBackfillWithAutoBatchSize(t *testing.T) {
+	t.Parallel()
+
+	addColumnMigration := &migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "events",
+				Up:    "upper(name)",
+				Column: migrations.Column{
+					Name:     "name_upper",
+					Type:     "text",
+					Nullable: true,
+				},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		analyze     bool
+		wantBatches func(t *testing.T, batches int)
+	}{
+		"batches are sized by the width of the table's rows": {
+			analyze: true,
+			wantBatches: func(t *testing.T, batches int) {
+				// The 100 rows fit on a single 8kB page, so a batch of 1kB
+				// updates no more than a fraction of them
+				assert.Greater(t, batches, 2)
+			},
+		},
+		"the batch size falls back to the default without statistics": {
+			analyze: false,
+			wantBatches: func(t *testing.T, batches int) {
+				// One batch updates all rows and the next finds none left
+				assert.Equal(t, 2, batches)
+			},
+		},
+	}
 
-		// Rollback the migration
-		err = mig.Rollback(ctx)
-		assert.NoError(t, err)
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+				ctx := context.Background()
 
-		// Get the migration status
-		status, err = mig.Status(ctx, "public")
-		assert.NoError(t, err)
+				_, err := db.ExecContext(ctx, "CREATE TABLE events (id SERIAL PRIMARY KEY, name text)")
+				require.NoError(t, err)
+				_, err = db.ExecContext(ctx, "INSERT INTO events (name) SELECT 'event-' || i FROM generate_series(1, 100) AS i")
+				require.NoError(t, err)
+				if tc.analyze {
+					_, err = db.ExecContext(ctx, "VACUUM ANALYZE events")
+					require.NoError(t, err)
+				}
 
-		// Ensure that the status shows "No migrations"
-		assert.Equal(t, &roll.Status{
-			Schema:  "public",
-			Version: "",
-			Status:  roll.NoneMigrationStatus,
-		}, status)
+				var batches int
+				cfg := backfill.NewConfig(backfill.WithAutoBatchSize(1024))
+				cfg.AddCallback(func(int64, int64) {
+					batches++
+				})
 
-		// Start and complete a migration
-		err = mig.Start(ctx, &migrations.Migration{
-			Name:       "01_create_table",
-			Operations: []migrations.Operation{createTableOp("table1")},
-		}, backfill.NewConfig())
-		assert.NoError(t, err)
-		err = mig.Complete(ctx)
-		assert.NoError(t, err)
+				err = mig.Start(ctx, addColumnMigration, cfg)
+				require.NoError(t, err)
 
-		// Get the migration status
-		status, err = mig.Status(ctx, "public")
-		assert.NoError(t, err)
+				tc.wantBatches(t, batches)
 
-		// Ensure that the status shows "Complete"
-		assert.Equal(t, &roll.Status{
-			Schema:  "public",
-			Version: "01_create_table",
-			Status:  roll.CompleteMigrationStatus,
-		}, status)
-	})
+				// All rows have been backfilled
+				var pending int
+				err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM events WHERE %s IS NULL",
+					pq.QuoteIdentifier(migrations.TemporaryName("name_upper")))).Scan(&pending)
+				require.NoError(t, err)
+				assert.Zero(t, pending)
+			})
+		})
+	}
 }
 
-func TestRoleIsRespected(t *testing.T) {
+func TestBackfillWithReversibilityCheck(t *testing.T) {
 	t.Parallel()
 
-	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", []roll.Option{roll.WithRole("pgroll")}, func(mig *roll.Roll, db *sql.DB) {
-		ctx := context.Background()
+	alterColumnMigration := func(down string) *migrations.Migration {
+		return &migrations.Migration{
+			Name: "02_alter_column",
+			Operations: migrations.Operations{
+				&migrations.OpAlterColumn{
+					Table:  "reviews",
+					Column: "rating",
+					Type:   ptr("text"),
+					Up:     "rating::text",
+					Down:   down,
+				},
+			},
+		}
+	}
 
-		// Start a create table migration
-		err := mig.Start(ctx, &migrations.Migration{
-			Name:       "01_create_table",
-			Operations: migrations.Operations{createTableOp("table1")},
-		}, backfill.NewConfig())
-		assert.NoError(t, err)
+	testCases := map[string]struct {
+		down    string
+		wantErr error
+	}{
+		"down SQL that reverses the up SQL passes the check": {
+			down: "rating::integer",
+		},
+		"down SQL that doesn't reverse the up SQL fails the check": {
+			down: "length(rating)",
+			wantErr: backfill.ReversibilityMismatchError{
+				Table:      "reviews",
+				Column:     "rating",
+				Mismatches: 9,
+				Sampled:    10,
+			},
+		},
+	}
 
-		// Complete the create table migration
-		err = mig.Complete(ctx)
-		assert.NoError(t, err)
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+				ctx := context.Background()
 
-		// Ensure that the table exists in the correct schema and owned by the correct role
-		var exists bool
-		err = db.QueryRow(`
-			SELECT EXISTS(
-				SELECT 1
-				FROM pg_catalog.pg_tables
-				WHERE tablename = $1
-					AND schemaname = $2
-					AND tableowner = $3
-		)`, "table1", "public", "pgroll").Scan(&exists)
-		assert.NoError(t, err)
-		assert.True(t, exists)
-	})
+				_, err := db.ExecContext(ctx, "CREATE TABLE reviews (id SERIAL PRIMARY KEY, rating integer)")
+				require.NoError(t, err)
+				_, err = db.ExecContext(ctx, "INSERT INTO reviews (rating) SELECT i FROM generate_series(1, 10) AS i")
+				require.NoError(t, err)
+
+				cfg := backfill.NewConfig(backfill.WithReversibilityCheck(backfill.DefaultVerifySampleSize))
+				err = mig.Start(ctx, alterColumnMigration(tc.down), cfg)
+				if tc.wantErr == nil {
+					require.NoError(t, err)
+					return
+				}
+				require.ErrorIs(t, err, tc.wantErr)
+
+				// The migration has been rolled back
+				active, err := mig.State().IsActiveMigrationPeriod(ctx, "public")
+				require.NoError(t, err)
+				assert.False(t, active)
+			})
+		})
+	}
 }
 
-func TestMigrationHooksAreInvoked(t *testing.T) {
+func TestTriggerSQLIsCheckedBeforeBackfill(t *testing.T) {
 	t.Parallel()
 
-	options := []roll.Option{roll.WithMigrationHooks(roll.MigrationHooks{
-		BeforeStartDDL: func(m *roll.Roll) error {
-			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE before_start_ddl (id integer)")
-			return err
-		},
-		AfterStartDDL: func(m *roll.Roll) error {
-			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE after_start_ddl (id integer)")
-			return err
-		},
-		BeforeCompleteDDL: func(m *roll.Roll) error {
-			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE before_complete_ddl (id integer)")
-			return err
+	// The up and down SQL is checked before the columns are changed, so the
+	// statements run by the triggers are used to make the triggers fail
+	migration := func(upStatements, downStatements string) *migrations.Migration {
+		return &migrations.Migration{
+			Name: "02_alter_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "reviews",
+					Column: migrations.Column{
+						Name:     "stars",
+						Type:     "integer",
+						Nullable: true,
+					},
+				},
+				&migrations.OpAlterColumn{
+					Table:                 "reviews",
+					Column:                "rating",
+					Type:                  ptr("text"),
+					Up:                    "rating::text",
+					Down:                  "rating::integer",
+					UpTriggerStatements:   upStatements,
+					DownTriggerStatements: downStatements,
+				},
+			},
+		}
+	}
+
+	testCases := map[string]struct {
+		upStatements, downStatements string
+		wantDirection                backfill.TriggerDirection
+		wantErr                      string
+	}{
+		"valid up and down SQL": {},
+		"up statements referencing a column that doesn't exist": {
+			upStatements:  "PERFORM ratng;",
+			wantDirection: backfill.TriggerDirectionUp,
+			wantErr:       `column "ratng" does not exist`,
 		},
-		AfterCompleteDDL: func(m *roll.Roll) error {
-			_, err := m.PgConn().ExecContext(context.Background(), "CREATE TABLE after_complete_ddl (id integer)")
-			return err
+		"down statements calling a function that doesn't exist": {
+			downStatements: "PERFORM to_rating(rating);",
+			wantDirection:  backfill.TriggerDirectionDown,
+			wantErr:        "function to_rating(text) does not exist",
 		},
-	})}
+	}
 
-	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", options, func(mig *roll.Roll, db *sql.DB) {
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+				ctx := context.Background()
+
+				_, err := db.ExecContext(ctx, "CREATE TABLE reviews (id SERIAL PRIMARY KEY, rating integer NOT NULL)")
+				require.NoError(t, err)
+				_, err = db.ExecContext(ctx, "INSERT INTO reviews (rating) SELECT i FROM generate_series(1, 10) AS i")
+				require.NoError(t, err)
+
+				err = mig.Start(ctx, migration(tc.upStatements, tc.downStatements), backfill.NewConfig())
+				if tc.wantErr == "" {
+					require.NoError(t, err)
+					return
+				}
+
+				// The error names the operation and the column whose SQL fails
+				var checkErr backfill.TriggerCheckError
+				require.ErrorAs(t, err, &checkErr)
+				assert.Equal(t, []string{"2 (alter_column)"}, checkErr.Operations)
+				assert.Equal(t, tc.wantDirection, checkErr.Direction)
+				assert.Equal(t, "reviews", checkErr.Table)
+				assert.Equal(t, "rating", checkErr.Column)
+				assert.ErrorContains(t, err, tc.wantErr)
+
+				// The migration has been rolled back before any row was backfilled
+				active, err := mig.State().IsActiveMigrationPeriod(ctx, "public")
+				require.NoError(t, err)
+				assert.False(t, active)
+
+				var columns int
+				err = db.QueryRowContext(ctx, `SELECT count(*) FROM information_schema.columns
+					WHERE table_schema = 'public' AND table_name = 'reviews'`).Scan(&columns)
+				require.NoError(t, err)
+				assert.Equal(t, 2, columns)
+			})
+		})
+	}
+}
+
+func TestNonTransactionalMigrationRunsEachStatementOnItsOwn(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
 		ctx := context.Background()
 
-		// Start a create table migration
-		err := mig.Start(ctx, &migrations.Migration{
-			Name:       "01_create_table",
-			Operations: migrations.Operations{createTableOp("table1")},
-		}, backfill.NewConfig())
-		assert.NoError(t, err)
+		_, err := db.ExecContext(ctx, "CREATE TABLE items (id integer PRIMARY KEY, name text, sku text)")
+		require.NoError(t, err)
 
-		// Ensure that both the before_start_ddl and after_start_ddl tables were created
-		assert.True(t, tableExists(t, db, "public", "before_start_ddl"))
-		assert.True(t, tableExists(t, db, "public", "after_start_ddl"))
+		// CREATE INDEX CONCURRENTLY can't run inside a transaction, so the
+		// statements can only succeed if they are executed one at a time
+		err = mig.Start(ctx, &migrations.Migration{
+			Name:          "01_create_indexes",
+			Transactional: ptr(false),
+			Operations: migrations.Operations{
+				&migrations.OpRawSQL{
+					Up: `CREATE INDEX CONCURRENTLY idx_items_name ON items (name);
+						CREATE INDEX CONCURRENTLY idx_items_sku ON items (sku);`,
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
 
-		// Complete the migration
 		err = mig.Complete(ctx)
-		assert.NoError(t, err)
+		require.NoError(t, err)
 
-		// Ensure that both the before_complete_ddl and after_complete_ddl tables were created
-		assert.True(t, tableExists(t, db, "public", "before_complete_ddl"))
-		assert.True(t, tableExists(t, db, "public", "after_complete_ddl"))
+		for _, index := range []string{"idx_items_name", "idx_items_sku"} {
+			var exists bool
+			err = db.QueryRowContext(ctx,
+				"SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = 'public' AND indexname = $1)", index).
+				Scan(&exists)
+			require.NoError(t, err)
+			assert.True(t, exists, "index %q should exist", index)
+		}
 	})
 }
 
-func TestCallbacksAreInvokedOnMigrationStart(t *testing.T) {
+func TestForwardOnlyMigrationCreatesNoDownTriggersAndCantBeRolledBack(t *testing.T) {
 	t.Parallel()
 
 	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
 		ctx := context.Background()
 
-		// Create a table
-		_, err := db.ExecContext(ctx, "CREATE TABLE users (id SERIAL PRIMARY KEY, name text)")
+		err := mig.Start(ctx, &migrations.Migration{Name: "01_create_table", Operations: migrations.Operations{createTableOp("table1")}}, backfill.NewConfig())
 		require.NoError(t, err)
-
-		// Insert some data
-		_, err = db.ExecContext(ctx,
-			"INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')")
+		err = mig.Complete(ctx)
 		require.NoError(t, err)
 
-		// Define a mock callback
-		invoked := false
-		cb := func(n, total int64) { invoked = true }
-
-		backfillConfig := backfill.NewConfig()
-		backfillConfig.AddCallback(cb)
-
-		// Start a migration that requires a backfill
 		err = mig.Start(ctx, &migrations.Migration{
-			Name: "02_change_type",
+			Name:       "02_change_type",
+			Reversible: ptr(false),
 			Operations: migrations.Operations{
 				&migrations.OpAlterColumn{
-					Table:  "users",
+					Table:  "table1",
 					Column: "name",
-					Type:   ptr("varchar(255)"),
+					Type:   ptr("text"),
 					Up:     "name",
-					Down:   "name",
 				},
 			},
-		}, backfillConfig)
+		}, backfill.NewConfig())
 		require.NoError(t, err)
 
-		// Ensure that the callback was invoked
-		assert.True(t, invoked)
+		// Only the up trigger function exists
+		for fn, want := range map[string]bool{
+			backfill.TriggerFunctionName("table1", "name"):                           true,
+			backfill.TriggerFunctionName("table1", migrations.TemporaryName("name")): false,
+		} {
+			var exists bool
+			err = db.QueryRowContext(ctx,
+				"SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_proc WHERE proname = $1)", fn).
+				Scan(&exists)
+			require.NoError(t, err)
+			assert.Equal(t, want, exists, "trigger function %q", fn)
+		}
+
+		// The migration can't be rolled back, but it can be completed
+		err = mig.Rollback(ctx)
+		require.ErrorIs(t, err, roll.ErrIrreversibleMigration)
+
+		err = mig.Complete(ctx)
+		require.NoError(t, err)
 	})
 }
 
@@ -830,6 +2617,24 @@ func createTableOp(tableName string) *migrations.OpCreateTable {
 	}
 }
 
+func TestIdleInTransactionTimeoutIsSetOnConnection(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithIdleInTransactionTimeoutMs(120000)}
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, _ *sql.DB) {
+		ctx := context.Background()
+
+		rows, err := mig.PgConn().QueryContext(ctx, "SHOW idle_in_transaction_session_timeout")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var timeout string
+		require.True(t, rows.Next())
+		require.NoError(t, rows.Scan(&timeout))
+		assert.Equal(t, "2min", timeout)
+	})
+}
+
 // pgroll uses two Postgres connections:
 // - one for the migrator (used for DDL operations on the target schema)
 // - one for the state (used to update pgroll's internal state)
@@ -946,6 +2751,114 @@ func TestStartFailsWithExistingSchemaWithoutHistory(t *testing.T) {
 	})
 }
 
+func TestMigrationWithPerTableTransactions(t *testing.T) {
+	t.Parallel()
+
+	opts := []roll.Option{roll.WithPerTableTransactions(true)}
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		require.NoError(t, mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_tables",
+			Operations: migrations.Operations{createTableOp("users"), createTableOp("items")},
+		}, backfill.NewConfig()))
+		require.NoError(t, mig.Complete(ctx))
+
+		columnExists := func(table string) bool {
+			var exists bool
+			err := db.QueryRowContext(ctx, `SELECT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = 'public' AND table_name = $1 AND column_name = 'age'
+			)`, table).Scan(&exists)
+			require.NoError(t, err)
+			return exists
+		}
+
+		// A failing operation rolls back the whole migration, including the
+		// groups of operations already committed
+		err := mig.Start(ctx, &migrations.Migration{
+			Name: "02_failing",
+			Operations: migrations.Operations{
+				addColumnOp("users"),
+				&migrations.OpRawSQL{Up: "SELECT 1/0"},
+			},
+		}, backfill.NewConfig())
+		require.Error(t, err)
+		assert.False(t, columnExists("users"))
+
+		// The concurrent index build can't run inside a transaction, so it is
+		// run on its own between the groups of operations on items
+		require.NoError(t, mig.Start(ctx, &migrations.Migration{
+			Name: "03_add_columns",
+			Operations: migrations.Operations{
+				addColumnOp("users"),
+				&migrations.OpCreateIndex{Name: "idx_items_name", Table: "items", Columns: migrations.OpCreateIndexColumns{"name": {}}},
+				addColumnOp("items"),
+			},
+		}, backfill.NewConfig()))
+		require.NoError(t, mig.Complete(ctx))
+
+		assert.True(t, columnExists("users"))
+		assert.True(t, columnExists("items"))
+
+		var exists bool
+		err = db.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = 'public' AND indexname = 'idx_items_name')").
+			Scan(&exists)
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestMigrationIsCompletedWithTheInternalPrefixItWasStartedWith(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithConnectionToContainer(t, func(db *sql.DB, connStr string) {
+		ctx := context.Background()
+
+		// Start a migration with a custom internal prefix
+		first := newRoll(t, connStr, roll.WithInternalPrefix("_acme_"))
+		require.NoError(t, first.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig()))
+		require.NoError(t, first.Complete(ctx))
+
+		_, err := db.ExecContext(ctx, "INSERT INTO users (id, name) VALUES (1, 'alice')")
+		require.NoError(t, err)
+
+		migration := addCheckedColumnMigration
+		require.NoError(t, first.Start(ctx, &migration, backfill.NewConfig()))
+		require.NoError(t, first.Close())
+
+		columnExists := func(name string) bool {
+			var exists bool
+			err := db.QueryRowContext(ctx, `SELECT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = 'public' AND table_name = 'users' AND column_name = $1
+			)`, name).Scan(&exists)
+			require.NoError(t, err)
+			return exists
+		}
+		assert.True(t, columnExists("_acme_new_name_length"))
+		assert.True(t, columnExists("_acme_needs_backfill"))
+
+		// Complete the migration with the default internal prefix
+		second := newRoll(t, connStr)
+		defer second.Close()
+
+		require.NoError(t, second.Complete(ctx))
+
+		// The objects named with the migration's prefix have been found and
+		// removed
+		assert.False(t, columnExists("_acme_new_name_length"))
+		assert.False(t, columnExists("_acme_needs_backfill"))
+
+		rows := MustSelect(t, db, "public", "02_add_column", "users")
+		assert.Equal(t, []map[string]any{{"id": 1, "name": "alice", "name_length": 5}}, rows)
+	})
+}
+
 func TestVersionSchemaCreationIsNotCapturedAsAnInferredMigration(t *testing.T) {
 	t.Parallel()
 
@@ -1066,6 +2979,23 @@ func tableExists(t *testing.T, db *sql.DB, schema, table string) bool {
 	return exists
 }
 
+func tableColumns(t *testing.T, db *sql.DB, schema, table string) []string {
+	t.Helper()
+
+	var columns pq.StringArray
+	err := db.QueryRow(`
+		SELECT array_agg(column_name::text ORDER BY ordinal_position)
+		FROM information_schema.columns
+		WHERE table_schema = $1
+		AND table_name = $2`,
+		schema, table).Scan(&columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return columns
+}
+
 func ptr[T any](v T) *T {
 	return &v
 }
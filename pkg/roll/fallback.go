@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+)
+
+// FillIncompleteBackfill sets the columns that the active migration is
+// backfilling to the `fallback` SQL expression in the rows that still need a
+// backfill, and marks those rows as backfilled. It is meant to be run just
+// before Complete, when the backfill has been unable to fill some rows and the
+// migration should be completed anyway.
+//
+// The rows are filled with pgroll's triggers disabled, so that the fallback
+// is not overwritten by the up SQL and the columns of the old version are left
+// as they are. The returned map holds the number of rows given the fallback in
+// each table in which there were any.
+func (m *Roll) FillIncompleteBackfill(ctx context.Context, fallback string) (map[string]int64, error) {
+	if strings.TrimSpace(fallback) == "" {
+		return nil, fmt.Errorf("a fallback expression is required")
+	}
+
+	if _, err := m.state.GetActiveMigration(ctx, m.schema); err != nil {
+		return nil, fmt.Errorf("unable to get active migration: %w", err)
+	}
+
+	mappings, err := m.TableMappings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read table mappings: %w", err)
+	}
+
+	naming, err := m.latestNaming(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filled := make(map[string]int64)
+	for name, mapping := range mappings {
+		columns := backfilledColumns(mapping, naming)
+		if mapping.NeedsBackfillColumn == "" || len(columns) == 0 {
+			continue
+		}
+
+		var rows int64
+		err := m.pgConn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			var err error
+			rows, err = m.fillTable(ctx, tx, mapping, naming, columns, fallback)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to fill incomplete backfill of table %q: %w", name, err)
+		}
+		if rows > 0 {
+			filled[name] = rows
+		}
+	}
+
+	return filled, nil
+}
+
+// fillTable sets the columns to the fallback in the rows of the table that
+// still need a backfill, with pgroll's triggers on the table disabled for the
+// duration of the transaction.
+func (m *Roll) fillTable(ctx context.Context, tx *sql.Tx, mapping *TableMapping, naming backfill.Naming, columns []string, fallback string) (int64, error) {
+	table := pq.QuoteIdentifier(m.schema) + "." + pq.QuoteIdentifier(mapping.Name)
+
+	triggers, err := tableTriggers(ctx, tx, m.schema, mapping.Name, naming)
+	if err != nil {
+		return 0, err
+	}
+	for _, trigger := range triggers {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DISABLE TRIGGER %s", table, pq.QuoteIdentifier(trigger))); err != nil {
+			return 0, err
+		}
+	}
+
+	assignments := make([]string, 0, len(columns)+1)
+	for _, column := range columns {
+		assignments = append(assignments, fmt.Sprintf("%s = %s", pq.QuoteIdentifier(column), fallback))
+	}
+	needsBackfill := pq.QuoteIdentifier(mapping.NeedsBackfillColumn)
+	assignments = append(assignments, needsBackfill+" = false")
+
+	res, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		table, strings.Join(assignments, ", "), needsBackfill))
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, trigger := range triggers {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ENABLE TRIGGER %s", table, pq.QuoteIdentifier(trigger))); err != nil {
+			return 0, err
+		}
+	}
+
+	return rows, nil
+}
+
+// tableTriggers returns the names of the enabled pgroll triggers on the table,
+// as named by the naming.
+func tableTriggers(ctx context.Context, tx *sql.Tx, schema, table string, naming backfill.Naming) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT t.tgname
+		FROM pg_catalog.pg_trigger t
+		JOIN pg_catalog.pg_class c ON c.oid = t.tgrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1
+			AND c.relname = $2
+			AND NOT t.tgisinternal
+			AND t.tgenabled <> 'D'
+			AND starts_with(t.tgname, $3)
+		ORDER BY t.tgname`,
+		schema, table, naming.TriggerFunctionPrefix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// backfilledColumns returns the physical columns of the table that the active
+// migration backfills, in name order. These are the temporary columns that
+// pgroll creates for new and changed columns, as named by the naming.
+func backfilledColumns(mapping *TableMapping, naming backfill.Naming) []string {
+	var columns []string
+	for logical, physical := range mapping.Columns {
+		if physical == naming.TemporaryName(logical) {
+			columns = append(columns, physical)
+		}
+	}
+	slices.Sort(columns)
+	return columns
+}
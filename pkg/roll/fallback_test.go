@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestFillIncompleteBackfill(t *testing.T) {
+	t.Parallel()
+
+	createTable := &migrations.Migration{
+		Name: "01_create_table",
+		Operations: migrations.Operations{
+			&migrations.OpCreateTable{
+				Name: "items",
+				Columns: []migrations.Column{
+					{Name: "id", Type: "serial", Pk: true},
+					{Name: "name", Type: "text"},
+				},
+			},
+		},
+	}
+	addColumn := &migrations.Migration{
+		Name: "02_add_column",
+		Operations: migrations.Operations{
+			&migrations.OpAddColumn{
+				Table: "items",
+				Up:    "upper(name)",
+				Column: migrations.Column{
+					Name:     "name_upper",
+					Type:     "text",
+					Nullable: true,
+				},
+			},
+		},
+	}
+
+	t.Run("rows that were not backfilled are given the fallback", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			require.NoError(t, mig.Start(ctx, createTable, backfill.NewConfig()))
+			require.NoError(t, mig.Complete(ctx))
+
+			_, err := db.ExecContext(ctx, "INSERT INTO items (name) SELECT 'item ' || i FROM generate_series(1, 3) AS i")
+			require.NoError(t, err)
+
+			// Run the DDL operations of the migration without backfilling the
+			// existing rows
+			_, err = mig.StartDDLOperations(ctx, addColumn)
+			require.NoError(t, err)
+
+			// A row written through the old version is filled by the trigger
+			_, err = db.ExecContext(ctx, "INSERT INTO items (name) VALUES ('item 4')")
+			require.NoError(t, err)
+
+			filled, err := mig.FillIncompleteBackfill(ctx, "'fallback'")
+			require.NoError(t, err)
+			assert.Equal(t, map[string]int64{"items": 3}, filled)
+
+			// Nothing is left to fill
+			filled, err = mig.FillIncompleteBackfill(ctx, "'fallback'")
+			require.NoError(t, err)
+			assert.Empty(t, filled)
+
+			require.NoError(t, mig.Complete(ctx))
+
+			rows, err := db.QueryContext(ctx, "SELECT name_upper FROM public_02_add_column.items ORDER BY id")
+			require.NoError(t, err)
+			defer rows.Close()
+			var values []string
+			for rows.Next() {
+				var value string
+				require.NoError(t, rows.Scan(&value))
+				values = append(values, value)
+			}
+			require.NoError(t, rows.Err())
+			assert.Equal(t, []string{"fallback", "fallback", "fallback", "ITEM 4"}, values)
+		})
+	})
+
+	t.Run("there must be an active migration", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			require.NoError(t, mig.Start(ctx, createTable, backfill.NewConfig()))
+			require.NoError(t, mig.Complete(ctx))
+
+			_, err := mig.FillIncompleteBackfill(ctx, "'fallback'")
+			require.Error(t, err)
+		})
+	})
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// GeneratedSQL holds the SQL statements that apply a migration and that
+// revert it.
+type GeneratedSQL struct {
+	// Up contains the statements that start the migration, backfill the
+	// affected tables and complete the migration.
+	Up []string
+
+	// Down contains the statements that roll back a started, but not yet
+	// completed, migration.
+	Down []string
+}
+
+// GenerateSQL returns the SQL statements that pgroll would execute to start,
+// backfill and complete the migration, and to roll it back, against the
+// current schema. The statements are recorded rather than executed, so the
+// database is not modified. Changes to pgroll's own state are not included.
+func (m *Roll) GenerateSQL(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) (*GeneratedSQL, error) {
+	// Nothing is executed against the database, so the schema only needs to
+	// be read once
+	defer m.withSchemaCache()()
+
+	if err := m.Validate(ctx, migration); err != nil {
+		return nil, err
+	}
+
+	ctx = migrations.WithNaming(ctx, m.naming)
+	rec := &db.RecordingDB{Conn: m.pgConn}
+	gen := *m
+	gen.pgConn = rec
+	gen.logger = migrations.NewNoopLogger()
+
+	up, err := gen.generateUpSQL(ctx, rec, migration, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rec.Reset()
+	down, err := gen.generateDownSQL(ctx, rec, migration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeneratedSQL{Up: up, Down: down}, nil
+}
+
+func (m *Roll) generateUpSQL(ctx context.Context, rec *db.RecordingDB, migration *migrations.Migration, cfg *backfill.Config) ([]string, error) {
+	s, err := m.readSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	// start the migration
+	job := backfill.NewJob(m.schema, VersionedSchemaName(m.schema, migration.VersionSchemaName()))
+	for _, op := range migration.Operations {
+		startOp, err := op.Start(ctx, m.logger, rec, s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for start %q migration: %w", migration.Name, err)
+		}
+		if startOp == nil {
+			continue
+		}
+
+		startOp.Actions = append(startOp.Actions, m.ownerActions(rec, op)...)
+
+		startOp.Actions, err = m.migrationActions(migration, startOp.Actions)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for start %q migration: %w", migration.Name, err)
+		}
+
+		if err := executeWithComment(ctx, rec, op, startOp.Actions); err != nil {
+			return nil, fmt.Errorf("unable to generate start operation of %q: %w", migration.Name, err)
+		}
+		if startOp.BackfillTask != nil {
+			// forward-only migrations don't keep the old version of the schema
+			// in sync with writes made through the new one
+			if !migration.IsReversible() {
+				startOp.BackfillTask.RemoveDownTriggers()
+			}
+			job.AddTask(startOp.BackfillTask)
+		}
+	}
+
+	if !m.disableVersionSchemas {
+		if err := m.ensureViews(ctx, s, migration); err != nil {
+			return nil, err
+		}
+	}
+
+	// backfill the affected tables
+	bf := backfill.New(rec, cfg)
+	bf.SetNaming(m.naming)
+	if err := bf.CreateTriggers(ctx, job); err != nil {
+		return nil, err
+	}
+	for _, table := range job.Tables {
+		_, err := rec.ExecContext(ctx, bf.LoopSQL(table.Name, job.Filter(table.Name)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// complete the migration, dropping the version schema that was the latest
+	// before this migration
+	if !m.disableVersionSchemas {
+		latestVersion, err := m.state.LatestVersion(ctx, m.schema)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get name of latest version: %w", err)
+		}
+		if latestVersion != nil {
+			_, err := rec.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE",
+				pq.QuoteIdentifier(VersionedSchemaName(m.schema, *latestVersion))))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, op := range migration.Operations {
+		actions, err := op.Complete(ctx, m.logger, rec, s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for complete operation: %w", err)
+		}
+		actions, err = m.migrationActions(migration, actions)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for complete operation: %w", err)
+		}
+		if err := executeWithComment(ctx, rec, op, actions); err != nil {
+			return nil, fmt.Errorf("unable to generate complete operation: %w", err)
+		}
+	}
+
+	return rec.Statements(), nil
+}
+
+func (m *Roll) generateDownSQL(ctx context.Context, rec *db.RecordingDB, migration *migrations.Migration) ([]string, error) {
+	s, err := m.readSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	// update the in-memory schema with the results of starting the migration
+	if err := migration.UpdateVirtualSchema(ctx, s); err != nil {
+		return nil, fmt.Errorf("unable to replay changes to in-memory schema: %w", err)
+	}
+
+	// delete the schema and views for the new version
+	_, err = rec.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE",
+		pq.QuoteIdentifier(VersionedSchemaName(m.schema, migration.VersionSchemaName()))))
+	if err != nil {
+		return nil, err
+	}
+
+	// roll back operations in reverse order
+	for i := len(migration.Operations) - 1; i >= 0; i-- {
+		actions, err := migration.Operations[i].Rollback(ctx, m.logger, rec, s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for rollback operation: %w", err)
+		}
+		actions, err = m.migrationActions(migration, actions)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect actions for rollback operation: %w", err)
+		}
+		if err := executeWithComment(ctx, rec, migration.Operations[i], actions); err != nil {
+			return nil, fmt.Errorf("unable to generate rollback operation: %w", err)
+		}
+	}
+
+	return rec.Statements(), nil
+}
+
+// executeWithComment records the statements executed by the actions of the
+// operation, preceded by the operation's comment, if it has one.
+func executeWithComment(ctx context.Context, rec *db.RecordingDB, op migrations.Operation, actions []migrations.DBAction) error {
+	return rec.WithComment(migrations.OperationComment(op), func() error {
+		for _, action := range actions {
+			if err := action.Execute(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IsComment returns true if the generated statement is a comment that
+// annotates the statements that follow it.
+func IsComment(stmt string) bool {
+	return strings.HasPrefix(strings.TrimSpace(stmt), "--")
+}
+
+// idempotentExceptions are the errors raised when re-running a statement that
+// creates an object that already exists.
+const idempotentExceptions = "duplicate_object OR duplicate_table OR duplicate_column OR duplicate_schema OR duplicate_function"
+
+var (
+	alreadyIdempotentRe  = regexp.MustCompile(`(?i)\b(IF NOT EXISTS|IF EXISTS|OR REPLACE)\b`)
+	createConcurrentlyRe = regexp.MustCompile(`(?i)^(CREATE\s+(UNIQUE\s+)?INDEX\s+CONCURRENTLY)\s+`)
+)
+
+// IdempotentSQL rewrites the statements so that they can be re-run against a
+// database on which some of them have already been applied. Statements that
+// create objects without an `IF NOT EXISTS` clause, such as `ALTER TABLE ...
+// ADD CONSTRAINT`, are wrapped in a `DO` block that ignores the error raised
+// when the object already exists. Concurrent index builds can't run inside a
+// `DO` block, so `IF NOT EXISTS` is added to them instead. Statements that are
+// already idempotent, transaction control statements and comments are
+// unchanged.
+func IdempotentSQL(statements []string) []string {
+	out := make([]string, len(statements))
+	for i, stmt := range statements {
+		out[i] = idempotentStatement(stmt)
+	}
+	return out
+}
+
+func idempotentStatement(stmt string) string {
+	trimmed := strings.TrimRight(strings.TrimSpace(stmt), ";")
+	upper := strings.ToUpper(trimmed)
+
+	switch {
+	case IsComment(stmt) || upper == "BEGIN" || upper == "COMMIT" || strings.HasPrefix(upper, "DO "):
+		return stmt
+	case createConcurrentlyRe.MatchString(trimmed):
+		if alreadyIdempotentRe.MatchString(trimmed) {
+			return stmt
+		}
+		return createConcurrentlyRe.ReplaceAllString(trimmed, "$1 IF NOT EXISTS ")
+	case alreadyIdempotentRe.MatchString(trimmed):
+		return stmt
+	}
+
+	// Choose a dollar quote tag that does not appear in the statement
+	tag := "$pgroll$"
+	for n := 1; strings.Contains(trimmed, tag); n++ {
+		tag = fmt.Sprintf("$pgroll%d$", n)
+	}
+
+	return fmt.Sprintf("DO %[1]s\nBEGIN\n  %[2]s;\nEXCEPTION\n  WHEN %[3]s THEN NULL;\nEND\n%[1]s",
+		tag, trimmed, idempotentExceptions)
+}
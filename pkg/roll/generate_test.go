@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestGenerateSQL(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table with a completed migration
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Generate the SQL for a migration that requires a backfill
+		addColumn := &migrations.OpAddColumn{
+			Table: "users",
+			Up:    "length(name)",
+			Column: migrations.Column{
+				Name: "name_length",
+				Type: "integer",
+			},
+		}
+		migrations.SetOperationComment(addColumn, "Add the length of each user's name")
+
+		generated, err := mig.GenerateSQL(ctx, &migrations.Migration{
+			Name:       "02_add_column",
+			Operations: migrations.Operations{addColumn},
+		}, backfill.NewConfig(backfill.WithBatchSize(50)))
+		require.NoError(t, err)
+
+		up := strings.Join(generated.Up, "\n")
+		down := strings.Join(generated.Down, "\n")
+
+		// The up SQL starts, backfills and completes the migration
+		assert.Contains(t, up, `ADD COLUMN "_pgroll_new_name_length"`)
+		assert.Contains(t, up, `CREATE SCHEMA IF NOT EXISTS "public_02_add_column"`)
+		assert.Contains(t, up, `CREATE OR REPLACE FUNCTION "_pgroll_trigger_users_name_length"`)
+		assert.Contains(t, up, `WHERE "_pgroll_needs_backfill" = true LIMIT 50`)
+		assert.Contains(t, up, `DROP SCHEMA IF EXISTS "public_01_create_table" CASCADE`)
+		assert.Contains(t, up, `RENAME COLUMN "_pgroll_new_name_length" TO "name_length"`)
+
+		// The statements of the operation are annotated with its comment
+		comment := slices.Index(generated.Up, "-- Add the length of each user's name")
+		require.NotEqual(t, -1, comment)
+		assert.Contains(t, generated.Up[comment+1], `ADD COLUMN "_pgroll_new_name_length"`)
+		assert.Contains(t, generated.Down, "-- Add the length of each user's name")
+
+		// The down SQL rolls back the started migration
+		assert.Contains(t, down, `DROP SCHEMA IF EXISTS "public_02_add_column" CASCADE`)
+		assert.Contains(t, down, `DROP COLUMN IF EXISTS "_pgroll_new_name_length"`)
+
+		// Generating the SQL does not change the database
+		var exists bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'users' AND column_name = '_pgroll_new_name_length'
+		)`).Scan(&exists)
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		status, err := mig.Status(ctx, "public")
+		require.NoError(t, err)
+		assert.Equal(t, "01_create_table", status.Version)
+	})
+}
+
+func TestIdempotentSQL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{
+			name:      "statements creating objects are wrapped in a DO block",
+			statement: `ALTER TABLE "users" ADD CONSTRAINT "name_length" CHECK (length(name) < 100) NOT VALID`,
+			want: "DO $pgroll$\nBEGIN\n" +
+				`  ALTER TABLE "users" ADD CONSTRAINT "name_length" CHECK (length(name) < 100) NOT VALID;` +
+				"\nEXCEPTION\n  WHEN duplicate_object OR duplicate_table OR duplicate_column OR duplicate_schema OR duplicate_function THEN NULL;\nEND\n$pgroll$",
+		},
+		{
+			name:      "the dollar quote tag does not clash with the statement",
+			statement: `ALTER TABLE "users" ADD COLUMN "tag" text DEFAULT $pgroll$x$pgroll$`,
+			want: "DO $pgroll1$\nBEGIN\n" +
+				`  ALTER TABLE "users" ADD COLUMN "tag" text DEFAULT $pgroll$x$pgroll$;` +
+				"\nEXCEPTION\n  WHEN duplicate_object OR duplicate_table OR duplicate_column OR duplicate_schema OR duplicate_function THEN NULL;\nEND\n$pgroll1$",
+		},
+		{
+			name:      "concurrent index builds get IF NOT EXISTS",
+			statement: `CREATE UNIQUE INDEX CONCURRENTLY "idx_users_name" ON "users" ("name")`,
+			want:      `CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS "idx_users_name" ON "users" ("name")`,
+		},
+		{
+			name:      "idempotent statements are unchanged",
+			statement: `DROP SCHEMA IF EXISTS "public_01_create_table" CASCADE`,
+			want:      `DROP SCHEMA IF EXISTS "public_01_create_table" CASCADE`,
+		},
+		{
+			name:      "transaction control statements are unchanged",
+			statement: "BEGIN",
+			want:      "BEGIN",
+		},
+		{
+			name:      "comments are unchanged",
+			statement: "-- Add the email column",
+			want:      "-- Add the email column",
+		},
+		{
+			name:      "DO blocks are unchanged",
+			statement: "DO $$ BEGIN PERFORM 1; END $$",
+			want:      "DO $$ BEGIN PERFORM 1; END $$",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := roll.IdempotentSQL([]string{tc.statement})
+			assert.Equal(t, []string{tc.want}, got)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// GraphFormat is the output format of a schema diagram.
+type GraphFormat string
+
+const (
+	// GraphFormatDOT renders the diagram as a Graphviz DOT digraph.
+	GraphFormatDOT GraphFormat = "dot"
+	// GraphFormatMermaid renders the diagram as a Mermaid entity relationship
+	// diagram.
+	GraphFormatMermaid GraphFormat = "mermaid"
+)
+
+// ErrUnknownGraphFormat is returned when a diagram is requested in a format
+// that is not supported.
+var ErrUnknownGraphFormat = fmt.Errorf("unknown graph format")
+
+// WriteGraph writes a diagram of the tables, columns and foreign keys in the
+// latest version of the schema to w. While a migration is active, the latest
+// version is the one created by the active migration.
+func (m *Roll) WriteGraph(ctx context.Context, w io.Writer, format GraphFormat) error {
+	if format != GraphFormatDOT && format != GraphFormatMermaid {
+		return fmt.Errorf("%w: %q", ErrUnknownGraphFormat, format)
+	}
+
+	physical, err := m.state.ReadSchema(ctx, m.schema)
+	if err != nil {
+		return fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	logical, err := m.latestVirtualSchema(ctx, physical)
+	if err != nil {
+		return err
+	}
+
+	g := newGraph(logical)
+	if format == GraphFormatMermaid {
+		return g.writeMermaid(w)
+	}
+	return g.writeDOT(w)
+}
+
+// graph is the set of tables and foreign keys to render in a diagram, named
+// as in the version of the schema being rendered.
+type graph struct {
+	tables []graphTable
+	edges  []graphEdge
+}
+
+type graphTable struct {
+	name    string
+	columns []graphColumn
+}
+
+type graphColumn struct {
+	name     string
+	typ      string
+	pk       bool
+	fk       bool
+	nullable bool
+}
+
+type graphEdge struct {
+	name       string
+	table      string
+	columns    []string
+	refTable   string
+	refColumns []string
+	nullable   bool
+}
+
+// newGraph builds the graph for the schema. Tables and columns are keyed by
+// their names in the schema version, whereas primary and foreign keys refer
+// to physical names, so these are translated back to version names.
+func newGraph(s *schema.Schema) *graph {
+	tableNames := make(map[string]string, len(s.Tables))
+	columnNames := make(map[string]map[string]string, len(s.Tables))
+	for name, table := range s.Tables {
+		if table.Deleted {
+			continue
+		}
+		tableNames[table.Name] = name
+		columnNames[table.Name] = make(map[string]string, len(table.Columns))
+		for columnName, column := range table.Columns {
+			if column.Deleted {
+				continue
+			}
+			columnNames[table.Name][column.Name] = columnName
+		}
+	}
+
+	versionName := func(names map[string]string, name string) string {
+		if n, ok := names[name]; ok {
+			return n
+		}
+		return name
+	}
+
+	g := &graph{}
+	for _, name := range slices.Sorted(maps.Keys(s.Tables)) {
+		table := s.Tables[name]
+		if table.Deleted {
+			continue
+		}
+		columns := columnNames[table.Name]
+
+		pk := make(map[string]bool, len(table.PrimaryKey))
+		for _, c := range table.PrimaryKey {
+			pk[versionName(columns, c)] = true
+		}
+		fk := make(map[string]bool)
+		for _, fkName := range slices.Sorted(maps.Keys(table.ForeignKeys)) {
+			key := table.ForeignKeys[fkName]
+
+			edge := graphEdge{
+				name:     fkName,
+				table:    name,
+				refTable: versionName(tableNames, key.ReferencedTable),
+			}
+			for _, c := range key.Columns {
+				c = versionName(columns, c)
+				fk[c] = true
+				edge.columns = append(edge.columns, c)
+				if col := table.GetColumn(c); col != nil && col.Nullable {
+					edge.nullable = true
+				}
+			}
+			for _, c := range key.ReferencedColumns {
+				edge.refColumns = append(edge.refColumns, versionName(columnNames[key.ReferencedTable], c))
+			}
+			g.edges = append(g.edges, edge)
+		}
+
+		gt := graphTable{name: name}
+		for _, columnName := range slices.Sorted(maps.Keys(table.Columns)) {
+			column := table.Columns[columnName]
+			if column.Deleted {
+				continue
+			}
+			gt.columns = append(gt.columns, graphColumn{
+				name:     columnName,
+				typ:      column.Type,
+				pk:       pk[columnName],
+				fk:       fk[columnName],
+				nullable: column.Nullable,
+			})
+		}
+		g.tables = append(g.tables, gt)
+	}
+
+	return g
+}
+
+// writeDOT renders the graph as a Graphviz digraph with a node per table and
+// an edge per foreign key, from the referencing to the referenced columns.
+func (g *graph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph schema {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=plaintext];\n")
+
+	for _, t := range g.tables {
+		fmt.Fprintf(&b, "  %s [label=<<TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\">", dotID(t.name))
+		fmt.Fprintf(&b, "<TR><TD BGCOLOR=\"lightgrey\"><B>%s</B></TD></TR>", html.EscapeString(t.name))
+		for _, c := range t.columns {
+			label := c.name + ": " + c.typ
+			if keys := c.keys(); keys != "" {
+				label += " (" + keys + ")"
+			}
+			fmt.Fprintf(&b, "<TR><TD PORT=%s ALIGN=\"LEFT\">%s</TD></TR>", dotID(c.name), html.EscapeString(label))
+		}
+		b.WriteString("</TABLE>>];\n")
+	}
+
+	for _, e := range g.edges {
+		if len(e.columns) == 1 && len(e.refColumns) == 1 {
+			fmt.Fprintf(&b, "  %s:%s -> %s:%s [label=%s];\n",
+				dotID(e.table), dotID(e.columns[0]), dotID(e.refTable), dotID(e.refColumns[0]), dotID(e.name))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", dotID(e.table), dotID(e.refTable), dotID(e.name))
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMermaid renders the graph as a Mermaid erDiagram with an entity per
+// table and a relationship per foreign key.
+func (g *graph) writeMermaid(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("erDiagram\n")
+
+	for _, t := range g.tables {
+		fmt.Fprintf(&b, "  %s {\n", mermaidEntity(t.name))
+		for _, c := range t.columns {
+			fmt.Fprintf(&b, "    %s %s", mermaidToken(c.typ), mermaidToken(c.name))
+			if keys := c.keys(); keys != "" {
+				fmt.Fprintf(&b, " %s", keys)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, e := range g.edges {
+		// each row of the referencing table refers to at most one row of the
+		// referenced table, or exactly one if the foreign key is not nullable
+		cardinality := "}o--||"
+		if e.nullable {
+			cardinality = "}o--o|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s : %q\n",
+			mermaidEntity(e.table), cardinality, mermaidEntity(e.refTable), e.name)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// keys returns the key markers of the column, e.g. "PK, FK".
+func (c graphColumn) keys() string {
+	var keys []string
+	if c.pk {
+		keys = append(keys, "PK")
+	}
+	if c.fk {
+		keys = append(keys, "FK")
+	}
+	return strings.Join(keys, ", ")
+}
+
+// dotID quotes a name for use as a DOT identifier.
+func dotID(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
+
+var (
+	mermaidNameRe       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	mermaidTokenInvalid = regexp.MustCompile(`[^A-Za-z0-9_\-\[\]()]+`)
+	mermaidTokenStart   = regexp.MustCompile(`^[A-Za-z_]`)
+)
+
+// mermaidEntity quotes a name for use as a Mermaid entity name if it contains
+// characters that Mermaid does not accept unquoted.
+func mermaidEntity(name string) string {
+	if mermaidNameRe.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `'`) + `"`
+}
+
+// mermaidToken rewrites a column name or type so that Mermaid accepts it as
+// an attribute name or type, which can't be quoted, e.g.
+// "character varying(255)" becomes "character_varying(255)".
+func mermaidToken(s string) string {
+	s = mermaidTokenInvalid.ReplaceAllString(s, "_")
+	if !mermaidTokenStart.MatchString(s) {
+		s = "_" + s
+	}
+	return s
+}
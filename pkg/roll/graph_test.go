@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestWriteGraph(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create two tables related by a foreign key
+		err := mig.Start(ctx, &migrations.Migration{
+			Name: "01_create_tables",
+			Operations: migrations.Operations{
+				createTableOp("users"),
+				&migrations.OpCreateTable{
+					Name: "orders",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "integer", Pk: true},
+						{Name: "user_id", Type: "integer", References: &migrations.ForeignKeyReference{
+							Name:   "fk_orders_user",
+							Table:  "users",
+							Column: "id",
+						}},
+					},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		var dot strings.Builder
+		err = mig.WriteGraph(ctx, &dot, roll.GraphFormatDOT)
+		require.NoError(t, err)
+		assert.Equal(t, `digraph schema {
+  rankdir=LR;
+  node [shape=plaintext];
+  "orders" [label=<<TABLE BORDER="0" CELLBORDER="1" CELLSPACING="0"><TR><TD BGCOLOR="lightgrey"><B>orders</B></TD></TR><TR><TD PORT="id" ALIGN="LEFT">id: integer (PK)</TD></TR><TR><TD PORT="user_id" ALIGN="LEFT">user_id: integer (FK)</TD></TR></TABLE>>];
+  "users" [label=<<TABLE BORDER="0" CELLBORDER="1" CELLSPACING="0"><TR><TD BGCOLOR="lightgrey"><B>users</B></TD></TR><TR><TD PORT="id" ALIGN="LEFT">id: integer (PK)</TD></TR><TR><TD PORT="name" ALIGN="LEFT">name: character varying(255)</TD></TR></TABLE>>];
+  "orders":"user_id" -> "users":"id" [label="fk_orders_user"];
+}
+`, dot.String())
+
+		// Start a migration that renames the foreign key column
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_rename_column",
+			Operations: migrations.Operations{
+				&migrations.OpRenameColumn{Table: "orders", From: "user_id", To: "customer_id"},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// The diagram shows the column under its name in the new version
+		var mermaid strings.Builder
+		err = mig.WriteGraph(ctx, &mermaid, roll.GraphFormatMermaid)
+		require.NoError(t, err)
+		assert.Equal(t, `erDiagram
+  orders {
+    integer customer_id FK
+    integer id PK
+  }
+  users {
+    integer id PK
+    character_varying(255) name
+  }
+  orders }o--|| users : "fk_orders_user"
+`, mermaid.String())
+	})
+}
+
+func TestWriteGraphRejectsUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		var out strings.Builder
+		err := mig.WriteGraph(context.Background(), &out, roll.GraphFormat("svg"))
+		assert.ErrorIs(t, err, roll.ErrUnknownGraphFormat)
+		assert.Empty(t, out.String())
+	})
+}
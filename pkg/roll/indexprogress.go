@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// indexBuildPollInterval is how often the progress of an index build is read
+// from pg_stat_progress_create_index.
+const indexBuildPollInterval = 2 * time.Second
+
+// IndexBuildProgress describes the progress of an index build, as reported
+// by the pg_stat_progress_create_index view.
+type IndexBuildProgress struct {
+	// Index is the name of the index.
+	Index string
+
+	// Phase is the current phase of the build, eg. "building index: scanning
+	// table".
+	Phase string
+
+	// BlocksDone and BlocksTotal are the number of blocks processed and to be
+	// processed in the current phase.
+	BlocksDone  int64
+	BlocksTotal int64
+
+	// TuplesDone and TuplesTotal are the number of tuples processed and to be
+	// processed in the current phase.
+	TuplesDone  int64
+	TuplesTotal int64
+}
+
+// Percent returns the percentage of blocks processed in the current phase, or
+// -1 if the phase does not report block counts.
+func (p IndexBuildProgress) Percent() float64 {
+	if p.BlocksTotal <= 0 {
+		return -1
+	}
+	return float64(p.BlocksDone) / float64(p.BlocksTotal) * 100
+}
+
+// IndexBuildProgressFn is called periodically while an index is built
+// concurrently.
+type IndexBuildProgressFn func(IndexBuildProgress)
+
+// executeAction executes the action. If the action builds an index and an
+// index build progress callback is set, the progress of the build is polled
+// and reported until the action finishes.
+func (m *Roll) executeAction(ctx context.Context, action migrations.DBAction) error {
+	indexBuild, ok := action.(migrations.IndexBuildAction)
+	if !ok || m.indexBuildProgress == nil {
+		return action.Execute(ctx)
+	}
+
+	monitorCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.monitorIndexBuild(monitorCtx, indexBuild.IndexName())
+	}()
+
+	err := action.Execute(ctx)
+	cancel()
+	<-done
+	return err
+}
+
+// monitorIndexBuild reports the progress of the build of the named index
+// until the context is cancelled. The view is read over the state connection,
+// as the migration connection is busy building the index, and opening another
+// connection from its pool would leave a connection without the session
+// settings applied in setupConn.
+func (m *Roll) monitorIndexBuild(ctx context.Context, index string) {
+	ticker := time.NewTicker(indexBuildPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		progress, ok := m.readIndexBuildProgress(ctx, index)
+		if ok {
+			m.indexBuildProgress(progress)
+		}
+	}
+}
+
+// readIndexBuildProgress reads the progress of the build of the named index.
+// It returns false if the build has not started or has finished, or if the
+// progress could not be read; monitoring is best effort and never fails the
+// migration.
+func (m *Roll) readIndexBuildProgress(ctx context.Context, index string) (IndexBuildProgress, bool) {
+	rows, err := m.state.PgConn().QueryContext(ctx, `SELECT p.phase, p.blocks_done, p.blocks_total, p.tuples_done, p.tuples_total
+		FROM pg_catalog.pg_stat_progress_create_index p
+		JOIN pg_catalog.pg_class c ON c.oid = p.index_relid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2`, m.schema, index)
+	if err != nil {
+		return IndexBuildProgress{}, false
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return IndexBuildProgress{}, false
+	}
+
+	p := IndexBuildProgress{Index: index}
+	var blocksDone, blocksTotal, tuplesDone, tuplesTotal sql.NullInt64
+	if err := rows.Scan(&p.Phase, &blocksDone, &blocksTotal, &tuplesDone, &tuplesTotal); err != nil {
+		return IndexBuildProgress{}, false
+	}
+	p.BlocksDone = blocksDone.Int64
+	p.BlocksTotal = blocksTotal.Int64
+	p.TuplesDone = tuplesDone.Int64
+	p.TuplesTotal = tuplesTotal.Int64
+
+	return p, true
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestIndexBuildProgressIsReported(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var reported []roll.IndexBuildProgress
+	opts := []roll.Option{
+		roll.WithIndexBuildProgress(func(p roll.IndexBuildProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, p)
+		}),
+	}
+
+	testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table with enough rows that building an index takes a while
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err = db.ExecContext(ctx, "INSERT INTO users (id, name) SELECT i, md5(i::text) FROM generate_series(1, 500000) AS i")
+		require.NoError(t, err)
+
+		// Build an index concurrently with progress reporting enabled
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_create_index",
+			Operations: migrations.Operations{
+				&migrations.OpCreateIndex{
+					Name:    "idx_users_id_name",
+					Table:   "users",
+					Columns: migrations.OpCreateIndexColumns{"id": {}, "name": {}},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Progress is sampled periodically, so a fast build may not be
+		// reported at all; any progress that is reported is for the index
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range reported {
+			assert.Equal(t, "idx_users_id_name", p.Index)
+			assert.NotEmpty(t, p.Phase)
+		}
+	})
+}
+
+func TestIndexBuildProgressPercent(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		progress roll.IndexBuildProgress
+		want     float64
+	}{
+		{
+			name:     "phase with block counts",
+			progress: roll.IndexBuildProgress{BlocksDone: 25, BlocksTotal: 200},
+			want:     12.5,
+		},
+		{
+			name:     "phase without block counts",
+			progress: roll.IndexBuildProgress{TuplesDone: 10, TuplesTotal: 100},
+			want:     -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.progress.Percent())
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"time"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// startMaintenance sets the maintenance flag of the schema while `migration`
+// is being completed, if maintenance mode is enabled, and then waits for the
+// configured period so that applications polling the flag can back off
+// before heavy locks are taken. The flag is tied to the session of pgConn,
+// which takes the locks. The returned function clears the flag.
+func (m *Roll) startMaintenance(ctx context.Context, migration *migrations.Migration) (func(), error) {
+	if !m.maintenanceMode {
+		return func() {}, nil
+	}
+
+	rows, err := m.pgConn.QueryContext(ctx, "SELECT pg_catalog.pg_backend_pid()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pid int
+	if err := db.ScanFirstValue(rows, &pid); err != nil {
+		return nil, err
+	}
+
+	if err := m.state.StartMaintenance(ctx, m.schema, migration.Name, pid); err != nil {
+		return nil, err
+	}
+	m.logger.Info("maintenance mode started", "migration", migration.Name, "schema", m.schema)
+
+	end := func() {
+		// The flag is cleared even if the completion was cancelled
+		if err := m.state.EndMaintenance(context.WithoutCancel(ctx), m.schema); err != nil {
+			m.logger.Warn("unable to end maintenance mode", "migration", migration.Name, "schema", m.schema, "error", err)
+			return
+		}
+		m.logger.Info("maintenance mode ended", "migration", migration.Name, "schema", m.schema)
+	}
+
+	if m.maintenanceWait > 0 {
+		timer := time.NewTimer(m.maintenanceWait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			end()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return end, nil
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	t.Parallel()
+
+	// Record whether the maintenance flag is set by the time the DDL phase of
+	// migration completion begins
+	inMaintenanceHook := func(inMaintenance *bool) roll.Option {
+		return roll.WithMigrationHooks(roll.MigrationHooks{
+			BeforeCompleteDDL: func(m *roll.Roll) (err error) {
+				*inMaintenance, err = m.State().InMaintenance(context.Background(), m.Schema())
+				return err
+			},
+		})
+	}
+
+	t.Run("the flag is set while the migration is completed", func(t *testing.T) {
+		var inMaintenance bool
+		opts := []roll.Option{roll.WithMaintenanceMode(true, 0), inMaintenanceHook(&inMaintenance)}
+
+		testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			err := mig.Start(ctx, &migrations.Migration{
+				Name:       "01_create_table",
+				Operations: migrations.Operations{createTableOp("table1")},
+			}, backfill.NewConfig())
+			require.NoError(t, err)
+			require.NoError(t, mig.Complete(ctx))
+
+			// The flag was set while the migration was being completed, and is
+			// cleared afterwards
+			assert.True(t, inMaintenance)
+
+			inMaintenance, err := state.InMaintenance(ctx, db, "pgroll", "public")
+			require.NoError(t, err)
+			assert.False(t, inMaintenance)
+		})
+	})
+
+	t.Run("the flag is not set unless maintenance mode is enabled", func(t *testing.T) {
+		var inMaintenance bool
+		opts := []roll.Option{inMaintenanceHook(&inMaintenance)}
+
+		testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			err := mig.Start(ctx, &migrations.Migration{
+				Name:       "01_create_table",
+				Operations: migrations.Operations{createTableOp("table1")},
+			}, backfill.NewConfig())
+			require.NoError(t, err)
+			require.NoError(t, mig.Complete(ctx))
+
+			assert.False(t, inMaintenance)
+		})
+	})
+
+	t.Run("a flag left by a session that has ended is ignored", func(t *testing.T) {
+		testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", nil, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+
+			// No session has a backend process ID of 0
+			require.NoError(t, mig.State().StartMaintenance(ctx, "public", "01_create_table", 0))
+
+			inMaintenance, err := state.InMaintenance(ctx, db, "pgroll", "public")
+			require.NoError(t, err)
+			assert.False(t, inMaintenance)
+		})
+	})
+}
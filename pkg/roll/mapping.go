@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/schema"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+// TableMapping describes the physical table that backs a table in the latest
+// version of the schema.
+type TableMapping struct {
+	// Name is the name of the physical table.
+	Name string `json:"name"`
+
+	// Columns maps the name of each column in the latest version of the
+	// schema to the name of the physical column that backs it.
+	Columns map[string]string `json:"columns"`
+
+	// NeedsBackfillColumn is the name of the column that marks rows of the
+	// table as needing a backfill, or empty if the table has no such column.
+	// Rows written directly to the physical table during a migration should
+	// set it to false once they have been given values for all new columns.
+	NeedsBackfillColumn string `json:"needsBackfillColumn,omitempty"`
+}
+
+// TableMappings returns, for each table in the latest version of the schema,
+// the physical table and columns that back it. While a migration is active,
+// the latest version is the one created by the active migration, so columns
+// that the migration changes map to the temporary columns that pgroll has
+// created for them.
+func (m *Roll) TableMappings(ctx context.Context) (map[string]*TableMapping, error) {
+	physical, err := m.state.ReadSchema(ctx, m.schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	logical, err := m.latestVirtualSchema(ctx, physical)
+	if err != nil {
+		return nil, err
+	}
+
+	naming, err := m.latestNaming(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := make(map[string]*TableMapping, len(logical.Tables))
+	for name, table := range logical.Tables {
+		if table.Deleted {
+			continue
+		}
+
+		mapping := &TableMapping{
+			Name:    table.Name,
+			Columns: make(map[string]string, len(table.Columns)),
+		}
+		for columnName, column := range table.Columns {
+			if column.Deleted {
+				continue
+			}
+			mapping.Columns[columnName] = column.Name
+		}
+		if pt := physical.GetTable(table.Name); pt != nil {
+			for _, column := range pt.Columns {
+				if naming.IsNeedsBackfillColumn(column) {
+					mapping.NeedsBackfillColumn = column.Name
+					break
+				}
+			}
+		}
+
+		mappings[name] = mapping
+	}
+
+	return mappings, nil
+}
+
+// latestVirtualSchema returns the schema as seen by the latest version. If
+// there is no active migration, this is the physical schema.
+func (m *Roll) latestVirtualSchema(ctx context.Context, physical *schema.Schema) (*schema.Schema, error) {
+	active, err := m.activeMigrations(ctx)
+	if errors.Is(err, state.ErrNoActiveMigration) {
+		return physical, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get active migration: %w", err)
+	}
+
+	return m.virtualSchema(ctx, active)
+}
+
+// latestNaming returns the naming of the objects created for pgroll's own use
+// by the last active migration, or the naming of this Roll instance if there
+// is no active migration.
+func (m *Roll) latestNaming(ctx context.Context) (backfill.Naming, error) {
+	active, err := m.activeMigrations(ctx)
+	if errors.Is(err, state.ErrNoActiveMigration) {
+		return m.naming, nil
+	}
+	if err != nil {
+		return backfill.Naming{}, fmt.Errorf("unable to get active migration: %w", err)
+	}
+
+	naming, err := m.state.Naming(ctx, m.schema, active[len(active)-1].Name)
+	if err != nil {
+		return backfill.Naming{}, fmt.Errorf("unable to read naming of migration %q: %w", active[len(active)-1].Name, err)
+	}
+	return naming, nil
+}
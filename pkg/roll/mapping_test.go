@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestTableMappings(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		// Create a table with a completed migration
+		err := mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		require.NoError(t, mig.Complete(ctx))
+
+		// Without an active migration, columns map to themselves
+		mappings, err := mig.TableMappings(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &roll.TableMapping{
+			Name:    "users",
+			Columns: map[string]string{"id": "id", "name": "name"},
+		}, mappings["users"])
+
+		// Start a migration that adds a column
+		err = mig.Start(ctx, &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "users",
+					Up:    "length(name)",
+					Column: migrations.Column{
+						Name:     "name_length",
+						Type:     "integer",
+						Nullable: true,
+					},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+
+		// The new column maps to the temporary column backing it
+		mappings, err = mig.TableMappings(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &roll.TableMapping{
+			Name: "users",
+			Columns: map[string]string{
+				"id":          "id",
+				"name":        "name",
+				"name_length": migrations.TemporaryName("name_length"),
+			},
+			NeedsBackfillColumn: backfill.CNeedsBackfillColumn,
+		}, mappings["users"])
+
+		// Once the migration is complete, the column maps to itself
+		require.NoError(t, mig.Complete(ctx))
+		mappings, err = mig.TableMappings(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &roll.TableMapping{
+			Name: "users",
+			Columns: map[string]string{
+				"id":          "id",
+				"name":        "name",
+				"name_length": "name_length",
+			},
+		}, mappings["users"])
+	})
+}
@@ -23,7 +23,7 @@ func (m *Roll) MissingMigrations(ctx context.Context, dir fs.FS) ([]*migrations.
 	// Create a set of local migration names for fast lookup
 	localMigNames := make(map[string]struct{}, len(files))
 	for _, file := range files {
-		mig, err := migrations.ReadRawMigration(dir, file)
+		mig, err := m.migrationCache.ReadRawMigration(dir, file)
 		if err != nil {
 			return nil, fmt.Errorf("reading migration file %s: %w", file, err)
 		}
@@ -2,25 +2,91 @@
 
 package roll
 
+import (
+	"time"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
 type options struct {
 	// lock timeout in milliseconds for pgroll DDL operations
 	lockTimeoutMs int
 
+	// idle in transaction session timeout in milliseconds for pgroll
+	// connections
+	idleInTransactionTimeoutMs int
+
 	// optional role to set before executing migrations
 	role string
 
+	// optional role to set as the owner of objects created by migrations
+	objectOwner string
+
+	// number of attempts to make when connecting to the database, and the
+	// initial delay between attempts
+	connectionAttempts   int
+	connectionRetryDelay time.Duration
+
 	// disable pgroll version schemas creation and deletion
 	disableVersionSchemas bool
 
+	// disable the `security_invoker` option on generated views
+	disableSecurityInvokerViews bool
+
+	// options of the generated views of individual tables
+	viewOptions map[string]ViewOptions
+
+	// build and drop indexes without CONCURRENTLY
+	disableConcurrentIndexes bool
+
 	// additional entries to add to the search_path during migration execution
 	searchPath []string
 
 	// whether to skip validation
 	skipValidation bool
 
+	// optional directory in which to cache decoded migration files
+	cacheDir string
+
+	// whether to leave pgroll triggers in place when completing a migration
+	keepTriggers bool
+
+	// whether to reorder operations so that intra-migration dependencies resolve
+	reorderOperations bool
+
+	// whether the operations of a migration are committed in per-table
+	// transactions
+	perTableTransactions bool
+
+	// whether a migration can be started while other migrations are active
+	migrationStacking bool
+
+	// prefix of the names of the objects that migrations create for pgroll's
+	// own use
+	internalPrefix string
+
+	// optional callback reporting the progress of concurrent index builds
+	indexBuildProgress IndexBuildProgressFn
+
+	// maximum number of tables on which constraints are validated
+	// concurrently when completing a migration
+	constraintValidationConcurrency int
+
+	// set the maintenance flag of the schema while completing a migration,
+	// and wait this long after setting it before taking heavy locks
+	maintenanceMode bool
+	maintenanceWait time.Duration
+
+	// optional classifier deciding which errors are retried
+	errorClassifier db.ErrorClassifier
+
 	migrationHooks MigrationHooks
 
 	verbose bool
+
+	// optional logger to which migration events are written
+	logger migrations.Logger
 }
 
 // MigrationHooks defines hooks that can be set to be called at various points
@@ -45,6 +111,17 @@ func WithLockTimeoutMs(lockTimeoutMs int) Option {
 	}
 }
 
+// WithIdleInTransactionTimeoutMs sets the idle_in_transaction_session_timeout
+// in milliseconds for pgroll connections, so that Postgres terminates a
+// connection that stalls in the middle of a transaction instead of letting it
+// hold its locks indefinitely. A value of 0 leaves the server default in
+// place.
+func WithIdleInTransactionTimeoutMs(timeoutMs int) Option {
+	return func(o *options) {
+		o.idleInTransactionTimeoutMs = timeoutMs
+	}
+}
+
 // WithRole sets the role to set before executing migrations
 func WithRole(role string) Option {
 	return func(o *options) {
@@ -52,6 +129,25 @@ func WithRole(role string) Option {
 	}
 }
 
+// WithObjectOwner sets the role that owns the tables, types, version schemas
+// and views created by migrations. Individual `create_table` operations can
+// override the owner with their `owner` field.
+func WithObjectOwner(role string) Option {
+	return func(o *options) {
+		o.objectOwner = role
+	}
+}
+
+// WithConnectionAttempts sets the number of attempts made to connect to the
+// database, and the initial delay between attempts. Subsequent delays increase
+// exponentially.
+func WithConnectionAttempts(attempts int, delay time.Duration) Option {
+	return func(o *options) {
+		o.connectionAttempts = attempts
+		o.connectionRetryDelay = delay
+	}
+}
+
 // WithVersionSchema enables or disables the creation of version schema for
 // migrations.
 func WithVersionSchema(enabled bool) Option {
@@ -60,6 +156,51 @@ func WithVersionSchema(enabled bool) Option {
 	}
 }
 
+// WithConcurrentIndexes enables or disables building and dropping indexes
+// with CONCURRENTLY. Disabling it is meant for Postgres-compatible databases
+// that don't support concurrent index builds; the index operations of a
+// migration then block writes to their tables while they run. Concurrent
+// index builds are enabled by default.
+func WithConcurrentIndexes(enabled bool) Option {
+	return func(o *options) {
+		o.disableConcurrentIndexes = !enabled
+	}
+}
+
+// WithSecurityInvokerViews enables or disables the `security_invoker` option
+// on the views that pgroll creates in version schemas. The option is only
+// applied on Postgres 15 and later, and is enabled by default.
+func WithSecurityInvokerViews(enabled bool) Option {
+	return func(o *options) {
+		o.disableSecurityInvokerViews = !enabled
+	}
+}
+
+// ViewOptions are the options of the view that exposes a table in version
+// schemas.
+type ViewOptions struct {
+	// SecurityInvoker overrides the `security_invoker` option set for all
+	// views with WithSecurityInvokerViews, if set. It is only applied on
+	// Postgres 15 and later.
+	SecurityInvoker *bool
+
+	// SecurityBarrier creates the view with the `security_barrier` option,
+	// which stops the conditions of queries on the view from being pushed down
+	// into it.
+	SecurityBarrier bool
+}
+
+// WithViewOptions sets the options of the view that exposes the given table in
+// version schemas, in place of those set for all views.
+func WithViewOptions(table string, opts ViewOptions) Option {
+	return func(o *options) {
+		if o.viewOptions == nil {
+			o.viewOptions = make(map[string]ViewOptions)
+		}
+		o.viewOptions[table] = opts
+	}
+}
+
 // WithMigrationHooks sets the migration hooks for the Roll instance
 // Migration hooks are called at various points during the migration process
 // to allow for custom behavior to be injected
@@ -86,6 +227,130 @@ func WithSkipValidation(skip bool) Option {
 	}
 }
 
+// WithReorderOperations controls whether the operations in a migration are
+// reordered before the migration is started, so that operations creating a
+// table or type run before the operations in the same migration that depend on
+// it.
+func WithReorderOperations(reorder bool) Option {
+	return func(o *options) {
+		o.reorderOperations = reorder
+	}
+}
+
+// WithPerTableTransactions controls whether the operations of a migration are
+// grouped by the tables they touch and each group is committed in a
+// transaction of its own when starting and completing the migration. Locks on
+// a table are then held until its group commits rather than being taken and
+// released statement by statement, and a group is applied either in full or
+// not at all. Atomicity is per table: if a later group fails, the groups
+// before it remain committed. Start rolls back the whole migration on failure
+// as usual, while a failed Complete leaves the migration active with the
+// committed groups applied.
+//
+// Operations that can't run inside a transaction block, such as raw SQL and
+// concurrent index builds, run on their own. Non-transactional migrations are
+// unaffected.
+func WithPerTableTransactions(enabled bool) Option {
+	return func(o *options) {
+		o.perTableTransactions = enabled
+	}
+}
+
+// WithMigrationStacking controls whether a migration can be started while
+// other migrations are active. A stacked migration must not touch any table,
+// column or type touched by the active migrations; Start returns a
+// migrations.MigrationConflictError naming the first shared object otherwise.
+// Each active migration has its own version schema. Stacked migrations are
+// completed oldest first and rolled back newest first.
+func WithMigrationStacking(stacking bool) Option {
+	return func(o *options) {
+		o.migrationStacking = stacking
+	}
+}
+
+// WithInternalPrefix sets the prefix of the names of the columns, triggers and
+// other objects that migrations started by the Roll instance create for
+// pgroll's own use. An empty prefix uses backfill.DefaultPrefix. The prefix is
+// recorded with each migration when it is started, and the migration is
+// completed or rolled back with the recorded prefix whatever the prefix of the
+// Roll instance that completes or rolls it back. Migrations can only be
+// stacked on active migrations started with the same prefix.
+func WithInternalPrefix(prefix string) Option {
+	return func(o *options) {
+		o.internalPrefix = prefix
+	}
+}
+
+// WithKeepTriggers controls whether the triggers and trigger functions
+// created by a migration are left in place when the migration is completed.
+// The triggers are disabled rather than dropped so that they can be inspected
+// when debugging backfills. Use `Roll.Cleanup` to remove them afterwards.
+//
+// This option is intended for debugging only.
+func WithKeepTriggers(keep bool) Option {
+	return func(o *options) {
+		o.keepTriggers = keep
+	}
+}
+
+// WithConstraintValidationConcurrency sets the maximum number of tables on
+// which NOT VALID constraints are validated concurrently, each on its own
+// connection, when completing a migration. Values less than 1 select
+// DefaultConstraintValidationConcurrency.
+func WithConstraintValidationConcurrency(n int) Option {
+	return func(o *options) {
+		o.constraintValidationConcurrency = n
+	}
+}
+
+// WithMaintenanceMode makes Complete set the maintenance flag of the schema
+// for as long as it takes locks that block the application's queries, so that
+// applications polling the flag with state.InMaintenance can back off. Once
+// the flag is set, Complete waits for `wait` before taking the locks, to give
+// the applications time to notice.
+func WithMaintenanceMode(enabled bool, wait time.Duration) Option {
+	return func(o *options) {
+		o.maintenanceMode = enabled
+		o.maintenanceWait = wait
+	}
+}
+
+// WithErrorClassifier sets the function used to decide whether an error
+// returned by a query is transient and the query should be retried. It is
+// consulted when executing migration DDL, backfilling and validating
+// constraints. By default only lock_timeout errors are retried; see
+// db.IsRetryableError.
+func WithErrorClassifier(fn db.ErrorClassifier) Option {
+	return func(o *options) {
+		o.errorClassifier = fn
+	}
+}
+
+// WithCacheDir enables caching of decoded migration files in the given
+// directory. Cached entries are invalidated when a migration file changes.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithIndexBuildProgress sets a callback that is called periodically with
+// the progress of each index that is built concurrently during a migration.
+func WithIndexBuildProgress(fn IndexBuildProgressFn) Option {
+	return func(o *options) {
+		o.indexBuildProgress = fn
+	}
+}
+
+// WithLogger sets the logger to which the events of each migration, such as
+// the start and end of each operation and each committed backfill batch, are
+// written. It takes precedence over WithLogging.
+func WithLogger(logger migrations.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
 // WithLogging enables verbose logging for the Roll instance
 func WithLogging(enabled bool) Option {
 	return func(o *options) {
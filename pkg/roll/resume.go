@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+// backfillProgress stores the progress of the backfills of a migration in
+// the pgroll state, so that they can be resumed if they are interrupted.
+type backfillProgress struct {
+	state     *state.State
+	schema    string
+	migration string
+}
+
+func (p *backfillProgress) Load(ctx context.Context, table string) ([]string, bool, error) {
+	return p.state.BackfillProgress(ctx, p.schema, p.migration, table)
+}
+
+func (p *backfillProgress) Save(ctx context.Context, table string, lastValue []string, rows, total int64, done bool) error {
+	return p.state.SaveBackfillProgress(ctx, p.schema, p.migration, table, lastValue, rows, total, done)
+}
+
+// resumeBackfill continues the backfill of the active migration, whose start
+// was interrupted after its DDL operations had run. The backfill of the tables
+// that had finished is skipped, and the backfill of the others continues
+// after the last batch that was committed.
+func (m *Roll) resumeBackfill(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) error {
+	m.logger.Info("resuming backfill of migration", "migration", migration.Name, "schema", m.schema)
+
+	job, err := m.backfillJob(ctx, migration)
+	if err != nil {
+		return fmt.Errorf("unable to resume backfill of migration %q: %w", migration.Name, err)
+	}
+
+	return m.performBackfills(ctx, migration, job, cfg)
+}
+
+// continueStart continues the start of the active migration when it is
+// started again without an unfinished backfill. If the previous start was
+// interrupted after the DDL operations had run but before the backfill was
+// recorded, the backfill is run; otherwise the migration has already been
+// started and nothing is done.
+func (m *Roll) continueStart(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) error {
+	// The version schema is created once all of the operations have run
+	if !m.disableVersionSchemas {
+		exists, err := m.schemaExists(ctx, VersionedSchemaName(m.schema, migration.VersionSchemaName()))
+		if err != nil {
+			return fmt.Errorf("unable to check for version schema: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("the start of migration %q was interrupted before its operations had run; roll it back and start it again", migration.Name)
+		}
+	}
+
+	job, err := m.backfillJob(ctx, migration)
+	if err != nil {
+		return fmt.Errorf("unable to continue migration %q: %w", migration.Name, err)
+	}
+
+	recorded, err := m.state.BackfillTables(ctx, m.schema, migration.Name)
+	if err != nil {
+		return fmt.Errorf("unable to read backfill progress: %w", err)
+	}
+	for _, table := range job.Tables {
+		if !slices.ContainsFunc(recorded, func(t state.TableBackfill) bool { return t.Table == table.Name }) {
+			m.logger.Info("resuming backfill of migration", "migration", migration.Name, "schema", m.schema)
+			return m.performBackfills(ctx, migration, job, cfg)
+		}
+	}
+
+	m.logger.Info("migration is already started", "migration", migration.Name, "schema", m.schema)
+	return nil
+}
+
+// schemaExists returns true if the Postgres schema exists.
+func (m *Roll) schemaExists(ctx context.Context, name string) (bool, error) {
+	rows, err := m.pgConn.QueryContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_namespace WHERE nspname = $1)", name)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var exists bool
+	if err := db.ScanFirstValue(rows, &exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// appliedMigration returns whether the migration has already been started,
+// and whether it has been completed. A migration is the same as one that was
+// started if it has the same name and the same checksum. A wrapped
+// ErrMismatchedMigration is returned if a migration with the same name was
+// started with different contents.
+func (m *Roll) appliedMigration(ctx context.Context, migration *migrations.Migration) (started, done bool, err error) {
+	_, done, err = m.state.MigrationRun(ctx, m.schema, migration.Name)
+	if errors.Is(err, state.ErrMigrationNotFound) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("unable to read migration %q: %w", migration.Name, err)
+	}
+
+	raw, err := m.state.Migration(ctx, m.schema, migration.Name)
+	if err != nil {
+		return false, false, fmt.Errorf("unable to read migration %q: %w", migration.Name, err)
+	}
+	recorded, err := migrations.ParseMigration(raw)
+	if err != nil {
+		return false, false, fmt.Errorf("unable to parse migration %q: %w", migration.Name, err)
+	}
+
+	same, err := m.sameMigration(ctx, migration, recorded)
+	if err != nil {
+		return false, false, err
+	}
+	if !same {
+		return false, false, fmt.Errorf("%w: migration %q has already been applied with different contents",
+			ErrMismatchedMigration, migration.Name)
+	}
+
+	return true, done, nil
+}
+
+// sameMigration returns true if the migration has the same checksum as the
+// recorded migration of the same name. With operation reordering, the
+// operations of the recorded migration were reordered before it was recorded,
+// so they are compared with the operations of the migration reordered in the
+// same way.
+func (m *Roll) sameMigration(ctx context.Context, migration, recorded *migrations.Migration) (bool, error) {
+	want, err := recorded.Checksum()
+	if err != nil {
+		return false, err
+	}
+	got, err := migration.Checksum()
+	if err != nil {
+		return false, err
+	}
+	if got == want || !m.reorderOperations {
+		return got == want, nil
+	}
+
+	s, err := m.state.SchemaBeforeMigration(ctx, m.schema, migration.Name)
+	if err != nil {
+		return false, fmt.Errorf("unable to read schema before migration %q: %w", migration.Name, err)
+	}
+	sorted := *migration
+	sorted.Operations = slices.Clone(migration.Operations)
+	if err := sorted.SortOperations(s); err != nil {
+		return false, nil
+	}
+	got, err = sorted.Checksum()
+	if err != nil {
+		return false, err
+	}
+	return got == want, nil
+}
+
+// backfillJob rebuilds the backfill job of the active migration from the
+// schema as it was before the migration started. None of the migration's
+// operations are run against the database.
+func (m *Roll) backfillJob(ctx context.Context, migration *migrations.Migration) (*backfill.Job, error) {
+	s, err := m.state.LatestSchema(ctx, m.schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema before migration: %w", err)
+	}
+	if s == nil {
+		return nil, errors.New("no schema is recorded from before the migration started")
+	}
+
+	if m.reorderOperations {
+		if err := migration.SortOperations(s); err != nil {
+			return nil, fmt.Errorf("unable to reorder operations: %w", err)
+		}
+	}
+
+	dry, groups := m.dryRun()
+	job := backfill.NewJob(m.schema, VersionedSchemaName(m.schema, migration.VersionSchemaName()))
+	for i, op := range migration.Operations {
+		startOp, err := op.Start(ctx, dry.logger, groups.rec, s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect backfill tasks: %w", err)
+		}
+		if startOp == nil || startOp.BackfillTask == nil {
+			continue
+		}
+		if !migration.IsReversible() {
+			startOp.BackfillTask.RemoveDownTriggers()
+		}
+		startOp.BackfillTask.SetOperation(operationLabel(i, op))
+		job.AddTask(startOp.BackfillTask)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,365 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+// newRoll creates a Roll instance with its own connections, as a separate
+// process would.
+func newRoll(t *testing.T, connStr string, opts ...roll.Option) *roll.Roll {
+	t.Helper()
+	ctx := context.Background()
+
+	st, err := state.New(ctx, connStr, "pgroll")
+	require.NoError(t, err)
+	require.NoError(t, st.Init(ctx))
+
+	mig, err := roll.New(ctx, connStr, "public", st, opts...)
+	require.NoError(t, err)
+
+	return mig
+}
+
+// addCheckedColumnMigration adds a column that requires a backfill, with a
+// check constraint that is only validated on completion and an assertion.
+var addCheckedColumnMigration = migrations.Migration{
+	Name: "02_add_column",
+	Operations: migrations.Operations{
+		&migrations.OpAddColumn{
+			Table: "users",
+			Up:    "length(name)",
+			Column: migrations.Column{
+				Name: "name_length",
+				Type: "integer",
+				Check: &migrations.CheckConstraint{
+					Name:       "name_length_positive",
+					Constraint: "name_length > 0",
+				},
+			},
+		},
+	},
+	Assertions: []migrations.MigrationAssertion{
+		{Name: "all_backfilled", Query: "SELECT count(*) FROM users WHERE name_length IS NULL"},
+	},
+}
+
+func TestMigrationCanBeCompletedByAnotherProcess(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithConnectionToContainer(t, func(db *sql.DB, connStr string) {
+		ctx := context.Background()
+
+		// Create a table with some rows and start a migration in the first
+		// process, which then exits
+		first := newRoll(t, connStr)
+		require.NoError(t, first.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig()))
+		require.NoError(t, first.Complete(ctx))
+
+		_, err := db.ExecContext(ctx, "INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')")
+		require.NoError(t, err)
+
+		migration := addCheckedColumnMigration
+		require.NoError(t, first.Start(ctx, &migration, backfill.NewConfig()))
+		require.NoError(t, first.Close())
+
+		// Complete the migration in a second process
+		second := newRoll(t, connStr)
+		defer second.Close()
+
+		status, err := second.Status(ctx, "public")
+		require.NoError(t, err)
+		assert.Equal(t, "02_add_column", status.Version)
+		assert.Equal(t, roll.InProgressMigrationStatus, status.Status)
+
+		require.NoError(t, second.Complete(ctx))
+
+		// The column has been backfilled and renamed to its final name
+		rows := MustSelect(t, db, "public", "02_add_column", "users")
+		assert.ElementsMatch(t, []map[string]any{
+			{"id": 1, "name": "alice", "name_length": 5},
+			{"id": 2, "name": "bob", "name_length": 3},
+		}, rows)
+
+		status, err = second.Status(ctx, "public")
+		require.NoError(t, err)
+		assert.Equal(t, roll.CompleteMigrationStatus, status.Status)
+	})
+}
+
+func TestMigrationCanBeRolledBackByAnotherProcess(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithConnectionToContainer(t, func(db *sql.DB, connStr string) {
+		ctx := context.Background()
+
+		// Start a migration in the first process, which then exits
+		first := newRoll(t, connStr)
+		require.NoError(t, first.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig()))
+		require.NoError(t, first.Complete(ctx))
+
+		migration := addCheckedColumnMigration
+		require.NoError(t, first.Start(ctx, &migration, backfill.NewConfig()))
+		require.NoError(t, first.Close())
+
+		// Roll the migration back in a second process
+		second := newRoll(t, connStr)
+		defer second.Close()
+
+		require.NoError(t, second.Rollback(ctx))
+
+		// The temporary column has been removed
+		var exists bool
+		err := db.QueryRowContext(ctx, `SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'users' AND column_name = '_pgroll_new_name_length'
+		)`).Scan(&exists)
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		status, err := second.Status(ctx, "public")
+		require.NoError(t, err)
+		assert.Equal(t, "01_create_table", status.Version)
+	})
+}
+
+func TestStartReusesTemporaryColumnFromInterruptedStart(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		require.NoError(t, mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig()))
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err := db.ExecContext(ctx, "INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')")
+		require.NoError(t, err)
+
+		// Leave behind the temporary column and constraint that an interrupted
+		// attempt to start the migration would have created
+		_, err = db.ExecContext(ctx, `ALTER TABLE users
+			ADD COLUMN _pgroll_new_name_length integer,
+			ADD CONSTRAINT name_length_positive CHECK (_pgroll_new_name_length > 0) NOT VALID`)
+		require.NoError(t, err)
+
+		migration := addCheckedColumnMigration
+		require.NoError(t, mig.Start(ctx, &migration, backfill.NewConfig()))
+		require.NoError(t, mig.Complete(ctx))
+
+		rows := MustSelect(t, db, "public", "02_add_column", "users")
+		assert.ElementsMatch(t, []map[string]any{
+			{"id": 1, "name": "alice", "name_length": 5},
+			{"id": 2, "name": "bob", "name_length": 3},
+		}, rows)
+	})
+}
+
+func TestStartFailsIfTemporaryColumnHasWrongType(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+		ctx := context.Background()
+
+		require.NoError(t, mig.Start(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig()))
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err := db.ExecContext(ctx, "ALTER TABLE users ADD COLUMN _pgroll_new_name_length text")
+		require.NoError(t, err)
+
+		migration := addCheckedColumnMigration
+		err = mig.Start(ctx, &migration, backfill.NewConfig())
+		require.ErrorAs(t, err, &migrations.ShadowColumnMismatchError{})
+	})
+}
+
+func TestResumeBackfill(t *testing.T) {
+	t.Parallel()
+
+	createTable := func() *migrations.Migration {
+		return &migrations.Migration{
+			Name: "01_create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name: "items",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "serial", Pk: true},
+						{Name: "name", Type: "text"},
+					},
+				},
+			},
+		}
+	}
+	addColumn := func() *migrations.Migration {
+		return &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "items",
+					Up:    "upper(name)",
+					Column: migrations.Column{
+						Name:     "name_upper",
+						Type:     "text",
+						Nullable: true,
+					},
+				},
+			},
+		}
+	}
+
+	setup := func(t *testing.T, mig *roll.Roll, db *sql.DB) {
+		t.Helper()
+		ctx := context.Background()
+
+		require.NoError(t, mig.Start(ctx, createTable(), backfill.NewConfig()))
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err := db.ExecContext(ctx, "INSERT INTO items (name) SELECT 'item ' || i FROM generate_series(1, 10) AS i")
+		require.NoError(t, err)
+	}
+
+	t.Run("an interrupted backfill continues after the last batch", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			// Run the DDL operations of the migration and record a backfill
+			// that was interrupted after the row with id 5
+			_, err := mig.StartDDLOperations(ctx, addColumn())
+			require.NoError(t, err)
+			require.NoError(t, mig.State().StartBackfill(ctx, mig.Schema(), "02_add_column", []string{"items"}))
+			require.NoError(t, mig.State().SaveBackfillProgress(ctx, mig.Schema(), "02_add_column", "items", []string{"5"}, 5, 10, false))
+
+			// Starting the migration again resumes the backfill
+			err = mig.Start(ctx, addColumn(), backfill.NewConfig(backfill.WithBatchSize(2)))
+			require.NoError(t, err)
+
+			// Only the rows after the recorded position were backfilled
+			rows, err := db.QueryContext(ctx, "SELECT id FROM public_02_add_column.items WHERE name_upper IS NOT NULL ORDER BY id")
+			require.NoError(t, err)
+			defer rows.Close()
+			var ids []int
+			for rows.Next() {
+				var id int
+				require.NoError(t, rows.Scan(&id))
+				ids = append(ids, id)
+			}
+			require.NoError(t, rows.Err())
+			assert.Equal(t, []int{6, 7, 8, 9, 10}, ids)
+
+			unfinished, err := mig.State().HasUnfinishedBackfill(ctx, mig.Schema(), "02_add_column")
+			require.NoError(t, err)
+			assert.False(t, unfinished)
+
+			require.NoError(t, mig.Complete(ctx))
+		})
+	})
+
+	t.Run("a start interrupted before the backfill began runs the backfill", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			// Run the DDL operations of the migration without recording a backfill
+			_, err := mig.StartDDLOperations(ctx, addColumn())
+			require.NoError(t, err)
+
+			// Starting the migration again backfills the table
+			require.NoError(t, mig.Start(ctx, addColumn(), backfill.NewConfig()))
+
+			var backfilled int
+			err = db.QueryRowContext(ctx, "SELECT count(*) FROM items WHERE _pgroll_new_name_upper = upper(name)").Scan(&backfilled)
+			require.NoError(t, err)
+			assert.Equal(t, 10, backfilled)
+
+			require.NoError(t, mig.Complete(ctx))
+		})
+	})
+
+	t.Run("starting a migration whose backfill has finished again does nothing", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			require.NoError(t, mig.Start(ctx, addColumn(), backfill.NewConfig()))
+			require.NoError(t, mig.Start(ctx, addColumn(), backfill.NewConfig()))
+
+			active, err := mig.State().GetActiveMigration(ctx, mig.Schema())
+			require.NoError(t, err)
+			assert.Equal(t, "02_add_column", active.Name)
+
+			require.NoError(t, mig.Complete(ctx))
+		})
+	})
+
+	t.Run("starting a completed migration again does nothing", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			require.NoError(t, mig.Start(ctx, addColumn(), backfill.NewConfig()))
+			require.NoError(t, mig.Complete(ctx))
+
+			require.NoError(t, mig.Start(ctx, addColumn(), backfill.NewConfig()))
+
+			active, err := mig.State().IsActiveMigrationPeriod(ctx, mig.Schema())
+			require.NoError(t, err)
+			assert.False(t, active)
+		})
+	})
+
+	t.Run("a migration with the name of a started migration but different contents is rejected", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			require.NoError(t, mig.Start(ctx, addColumn(), backfill.NewConfig()))
+			require.NoError(t, mig.Complete(ctx))
+
+			changed := addColumn()
+			changed.Operations[0].(*migrations.OpAddColumn).Up = "lower(name)"
+
+			err := mig.Start(ctx, changed, backfill.NewConfig())
+			require.ErrorIs(t, err, roll.ErrMismatchedMigration)
+		})
+	})
+
+	t.Run("rolling back a migration discards the progress of its backfill", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			_, err := mig.StartDDLOperations(ctx, addColumn())
+			require.NoError(t, err)
+			require.NoError(t, mig.State().StartBackfill(ctx, mig.Schema(), "02_add_column", []string{"items"}))
+			require.NoError(t, mig.Rollback(ctx))
+
+			unfinished, err := mig.State().HasUnfinishedBackfill(ctx, mig.Schema(), "02_add_column")
+			require.NoError(t, err)
+			assert.False(t, unfinished)
+		})
+	})
+}
@@ -7,9 +7,11 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 
+	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/migrations"
 	"github.com/xataio/pgroll/pkg/state"
@@ -28,6 +30,10 @@ const (
 var (
 	ErrMismatchedMigration          = fmt.Errorf("remote migration does not match local migration")
 	ErrExistingSchemaWithoutHistory = fmt.Errorf("schema has existing tables but no migration history - baseline required")
+	ErrRoleDoesNotExist             = fmt.Errorf("role does not exist")
+	ErrRoleNotGranted               = fmt.Errorf("current role is not a member of role")
+	ErrIrreversibleMigration        = fmt.Errorf("migration is declared as forward-only and can't be rolled back")
+	ErrWriteGuardDisabled           = fmt.Errorf("write guard was disabled or dropped while the migration was active")
 )
 
 type Roll struct {
@@ -41,10 +47,64 @@ type Roll struct {
 	// disable pgroll version schemas creation and deletion
 	disableVersionSchemas bool
 
+	// disable the `security_invoker` option on generated views
+	disableSecurityInvokerViews bool
+
+	// options of the generated views of individual tables
+	viewOptions map[string]ViewOptions
+
+	// role to set as the owner of objects created by migrations
+	objectOwner string
+
 	migrationHooks MigrationHooks
 	state          *state.State
 	pgVersion      PGVersion
 	skipValidation bool
+
+	// reorder operations so that intra-migration dependencies resolve
+	reorderOperations bool
+
+	// commit the operations of migrations in per-table transactions
+	perTableTransactions bool
+
+	// allow migrations to be started while other migrations are active
+	migrationStacking bool
+
+	// naming of the objects that migrations started by this instance create
+	// for pgroll's own use
+	naming backfill.Naming
+
+	// leave pgroll triggers in place when completing migrations
+	keepTriggers bool
+
+	// build and drop indexes without CONCURRENTLY
+	disableConcurrentIndexes bool
+
+	// cache of decoded migration files; nil if caching is disabled
+	migrationCache *migrations.Cache
+
+	// optional callback reporting the progress of concurrent index builds
+	indexBuildProgress IndexBuildProgressFn
+
+	// cache of the introspected schema during a Start or Complete invocation;
+	// nil outside of these
+	schemaCache *schemaCache
+
+	// maximum number of tables on which constraints are validated
+	// concurrently when completing a migration
+	constraintValidationConcurrency int
+
+	// set the maintenance flag of the schema while completing a migration,
+	// and wait this long after setting it before taking heavy locks
+	maintenanceMode bool
+	maintenanceWait time.Duration
+
+	// opens a new connection, limited to a single session, with the same
+	// session settings as pgConn
+	openConn func(context.Context) (db.Conn, error)
+
+	// decides which errors returned by queries are retried
+	errorClassifier db.ErrorClassifier
 }
 
 // New creates a new Roll instance
@@ -59,26 +119,107 @@ func New(ctx context.Context, pgURL, schema string, state *state.State, opts ...
 		return nil, err
 	}
 
+	return newRoll(ctx, conn, schema, state, rollOpts, func(ctx context.Context) (db.Conn, error) {
+		conn, err := setupConn(ctx, pgURL, schema, *rollOpts)
+		if err != nil {
+			return nil, err
+		}
+		conn.SetMaxOpenConns(1)
+		conn.SetMaxIdleConns(1)
+		return conn, nil
+	})
+}
+
+// NewFromDB creates a new Roll instance that takes its connections from
+// `pool`, which is owned by the caller. The connections are returned to the
+// pool with their session settings reset when the Roll instance is closed,
+// and the pool itself is left open.
+func NewFromDB(ctx context.Context, pool *sql.DB, schema string, state *state.State, opts ...Option) (*Roll, error) {
+	rollOpts := &options{}
+	for _, o := range opts {
+		o(rollOpts)
+	}
+
+	if err := db.PingWithRetry(ctx, pool, rollOpts.connectionAttempts, rollOpts.connectionRetryDelay); err != nil {
+		return nil, err
+	}
+
+	openConn := func(ctx context.Context) (db.Conn, error) {
+		return setupPooledConn(ctx, pool, schema, *rollOpts)
+	}
+	conn, err := openConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newRoll(ctx, conn, schema, state, rollOpts, openConn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// newRoll creates a new Roll instance that runs migrations on `conn`.
+func newRoll(ctx context.Context, conn db.Conn, schema string, state *state.State, rollOpts *options, openConn func(context.Context) (db.Conn, error)) (*Roll, error) {
 	logger := migrations.NewNoopLogger()
-	if rollOpts.verbose {
+	switch {
+	case rollOpts.logger != nil:
+		logger = rollOpts.logger
+	case rollOpts.verbose:
 		logger = migrations.NewLogger()
 	}
 
 	var pgMajorVersion PGVersion
-	err = conn.QueryRowContext(ctx, "SELECT substring(split_part(version(), ' ', 2) from '^[0-9]+')").Scan(&pgMajorVersion)
+	err := conn.QueryRowContext(ctx, "SELECT substring(split_part(version(), ' ', 2) from '^[0-9]+')").Scan(&pgMajorVersion)
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve postgres version: %w", err)
 	}
 
+	if rollOpts.objectOwner != "" {
+		if err := checkRole(ctx, &db.RDB{DB: conn, IsRetryable: rollOpts.errorClassifier}, rollOpts.objectOwner); err != nil {
+			return nil, fmt.Errorf("invalid object owner: %w", err)
+		}
+	}
+
+	validationConcurrency := rollOpts.constraintValidationConcurrency
+	if validationConcurrency < 1 {
+		validationConcurrency = DefaultConstraintValidationConcurrency
+	}
+
+	var migrationCache *migrations.Cache
+	if rollOpts.cacheDir != "" {
+		migrationCache, err = migrations.NewCache(rollOpts.cacheDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Roll{
-		pgConn:                &db.RDB{DB: conn},
-		logger:                logger,
-		schema:                schema,
-		state:                 state,
-		pgVersion:             pgMajorVersion,
-		disableVersionSchemas: rollOpts.disableVersionSchemas,
-		migrationHooks:        rollOpts.migrationHooks,
-		skipValidation:        rollOpts.skipValidation,
+		pgConn:                          &db.RDB{DB: conn, IsRetryable: rollOpts.errorClassifier},
+		logger:                          logger,
+		schema:                          schema,
+		state:                           state,
+		pgVersion:                       pgMajorVersion,
+		disableVersionSchemas:           rollOpts.disableVersionSchemas,
+		disableSecurityInvokerViews:     rollOpts.disableSecurityInvokerViews,
+		viewOptions:                     rollOpts.viewOptions,
+		objectOwner:                     rollOpts.objectOwner,
+		migrationHooks:                  rollOpts.migrationHooks,
+		skipValidation:                  rollOpts.skipValidation,
+		reorderOperations:               rollOpts.reorderOperations,
+		perTableTransactions:            rollOpts.perTableTransactions,
+		migrationStacking:               rollOpts.migrationStacking,
+		naming:                          backfill.NewNaming(rollOpts.internalPrefix),
+		keepTriggers:                    rollOpts.keepTriggers,
+		disableConcurrentIndexes:        rollOpts.disableConcurrentIndexes,
+		migrationCache:                  migrationCache,
+		indexBuildProgress:              rollOpts.indexBuildProgress,
+		constraintValidationConcurrency: validationConcurrency,
+		maintenanceMode:                 rollOpts.maintenanceMode,
+		maintenanceWait:                 rollOpts.maintenanceWait,
+		errorClassifier:                 rollOpts.errorClassifier,
+		openConn:                        openConn,
 	}, nil
 }
 
@@ -97,30 +238,125 @@ func setupConn(ctx context.Context, pgURL, schema string, options options) (*sql
 		return nil, err
 	}
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := db.PingWithRetry(ctx, conn, options.connectionAttempts, options.connectionRetryDelay); err != nil {
 		return nil, err
 	}
 
-	_, err = conn.ExecContext(ctx, "SET pgroll.no_inferred_migrations TO 'TRUE'")
+	if err := setupSession(ctx, conn, options); err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// setupPooledConn takes a connection from `pool` and sets up its session as
+// setupConn does.
+func setupPooledConn(ctx context.Context, pool *sql.DB, schema string, options options) (db.Conn, error) {
+	conn, err := db.NewPooledConn(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	searchPath := make([]string, 0, len(options.searchPath)+1)
+	for _, s := range append([]string{schema}, options.searchPath...) {
+		searchPath = append(searchPath, pq.QuoteIdentifier(s))
+	}
+	setup := []string{
+		fmt.Sprintf("SET search_path TO %s", strings.Join(searchPath, ", ")),
+		fmt.Sprintf("SET application_name TO %s", pq.QuoteLiteral(applicationName)),
+	}
+	for _, stmt := range setup {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to set up session: %w", err)
+		}
+	}
+
+	if err := setupSession(ctx, conn, options); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// setupSession applies the session settings configured by `options` to
+// `conn`.
+func setupSession(ctx context.Context, conn db.Conn, options options) error {
+	_, err := conn.ExecContext(ctx, "SET pgroll.no_inferred_migrations TO 'TRUE'")
 	if err != nil {
-		return nil, fmt.Errorf("unable to set pgroll.no_inferred_migrations to true: %w", err)
+		return fmt.Errorf("unable to set pgroll.no_inferred_migrations to true: %w", err)
 	}
 
 	if options.lockTimeoutMs > 0 {
 		_, err = conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout to '%dms'", options.lockTimeoutMs))
 		if err != nil {
-			return nil, fmt.Errorf("unable to set lock_timeout: %w", err)
+			return fmt.Errorf("unable to set lock_timeout: %w", err)
+		}
+	}
+
+	if options.idleInTransactionTimeoutMs > 0 {
+		_, err = conn.ExecContext(ctx, fmt.Sprintf("SET idle_in_transaction_session_timeout to '%dms'", options.idleInTransactionTimeoutMs))
+		if err != nil {
+			return fmt.Errorf("unable to set idle_in_transaction_session_timeout: %w", err)
 		}
 	}
 
 	if options.role != "" {
 		_, err = conn.ExecContext(ctx, fmt.Sprintf("SET ROLE %s", options.role))
 		if err != nil {
-			return nil, fmt.Errorf("unable to set role to '%s': %w", options.role, err)
+			return fmt.Errorf("unable to set role to '%s': %w", options.role, err)
 		}
 	}
 
-	return conn, nil
+	return nil
+}
+
+// checkRole ensures that the given role exists and that the current role is
+// a member of it, which is required to transfer ownership of objects to it.
+func checkRole(ctx context.Context, conn db.DB, role string) error {
+	rows, err := conn.QueryContext(ctx,
+		"SELECT pg_has_role(current_user, oid, 'MEMBER') FROM pg_catalog.pg_roles WHERE rolname = $1",
+		role)
+	if err != nil {
+		return fmt.Errorf("unable to check role %q: %w", role, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %q", ErrRoleDoesNotExist, role)
+	}
+
+	var isMember bool
+	if err := rows.Scan(&isMember); err != nil {
+		return err
+	}
+	if !isMember {
+		return fmt.Errorf("%w: %q", ErrRoleNotGranted, role)
+	}
+
+	return rows.Err()
+}
+
+// checkRoleExists ensures that the given role exists.
+func checkRoleExists(ctx context.Context, conn db.DB, role string) error {
+	rows, err := conn.QueryContext(ctx, "SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $1", role)
+	if err != nil {
+		return fmt.Errorf("unable to check role %q: %w", role, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %q", ErrRoleDoesNotExist, role)
+	}
+
+	return rows.Err()
 }
 
 // Init initializes the Roll instance
@@ -148,6 +384,12 @@ func (m *Roll) Schema() string {
 	return m.schema
 }
 
+// ParseMigration parses a migration, reusing the operations parsed when it was
+// read from the migration cache
+func (m *Roll) ParseMigration(raw *migrations.RawMigration) (*migrations.Migration, error) {
+	return m.migrationCache.ParseMigration(raw)
+}
+
 func (m *Roll) UseVersionSchema() bool {
 	return !m.disableVersionSchemas
 }
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// Runner runs migrations the way the pgroll CLI does, returning the outcome
+// of each step instead of printing it. It is the entrypoint for programs that
+// embed pgroll, which typically create the Roll instance it runs migrations
+// with using NewFromDB:
+//
+//	st, err := state.NewFromDB(ctx, pool, "pgroll")
+//	...
+//	m, err := roll.NewFromDB(ctx, pool, "public", st)
+//	...
+//	runner := roll.NewRunner(m)
+//	defer runner.Close()
+type Runner struct {
+	m *Roll
+}
+
+// StartResult describes the outcome of starting a migration.
+type StartResult struct {
+	// Migration is the name of the migration
+	Migration string
+
+	// VersionSchema is the name of the Postgres schema through which the new
+	// version of the schema is available, or empty if version schemas are
+	// disabled
+	VersionSchema string
+
+	// Done is true if the migration has been completed, either by Apply or
+	// before it was started again
+	Done bool
+}
+
+// NewRunner creates a Runner that runs migrations with `m`. Closing the
+// Runner closes `m`.
+func NewRunner(m *Roll) *Runner {
+	return &Runner{m: m}
+}
+
+// Roll returns the Roll instance the Runner runs migrations with.
+func (r *Runner) Roll() *Roll {
+	return r.m
+}
+
+// Init initializes pgroll's state, if it hasn't been initialized yet.
+func (r *Runner) Init(ctx context.Context) error {
+	ok, err := r.m.State().IsInitialized(ctx)
+	if err != nil || ok {
+		return err
+	}
+	return r.m.Init(ctx)
+}
+
+// Start starts `migration`, backfilling the columns it changes as configured
+// by `cfg`. Starting a migration that has already been started continues it,
+// and starting a completed migration does nothing. The migration's warnings,
+// such as the lossy operations it contains, can be checked beforehand with
+// migration.Warnings.
+func (r *Runner) Start(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) (*StartResult, error) {
+	if err := r.m.Start(ctx, migration, cfg); err != nil {
+		return nil, err
+	}
+
+	_, done, err := r.m.State().MigrationRun(ctx, r.m.Schema(), migration.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StartResult{Migration: migration.Name, Done: done}
+	if r.m.UseVersionSchema() {
+		result.VersionSchema = VersionedSchemaName(r.m.Schema(), migration.VersionSchemaName())
+	}
+	return result, nil
+}
+
+// Apply starts `migration` as Start does and then completes it.
+func (r *Runner) Apply(ctx context.Context, migration *migrations.Migration, cfg *backfill.Config) (*StartResult, error) {
+	result, err := r.Start(ctx, migration, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if result.Done {
+		return result, nil
+	}
+
+	if err := r.Complete(ctx); err != nil {
+		return nil, err
+	}
+	result.Done = true
+	return result, nil
+}
+
+// Complete completes the active migration.
+func (r *Runner) Complete(ctx context.Context) error {
+	return r.m.Complete(ctx)
+}
+
+// Rollback rolls back the active migration.
+func (r *Runner) Rollback(ctx context.Context) error {
+	return r.m.Rollback(ctx)
+}
+
+// Status returns the status of the latest migration of the schema the Runner
+// runs migrations on.
+func (r *Runner) Status(ctx context.Context) (*Status, error) {
+	return r.m.Status(ctx, r.m.Schema())
+}
+
+// Close closes the Roll instance the Runner runs migrations with.
+func (r *Runner) Close() error {
+	return r.m.Close()
+}
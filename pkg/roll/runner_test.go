@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithConnectionToContainer(t, func(pool *sql.DB, _ string) {
+		ctx := context.Background()
+
+		// Create a runner that takes its connections from the pool
+		st, err := state.NewFromDB(ctx, pool, "pgroll")
+		require.NoError(t, err)
+		m, err := roll.NewFromDB(ctx, pool, "public", st)
+		require.NoError(t, err)
+		runner := roll.NewRunner(m)
+		require.NoError(t, runner.Init(ctx))
+
+		// Create a table and fill it with some rows
+		result, err := runner.Apply(ctx, &migrations.Migration{
+			Name:       "01_create_table",
+			Operations: migrations.Operations{createTableOp("users")},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		assert.Equal(t, &roll.StartResult{
+			Migration:     "01_create_table",
+			VersionSchema: "public_01_create_table",
+			Done:          true,
+		}, result)
+
+		_, err = pool.ExecContext(ctx, "INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')")
+		require.NoError(t, err)
+
+		// Start a migration that backfills a new column
+		result, err = runner.Start(ctx, &migrations.Migration{
+			Name: "02_add_column",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: "users",
+					Up:    "length(name)",
+					Column: migrations.Column{
+						Name:     "name_length",
+						Type:     "integer",
+						Nullable: true,
+					},
+				},
+			},
+		}, backfill.NewConfig())
+		require.NoError(t, err)
+		assert.Equal(t, &roll.StartResult{
+			Migration:     "02_add_column",
+			VersionSchema: "public_02_add_column",
+		}, result)
+
+		status, err := runner.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, roll.InProgressMigrationStatus, status.Status)
+
+		// The new version of the schema sees the backfilled column
+		var lengths []int
+		rows, err := pool.QueryContext(ctx, "SELECT name_length FROM public_02_add_column.users ORDER BY id")
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var length int
+			require.NoError(t, rows.Scan(&length))
+			lengths = append(lengths, length)
+		}
+		require.NoError(t, rows.Err())
+		assert.Equal(t, []int{5, 3}, lengths)
+
+		// Complete the migration
+		require.NoError(t, runner.Complete(ctx))
+
+		status, err = runner.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "02_add_column", status.Version)
+		assert.Equal(t, roll.CompleteMigrationStatus, status.Status)
+
+		require.NoError(t, runner.Close())
+
+		// The schema changes made by the runner weren't recorded as inferred
+		// migrations, and the pool is left open
+		var inferred int
+		err = pool.QueryRowContext(ctx, "SELECT count(*) FROM pgroll.migrations WHERE migration_type = 'inferred'").Scan(&inferred)
+		require.NoError(t, err)
+		assert.Zero(t, inferred)
+	})
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/schema"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+// schemaCache caches the schema introspected from the database for the
+// duration of a single Start or Complete invocation. Reading the schema is
+// expensive on databases with many tables, and most operations don't need
+// it to be re-read. The cache must be invalidated whenever statements that
+// may change the schema have been executed.
+type schemaCache struct {
+	state  *state.State
+	schema string
+
+	// raw is the JSON encoding of the cached schema, or nil if the cache is
+	// empty. Each read decodes a fresh copy, as callers modify the schema
+	// they are given.
+	raw []byte
+}
+
+func newSchemaCache(st *state.State, schemaName string) *schemaCache {
+	return &schemaCache{
+		state:  st,
+		schema: schemaName,
+	}
+}
+
+// read returns a copy of the cached schema, reading it from the database if
+// the cache is empty.
+func (c *schemaCache) read(ctx context.Context) (*schema.Schema, error) {
+	if c.raw == nil {
+		s, err := c.state.ReadSchema(ctx, c.schema)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to cache schema: %w", err)
+		}
+		c.raw = raw
+		return s, nil
+	}
+
+	var s schema.Schema
+	if err := json.Unmarshal(c.raw, &s); err != nil {
+		return nil, fmt.Errorf("unable to read cached schema: %w", err)
+	}
+	return &s, nil
+}
+
+// invalidate empties the cache so that the next read queries the database.
+func (c *schemaCache) invalidate() {
+	c.raw = nil
+}
+
+// readSchema reads the schema, using the schema cache of the current Start or
+// Complete invocation, if there is one.
+func (m *Roll) readSchema(ctx context.Context) (*schema.Schema, error) {
+	if m.schemaCache != nil {
+		return m.schemaCache.read(ctx)
+	}
+	return m.state.ReadSchema(ctx, m.schema)
+}
+
+// readSchemaWith reads the schema as seen by conn. Inside a per-table
+// transaction, the schema is read over the transaction, as neither the state
+// connection nor the schema cache see the changes it has made so far.
+func (m *Roll) readSchemaWith(ctx context.Context, conn db.DB) (*schema.Schema, error) {
+	if _, ok := conn.(*txConn); ok {
+		return m.state.ReadSchemaWith(ctx, conn, m.schema)
+	}
+	return m.readSchema(ctx)
+}
+
+// invalidateSchema invalidates the schema cache of the current Start or
+// Complete invocation, if there is one.
+func (m *Roll) invalidateSchema() {
+	if m.schemaCache != nil {
+		m.schemaCache.invalidate()
+	}
+}
+
+// withSchemaCache enables schema caching until the returned function is
+// called. Nested calls share the outermost cache.
+func (m *Roll) withSchemaCache() func() {
+	if m.schemaCache != nil {
+		return func() {}
+	}
+	m.schemaCache = newSchemaCache(m.state, m.schema)
+	return func() { m.schemaCache = nil }
+}
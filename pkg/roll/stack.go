@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/schema"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+// activeMigrations returns the active migrations, oldest first, or
+// state.ErrNoActiveMigration if there are none.
+func (m *Roll) activeMigrations(ctx context.Context) ([]*migrations.Migration, error) {
+	active, err := m.state.ActiveMigrations(ctx, m.schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(active) == 0 {
+		return nil, state.ErrNoActiveMigration
+	}
+	return active, nil
+}
+
+// withMigrationNaming returns a context in which operations name the objects
+// they create for pgroll's own use as they were named when the migration was
+// started, whatever the naming of this Roll instance.
+func (m *Roll) withMigrationNaming(ctx context.Context, migration *migrations.Migration) (context.Context, error) {
+	naming, err := m.state.Naming(ctx, m.schema, migration.Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read naming of migration %q: %w", migration.Name, err)
+	}
+	return migrations.WithNaming(ctx, naming), nil
+}
+
+// checkStacking returns the active migrations on top of which the migration
+// is to be started. Without migration stacking, no migration may be active. With
+// it, the migration must not touch any object touched by an active migration,
+// the active migrations must have been started with the same internal prefix,
+// and they must have finished their backfills without replacing their triggers
+// with write guards.
+func (m *Roll) checkStacking(ctx context.Context, migration *migrations.Migration) ([]*migrations.Migration, error) {
+	active, err := m.state.ActiveMigrations(ctx, m.schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(active) == 0 {
+		return nil, nil
+	}
+	if !m.migrationStacking {
+		return nil, fmt.Errorf("a migration for schema %q is already in progress", m.schema)
+	}
+
+	for _, a := range active {
+		if err := migration.StackingConflict(a); err != nil {
+			return nil, err
+		}
+
+		naming, err := m.state.Naming(ctx, m.schema, a.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read naming of migration %q: %w", a.Name, err)
+		}
+		if naming.Prefix() != m.naming.Prefix() {
+			return nil, fmt.Errorf("active migration %q was started with internal prefix %q; migrations can only be stacked on migrations started with the same prefix", a.Name, naming.Prefix())
+		}
+
+		unfinished, err := m.state.HasUnfinishedBackfill(ctx, m.schema, a.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for an unfinished backfill: %w", err)
+		}
+		if unfinished {
+			return nil, fmt.Errorf("the backfill of active migration %q has not finished; start it again to resume the backfill before stacking another migration on it", a.Name)
+		}
+	}
+
+	// any write guards belong to the last of the active migrations
+	guardCtx, err := m.withMigrationNaming(ctx, active[len(active)-1])
+	if err != nil {
+		return nil, err
+	}
+	guards, err := m.writeGuards(guardCtx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read write guards: %w", err)
+	}
+	if len(guards) > 0 {
+		return nil, fmt.Errorf("a migration for schema %q backfilled tables without triggers; complete it before stacking another migration on it", m.schema)
+	}
+
+	return active, nil
+}
+
+// previousVersion returns the name of the version schema that is dropped when
+// the oldest active migration is completed. When other migrations are stacked
+// on it, this is the version before the migration rather than the version
+// before the latest migration.
+func (m *Roll) previousVersion(ctx context.Context, active []*migrations.Migration) (*string, error) {
+	if len(active) > 1 {
+		return m.state.VersionBefore(ctx, m.schema, active[0].Name)
+	}
+	return m.state.PreviousVersion(ctx, m.schema)
+}
+
+// virtualSchema returns the schema as seen by the version of the last of the
+// active migrations, by replaying the migrations in order on the schema
+// recorded before the first of them.
+func (m *Roll) virtualSchema(ctx context.Context, active []*migrations.Migration) (*schema.Schema, error) {
+	s, err := m.state.SchemaBeforeMigration(ctx, m.schema, active[0].Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	// update the in-memory schema with the results of starting the migrations
+	for _, migration := range active {
+		ctx, err := m.withMigrationNaming(ctx, migration)
+		if err != nil {
+			return nil, err
+		}
+		if err := migration.UpdateVirtualSchema(ctx, s); err != nil {
+			return nil, fmt.Errorf("unable to replay changes to in-memory schema: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// stackTriggers replaces the trigger functions of the active migrations so
+// that writes made through the version schema of a migration stacked on top of
+// them are treated like writes made through their own version schema. Without
+// this, a write through the stacked version to a column being backfilled by an
+// earlier migration would be overwritten by the column's up SQL.
+func (m *Roll) stackTriggers(ctx context.Context, active []*migrations.Migration, versionSchema string) error {
+	physical, err := m.readSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	for i, migration := range active {
+		ctx, err := m.withMigrationNaming(ctx, migration)
+		if err != nil {
+			return err
+		}
+		naming := migrations.NamingFrom(ctx)
+
+		job, err := m.backfillJob(ctx, migration)
+		if err != nil {
+			return err
+		}
+
+		stacked := make([]string, 0, len(active)-i)
+		for _, later := range active[i+1:] {
+			stacked = append(stacked, VersionedSchemaName(m.schema, later.VersionSchemaName()))
+		}
+		job.SetStackedSchemas(append(stacked, versionSchema)...)
+
+		cfg := backfill.NewConfig(
+			backfill.WithNaming(naming),
+			backfill.WithNeedsBackfillColumn(needsBackfillColumn(physical, job, naming)))
+		if err := backfill.New(m.pgConn, cfg).ReplaceTriggerFunctions(ctx, job); err != nil {
+			return fmt.Errorf("unable to stack triggers of migration %q: %w", migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// needsBackfillColumn returns the name of the needs backfill column of the
+// tables backfilled by the job, as named by the naming of the job's
+// migration. All tables backfilled by a migration use the same name.
+func needsBackfillColumn(physical *schema.Schema, job *backfill.Job, naming backfill.Naming) string {
+	for _, t := range job.Tables {
+		table := physical.GetTable(t.Name)
+		if table == nil {
+			continue
+		}
+		for _, column := range table.Columns {
+			if naming.IsNeedsBackfillColumn(column) {
+				return column.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+func TestMigrationStacking(t *testing.T) {
+	t.Parallel()
+
+	createTables := func() *migrations.Migration {
+		return &migrations.Migration{
+			Name: "01_create_tables",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name: "users",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "serial", Pk: true},
+						{Name: "name", Type: "text"},
+					},
+				},
+				&migrations.OpCreateTable{
+					Name: "products",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "serial", Pk: true},
+						{Name: "name", Type: "text"},
+					},
+				},
+			},
+		}
+	}
+	addColumn := func(name, table, column string) *migrations.Migration {
+		return &migrations.Migration{
+			Name: name,
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table: table,
+					Up:    "upper(name)",
+					Column: migrations.Column{
+						Name:     column,
+						Type:     "text",
+						Nullable: true,
+					},
+				},
+			},
+		}
+	}
+
+	setup := func(t *testing.T, mig *roll.Roll, db *sql.DB) {
+		t.Helper()
+		ctx := context.Background()
+
+		require.NoError(t, mig.Start(ctx, createTables(), backfill.NewConfig()))
+		require.NoError(t, mig.Complete(ctx))
+
+		_, err := db.ExecContext(ctx, "INSERT INTO users (name) VALUES ('alice'), ('bob')")
+		require.NoError(t, err)
+	}
+
+	t.Run("a migration on other tables can be stacked on an active migration", func(t *testing.T) {
+		opts := []roll.Option{roll.WithMigrationStacking(true)}
+		testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			require.NoError(t, mig.Start(ctx, addColumn("02_users_upper", "users", "name_upper"), backfill.NewConfig()))
+			require.NoError(t, mig.Start(ctx, addColumn("03_products_upper", "products", "name_upper"), backfill.NewConfig()))
+
+			// Both migrations are active, oldest first
+			active, err := mig.State().ActiveMigrations(ctx, mig.Schema())
+			require.NoError(t, err)
+			require.Len(t, active, 2)
+			assert.Equal(t, "02_users_upper", active[0].Name)
+			assert.Equal(t, "03_products_upper", active[1].Name)
+
+			// The stacked version sees the columns added by both migrations
+			conn, err := db.Conn(ctx)
+			require.NoError(t, err)
+			_, err = conn.ExecContext(ctx, "SET search_path = public_03_products_upper")
+			require.NoError(t, err)
+			_, err = conn.ExecContext(ctx, "INSERT INTO users (name, name_upper) VALUES ('carol', 'Carol')")
+			require.NoError(t, err)
+
+			// A write through the stacked version is not overwritten by the up
+			// SQL of the migration it is stacked on
+			var nameUpper string
+			err = conn.QueryRowContext(ctx, "SELECT name_upper FROM users WHERE name = 'carol'").Scan(&nameUpper)
+			require.NoError(t, err)
+			assert.Equal(t, "Carol", nameUpper)
+			require.NoError(t, conn.Close())
+
+			// The migrations are completed oldest first
+			require.NoError(t, mig.Complete(ctx))
+			require.NoError(t, mig.Complete(ctx))
+
+			_, err = mig.State().GetActiveMigration(ctx, mig.Schema())
+			assert.ErrorIs(t, err, state.ErrNoActiveMigration)
+
+			rows, err := db.QueryContext(ctx, "SELECT name_upper FROM users ORDER BY id")
+			require.NoError(t, err)
+			defer rows.Close()
+			var got []string
+			for rows.Next() {
+				var v string
+				require.NoError(t, rows.Scan(&v))
+				got = append(got, v)
+			}
+			require.NoError(t, rows.Err())
+			assert.Equal(t, []string{"ALICE", "BOB", "Carol"}, got)
+		})
+	})
+
+	t.Run("a migration touching the same column as an active migration is rejected", func(t *testing.T) {
+		opts := []roll.Option{roll.WithMigrationStacking(true)}
+		testutils.WithMigratorInSchemaAndConnectionToContainerWithOptions(t, "public", opts, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			require.NoError(t, mig.Start(ctx, addColumn("02_users_upper", "users", "name_upper"), backfill.NewConfig()))
+
+			err := mig.Start(ctx, &migrations.Migration{
+				Name: "03_drop_users_upper",
+				Operations: migrations.Operations{
+					&migrations.OpDropColumn{Table: "users", Column: "name_upper"},
+				},
+			}, backfill.NewConfig())
+
+			var conflict migrations.MigrationConflictError
+			require.ErrorAs(t, err, &conflict)
+			assert.Equal(t, "02_users_upper", conflict.Migration)
+			assert.Equal(t, `column "name_upper" of table "users"`, conflict.Object)
+		})
+	})
+
+	t.Run("without migration stacking only one migration can be active", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			setup(t, mig, db)
+
+			require.NoError(t, mig.Start(ctx, addColumn("02_users_upper", "users", "name_upper"), backfill.NewConfig()))
+
+			err := mig.Start(ctx, addColumn("03_products_upper", "products", "name_upper"), backfill.NewConfig())
+			assert.ErrorContains(t, err, "is already in progress")
+		})
+	})
+}
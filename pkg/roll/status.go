@@ -2,7 +2,17 @@
 
 package roll
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/state"
+)
 
 type MigrationStatus string
 
@@ -10,6 +20,7 @@ const (
 	NoneMigrationStatus       MigrationStatus = "No migrations"
 	InProgressMigrationStatus MigrationStatus = "In progress"
 	CompleteMigrationStatus   MigrationStatus = "Complete"
+	RolledBackMigrationStatus MigrationStatus = "Rolled back"
 )
 
 // Status describes the current migration status of a database schema.
@@ -22,6 +33,15 @@ type Status struct {
 
 	// The status of the most recent migration.
 	Status MigrationStatus `json:"status"`
+
+	// The hash of the logical schema after the latest completed migration, as
+	// computed by schema.Hash. Databases with the same hash have the same
+	// logical schema.
+	SchemaHash string `json:"schemaHash,omitempty"`
+
+	// The check constraints and foreign keys that were added NOT VALID and
+	// remain to be validated, as "table.constraint".
+	NotValidConstraints []string `json:"notValidConstraints,omitempty"`
 }
 
 // Status returns the current migration status of the specified schema
@@ -48,9 +68,96 @@ func (m *Roll) Status(ctx context.Context, schema string) (*Status, error) {
 		status = CompleteMigrationStatus
 	}
 
+	schemaHash, err := m.State().LatestSchemaHash(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := m.State().ReadSchema(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var notValid []string
+	for _, name := range slices.Sorted(maps.Keys(sc.Tables)) {
+		for _, constraint := range sc.Tables[name].NotValidConstraints() {
+			notValid = append(notValid, name+"."+constraint)
+		}
+	}
+
 	return &Status{
-		Schema:  schema,
-		Version: *latestVersion,
-		Status:  status,
+		Schema:              schema,
+		Version:             *latestVersion,
+		Status:              status,
+		SchemaHash:          schemaHash,
+		NotValidConstraints: notValid,
 	}, nil
 }
+
+// MigrationProgress describes how far a migration has got, as recorded in the
+// pgroll state.
+type MigrationProgress struct {
+	// The migration name.
+	Migration string `json:"migration"`
+
+	// The status of the migration: in progress, complete or rolled back. A
+	// migration that fails to start is rolled back.
+	Status MigrationStatus `json:"status"`
+
+	// The names of the migration's operations, eg. "add_column".
+	Operations []string `json:"operations,omitempty"`
+
+	// The time the migration was started.
+	StartedAt time.Time `json:"startedAt"`
+
+	// The progress of the backfill of each table of the migration. It is empty
+	// until the backfill starts.
+	Backfill []state.TableBackfill `json:"backfill,omitempty"`
+}
+
+// Terminal returns true if the migration has been completed or rolled back.
+func (p *MigrationProgress) Terminal() bool {
+	return p.Status != InProgressMigrationStatus
+}
+
+// MigrationProgress returns the progress of the named migration in the
+// specified schema. A migration that is no longer recorded in the state is
+// reported as rolled back.
+func (m *Roll) MigrationProgress(ctx context.Context, schema, name string) (*MigrationProgress, error) {
+	startedAt, done, err := m.State().MigrationRun(ctx, schema, name)
+	if errors.Is(err, state.ErrMigrationNotFound) {
+		return &MigrationProgress{Migration: name, Status: RolledBackMigrationStatus}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read migration %q: %w", name, err)
+	}
+
+	progress := &MigrationProgress{
+		Migration: name,
+		Status:    InProgressMigrationStatus,
+		StartedAt: startedAt,
+	}
+	if done {
+		progress.Status = CompleteMigrationStatus
+		return progress, nil
+	}
+
+	raw, err := m.State().Migration(ctx, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read migration %q: %w", name, err)
+	}
+	migration, err := migrations.ParseMigration(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse migration %q: %w", name, err)
+	}
+	for _, op := range migration.Operations {
+		progress.Operations = append(progress.Operations, string(migrations.OperationName(op)))
+	}
+
+	progress.Backfill, err = m.State().BackfillTables(ctx, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read backfill progress of migration %q: %w", name, err)
+	}
+
+	return progress, nil
+}
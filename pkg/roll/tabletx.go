@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/db"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// activeSQLTransactionErrorCode is returned for statements, such as CREATE
+// INDEX CONCURRENTLY, that can't run inside a transaction block.
+const activeSQLTransactionErrorCode pq.ErrorCode = "25001"
+
+// errOutsideTransaction is returned inside a per-table transaction for an
+// operation that has to run outside of any transaction block.
+var errOutsideTransaction = errors.New("operation can't run inside a transaction block")
+
+// operationRunner runs the operation at index i of a migration over conn.
+type operationRunner func(ctx context.Context, conn db.DB, i int) error
+
+// checkpointer saves the in-memory state changed by an operationRunner and
+// returns a function that restores it, so that the operations of a per-table
+// transaction that is rolled back can be run again.
+type checkpointer func() (restore func() error, err error)
+
+// runOperations runs each operation of the migration in order. With per-table
+// transactions, the operations are run in groups that touch disjoint tables,
+// each group in a transaction of its own, so that the locks taken by a group
+// are released when it commits rather than at the end of the migration.
+// Operations that can't run inside a transaction block, such as raw SQL and
+// concurrent index builds, are run on their own outside of any transaction.
+// Non-transactional migrations always run each statement on its own.
+func (m *Roll) runOperations(ctx context.Context, migration *migrations.Migration, run operationRunner, checkpoint checkpointer) error {
+	if !m.perTableTransactions || !migration.IsTransactional() {
+		for i := range migration.Operations {
+			if err := run(ctx, m.pgConn, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, group := range migration.TableGroups() {
+		if err := m.runGroup(ctx, migration, group, run, checkpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runGroup runs the operations at the given indexes in one transaction. When
+// an operation can't run inside a transaction block, the operations before it
+// are run in a transaction, the operation on its own and the operations after
+// it in another transaction.
+func (m *Roll) runGroup(ctx context.Context, migration *migrations.Migration, group []int, run operationRunner, checkpoint checkpointer) error {
+	if len(group) == 0 {
+		return nil
+	}
+
+	restore, err := checkpoint()
+	if err != nil {
+		return err
+	}
+
+	outside := -1
+	err = m.pgConn.WithRetryableTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		// a retried transaction starts over from the checkpoint
+		if err := restore(); err != nil {
+			return err
+		}
+
+		conn := &txConn{tx: tx}
+		for j, i := range group {
+			if _, ok := migration.Operations[i].(*migrations.OpRawSQL); ok {
+				outside = j
+				return errOutsideTransaction
+			}
+			if err := run(ctx, conn, i); err != nil {
+				if isActiveSQLTransactionError(err) {
+					outside = j
+					return errOutsideTransaction
+				}
+				return err
+			}
+		}
+		return nil
+	})
+	m.invalidateSchema()
+	if !errors.Is(err, errOutsideTransaction) {
+		return err
+	}
+
+	if err := restore(); err != nil {
+		return err
+	}
+	if err := m.runGroup(ctx, migration, group[:outside], run, checkpoint); err != nil {
+		return err
+	}
+	if err := run(ctx, m.pgConn, group[outside]); err != nil {
+		return err
+	}
+	m.invalidateSchema()
+	return m.runGroup(ctx, migration, group[outside+1:], run, checkpoint)
+}
+
+// isActiveSQLTransactionError reports whether err is the error returned for a
+// statement that can't run inside a transaction block.
+func isActiveSQLTransactionError(err error) bool {
+	pqErr := &pq.Error{}
+	return errors.As(err, &pqErr) && pqErr.Code == activeSQLTransactionErrorCode
+}
+
+// schemaCheckpoint returns a checkpointer for the in-memory schema `s`, whose
+// restore function resets `s` to the schema at the time of the checkpoint.
+func schemaCheckpoint(s *schema.Schema) checkpointer {
+	return func() (func() error, error) {
+		saved, err := cloneSchema(s)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			c, err := cloneSchema(saved)
+			if err != nil {
+				return err
+			}
+			*s = *c
+			return nil
+		}, nil
+	}
+}
+
+// cloneSchema returns a deep copy of the schema, including the tables and
+// columns that are deleted in the virtual schema.
+func cloneSchema(s *schema.Schema) (*schema.Schema, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var c schema.Schema
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+
+	// deleted tables and columns are not serialized as deleted
+	for name, table := range s.Tables {
+		ct := c.Tables[name]
+		ct.Deleted = table.Deleted
+		for column, col := range table.Columns {
+			ct.Columns[column].Deleted = col.Deleted
+		}
+	}
+	return &c, nil
+}
+
+// txConn runs the statements of operations inside a per-table transaction.
+// Statements are not retried on their own, as a failed statement aborts the
+// transaction; the transaction is retried as a whole instead.
+type txConn struct {
+	tx *sql.Tx
+}
+
+func (c *txConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.tx.ExecContext(ctx, query, args...)
+}
+
+func (c *txConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.tx.QueryContext(ctx, query, args...)
+}
+
+// WithRetryableTransaction runs `f` in the per-table transaction.
+func (c *txConn) WithRetryableTransaction(ctx context.Context, f func(context.Context, *sql.Tx) error) error {
+	return f(ctx, c.tx)
+}
+
+// Close is a no-op; the transaction is committed or rolled back by runGroup.
+func (c *txConn) Close() error {
+	return nil
+}
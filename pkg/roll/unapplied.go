@@ -42,7 +42,7 @@ func (m *Roll) UnappliedMigrations(ctx context.Context, dir fs.FS) ([]*migration
 	// Find the index of the first local migration after the baseline
 	filesStartIdx := sort.Search(len(files), func(i int) bool {
 		var migration *migrations.RawMigration
-		migration, err = migrations.ReadRawMigration(dir, files[i])
+		migration, err = m.migrationCache.ReadRawMigration(dir, files[i])
 		if err != nil {
 			return false
 		}
@@ -55,7 +55,7 @@ func (m *Roll) UnappliedMigrations(ctx context.Context, dir fs.FS) ([]*migration
 	// Read all migrations that come after the baseline
 	migsAfterBaseline := make([]*migrations.RawMigration, 0, len(files))
 	for _, file := range files[filesStartIdx:] {
-		migration, err := migrations.ReadRawMigration(dir, file)
+		migration, err := m.migrationCache.ReadRawMigration(dir, file)
 		if err != nil {
 			return nil, fmt.Errorf("reading migration file %q: %w", file, err)
 		}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// ValidateAll checks that the migration can be applied after the latest
+// completed migration and returns every error found in it, rather than
+// stopping at the first one. Errors in an operation are returned as a
+// migrations.OperationError.
+//
+// The migration is validated against the schema recorded in the pgroll state
+// after the latest completed migration, so the database schema itself is only
+// read if no migration has been completed yet. No changes are made to the
+// database.
+func (m *Roll) ValidateAll(ctx context.Context, migration *migrations.Migration) ([]error, error) {
+	s, err := m.state.LatestSchema(ctx, m.schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema after latest migration: %w", err)
+	}
+	if s == nil {
+		s, err = m.state.ReadSchema(ctx, m.schema)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read schema: %w", err)
+		}
+	}
+
+	errs := migration.ValidateAll(migrations.WithNaming(ctx, m.naming), s)
+	for i, op := range migration.Operations {
+		if err := m.validateRoles(ctx, op); err != nil {
+			errs = append(errs, migrations.OperationError{Index: i, Operation: migrations.OperationName(op), Err: err})
+		}
+	}
+
+	return errs, nil
+}
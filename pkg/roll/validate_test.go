@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestValidateAll(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithMigratorAndConnectionToContainer(t, func(roll *roll.Roll, _ *sql.DB) {
+		ctx := context.Background()
+
+		first := &migrations.Migration{
+			Name: "01_create_table",
+			Operations: migrations.Operations{
+				&migrations.OpCreateTable{
+					Name: "users",
+					Columns: []migrations.Column{
+						{Name: "id", Type: "serial", Pk: true},
+					},
+				},
+			},
+		}
+		require.NoError(t, roll.Start(ctx, first, backfill.NewConfig()))
+		require.NoError(t, roll.Complete(ctx))
+
+		second := &migrations.Migration{
+			Name: "02_add_columns",
+			Operations: migrations.Operations{
+				&migrations.OpAddColumn{
+					Table:  "users",
+					Column: migrations.Column{Name: "name", Type: "text", Nullable: true},
+				},
+				&migrations.OpAddColumn{
+					Table:  "orders",
+					Column: migrations.Column{Name: "total", Type: "integer", Nullable: true},
+				},
+				&migrations.OpAddColumn{
+					Table:  "users",
+					Column: migrations.Column{Name: "email", Type: "text; DROP TABLE users", Nullable: true},
+				},
+			},
+		}
+
+		// Both invalid operations are reported, against the schema after the
+		// first migration
+		errs, err := roll.ValidateAll(ctx, second)
+		require.NoError(t, err)
+		require.Len(t, errs, 2)
+
+		var opErr migrations.OperationError
+		require.ErrorAs(t, errs[0], &opErr)
+		require.Equal(t, 1, opErr.Index)
+		require.ErrorIs(t, errs[0], migrations.TableDoesNotExistError{Name: "orders"})
+
+		require.ErrorAs(t, errs[1], &opErr)
+		require.Equal(t, 2, opErr.Index)
+		require.ErrorAs(t, errs[1], &migrations.InvalidTypeNameError{})
+	})
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// ViewDiscrepancyKind is the kind of difference between a view in a version
+// schema and the table it exposes.
+type ViewDiscrepancyKind string
+
+const (
+	MissingViewDiscrepancy      ViewDiscrepancyKind = "missing view"
+	UnexpectedViewDiscrepancy   ViewDiscrepancyKind = "unexpected view"
+	MissingColumnDiscrepancy    ViewDiscrepancyKind = "missing column"
+	UnexpectedColumnDiscrepancy ViewDiscrepancyKind = "unexpected column"
+	InternalColumnDiscrepancy   ViewDiscrepancyKind = "internal column"
+)
+
+// ViewDiscrepancy is a difference between a view in a version schema and the
+// logical schema of the table it exposes.
+type ViewDiscrepancy struct {
+	Kind   ViewDiscrepancyKind `json:"kind"`
+	View   string              `json:"view"`
+	Column string              `json:"column,omitempty"`
+}
+
+func (d ViewDiscrepancy) String() string {
+	if d.Column == "" {
+		return fmt.Sprintf("%s %q", d.Kind, d.View)
+	}
+	return fmt.Sprintf("%s %q in view %q", d.Kind, d.Column, d.View)
+}
+
+// VerifyViews checks that the views in the latest version schema expose
+// exactly the logical columns of the tables in the schema, and no columns
+// that pgroll uses internally. The differences found are returned; an empty
+// result means the views match the schema. Views can't be verified while a
+// migration is active, as the logical schema of its version isn't recorded
+// until it completes.
+func (m *Roll) VerifyViews(ctx context.Context) ([]ViewDiscrepancy, error) {
+	if !m.UseVersionSchema() {
+		return nil, fmt.Errorf("version schemas are disabled")
+	}
+
+	active, err := m.state.IsActiveMigrationPeriod(ctx, m.schema)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, fmt.Errorf("a migration for schema %q is in progress", m.schema)
+	}
+
+	version, err := m.LatestVersionRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := m.readSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	views, err := m.viewColumns(ctx, VersionedSchemaName(m.schema, version))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read views: %w", err)
+	}
+
+	prefixes, err := m.state.InternalPrefixes(ctx, m.schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read internal prefixes: %w", err)
+	}
+
+	return compareViews(sc, views, prefixes), nil
+}
+
+// viewColumns returns the columns of each view in the given schema, in the
+// order in which the view exposes them.
+func (m *Roll) viewColumns(ctx context.Context, schemaName string) (map[string][]string, error) {
+	rows, err := m.pgConn.QueryContext(ctx, `
+		SELECT c.relname, a.attname
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid
+		WHERE n.nspname = $1
+		  AND c.relkind = 'v'
+		  AND a.attnum > 0
+		  AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum`, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := make(map[string][]string)
+	for rows.Next() {
+		var view, column string
+		if err := rows.Scan(&view, &column); err != nil {
+			return nil, err
+		}
+		views[view] = append(views[view], column)
+	}
+	return views, rows.Err()
+}
+
+// compareViews compares the columns of the views in a version schema with
+// the logical schema of the tables they expose. Tables and columns with one of
+// the internal prefixes are created by pgroll for its own use and are never
+// part of a version schema.
+func compareViews(sc *schema.Schema, views map[string][]string, prefixes []string) []ViewDiscrepancy {
+	var discrepancies []ViewDiscrepancy
+
+	for name, table := range sc.Tables {
+		if table.Deleted || hasInternalPrefix(name, prefixes) {
+			continue
+		}
+
+		columns, ok := views[name]
+		if !ok {
+			discrepancies = append(discrepancies, ViewDiscrepancy{Kind: MissingViewDiscrepancy, View: name})
+			continue
+		}
+
+		var expected []string
+		for columnName, column := range table.Columns {
+			if column.Deleted || hasInternalPrefix(columnName, prefixes) {
+				continue
+			}
+			expected = append(expected, columnName)
+			if !slices.Contains(columns, columnName) {
+				discrepancies = append(discrepancies, ViewDiscrepancy{Kind: MissingColumnDiscrepancy, View: name, Column: columnName})
+			}
+		}
+
+		for _, column := range columns {
+			switch {
+			case hasInternalPrefix(column, prefixes):
+				discrepancies = append(discrepancies, ViewDiscrepancy{Kind: InternalColumnDiscrepancy, View: name, Column: column})
+			case !slices.Contains(expected, column):
+				discrepancies = append(discrepancies, ViewDiscrepancy{Kind: UnexpectedColumnDiscrepancy, View: name, Column: column})
+			}
+		}
+	}
+
+	for view := range views {
+		if table, ok := sc.Tables[view]; !ok || table.Deleted || hasInternalPrefix(view, prefixes) {
+			discrepancies = append(discrepancies, ViewDiscrepancy{Kind: UnexpectedViewDiscrepancy, View: view})
+		}
+	}
+
+	slices.SortFunc(discrepancies, func(a, b ViewDiscrepancy) int {
+		if c := strings.Compare(a.View, b.View); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Column, b.Column); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.Kind), string(b.Kind))
+	})
+	return discrepancies
+}
+
+// hasInternalPrefix returns true if the name starts with one of the prefixes.
+func hasInternalPrefix(name string, prefixes []string) bool {
+	return slices.ContainsFunc(prefixes, func(prefix string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package roll_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/roll"
+)
+
+func TestVerifyViews(t *testing.T) {
+	t.Parallel()
+
+	t.Run("views match the schema", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, _ *sql.DB) {
+			ctx := context.Background()
+			startAndComplete(t, mig, "01_create_table", createTableOp("users"))
+			startAndComplete(t, mig, "02_add_column", addColumnOp("users"))
+
+			discrepancies, err := mig.VerifyViews(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, discrepancies)
+		})
+	})
+
+	t.Run("views that differ from the schema are reported", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, db *sql.DB) {
+			ctx := context.Background()
+			startAndComplete(t, mig, "01_create_table", createTableOp("users"))
+			startAndComplete(t, mig, "02_create_table", createTableOp("orders"))
+
+			_, err := db.ExecContext(ctx, `
+				ALTER TABLE users ADD COLUMN _pgroll_leaked boolean;
+				CREATE OR REPLACE VIEW public_02_create_table.users AS SELECT id, _pgroll_leaked FROM users;
+				DROP VIEW public_02_create_table.orders;
+				CREATE VIEW public_02_create_table.customers AS SELECT 1 AS id;
+			`)
+			require.NoError(t, err)
+
+			discrepancies, err := mig.VerifyViews(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []roll.ViewDiscrepancy{
+				{Kind: roll.UnexpectedViewDiscrepancy, View: "customers"},
+				{Kind: roll.MissingViewDiscrepancy, View: "orders"},
+				{Kind: roll.InternalColumnDiscrepancy, View: "users", Column: "_pgroll_leaked"},
+				{Kind: roll.MissingColumnDiscrepancy, View: "users", Column: "name"},
+			}, discrepancies)
+		})
+	})
+
+	t.Run("views can't be verified during an active migration", func(t *testing.T) {
+		testutils.WithMigratorAndConnectionToContainer(t, func(mig *roll.Roll, _ *sql.DB) {
+			ctx := context.Background()
+			startAndComplete(t, mig, "01_create_table", createTableOp("users"))
+
+			err := mig.Start(ctx, &migrations.Migration{
+				Name:       "02_add_column",
+				Operations: migrations.Operations{addColumnOp("users")},
+			}, backfill.NewConfig())
+			require.NoError(t, err)
+
+			_, err = mig.VerifyViews(ctx)
+			require.Error(t, err)
+		})
+	})
+}
+
+func startAndComplete(t *testing.T, mig *roll.Roll, name string, op migrations.Operation) {
+	t.Helper()
+	ctx := context.Background()
+
+	err := mig.Start(ctx, &migrations.Migration{
+		Name:       name,
+		Operations: migrations.Operations{op},
+	}, backfill.NewConfig())
+	require.NoError(t, err)
+
+	err = mig.Complete(ctx)
+	require.NoError(t, err)
+}
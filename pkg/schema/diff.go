@@ -0,0 +1,512 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ChangeKind describes how an object differs between two schemas
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeDropped  ChangeKind = "dropped"
+	ChangeRenamed  ChangeKind = "renamed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Renames records the tables and columns known to have been renamed between
+// two schemas, so that they are reported as renames rather than as a drop and
+// an add.
+type Renames struct {
+	// Tables maps the name of a table in the old schema to its name in the new
+	// schema
+	Tables map[string]string
+
+	// Columns maps the name of a table in the old schema to a map of old column
+	// name -> new column name
+	Columns map[string]map[string]string
+}
+
+// NewRenames returns an empty set of renames
+func NewRenames() *Renames {
+	return &Renames{
+		Tables:  make(map[string]string),
+		Columns: make(map[string]map[string]string),
+	}
+}
+
+// RenameTable records that the table currently named `from` is renamed to
+// `to`. Renames are recorded in the order in which they are applied, so that
+// chains of renames resolve to the first and last names.
+func (r *Renames) RenameTable(from, to string) {
+	original := r.originalTable(from)
+	r.Tables[original] = to
+}
+
+// RenameColumn records that the column currently named `from` in the table
+// currently named `table` is renamed to `to`.
+func (r *Renames) RenameColumn(table, from, to string) {
+	original := r.originalTable(table)
+	columns, ok := r.Columns[original]
+	if !ok {
+		columns = make(map[string]string)
+		r.Columns[original] = columns
+	}
+	for old, current := range columns {
+		if current == from {
+			columns[old] = to
+			return
+		}
+	}
+	columns[from] = to
+}
+
+// Invert returns the renames that turn the new schema back into the old one
+func (r *Renames) Invert() *Renames {
+	inverted := NewRenames()
+	for from, to := range r.Tables {
+		inverted.Tables[to] = from
+	}
+	for table, columns := range r.Columns {
+		newTable := table
+		if to, ok := r.Tables[table]; ok {
+			newTable = to
+		}
+		inverted.Columns[newTable] = make(map[string]string, len(columns))
+		for from, to := range columns {
+			inverted.Columns[newTable][to] = from
+		}
+	}
+	return inverted
+}
+
+// originalTable returns the name in the old schema of the table currently
+// named `name`
+func (r *Renames) originalTable(name string) string {
+	for original, current := range r.Tables {
+		if current == name {
+			return original
+		}
+	}
+	return name
+}
+
+// SchemaDiff is the set of differences between two schemas
+type SchemaDiff struct {
+	Tables []TableDiff `json:"tables"`
+}
+
+// TableDiff describes how a table differs between two schemas
+type TableDiff struct {
+	// Name is the name of the table in the new schema, or in the old schema if
+	// the table was dropped
+	Name string `json:"name"`
+
+	// From is the name of the table in the old schema if it was renamed
+	From string `json:"from,omitempty"`
+
+	Change          ChangeKind   `json:"change"`
+	Columns         []ColumnDiff `json:"columns,omitempty"`
+	Indexes         []ObjectDiff `json:"indexes,omitempty"`
+	Constraints     []ObjectDiff `json:"constraints,omitempty"`
+	ReplicaIdentity *ValueChange `json:"replicaIdentity,omitempty"`
+}
+
+// ColumnDiff describes how a column differs between two schemas
+type ColumnDiff struct {
+	// Name is the name of the column in the new schema, or in the old schema if
+	// the column was dropped
+	Name string `json:"name"`
+
+	// From is the name of the column in the old schema if it was renamed
+	From string `json:"from,omitempty"`
+
+	Change   ChangeKind   `json:"change"`
+	Type     *ValueChange `json:"type,omitempty"`
+	Nullable *ValueChange `json:"nullable,omitempty"`
+	Default  *ValueChange `json:"default,omitempty"`
+}
+
+// ObjectDiff describes how an index or constraint differs between two schemas
+type ObjectDiff struct {
+	Name string `json:"name"`
+
+	// Type is the type of a constraint: primary_key, foreign_key, check, unique
+	// or exclude. It is empty for indexes.
+	Type string `json:"type,omitempty"`
+
+	Change ChangeKind `json:"change"`
+
+	// Definition is the definition of the object in the new schema, or in the
+	// old schema if it was dropped
+	Definition string `json:"definition,omitempty"`
+}
+
+// ValueChange is the old and new value of an attribute of a table or column
+type ValueChange struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// IsEmpty returns true if there are no differences
+func (d *SchemaDiff) IsEmpty() bool {
+	return len(d.Tables) == 0
+}
+
+// IsEmpty returns true if the attributes, columns, indexes and constraints of
+// the table don't differ
+func (d *TableDiff) IsEmpty() bool {
+	return len(d.Columns) == 0 &&
+		len(d.Indexes) == 0 &&
+		len(d.Constraints) == 0 &&
+		d.ReplicaIdentity == nil
+}
+
+// Diff returns the differences between the `from` and `to` schemas. Tables are
+// matched by name, then by the renames in `renames`, then by OID; columns are
+// matched by name and by the renames in `renames`. `renames` may be nil.
+func Diff(from, to *Schema, renames *Renames) *SchemaDiff {
+	if renames == nil {
+		renames = NewRenames()
+	}
+
+	fromTables := liveTables(from)
+	toTables := liveTables(to)
+
+	diff := &SchemaDiff{Tables: []TableDiff{}}
+	matched := make(map[string]bool, len(toTables))
+
+	for _, name := range slices.Sorted(maps.Keys(fromTables)) {
+		target := matchTable(name, fromTables[name], toTables, matched, renames)
+		if target == "" {
+			diff.Tables = append(diff.Tables, TableDiff{Name: name, Change: ChangeDropped})
+			continue
+		}
+		matched[target] = true
+
+		td := diffTable(fromTables[name], toTables[target], renames.Columns[name])
+		td.Name = target
+		if target != name {
+			td.From = name
+			td.Change = ChangeRenamed
+		} else if !td.IsEmpty() {
+			td.Change = ChangeModified
+		} else {
+			continue
+		}
+		diff.Tables = append(diff.Tables, td)
+	}
+
+	for name := range toTables {
+		if !matched[name] {
+			diff.Tables = append(diff.Tables, TableDiff{Name: name, Change: ChangeAdded})
+		}
+	}
+
+	slices.SortStableFunc(diff.Tables, func(a, b TableDiff) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return diff
+}
+
+// liveTables returns the tables of the schema that are not marked as deleted
+func liveTables(s *Schema) map[string]*Table {
+	tables := make(map[string]*Table)
+	if s == nil {
+		return tables
+	}
+	for name, t := range s.Tables {
+		if !t.Deleted {
+			tables[name] = t
+		}
+	}
+	return tables
+}
+
+// matchTable returns the name of the table in `to` that corresponds to the
+// table `name` in the old schema, or an empty string if it was dropped
+func matchTable(name string, t *Table, to map[string]*Table, matched map[string]bool, renames *Renames) string {
+	if newName, ok := renames.Tables[name]; ok && to[newName] != nil && !matched[newName] {
+		return newName
+	}
+	if to[name] != nil && !matched[name] {
+		return name
+	}
+	if t.OID == "" {
+		return ""
+	}
+	for _, newName := range slices.Sorted(maps.Keys(to)) {
+		if to[newName].OID == t.OID && !matched[newName] {
+			return newName
+		}
+	}
+	return ""
+}
+
+// diffTable returns the differences between the replica identity, columns,
+// indexes and constraints of two versions of a table
+func diffTable(from, to *Table, columnRenames map[string]string) TableDiff {
+	td := TableDiff{}
+
+	if fromIdentity, toIdentity := replicaIdentityOf(from), replicaIdentityOf(to); fromIdentity != toIdentity {
+		td.ReplicaIdentity = &ValueChange{From: fromIdentity, To: toIdentity}
+	}
+
+	fromColumns := liveColumns(from)
+	toColumns := liveColumns(to)
+
+	// Map old column names to new ones so that indexes and constraints on
+	// renamed columns aren't reported as modified
+	columnNames := make(map[string]string)
+	matched := make(map[string]bool, len(toColumns))
+
+	for _, name := range slices.Sorted(maps.Keys(fromColumns)) {
+		target := ""
+		if newName, ok := columnRenames[name]; ok && toColumns[newName] != nil && !matched[newName] {
+			target = newName
+		} else if toColumns[name] != nil && !matched[name] {
+			target = name
+		}
+
+		old := fromColumns[name]
+		if target == "" {
+			td.Columns = append(td.Columns, ColumnDiff{
+				Name:   name,
+				Change: ChangeDropped,
+				Type:   &ValueChange{From: old.Type},
+			})
+			continue
+		}
+		matched[target] = true
+		columnNames[name] = target
+
+		cd := diffColumn(old, toColumns[target])
+		cd.Name = target
+		if target != name {
+			cd.From = name
+			cd.Change = ChangeRenamed
+		} else if cd.Type != nil || cd.Nullable != nil || cd.Default != nil {
+			cd.Change = ChangeModified
+		} else {
+			continue
+		}
+		td.Columns = append(td.Columns, cd)
+	}
+
+	for name, c := range toColumns {
+		if !matched[name] {
+			td.Columns = append(td.Columns, ColumnDiff{
+				Name:   name,
+				Change: ChangeAdded,
+				Type:   &ValueChange{To: c.Type},
+			})
+		}
+	}
+	slices.SortStableFunc(td.Columns, func(a, b ColumnDiff) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	td.Indexes = diffObjects(indexObjects(from), indexObjects(to), columnNames)
+	td.Constraints = diffObjects(constraintObjects(from), constraintObjects(to), columnNames)
+
+	return td
+}
+
+// replicaIdentityOf returns the replica identity of the table as it is written
+// in ALTER TABLE ... REPLICA IDENTITY. Tables whose replica identity isn't
+// known have the default one.
+func replicaIdentityOf(t *Table) string {
+	if t.ReplicaIdentity == nil {
+		return "DEFAULT"
+	}
+	if t.ReplicaIdentity.Type == "INDEX" {
+		return "USING INDEX " + t.ReplicaIdentity.Index
+	}
+	return t.ReplicaIdentity.Type
+}
+
+// liveColumns returns the columns of the table that are not marked as deleted
+func liveColumns(t *Table) map[string]*Column {
+	columns := make(map[string]*Column)
+	for name, c := range t.Columns {
+		if !c.Deleted {
+			columns[name] = c
+		}
+	}
+	return columns
+}
+
+// diffColumn returns the differences between the attributes of two versions
+// of a column
+func diffColumn(from, to *Column) ColumnDiff {
+	cd := ColumnDiff{}
+	if from.Type != to.Type {
+		cd.Type = &ValueChange{From: from.Type, To: to.Type}
+	}
+	if from.Nullable != to.Nullable {
+		cd.Nullable = &ValueChange{From: nullability(from.Nullable), To: nullability(to.Nullable)}
+	}
+	if fromDefault, toDefault := defaultOf(from), defaultOf(to); fromDefault != toDefault {
+		cd.Default = &ValueChange{From: fromDefault, To: toDefault}
+	}
+	return cd
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+func defaultOf(c *Column) string {
+	if c.Default == nil {
+		return ""
+	}
+	return *c.Default
+}
+
+// object is an index or constraint in a form that can be compared across
+// schemas
+type object struct {
+	typ        string
+	definition string
+	columns    []string
+	// fingerprint holds the attributes other than the key columns that
+	// identify the object
+	fingerprint string
+	// compareDefinition is set for objects whose definition isn't fully
+	// described by the columns and fingerprint
+	compareDefinition bool
+}
+
+func indexObjects(t *Table) map[string]object {
+	objects := make(map[string]object, len(t.Indexes))
+	for name, idx := range t.Indexes {
+		predicate := ""
+		if idx.Predicate != nil {
+			predicate = *idx.Predicate
+		}
+		objects[name] = object{
+			definition:  idx.Definition,
+			columns:     idx.Columns,
+			fingerprint: fmt.Sprintf("%t|%s|%s|%v", idx.Unique, idx.Method, predicate, idx.Expressions),
+		}
+	}
+	return objects
+}
+
+func constraintObjects(t *Table) map[string]object {
+	objects := make(map[string]object)
+	if len(t.PrimaryKey) > 0 {
+		objects[""] = object{
+			typ:        "primary_key",
+			definition: fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(t.PrimaryKey, ", ")),
+			columns:    t.PrimaryKey,
+		}
+	}
+	for name, fk := range t.ForeignKeys {
+		objects[name] = object{
+			typ:        "foreign_key",
+			definition: fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", ")),
+			columns:    fk.Columns,
+			fingerprint: fmt.Sprintf("%s|%v|%s|%s|%s|%t|%t|%t",
+				fk.ReferencedTable, fk.ReferencedColumns, fk.OnDelete, fk.OnUpdate, fk.MatchType, fk.Deferrable, fk.InitiallyDeferred, fk.NotValid),
+		}
+	}
+	for name, cc := range t.CheckConstraints {
+		objects[name] = object{
+			typ:               "check",
+			definition:        cc.Definition,
+			columns:           cc.Columns,
+			fingerprint:       fmt.Sprintf("%t", cc.NoInherit),
+			compareDefinition: true,
+		}
+	}
+	for name, uc := range t.UniqueConstraints {
+		objects[name] = object{
+			typ:         "unique",
+			definition:  fmt.Sprintf("UNIQUE (%s)", strings.Join(uc.Columns, ", ")),
+			columns:     uc.Columns,
+			fingerprint: fmt.Sprintf("%t|%t", uc.Deferrable, uc.InitiallyDeferred),
+		}
+	}
+	for name, ec := range t.ExcludeConstraints {
+		objects[name] = object{
+			typ:               "exclude",
+			definition:        ec.Definition,
+			columns:           ec.Columns,
+			fingerprint:       fmt.Sprintf("%s|%s", ec.Method, ec.Predicate),
+			compareDefinition: true,
+		}
+	}
+	return objects
+}
+
+// diffObjects returns the indexes or constraints that were added, dropped or
+// modified. Objects are matched by name; `columnNames` maps old column names
+// to new ones.
+func diffObjects(from, to map[string]object, columnNames map[string]string) []ObjectDiff {
+	var diffs []ObjectDiff
+
+	for name, old := range from {
+		nu, ok := to[name]
+		if !ok || nu.typ != old.typ {
+			diffs = append(diffs, ObjectDiff{Name: name, Type: old.typ, Change: ChangeDropped, Definition: old.definition})
+			if ok {
+				diffs = append(diffs, ObjectDiff{Name: name, Type: nu.typ, Change: ChangeAdded, Definition: nu.definition})
+			}
+			continue
+		}
+		if objectModified(old, nu, columnNames) {
+			diffs = append(diffs, ObjectDiff{Name: name, Type: nu.typ, Change: ChangeModified, Definition: nu.definition})
+		}
+	}
+
+	for name, nu := range to {
+		if _, ok := from[name]; !ok {
+			diffs = append(diffs, ObjectDiff{Name: name, Type: nu.typ, Change: ChangeAdded, Definition: nu.definition})
+		}
+	}
+
+	slices.SortStableFunc(diffs, func(a, b ObjectDiff) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.Change), string(b.Change))
+	})
+
+	return diffs
+}
+
+// objectModified returns true if the object changed other than by having its
+// columns renamed
+func objectModified(from, to object, columnNames map[string]string) bool {
+	if from.fingerprint != to.fingerprint || len(from.columns) != len(to.columns) {
+		return true
+	}
+
+	renamed := false
+	for i, c := range from.columns {
+		newName := c
+		if n, ok := columnNames[c]; ok {
+			newName = n
+		}
+		if newName != to.columns[i] {
+			return true
+		}
+		if newName != c {
+			renamed = true
+		}
+	}
+
+	// The definitions of objects on renamed columns mention the new column
+	// names, so they can only be compared if no column was renamed
+	return to.compareDefinition && !renamed && from.definition != to.definition
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		from    *schema.Schema
+		to      *schema.Schema
+		renames *schema.Renames
+		want    []schema.TableDiff
+	}{
+		"identical schemas": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to:   &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			want: []schema.TableDiff{},
+		},
+		"added and dropped tables": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to:   &schema.Schema{Tables: map[string]*schema.Table{"orders": {Name: "orders", OID: "2"}}},
+			want: []schema.TableDiff{
+				{Name: "orders", Change: schema.ChangeAdded},
+				{Name: "users", Change: schema.ChangeDropped},
+			},
+		},
+		"deleted tables in a virtual schema are ignored": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to: &schema.Schema{Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.Deleted = true
+				return t
+			}()}},
+			want: []schema.TableDiff{
+				{Name: "users", Change: schema.ChangeDropped},
+			},
+		},
+		"renamed tables are matched by OID": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to: &schema.Schema{Tables: map[string]*schema.Table{"customers": func() *schema.Table {
+				t := usersTable()
+				t.Name = "customers"
+				return t
+			}()}},
+			want: []schema.TableDiff{
+				{Name: "customers", From: "users", Change: schema.ChangeRenamed},
+			},
+		},
+		"column type, nullability and default changes": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to: &schema.Schema{Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.Columns["name"].Type = "text"
+				t.Columns["name"].Nullable = false
+				t.Columns["name"].Default = ptr("'anonymous'::text")
+				return t
+			}()}},
+			want: []schema.TableDiff{
+				{
+					Name:   "users",
+					Change: schema.ChangeModified,
+					Columns: []schema.ColumnDiff{
+						{
+							Name:     "name",
+							Change:   schema.ChangeModified,
+							Type:     &schema.ValueChange{From: "varchar(255)", To: "text"},
+							Nullable: &schema.ValueChange{From: "NULL", To: "NOT NULL"},
+							Default:  &schema.ValueChange{To: "'anonymous'::text"},
+						},
+					},
+				},
+			},
+		},
+		"renamed columns without metadata are a drop and an add": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to:   &schema.Schema{Tables: map[string]*schema.Table{"users": renamedNameColumn(usersTable())}},
+			want: []schema.TableDiff{
+				{
+					Name:   "users",
+					Change: schema.ChangeModified,
+					Columns: []schema.ColumnDiff{
+						{Name: "full_name", Change: schema.ChangeAdded, Type: &schema.ValueChange{To: "varchar(255)"}},
+						{Name: "name", Change: schema.ChangeDropped, Type: &schema.ValueChange{From: "varchar(255)"}},
+					},
+					Indexes: []schema.ObjectDiff{
+						{Name: "users_name_idx", Change: schema.ChangeModified, Definition: "CREATE INDEX users_name_idx ON users (full_name)"},
+					},
+					Constraints: []schema.ObjectDiff{
+						{Name: "name_length", Type: "check", Change: schema.ChangeModified, Definition: "CHECK (length(full_name) > 0)"},
+					},
+				},
+			},
+		},
+		"renamed columns with metadata are a rename": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to:   &schema.Schema{Tables: map[string]*schema.Table{"users": renamedNameColumn(usersTable())}},
+			renames: func() *schema.Renames {
+				r := schema.NewRenames()
+				r.RenameColumn("users", "name", "full_name")
+				return r
+			}(),
+			want: []schema.TableDiff{
+				{
+					Name:   "users",
+					Change: schema.ChangeModified,
+					Columns: []schema.ColumnDiff{
+						{Name: "full_name", From: "name", Change: schema.ChangeRenamed},
+					},
+				},
+			},
+		},
+		"added, dropped and modified indexes and constraints": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to: &schema.Schema{Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.Indexes["users_name_idx"].Unique = true
+				delete(t.CheckConstraints, "name_length")
+				t.UniqueConstraints = map[string]*schema.UniqueConstraint{
+					"users_name_key": {Name: "users_name_key", Columns: []string{"name"}},
+				}
+				return t
+			}()}},
+			want: []schema.TableDiff{
+				{
+					Name:   "users",
+					Change: schema.ChangeModified,
+					Indexes: []schema.ObjectDiff{
+						{Name: "users_name_idx", Change: schema.ChangeModified, Definition: "CREATE INDEX users_name_idx ON users (name)"},
+					},
+					Constraints: []schema.ObjectDiff{
+						{Name: "name_length", Type: "check", Change: schema.ChangeDropped, Definition: "CHECK (length(name) > 0)"},
+						{Name: "users_name_key", Type: "unique", Change: schema.ChangeAdded, Definition: "UNIQUE (name)"},
+					},
+				},
+			},
+		},
+		"a constraint made deferrable is modified": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.UniqueConstraints = map[string]*schema.UniqueConstraint{
+					"users_name_key": {Name: "users_name_key", Columns: []string{"name"}},
+				}
+				return t
+			}()}},
+			to: &schema.Schema{Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.UniqueConstraints = map[string]*schema.UniqueConstraint{
+					"users_name_key": {Name: "users_name_key", Columns: []string{"name"}, Deferrable: true},
+				}
+				return t
+			}()}},
+			want: []schema.TableDiff{
+				{
+					Name:   "users",
+					Change: schema.ChangeModified,
+					Constraints: []schema.ObjectDiff{
+						{Name: "users_name_key", Type: "unique", Change: schema.ChangeModified, Definition: "UNIQUE (name)"},
+					},
+				},
+			},
+		},
+		"a changed replica identity is modified": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to: &schema.Schema{Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.ReplicaIdentity = &schema.ReplicaIdentity{Type: "INDEX", Index: "users_name_idx"}
+				return t
+			}()}},
+			want: []schema.TableDiff{
+				{
+					Name:            "users",
+					Change:          schema.ChangeModified,
+					ReplicaIdentity: &schema.ValueChange{From: "DEFAULT", To: "USING INDEX users_name_idx"},
+				},
+			},
+		},
+		"a replica identity that isn't known is the default": {
+			from: &schema.Schema{Tables: map[string]*schema.Table{"users": usersTable()}},
+			to: &schema.Schema{Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.ReplicaIdentity = &schema.ReplicaIdentity{Type: "DEFAULT"}
+				return t
+			}()}},
+			want: []schema.TableDiff{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			diff := schema.Diff(tc.from, tc.to, tc.renames)
+			assert.Equal(t, tc.want, diff.Tables)
+		})
+	}
+}
+
+func TestRenames(t *testing.T) {
+	t.Parallel()
+
+	r := schema.NewRenames()
+	r.RenameTable("users", "customers")
+	r.RenameColumn("customers", "name", "full_name")
+	r.RenameColumn("customers", "full_name", "display_name")
+	r.RenameTable("customers", "people")
+
+	// Chains of renames resolve to the first and last names
+	assert.Equal(t, map[string]string{"users": "people"}, r.Tables)
+	assert.Equal(t, map[string]map[string]string{"users": {"name": "display_name"}}, r.Columns)
+
+	inverted := r.Invert()
+	assert.Equal(t, map[string]string{"people": "users"}, inverted.Tables)
+	assert.Equal(t, map[string]map[string]string{"people": {"display_name": "name"}}, inverted.Columns)
+}
+
+func usersTable() *schema.Table {
+	return &schema.Table{
+		OID:  "1",
+		Name: "users",
+		Columns: map[string]*schema.Column{
+			"id":   {Name: "id", Type: "integer"},
+			"name": {Name: "name", Type: "varchar(255)", Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes: map[string]*schema.Index{
+			"users_name_idx": {Name: "users_name_idx", Columns: []string{"name"}, Definition: "CREATE INDEX users_name_idx ON users (name)"},
+		},
+		CheckConstraints: map[string]*schema.CheckConstraint{
+			"name_length": {Name: "name_length", Columns: []string{"name"}, Definition: "CHECK (length(name) > 0)"},
+		},
+	}
+}
+
+func renamedNameColumn(t *schema.Table) *schema.Table {
+	t.Columns["full_name"] = t.Columns["name"]
+	t.Columns["full_name"].Name = "full_name"
+	delete(t.Columns, "name")
+	t.Indexes["users_name_idx"].Columns = []string{"full_name"}
+	t.Indexes["users_name_idx"].Definition = "CREATE INDEX users_name_idx ON users (full_name)"
+	t.CheckConstraints["name_length"].Columns = []string{"full_name"}
+	t.CheckConstraints["name_length"].Definition = "CHECK (length(full_name) > 0)"
+	return t
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Hash returns a hash of the logical schema, as a hex-encoded SHA-256 digest.
+// Two schemas with the same tables, columns, indexes and constraints have the
+// same hash, whatever database they were read from: the OIDs of tables are
+// ignored, as are fields that are empty or set to their zero value, so that a
+// missing map and an empty one hash the same.
+func (s *Schema) Hash() (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	var v map[string]any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "", err
+	}
+	if tables, ok := v["tables"].(map[string]any); ok {
+		for _, table := range tables {
+			if t, ok := table.(map[string]any); ok {
+				delete(t, "oid")
+			}
+		}
+	}
+
+	// Objects are marshaled with their keys in sorted order, so the
+	// canonical form of the schema is stable
+	canonical, err := json.Marshal(pruneZeroValues(v))
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// pruneZeroValues removes the fields of the decoded JSON value whose values
+// are null, false, zero, empty strings or empty arrays and objects.
+func pruneZeroValues(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			field = pruneZeroValues(field)
+			if isZeroValue(field) {
+				delete(v, k)
+				continue
+			}
+			v[k] = field
+		}
+		return v
+	case []any:
+		for i, elem := range v {
+			v[i] = pruneZeroValues(elem)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isZeroValue(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	hash := func(t *testing.T, s *schema.Schema) string {
+		t.Helper()
+		h, err := s.Hash()
+		require.NoError(t, err)
+		return h
+	}
+
+	users := &schema.Schema{Name: "public", Tables: map[string]*schema.Table{"users": usersTable()}}
+
+	tests := map[string]struct {
+		schema *schema.Schema
+		equal  bool
+	}{
+		"identical schemas have the same hash": {
+			schema: &schema.Schema{Name: "public", Tables: map[string]*schema.Table{"users": usersTable()}},
+			equal:  true,
+		},
+		"table OIDs are ignored": {
+			schema: &schema.Schema{Name: "public", Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.OID = "12345"
+				return t
+			}()}},
+			equal: true,
+		},
+		"empty and missing maps hash the same": {
+			schema: &schema.Schema{Name: "public", Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.ForeignKeys = map[string]*schema.ForeignKey{}
+				t.UniqueConstraints = map[string]*schema.UniqueConstraint{}
+				t.Triggers = map[string]*schema.Trigger{}
+				return t
+			}()}},
+			equal: true,
+		},
+		"a changed column type changes the hash": {
+			schema: &schema.Schema{Name: "public", Tables: map[string]*schema.Table{"users": func() *schema.Table {
+				t := usersTable()
+				t.Columns["name"].Type = "text"
+				return t
+			}()}},
+			equal: false,
+		},
+		"a renamed column changes the hash": {
+			schema: &schema.Schema{Name: "public", Tables: map[string]*schema.Table{"users": renamedNameColumn(usersTable())}},
+			equal:  false,
+		},
+		"an added table changes the hash": {
+			schema: &schema.Schema{Name: "public", Tables: map[string]*schema.Table{
+				"users":  usersTable(),
+				"orders": {Name: "orders", OID: "2"},
+			}},
+			equal: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := hash(t, tc.schema)
+			assert.Len(t, got, 64)
+			if tc.equal {
+				assert.Equal(t, hash(t, users), got)
+			} else {
+				assert.NotEqual(t, hash(t, users), got)
+			}
+		})
+	}
+}
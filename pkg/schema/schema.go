@@ -62,6 +62,27 @@ type Table struct {
 	// ExcludeConstraints is a map of all exclude constraints defined on the table
 	ExcludeConstraints map[string]*ExcludeConstraint `json:"excludeConstraints"`
 
+	// ReplicaIdentity is the replica identity of the table
+	ReplicaIdentity *ReplicaIdentity `json:"replicaIdentity,omitempty"`
+
+	// Triggers is a map of the user-defined triggers on the table. Internal
+	// triggers and triggers created by pgroll are not included.
+	Triggers map[string]*Trigger `json:"triggers,omitempty"`
+
+	// Rules is a map of the rules on the table
+	Rules map[string]*Rule `json:"rules,omitempty"`
+
+	// Inherits is the list of tables the table inherits from, excluding the
+	// parent of a declarative partition
+	Inherits []string `json:"inherits,omitempty"`
+
+	// PartitionBy is the partition key of a partitioned table
+	PartitionBy *PartitionKey `json:"partitionBy,omitempty"`
+
+	// PartitionOf is the name of the partitioned table of which the table is a
+	// partition
+	PartitionOf string `json:"partitionOf,omitempty"`
+
 	// Whether or not the table has been deleted in the virtual schema
 	Deleted bool `json:"-"`
 }
@@ -78,9 +99,17 @@ type Column struct {
 	Nullable bool    `json:"nullable"`
 	Unique   bool    `json:"unique"`
 
+	// Generated is the expression that generates the values of a stored
+	// generated column
+	Generated *string `json:"generated,omitempty"`
+
 	// Optional comment for the column
 	Comment string `json:"comment"`
 
+	// Compression is the compression method set on the column, or empty if the
+	// column uses the server's default_toast_compression
+	Compression string `json:"compression,omitempty"`
+
 	// Will contain possible enum values if the type is an enum
 	EnumValues []string `json:"enumValues"`
 
@@ -91,6 +120,40 @@ type Column struct {
 	PostgresType string `json:"postgresType"`
 }
 
+// ReplicaIdentity represents the replica identity of a table
+type ReplicaIdentity struct {
+	// Type is the replica identity type; one of DEFAULT, FULL, NOTHING or INDEX
+	Type string `json:"type"`
+
+	// Index is the name of the index used as the replica identity when Type is
+	// INDEX
+	Index string `json:"index,omitempty"`
+}
+
+// Trigger represents a trigger on a table
+type Trigger struct {
+	// Name is the name of the trigger in postgres
+	Name string `json:"name"`
+
+	// State is whether the trigger fires; one of ENABLE, DISABLE,
+	// ENABLE REPLICA or ENABLE ALWAYS
+	State string `json:"state"`
+}
+
+// Rule represents a rule on a table
+type Rule struct {
+	// Name is the name of the rule in postgres
+	Name string `json:"name"`
+
+	// Definition is the statement that creates the rule, as returned by
+	// pg_get_ruledef
+	Definition string `json:"definition"`
+
+	// State is whether the rule fires; one of ENABLE, DISABLE,
+	// ENABLE REPLICA or ENABLE ALWAYS
+	State string `json:"state"`
+}
+
 // Index represents an index on a table
 type Index struct {
 	// Name is the name of the index in postgres
@@ -105,6 +168,9 @@ type Index struct {
 	// Columns is the set of key columns on which the index is defined
 	Columns []string `json:"columns"`
 
+	// Expressions is the set of key expressions on which the index is defined
+	Expressions []string `json:"expressions,omitempty"`
+
 	// Predicate is the optional predicate for the index
 	Predicate *string `json:"predicate,omitempty"`
 
@@ -140,6 +206,18 @@ type ForeignKey struct {
 
 	// MatchType is the match type of the foreign key
 	MatchType string `json:"matchType"`
+
+	// Deferrable indicates that checking the foreign key can be deferred
+	// until the end of the transaction
+	Deferrable bool `json:"deferrable"`
+
+	// InitiallyDeferred indicates that checking the foreign key is deferred
+	// by default
+	InitiallyDeferred bool `json:"initiallyDeferred"`
+
+	// NotValid indicates that the foreign key was added NOT VALID and has not
+	// been validated, so existing rows may violate it
+	NotValid bool `json:"notValid"`
 }
 
 // CheckConstraint represents a check constraint on a table
@@ -155,6 +233,10 @@ type CheckConstraint struct {
 
 	// NoInherit indicates that the check constraint should not be inherited
 	NoInherit bool `json:"noInherit"`
+
+	// NotValid indicates that the check constraint was added NOT VALID and has
+	// not been validated, so existing rows may violate it
+	NotValid bool `json:"notValid"`
 }
 
 // UniqueConstraint represents a unique constraint on a table
@@ -164,6 +246,14 @@ type UniqueConstraint struct {
 
 	// The columns that the unique constraint is defined on
 	Columns []string `json:"columns"`
+
+	// Deferrable indicates that checking the unique constraint can be
+	// deferred until the end of the transaction
+	Deferrable bool `json:"deferrable"`
+
+	// InitiallyDeferred indicates that checking the unique constraint is
+	// deferred by default
+	InitiallyDeferred bool `json:"initiallyDeferred"`
 }
 
 // ExcludeConstraint represents a unique constraint on a table
@@ -184,6 +274,19 @@ type ExcludeConstraint struct {
 	Definition string `json:"definition"`
 }
 
+// PartitionKey represents the partition key of a partitioned table
+type PartitionKey struct {
+	// Type is the partitioning strategy: range, list or hash
+	Type string `json:"type"`
+
+	// The columns of the partition key
+	Columns []string `json:"columns,omitempty"`
+
+	// Expression is the expression of the partition key, if it isn't made of
+	// columns only
+	Expression string `json:"expression,omitempty"`
+}
+
 // GetTable returns a table by name
 func (s *Schema) GetTable(name string) *Table {
 	if s.Tables == nil {
@@ -235,6 +338,51 @@ func (s *Schema) UnRemoveTable(name string) {
 	}
 }
 
+// GetTrigger returns a trigger by name
+func (t *Table) GetTrigger(name string) *Trigger {
+	if t.Triggers == nil {
+		return nil
+	}
+	return t.Triggers[name]
+}
+
+// GetRule returns a rule by name
+func (t *Table) GetRule(name string) *Rule {
+	if t.Rules == nil {
+		return nil
+	}
+	return t.Rules[name]
+}
+
+// InheritsFrom returns true if the table inherits from the given table.
+func (t *Table) InheritsFrom(parent string) bool {
+	return slices.Contains(t.Inherits, parent)
+}
+
+// IsPartitioned returns true if the table is a partitioned table.
+func (t *Table) IsPartitioned() bool {
+	return t.PartitionBy != nil
+}
+
+// IncludesPartitionKey returns true if the given columns include every column
+// of the table's partition key, as Postgres requires of the primary key and
+// unique constraints of a partitioned table. Partition keys with expressions
+// are never included.
+func (t *Table) IncludesPartitionKey(columns []string) bool {
+	if t.PartitionBy == nil {
+		return true
+	}
+	if t.PartitionBy.Expression != "" {
+		return false
+	}
+	for _, c := range t.PartitionBy.Columns {
+		if !slices.Contains(columns, c) {
+			return false
+		}
+	}
+	return true
+}
+
 // GetColumn returns a column by name
 func (t *Table) GetColumn(name string) *Column {
 	if t.Columns == nil {
@@ -300,6 +448,27 @@ func (t *Table) RenameConstraintColumns(from, to string) {
 	for _, fk := range t.ForeignKeys {
 		updateColumns(fk.Columns)
 	}
+	if t.PartitionBy != nil {
+		updateColumns(t.PartitionBy.Columns)
+	}
+}
+
+// NotValidConstraints returns the names of the check constraints and foreign
+// keys of the table that have not been validated, in order.
+func (t *Table) NotValidConstraints() []string {
+	var names []string
+	for name, cc := range t.CheckConstraints {
+		if cc.NotValid {
+			names = append(names, name)
+		}
+	}
+	for name, fk := range t.ForeignKeys {
+		if fk.NotValid {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
 }
 
 // GetPrimaryKey returns the columns that make up the primary key
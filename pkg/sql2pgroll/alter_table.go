@@ -44,10 +44,14 @@ func convertAlterTableStmt(stmt *pgq.AlterTableStmt) (migrations.Operations, err
 			op, err = convertAlterTableDropColumn(stmt, alterTableCmd)
 		case pgq.AlterTableType_AT_ColumnDefault:
 			op, err = convertAlterTableSetColumnDefault(stmt, alterTableCmd)
+		case pgq.AlterTableType_AT_DropExpression:
+			op, err = convertAlterTableDropExpression(stmt, alterTableCmd)
 		case pgq.AlterTableType_AT_DropConstraint:
 			op, err = convertAlterTableDropConstraint(stmt, alterTableCmd)
 		case pgq.AlterTableType_AT_AddColumn:
 			op, err = convertAlterTableAddColumn(stmt, alterTableCmd)
+		case pgq.AlterTableType_AT_ValidateConstraint:
+			op, err = convertAlterTableValidateConstraint(stmt, alterTableCmd)
 		}
 
 		if err != nil {
@@ -171,12 +175,14 @@ func convertAlterTableAddUniqueConstraint(stmt *pgq.AlterTableStmt, constraint *
 	}
 
 	return &migrations.OpCreateConstraint{
-		Type:    migrations.OpCreateConstraintTypeUnique,
-		Name:    constraint.GetConname(),
-		Table:   stmt.GetRelation().GetRelname(),
-		Columns: columns,
-		Down:    upDown,
-		Up:      upDown,
+		Type:              migrations.OpCreateConstraintTypeUnique,
+		Name:              constraint.GetConname(),
+		Table:             stmt.GetRelation().GetRelname(),
+		Columns:           columns,
+		Deferrable:        constraint.GetDeferrable(),
+		InitiallyDeferred: constraint.GetInitdeferred(),
+		Down:              upDown,
+		Up:                upDown,
 	}, nil
 }
 
@@ -194,13 +200,15 @@ func convertAlterTableAddForeignKeyConstraint(stmt *pgq.AlterTableStmt, constrai
 	}
 
 	return &migrations.OpCreateConstraint{
-		Columns:    columns,
-		Up:         migs,
-		Down:       migs,
-		Name:       constraint.GetConname(),
-		References: references,
-		Table:      tableName,
-		Type:       migrations.OpCreateConstraintTypeForeignKey,
+		Columns:           columns,
+		Up:                migs,
+		Down:              migs,
+		Name:              constraint.GetConname(),
+		References:        references,
+		Table:             tableName,
+		Type:              migrations.OpCreateConstraintTypeForeignKey,
+		Deferrable:        constraint.GetDeferrable(),
+		InitiallyDeferred: constraint.GetInitdeferred(),
 	}, nil
 }
 
@@ -291,6 +299,27 @@ func convertAlterTableSetColumnDefault(stmt *pgq.AlterTableStmt, cmd *pgq.AlterT
 	return nil, nil
 }
 
+// convertAlterTableDropExpression converts SQL statements like:
+//
+// `ALTER TABLE foo ALTER COLUMN bar DROP EXPRESSION`
+//
+// to an OpAlterColumn operation that converts the generated column to a
+// regular column.
+func convertAlterTableDropExpression(stmt *pgq.AlterTableStmt, cmd *pgq.AlterTableCmd) (migrations.Operation, error) {
+	// IF EXISTS clauses are not represented by OpAlterColumn
+	if cmd.GetMissingOk() {
+		return nil, nil
+	}
+
+	return &migrations.OpAlterColumn{
+		Table:     stmt.GetRelation().GetRelname(),
+		Column:    cmd.GetName(),
+		Generated: nullable.NewNullNullable[string](),
+		Up:        PlaceHolderSQL,
+		Down:      PlaceHolderSQL,
+	}, nil
+}
+
 func extractDefault(node *pgq.Node) (nullable.Nullable[string], error) {
 	if c := node.GetAConst(); c != nil && c.GetIsnull() {
 		// The default can be set to null
@@ -342,6 +371,19 @@ func canConvertDropConstraint(cmd *pgq.AlterTableCmd) bool {
 	return cmd.Behavior != pgq.DropBehavior_DROP_CASCADE
 }
 
+// convertAlterTableValidateConstraint converts VALIDATE CONSTRAINT SQL into an
+// OpValidateConstraint.
+//
+// SQL statements like the following are supported:
+//
+// `ALTER TABLE foo VALIDATE CONSTRAINT constraint_foo`
+func convertAlterTableValidateConstraint(stmt *pgq.AlterTableStmt, cmd *pgq.AlterTableCmd) (migrations.Operation, error) {
+	return &migrations.OpValidateConstraint{
+		Table: getQualifiedRelationName(stmt.GetRelation()),
+		Name:  cmd.GetName(),
+	}, nil
+}
+
 // convertAlterTableAddColumn converts ADD COLUMN SQL into an OpAddColumn.
 //
 // See TestConvertAlterTableStatements and TestUnconvertableAlterTableStatements for statements we
@@ -377,21 +419,16 @@ func convertAlterTableDropColumn(stmt *pgq.AlterTableStmt, cmd *pgq.AlterTableCm
 	}
 
 	return &migrations.OpDropColumn{
-		Table:  stmt.GetRelation().GetRelname(),
-		Column: cmd.GetName(),
-		Down:   PlaceHolderSQL,
+		Table:   stmt.GetRelation().GetRelname(),
+		Column:  cmd.GetName(),
+		Down:    PlaceHolderSQL,
+		Cascade: cmd.Behavior == pgq.DropBehavior_DROP_CASCADE,
 	}, nil
 }
 
 // canConvertDropColumn checks whether we can convert the command without losing any information.
 func canConvertDropColumn(cmd *pgq.AlterTableCmd) bool {
-	if cmd.MissingOk {
-		return false
-	}
-	if cmd.Behavior == pgq.DropBehavior_DROP_CASCADE {
-		return false
-	}
-	return true
+	return !cmd.MissingOk
 }
 
 // canConvertUniqueConstraint checks if the unique constraint `constraint` can
@@ -72,6 +72,10 @@ func TestConvertAlterTableStatements(t *testing.T) {
 			sql:        "ALTER TABLE foo ALTER COLUMN bar SET DEFAULT (first_name || ' ' || last_name)",
 			expectedOp: expect.AlterColumnOp12,
 		},
+		{
+			sql:        "ALTER TABLE foo ALTER COLUMN bar DROP EXPRESSION",
+			expectedOp: expect.AlterColumnOp13,
+		},
 		{
 			sql:        "ALTER TABLE foo ADD CONSTRAINT bar UNIQUE (a)",
 			expectedOp: expect.CreateConstraintOp1,
@@ -80,6 +84,14 @@ func TestConvertAlterTableStatements(t *testing.T) {
 			sql:        "ALTER TABLE foo ADD CONSTRAINT bar UNIQUE (a, b)",
 			expectedOp: expect.CreateConstraintOp2,
 		},
+		{
+			sql:        "ALTER TABLE foo ADD CONSTRAINT bar UNIQUE (a) NOT DEFERRABLE",
+			expectedOp: expect.CreateConstraintOp1,
+		},
+		{
+			sql:        "ALTER TABLE foo ADD CONSTRAINT bar UNIQUE (a) DEFERRABLE INITIALLY DEFERRED",
+			expectedOp: expect.CreateConstraintOp6,
+		},
 		{
 			sql:        "ALTER TABLE foo DROP COLUMN bar",
 			expectedOp: expect.DropColumnOp1,
@@ -88,6 +100,10 @@ func TestConvertAlterTableStatements(t *testing.T) {
 			sql:        "ALTER TABLE foo DROP COLUMN bar RESTRICT ",
 			expectedOp: expect.DropColumnOp1,
 		},
+		{
+			sql:        "ALTER TABLE foo DROP COLUMN bar CASCADE",
+			expectedOp: expect.DropColumnOp2,
+		},
 		{
 			sql:        "ALTER TABLE foo ADD CONSTRAINT fk_bar_cd FOREIGN KEY (a, b) REFERENCES bar (c, d);",
 			expectedOp: expect.AddForeignKeyOp1WithParams(migrations.ForeignKeyMatchTypeSIMPLE, migrations.ForeignKeyActionNOACTION, migrations.ForeignKeyActionNOACTION),
@@ -128,6 +144,10 @@ func TestConvertAlterTableStatements(t *testing.T) {
 			sql:        "ALTER TABLE schema_a.foo ADD CONSTRAINT fk_bar_c FOREIGN KEY (a) REFERENCES schema_a.bar (c);",
 			expectedOp: expect.AddForeignKeyOp3,
 		},
+		{
+			sql:        "ALTER TABLE foo ADD CONSTRAINT fk_bar_c FOREIGN KEY (a) REFERENCES bar (c) DEFERRABLE;",
+			expectedOp: expect.AddForeignKeyOp4,
+		},
 		{
 			sql:        "ALTER TABLE foo DROP CONSTRAINT constraint_foo",
 			expectedOp: expect.OpDropConstraintWithTable("foo"),
@@ -144,6 +164,14 @@ func TestConvertAlterTableStatements(t *testing.T) {
 			sql:        "ALTER TABLE foo DROP CONSTRAINT IF EXISTS constraint_foo RESTRICT",
 			expectedOp: expect.OpDropConstraintWithTable("foo"),
 		},
+		{
+			sql:        "ALTER TABLE foo VALIDATE CONSTRAINT constraint_foo",
+			expectedOp: expect.ValidateConstraintOp1,
+		},
+		{
+			sql:        "ALTER TABLE schema.foo VALIDATE CONSTRAINT constraint_foo",
+			expectedOp: expect.ValidateConstraintOp2,
+		},
 		{
 			sql:        "ALTER TABLE foo ADD CONSTRAINT bar CHECK (age > 0)",
 			expectedOp: expect.CreateConstraintOp3,
@@ -312,10 +340,12 @@ func TestUnconvertableAlterTableStatements(t *testing.T) {
 		`ALTER TABLE foo ALTER COLUMN a SET DATA TYPE text COLLATE "en_US"`,
 		"ALTER TABLE foo ALTER COLUMN a SET DATA TYPE text USING 'foo'",
 
-		// CASCADE and IF EXISTS clauses are not represented by OpDropColumn
-		"ALTER TABLE foo DROP COLUMN bar CASCADE",
+		// IF EXISTS clauses are not represented by OpDropColumn
 		"ALTER TABLE foo DROP COLUMN IF EXISTS bar",
 
+		// IF EXISTS clauses are not represented by OpAlterColumn
+		"ALTER TABLE foo ALTER COLUMN bar DROP EXPRESSION IF EXISTS",
+
 		// Unsupported foreign key statements
 		"ALTER TABLE foo ADD CONSTRAINT fk_bar_cd FOREIGN KEY (a, b) REFERENCES bar (c, d) NOT VALID",
 		// MATCH PARTIAL is not implemented in the actual parser yet
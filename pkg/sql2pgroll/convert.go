@@ -3,13 +3,19 @@
 package sql2pgroll
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	pgq "github.com/xataio/pg_query_go/v6"
 
 	"github.com/xataio/pgroll/pkg/migrations"
 )
 
+// RawSQLComment is the comment set on the raw SQL operations that statements
+// which can't be converted to pgroll operations fall back to.
+const RawSQLComment = "TODO: statement could not be converted to a pgroll operation; review the raw SQL and add a down migration if needed"
+
 // Convert converts a SQL statement to a slice of pgroll operations.
 func Convert(sql string) (migrations.Operations, error) {
 	tree, err := pgq.Parse(sql)
@@ -17,6 +23,23 @@ func Convert(sql string) (migrations.Operations, error) {
 		return nil, fmt.Errorf("parse error: %w", err)
 	}
 
+	// The raw SQL of each statement is only needed for statements that can't
+	// be converted, so split the SQL lazily
+	var rawStmts []string
+	rawSQL := func(idx int) string {
+		if rawStmts == nil {
+			split, err := pgq.SplitWithParser(sql, true)
+			if err != nil {
+				return sql
+			}
+			rawStmts = split
+		}
+		if idx >= len(rawStmts) {
+			return sql
+		}
+		return rawStmts[idx]
+	}
+
 	var migOps migrations.Operations
 	stmts := tree.GetStmts()
 	for i, stmt := range stmts {
@@ -37,30 +60,49 @@ func Convert(sql string) (migrations.Operations, error) {
 			ops, err = convertDropStatement(node.DropStmt)
 		case *pgq.Node_IndexStmt:
 			ops, err = convertCreateIndexStmt(node.IndexStmt)
-		default:
-			// SQL statement cannot be transformed to pgroll operation
-			// so we will use raw SQL operation
-			ops = makeRawSQLOperation(sql, i)
+		case *pgq.Node_CompositeTypeStmt:
+			ops, err = convertCompositeTypeStmt(node.CompositeTypeStmt)
+		case *pgq.Node_CreateTableAsStmt:
+			ops, err = convertCreateTableAsStmt(node.CreateTableAsStmt)
 		}
 		if err != nil {
 			return nil, err
 		}
 		if ops == nil {
-			ops = makeRawSQLOperation(sql, i)
+			// SQL statement cannot be transformed to pgroll operation
+			// so we will use raw SQL operation
+			ops = makeRawSQLOperation(rawSQL(i))
 		}
 		migOps = append(migOps, ops...)
 	}
 	return migOps, nil
 }
 
-func makeRawSQLOperation(sql string, idx int) migrations.Operations {
-	stmts, err := pgq.SplitWithParser(sql, true)
-	if err != nil {
-		return migrations.Operations{
-			&migrations.OpRawSQL{Up: sql},
+func makeRawSQLOperation(sql string) migrations.Operations {
+	op := &migrations.OpRawSQL{Up: sql}
+	migrations.SetOperationComment(op, RawSQLComment)
+	return migrations.Operations{op}
+}
+
+// Warnings returns a warning for each converted operation that needs human
+// review: statements that fell back to raw SQL, and operations with
+// placeholder up or down SQL that must be filled in manually.
+func Warnings(ops migrations.Operations) []string {
+	var warnings []string
+	for i, op := range ops {
+		name := migrations.OperationName(op)
+		if migrations.OperationComment(op) == RawSQLComment {
+			warnings = append(warnings, fmt.Sprintf("operation %d (%s): statement could not be converted to a pgroll operation and runs as raw SQL", i+1, name))
+			continue
+		}
+
+		b, err := json.Marshal(op)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(string(b), PlaceHolderSQL) {
+			warnings = append(warnings, fmt.Sprintf("operation %d (%s): placeholder up or down SQL must be filled in", i+1, name))
 		}
 	}
-	return migrations.Operations{
-		&migrations.OpRawSQL{Up: stmts[idx]},
-	}
+	return warnings
 }
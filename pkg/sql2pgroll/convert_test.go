@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/xataio/pgroll/pkg/migrations"
 	"github.com/xataio/pgroll/pkg/sql2pgroll"
@@ -243,3 +244,26 @@ SECURITY DEFINER`,
 		})
 	}
 }
+
+func TestConvertWarnings(t *testing.T) {
+	t.Parallel()
+
+	sql := `CREATE TABLE t1 (id INT);
+CREATE DOMAIN d1 AS TEXT;
+ALTER TABLE t1 ADD COLUMN name TEXT;`
+
+	ops, err := sql2pgroll.Convert(sql)
+	require.NoError(t, err)
+	require.Len(t, ops, 3)
+
+	// Statements that fall back to raw SQL are commented
+	assert.Empty(t, migrations.OperationComment(ops[0]))
+	assert.Equal(t, sql2pgroll.RawSQLComment, migrations.OperationComment(ops[1]))
+	assert.Empty(t, migrations.OperationComment(ops[2]))
+
+	// The raw SQL fallback and the placeholder up SQL are reported
+	assert.Equal(t, []string{
+		"operation 2 (sql): statement could not be converted to a pgroll operation and runs as raw SQL",
+		"operation 3 (add_column): placeholder up or down SQL must be filled in",
+	}, sql2pgroll.Warnings(ops))
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sql2pgroll
+
+import (
+	"fmt"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// convertCreateTableAsStmt converts a CREATE TABLE ... AS statement to a
+// pgroll operation.
+func convertCreateTableAsStmt(stmt *pgq.CreateTableAsStmt) (migrations.Operations, error) {
+	if !canConvertCreateTableAsStmt(stmt) {
+		return nil, nil
+	}
+
+	query, err := pgq.Deparse(&pgq.ParseResult{
+		Stmts: []*pgq.RawStmt{{Stmt: stmt.GetQuery()}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error deparsing query: %w", err)
+	}
+
+	return migrations.Operations{
+		&migrations.OpCreateTableAs{
+			Name:       getQualifiedRelationName(stmt.GetInto().GetRel()),
+			Query:      query,
+			WithNoData: stmt.GetInto().GetSkipData(),
+		},
+	}, nil
+}
+
+// canConvertCreateTableAsStmt checks if a CREATE TABLE ... AS statement can
+// be converted to a pgroll operation.
+func canConvertCreateTableAsStmt(stmt *pgq.CreateTableAsStmt) bool {
+	into := stmt.GetInto()
+
+	switch {
+	// Materialized views and SELECT INTO are not supported
+	case stmt.GetObjtype() != pgq.ObjectType_OBJECT_TABLE,
+		stmt.GetIsSelectInto(),
+		// IF NOT EXISTS is not supported
+		stmt.GetIfNotExists(),
+		// Temporary and unlogged tables are not supported
+		into.GetRel().GetRelpersistence() != "p",
+		// Renaming the columns of the query is not supported
+		len(into.GetColNames()) != 0,
+		// Specifying an access method is not supported
+		into.GetAccessMethod() != "",
+		// Specifying storage options is not supported
+		len(into.GetOptions()) != 0,
+		// ON COMMIT options are not supported
+		into.GetOnCommit() != pgq.OnCommitAction_ONCOMMIT_NOOP,
+		// Setting a tablespace is not supported
+		into.GetTableSpaceName() != "":
+		return false
+	default:
+		return true
+	}
+}
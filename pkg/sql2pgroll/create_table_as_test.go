@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sql2pgroll_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/sql2pgroll"
+	"github.com/xataio/pgroll/pkg/sql2pgroll/expect"
+)
+
+func TestConvertCreateTableAsStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sql        string
+		expectedOp migrations.Operation
+	}{
+		{
+			sql:        "CREATE TABLE foo AS SELECT id, lower(name) AS name FROM users WHERE id > 10",
+			expectedOp: expect.CreateTableAsOp1,
+		},
+		{
+			sql: `CREATE TABLE foo.bar AS
+  SELECT id, lower(name) AS name
+  FROM users
+  WHERE id > 10`,
+			expectedOp: expect.CreateTableAsOp2,
+		},
+		{
+			sql:        "CREATE TABLE foo AS SELECT id, lower(name) AS name FROM users WHERE id > 10 WITH DATA",
+			expectedOp: expect.CreateTableAsOp1,
+		},
+		{
+			sql:        "CREATE TABLE foo AS SELECT id, lower(name) AS name FROM users WHERE id > 10 WITH NO DATA",
+			expectedOp: expect.CreateTableAsOp3,
+		},
+		{
+			sql:        "CREATE TABLE foo AS TABLE users",
+			expectedOp: expect.CreateTableAsOp4,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.sql, func(t *testing.T) {
+			ops, err := sql2pgroll.Convert(tc.sql)
+			require.NoError(t, err)
+
+			require.Len(t, ops, 1)
+
+			assert.Equal(t, tc.expectedOp, ops[0])
+		})
+	}
+}
+
+func TestUnconvertableCreateTableAsStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		// Materialized views and SELECT INTO are not supported
+		"CREATE MATERIALIZED VIEW foo AS SELECT * FROM users",
+		"SELECT * INTO foo FROM users",
+
+		// IF NOT EXISTS is not supported
+		"CREATE TABLE IF NOT EXISTS foo AS SELECT * FROM users",
+
+		// Temporary and unlogged tables are not supported
+		"CREATE TEMPORARY TABLE foo AS SELECT * FROM users",
+		"CREATE UNLOGGED TABLE foo AS SELECT * FROM users",
+
+		// Renaming the columns of the query is not supported
+		"CREATE TABLE foo (a, b) AS SELECT id, name FROM users",
+
+		// Specifying a table access method is not supported
+		"CREATE TABLE foo USING bar AS SELECT * FROM users",
+
+		// Specifying storage options is not supported
+		"CREATE TABLE foo WITH (fillfactor=70) AS SELECT * FROM users",
+
+		// ON COMMIT options are not supported
+		"CREATE TABLE foo ON COMMIT DROP AS SELECT * FROM users",
+
+		// Specifying a tablespace is not supported
+		"CREATE TABLE foo TABLESPACE bar AS SELECT * FROM users",
+	}
+
+	for _, sql := range tests {
+		t.Run(sql, func(t *testing.T) {
+			ops, err := sql2pgroll.Convert(sql)
+			require.NoError(t, err)
+
+			require.Len(t, ops, 1)
+
+			assert.Equal(t, expect.RawSQLOp(sql), ops[0])
+		})
+	}
+}
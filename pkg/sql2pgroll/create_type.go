@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sql2pgroll
+
+import (
+	"fmt"
+
+	pgq "github.com/xataio/pg_query_go/v6"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+// convertCompositeTypeStmt converts a CREATE TYPE ... AS (...) statement to a
+// pgroll operation.
+func convertCompositeTypeStmt(stmt *pgq.CompositeTypeStmt) (migrations.Operations, error) {
+	attributes := make([]migrations.CompositeTypeAttribute, 0, len(stmt.GetColdeflist()))
+	for _, def := range stmt.GetColdeflist() {
+		col := def.GetColumnDef()
+		// Attributes with a collation are not supported
+		if col == nil || col.GetCollClause() != nil {
+			return nil, nil
+		}
+
+		typeString, err := pgq.DeparseTypeName(col.GetTypeName())
+		if err != nil {
+			return nil, fmt.Errorf("error deparsing attribute type: %w", err)
+		}
+
+		attributes = append(attributes, migrations.CompositeTypeAttribute{
+			Name: col.GetColname(),
+			Type: typeString,
+		})
+	}
+
+	return migrations.Operations{
+		&migrations.OpCreateType{
+			Name:       getQualifiedRelationName(stmt.GetTypevar()),
+			Attributes: attributes,
+		},
+	}, nil
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sql2pgroll_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/sql2pgroll"
+	"github.com/xataio/pgroll/pkg/sql2pgroll/expect"
+)
+
+func TestConvertCreateTypeStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sql        string
+		expectedOp migrations.Operation
+	}{
+		{
+			sql:        "CREATE TYPE address AS (street text, zip varchar(10))",
+			expectedOp: expect.CreateTypeOp1,
+		},
+		{
+			sql:        "CREATE TYPE foo.address AS (street text, zip varchar(10))",
+			expectedOp: expect.CreateTypeOp2,
+		},
+		{
+			sql:        "CREATE TYPE address AS ()",
+			expectedOp: expect.CreateTypeOp3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.sql, func(t *testing.T) {
+			ops, err := sql2pgroll.Convert(tc.sql)
+			require.NoError(t, err)
+
+			require.Len(t, ops, 1)
+
+			assert.Equal(t, tc.expectedOp, ops[0])
+		})
+	}
+}
+
+func TestUnconvertableCreateTypeStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		// Attributes with a collation are not supported
+		`CREATE TYPE address AS (street text COLLATE "C")`,
+
+		// Enum, range and base types are not supported
+		"CREATE TYPE mood AS ENUM ('sad', 'happy')",
+		"CREATE TYPE floatrange AS RANGE (subtype = float8)",
+		"CREATE TYPE address",
+	}
+
+	for _, sql := range tests {
+		t.Run(sql, func(t *testing.T) {
+			ops, err := sql2pgroll.Convert(sql)
+			require.NoError(t, err)
+
+			require.Len(t, ops, 1)
+
+			assert.Equal(t, expect.RawSQLOp(sql), ops[0])
+		})
+	}
+}
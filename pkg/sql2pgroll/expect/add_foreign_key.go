@@ -70,3 +70,24 @@ var AddForeignKeyOp3 = &migrations.OpCreateConstraint{
 		"a": sql2pgroll.PlaceHolderSQL,
 	},
 }
+
+var AddForeignKeyOp4 = &migrations.OpCreateConstraint{
+	Columns: []string{"a"},
+	Name:    "fk_bar_c",
+	References: &migrations.TableForeignKeyReference{
+		Columns:   []string{"c"},
+		OnDelete:  migrations.ForeignKeyActionNOACTION,
+		OnUpdate:  migrations.ForeignKeyActionNOACTION,
+		MatchType: migrations.ForeignKeyMatchTypeSIMPLE,
+		Table:     "bar",
+	},
+	Table:      "foo",
+	Type:       migrations.OpCreateConstraintTypeForeignKey,
+	Deferrable: true,
+	Up: map[string]string{
+		"a": sql2pgroll.PlaceHolderSQL,
+	},
+	Down: map[string]string{
+		"a": sql2pgroll.PlaceHolderSQL,
+	},
+}
@@ -97,6 +97,14 @@ var AlterColumnOp12 = &migrations.OpAlterColumn{
 	Down:    sql2pgroll.PlaceHolderSQL,
 }
 
+var AlterColumnOp13 = &migrations.OpAlterColumn{
+	Table:     "foo",
+	Column:    "bar",
+	Generated: nullable.NewNullNullable[string](),
+	Up:        sql2pgroll.PlaceHolderSQL,
+	Down:      sql2pgroll.PlaceHolderSQL,
+}
+
 func ptr[T any](v T) *T {
 	return &v
 }
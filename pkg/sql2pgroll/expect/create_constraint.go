@@ -73,3 +73,14 @@ var CreateConstraintOp5 = &migrations.OpCreateConstraint{
 		sql2pgroll.PlaceHolderColumnName: sql2pgroll.PlaceHolderSQL,
 	},
 }
+
+var CreateConstraintOp6 = &migrations.OpCreateConstraint{
+	Type:              migrations.OpCreateConstraintTypeUnique,
+	Name:              "bar",
+	Table:             "foo",
+	Columns:           []string{"a"},
+	Deferrable:        true,
+	InitiallyDeferred: true,
+	Down:              map[string]string{"a": sql2pgroll.PlaceHolderSQL},
+	Up:                map[string]string{"a": sql2pgroll.PlaceHolderSQL},
+}
@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expect
+
+import (
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+var CreateTableAsOp1 = &migrations.OpCreateTableAs{
+	Name:  "foo",
+	Query: "SELECT id, lower(name) AS name FROM users WHERE id > 10",
+}
+
+var CreateTableAsOp2 = &migrations.OpCreateTableAs{
+	Name:  "foo.bar",
+	Query: "SELECT id, lower(name) AS name FROM users WHERE id > 10",
+}
+
+var CreateTableAsOp3 = &migrations.OpCreateTableAs{
+	Name:       "foo",
+	Query:      "SELECT id, lower(name) AS name FROM users WHERE id > 10",
+	WithNoData: true,
+}
+
+var CreateTableAsOp4 = &migrations.OpCreateTableAs{
+	Name:  "foo",
+	Query: "SELECT * FROM users",
+}
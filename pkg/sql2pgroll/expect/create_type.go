@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expect
+
+import (
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+var CreateTypeOp1 = &migrations.OpCreateType{
+	Name: "address",
+	Attributes: []migrations.CompositeTypeAttribute{
+		{Name: "street", Type: "text"},
+		{Name: "zip", Type: "varchar(10)"},
+	},
+}
+
+var CreateTypeOp2 = &migrations.OpCreateType{
+	Name: "foo.address",
+	Attributes: []migrations.CompositeTypeAttribute{
+		{Name: "street", Type: "text"},
+		{Name: "zip", Type: "varchar(10)"},
+	},
+}
+
+var CreateTypeOp3 = &migrations.OpCreateType{
+	Name:       "address",
+	Attributes: []migrations.CompositeTypeAttribute{},
+}
@@ -12,3 +12,10 @@ var DropColumnOp1 = &migrations.OpDropColumn{
 	Column: "bar",
 	Down:   sql2pgroll.PlaceHolderSQL,
 }
+
+var DropColumnOp2 = &migrations.OpDropColumn{
+	Table:   "foo",
+	Column:  "bar",
+	Down:    sql2pgroll.PlaceHolderSQL,
+	Cascade: true,
+}
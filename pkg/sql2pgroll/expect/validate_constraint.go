@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expect
+
+import (
+	"github.com/xataio/pgroll/pkg/migrations"
+)
+
+var ValidateConstraintOp1 = &migrations.OpValidateConstraint{
+	Table: "foo",
+	Name:  "constraint_foo",
+}
+
+var ValidateConstraintOp2 = &migrations.OpValidateConstraint{
+	Table: "schema.foo",
+	Name:  "constraint_foo",
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// StartBackfill records that the backfill of the tables of the active
+// migration `migration` has started. Tables whose backfill has already been
+// recorded keep their progress.
+func (s *State) StartBackfill(ctx context.Context, schemaName, migration string, tables []string) error {
+	_, err := s.pgConn.ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO %s.backfill_progress (schema, migration, table_name)
+			SELECT $1, $2, unnest($3::text[])
+			ON CONFLICT DO NOTHING`, pq.QuoteIdentifier(s.schema)),
+		schemaName, migration, pq.StringArray(tables))
+	if err != nil {
+		return fmt.Errorf("failed to record start of backfill: %w", err)
+	}
+	return nil
+}
+
+// HasUnfinishedBackfill returns true if `migration` is the active migration
+// and the backfill of any of its tables was started but hasn't finished.
+func (s *State) HasUnfinishedBackfill(ctx context.Context, schemaName, migration string) (bool, error) {
+	var unfinished bool
+	err := s.pgConn.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT EXISTS (
+			SELECT 1 FROM %[1]s.backfill_progress AS p
+			JOIN %[1]s.migrations AS m ON m.schema = p.schema AND m.name = p.migration
+			WHERE p.schema = $1 AND p.migration = $2 AND NOT m.done AND NOT p.done)`,
+			pq.QuoteIdentifier(s.schema)),
+		schemaName, migration).Scan(&unfinished)
+	if err != nil {
+		return false, err
+	}
+	return unfinished, nil
+}
+
+// BackfillProgress returns the paging key of the last row backfilled in
+// `table` by `migration`, and whether the backfill of the table has finished.
+// A nil key is returned if no rows have been backfilled yet.
+func (s *State) BackfillProgress(ctx context.Context, schemaName, migration, table string) ([]string, bool, error) {
+	var lastValue pq.StringArray
+	var done bool
+	err := s.pgConn.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT last_value, done FROM %s.backfill_progress
+			WHERE schema = $1 AND migration = $2 AND table_name = $3`,
+			pq.QuoteIdentifier(s.schema)),
+		schemaName, migration, table).Scan(&lastValue, &done)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return lastValue, done, nil
+}
+
+// SaveBackfillProgress stores the paging key of the last row backfilled in
+// `table` by `migration`, and whether the backfill of the table has finished.
+// `rows` is added to the number of rows backfilled in the table so far, and
+// `total` replaces the number of rows that the table is expected to have.
+func (s *State) SaveBackfillProgress(ctx context.Context, schemaName, migration, table string, lastValue []string, rows, total int64, done bool) error {
+	_, err := s.pgConn.ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO %[1]s.backfill_progress (schema, migration, table_name, last_value, rows_done, rows_total, done)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (schema, migration, table_name)
+			DO UPDATE SET last_value = EXCLUDED.last_value,
+				rows_done = %[1]s.backfill_progress.rows_done + EXCLUDED.rows_done,
+				rows_total = EXCLUDED.rows_total,
+				done = EXCLUDED.done,
+				updated_at = CURRENT_TIMESTAMP`,
+			pq.QuoteIdentifier(s.schema)),
+		schemaName, migration, table, pq.StringArray(lastValue), rows, total, done)
+	return err
+}
+
+// TableBackfill describes how far the backfill of a table has got.
+type TableBackfill struct {
+	Table string `json:"table"`
+
+	// RowsDone is the number of rows backfilled so far, and RowsTotal the
+	// number of rows the table was expected to have when its backfill last
+	// reported progress. RowsTotal is an estimate for large tables.
+	RowsDone  int64 `json:"rowsDone"`
+	RowsTotal int64 `json:"rowsTotal"`
+
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// BackfillTables returns the progress of the backfill of each table of
+// `migration`, in table name order. No tables are returned if the backfill of
+// the migration hasn't started or the migration has no backfill.
+func (s *State) BackfillTables(ctx context.Context, schemaName, migration string) ([]TableBackfill, error) {
+	rows, err := s.pgConn.QueryContext(ctx,
+		fmt.Sprintf(`SELECT table_name, rows_done, rows_total, done, updated_at
+			FROM %s.backfill_progress
+			WHERE schema = $1 AND migration = $2
+			ORDER BY table_name`,
+			pq.QuoteIdentifier(s.schema)),
+		schemaName, migration)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []TableBackfill
+	for rows.Next() {
+		var t TableBackfill
+		if err := rows.Scan(&t.Table, &t.RowsDone, &t.RowsTotal, &t.Done, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("row scan: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
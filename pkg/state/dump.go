@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// dumpedTables are the tables of the state schema that are dumped, in the
+// order in which they are restored.
+var dumpedTables = []string{"migrations", "backfill_progress"}
+
+// Dump holds the contents of the pgroll state schema: the migration history
+// of every schema, including the schema snapshots stored with each migration,
+// and the progress of the backfills of active migrations.
+type Dump struct {
+	// Version is the version of pgroll that initialized the dumped state
+	// schema
+	Version string `json:"version"`
+
+	// Tables holds the rows of each table of the state schema, as an array of
+	// JSON objects keyed by column name
+	Tables map[string]json.RawMessage `json:"tables"`
+}
+
+// Dump returns the contents of the state schema.
+func (s *State) Dump(ctx context.Context) (*Dump, error) {
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored version: %w", err)
+	}
+
+	dump := &Dump{Version: version, Tables: make(map[string]json.RawMessage)}
+	for _, table := range dumpedTables {
+		//nolint:gosec // the table names are constants and the schema is quoted
+		query := fmt.Sprintf("SELECT COALESCE(jsonb_agg(to_jsonb(t) ORDER BY t), '[]') FROM %s.%s t",
+			pq.QuoteIdentifier(s.schema), pq.QuoteIdentifier(table))
+
+		var rows []byte
+		if err := s.pgConn.QueryRowContext(ctx, query).Scan(&rows); err != nil {
+			return nil, fmt.Errorf("failed to dump table %q: %w", table, err)
+		}
+		dump.Tables[table] = rows
+	}
+
+	return dump, nil
+}
+
+// Restore loads the contents of a dump into the state schema, which must be
+// initialized and must not hold any migrations. Dumps made by newer versions
+// of pgroll are rejected, as they may hold state that this version can't
+// read. Columns missing from dumps made by older versions are set to their
+// defaults.
+func (s *State) Restore(ctx context.Context, dump *Dump) error {
+	if compareVersions(dump.Version, s.pgrollVersion) == VersionCompatVersionSchemaNewer {
+		return fmt.Errorf("%w: dump version %s, pgroll version %s", ErrNewerStateDump, dump.Version, s.pgrollVersion)
+	}
+
+	tx, err := s.pgConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var empty bool
+	err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT NOT EXISTS (SELECT 1 FROM %s.migrations)",
+		pq.QuoteIdentifier(s.schema))).Scan(&empty)
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return ErrStateNotEmpty
+	}
+
+	for _, table := range dumpedTables {
+		rows, ok := dump.Tables[table]
+		if !ok {
+			continue
+		}
+
+		columns, err := dumpColumns(rows)
+		if err != nil {
+			return fmt.Errorf("invalid rows for table %q in dump: %w", table, err)
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		quoted := make([]string, 0, len(columns))
+		for _, column := range columns {
+			quoted = append(quoted, pq.QuoteIdentifier(column))
+		}
+		qualified := fmt.Sprintf("%s.%s", pq.QuoteIdentifier(s.schema), pq.QuoteIdentifier(table))
+
+		//nolint:gosec // the table and column names are quoted
+		query := fmt.Sprintf("INSERT INTO %[1]s (%[2]s) SELECT %[2]s FROM jsonb_populate_recordset(NULL::%[1]s, $1)",
+			qualified, strings.Join(quoted, ", "))
+		if _, err := tx.ExecContext(ctx, query, string(rows)); err != nil {
+			return fmt.Errorf("failed to restore table %q: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// dumpColumns returns the names of the columns of the dumped rows of a table,
+// in order.
+func dumpColumns(rows json.RawMessage) ([]string, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(rows, &objects); err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]bool)
+	for _, object := range objects {
+		for column := range object {
+			columns[column] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(columns)), nil
+}
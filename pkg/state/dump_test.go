@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package state_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+func TestDumpAndRestore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the restored state matches the dumped state", func(t *testing.T) {
+		testutils.WithStateAndConnectionToContainer(t, func(st *state.State, db *sql.DB) {
+			ctx := context.Background()
+
+			// Run some SQL to generate an inferred migration
+			_, err := db.ExecContext(ctx, "CREATE TABLE items (id int NOT NULL)")
+			require.NoError(t, err)
+
+			// Start a migration with a backfill in progress
+			err = st.Start(ctx, "public", &migrations.Migration{Name: "02_add_column"}, backfill.Naming{})
+			require.NoError(t, err)
+			require.NoError(t, st.StartBackfill(ctx, "public", "02_add_column", []string{"items"}))
+			require.NoError(t, st.SaveBackfillProgress(ctx, "public", "02_add_column", "items", []string{"10"}, 10, 20, false))
+
+			dump, err := st.Dump(ctx)
+			require.NoError(t, err)
+
+			testutils.WithUninitializedState(t, func(restored *state.State) {
+				require.NoError(t, restored.Init(ctx))
+				require.NoError(t, restored.Restore(ctx, dump))
+
+				restoredDump, err := restored.Dump(ctx)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(dump.Tables["migrations"]), string(restoredDump.Tables["migrations"]))
+				assert.JSONEq(t, string(dump.Tables["backfill_progress"]), string(restoredDump.Tables["backfill_progress"]))
+
+				// The restored state tracks the active migration and its backfill
+				active, err := restored.IsActiveMigrationPeriod(ctx, "public")
+				require.NoError(t, err)
+				assert.True(t, active)
+
+				lastValue, done, err := restored.BackfillProgress(ctx, "public", "02_add_column", "items")
+				require.NoError(t, err)
+				assert.Equal(t, []string{"10"}, lastValue)
+				assert.False(t, done)
+			})
+		})
+	})
+
+	t.Run("a state with migrations can't be restored into", func(t *testing.T) {
+		testutils.WithStateAndConnectionToContainer(t, func(st *state.State, db *sql.DB) {
+			ctx := context.Background()
+
+			_, err := db.ExecContext(ctx, "CREATE TABLE items (id int NOT NULL)")
+			require.NoError(t, err)
+
+			dump, err := st.Dump(ctx)
+			require.NoError(t, err)
+
+			err = st.Restore(ctx, dump)
+			require.ErrorIs(t, err, state.ErrStateNotEmpty)
+		})
+	})
+
+	t.Run("a dump made by a newer version of pgroll can't be restored", func(t *testing.T) {
+		testutils.WithStateAtVersionAndConnectionToContainer(t, "0.14.0", func(_ *state.State, connStr string, _ *sql.DB) {
+			ctx := context.Background()
+
+			st, err := state.New(ctx, connStr, "pgroll", state.WithPgrollVersion("0.14.0"))
+			require.NoError(t, err)
+			defer st.Close()
+
+			err = st.Restore(ctx, &state.Dump{Version: "0.15.0"})
+			require.ErrorIs(t, err, state.ErrNewerStateDump)
+		})
+	})
+}
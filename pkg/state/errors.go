@@ -4,4 +4,9 @@ package state
 
 import "errors"
 
-var ErrNoActiveMigration = errors.New("no active migration")
+var (
+	ErrNoActiveMigration = errors.New("no active migration")
+	ErrMigrationNotFound = errors.New("migration not found")
+	ErrStateNotEmpty     = errors.New("pgroll state is not empty")
+	ErrNewerStateDump    = errors.New("pgroll binary version is older than the pgroll version of the state dump")
+)
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package state
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/xataio/pgroll/pkg/schema"
+)
+
+// SchemaHash returns the hash of the logical schema after the migration
+// `version` was applied to `schemaName`, as computed by schema.Hash. The hash
+// is empty if the migration has not been completed. ErrMigrationNotFound is
+// returned if no migration with that name has been applied.
+func (s *State) SchemaHash(ctx context.Context, schemaName, version string) (string, error) {
+	var hash sql.NullString
+	var rawSchema []byte
+	err := s.pgConn.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT schema_hash, CASE WHEN schema_hash IS NULL AND done THEN resulting_schema END
+			FROM %s.migrations WHERE schema=$1 AND name=$2`, pq.QuoteIdentifier(s.schema)),
+		schemaName, version).Scan(&hash, &rawSchema)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrMigrationNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return snapshotHash(hash, rawSchema)
+}
+
+// LatestSchemaHash returns the hash of the logical schema after the latest
+// completed migration applied to `schemaName`, or an empty string if no
+// migration has been completed.
+func (s *State) LatestSchemaHash(ctx context.Context, schemaName string) (string, error) {
+	var hash sql.NullString
+	var rawSchema []byte
+	err := s.pgConn.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT schema_hash, CASE WHEN schema_hash IS NULL THEN resulting_schema END
+			FROM %s.migrations
+			WHERE schema=$1 AND done AND resulting_schema IS NOT NULL
+			ORDER BY created_at DESC LIMIT 1`, pq.QuoteIdentifier(s.schema)),
+		schemaName).Scan(&hash, &rawSchema)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return snapshotHash(hash, rawSchema)
+}
+
+// storeSchemaHash stores the hash of the schema snapshot recorded for the
+// completed migration.
+func (s *State) storeSchemaHash(ctx context.Context, schemaName, name string, rawSchema []byte) error {
+	hash, err := snapshotHash(sql.NullString{}, rawSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.pgConn.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %s.migrations SET schema_hash=$1 WHERE schema=$2 AND name=$3", pq.QuoteIdentifier(s.schema)),
+		hash, schemaName, name)
+	return err
+}
+
+// snapshotHash returns the stored hash of a schema snapshot, or computes it
+// from the snapshot for migrations recorded without one, such as inferred
+// migrations and migrations completed by older versions of pgroll. The hash
+// is empty if there is neither.
+func snapshotHash(hash sql.NullString, rawSchema []byte) (string, error) {
+	if hash.Valid {
+		return hash.String, nil
+	}
+	if rawSchema == nil {
+		return "", nil
+	}
+
+	var sc schema.Schema
+	if err := json.Unmarshal(rawSchema, &sc); err != nil {
+		return "", fmt.Errorf("unable to unmarshal schema: %w", err)
+	}
+	return sc.Hash()
+}
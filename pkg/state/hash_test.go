@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package state_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/migrations"
+	"github.com/xataio/pgroll/pkg/state"
+)
+
+func TestSchemaHash(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithStateAndConnectionToContainer(t, func(st *state.State, db *sql.DB) {
+		ctx := context.Background()
+
+		liveHash := func() string {
+			sc, err := st.ReadSchema(ctx, "public")
+			require.NoError(t, err)
+			hash, err := sc.Hash()
+			require.NoError(t, err)
+			return hash
+		}
+
+		// No migration has been completed yet
+		hash, err := st.LatestSchemaHash(ctx, "public")
+		require.NoError(t, err)
+		assert.Empty(t, hash)
+
+		// A migration that hasn't been completed has no hash
+		require.NoError(t, st.Start(ctx, "public", &migrations.Migration{Name: "01_empty"}, backfill.Naming{}))
+		hash, err = st.SchemaHash(ctx, "public", "01_empty")
+		require.NoError(t, err)
+		assert.Empty(t, hash)
+
+		// The hash of the schema is stored when the migration is completed
+		require.NoError(t, st.Complete(ctx, "public", "01_empty"))
+		emptyHash, err := st.SchemaHash(ctx, "public", "01_empty")
+		require.NoError(t, err)
+		assert.Equal(t, liveHash(), emptyHash)
+
+		// The hash of an inferred migration is computed from its snapshot
+		_, err = db.ExecContext(ctx, "CREATE TABLE items (id int NOT NULL)")
+		require.NoError(t, err)
+
+		hash, err = st.LatestSchemaHash(ctx, "public")
+		require.NoError(t, err)
+		assert.Equal(t, liveHash(), hash)
+		assert.NotEqual(t, emptyHash, hash)
+
+		// The history reports the hash of each migration
+		history, err := st.SchemaHistory(ctx, "public")
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, emptyHash, history[0].SchemaHash)
+		assert.Equal(t, hash, history[1].SchemaHash)
+
+		_, err = st.SchemaHash(ctx, "public", "doesnt_exist")
+		require.ErrorIs(t, err, state.ErrMigrationNotFound)
+	})
+}
@@ -20,6 +20,11 @@ import (
 type HistoryEntry struct {
 	Migration migrations.RawMigration
 	CreatedAt time.Time
+
+	// SchemaHash is the hash of the logical schema after the migration, as
+	// computed by schema.Hash. It is empty if the migration has not been
+	// completed.
+	SchemaHash string
 }
 
 // BaselineMigration represents a baseline migration record
@@ -33,7 +38,8 @@ type BaselineMigration struct {
 // recent baseline in ascending timestamp order
 func (s *State) SchemaHistory(ctx context.Context, schema string) ([]HistoryEntry, error) {
 	rows, err := s.pgConn.QueryContext(ctx,
-		fmt.Sprintf(`SELECT name, migration, created_at
+		fmt.Sprintf(`SELECT name, migration, created_at, schema_hash,
+			CASE WHEN schema_hash IS NULL AND done THEN resulting_schema END
 			FROM %[1]s.migrations
 			WHERE schema=$1
 			AND created_at > COALESCE(
@@ -52,8 +58,10 @@ func (s *State) SchemaHistory(ctx context.Context, schema string) ([]HistoryEntr
 	for rows.Next() {
 		var name, rawMigration string
 		var createdAt time.Time
+		var hash sql.NullString
+		var rawSchema []byte
 
-		if err := rows.Scan(&name, &rawMigration, &createdAt); err != nil {
+		if err := rows.Scan(&name, &rawMigration, &createdAt, &hash, &rawSchema); err != nil {
 			return nil, fmt.Errorf("row scan: %w", err)
 		}
 
@@ -64,9 +72,15 @@ func (s *State) SchemaHistory(ctx context.Context, schema string) ([]HistoryEntr
 		}
 		mig.Name = name
 
+		schemaHash, err := snapshotHash(hash, rawSchema)
+		if err != nil {
+			return nil, err
+		}
+
 		entries = append(entries, HistoryEntry{
-			Migration: mig,
-			CreatedAt: createdAt,
+			Migration:  mig,
+			CreatedAt:  createdAt,
+			SchemaHash: schemaHash,
 		})
 	}
 
@@ -77,6 +91,49 @@ func (s *State) SchemaHistory(ctx context.Context, schema string) ([]HistoryEntr
 	return entries, nil
 }
 
+// Migration returns the migration with the given name, exactly as it was
+// recorded when it was applied to the schema. ErrMigrationNotFound is returned
+// if no migration with that name has been applied.
+func (s *State) Migration(ctx context.Context, schema, name string) (*migrations.RawMigration, error) {
+	var rawMigration string
+	err := s.pgConn.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT migration FROM %s.migrations WHERE schema=$1 AND name=$2", pq.QuoteIdentifier(s.schema)),
+		schema, name).Scan(&rawMigration)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMigrationNotFound
+		}
+		return nil, err
+	}
+
+	var mig migrations.RawMigration
+	if err := json.Unmarshal([]byte(rawMigration), &mig); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal migration: %w", err)
+	}
+	mig.Name = name
+
+	return &mig, nil
+}
+
+// MigrationRun returns the time the migration with the given name was
+// started and whether it has been completed. ErrMigrationNotFound is returned
+// if no migration with that name has been applied, as is the case once a
+// migration has been rolled back.
+func (s *State) MigrationRun(ctx context.Context, schema, name string) (time.Time, bool, error) {
+	var startedAt time.Time
+	var done bool
+	err := s.pgConn.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT created_at, done FROM %s.migrations WHERE schema=$1 AND name=$2", pq.QuoteIdentifier(s.schema)),
+		schema, name).Scan(&startedAt, &done)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, false, ErrMigrationNotFound
+		}
+		return time.Time{}, false, err
+	}
+	return startedAt, done, nil
+}
+
 // LatestBaseline returns the most recent baseline migration for a schema,
 // or nil if no baseline exists
 func (s *State) LatestBaseline(ctx context.Context, schemaName string) (*BaselineMigration, error) {
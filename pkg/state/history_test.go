@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/xataio/pgroll/internal/testutils"
+	"github.com/xataio/pgroll/pkg/backfill"
 	"github.com/xataio/pgroll/pkg/migrations"
 	"github.com/xataio/pgroll/pkg/state"
 )
@@ -55,7 +56,7 @@ func TestSchemaHistoryReturnsFullSchemaHistory(t *testing.T) {
 
 		// Start and complete both migrations
 		for _, mig := range migs {
-			err := state.Start(ctx, "public", &mig)
+			err := state.Start(ctx, "public", &mig, backfill.Naming{})
 			require.NoError(t, err)
 			err = state.Complete(ctx, "public", mig.Name)
 			require.NoError(t, err)
@@ -263,3 +264,60 @@ func TestLatestBaseline(t *testing.T) {
 func ptr[T any](v T) *T {
 	return &v
 }
+
+func TestMigrationReturnsStoredMigration(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithStateAndConnectionToContainer(t, func(state *state.State, db *sql.DB) {
+		ctx := context.Background()
+		migs := []migrations.Migration{
+			{
+				Name: "01_add_table",
+				Operations: migrations.Operations{
+					&migrations.OpCreateTable{
+						Name: "users",
+						Columns: []migrations.Column{
+							{Name: "id", Type: "serial", Pk: true},
+							{Name: "username", Type: "text"},
+						},
+					},
+				},
+			},
+			{
+				Name: "02_add_column",
+				Operations: migrations.Operations{
+					&migrations.OpAddColumn{
+						Table:  "users",
+						Column: migrations.Column{Name: "email", Type: "text", Nullable: true},
+					},
+				},
+			},
+		}
+
+		for _, mig := range migs {
+			err := state.Start(ctx, "public", &mig, backfill.Naming{})
+			require.NoError(t, err)
+			err = state.Complete(ctx, "public", mig.Name)
+			require.NoError(t, err)
+		}
+
+		// Any migration in the history can be read by name
+		raw, err := state.Migration(ctx, "public", "01_add_table")
+		require.NoError(t, err)
+
+		m, err := migrations.ParseMigration(raw)
+		require.NoError(t, err)
+		assert.Equal(t, migs[0], *m)
+	})
+}
+
+func TestMigrationReturnsErrorForUnknownMigration(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithStateAndConnectionToContainer(t, func(st *state.State, db *sql.DB) {
+		ctx := context.Background()
+
+		_, err := st.Migration(ctx, "public", "01_does_not_exist")
+		require.ErrorIs(t, err, state.ErrMigrationNotFound)
+	})
+}
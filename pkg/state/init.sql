@@ -133,7 +133,8 @@ CREATE TABLE IF NOT EXISTS placeholder.migrations (
     FOREIGN KEY (schema, parent) REFERENCES placeholder.migrations (schema, name)
 );
 
--- Only one migration can be active at a time
+-- Migrations that are active at the same time are stacked: each is the parent
+-- of the next, so history stays linear
 CREATE UNIQUE INDEX IF NOT EXISTS only_one_active ON placeholder.migrations (schema, name, done)
 WHERE
     done = FALSE;
@@ -157,11 +158,47 @@ ALTER TABLE placeholder.migrations
 ALTER TABLE placeholder.migrations
     ADD CONSTRAINT migration_type_check CHECK (migration_type IN ('pgroll', 'inferred', 'baseline'));
 
+-- Update the `migration_type` column to also allow a `skipped` migration type.
+ALTER TABLE placeholder.migrations
+    DROP CONSTRAINT migration_type_check;
+
+ALTER TABLE placeholder.migrations
+    ADD CONSTRAINT migration_type_check CHECK (migration_type IN ('pgroll', 'inferred', 'baseline', 'skipped'));
+
 -- Change timestamp columns to use timestamptz
 ALTER TABLE placeholder.migrations
     ALTER COLUMN created_at SET DATA TYPE timestamptz USING created_at AT TIME ZONE 'UTC',
     ALTER COLUMN updated_at SET DATA TYPE timestamptz USING updated_at AT TIME ZONE 'UTC';
 
+-- Store a hash of the logical schema after each migration, so that the schemas
+-- of two databases can be compared without comparing the snapshots
+ALTER TABLE placeholder.migrations
+    ADD COLUMN IF NOT EXISTS schema_hash text;
+
+-- Store the prefix of the names of the objects that pgroll created for each
+-- migration, so that they are found when the migration is completed or rolled
+-- back. Migrations started before the prefix was recorded have a NULL prefix
+ALTER TABLE placeholder.migrations
+    ADD COLUMN IF NOT EXISTS internal_prefix text;
+
+-- Table to track how far the backfill of each table of an active migration has got
+CREATE TABLE IF NOT EXISTS placeholder.backfill_progress (
+    schema NAME NOT NULL,
+    migration text NOT NULL,
+    table_name text NOT NULL,
+    last_value text[],
+    done boolean NOT NULL DEFAULT FALSE,
+    updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (schema, migration, table_name),
+    FOREIGN KEY (schema, migration) REFERENCES placeholder.migrations (schema, name) ON DELETE CASCADE
+);
+
+-- Track the number of rows backfilled in each table, so that the progress of
+-- a backfill can be watched
+ALTER TABLE placeholder.backfill_progress
+    ADD COLUMN IF NOT EXISTS rows_done bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rows_total bigint NOT NULL DEFAULT 0;
+
 -- Table to track pgroll binary version
 CREATE TABLE IF NOT EXISTS placeholder.pgroll_version (
     version text NOT NULL,
@@ -169,7 +206,37 @@ CREATE TABLE IF NOT EXISTS placeholder.pgroll_version (
     PRIMARY KEY (version)
 );
 
+-- Table holding the maintenance flag of each schema, which is set while a
+-- migration is being completed with heavy locks held
+CREATE TABLE IF NOT EXISTS placeholder.maintenance (
+    schema NAME NOT NULL,
+    migration text NOT NULL,
+    pid integer NOT NULL,
+    started_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (schema)
+);
+
 -- Helper functions
+-- Is a migration of the schema being completed with heavy locks held? The flag
+-- is ignored once the session of the pgroll process that set it has gone.
+CREATE OR REPLACE FUNCTION placeholder.in_maintenance (schemaname name)
+    RETURNS boolean
+    SECURITY DEFINER
+    SET search_path = placeholder, pg_catalog, pg_temp
+    AS $$
+    SELECT
+        EXISTS (
+            SELECT
+                1
+            FROM
+                placeholder.maintenance m
+                INNER JOIN pg_catalog.pg_stat_activity a ON a.pid = m.pid
+            WHERE
+                m.schema = schemaname)
+$$
+LANGUAGE SQL
+STABLE;
+
 -- Are we in the middle of a migration?
 CREATE OR REPLACE FUNCTION placeholder.is_active_migration_period (schemaname name)
     RETURNS boolean
@@ -290,6 +357,58 @@ $$
 LANGUAGE SQL
 STABLE;
 
+-- version_before returns the name of the latest version schema created by a
+-- migration that precedes the given migration, or NULL if there is none. When
+-- migrations are stacked, this is the version schema that is no longer needed
+-- once the given migration is completed.
+CREATE OR REPLACE FUNCTION placeholder.version_before (schemaname name, migrationname text)
+    RETURNS text
+    AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT
+            p.name,
+            COALESCE(p.migration ->> 'version_schema', p.name) AS version_schema,
+            p.schema,
+            p.parent,
+            0 AS depth
+        FROM
+            placeholder.migrations m
+            JOIN placeholder.migrations p ON p.name = m.parent
+                AND p.schema = m.schema
+        WHERE
+            m.name = migrationname
+            AND m.schema = schemaname
+        UNION ALL
+        SELECT
+            m.name,
+            COALESCE(m.migration ->> 'version_schema', m.name) AS version_schema,
+            m.schema,
+            m.parent,
+            a.depth + 1
+        FROM
+            placeholder.migrations m
+            JOIN ancestors a ON m.name = a.parent
+                AND m.schema = a.schema
+)
+        SELECT
+            a.version_schema
+        FROM
+            ancestors a
+    WHERE
+        EXISTS (
+            SELECT
+                1
+            FROM
+                information_schema.schemata s
+            WHERE
+                s.schema_name = schemaname || '_' || a.version_schema)
+    ORDER BY
+        a.depth ASC
+    LIMIT 1;
+$$
+LANGUAGE SQL
+STABLE;
+
 -- latest_version returns the name of the latest version schema for a given
 -- schema name or NULL if there are no version schema.
 CREATE OR REPLACE FUNCTION latest_version (schemaname name)
@@ -308,16 +427,53 @@ CREATE OR REPLACE FUNCTION placeholder.read_schema (schemaname text)
     AS $$
 DECLARE
     tables jsonb;
+    trigger_prefixes text[];
 BEGIN
+    -- The triggers that pgroll creates are named with the prefix of the
+    -- migration that created them, so those of any migration of the schema
+    -- are left out
+    SELECT
+        array_agg(DISTINCT p.prefix || 'trigger_') INTO trigger_prefixes
+    FROM (
+        SELECT
+            '_pgroll_' AS prefix
+        UNION
+        SELECT
+            m.internal_prefix
+        FROM
+            placeholder.migrations AS m
+        WHERE
+            m.schema = schemaname
+            AND m.internal_prefix IS NOT NULL) AS p;
     SELECT
         json_build_object('name', schemaname, 'tables', (
                 SELECT
-                    COALESCE(json_object_agg(t.relname, jsonb_strip_nulls (jsonb_build_object('name', t.relname, 'oid', t.oid, 'comment', descr.description, 'columns', (
+                    COALESCE(json_object_agg(t.relname, jsonb_strip_nulls (jsonb_build_object('name', t.relname, 'oid', t.oid, 'comment', descr.description, 'replicaIdentity', jsonb_build_object('type', CASE t.relreplident
+                                WHEN 'd' THEN
+                                    'DEFAULT'
+                                WHEN 'f' THEN
+                                    'FULL'
+                                WHEN 'n' THEN
+                                    'NOTHING'
+                                WHEN 'i' THEN
+                                    'INDEX'
+                                END, 'index', (
+                                    SELECT
+                                        ri_cls.relname
+                                    FROM pg_index AS ri
+                                    JOIN pg_class AS ri_cls ON ri_cls.oid = ri.indexrelid
+                                WHERE
+                                    ri.indrelid = t.oid
+                                    AND ri.indisreplident)), 'columns', (
                                         SELECT
                                             json_object_agg(name, c)
                                     FROM (
                                         SELECT
-                                            attr.attname AS name, pg_get_expr(def.adbin, def.adrelid) AS default, NOT (attr.attnotnull
+                                            attr.attname AS name, CASE WHEN attr.attgenerated = '' THEN
+                                                pg_get_expr(def.adbin, def.adrelid)
+                                            END AS default, CASE WHEN attr.attgenerated = 's' THEN
+                                                pg_get_expr(def.adbin, def.adrelid)
+                                            END AS generated, NOT (attr.attnotnull
                                             OR tp.typtype = 'd'
                                             AND tp.typnotnull) AS nullable, CASE WHEN 'character varying'::regtype = ANY (ARRAY[attr.atttypid, tp.typelem]) THEN
                                         REPLACE(format_type(attr.atttypid, attr.atttypmod), 'character varying', 'varchar')
@@ -325,7 +481,12 @@ BEGIN
                                         REPLACE(format_type(attr.atttypid, attr.atttypmod), 'timestamp with time zone', 'timestamptz')
                                     ELSE
                                         format_type(attr.atttypid, attr.atttypmod)
-                                    END AS type, descr.description AS comment, (EXISTS (
+                                    END AS type, descr.description AS comment, CASE attr.attcompression
+                                    WHEN 'p' THEN
+                                        'pglz'
+                                    WHEN 'l' THEN
+                                        'lz4'
+                                    END AS compression, (EXISTS (
                                             SELECT
                                                 1
                                             FROM pg_constraint
@@ -380,37 +541,43 @@ BEGIN
                                 AND pg_attribute.attnum = ANY (pg_index.indkey)
                                 AND indisprimary), 'indexes', (
                                 SELECT
-                                    json_object_agg(ix_details.name, json_build_object('name', ix_details.name, 'unique', ix_details.indisunique, 'exclusion', ix_details.indisexclusion, 'columns', ix_details.columns, 'predicate', ix_details.predicate, 'method', ix_details.method, 'definition', ix_details.definition))
+                                    json_object_agg(ix_details.name, json_build_object('name', ix_details.name, 'unique', ix_details.indisunique, 'exclusion', ix_details.indisexclusion, 'columns', ix_details.columns, 'expressions', ix_details.expressions, 'predicate', ix_details.predicate, 'method', ix_details.method, 'definition', ix_details.definition))
                             FROM (
                                 SELECT
-                                    replace(reverse(split_part(reverse(pi.indexrelid::regclass::text), '.', 1)), '"', '') AS name, pi.indisunique, pi.indisexclusion, array_agg(a.attname) AS columns, pg_get_expr(pi.indpred, t.oid) AS predicate, am.amname AS method, pg_get_indexdef(pi.indexrelid) AS definition
+                                    replace(reverse(split_part(reverse(pi.indexrelid::regclass::text), '.', 1)), '"', '') AS name, pi.indisunique, pi.indisexclusion, coalesce(array_agg(a.attname) FILTER (WHERE a.attname IS NOT NULL), '{}') AS columns, (
+                                        SELECT
+                                            array_agg(pg_get_indexdef(pix.indexrelid, k + 1, TRUE) ORDER BY k)
+                                        FROM pg_index pix, generate_subscripts(pix.indkey, 1) AS k
+                                        WHERE
+                                            pix.indexrelid = pi.indexrelid
+                                            AND pix.indkey[k] = 0) AS expressions, pg_get_expr(pi.indpred, t.oid) AS predicate, am.amname AS method, pg_get_indexdef(pi.indexrelid) AS definition
                                 FROM pg_index pi
-                                JOIN pg_attribute a ON a.attrelid = pi.indrelid
+                                LEFT JOIN pg_attribute a ON a.attrelid = pi.indrelid
                                     AND a.attnum = ANY (pi.indkey)
                                 JOIN pg_class cls ON cls.oid = pi.indexrelid
                                 JOIN pg_am am ON am.oid = cls.relam
                                 WHERE
                                     indrelid = t.oid::regclass GROUP BY pi.indexrelid, pi.indisunique, pi.indpred, am.amname) AS ix_details), 'checkConstraints', (
                         SELECT
-                            json_object_agg(cc_details.conname, json_build_object('name', cc_details.conname, 'columns', cc_details.columns, 'definition', cc_details.definition, 'noInherit', cc_details.connoinherit))
+                            json_object_agg(cc_details.conname, json_build_object('name', cc_details.conname, 'columns', cc_details.columns, 'definition', cc_details.definition, 'noInherit', cc_details.connoinherit, 'notValid', NOT cc_details.convalidated))
                         FROM (
                             SELECT
-                                cc_constraint.conname, array_agg(cc_attr.attname ORDER BY cc_constraint.conkey::int[]) AS columns, pg_get_constraintdef(cc_constraint.oid) AS definition, cc_constraint.connoinherit FROM pg_constraint AS cc_constraint
+                                cc_constraint.conname, array_agg(cc_attr.attname ORDER BY cc_constraint.conkey::int[]) AS columns, pg_get_constraintdef(cc_constraint.oid) AS definition, cc_constraint.connoinherit, cc_constraint.convalidated FROM pg_constraint AS cc_constraint
                             INNER JOIN pg_attribute cc_attr ON cc_attr.attrelid = cc_constraint.conrelid
                                 AND cc_attr.attnum = ANY (cc_constraint.conkey)
                             WHERE
                                 cc_constraint.conrelid = t.oid
                                 AND cc_constraint.contype = 'c' GROUP BY cc_constraint.oid, cc_constraint.conname) AS cc_details), 'uniqueConstraints', (
                             SELECT
-                                json_object_agg(uc_details.conname, json_build_object('name', uc_details.conname, 'columns', uc_details.columns))
+                                json_object_agg(uc_details.conname, json_build_object('name', uc_details.conname, 'columns', uc_details.columns, 'deferrable', uc_details.condeferrable, 'initiallyDeferred', uc_details.condeferred))
                             FROM (
                                 SELECT
-                                    uc_constraint.conname, array_agg(uc_attr.attname ORDER BY uc_constraint.conkey::int[]) AS columns, pg_get_constraintdef(uc_constraint.oid) AS definition FROM pg_constraint AS uc_constraint
+                                    uc_constraint.conname, array_agg(uc_attr.attname ORDER BY uc_constraint.conkey::int[]) AS columns, pg_get_constraintdef(uc_constraint.oid) AS definition, uc_constraint.condeferrable, uc_constraint.condeferred FROM pg_constraint AS uc_constraint
                                 INNER JOIN pg_attribute uc_attr ON uc_attr.attrelid = uc_constraint.conrelid
                                     AND uc_attr.attnum = ANY (uc_constraint.conkey)
                                 WHERE
                                     uc_constraint.conrelid = t.oid
-                                    AND uc_constraint.contype = 'u' GROUP BY uc_constraint.oid, uc_constraint.conname) AS uc_details), 'excludeConstraints', (
+                                    AND uc_constraint.contype = 'u' GROUP BY uc_constraint.oid, uc_constraint.conname, uc_constraint.condeferrable, uc_constraint.condeferred) AS uc_details), 'excludeConstraints', (
                                 SELECT
                                     json_object_agg(xc_details.conname, json_build_object('name', xc_details.conname, 'columns', xc_details.columns, 'definition', xc_details.definition, 'predicate', xc_details.predicate, 'method', xc_details.method))
                                 FROM (
@@ -425,7 +592,7 @@ BEGIN
                                         xc_constraint.conrelid = t.oid
                                         AND xc_constraint.contype = 'x' GROUP BY xc_constraint.oid, xc_constraint.conname, pi.indpred, pi.indexrelid, am.amname) AS xc_details), 'foreignKeys', (
                                     SELECT
-                                        json_object_agg(fk_details.conname, json_build_object('name', fk_details.conname, 'columns', fk_details.columns, 'referencedTable', fk_details.referencedTable, 'referencedColumns', fk_details.referencedColumns, 'matchType', fk_details.matchType, 'onDelete', fk_details.onDelete, 'onUpdate', fk_details.onUpdate))
+                                        json_object_agg(fk_details.conname, json_build_object('name', fk_details.conname, 'columns', fk_details.columns, 'referencedTable', fk_details.referencedTable, 'referencedColumns', fk_details.referencedColumns, 'matchType', fk_details.matchType, 'onDelete', fk_details.onDelete, 'onUpdate', fk_details.onUpdate, 'deferrable', fk_details.condeferrable, 'initiallyDeferred', fk_details.condeferred, 'notValid', NOT fk_details.convalidated))
                                     FROM (
                                         SELECT
                                             fk_info.conname AS conname, fk_info.columns AS columns, fk_info.relname AS referencedTable, array_agg(ref_attr.attname ORDER BY ref_attr.attname) AS referencedColumns, CASE WHEN fk_info.confmatchtype = 'f' THEN
@@ -454,18 +621,85 @@ BEGIN
                                             'SET DEFAULT'
                                         WHEN fk_info.confupdtype = 'n' THEN
                                             'SET NULL'
-                                        END AS onUpdate FROM (
+                                        END AS onUpdate, fk_info.condeferrable, fk_info.condeferred, fk_info.convalidated FROM (
                                             SELECT
-                                                fk_constraint.conname, fk_constraint.conrelid, fk_constraint.confrelid, fk_constraint.confkey, fk_cl.relname, fk_constraint.confmatchtype, fk_constraint.confdeltype, fk_constraint.confupdtype, array_agg(fk_attr.attname ORDER BY fk_attr.attname) AS columns FROM pg_constraint AS fk_constraint
+                                                fk_constraint.conname, fk_constraint.conrelid, fk_constraint.confrelid, fk_constraint.confkey, fk_cl.relname, fk_constraint.confmatchtype, fk_constraint.confdeltype, fk_constraint.confupdtype, fk_constraint.condeferrable, fk_constraint.condeferred, fk_constraint.convalidated, array_agg(fk_attr.attname ORDER BY fk_attr.attname) AS columns FROM pg_constraint AS fk_constraint
                                             INNER JOIN pg_class fk_cl ON fk_constraint.confrelid = fk_cl.oid -- join the referenced table
                                             INNER JOIN pg_attribute fk_attr ON fk_attr.attrelid = fk_constraint.conrelid
                                                 AND fk_attr.attnum = ANY (fk_constraint.conkey) -- join the columns of the referencing table
                                             WHERE
                                                 fk_constraint.conrelid = t.oid
-                                                AND fk_constraint.contype = 'f' GROUP BY fk_constraint.conrelid, fk_constraint.conname, fk_constraint.confrelid, fk_cl.relname, fk_constraint.confkey, fk_constraint.confmatchtype, fk_constraint.confdeltype, fk_constraint.confupdtype) AS fk_info
+                                                AND fk_constraint.contype = 'f' GROUP BY fk_constraint.conrelid, fk_constraint.conname, fk_constraint.confrelid, fk_cl.relname, fk_constraint.confkey, fk_constraint.confmatchtype, fk_constraint.confdeltype, fk_constraint.confupdtype, fk_constraint.condeferrable, fk_constraint.condeferred, fk_constraint.convalidated) AS fk_info
                                             INNER JOIN pg_attribute ref_attr ON ref_attr.attrelid = fk_info.confrelid
                                                 AND ref_attr.attnum = ANY (fk_info.confkey) -- join the columns of the referenced table
-                                        GROUP BY fk_info.conname, fk_info.conrelid, fk_info.columns, fk_info.confrelid, fk_info.confmatchtype, fk_info.confdeltype, fk_info.confupdtype, fk_info.relname) AS fk_details)))), '{}'::json)
+                                        GROUP BY fk_info.conname, fk_info.conrelid, fk_info.columns, fk_info.confrelid, fk_info.confmatchtype, fk_info.confdeltype, fk_info.confupdtype, fk_info.relname, fk_info.condeferrable, fk_info.condeferred, fk_info.convalidated) AS fk_details), 'triggers', (
+                                        SELECT
+                                            json_object_agg(tg.tgname, json_build_object('name', tg.tgname, 'state', CASE tg.tgenabled
+                                                    WHEN 'O' THEN
+                                                        'ENABLE'
+                                                    WHEN 'D' THEN
+                                                        'DISABLE'
+                                                    WHEN 'R' THEN
+                                                        'ENABLE REPLICA'
+                                                    WHEN 'A' THEN
+                                                        'ENABLE ALWAYS'
+                                                    END))
+                                        FROM pg_trigger AS tg
+                                        WHERE
+                                            tg.tgrelid = t.oid
+                                            AND NOT tg.tgisinternal
+                                            AND NOT EXISTS (
+                                                SELECT
+                                                    1
+                                                FROM
+                                                    unnest(trigger_prefixes) AS tp (prefix)
+                                                WHERE
+                                                    left(tg.tgname, length(tp.prefix)) = tp.prefix)), 'rules', (
+                                        SELECT
+                                            json_object_agg(rw.rulename, json_build_object('name', rw.rulename, 'definition', pg_get_ruledef(rw.oid), 'state', CASE rw.ev_enabled
+                                                    WHEN 'O' THEN
+                                                        'ENABLE'
+                                                    WHEN 'D' THEN
+                                                        'DISABLE'
+                                                    WHEN 'R' THEN
+                                                        'ENABLE REPLICA'
+                                                    WHEN 'A' THEN
+                                                        'ENABLE ALWAYS'
+                                                    END))
+                                        FROM pg_rewrite AS rw
+                                        WHERE
+                                            rw.ev_class = t.oid), 'inherits', (
+                                        SELECT
+                                            json_agg(parent.relname ORDER BY inh.inhseqno)
+                                        FROM pg_inherits AS inh
+                                        INNER JOIN pg_class AS parent ON inh.inhparent = parent.oid
+                                        WHERE
+                                            inh.inhrelid = t.oid
+                                            AND parent.relkind = 'r'), 'partitionBy', (
+                                        SELECT
+                                            json_build_object('type', CASE pt.partstrat
+                                                WHEN 'r' THEN
+                                                    'range'
+                                                WHEN 'l' THEN
+                                                    'list'
+                                                WHEN 'h' THEN
+                                                    'hash'
+                                                END, 'columns', (
+                                                SELECT
+                                                    json_agg(pk_attr.attname ORDER BY k)
+                                                FROM generate_subscripts(pt.partattrs, 1) AS k
+                                                INNER JOIN pg_attribute AS pk_attr ON pk_attr.attrelid = pt.partrelid
+                                                    AND pk_attr.attnum = pt.partattrs[k]), 'expression', pg_get_expr(pt.partexprs, pt.partrelid))
+                                        FROM pg_partitioned_table AS pt
+                                    WHERE
+                                        pt.partrelid = t.oid), 'partitionOf', (
+                                    SELECT
+                                        parent.relname
+                                    FROM pg_inherits AS inh
+                                    INNER JOIN pg_class AS parent ON inh.inhparent = parent.oid
+                                WHERE
+                                    t.relispartition
+                                    AND inh.inhrelid = t.oid)))), '{}'::json)
                     FROM pg_class AS t
                     INNER JOIN pg_namespace AS ns ON t.relnamespace = ns.oid
                     LEFT JOIN pg_description AS descr ON t.oid = descr.objoid
@@ -63,3 +63,17 @@ func (s *State) PreviousMigration(ctx context.Context, schema string) (*string,
 
 	return parent, nil
 }
+
+// VersionBefore returns the name of the latest version schema created by a
+// migration that precedes the migration `name`, or nil if there is none.
+func (s *State) VersionBefore(ctx context.Context, schema, name string) (*string, error) {
+	var version *string
+	err := s.pgConn.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT %s.version_before($1, $2)", pq.QuoteIdentifier(s.schema)),
+		schema, name).Scan(&version)
+	if err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
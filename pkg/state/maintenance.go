@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package state
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Queryer runs a query that returns at most one row. It is implemented by
+// *sql.DB, *sql.Conn and *sql.Tx.
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// InMaintenance returns true while a migration of `schemaName` is being
+// completed in maintenance mode, in which pgroll takes locks that block the
+// application's queries on the tables the migration changes. Applications
+// can poll it to back off, or to retry their queries later, while the flag
+// is set.
+//
+// `stateSchema` is the schema in which pgroll keeps its state, "pgroll" by
+// default. The flag is read with the state schema's `in_maintenance`
+// function, so the role of the application needs USAGE on the state schema
+// and EXECUTE on the function, but no access to the state's tables.
+func InMaintenance(ctx context.Context, q Queryer, stateSchema, schemaName string) (bool, error) {
+	var inMaintenance bool
+	err := q.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT %s.in_maintenance($1)", pq.QuoteIdentifier(stateSchema)),
+		schemaName).Scan(&inMaintenance)
+	if err != nil {
+		return false, fmt.Errorf("failed to check maintenance flag: %w", err)
+	}
+	return inMaintenance, nil
+}
+
+// InMaintenance returns true while a migration of `schemaName` is being
+// completed in maintenance mode.
+func (s *State) InMaintenance(ctx context.Context, schemaName string) (bool, error) {
+	return InMaintenance(ctx, s.pgConn, s.schema, schemaName)
+}
+
+// StartMaintenance sets the maintenance flag of `schemaName` while
+// `migration` is being completed. The flag is tied to the session of
+// pgroll with backend process ID `pid`: it is ignored once that session has
+// ended, so that a pgroll process that fails before clearing the flag doesn't
+// leave it set.
+func (s *State) StartMaintenance(ctx context.Context, schemaName, migration string, pid int) error {
+	_, err := s.pgConn.ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO %s.maintenance (schema, migration, pid)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (schema)
+			DO UPDATE SET migration = EXCLUDED.migration,
+				pid = EXCLUDED.pid,
+				started_at = CURRENT_TIMESTAMP`, pq.QuoteIdentifier(s.schema)),
+		schemaName, migration, pid)
+	if err != nil {
+		return fmt.Errorf("failed to set maintenance flag: %w", err)
+	}
+	return nil
+}
+
+// EndMaintenance clears the maintenance flag of `schemaName`.
+func (s *State) EndMaintenance(ctx context.Context, schemaName string) error {
+	_, err := s.pgConn.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s.maintenance WHERE schema = $1", pq.QuoteIdentifier(s.schema)),
+		schemaName)
+	if err != nil {
+		return fmt.Errorf("failed to clear maintenance flag: %w", err)
+	}
+	return nil
+}
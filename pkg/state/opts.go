@@ -2,6 +2,8 @@
 
 package state
 
+import "time"
+
 type StateOpt func(s *State)
 
 // WithPgrollVersion sets the version of `pgroll` that is constructing the State
@@ -11,3 +13,13 @@ func WithPgrollVersion(version string) StateOpt {
 		s.pgrollVersion = version
 	}
 }
+
+// WithConnectionAttempts sets the number of attempts made to connect to the
+// database, and the initial delay between attempts. Subsequent delays increase
+// exponentially.
+func WithConnectionAttempts(attempts int, delay time.Duration) StateOpt {
+	return func(s *State) {
+		s.connectionAttempts = attempts
+		s.connectionRetryDelay = delay
+	}
+}
@@ -10,9 +10,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 
+	"github.com/xataio/pgroll/pkg/backfill"
+	"github.com/xataio/pgroll/pkg/db"
 	"github.com/xataio/pgroll/pkg/migrations"
 	"github.com/xataio/pgroll/pkg/schema"
 )
@@ -28,6 +31,13 @@ type State struct {
 	pgConn        *sql.DB
 	pgrollVersion string
 	schema        string
+
+	connectionAttempts   int
+	connectionRetryDelay time.Duration
+
+	// whether pgConn is a pool owned by the caller, which is left open by
+	// Close
+	sharedConn bool
 }
 
 func New(ctx context.Context, pgURL, stateSchema string, opts ...StateOpt) (*State, error) {
@@ -38,12 +48,23 @@ func New(ctx context.Context, pgURL, stateSchema string, opts ...StateOpt) (*Sta
 
 	dsn += fmt.Sprintf(" search_path=%s application_name=%s", stateSchema, applicationName)
 
+	st := &State{
+		pgrollVersion:      "development",
+		schema:             stateSchema,
+		connectionAttempts: 1,
+	}
+
+	// Apply options to the State instance
+	for _, opt := range opts {
+		opt(st)
+	}
+
 	conn, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := db.PingWithRetry(ctx, conn, st.connectionAttempts, st.connectionRetryDelay); err != nil {
 		return nil, err
 	}
 
@@ -52,38 +73,64 @@ func New(ctx context.Context, pgURL, stateSchema string, opts ...StateOpt) (*Sta
 		return nil, fmt.Errorf("unable to set pgroll.no_inferred_migrations to true: %w", err)
 	}
 
+	st.pgConn = conn
+
+	if err := st.checkVersionCompatibility(ctx); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// NewFromDB creates a new State instance that keeps pgroll's state in
+// `stateSchema` using connections from `pool`, which is owned by the caller
+// and is left open by Close.
+func NewFromDB(ctx context.Context, pool *sql.DB, stateSchema string, opts ...StateOpt) (*State, error) {
 	st := &State{
-		pgConn:        conn,
-		pgrollVersion: "development",
-		schema:        stateSchema,
+		pgrollVersion:      "development",
+		schema:             stateSchema,
+		connectionAttempts: 1,
+		sharedConn:         true,
 	}
 
-	// Apply options to the State instance
 	for _, opt := range opts {
 		opt(st)
 	}
 
-	// Check version compatibility between the pgroll version and the version of
-	// the pgroll state schema.
-	compat, err := st.VersionCompatibility(ctx)
-	if err != nil {
+	if err := db.PingWithRetry(ctx, pool, st.connectionAttempts, st.connectionRetryDelay); err != nil {
 		return nil, err
 	}
 
+	st.pgConn = pool
+
+	if err := st.checkVersionCompatibility(ctx); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// checkVersionCompatibility checks the version compatibility between the
+// pgroll version and the version of the pgroll state schema, re-initializing
+// the state schema if it is older.
+func (s *State) checkVersionCompatibility(ctx context.Context) error {
+	compat, err := s.VersionCompatibility(ctx)
+	if err != nil {
+		return err
+	}
+
 	// If the state schema is newer than the pgroll version, return an error
 	if compat == VersionCompatVersionSchemaNewer {
-		return nil, ErrNewPgrollSchema
+		return ErrNewPgrollSchema
 	}
 
 	// if the state schema is older than the pgroll version, re-initialize the
 	// state schema
 	if compat == VersionCompatVersionSchemaOlder {
-		if err := st.Init(ctx); err != nil {
-			return nil, err
-		}
+		return s.Init(ctx)
 	}
 
-	return st, nil
+	return nil
 }
 
 // Init initializes the required pg_roll schema to store the state
@@ -103,6 +150,13 @@ func (s *State) Init(ctx context.Context) error {
 		return err
 	}
 
+	// The connection may be taken from a pool whose sessions don't ignore
+	// schema changes made by pgroll
+	_, err = tx.ExecContext(ctx, "SET LOCAL pgroll.no_inferred_migrations TO 'TRUE'")
+	if err != nil {
+		return err
+	}
+
 	// Perform pgroll state initialization
 	q := strings.ReplaceAll(sqlInit, "placeholder", pq.QuoteIdentifier(s.schema))
 	_, err = tx.ExecContext(ctx, q)
@@ -147,6 +201,9 @@ func (s *State) IsInitialized(ctx context.Context) (bool, error) {
 }
 
 func (s *State) Close() error {
+	if s.sharedConn {
+		return nil
+	}
 	return s.pgConn.Close()
 }
 
@@ -203,32 +260,68 @@ func (s *State) IsActiveMigrationPeriod(ctx context.Context, schema string) (boo
 	return isActive, nil
 }
 
-// GetActiveMigration returns the name & raw content of the active migration (if any), errors out otherwise
+// GetActiveMigration returns the name & raw content of the active migration (if any), errors out otherwise.
+// If migrations are stacked, the oldest active migration is returned, as it is the next to be completed.
 func (s *State) GetActiveMigration(ctx context.Context, schema string) (*migrations.Migration, error) {
-	var name, rawMigration string
-	err := s.pgConn.QueryRowContext(ctx, fmt.Sprintf("SELECT name, migration FROM %s.migrations WHERE schema=$1 AND done=false", pq.QuoteIdentifier(s.schema)), schema).Scan(&name, &rawMigration)
+	active, err := s.ActiveMigrations(ctx, schema)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoActiveMigration
-		}
 		return nil, err
 	}
+	if len(active) == 0 {
+		return nil, ErrNoActiveMigration
+	}
+	return active[0], nil
+}
 
-	var migration migrations.Migration
-	err = json.Unmarshal([]byte(rawMigration), &migration)
+// ActiveMigrations returns the migrations that have been started but not
+// completed, oldest first. More than one migration is active only when
+// migrations are stacked, in which case each is the parent of the next.
+func (s *State) ActiveMigrations(ctx context.Context, schema string) ([]*migrations.Migration, error) {
+	rows, err := s.pgConn.QueryContext(ctx, fmt.Sprintf(`WITH RECURSIVE active AS (
+			SELECT c.name, c.migration, 0 AS depth
+			FROM %[1]s.migrations c
+			WHERE c.schema = $1 AND NOT c.done
+				AND NOT EXISTS (
+					SELECT 1 FROM %[1]s.migrations p
+					WHERE p.schema = c.schema AND p.name = c.parent AND NOT p.done)
+			UNION ALL
+			SELECT m.name, m.migration, a.depth + 1
+			FROM %[1]s.migrations m
+			JOIN active a ON m.parent = a.name
+			WHERE m.schema = $1 AND NOT m.done
+		)
+		SELECT name, migration FROM active ORDER BY depth`, pq.QuoteIdentifier(s.schema)), schema)
 	if err != nil {
-		return nil, fmt.Errorf("unable to unmarshal migration: %w", err)
+		return nil, err
 	}
-	migration.Name = name
+	defer rows.Close()
 
-	return &migration, nil
+	var active []*migrations.Migration
+	for rows.Next() {
+		var name, rawMigration string
+		if err := rows.Scan(&name, &rawMigration); err != nil {
+			return nil, err
+		}
+
+		var migration migrations.Migration
+		if err := json.Unmarshal([]byte(rawMigration), &migration); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal migration: %w", err)
+		}
+		migration.Name = name
+		active = append(active, &migration)
+	}
+
+	return active, rows.Err()
 }
 
 // Start creates a new migration, storing its name and raw content
 // this will effectively activate a new migration period, so `IsActiveMigrationPeriod` will return true
 // until the migration is completed
 // This method will return the current schema (before the migration is applied)
-func (s *State) Start(ctx context.Context, schemaname string, migration *migrations.Migration) error {
+// The prefix of `naming` is recorded with the migration, so that the objects
+// the migration creates for pgroll's own use are found by the same names when
+// it is completed or rolled back.
+func (s *State) Start(ctx context.Context, schemaname string, migration *migrations.Migration, naming backfill.Naming) error {
 	rawMigration, err := json.Marshal(migration)
 	if err != nil {
 		return fmt.Errorf("unable to marshal migration: %w", err)
@@ -236,29 +329,73 @@ func (s *State) Start(ctx context.Context, schemaname string, migration *migrati
 
 	// create a new migration object and return the previous known schema
 	// if there is no previous migration, read the schema from postgres
-	stmt := fmt.Sprintf(`INSERT INTO %[1]s.migrations (schema, name, parent, migration) VALUES ($1, $2, %[1]s.latest_migration($1), $3)`,
+	stmt := fmt.Sprintf(`INSERT INTO %[1]s.migrations (schema, name, parent, migration, internal_prefix) VALUES ($1, $2, %[1]s.latest_migration($1), $3, $4)`,
 		pq.QuoteIdentifier(s.schema))
 
-	_, err = s.pgConn.ExecContext(ctx, stmt, schemaname, migration.Name, rawMigration)
+	_, err = s.pgConn.ExecContext(ctx, stmt, schemaname, migration.Name, rawMigration, naming.Prefix())
 	return err
 }
 
-// Complete marks a migration as completed
-func (s *State) Complete(ctx context.Context, schema, name string) error {
-	res, err := s.pgConn.ExecContext(ctx, fmt.Sprintf("UPDATE %[1]s.migrations SET done=$1, resulting_schema=(SELECT %[1]s.read_schema($2)) WHERE schema=$2 AND name=$3 AND done=$4", pq.QuoteIdentifier(s.schema)), true, schema, name, false)
+// Naming returns the naming of the objects created by the given migration for
+// pgroll's own use. Migrations started by versions of pgroll that didn't
+// record an internal prefix use backfill.LegacyNaming.
+func (s *State) Naming(ctx context.Context, schema, name string) (backfill.Naming, error) {
+	var prefix sql.NullString
+	err := s.pgConn.QueryRowContext(ctx, fmt.Sprintf("SELECT internal_prefix FROM %s.migrations WHERE schema=$1 AND name=$2", pq.QuoteIdentifier(s.schema)), schema, name).Scan(&prefix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return backfill.Naming{}, fmt.Errorf("no migration found with name %s", name)
+	}
 	if err != nil {
-		return err
+		return backfill.Naming{}, err
 	}
 
-	rows, err := res.RowsAffected()
+	if !prefix.Valid {
+		return backfill.LegacyNaming(), nil
+	}
+	return backfill.NewNaming(prefix.String), nil
+}
+
+// InternalPrefixes returns the prefixes recorded with the migrations of the
+// given schema, along with backfill.DefaultPrefix, which is the prefix of
+// migrations that didn't record one.
+func (s *State) InternalPrefixes(ctx context.Context, schema string) ([]string, error) {
+	rows, err := s.pgConn.QueryContext(ctx, fmt.Sprintf(`SELECT $2 UNION
+		SELECT internal_prefix FROM %s.migrations WHERE schema=$1 AND internal_prefix IS NOT NULL`,
+		pq.QuoteIdentifier(s.schema)), schema, backfill.DefaultPrefix)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	if rows == 0 {
+	var prefixes []string
+	for rows.Next() {
+		var prefix string
+		if err := rows.Scan(&prefix); err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, rows.Err()
+}
+
+// Complete marks a migration as completed
+func (s *State) Complete(ctx context.Context, schema, name string) error {
+	var rawSchema []byte
+	err := s.pgConn.QueryRowContext(ctx, fmt.Sprintf("UPDATE %[1]s.migrations SET done=$1, resulting_schema=(SELECT %[1]s.read_schema($2)) WHERE schema=$2 AND name=$3 AND done=$4 RETURNING resulting_schema", pq.QuoteIdentifier(s.schema)), true, schema, name, false).Scan(&rawSchema)
+	if errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("no migration found with name %s", name)
 	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.storeSchemaHash(ctx, schema, name, rawSchema); err != nil {
+		return err
+	}
 
+	// The progress of the migration's backfills is only needed to resume them
+	_, err = s.pgConn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s.backfill_progress WHERE schema=$1 AND migration=$2", pq.QuoteIdentifier(s.schema)), schema, name)
 	return err
 }
 
@@ -279,6 +416,29 @@ func (s *State) ReadSchema(ctx context.Context, schemaName string) (*schema.Sche
 	return &sc, nil
 }
 
+// ReadSchemaWith reads the schema for the specified schema name over `conn`
+// rather than the state connection, so that the schema includes the changes
+// made by a transaction open on `conn`.
+func (s *State) ReadSchemaWith(ctx context.Context, conn db.DB, schemaName string) (*schema.Schema, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT %s.read_schema($1)", pq.QuoteIdentifier(s.schema)), schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rawSchema []byte
+	if err := db.ScanFirstValue(rows, &rawSchema); err != nil {
+		return nil, err
+	}
+
+	var sc schema.Schema
+	if err := json.Unmarshal(rawSchema, &sc); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal schema: %w", err)
+	}
+
+	return &sc, nil
+}
+
 // SchemaAfterMigration reads the schema after the migration `version` was
 // applied to `schemaName`
 func (s *State) SchemaAfterMigration(ctx context.Context, schemaName, version string) (*schema.Schema, error) {
@@ -299,6 +459,57 @@ func (s *State) SchemaAfterMigration(ctx context.Context, schemaName, version st
 	return &sc, nil
 }
 
+// SchemaBeforeMigration reads the schema recorded after the parent of the
+// migration `name` was applied to `schemaName`, or returns an empty schema if
+// the migration has no parent.
+func (s *State) SchemaBeforeMigration(ctx context.Context, schemaName, name string) (*schema.Schema, error) {
+	query := fmt.Sprintf(`SELECT p.resulting_schema FROM %[1]s.migrations m
+		LEFT JOIN %[1]s.migrations p ON p.schema = m.schema AND p.name = m.parent
+		WHERE m.schema=$1 AND m.name=$2`, pq.QuoteIdentifier(s.schema))
+
+	var rawSchema []byte
+	err := s.pgConn.QueryRowContext(ctx, query, schemaName, name).Scan(&rawSchema)
+	if err != nil {
+		return nil, err
+	}
+	if rawSchema == nil {
+		return schema.New(), nil
+	}
+
+	var sc schema.Schema
+	err = json.Unmarshal(rawSchema, &sc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal schema: %w", err)
+	}
+
+	return &sc, nil
+}
+
+// LatestSchema reads the schema recorded after the latest completed migration
+// applied to `schemaName`, or returns nil if no migration has been completed.
+func (s *State) LatestSchema(ctx context.Context, schemaName string) (*schema.Schema, error) {
+	query := fmt.Sprintf(`SELECT resulting_schema FROM %s.migrations
+		WHERE schema=$1 AND done AND resulting_schema IS NOT NULL
+		ORDER BY created_at DESC LIMIT 1`, pq.QuoteIdentifier(s.schema))
+
+	var rawSchema []byte
+	err := s.pgConn.QueryRowContext(ctx, query, schemaName).Scan(&rawSchema)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sc schema.Schema
+	err = json.Unmarshal(rawSchema, &sc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal schema: %w", err)
+	}
+
+	return &sc, nil
+}
+
 // Rollback removes a migration from the state (we consider it rolled back, as if it never started)
 func (s *State) Rollback(ctx context.Context, schema, name string) error {
 	res, err := s.pgConn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s.migrations WHERE schema=$1 AND name=$2 AND done=$3", pq.QuoteIdentifier(s.schema)), schema, name, false)
@@ -318,6 +529,40 @@ func (s *State) Rollback(ctx context.Context, schema, name string) error {
 	return nil
 }
 
+// Skip records a migration that is not applied, marking it as 'skipped' and
+// completed (done=true). The migration makes no changes to the schema, but
+// it is part of the schema history, so that the order of the migrations in
+// the history still matches the order of the migration files.
+func (s *State) Skip(ctx context.Context, schemaName string, migration *migrations.Migration) error {
+	isActive, err := s.IsActiveMigrationPeriod(ctx, schemaName)
+	if err != nil {
+		return fmt.Errorf("failed to check for active migrations: %w", err)
+	}
+	if isActive {
+		return fmt.Errorf("cannot skip migration %q while a migration is in progress", migration.Name)
+	}
+
+	rawMigration, err := json.Marshal(migration)
+	if err != nil {
+		return fmt.Errorf("unable to marshal migration: %w", err)
+	}
+
+	stmt := fmt.Sprintf(`
+		INSERT INTO %[1]s.migrations
+		(schema, name, migration, resulting_schema, done, parent, migration_type, created_at, updated_at)
+		VALUES ($1, $2, $3, %[1]s.read_schema($1), TRUE, %[1]s.latest_migration($1), 'skipped', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING resulting_schema`,
+		pq.QuoteIdentifier(s.schema))
+
+	var rawSchema []byte
+	err = s.pgConn.QueryRowContext(ctx, stmt, schemaName, migration.Name, rawMigration).Scan(&rawSchema)
+	if err != nil {
+		return fmt.Errorf("failed to insert skipped migration: %w", err)
+	}
+
+	return s.storeSchemaHash(ctx, schemaName, migration.Name, rawSchema)
+}
+
 // CreateBaseline creates a baseline migration that captures the current state of the schema.
 // It marks the migration as 'baseline' type and completed (done=true).
 // This is used when you want to start using pgroll with an existing database.
@@ -353,14 +598,19 @@ func (s *State) CreateBaseline(ctx context.Context, schemaName, baselineVersion
 		return fmt.Errorf("unable to marshal schema: %w", err)
 	}
 
+	hash, err := schema.Hash()
+	if err != nil {
+		return fmt.Errorf("unable to hash schema: %w", err)
+	}
+
 	// Insert a baseline migration record
 	stmt := fmt.Sprintf(`
 		INSERT INTO %[1]s.migrations 
-		(schema, name, migration, resulting_schema, done, parent, migration_type, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, TRUE,  %[1]s.latest_migration($1), 'baseline', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		(schema, name, migration, resulting_schema, schema_hash, done, parent, migration_type, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE,  %[1]s.latest_migration($1), 'baseline', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
 		pq.QuoteIdentifier(s.schema))
 
-	_, err = s.pgConn.ExecContext(ctx, stmt, schemaName, baselineVersion, rawMigration, rawSchema)
+	_, err = s.pgConn.ExecContext(ctx, stmt, schemaName, baselineVersion, rawMigration, rawSchema, hash)
 	if err != nil {
 		return fmt.Errorf("failed to insert baseline migration: %w", err)
 	}
@@ -56,7 +56,7 @@ func TestSchemaOptionIsRespected(t *testing.T) {
 					},
 				},
 			},
-		})
+		}, backfill.Naming{})
 		assert.NoError(t, err)
 	})
 }
@@ -474,7 +474,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 						},
 					},
 				},
@@ -486,7 +487,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -506,7 +508,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -542,7 +545,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -577,7 +581,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -599,7 +604,8 @@ func TestReadSchema(t *testing.T) {
 							},
 						},
 						"table2": {
-							Name: "table2",
+							Name:            "table2",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"fk": {
 									Name:         "fk",
@@ -630,7 +636,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -652,7 +659,8 @@ func TestReadSchema(t *testing.T) {
 							},
 						},
 						"table2": {
-							Name: "table2",
+							Name:            "table2",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"fk": {
 									Name:         "fk",
@@ -676,6 +684,62 @@ func TestReadSchema(t *testing.T) {
 					},
 				},
 			},
+			{
+				name:       "deferrable foreign key",
+				createStmt: "CREATE TABLE public.table1 (id int PRIMARY KEY); CREATE TABLE public.table2 (fk int NOT NULL, CONSTRAINT fk_fkey FOREIGN KEY (fk) REFERENCES public.table1 (id) DEFERRABLE)",
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     false,
+									Unique:       true,
+									PostgresType: "base",
+								},
+							},
+							PrimaryKey: []string{"id"},
+							Indexes: map[string]*schema.Index{
+								"table1_pkey": {
+									Name:       "table1_pkey",
+									Unique:     true,
+									Columns:    []string{"id"},
+									Method:     string(migrations.OpCreateIndexMethodBtree),
+									Definition: "CREATE UNIQUE INDEX table1_pkey ON public.table1 USING btree (id)",
+								},
+							},
+						},
+						"table2": {
+							Name:            "table2",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"fk": {
+									Name:         "fk",
+									Type:         "integer",
+									Nullable:     false,
+									PostgresType: "base",
+								},
+							},
+							ForeignKeys: map[string]*schema.ForeignKey{
+								"fk_fkey": {
+									Name:              "fk_fkey",
+									Columns:           []string{"fk"},
+									ReferencedTable:   "table1",
+									ReferencedColumns: []string{"id"},
+									MatchType:         "SIMPLE",
+									OnDelete:          "NO ACTION",
+									OnUpdate:          "NO ACTION",
+									Deferrable:        true,
+								},
+							},
+						},
+					},
+				},
+			},
 			{
 				name:       "foreign key with ON DELETE CASCADE ON UPDATE CASCADE",
 				createStmt: "CREATE TABLE public.table1 (id int PRIMARY KEY); CREATE TABLE public.table2 (fk int NOT NULL, CONSTRAINT fk_fkey FOREIGN KEY (fk) REFERENCES public.table1 (id) ON DELETE CASCADE ON UPDATE CASCADE)",
@@ -683,7 +747,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -705,7 +770,8 @@ func TestReadSchema(t *testing.T) {
 							},
 						},
 						"table2": {
-							Name: "table2",
+							Name:            "table2",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"fk": {
 									Name:         "fk",
@@ -736,7 +802,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -758,7 +825,8 @@ func TestReadSchema(t *testing.T) {
 							},
 						},
 						"table2": {
-							Name: "table2",
+							Name:            "table2",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"fk": {
 									Name:         "fk",
@@ -789,7 +857,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -834,7 +903,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"age": {
 									Name:         "age",
@@ -855,6 +925,91 @@ func TestReadSchema(t *testing.T) {
 					},
 				},
 			},
+			{
+				name:       "not valid check constraint",
+				createStmt: "CREATE TABLE public.table1 (age INTEGER); ALTER TABLE public.table1 ADD CONSTRAINT age_check CHECK (age > 18) NOT VALID;",
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"age": {
+									Name:         "age",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+							CheckConstraints: map[string]*schema.CheckConstraint{
+								"age_check": {
+									Name:       "age_check",
+									Columns:    []string{"age"},
+									Definition: "CHECK ((age > 18)) NOT VALID",
+									NotValid:   true,
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				name:       "not valid foreign key",
+				createStmt: "CREATE TABLE public.table1 (id int PRIMARY KEY); CREATE TABLE public.table2 (fk int NOT NULL); ALTER TABLE public.table2 ADD CONSTRAINT fk_fkey FOREIGN KEY (fk) REFERENCES public.table1 (id) NOT VALID;",
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     false,
+									Unique:       true,
+									PostgresType: "base",
+								},
+							},
+							PrimaryKey: []string{"id"},
+							Indexes: map[string]*schema.Index{
+								"table1_pkey": {
+									Name:       "table1_pkey",
+									Unique:     true,
+									Columns:    []string{"id"},
+									Method:     string(migrations.OpCreateIndexMethodBtree),
+									Definition: "CREATE UNIQUE INDEX table1_pkey ON public.table1 USING btree (id)",
+								},
+							},
+						},
+						"table2": {
+							Name:            "table2",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"fk": {
+									Name:         "fk",
+									Type:         "integer",
+									Nullable:     false,
+									PostgresType: "base",
+								},
+							},
+							ForeignKeys: map[string]*schema.ForeignKey{
+								"fk_fkey": {
+									Name:              "fk_fkey",
+									Columns:           []string{"fk"},
+									ReferencedTable:   "table1",
+									ReferencedColumns: []string{"id"},
+									MatchType:         "SIMPLE",
+									OnDelete:          "NO ACTION",
+									OnUpdate:          "NO ACTION",
+									NotValid:          true,
+								},
+							},
+						},
+					},
+				},
+			},
 			{
 				name:       "unique constraint",
 				createStmt: "CREATE TABLE public.table1 (id int PRIMARY KEY, name TEXT, CONSTRAINT name_unique UNIQUE(name) );",
@@ -862,7 +1017,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -913,7 +1069,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -957,6 +1114,60 @@ func TestReadSchema(t *testing.T) {
 					},
 				},
 			},
+			{
+				name:       "deferrable unique constraint",
+				createStmt: "CREATE TABLE public.table1 (id int PRIMARY KEY, name TEXT, CONSTRAINT name_id_unique UNIQUE(id, name) DEFERRABLE INITIALLY DEFERRED);",
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     false,
+									Unique:       true,
+									PostgresType: "base",
+								},
+								"name": {
+									Name:         "name",
+									Type:         "text",
+									Nullable:     true,
+									Unique:       false,
+									PostgresType: "base",
+								},
+							},
+							PrimaryKey: []string{"id"},
+							Indexes: map[string]*schema.Index{
+								"table1_pkey": {
+									Name:       "table1_pkey",
+									Unique:     true,
+									Columns:    []string{"id"},
+									Method:     string(migrations.OpCreateIndexMethodBtree),
+									Definition: "CREATE UNIQUE INDEX table1_pkey ON public.table1 USING btree (id)",
+								},
+								"name_id_unique": {
+									Name:       "name_id_unique",
+									Unique:     true,
+									Columns:    []string{"id", "name"},
+									Method:     string(migrations.OpCreateIndexMethodBtree),
+									Definition: "CREATE UNIQUE INDEX name_id_unique ON public.table1 USING btree (id, name)",
+								},
+							},
+							UniqueConstraints: map[string]*schema.UniqueConstraint{
+								"name_id_unique": {
+									Name:              "name_id_unique",
+									Columns:           []string{"id", "name"},
+									Deferrable:        true,
+									InitiallyDeferred: true,
+								},
+							},
+						},
+					},
+				},
+			},
 			{
 				name:       "exclusion constraint",
 				createStmt: "CREATE TABLE public.table1 (name TEXT, CONSTRAINT name_unique EXCLUDE USING btree (name WITH =));",
@@ -964,7 +1175,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"name": {
 									Name:         "name",
@@ -1010,7 +1222,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"products": {
-							Name: "products",
+							Name:            "products",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"customer_id": {
 									Name:         "customer_id",
@@ -1037,7 +1250,8 @@ func TestReadSchema(t *testing.T) {
 							},
 						},
 						"orders": {
-							Name: "orders",
+							Name:            "orders",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"customer_id": {
 									Name:         "customer_id",
@@ -1082,7 +1296,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"products": {
-							Name: "products",
+							Name:            "products",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"customer_id": {
 									Name:         "customer_id",
@@ -1109,7 +1324,8 @@ func TestReadSchema(t *testing.T) {
 							},
 						},
 						"orders": {
-							Name: "orders",
+							Name:            "orders",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"customer_id": {
 									Name:         "customer_id",
@@ -1146,7 +1362,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"a": {
 									Name:         "a",
@@ -1174,6 +1391,43 @@ func TestReadSchema(t *testing.T) {
 					},
 				},
 			},
+			{
+				name:       "expression index",
+				createStmt: "CREATE TABLE public.table1 (a text, b jsonb); CREATE INDEX idx_expr ON public.table1 (lower(a), (b->>'key'), a);",
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"a": {
+									Name:         "a",
+									Type:         "text",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+								"b": {
+									Name:         "b",
+									Type:         "jsonb",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+							Indexes: map[string]*schema.Index{
+								"idx_expr": {
+									Name:        "idx_expr",
+									Unique:      false,
+									Columns:     []string{"a"},
+									Expressions: []string{"lower(a)", "(b ->> 'key'::text)"},
+									Method:      string(migrations.OpCreateIndexMethodBtree),
+									Definition:  "CREATE INDEX idx_expr ON public.table1 USING btree (lower(a), ((b ->> 'key'::text)), a)",
+								},
+							},
+						},
+					},
+				},
+			},
 			{
 				name:       "column whose type is a UDT in another schema should have the type prefixed with the schema",
 				createStmt: "CREATE DOMAIN email_type AS varchar(255); CREATE TABLE public.table1 (a email_type);",
@@ -1181,7 +1435,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"a": {
 									Name:         "a",
@@ -1201,7 +1456,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"name": {
 									Name:         "name",
@@ -1237,7 +1493,8 @@ func TestReadSchema(t *testing.T) {
 					Name: "public",
 					Tables: map[string]*schema.Table{
 						"table1": {
-							Name: "table1",
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 							Columns: map[string]*schema.Column{
 								"id": {
 									Name:         "id",
@@ -1275,6 +1532,254 @@ func TestReadSchema(t *testing.T) {
 					},
 				},
 			},
+			{
+				name:       "replica identity full",
+				createStmt: "CREATE TABLE public.table1 (id int); ALTER TABLE public.table1 REPLICA IDENTITY FULL",
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "FULL"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				name:       "replica identity nothing",
+				createStmt: "CREATE TABLE public.table1 (id int); ALTER TABLE public.table1 REPLICA IDENTITY NOTHING",
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "NOTHING"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				name: "replica identity using index",
+				createStmt: `CREATE TABLE public.table1 (id int NOT NULL);
+					CREATE UNIQUE INDEX idx_id ON public.table1 (id);
+					ALTER TABLE public.table1 REPLICA IDENTITY USING INDEX idx_id`,
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "INDEX", Index: "idx_id"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     false,
+									Unique:       true,
+									PostgresType: "base",
+								},
+							},
+							Indexes: map[string]*schema.Index{
+								"idx_id": {
+									Name:       "idx_id",
+									Unique:     true,
+									Columns:    []string{"id"},
+									Method:     string(migrations.OpCreateIndexMethodBtree),
+									Definition: "CREATE UNIQUE INDEX idx_id ON public.table1 USING btree (id)",
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				name:       "generated column",
+				createStmt: `CREATE TABLE public.table1 (a int, b int GENERATED ALWAYS AS (a * 2) STORED)`,
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"a": {
+									Name:         "a",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+								"b": {
+									Name:         "b",
+									Type:         "integer",
+									Nullable:     true,
+									Generated:    ptr("(a * 2)"),
+									PostgresType: "base",
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				name: "disabled trigger",
+				createStmt: `CREATE TABLE public.table1 (id int);
+					CREATE FUNCTION public.noop() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$;
+					CREATE TRIGGER noop_trigger BEFORE INSERT ON public.table1 FOR EACH ROW EXECUTE FUNCTION public.noop();
+					ALTER TABLE public.table1 DISABLE TRIGGER noop_trigger`,
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+							Triggers: map[string]*schema.Trigger{
+								"noop_trigger": {
+									Name:  "noop_trigger",
+									State: "DISABLE",
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				name: "rule",
+				createStmt: `CREATE TABLE public.table1 (id int);
+					CREATE RULE protect_table1 AS ON DELETE TO public.table1 DO INSTEAD NOTHING`,
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"table1": {
+							Name:            "table1",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+							Rules: map[string]*schema.Rule{
+								"protect_table1": {
+									Name:       "protect_table1",
+									Definition: "CREATE RULE protect_table1 AS\n    ON DELETE TO public.table1 DO INSTEAD NOTHING;",
+									State:      "ENABLE",
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				name: "inheritance",
+				createStmt: `CREATE TABLE public.parent (id int);
+					CREATE TABLE public.child () INHERITS (public.parent)`,
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"parent": {
+							Name:            "parent",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+						},
+						"child": {
+							Name:            "child",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+							Inherits: []string{"parent"},
+						},
+					},
+				},
+			},
+			{
+				name: "partitioning",
+				createStmt: `CREATE TABLE public.events (id int, region text) PARTITION BY LIST (region);
+					CREATE TABLE public.events_eu PARTITION OF public.events FOR VALUES IN ('eu')`,
+				wantSchema: &schema.Schema{
+					Name: "public",
+					Tables: map[string]*schema.Table{
+						"events": {
+							Name:            "events",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+								"region": {
+									Name:         "region",
+									Type:         "text",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+							PartitionBy: &schema.PartitionKey{
+								Type:    "list",
+								Columns: []string{"region"},
+							},
+						},
+						"events_eu": {
+							Name:            "events_eu",
+							ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
+							Columns: map[string]*schema.Column{
+								"id": {
+									Name:         "id",
+									Type:         "integer",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+								"region": {
+									Name:         "region",
+									Type:         "text",
+									Nullable:     true,
+									PostgresType: "base",
+								},
+							},
+							PartitionOf: "events",
+						},
+					},
+				},
+			},
 		}
 
 		for _, tt := range tests {
@@ -1415,7 +1920,8 @@ func TestSchemaAfterMigration(t *testing.T) {
 
 			// Assert the schema after the first migration
 			expectedTable := &schema.Table{
-				Name: "items",
+				Name:            "items",
+				ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 				Columns: map[string]*schema.Column{
 					"id": {
 						Name:         "id",
@@ -1434,7 +1940,8 @@ func TestSchemaAfterMigration(t *testing.T) {
 
 			// Assert the schema after the second migration
 			expectedTable = &schema.Table{
-				Name: "items",
+				Name:            "items",
+				ReplicaIdentity: &schema.ReplicaIdentity{Type: "DEFAULT"},
 				Columns: map[string]*schema.Column{
 					"id": {
 						Name:         "id",
@@ -1462,3 +1969,31 @@ func clearOIDS(s *schema.Schema) {
 		s.Tables[k] = c
 	}
 }
+
+func TestNamingIsRecordedWithMigration(t *testing.T) {
+	t.Parallel()
+
+	testutils.WithStateAndConnectionToContainer(t, func(st *state.State, db *sql.DB) {
+		ctx := context.Background()
+
+		require.NoError(t, st.Start(ctx, "public", &migrations.Migration{Name: "01_empty"}, backfill.NewNaming("_acme_")))
+
+		naming, err := st.Naming(ctx, "public", "01_empty")
+		require.NoError(t, err)
+		assert.Equal(t, "_acme_", naming.Prefix())
+		assert.False(t, naming.IsLegacy())
+
+		prefixes, err := st.InternalPrefixes(ctx, "public")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{backfill.DefaultPrefix, "_acme_"}, prefixes)
+
+		// Migrations started before the prefix was recorded use the legacy
+		// naming
+		_, err = db.ExecContext(ctx, "UPDATE pgroll.migrations SET internal_prefix = NULL WHERE name = '01_empty'")
+		require.NoError(t, err)
+
+		naming, err = st.Naming(ctx, "public", "01_empty")
+		require.NoError(t, err)
+		assert.True(t, naming.IsLegacy())
+	})
+}
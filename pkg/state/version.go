@@ -60,10 +60,16 @@ func (s *State) VersionCompatibility(ctx context.Context) (VersionCompatibility,
 		return 0, fmt.Errorf("failed to get stored version: %w", err)
 	}
 
+	return compareVersions(schemaVersion, pgrollVersion), nil
+}
+
+// compareVersions compares the version of pgroll that created some state,
+// such as the state schema, with the version of pgroll using it.
+func compareVersions(schemaVersion, pgrollVersion string) VersionCompatibility {
 	// pgroll schemas created by development versions of pgroll are not checked
 	// for compatibility.
-	if schemaVersion == "development" {
-		return VersionCompatCheckSkipped, nil
+	if schemaVersion == "development" || pgrollVersion == "development" {
+		return VersionCompatCheckSkipped
 	}
 
 	// Ensure both versions have the 'v' prefix for compatibility with Go's
@@ -74,7 +80,7 @@ func (s *State) VersionCompatibility(ctx context.Context) (VersionCompatibility,
 	// If either the schema version or the pgroll version is invalid, do not make
 	// any assumptions about compatibility
 	if !semver.IsValid(schemaVersion) || !semver.IsValid(pgrollVersion) {
-		return VersionCompatCheckSkipped, nil
+		return VersionCompatCheckSkipped
 	}
 
 	// Canonicalize both versions to ensure they are in the correct format
@@ -84,13 +90,13 @@ func (s *State) VersionCompatibility(ctx context.Context) (VersionCompatibility,
 	// Compare versions
 	cmp := semver.Compare(schemaVersion, pgrollVersion)
 	if cmp < 0 {
-		return VersionCompatVersionSchemaOlder, nil
+		return VersionCompatVersionSchemaOlder
 	}
 	if cmp > 0 {
-		return VersionCompatVersionSchemaNewer, nil
+		return VersionCompatVersionSchemaNewer
 	}
 
-	return VersionCompatVersionSchemaEqual, nil
+	return VersionCompatVersionSchemaEqual
 }
 
 // schemaVersion retrieves the version stored in the pgroll_version table.
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// build-schema-defaults generates pkg/migrations/schema_defaults.go from the
+// default values declared in schema.json.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	schemaFile = "schema.json"
+	outputFile = "pkg/migrations/schema_defaults.go"
+	refPrefix  = "#/$defs/"
+)
+
+// node is the subset of a JSON schema node needed to find default values.
+type node struct {
+	Ref        string           `json:"$ref"`
+	Default    *json.RawMessage `json:"default"`
+	Properties map[string]*node `json:"properties"`
+	Items      *node            `json:"items"`
+	AnyOf      []*node          `json:"anyOf"`
+}
+
+type schema struct {
+	Defs map[string]*node `json:"$defs"`
+}
+
+type generator struct {
+	defs map[string]*node
+	// names of the $defs for which a variable is generated
+	used map[string]bool
+}
+
+func main() {
+	fmt.Println("Generating schema defaults...")
+
+	data, err := os.ReadFile(schemaFile)
+	if err != nil {
+		log.Fatalf("failed to read %s: %v", schemaFile, err)
+	}
+
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		log.Fatalf("failed to parse %s: %v", schemaFile, err)
+	}
+
+	g := &generator{defs: s.Defs, used: make(map[string]bool)}
+
+	var buf bytes.Buffer
+	buf.WriteString("// SPDX-License-Identifier: Apache-2.0\n\n")
+	buf.WriteString("// Code generated by tools/build-schema-defaults. DO NOT EDIT.\n\n")
+	buf.WriteString("package migrations\n\n")
+
+	// Map each operation name to the defaults for its $def
+	buf.WriteString("// operationDefaults maps operation names to the default values of their fields.\n")
+	buf.WriteString("var operationDefaults = map[OpName]*defaultsNode{\n")
+	for _, op := range s.Defs["PgRollOperation"].AnyOf {
+		for _, name := range sortedKeys(op.Properties) {
+			def := strings.TrimPrefix(op.Properties[name].Ref, refPrefix)
+			if !g.hasDefaults(s.Defs[def], nil) {
+				continue
+			}
+			g.used[def] = true
+			fmt.Fprintf(&buf, "\t%q: %s,\n", name, varName(def))
+		}
+	}
+	buf.WriteString("}\n")
+
+	// Generate a variable for every $def referenced from an operation,
+	// including those referenced by other $defs.
+	done := make(map[string]bool)
+	for {
+		var pending []string
+		for name := range g.used {
+			if !done[name] {
+				pending = append(pending, name)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		slices.Sort(pending)
+		for _, name := range pending {
+			done[name] = true
+			fmt.Fprintf(&buf, "\nvar %s = %s\n", varName(name), g.literal(s.Defs[name]))
+		}
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("failed to format generated code: %v", err)
+	}
+
+	if err := os.WriteFile(outputFile, src, 0o644); err != nil {
+		log.Fatalf("failed to write %s: %v", outputFile, err)
+	}
+
+	fmt.Println("Schema defaults generated successfully")
+}
+
+// resolve follows $refs and array items to the object node that describes
+// the properties of a value.
+func (g *generator) resolve(n *node) (*node, string) {
+	for n != nil {
+		switch {
+		case n.Ref != "":
+			name := strings.TrimPrefix(n.Ref, refPrefix)
+			return g.defs[name], name
+		case n.Items != nil:
+			n = n.Items
+		default:
+			return n, ""
+		}
+	}
+	return nil, ""
+}
+
+// hasDefaults returns true if the node or any nested node declares a default
+// value for one of its properties.
+func (g *generator) hasDefaults(n *node, seen []string) bool {
+	if n == nil {
+		return false
+	}
+	for _, prop := range n.Properties {
+		if prop.Default != nil {
+			return true
+		}
+		child, ref := g.resolve(prop)
+		if ref != "" && slices.Contains(seen, ref) {
+			continue
+		}
+		if g.hasDefaults(child, append(seen, ref)) {
+			return true
+		}
+	}
+	return false
+}
+
+// literal returns a Go expression for the defaultsNode describing n.
+func (g *generator) literal(n *node) string {
+	var defaults, properties []string
+	for _, name := range sortedKeys(n.Properties) {
+		prop := n.Properties[name]
+		if prop.Default != nil {
+			defaults = append(defaults, fmt.Sprintf("%q: %s,", name, goValue(*prop.Default)))
+			continue
+		}
+
+		child, ref := g.resolve(prop)
+		if !g.hasDefaults(child, []string{ref}) {
+			continue
+		}
+		if ref != "" {
+			g.used[ref] = true
+			properties = append(properties, fmt.Sprintf("%q: %s,", name, varName(ref)))
+		} else {
+			properties = append(properties, fmt.Sprintf("%q: %s,", name, g.literal(child)))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("&defaultsNode{\n")
+	if len(defaults) > 0 {
+		b.WriteString("defaults: map[string]any{\n" + strings.Join(defaults, "\n") + "\n},\n")
+	}
+	if len(properties) > 0 {
+		b.WriteString("properties: map[string]*defaultsNode{\n" + strings.Join(properties, "\n") + "\n},\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// goValue returns a Go expression for a JSON scalar default value.
+func goValue(raw json.RawMessage) string {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		log.Fatalf("failed to parse default value %s: %v", raw, err)
+	}
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case bool:
+		return fmt.Sprintf("%t", v)
+	case float64:
+		return fmt.Sprintf("%v", v)
+	default:
+		log.Fatalf("unsupported default value %s", raw)
+	}
+	return ""
+}
+
+func varName(def string) string {
+	return "defaults" + def
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}